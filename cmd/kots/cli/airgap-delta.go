@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	kotsairgap "github.com/replicatedhq/kots/pkg/airgap"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/kotsutil"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func AirgapDeltaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delta [airgap bundle]",
+		Short: "Create a delta airgap bundle that updates an installed release",
+		Long: `Writes a copy of a full airgap bundle that leaves out the image layers that are already in the registry.
+The layers of the images of the installed release are read from the registry, using the airgap images of its installation.yaml.
+The delta bundle can only be uploaded to an application that has that release installed.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			bundlePath := args[0]
+
+			installationPath := v.GetString("installation")
+			if installationPath == "" {
+				return errors.New("--installation is required")
+			}
+
+			registryEndpoint := v.GetString("kotsadm-registry")
+			if registryEndpoint == "" {
+				return errors.New("--kotsadm-registry is required")
+			}
+
+			outputPath := v.GetString("output")
+			if outputPath == "" {
+				outputPath = strings.TrimSuffix(bundlePath, ".airgap") + "-delta.airgap"
+			}
+
+			installation, err := kotsutil.LoadInstallationFromPath(installationPath)
+			if err != nil {
+				return errors.Wrap(err, "failed to load installation")
+			}
+			if len(installation.Spec.AirgapImages) == 0 {
+				return errors.Errorf("installation %s does not have airgap images, upload a full airgap bundle of the release first", installationPath)
+			}
+
+			log := logger.NewLogger()
+			log.ActionWithoutSpinner("Creating delta airgap bundle")
+
+			options := kotsairgap.CreateDeltaBundleOptions{
+				BundlePath:   bundlePath,
+				OutputPath:   outputPath,
+				Installation: installation,
+				Registry: registry.RegistryOptions{
+					Endpoint:  registryEndpoint,
+					Namespace: v.GetString("kotsadm-namespace"),
+					Username:  v.GetString("registry-username"),
+					Password:  v.GetString("registry-password"),
+				},
+				ProgressWriter: os.Stdout,
+			}
+			result, err := kotsairgap.CreateDeltaBundle(options)
+			if err != nil {
+				os.Remove(outputPath)
+				return errors.Wrap(err, "failed to create delta bundle")
+			}
+
+			log.ActionWithoutSpinner("Left out %d layers (%d bytes) that are in the registry", result.SkippedLayers, result.SkippedBytes)
+			log.ActionWithoutSpinner("Delta airgap bundle for version %s written to %s", installation.Spec.VersionLabel, outputPath)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("installation", "", "path to the installation.yaml of the installed release, as written by kots download")
+	cmd.Flags().String("kotsadm-registry", "", "registry endpoint where the images of the installed release were pushed")
+	cmd.Flags().String("kotsadm-namespace", "", "registry namespace of the images of the installed release")
+	cmd.Flags().String("registry-username", "", "user name to use to authenticate with the registry")
+	cmd.Flags().String("registry-password", "", "password to use to authenticate with the registry")
+	cmd.Flags().StringP("output", "o", "", "file to write the delta bundle to (defaults to <airgap bundle>-delta.airgap)")
+
+	return cmd
+}
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func AirgapCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "airgap",
+		Short:         "Provides wrapper functionality to interface with airgap bundles",
+		Long:          ``,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.Help()
+			return nil
+		},
+	}
+
+	cmd.AddCommand(AirgapDeltaCmd())
+
+	return cmd
+}
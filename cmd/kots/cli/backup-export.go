@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/snapshot"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func BackupExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "export [backup name]",
+		Short:         "Export a backup and its volume data as a portable archive",
+		Long:          ``,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			outputFile := v.GetString("output")
+			if outputFile == "" {
+				outputFile = fmt.Sprintf("%s.tar.gz", args[0])
+			}
+
+			options := snapshot.ExportInstanceBackupOptions{
+				BackupName:            args[0],
+				Namespace:             v.GetString("namespace"),
+				OutputFile:            outputFile,
+				KubernetesConfigFlags: kubernetesConfigFlags,
+			}
+			if err := snapshot.ExportInstanceBackup(options); err != nil {
+				return errors.Wrap(err, "failed to export instance backup")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("namespace", "n", "default", "namespace in which kots/kotsadm is installed")
+	cmd.Flags().StringP("output", "o", "", "file to write the archive to (defaults to <backup name>.tar.gz)")
+
+	return cmd
+}
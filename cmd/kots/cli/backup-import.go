@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/snapshot"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func BackupImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "import [archive]",
+		Short:         "Import a backup archive into the snapshot store so it can be restored",
+		Long:          ``,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			options := snapshot.ImportInstanceBackupOptions{
+				InputFile:             args[0],
+				Namespace:             v.GetString("namespace"),
+				KubernetesConfigFlags: kubernetesConfigFlags,
+				Wait:                  v.GetBool("wait"),
+				Timeout:               v.GetDuration("timeout"),
+			}
+			if err := snapshot.ImportInstanceBackup(options); err != nil {
+				return errors.Wrap(err, "failed to import instance backup")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("namespace", "n", "default", "namespace in which kots/kotsadm is installed")
+	cmd.Flags().Bool("wait", true, "wait for velero to sync the imported backup")
+	cmd.Flags().Duration("timeout", 5*time.Minute, "how long to wait for velero to sync the imported backup")
+
+	return cmd
+}
@@ -39,6 +39,8 @@ func BackupCmd() *cobra.Command {
 	cmd.Flags().Bool("wait", true, "wait for the backup to finish")
 
 	cmd.AddCommand(BackupListCmd())
+	cmd.AddCommand(BackupExportCmd())
+	cmd.AddCommand(BackupImportCmd())
 
 	return cmd
 }
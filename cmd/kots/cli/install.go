@@ -17,6 +17,7 @@ import (
 	"github.com/manifoldco/promptui"
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotsairgap "github.com/replicatedhq/kots/pkg/airgap"
 	"github.com/replicatedhq/kots/pkg/auth"
 	"github.com/replicatedhq/kots/pkg/identity"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
@@ -168,6 +169,7 @@ func InstallCmd() *cobra.Command {
 				HTTPSProxyEnvValue:        v.GetString("https-proxy"),
 				NoProxyEnvValue:           v.GetString("no-proxy"),
 				SkipPreflights:            v.GetBool("skip-preflights"),
+				OverrideStrictPreflights:  v.GetBool("override-strict-preflights"),
 
 				KotsadmOptions: *registryConfig,
 
@@ -220,6 +222,14 @@ func InstallCmd() *cobra.Command {
 					return errors.Wrap(err, "failed to extract images")
 				}
 
+				airgapMeta, err := pull.FindAirgapMetaInDir(airgapRootDir)
+				if err != nil {
+					return errors.Wrap(err, "failed to find airgap meta")
+				}
+				if err := kotsairgap.CheckDeltaBase(airgapMeta, nil); err != nil {
+					return err
+				}
+
 				deployOptions.AirgapRootDir = airgapRootDir
 			}
 
@@ -347,6 +357,7 @@ func InstallCmd() *cobra.Command {
 	cmd.Flags().String("airgap-bundle", "", "path to the application airgap bundle where application metadata will be loaded from")
 	cmd.Flags().Bool("airgap", false, "set to true to run install in airgapped mode. setting --airgap-bundle implies --airgap=true.")
 	cmd.Flags().Bool("skip-preflights", false, "set to true to skip preflight checks")
+	cmd.Flags().Bool("override-strict-preflights", false, "set to true to deploy even if strict preflight checks fail or are skipped, the override is recorded on the version")
 
 	cmd.Flags().String("repo", "", "repo uri to use when installing a helm chart")
 	cmd.Flags().StringSlice("set", []string{}, "values to pass to helm when running helm template")
@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	redacttypes "github.com/replicatedhq/kots/pkg/api/redact/types"
+	"github.com/replicatedhq/kots/pkg/auth"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/print"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type previewRedactResponse struct {
+	Preview *redacttypes.RedactPreview `json:"preview"`
+	Success bool                       `json:"success"`
+	Error   string                     `json:"error"`
+}
+
+func RedactPreviewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "preview [redactor spec file]",
+		Short: "Preview the values that a redactor spec removes from a support bundle without saving the spec",
+		Long: `Runs the redactor spec against a support bundle in the admin console (--bundle-id) or a support bundle archive (--bundle),
+and lists the matches in each file and the redactors that never matched.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			bundleID := v.GetString("bundle-id")
+			bundlePath := v.GetString("bundle")
+			if (bundleID == "") == (bundlePath == "") {
+				return errors.New("exactly one of --bundle-id or --bundle is required")
+			}
+
+			output := v.GetString("output")
+			if output != "json" && output != "" {
+				return errors.Errorf("output format %s not supported (allowed formats are: json)", output)
+			}
+
+			spec, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return errors.Wrap(err, "failed to read redactor spec")
+			}
+
+			log := logger.NewLogger()
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+
+			clientset, err := k8sutil.GetClientset(kubernetesConfigFlags)
+			if err != nil {
+				return errors.Wrap(err, "failed to get clientset")
+			}
+
+			namespace := v.GetString("namespace")
+			if err := validateNamespace(namespace); err != nil {
+				return errors.Wrap(err, "failed to validate namespace")
+			}
+
+			podName, err := k8sutil.FindKotsadm(clientset, namespace)
+			if err != nil {
+				return errors.Wrap(err, "failed to find kotsadm pod")
+			}
+
+			localPort, errChan, err := k8sutil.PortForward(kubernetesConfigFlags, 0, 3000, namespace, podName, false, stopCh, log)
+			if err != nil {
+				log.FinishSpinnerWithError()
+				return errors.Wrap(err, "failed to start port forwarding")
+			}
+
+			go func() {
+				select {
+				case err := <-errChan:
+					if err != nil {
+						log.Error(err)
+					}
+				case <-stopCh:
+				}
+			}()
+
+			authSlug, err := auth.GetOrCreateAuthSlug(kubernetesConfigFlags, namespace)
+			if err != nil {
+				log.FinishSpinnerWithError()
+				log.Info("Unable to authenticate to the Admin Console running in the %s namespace. Ensure you have read access to secrets in this namespace and try again.", namespace)
+				if v.GetBool("debug") {
+					return errors.Wrap(err, "failed to get kotsadm auth slug")
+				}
+				os.Exit(2) // not returning error here as we don't want to show the entire stack trace to normal users
+			}
+
+			var newReq *http.Request
+			if bundleID != "" {
+				newReq, err = newPreviewSupportBundleRedactRequest(fmt.Sprintf("http://localhost:%d/api/v1/redact/preview/supportbundle/%s", localPort, bundleID), string(spec))
+			} else {
+				newReq, err = newPreviewRedactRequest(fmt.Sprintf("http://localhost:%d/api/v1/redact/preview", localPort), string(spec), bundlePath)
+			}
+			if err != nil {
+				return errors.Wrap(err, "failed to create request")
+			}
+			newReq.Header.Add("Authorization", authSlug)
+
+			preview, err := previewRedact(newReq)
+			if err != nil {
+				return errors.Wrap(err, "failed to preview redactions")
+			}
+
+			print.RedactPreview(preview, output)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("namespace", "n", "default", "namespace in which kots/kotsadm is installed")
+	cmd.Flags().String("bundle-id", "", "id of a support bundle in the admin console to run the redactor spec against")
+	cmd.Flags().String("bundle", "", "path to a support bundle archive to run the redactor spec against")
+	cmd.Flags().StringP("output", "o", "", "Output format. Supported values: json")
+
+	return cmd
+}
+
+func newPreviewSupportBundleRedactRequest(url string, spec string) (*http.Request, error) {
+	body, err := json.Marshal(map[string]string{
+		"redactSpec": spec,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal request")
+	}
+
+	newReq, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	newReq.Header.Add("Content-Type", "application/json")
+
+	return newReq, nil
+}
+
+func newPreviewRedactRequest(url string, spec string, bundlePath string) (*http.Request, error) {
+	body := &bytes.Buffer{}
+	bodyWriter := multipart.NewWriter(body)
+
+	if err := bodyWriter.WriteField("redactSpec", spec); err != nil {
+		return nil, errors.Wrap(err, "failed to add redactor spec")
+	}
+
+	fileWriter, err := bodyWriter.CreateFormFile("bundle", filepath.Base(bundlePath))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create form from file")
+	}
+
+	fileReader, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open support bundle")
+	}
+	defer fileReader.Close()
+
+	if _, err := io.Copy(fileWriter, fileReader); err != nil {
+		return nil, errors.Wrap(err, "failed to copy support bundle")
+	}
+
+	contentType := bodyWriter.FormDataContentType()
+	bodyWriter.Close()
+
+	newReq, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	newReq.Header.Add("Content-Type", contentType)
+
+	return newReq, nil
+}
+
+func previewRedact(newReq *http.Request) (*redacttypes.RedactPreview, error) {
+	resp, err := http.DefaultClient.Do(newReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read")
+	}
+
+	response := previewRedactResponse{}
+	if err := json.Unmarshal(b, &response); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal response with status code %d", resp.StatusCode)
+	}
+
+	if !response.Success {
+		return nil, errors.New(response.Error)
+	}
+
+	return response.Preview, nil
+}
@@ -5,7 +5,9 @@ import (
 	"os"
 
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/print"
 	"github.com/replicatedhq/kots/pkg/snapshot"
+	snapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -33,7 +35,27 @@ func RestoreCmd() *cobra.Command {
 				BackupName:            backupName,
 				KubernetesConfigFlags: kubernetesConfigFlags,
 				WaitForApps:           v.GetBool("wait-for-apps"),
+				RestoreOptions: &snapshottypes.RestoreOptions{
+					IncludeNamespaces: v.GetStringSlice("include-namespaces"),
+					ExcludeNamespaces: v.GetStringSlice("exclude-namespaces"),
+					IncludeResources:  v.GetStringSlice("include-resources"),
+					ExcludeResources:  v.GetStringSlice("exclude-resources"),
+					IncludeLabels:     v.GetStringSlice("include-labels"),
+					ExcludeLabels:     v.GetStringSlice("exclude-labels"),
+					IncludeVolumes:    v.GetStringSlice("include-volumes"),
+					ExcludeVolumes:    v.GetStringSlice("exclude-volumes"),
+				},
 			}
+
+			if v.GetBool("dry-run") {
+				plans, err := snapshot.PlanInstanceRestore(options)
+				if err != nil {
+					return errors.Wrap(err, "failed to plan instance restore")
+				}
+				print.RestorePlans(plans)
+				return nil
+			}
+
 			_, err := snapshot.RestoreInstanceBackup(options)
 			if err != nil {
 				return errors.Wrap(err, "failed to restore instance backup")
@@ -45,6 +67,15 @@ func RestoreCmd() *cobra.Command {
 
 	cmd.Flags().String("from-backup", "", "the name of the backup to restore from")
 	cmd.Flags().Bool("wait-for-apps", true, "wait for all applications to be restored")
+	cmd.Flags().StringSlice("include-namespaces", []string{}, "only restore application resources in these namespaces")
+	cmd.Flags().StringSlice("exclude-namespaces", []string{}, "do not restore application resources in these namespaces")
+	cmd.Flags().StringSlice("include-resources", []string{}, "only restore these application resource kinds (e.g. persistentvolumeclaims)")
+	cmd.Flags().StringSlice("exclude-resources", []string{}, "do not restore these application resource kinds")
+	cmd.Flags().StringSlice("include-labels", []string{}, "only restore application resources with these labels (key=value or key)")
+	cmd.Flags().StringSlice("exclude-labels", []string{}, "do not restore application resources with these labels (key=value or key)")
+	cmd.Flags().StringSlice("include-volumes", []string{}, "only restore these pod volumes (namespace/pod/volume) and their pods")
+	cmd.Flags().StringSlice("exclude-volumes", []string{}, "do not restore these pod volumes (namespace/pod/volume)")
+	cmd.Flags().Bool("dry-run", false, "show what would be restored for each application without restoring anything")
 
 	cmd.AddCommand(RestoreListCmd())
 
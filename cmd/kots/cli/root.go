@@ -39,6 +39,9 @@ func RootCmd() *cobra.Command {
 	cmd.AddCommand(IdentityServiceCmd())
 	cmd.AddCommand(AppStatusCmd())
 	cmd.AddCommand(GetCmd())
+	cmd.AddCommand(SupportBundleCmd())
+	cmd.AddCommand(RedactCmd())
+	cmd.AddCommand(AirgapCmd())
 
 	viper.BindPFlags(cmd.Flags())
 
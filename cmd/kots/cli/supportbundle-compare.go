@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+	supportbundletypes "github.com/replicatedhq/kots/pkg/api/supportbundle/types"
+	"github.com/replicatedhq/kots/pkg/auth"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/print"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func SupportBundleCompareCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare [base bundle id] [target bundle id]",
+		Short: "Compare two support bundles of an application",
+		Long: `Lists the analyzers that changed outcome, the changed cluster resources and node conditions,
+the logs that newly contain errors, and the differences in the application config and version between two support bundles.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 2 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			output := v.GetString("output")
+			if output != "json" && output != "" {
+				return errors.Errorf("output format %s not supported (allowed formats are: json)", output)
+			}
+
+			log := logger.NewLogger()
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+
+			clientset, err := k8sutil.GetClientset(kubernetesConfigFlags)
+			if err != nil {
+				return errors.Wrap(err, "failed to get clientset")
+			}
+
+			namespace := v.GetString("namespace")
+			if err := validateNamespace(namespace); err != nil {
+				return errors.Wrap(err, "failed to validate namespace")
+			}
+
+			podName, err := k8sutil.FindKotsadm(clientset, namespace)
+			if err != nil {
+				return errors.Wrap(err, "failed to find kotsadm pod")
+			}
+
+			localPort, errChan, err := k8sutil.PortForward(kubernetesConfigFlags, 0, 3000, namespace, podName, false, stopCh, log)
+			if err != nil {
+				log.FinishSpinnerWithError()
+				return errors.Wrap(err, "failed to start port forwarding")
+			}
+
+			go func() {
+				select {
+				case err := <-errChan:
+					if err != nil {
+						log.Error(err)
+					}
+				case <-stopCh:
+				}
+			}()
+
+			authSlug, err := auth.GetOrCreateAuthSlug(kubernetesConfigFlags, namespace)
+			if err != nil {
+				log.FinishSpinnerWithError()
+				log.Info("Unable to authenticate to the Admin Console running in the %s namespace. Ensure you have read access to secrets in this namespace and try again.", namespace)
+				if v.GetBool("debug") {
+					return errors.Wrap(err, "failed to get kotsadm auth slug")
+				}
+				os.Exit(2) // not returning error here as we don't want to show the entire stack trace to normal users
+			}
+
+			url := fmt.Sprintf("http://localhost:%d/api/v1/troubleshoot/supportbundle/%s/compare/%s", localPort, args[0], args[1])
+			comparison, err := compareSupportBundles(url, authSlug)
+			if err != nil {
+				return errors.Wrap(err, "failed to compare support bundles")
+			}
+
+			print.SupportBundleComparison(comparison, output)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("namespace", "n", "default", "namespace in which kots/kotsadm is installed")
+	cmd.Flags().StringP("output", "o", "", "Output format. Supported values: json")
+
+	return cmd
+}
+
+func compareSupportBundles(url string, authSlug string) (*supportbundletypes.BundleComparison, error) {
+	newReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	newReq.Header.Add("Content-Type", "application/json")
+	newReq.Header.Add("Authorization", authSlug)
+
+	resp, err := http.DefaultClient.Do(newReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errorResponse := struct {
+			Error string `json:"error"`
+		}{}
+		if err := json.Unmarshal(b, &errorResponse); err == nil && errorResponse.Error != "" {
+			return nil, errors.New(errorResponse.Error)
+		}
+		return nil, errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	comparison := &supportbundletypes.BundleComparison{}
+	if err := json.Unmarshal(b, comparison); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal comparison")
+	}
+
+	return comparison, nil
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	kotsairgap "github.com/replicatedhq/kots/pkg/airgap"
 	"github.com/replicatedhq/kots/pkg/auth"
 	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
@@ -21,6 +22,7 @@ import (
 	kotsadmtypes "github.com/replicatedhq/kots/pkg/kotsadm/types"
 	"github.com/replicatedhq/kots/pkg/kotsutil"
 	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/pull"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	kustomizetypes "sigs.k8s.io/kustomize/api/types"
@@ -82,6 +84,11 @@ func UpstreamUpgradeCmd() *cobra.Command {
 					return errors.Wrap(err, "failed to extract images")
 				}
 
+				airgapMeta, err := pull.FindAirgapMetaInDir(airgapRootDir)
+				if err != nil {
+					return errors.Wrap(err, "failed to find airgap meta")
+				}
+
 				pushOptions := kotsadmtypes.PushImagesOptions{
 					Registry: registry.RegistryOptions{
 						Endpoint:  registryEndpoint,
@@ -92,6 +99,13 @@ func UpstreamUpgradeCmd() *cobra.Command {
 					ProgressWriter: os.Stdout,
 				}
 
+				if airgapMeta != nil && airgapMeta.Spec.IsDelta {
+					err := kotsairgap.RehydrateDeltaImages(airgapRootDir, airgapMeta, pushOptions.Registry, os.Stdout)
+					if err != nil {
+						return errors.Wrap(err, "failed to restore images of delta bundle")
+					}
+				}
+
 				imagesRootDir := filepath.Join(airgapRootDir, "images")
 				images, err = kotsadm.TagAndPushAppImages(imagesRootDir, pushOptions)
 				if err != nil {
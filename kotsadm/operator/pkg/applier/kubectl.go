@@ -2,6 +2,7 @@ package applier
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -56,18 +57,25 @@ func (c *Kubectl) connectArgs() []string {
 	return args
 }
 
-func (c *Kubectl) SupportBundle(collectorURI string) error {
+// SupportBundle runs the support-bundle command, onProgress is called for every collector that it starts or that fails
+// SupportBundle runs the support-bundle command until it exits, or is stopped when the context is done
+func (c *Kubectl) SupportBundle(ctx context.Context, collectorURI string, onProgress func(progress SupportBundleCollectorProgress)) error {
 	log.Printf("running kubectl supportBundle %s", collectorURI)
 	args := []string{
 		"--collect-without-permissions",
+		"--interactive=false",
 		collectorURI,
 	}
 
-	cmd := c.supportBundleCommand(args...)
+	cmd := c.supportBundleCommand(ctx, args...)
 	cmd.Env = os.Environ()
 	cmd.Dir = "/tmp"
 
-	stdout, stderr, err := Run(cmd)
+	stdout, stderr, err := RunWithProgress(cmd, func(line string) {
+		if progress := ParseSupportBundleProgress(line); progress != nil {
+			onProgress(*progress)
+		}
+	})
 	if err != nil {
 		log.Printf("error running kubectl support-bundle: \n stderr %s\n stdout %s", stderr, stdout)
 		return errors.Wrap(err, "failed to run kubectl support-bundle")
@@ -182,15 +190,15 @@ func (c *Kubectl) kubectlCommand(args ...string) *exec.Cmd {
 	return exec.Command(c.kubectl, append(args, c.connectArgs()...)...)
 }
 
-func (c *Kubectl) supportBundleCommand(args ...string) *exec.Cmd {
+func (c *Kubectl) supportBundleCommand(ctx context.Context, args ...string) *exec.Cmd {
 	if c.supportBundle != "" {
 		allArgs := append(args, c.connectArgs()...)
-		return exec.Command(c.supportBundle, allArgs...)
+		return exec.CommandContext(ctx, c.supportBundle, allArgs...)
 	}
 
 	allArgs := append([]string{"support-bundle"}, args...)
 	allArgs = append(allArgs, c.connectArgs()...)
-	return exec.Command(c.kubectl, allArgs...)
+	return exec.CommandContext(ctx, c.kubectl, allArgs...)
 }
 
 func (c *Kubectl) preflightCommand(args ...string) *exec.Cmd {
@@ -1,12 +1,19 @@
 package applier
 
 import (
+	"bufio"
+	"bytes"
+	"io"
 	"io/ioutil"
 	"os/exec"
+	"sync"
 
 	"github.com/pkg/errors"
 )
 
+// maxProgressLineSize is the longest line RunWithProgress passes to onLine, the rest of the output is read without calling it
+const maxProgressLineSize = 1024 * 1024
+
 func Run(cmd *exec.Cmd) ([]byte, []byte, error) {
 	stdoutReader, err := cmd.StdoutPipe()
 	if err != nil {
@@ -31,3 +38,70 @@ func Run(cmd *exec.Cmd) ([]byte, []byte, error) {
 	err = cmd.Wait()
 	return stdout, stderr, err
 }
+
+// RunWithProgress is Run for long running commands, it calls onLine for every line of output as it's written
+func RunWithProgress(cmd *exec.Cmd, onLine func(line string)) ([]byte, []byte, error) {
+	stdoutReader, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to create stdout reader")
+	}
+	defer stdoutReader.Close()
+
+	stderrReader, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to create stderr reader")
+	}
+	defer stderrReader.Close()
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to start commmand")
+	}
+
+	var stdout, stderr bytes.Buffer
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	scan := func(r io.Reader, buf *bytes.Buffer) {
+		defer wg.Done()
+		// the output is read to the end even when a line is too long to be passed to onLine,
+		// a pipe that is not read blocks the command and cmd.Wait would never return
+		reader := bufio.NewReaderSize(r, 64*1024)
+		line := []byte{}
+		tooLong := false
+		for {
+			chunk, isPrefix, err := reader.ReadLine()
+			if err != nil {
+				return
+			}
+			buf.Write(chunk)
+
+			if !tooLong && len(line)+len(chunk) > maxProgressLineSize {
+				tooLong = true
+				line = line[:0]
+			}
+			if !tooLong {
+				line = append(line, chunk...)
+			}
+			if isPrefix {
+				continue
+			}
+			buf.WriteString("\n")
+
+			if !tooLong {
+				mtx.Lock()
+				onLine(string(line))
+				mtx.Unlock()
+			}
+			line = line[:0]
+			tooLong = false
+		}
+	}
+
+	wg.Add(2)
+	go scan(stdoutReader, &stdout)
+	go scan(stderrReader, &stderr)
+	wg.Wait()
+
+	err = cmd.Wait()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
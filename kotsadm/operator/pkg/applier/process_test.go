@@ -0,0 +1,85 @@
+package applier
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRunWithProgress(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "echo cluster-info; echo cluster-resources; echo 'failed to run collector \"logs\"' >&2")
+
+	lines := []string{}
+	stdout, stderr, err := RunWithProgress(cmd, func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatalf("RunWithProgress() error = %v", err)
+	}
+
+	// stdout and stderr are read concurrently, so the order of lines across them is not guaranteed
+	sort.Strings(lines)
+	wantLines := []string{"cluster-info", "cluster-resources", `failed to run collector "logs"`}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("RunWithProgress() lines = %v, want %v", lines, wantLines)
+	}
+	if string(stdout) != "cluster-info\ncluster-resources\n" {
+		t.Errorf("RunWithProgress() stdout = %q", stdout)
+	}
+	if string(stderr) != "failed to run collector \"logs\"\n" {
+		t.Errorf("RunWithProgress() stderr = %q", stderr)
+	}
+}
+
+func TestRunWithProgressLongLines(t *testing.T) {
+	// lines longer than the read buffer are passed to onLine, lines that are too long are only kept in the output
+	for _, size := range []int{100 * 1024, 2 * maxProgressLineSize} {
+		t.Run(fmt.Sprintf("%d", size), func(t *testing.T) {
+			cmd := exec.Command("sh", "-c", fmt.Sprintf("head -c %d /dev/zero | tr '\\0' 'a'; echo; echo done", size))
+
+			lines := []string{}
+			longLines := 0
+			stdout, _, err := RunWithProgress(cmd, func(line string) {
+				if len(line) == size {
+					longLines++
+				} else {
+					lines = append(lines, line)
+				}
+			})
+			if err != nil {
+				t.Fatalf("RunWithProgress() error = %v", err)
+			}
+			if len(stdout) != size+len("\ndone\n") {
+				t.Errorf("RunWithProgress() stdout length = %d, want %d", len(stdout), size+len("\ndone\n"))
+			}
+			if !reflect.DeepEqual(lines, []string{"done"}) {
+				t.Errorf("RunWithProgress() lines = %v, want [done]", lines)
+			}
+			wantLongLines := 1
+			if size > maxProgressLineSize {
+				wantLongLines = 0
+			}
+			if longLines != wantLongLines {
+				t.Errorf("RunWithProgress() long lines = %d, want %d", longLines, wantLongLines)
+			}
+		})
+	}
+}
+
+func TestRunWithProgressContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, "sleep", "10")
+	if _, _, err := RunWithProgress(cmd, func(line string) {}); err == nil {
+		t.Error("RunWithProgress() expected error for a stopped command")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("RunWithProgress() did not stop the command, took %s", time.Since(start))
+	}
+}
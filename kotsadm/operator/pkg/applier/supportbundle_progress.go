@@ -0,0 +1,44 @@
+package applier
+
+import (
+	"regexp"
+	"strings"
+)
+
+// the support-bundle command writes the name of every collector when it starts it, names are the collector kind
+// optionally followed by the collector name or selector, as in "cluster-info" or "logs/app=kotsadm"
+var (
+	collectorStartedRegexp = regexp.MustCompile(`^(cluster-info|cluster-resources|secret|configmap|logs|run|exec|copy|http|data|postgres|mysql|redis|ceph|longhorn|registry-images|collectd)(/\S+)?$`)
+	collectorFailedRegexp  = regexp.MustCompile(`failed to run collector "([^"]+)"`)
+)
+
+// SupportBundleCollectorProgress is a line of output of the support-bundle command that reports a collector starting or failing
+type SupportBundleCollectorProgress struct {
+	Collector string
+	Failed    bool
+	Message   string
+}
+
+// ParseSupportBundleProgress returns nil for lines of output that are not about a collector
+func ParseSupportBundleProgress(line string) *SupportBundleCollectorProgress {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	if matches := collectorFailedRegexp.FindStringSubmatch(line); len(matches) == 2 {
+		return &SupportBundleCollectorProgress{
+			Collector: matches[1],
+			Failed:    true,
+			Message:   strings.TrimLeft(line, "* "),
+		}
+	}
+
+	if collectorStartedRegexp.MatchString(line) {
+		return &SupportBundleCollectorProgress{
+			Collector: line,
+		}
+	}
+
+	return nil
+}
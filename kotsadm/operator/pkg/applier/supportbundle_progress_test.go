@@ -0,0 +1,51 @@
+package applier
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSupportBundleProgress(t *testing.T) {
+	tests := []struct {
+		line string
+		want *SupportBundleCollectorProgress
+	}{
+		{
+			line: "cluster-info",
+			want: &SupportBundleCollectorProgress{Collector: "cluster-info"},
+		},
+		{
+			line: "  logs/app=kotsadm\n",
+			want: &SupportBundleCollectorProgress{Collector: "logs/app=kotsadm"},
+		},
+		{
+			line: ` * failed to run collector "run/ping": timeout`,
+			want: &SupportBundleCollectorProgress{Collector: "run/ping", Failed: true, Message: `failed to run collector "run/ping": timeout`},
+		},
+		{
+			line: "Collecting support bundle",
+			want: nil,
+		},
+		{
+			line: "running collectors",
+			want: nil,
+		},
+		{
+			line: "error: context deadline exceeded",
+			want: nil,
+		},
+		{
+			line: "",
+			want: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			got := ParseSupportBundleProgress(test.line)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseSupportBundleProgress() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
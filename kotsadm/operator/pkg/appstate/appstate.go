@@ -85,6 +85,14 @@ func (m *Monitor) run(ctx context.Context) {
 
 		case appInformer := <-m.appInformersCh:
 			appMonitor, ok := appMonitors[appInformer.appID]
+			if len(appInformer.informers) == 0 {
+				// the app was removed, stop watching its resources
+				if ok {
+					appMonitor.Shutdown()
+					delete(appMonitors, appInformer.appID)
+				}
+				continue
+			}
 			if !ok {
 				appMonitor = NewAppMonitor(m.clientset, m.targetNamespace, appInformer.appID)
 				go func() {
@@ -140,10 +148,13 @@ func (m *AppMonitor) run(ctx context.Context) {
 	defer close(m.informersCh)
 	defer close(m.appStatusCh)
 
+	var informersWg sync.WaitGroup
 	prevCancel := context.CancelFunc(func() {})
 	defer func() {
 		// wrap this in a function to cancel the variable when updated
 		prevCancel()
+		// the informers send to the app status channel, it can only be closed once they have stopped
+		informersWg.Wait()
 	}()
 
 	for {
@@ -158,7 +169,11 @@ func (m *AppMonitor) run(ctx context.Context) {
 
 			ctx, cancel := context.WithCancel(ctx)
 			prevCancel = cancel
-			go m.runInformers(ctx, informers)
+			informersWg.Add(1)
+			go func() {
+				defer informersWg.Done()
+				m.runInformers(ctx, informers)
+			}()
 		}
 	}
 }
@@ -175,11 +190,21 @@ func (m *AppMonitor) runInformers(ctx context.Context, informers []types.StatusI
 		ResourceStates: buildResourceStatesFromStatusInformers(informers),
 		UpdatedAt:      time.Now(),
 	}
-	m.appStatusCh <- appStatus // reset last app status
+	// reset last app status
+	select {
+	case m.appStatusCh <- appStatus:
+	case <-ctx.Done():
+		return
+	}
 
 	var shutdown sync.WaitGroup
 	resourceStateCh := make(chan types.ResourceState)
 	defer func() {
+		// keep reading so that the event handlers do not block the informers from stopping
+		go func() {
+			for range resourceStateCh {
+			}
+		}()
 		shutdown.Wait()
 		close(resourceStateCh)
 	}()
@@ -227,7 +252,11 @@ func (m *AppMonitor) runInformers(ctx context.Context, informers []types.StatusI
 		case resourceState := <-resourceStateCh:
 			appStatus.ResourceStates, _ = resourceStatesApplyNew(appStatus.ResourceStates, informers, resourceState)
 			appStatus.UpdatedAt = time.Now() // TODO: this should come from the informer
-			m.appStatusCh <- appStatus
+			select {
+			case m.appStatusCh <- appStatus:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
 }
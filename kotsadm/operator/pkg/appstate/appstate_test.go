@@ -0,0 +1,29 @@
+package appstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/kots/kotsadm/operator/pkg/appstate/types"
+)
+
+func TestAppMonitorShutdown(t *testing.T) {
+	m := NewAppMonitor(nil, "default", "app")
+	m.Apply([]types.StatusInformer{
+		{Kind: "unsupported", Name: "web", Namespace: "default"},
+	})
+	m.Shutdown()
+
+	// the status channel is closed once the informers have stopped, whether or not their status was read
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case _, ok := <-m.AppStatusChan():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("app monitor did not stop")
+		}
+	}
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"os/exec"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mitchellh/hashstructure"
@@ -29,6 +31,9 @@ var (
 	PollInterval = time.Second * 10
 )
 
+// defaultSupportBundleGracePeriod is how long the support-bundle command can run past its timeout when kotsadm doesn't send one
+const defaultSupportBundleGracePeriod = 2 * time.Minute
+
 type ApplicationManifests struct {
 	AppID                string   `json:"app_id"`
 	AppSlug              string   `json:"app_slug"`
@@ -59,11 +64,29 @@ type PreflightRequest struct {
 
 type SupportBundleRequest struct {
 	URI string `json:"uri"`
+	// BundleID, TimeoutSeconds, GracePeriodSeconds and ProgressCallback are not sent by older versions of kotsadm
+	BundleID           string `json:"bundleId"`
+	TimeoutSeconds     int    `json:"timeoutSeconds"`
+	GracePeriodSeconds int    `json:"gracePeriodSeconds"`
+	ProgressCallback   string `json:"progressCallback"`
+}
+
+// SupportBundleProgress is reported to kotsadm for every collector that the support-bundle command starts or that fails,
+// when the collection times out, and once more when the command exits
+type SupportBundleProgress struct {
+	Collector       string `json:"collector,omitempty"`
+	CollectorStatus string `json:"collectorStatus,omitempty"`
+	Message         string `json:"message,omitempty"`
+	Done            bool   `json:"done,omitempty"`
+	Error           string `json:"error,omitempty"`
+	TimedOut        bool   `json:"timedOut,omitempty"`
 }
 
 type InformRequest struct {
 	AppID     string                       `json:"app_id"`
 	Informers []types.StatusInformerString `json:"informers"`
+	// Verification is set for the informers of the scratch namespaces a backup is verified in
+	Verification bool `json:"verification"`
 }
 
 type Client struct {
@@ -308,7 +331,7 @@ func (c *Client) registerHandlers(socketClient *socket.Client) error {
 			startTime := time.Now()
 			// This is in a goroutine because if we disconnect and reconnect to the
 			// websocket, we will want to report that it's completed...
-			err := runSupportBundle(args.URI)
+			err := c.runSupportBundle(args)
 			log.Printf("support bundle run completed in %s", time.Since(startTime).String())
 			if err != nil {
 				log.Printf("error running support bundle: %s", err.Error())
@@ -321,7 +344,7 @@ func (c *Client) registerHandlers(socketClient *socket.Client) error {
 
 	err = socketClient.On("appInformers", func(h *socket.Channel, args InformRequest) {
 		log.Printf("received an inform event: %#v", args)
-		c.applyAppInformers(args.AppID, args.Informers)
+		c.applyAppInformers(args.AppID, args.Informers, args.Verification)
 	})
 	if err != nil {
 		return errors.Wrap(err, "failed to add inform handler")
@@ -375,7 +398,7 @@ func (c *Client) sendResult(applicationManifests ApplicationManifests, isError b
 	return nil
 }
 
-func runSupportBundle(collectorURI string) error {
+func (c *Client) runSupportBundle(args SupportBundleRequest) error {
 	kubectl, err := exec.LookPath("kubectl")
 	if err != nil {
 		return errors.Wrap(err, "failed to find kubectl")
@@ -400,7 +423,89 @@ func runSupportBundle(collectorURI string) error {
 
 	kubernetesApplier := applier.NewKubectl(kubectl, preflight, supportBundle, config)
 
-	return kubernetesApplier.SupportBundle(collectorURI)
+	ctx := context.Background()
+	var timedOut int32
+	if args.TimeoutSeconds > 0 {
+		// the command is not stopped on timeout, everything that was collected would be lost.
+		// kotsadm fails the collectors that are still running so that the bundle is partial when it's uploaded,
+		// and the command is stopped if it does not finish within the grace period.
+		timeout := time.Duration(args.TimeoutSeconds) * time.Second
+		timer := time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			if err := c.sendSupportBundleProgress(args.ProgressCallback, SupportBundleProgress{TimedOut: true}); err != nil {
+				log.Printf("failed to report support bundle progress: %s", err.Error())
+			}
+		})
+		defer timer.Stop()
+
+		gracePeriod := time.Duration(args.GracePeriodSeconds) * time.Second
+		if gracePeriod <= 0 {
+			gracePeriod = defaultSupportBundleGracePeriod
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout+gracePeriod)
+		defer cancel()
+	}
+
+	onProgress := func(collectorProgress applier.SupportBundleCollectorProgress) {
+		progress := SupportBundleProgress{
+			Collector:       collectorProgress.Collector,
+			CollectorStatus: "running",
+			Message:         collectorProgress.Message,
+		}
+		if collectorProgress.Failed {
+			progress.CollectorStatus = "failed"
+		}
+		if err := c.sendSupportBundleProgress(args.ProgressCallback, progress); err != nil {
+			log.Printf("failed to report support bundle progress: %s", err.Error())
+		}
+	}
+
+	runErr := kubernetesApplier.SupportBundle(ctx, args.URI, onProgress)
+
+	progress := SupportBundleProgress{
+		Done:     true,
+		TimedOut: atomic.LoadInt32(&timedOut) == 1,
+	}
+	if runErr != nil {
+		progress.Error = runErr.Error()
+	}
+	if err := c.sendSupportBundleProgress(args.ProgressCallback, progress); err != nil {
+		log.Printf("failed to report support bundle progress: %s", err.Error())
+	}
+
+	return runErr
+}
+
+func (c *Client) sendSupportBundleProgress(progressCallback string, progress SupportBundleProgress) error {
+	if progressCallback == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(progress)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal progress")
+	}
+
+	uri := fmt.Sprintf("%s%s", c.APIEndpoint, progressCallback)
+
+	req, err := http.NewRequest("PUT", uri, bytes.NewBuffer(b))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("", c.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status code from kotsadm server: %d", resp.StatusCode)
+	}
+
+	return nil
 }
 
 func runPreflight(preflightURI string, ignorePermissions bool) error {
@@ -431,17 +536,23 @@ func runPreflight(preflightURI string, ignorePermissions bool) error {
 	return kubernetesApplier.Preflight(preflightURI, ignorePermissions)
 }
 
-func (c *Client) applyAppInformers(appID string, informerStrings []types.StatusInformerString) {
+func (c *Client) applyAppInformers(appID string, informerStrings []types.StatusInformerString, verification bool) {
 	var informers []types.StatusInformer
 	for _, str := range informerStrings {
 		informer, err := str.Parse()
 		if err != nil {
+			if verification {
+				// a verification that only watches the informers that did parse could pass without the missing resources
+				log.Printf(fmt.Sprintf("failed to parse informer %s, skipping verification %s: %s", str, appID, err.Error()))
+				return
+			}
 			log.Printf(fmt.Sprintf("failed to parse informer %s: %s", str, err.Error()))
 			continue // don't stop
 		}
 		informers = append(informers, informer)
 	}
-	if len(informers) > 0 {
+	// an empty list stops the informers of a verification once it is done
+	if len(informers) > 0 || verification {
 		c.appStateMonitor.Apply(appID, informers)
 	}
 }
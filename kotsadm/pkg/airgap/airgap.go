@@ -23,6 +23,7 @@ import (
 	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle"
 	"github.com/replicatedhq/kots/kotsadm/pkg/version"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotsairgap "github.com/replicatedhq/kots/pkg/airgap"
 	"github.com/replicatedhq/kots/pkg/archives"
 	"github.com/replicatedhq/kots/pkg/crypto"
 	"github.com/replicatedhq/kots/pkg/kotsutil"
@@ -34,7 +35,7 @@ import (
 // This function assumes that there's an app in the database that doesn't have a version
 // After execution, there will be a sequence 0 of the app, and all clusters in the database
 // will also have a version
-func CreateAppFromAirgap(pendingApp *types.PendingApp, airgapPath string, registryHost string, namespace string, username string, password string, isAutomated bool, skipPreflights bool) (finalError error) {
+func CreateAppFromAirgap(pendingApp *types.PendingApp, airgapPath string, registryHost string, namespace string, username string, password string, isAutomated bool, skipPreflights bool, overrideStrictPreflights bool) (finalError error) {
 	if err := store.GetStore().SetTaskStatus("airgap-install", "Processing package...", "running"); err != nil {
 		return errors.Wrap(err, "failed to set task status")
 	}
@@ -93,6 +94,16 @@ func CreateAppFromAirgap(pendingApp *types.PendingApp, airgapPath string, regist
 		archiveDir = dir
 	}
 
+	airgapMeta, err := pull.FindAirgapMetaInDir(archiveDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to find airgap meta")
+	}
+
+	// there's no release in the registry to restore the layers of a delta bundle from
+	if err := kotsairgap.CheckDeltaBase(airgapMeta, nil); err != nil {
+		return errors.Wrap(err, "failed to check delta bundle base")
+	}
+
 	// extract the release
 	workspace, err := ioutil.TempDir("", "kots-airgap")
 	if err != nil {
@@ -229,11 +240,29 @@ func CreateAppFromAirgap(pendingApp *types.PendingApp, airgapPath string, regist
 		return errors.Wrap(err, "failed to set app is airgap the second time")
 	}
 
+	// strict preflight checks can't be skipped, only overridden
+	if skipPreflights && !overrideStrictPreflights {
+		hasStrictAnalyzers, err := preflight.HasStrictAnalyzers(pendingApp.ID, pendingApp.Slug, 0, true, tmpRoot)
+		if err != nil {
+			return errors.Wrap(err, "failed to check for strict analyzers")
+		}
+		if hasStrictAnalyzers {
+			logger.Info("not skipping preflight checks because the release has strict preflight checks")
+			skipPreflights = false
+		}
+	}
+
 	newSequence, err := version.CreateFirstVersion(a.ID, tmpRoot, "Airgap Upload", skipPreflights)
 	if err != nil {
 		return errors.Wrap(err, "failed to create new version")
 	}
 
+	if overrideStrictPreflights {
+		if err := preflight.OverrideStrictPreflights(a.ID, newSequence, "kots install", ""); err != nil {
+			return errors.Wrap(err, "failed to override strict preflights")
+		}
+	}
+
 	kotsKinds, err := kotsutil.LoadKotsKindsFromPath(tmpRoot)
 	if err != nil {
 		return errors.Wrap(err, "failed to load kotskinds from path")
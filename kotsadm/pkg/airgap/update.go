@@ -13,12 +13,15 @@ import (
 	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/identity"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow"
 	"github.com/replicatedhq/kots/kotsadm/pkg/preflight"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
 	"github.com/replicatedhq/kots/kotsadm/pkg/version"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotsairgap "github.com/replicatedhq/kots/pkg/airgap"
 	"github.com/replicatedhq/kots/pkg/crypto"
 	"github.com/replicatedhq/kots/pkg/cursor"
+	kotsregistry "github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/kotsutil"
 	"github.com/replicatedhq/kots/pkg/pull"
 	"github.com/replicatedhq/kots/pkg/util"
@@ -96,6 +99,15 @@ func UpdateAppFromPath(a *apptypes.App, airgapRoot string, deploy bool, skipPref
 		return err
 	}
 
+	airgapMeta, err := pull.FindAirgapMetaInDir(airgapRoot)
+	if err != nil {
+		return errors.Wrap(err, "failed to find airgap meta")
+	}
+
+	if err := kotsairgap.CheckDeltaBase(airgapMeta, &beforeKotsKinds.Installation); err != nil {
+		return errors.Wrap(err, "failed to check delta bundle base")
+	}
+
 	if err := store.GetStore().SetTaskStatus("update-download", "Processing app package...", "running"); err != nil {
 		return errors.Wrap(err, "failed to set task status")
 	}
@@ -125,6 +137,18 @@ func UpdateAppFromPath(a *apptypes.App, airgapRoot string, deploy bool, skipPref
 		pipeReader.CloseWithError(scanner.Err())
 	}()
 
+	if airgapMeta != nil && airgapMeta.Spec.IsDelta {
+		registryOptions := kotsregistry.RegistryOptions{
+			Endpoint:  registrySettings.Hostname,
+			Namespace: registrySettings.Namespace,
+			Username:  registrySettings.Username,
+			Password:  registrySettings.Password,
+		}
+		if err := kotsairgap.RehydrateDeltaImages(airgapRoot, airgapMeta, registryOptions, pipeWriter); err != nil {
+			return errors.Wrap(err, "failed to restore images of delta bundle")
+		}
+	}
+
 	// Using license from db instead of upstream bundle because the one in db has not been re-marshalled
 	license, err := pull.ParseLicenseFromBytes([]byte(a.License))
 	if err != nil {
@@ -211,7 +235,31 @@ func UpdateAppFromPath(a *apptypes.App, airgapRoot string, deploy bool, skipPref
 	}
 
 	if deploy {
-		err := version.DeployVersion(a.ID, newSequence)
+		// the new version has been created, so it can still be deployed from the admin console once the window opens
+		if err := maintenancewindow.CheckOpenForApp(a.ID); err != nil {
+			return errors.Wrap(err, "failed to deploy airgap update")
+		}
+
+		downstreams, err := store.GetStore().ListDownstreamsForApp(a.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to list downstreams for app")
+		}
+		if len(downstreams) == 0 {
+			return errors.New("no downstreams for app")
+		}
+		if err := version.CheckRequiredVersions(a.ID, downstreams[0].ClusterID, newSequence); err != nil {
+			return errors.Wrap(err, "failed to check required versions")
+		}
+
+		checkStrictPreflights := preflight.WaitForStrictPreflights
+		if skipPreflights {
+			checkStrictPreflights = preflight.CheckStrictPreflights
+		}
+		if err := checkStrictPreflights(a.ID, newSequence); err != nil {
+			return errors.Wrap(err, "failed to check strict preflights")
+		}
+
+		err = version.DeployVersion(a.ID, newSequence)
 		if err != nil {
 			return errors.Wrap(err, "failed to deploy app version")
 		}
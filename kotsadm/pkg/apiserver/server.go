@@ -13,9 +13,14 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/replicatedhq/kots/kotsadm/pkg/automation"
+	"github.com/replicatedhq/kots/kotsadm/pkg/autosupportbundle"
+	"github.com/replicatedhq/kots/kotsadm/pkg/gitops"
+	"github.com/replicatedhq/kots/kotsadm/pkg/gitopssync"
 	"github.com/replicatedhq/kots/kotsadm/pkg/handlers"
+	"github.com/replicatedhq/kots/kotsadm/pkg/healthverifier"
 	"github.com/replicatedhq/kots/kotsadm/pkg/informers"
 	"github.com/replicatedhq/kots/kotsadm/pkg/policy"
+	"github.com/replicatedhq/kots/kotsadm/pkg/preflight"
 	"github.com/replicatedhq/kots/kotsadm/pkg/snapshotscheduler"
 	"github.com/replicatedhq/kots/kotsadm/pkg/socketservice"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
@@ -46,6 +51,10 @@ func Start() {
 
 	supportbundle.StartServer()
 
+	if err := supportbundle.FailInterruptedPushes(); err != nil {
+		log.Println("Failed to fail interrupted support bundle pushes", err)
+	}
+
 	if err := informers.Start(); err != nil {
 		log.Println("Failed to start informers", err)
 	}
@@ -58,6 +67,26 @@ func Start() {
 		log.Println("Failed to start snapshot scheduler", err)
 	}
 
+	if err := healthverifier.Start(); err != nil {
+		log.Println("Failed to start health verifier", err)
+	}
+
+	if err := autosupportbundle.Start(); err != nil {
+		log.Println("Failed to start automatic support bundle collection", err)
+	}
+
+	if err := gitops.StartPullRequestTracker(); err != nil {
+		log.Println("Failed to start gitops pull request tracker", err)
+	}
+
+	if err := gitopssync.Start(); err != nil {
+		log.Println("Failed to start gitops sync reconciler", err)
+	}
+
+	if err := preflight.StartChecks(); err != nil {
+		log.Println("Failed to start scheduled preflight checks", err)
+	}
+
 	waitForAirgap, err := automation.NeedToWaitForAirgapApp()
 	if err != nil {
 		log.Println("Failed to check if airgap install is in progress", err)
@@ -90,6 +119,7 @@ func Start() {
 	r.Path("/api/v1/troubleshoot/{appId}/{bundleId}").Methods("PUT").HandlerFunc(handler.UploadSupportBundle)
 	r.Path("/api/v1/troubleshoot/supportbundle/{bundleId}/redactions").Methods("PUT").HandlerFunc(handler.SetSupportBundleRedactions)
 	r.Path("/api/v1/preflight/app/{appSlug}/sequence/{sequence}").Methods("POST").HandlerFunc(handler.PostPreflightStatus)
+	r.Path("/api/v1/preflight/check/{runId}").Methods("POST").HandlerFunc(handler.PostPreflightCheckStatus)
 
 	// This the handler for license API and should be called by the application only.
 	r.Path("/license/v1/license").Methods("GET").HandlerFunc(handler.GetPlatformLicenseCompatibility)
@@ -101,6 +131,7 @@ func Start() {
 	r.Path("/api/v1/appstatus").Methods("PUT").HandlerFunc(handler.SetAppStatus)
 	r.Path("/api/v1/deploy/result").Methods("PUT").HandlerFunc(handler.UpdateDeployResult)
 	r.Path("/api/v1/undeploy/result").Methods("PUT").HandlerFunc(handler.UpdateUndeployResult)
+	r.Path("/api/v1/troubleshoot/supportbundle/{bundleId}/progress").Methods("PUT").HandlerFunc(handler.UpdateSupportBundleProgress)
 	r.Handle("/socket.io/", socketservice.Start())
 
 	/**********************************************************************
@@ -1,11 +1,14 @@
 package app
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/app/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 )
 
 // LastUpdateAtTime sets the time that the client last checked for an update to now
@@ -20,10 +23,20 @@ func LastUpdateAtTime(appID string) error {
 	return nil
 }
 
-func InitiateRestore(snapshotName string, appID string) error {
+func InitiateRestore(snapshotName string, appID string, restoreOptions *kotssnapshottypes.RestoreOptions) error {
+	var marshalledOptions sql.NullString
+	if restoreOptions.IsSelective() {
+		b, err := json.Marshal(restoreOptions)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal restore options")
+		}
+		marshalledOptions.String = string(b)
+		marshalledOptions.Valid = true
+	}
+
 	db := persistence.MustGetPGSession()
-	query := `update app set restore_in_progress_name = $1 where id = $2`
-	_, err := db.Exec(query, snapshotName, appID)
+	query := `update app set restore_in_progress_name = $1, restore_options = $2 where id = $3`
+	_, err := db.Exec(query, snapshotName, marshalledOptions, appID)
 	if err != nil {
 		return errors.Wrap(err, "failed to update restore_in_progress_name")
 	}
@@ -33,7 +46,7 @@ func InitiateRestore(snapshotName string, appID string) error {
 
 func ResetRestore(appID string) error {
 	db := persistence.MustGetPGSession()
-	query := `update app set restore_in_progress_name = NULL, restore_undeploy_status = '' where id = $1`
+	query := `update app set restore_in_progress_name = NULL, restore_undeploy_status = '', restore_options = NULL where id = $1`
 	_, err := db.Exec(query, appID)
 	if err != nil {
 		return errors.Wrap(err, "failed to exec")
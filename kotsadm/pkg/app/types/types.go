@@ -1,6 +1,12 @@
 package types
 
-import "time"
+import (
+	"time"
+
+	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
+	supportbundletypes "github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
+)
 
 type UndeployStatus string
 
@@ -27,9 +33,18 @@ type App struct {
 	IsConfigurable        bool           `json:"isConfigurable"`
 	SnapshotTTL           string         `json:"snapshotTtl"`
 	SnapshotSchedule      string         `json:"snapshotSchedule"`
+	PreUpgradeSnapshot    string         `json:"preUpgradeSnapshot"`
 	RestoreInProgressName string         `json:"restoreInProgressName"`
 	RestoreUndeployStatus UndeployStatus `json:"restoreUndeloyStatus"`
-	UpdateCheckerSpec     string         `json:"updateCheckerSpec"`
-	IsGitOps              bool           `json:"isGitOps"`
-	InstallState          string         `json:"installState"`
+	// RestoreOptions select what the restore in progress restores, everything is restored when nil
+	RestoreOptions    *kotssnapshottypes.RestoreOptions `json:"restoreOptions,omitempty"`
+	UpdateCheckerSpec string                            `json:"updateCheckerSpec"`
+	IsGitOps          bool                              `json:"isGitOps"`
+	InstallState      string                            `json:"installState"`
+	// SnapshotRetentionPolicy prunes scheduled snapshots, they are kept until they expire when nil
+	SnapshotRetentionPolicy *kotssnapshottypes.RetentionPolicy `json:"snapshotRetentionPolicy,omitempty"`
+	// SupportBundlePolicy collects support bundles when the app fails, bundles are only collected on request when nil
+	SupportBundlePolicy *supportbundletypes.AutoCollectPolicy `json:"supportBundlePolicy,omitempty"`
+	// PreflightCheckPolicy re-runs the preflight checks of the deployed version on a schedule, they only run for new versions when nil
+	PreflightCheckPolicy *preflighttypes.CheckPolicy `json:"preflightCheckPolicy,omitempty"`
 }
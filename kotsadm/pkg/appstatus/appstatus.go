@@ -51,3 +51,14 @@ func Set(appID string, resourceStates []types.ResourceState, updatedAt time.Time
 
 	return nil
 }
+
+func Delete(appID string) error {
+	db := persistence.MustGetPGSession()
+	query := `delete from app_status where app_id = $1`
+	_, err := db.Exec(query, appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec")
+	}
+
+	return nil
+}
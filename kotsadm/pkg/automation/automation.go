@@ -159,7 +159,7 @@ LICENSE_LOOP:
 				LicenseData: string(license),
 			}
 
-			err = airgap.CreateAppFromAirgap(&pendingApp, airgapFilesDir, registryHost, namespace, username, password, true, instParams.SkipPreflights)
+			err = airgap.CreateAppFromAirgap(&pendingApp, airgapFilesDir, registryHost, namespace, username, password, true, instParams.SkipPreflights, instParams.OverrideStrictPreflights)
 			if err != nil {
 				logger.Error(errors.Wrap(err, "failed to create airgap app"))
 				continue
@@ -173,7 +173,7 @@ LICENSE_LOOP:
 				LicenseData: string(license),
 			}
 
-			_, err := online.CreateAppFromOnline(&pendingApp, upstreamURI, true, instParams.SkipPreflights)
+			_, err := online.CreateAppFromOnline(&pendingApp, upstreamURI, true, instParams.SkipPreflights, instParams.OverrideStrictPreflights)
 			if err != nil {
 				logger.Error(errors.Wrap(err, "failed to create online app"))
 				continue
@@ -310,7 +310,7 @@ func AirgapInstall(appSlug string, additionalFiles map[string][]byte) error {
 		LicenseData: string(license),
 	}
 
-	err = airgap.CreateAppFromAirgap(&pendingApp, airgapFilesDir, registryHost, namespace, username, password, true, instParams.SkipPreflights)
+	err = airgap.CreateAppFromAirgap(&pendingApp, airgapFilesDir, registryHost, namespace, username, password, true, instParams.SkipPreflights, instParams.OverrideStrictPreflights)
 	if err != nil {
 		return errors.Wrap(err, "failed to create airgap app")
 	}
@@ -0,0 +1,145 @@
+package autosupportbundle
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	supportbundletypes "github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	appstatustypes "github.com/replicatedhq/kots/pkg/api/appstatus/types"
+	"github.com/segmentio/ksuid"
+)
+
+func Start() error {
+	logger.Debug("starting automatic support bundle collection")
+
+	startLoop(unavailableLoop, 30)
+
+	return nil
+}
+
+func startLoop(fn func(), intervalInSeconds time.Duration) {
+	go func() {
+		for {
+			fn()
+			time.Sleep(time.Second * intervalInSeconds)
+		}
+	}()
+}
+
+// DeployFailed queues a support bundle for a sequence that failed to deploy, if the support bundle policy of the app asks for one
+func DeployFailed(appID string, clusterID string, sequence int64) error {
+	a, err := store.GetStore().GetApp(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app")
+	}
+
+	if !a.SupportBundlePolicy.IsEnabled() || !a.SupportBundlePolicy.DeployFailed {
+		return nil
+	}
+
+	return collect(appID, clusterID, sequence, supportbundletypes.ReasonDeployFailed, time.Time{})
+}
+
+// PreflightFailed queues a support bundle in every cluster of the app for a sequence whose preflight checks failed,
+// if the support bundle policy of the app asks for one
+func PreflightFailed(appID string, sequence int64) error {
+	a, err := store.GetStore().GetApp(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app")
+	}
+
+	if !a.SupportBundlePolicy.IsEnabled() || !a.SupportBundlePolicy.PreflightFailed {
+		return nil
+	}
+
+	downstreams, err := store.GetStore().ListDownstreamsForApp(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list downstreams for app")
+	}
+
+	for _, d := range downstreams {
+		if err := collect(appID, d.ClusterID, sequence, supportbundletypes.ReasonPreflightFailed, time.Time{}); err != nil {
+			return errors.Wrapf(err, "failed to collect support bundle in cluster %s", d.ClusterID)
+		}
+	}
+
+	return nil
+}
+
+func unavailableLoop() {
+	apps, err := store.GetStore().ListInstalledApps()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to list installed apps"))
+		return
+	}
+
+	for _, a := range apps {
+		if err := checkUnavailable(a); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to check if app %s is unavailable", a.ID))
+		}
+	}
+}
+
+func checkUnavailable(a *apptypes.App) error {
+	if !a.SupportBundlePolicy.IsEnabled() || a.SupportBundlePolicy.UnavailableMinutes <= 0 {
+		return nil
+	}
+
+	appStatus, err := store.GetStore().GetAppStatus(a.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app status")
+	}
+
+	duration := time.Duration(a.SupportBundlePolicy.UnavailableMinutes) * time.Minute
+	if !isUnavailableFor(appStatus, duration, time.Now()) {
+		return nil
+	}
+
+	downstreams, err := store.GetStore().ListDownstreamsForApp(a.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list downstreams for app")
+	}
+
+	for _, d := range downstreams {
+		currentSequence, err := downstream.GetCurrentSequence(a.ID, d.ClusterID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get current sequence")
+		}
+		if currentSequence == -1 {
+			continue
+		}
+
+		// one bundle each time the app becomes unavailable
+		if err := collect(a.ID, d.ClusterID, currentSequence, supportbundletypes.ReasonAppUnavailable, appStatus.UpdatedAt); err != nil {
+			return errors.Wrapf(err, "failed to collect support bundle in cluster %s", d.ClusterID)
+		}
+	}
+
+	return nil
+}
+
+// isUnavailableFor returns true if the app has been unavailable for at least the duration.
+// The operator only reports app status when it changes, so the status was last updated when the app became unavailable.
+func isUnavailableFor(appStatus *appstatustypes.AppStatus, duration time.Duration, now time.Time) bool {
+	if appStatus == nil || appStatus.State != appstatustypes.StateUnavailable || appStatus.UpdatedAt.IsZero() {
+		return false
+	}
+	return !now.Before(appStatus.UpdatedAt.Add(duration))
+}
+
+// collect queues a support bundle linked to the sequence, unless one was already queued for the same reason since the given time
+func collect(appID string, clusterID string, sequence int64, reason string, since time.Time) error {
+	created, err := store.GetStore().CreateTriggeredPendingSupportBundle(ksuid.New().String(), appID, clusterID, sequence, reason, since)
+	if err != nil {
+		return errors.Wrap(err, "failed to create pending support bundle")
+	}
+	if created {
+		logger.Infof("collecting support bundle for app %s sequence %d: %s", appID, sequence, reason)
+	}
+
+	return nil
+}
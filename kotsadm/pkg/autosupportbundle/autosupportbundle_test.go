@@ -0,0 +1,72 @@
+package autosupportbundle
+
+import (
+	"testing"
+	"time"
+
+	appstatustypes "github.com/replicatedhq/kots/pkg/api/appstatus/types"
+)
+
+func TestIsUnavailableFor(t *testing.T) {
+	now := time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)
+	duration := 10 * time.Minute
+
+	tests := []struct {
+		name      string
+		appStatus *appstatustypes.AppStatus
+		want      bool
+	}{
+		{
+			name:      "no status",
+			appStatus: nil,
+			want:      false,
+		},
+		{
+			name: "status never reported",
+			appStatus: &appstatustypes.AppStatus{
+				State: appstatustypes.StateMissing,
+			},
+			want: false,
+		},
+		{
+			name: "unavailable for longer than the duration",
+			appStatus: &appstatustypes.AppStatus{
+				State:     appstatustypes.StateUnavailable,
+				UpdatedAt: now.Add(-15 * time.Minute),
+			},
+			want: true,
+		},
+		{
+			name: "unavailable for exactly the duration",
+			appStatus: &appstatustypes.AppStatus{
+				State:     appstatustypes.StateUnavailable,
+				UpdatedAt: now.Add(-10 * time.Minute),
+			},
+			want: true,
+		},
+		{
+			name: "recently unavailable",
+			appStatus: &appstatustypes.AppStatus{
+				State:     appstatustypes.StateUnavailable,
+				UpdatedAt: now.Add(-5 * time.Minute),
+			},
+			want: false,
+		},
+		{
+			name: "degraded for longer than the duration",
+			appStatus: &appstatustypes.AppStatus{
+				State:     appstatustypes.StateDegraded,
+				UpdatedAt: now.Add(-time.Hour),
+			},
+			want: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isUnavailableFor(test.appStatus, duration, now); got != test.want {
+				t.Errorf("isUnavailableFor() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -3,12 +3,16 @@ package downstream
 import (
 	"database/sql"
 	"encoding/base64"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
 	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/pkg/api/downstream/types"
+	"github.com/replicatedhq/kots/pkg/kotsutil"
+	"github.com/replicatedhq/kots/pkg/upstream"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
@@ -192,9 +196,14 @@ func GetCurrentVersion(appID string, clusterID string) (*types.DownstreamVersion
 	adv.preflight_result_created_at,
 	adv.git_commit_url,
 	adv.git_deployable,
+	adv.pre_upgrade_snapshot_name,
+	adv.deploy_held_reason,
 	ado.is_error,
 	av.upstream_released_at,
-	av.kots_installation_spec
+	av.kots_installation_spec,
+	av.kots_app_spec,
+	gpr.url,
+	gpr.state
  FROM
 	 app_downstream_version AS adv
  LEFT JOIN
@@ -205,6 +214,10 @@ func GetCurrentVersion(appID string, clusterID string) (*types.DownstreamVersion
 	 app_downstream_output AS ado
  ON
 	 adv.app_id = ado.app_id AND adv.cluster_id = ado.cluster_id AND adv.sequence = ado.downstream_sequence
+ LEFT JOIN
+	 gitops_pull_request AS gpr
+ ON
+	 adv.app_id = gpr.app_id AND adv.cluster_id = gpr.cluster_id AND adv.parent_sequence = gpr.sequence
  WHERE
 	 adv.app_id = $1 AND
 	 adv.cluster_id = $3 AND
@@ -242,9 +255,14 @@ func GetPendingVersions(appID string, clusterID string) ([]types.DownstreamVersi
 	adv.preflight_result_created_at,
 	adv.git_commit_url,
 	adv.git_deployable,
+	adv.pre_upgrade_snapshot_name,
+	adv.deploy_held_reason,
 	ado.is_error,
 	av.upstream_released_at,
-	av.kots_installation_spec
+	av.kots_installation_spec,
+	av.kots_app_spec,
+	gpr.url,
+	gpr.state
  FROM
 	 app_downstream_version AS adv
  LEFT JOIN
@@ -255,6 +273,10 @@ func GetPendingVersions(appID string, clusterID string) ([]types.DownstreamVersi
 	 app_downstream_output AS ado
  ON
 	 adv.app_id = ado.app_id AND adv.cluster_id = ado.cluster_id AND adv.sequence = ado.downstream_sequence
+ LEFT JOIN
+	 gitops_pull_request AS gpr
+ ON
+	 adv.app_id = gpr.app_id AND adv.cluster_id = gpr.cluster_id AND adv.parent_sequence = gpr.sequence
  WHERE
 	 adv.app_id = $1 AND
 	 adv.cluster_id = $3 AND
@@ -306,9 +328,14 @@ func GetPastVersions(appID string, clusterID string) ([]types.DownstreamVersion,
 	adv.preflight_result_created_at,
 	adv.git_commit_url,
 	adv.git_deployable,
+	adv.pre_upgrade_snapshot_name,
+	adv.deploy_held_reason,
 	ado.is_error,
 	av.upstream_released_at,
-	av.kots_installation_spec
+	av.kots_installation_spec,
+	av.kots_app_spec,
+	gpr.url,
+	gpr.state
  FROM
 	 app_downstream_version AS adv
  LEFT JOIN
@@ -319,6 +346,10 @@ func GetPastVersions(appID string, clusterID string) ([]types.DownstreamVersion,
 	 app_downstream_output AS ado
  ON
 	 adv.app_id = ado.app_id AND adv.cluster_id = ado.cluster_id AND adv.sequence = ado.downstream_sequence
+ LEFT JOIN
+	 gitops_pull_request AS gpr
+ ON
+	 adv.app_id = gpr.app_id AND adv.cluster_id = gpr.cluster_id AND adv.parent_sequence = gpr.sequence
  WHERE
 	 adv.app_id = $1 AND
 	 adv.cluster_id = $3 AND
@@ -361,9 +392,14 @@ func versionFromRow(appID string, row scannable) (*types.DownstreamVersion, erro
 	var preflightResultCreatedAt sql.NullTime
 	var commitURL sql.NullString
 	var gitDeployable sql.NullBool
+	var preUpgradeSnapshotName sql.NullString
+	var deployHeldReason sql.NullString
 	var hasError sql.NullBool
 	var upstreamReleasedAt sql.NullTime
 	var kotsInstallationSpecStr sql.NullString
+	var kotsAppSpecStr sql.NullString
+	var pullRequestURL sql.NullString
+	var pullRequestState sql.NullString
 
 	if err := row.Scan(
 		&createdOn,
@@ -379,9 +415,14 @@ func versionFromRow(appID string, row scannable) (*types.DownstreamVersion, erro
 		&preflightResultCreatedAt,
 		&commitURL,
 		&gitDeployable,
+		&preUpgradeSnapshotName,
+		&deployHeldReason,
 		&hasError,
 		&upstreamReleasedAt,
 		&kotsInstallationSpecStr,
+		&kotsAppSpecStr,
+		&pullRequestURL,
+		&pullRequestState,
 	); err != nil {
 		return nil, errors.Wrap(err, "failed to scan")
 	}
@@ -406,6 +447,14 @@ func versionFromRow(appID string, row scannable) (*types.DownstreamVersion, erro
 	}
 	v.CommitURL = commitURL.String
 	v.GitDeployable = gitDeployable.Bool
+	v.PreUpgradeSnapshot = preUpgradeSnapshotName.String
+	v.DeployHeldReason = deployHeldReason.String
+	if v.DeployHeldReason != "" && v.Status == "deploying" {
+		// the deploy loop has not sent the version to the operator yet
+		v.Status = "pending_maintenance_window"
+	}
+	v.PullRequestURL = pullRequestURL.String
+	v.PullRequestState = pullRequestState.String
 
 	releaseNotes, err := getReleaseNotes(appID, v.ParentSequence)
 	if err != nil {
@@ -428,6 +477,21 @@ func versionFromRow(appID string, row scannable) (*types.DownstreamVersion, erro
 		v.YamlErrors = installationSpec.Spec.YAMLErrors
 	}
 
+	if kotsAppSpecStr.Valid && kotsAppSpecStr.String != "" {
+		// an invalid application spec should not prevent listing versions
+		kotsAppSpec, err := kotsutil.LoadKotsAppFromContents([]byte(kotsAppSpecStr.String))
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to load kots app spec for sequence %d", v.Sequence))
+		} else {
+			needsKotsUpgrade, err := upstream.NeedsKotsUpgrade(kotsAppSpec.Spec.TargetKotsVersion)
+			if err != nil {
+				logger.Error(errors.Wrap(err, "failed to check target kots version"))
+			}
+			v.NeedsKotsUpgrade = needsKotsUpgrade
+			v.TargetKotsVersion = kotsAppSpec.Spec.TargetKotsVersion
+		}
+	}
+
 	return v, nil
 }
 
@@ -576,6 +640,34 @@ func UpdateDownstreamDeployStatus(appID string, clusterID string, sequence int64
 	return nil
 }
 
+// AppendDownstreamDeployError marks the deploy of the downstream version as failed, and adds the message to the
+// end of its apply output so that it is shown in the deploy log
+func AppendDownstreamDeployError(appID string, clusterID string, sequence int64, message string) error {
+	output, err := GetDownstreamOutput(appID, clusterID, sequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to get downstream output")
+	}
+
+	applyStderr := output.ApplyStderr
+	if applyStderr != "" && !strings.HasSuffix(applyStderr, "\n") {
+		applyStderr += "\n"
+	}
+	applyStderr += message + "\n"
+
+	// deploy output is stored base64 encoded, the way the operator reports it
+	encodedOutput := types.DownstreamOutput{
+		DryrunStdout: base64.StdEncoding.EncodeToString([]byte(output.DryrunStdout)),
+		DryrunStderr: base64.StdEncoding.EncodeToString([]byte(output.DryrunStderr)),
+		ApplyStdout:  base64.StdEncoding.EncodeToString([]byte(output.ApplyStdout)),
+		ApplyStderr:  base64.StdEncoding.EncodeToString([]byte(applyStderr)),
+	}
+	if err := UpdateDownstreamDeployStatus(appID, clusterID, sequence, true, encodedOutput); err != nil {
+		return errors.Wrap(err, "failed to update downstream deploy status")
+	}
+
+	return nil
+}
+
 func DeleteDownstreamDeployStatus(appID string, clusterID string, sequence int64) error {
 	db := persistence.MustGetPGSession()
 
@@ -588,3 +680,165 @@ func DeleteDownstreamDeployStatus(appID string, clusterID string, sequence int64
 
 	return nil
 }
+
+// SetDownstreamDeployHeld records why the deploy loop is holding the downstream version, an empty reason clears it
+func SetDownstreamDeployHeld(appID string, clusterID string, sequence int64, reason string) error {
+	db := persistence.MustGetPGSession()
+
+	query := `update app_downstream_version set deploy_held_reason = $4 where app_id = $1 and cluster_id = $2 and sequence = $3`
+
+	_, err := db.Exec(query, appID, clusterID, sequence, reason)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec")
+	}
+
+	return nil
+}
+
+// SetPreUpgradeSnapshotName records the snapshot that was taken of the downstream version before it was upgraded
+func SetPreUpgradeSnapshotName(appID string, clusterID string, sequence int64, snapshotName string) error {
+	db := persistence.MustGetPGSession()
+
+	query := `update app_downstream_version set pre_upgrade_snapshot_name = $4 where app_id = $1 and cluster_id = $2 and sequence = $3`
+
+	_, err := db.Exec(query, appID, clusterID, sequence, snapshotName)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec")
+	}
+
+	return nil
+}
+
+// GetPreUpgradeSnapshotName returns the snapshot that was taken of the downstream version before it was upgraded, if any
+func GetPreUpgradeSnapshotName(appID string, clusterID string, sequence int64) (string, error) {
+	db := persistence.MustGetPGSession()
+
+	query := `select pre_upgrade_snapshot_name from app_downstream_version where app_id = $1 and cluster_id = $2 and sequence = $3`
+	row := db.QueryRow(query, appID, clusterID, sequence)
+
+	var snapshotName sql.NullString
+	if err := row.Scan(&snapshotName); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to scan")
+	}
+
+	return snapshotName.String, nil
+}
+
+// GetPreUpgradeBackup returns the snapshot that has to complete before the downstream version is deployed, if one was started
+func GetPreUpgradeBackup(appID string, clusterID string, sequence int64) (*types.PreUpgradeBackup, error) {
+	db := persistence.MustGetPGSession()
+
+	query := `select pre_upgrade_backup_name, pre_upgrade_backup_status, pre_upgrade_backup_deployed_at from app_downstream_version where app_id = $1 and cluster_id = $2 and sequence = $3`
+	row := db.QueryRow(query, appID, clusterID, sequence)
+
+	var backupName sql.NullString
+	var status sql.NullString
+	var deployedAt sql.NullTime
+	if err := row.Scan(&backupName, &status, &deployedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+
+	if !status.Valid {
+		return nil, nil
+	}
+
+	backup := &types.PreUpgradeBackup{
+		BackupName: backupName.String,
+		Status:     status.String,
+	}
+	if deployedAt.Valid {
+		backup.DeployedAt = &deployedAt.Time
+	}
+
+	return backup, nil
+}
+
+// SetPreUpgradeBackup records the snapshot that has to complete before the downstream version is deployed
+func SetPreUpgradeBackup(appID string, clusterID string, sequence int64, backup types.PreUpgradeBackup) error {
+	db := persistence.MustGetPGSession()
+
+	query := `update app_downstream_version set pre_upgrade_backup_name = $4, pre_upgrade_backup_status = $5, pre_upgrade_backup_deployed_at = $6 where app_id = $1 and cluster_id = $2 and sequence = $3`
+
+	_, err := db.Exec(query, appID, clusterID, sequence, backup.BackupName, backup.Status, backup.DeployedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec")
+	}
+
+	return nil
+}
+
+// HasDownstreamDeployOutput returns true if the operator has reported the result of deploying the downstream version
+func HasDownstreamDeployOutput(appID string, clusterID string, sequence int64) (bool, error) {
+	db := persistence.MustGetPGSession()
+
+	query := `select count(1) from app_downstream_output where app_id = $1 and cluster_id = $2 and downstream_sequence = $3`
+	row := db.QueryRow(query, appID, clusterID, sequence)
+
+	var count int64
+	if err := row.Scan(&count); err != nil {
+		return false, errors.Wrap(err, "failed to scan")
+	}
+
+	return count > 0, nil
+}
+
+// StartHealthVerification marks the downstream version as waiting for the app to become ready after deploying
+func StartHealthVerification(appID string, clusterID string, sequence int64) error {
+	db := persistence.MustGetPGSession()
+
+	query := `update app_downstream_version set health_verification_started_at = $4 where app_id = $1 and cluster_id = $2 and sequence = $3`
+
+	_, err := db.Exec(query, appID, clusterID, sequence, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to exec")
+	}
+
+	return nil
+}
+
+// EndHealthVerification clears the health verification for the downstream version, regardless of its outcome
+func EndHealthVerification(appID string, clusterID string, sequence int64) error {
+	db := persistence.MustGetPGSession()
+
+	query := `update app_downstream_version set health_verification_started_at = null where app_id = $1 and cluster_id = $2 and sequence = $3`
+
+	_, err := db.Exec(query, appID, clusterID, sequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec")
+	}
+
+	return nil
+}
+
+// ListHealthVerifications returns all downstream versions with a health verification in progress
+func ListHealthVerifications() ([]types.HealthVerification, error) {
+	db := persistence.MustGetPGSession()
+
+	query := `select app_id, cluster_id, sequence, parent_sequence, health_verification_started_at
+	from app_downstream_version where health_verification_started_at is not null`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query")
+	}
+	defer rows.Close()
+
+	verifications := []types.HealthVerification{}
+	for rows.Next() {
+		var parentSequence sql.NullInt64
+		v := types.HealthVerification{}
+		if err := rows.Scan(&v.AppID, &v.ClusterID, &v.Sequence, &parentSequence, &v.StartedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		v.ParentSequence = parentSequence.Int64
+		verifications = append(verifications, v)
+	}
+
+	return verifications, nil
+}
@@ -0,0 +1,192 @@
+package gitops
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	go_git_http "github.com/go-git/go-git/v5/plumbing/transport/http"
+	go_git_ssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	AuthMethodSSHKey     = "ssh-key"
+	AuthMethodHTTPSToken = "https-token"
+	AuthMethodHTTPSBasic = "https-basic"
+)
+
+// GitOpsAuth holds the credentials used to clone and push to the gitops repo.
+// The private key for the ssh-key method is generated and is not part of this.
+type GitOpsAuth struct {
+	Method   string
+	Username string
+	Password string // the token for the https-token method
+}
+
+var installProxyTransportOnce sync.Once
+
+// installProxyTransport makes go-git use an http client that honors the HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY settings of the kotsadm deployment. go-git picks its transport per protocol, so this only
+// changes the client of go-git, which kotsadm only uses for gitops. Other http clients are not affected.
+func installProxyTransport() {
+	installProxyTransportOnce.Do(func() {
+		client.InstallProtocol("https", go_git_http.NewClient(gitOpsHTTPClient()))
+		client.InstallProtocol("http", go_git_http.NewClient(gitOpsHTTPClient()))
+	})
+}
+
+// gitOpsHTTPClient returns a client with the dial, tls and idle timeouts of the default transport
+func gitOpsHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
+func IsValidAuthMethod(method string) bool {
+	switch method {
+	case "", AuthMethodSSHKey, AuthMethodHTTPSToken, AuthMethodHTTPSBasic:
+		return true
+	}
+	return false
+}
+
+func (g *GitOpsConfig) authMethod() string {
+	if g.AuthMethod == "" {
+		return AuthMethodSSHKey
+	}
+	return g.AuthMethod
+}
+
+func (g *GitOpsConfig) isHTTPS() bool {
+	method := g.authMethod()
+	return method == AuthMethodHTTPSToken || method == AuthMethodHTTPSBasic
+}
+
+// httpsCloneURL returns the https url to clone the repo from, based on the repo uri
+func (g *GitOpsConfig) httpsCloneURL() string {
+	u, err := url.Parse(g.RepoURI)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+	owner, repo, err := ownerAndRepo(g.RepoURI)
+	if err != nil {
+		return ""
+	}
+	u.Path = "/" + owner + "/" + repo + ".git"
+	return u.String()
+}
+
+// validateAuth checks that the credentials required by the auth method are present
+func validateAuth(gitOpsConfig *GitOpsConfig) error {
+	switch gitOpsConfig.authMethod() {
+	case AuthMethodSSHKey:
+		if gitOpsConfig.PrivateKey == "" {
+			return errors.New("no deploy key has been generated for this repo")
+		}
+	case AuthMethodHTTPSToken:
+		if gitOpsConfig.Password == "" {
+			return errors.New("a token is required for https-token auth")
+		}
+	case AuthMethodHTTPSBasic:
+		if gitOpsConfig.Username == "" || gitOpsConfig.Password == "" {
+			return errors.New("a username and password are required for https-basic auth")
+		}
+	default:
+		return errors.Errorf("unsupported auth method %q", gitOpsConfig.AuthMethod)
+	}
+
+	if gitOpsConfig.isHTTPS() && gitOpsConfig.httpsCloneURL() == "" {
+		return errors.Errorf("failed to build an https clone url from %q", gitOpsConfig.RepoURI)
+	}
+
+	return nil
+}
+
+func getAuth(gitOpsConfig *GitOpsConfig) (transport.AuthMethod, error) {
+	if err := validateAuth(gitOpsConfig); err != nil {
+		return nil, err
+	}
+
+	switch gitOpsConfig.authMethod() {
+	case AuthMethodHTTPSToken:
+		installProxyTransport()
+		return &go_git_http.BasicAuth{
+			Username: tokenUsername(gitOpsConfig),
+			Password: gitOpsConfig.Password,
+		}, nil
+
+	case AuthMethodHTTPSBasic:
+		installProxyTransport()
+		return &go_git_http.BasicAuth{
+			Username: gitOpsConfig.Username,
+			Password: gitOpsConfig.Password,
+		}, nil
+	}
+
+	var auth transport.AuthMethod
+	signer, err := ssh.ParsePrivateKey([]byte(gitOpsConfig.PrivateKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse deploy key")
+	}
+	auth = &go_git_ssh.PublicKeys{User: "git", Signer: signer}
+	auth.(*go_git_ssh.PublicKeys).HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	return auth, nil
+}
+
+// tokenUsername returns the username to send with a token. Most providers ignore it,
+// but some require a specific value.
+func tokenUsername(gitOpsConfig *GitOpsConfig) string {
+	if gitOpsConfig.Username != "" {
+		return gitOpsConfig.Username
+	}
+
+	switch gitOpsConfig.Provider {
+	case "gitlab", "gitlab_enterprise":
+		return "oauth2"
+	case "bitbucket":
+		return "x-token-auth"
+	default:
+		return "kots"
+	}
+}
+
+func encryptSecretValue(value string) ([]byte, error) {
+	cipher, err := crypto.AESCipherFromString(os.Getenv("API_ENCRYPTION_KEY"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create aes cipher")
+	}
+	encrypted := cipher.Encrypt([]byte(value))
+	return []byte(base64.StdEncoding.EncodeToString(encrypted)), nil
+}
+
+func decryptSecretValue(value []byte) (string, error) {
+	if len(value) == 0 {
+		return "", nil
+	}
+
+	cipher, err := crypto.AESCipherFromString(os.Getenv("API_ENCRYPTION_KEY"))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create aes cipher")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(value))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decode")
+	}
+	decrypted, err := cipher.Decrypt(decoded)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decrypt")
+	}
+	return string(decrypted), nil
+}
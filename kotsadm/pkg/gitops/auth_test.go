@@ -0,0 +1,126 @@
+package gitops
+
+import (
+	"net/http"
+	"testing"
+
+	go_git_http "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_httpsCloneURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		repoURI  string
+		expected string
+	}{
+		{
+			name:     "github",
+			repoURI:  "https://github.com/org/repo",
+			expected: "https://github.com/org/repo.git",
+		},
+		{
+			name:     "trailing slash and .git",
+			repoURI:  "https://git.example.com/org/repo.git/",
+			expected: "https://git.example.com/org/repo.git",
+		},
+		{
+			name:     "custom port",
+			repoURI:  "http://gitea.internal:3000/org/repo",
+			expected: "http://gitea.internal:3000/org/repo.git",
+		},
+		{
+			name:     "missing repo",
+			repoURI:  "https://github.com/org",
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := &GitOpsConfig{RepoURI: test.repoURI, AuthMethod: AuthMethodHTTPSToken}
+			assert.Equal(t, test.expected, g.httpsCloneURL())
+		})
+	}
+}
+
+func Test_validateAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  GitOpsConfig
+		wantErr bool
+	}{
+		{
+			name:    "ssh key without private key",
+			config:  GitOpsConfig{RepoURI: "https://github.com/org/repo"},
+			wantErr: true,
+		},
+		{
+			name:   "https token",
+			config: GitOpsConfig{RepoURI: "https://github.com/org/repo", AuthMethod: AuthMethodHTTPSToken, Password: "token"},
+		},
+		{
+			name:    "https token without token",
+			config:  GitOpsConfig{RepoURI: "https://github.com/org/repo", AuthMethod: AuthMethodHTTPSToken},
+			wantErr: true,
+		},
+		{
+			name:    "https basic without username",
+			config:  GitOpsConfig{RepoURI: "https://github.com/org/repo", AuthMethod: AuthMethodHTTPSBasic, Password: "password"},
+			wantErr: true,
+		},
+		{
+			name:    "https basic with invalid repo uri",
+			config:  GitOpsConfig{RepoURI: "github.com/org/repo", AuthMethod: AuthMethodHTTPSBasic, Username: "user", Password: "password"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown method",
+			config:  GitOpsConfig{RepoURI: "https://github.com/org/repo", AuthMethod: "oauth"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateAuth(&test.config)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_getAuthHTTPSToken(t *testing.T) {
+	req := require.New(t)
+
+	auth, err := getAuth(&GitOpsConfig{
+		Provider:   "gitlab",
+		RepoURI:    "https://gitlab.com/org/repo",
+		AuthMethod: AuthMethodHTTPSToken,
+		Password:   "glpat-123",
+	})
+	req.NoError(err)
+
+	basicAuth, ok := auth.(*go_git_http.BasicAuth)
+	req.True(ok)
+	req.Equal("oauth2", basicAuth.Username)
+	req.Equal("glpat-123", basicAuth.Password)
+}
+
+func Test_gitOpsHTTPClient(t *testing.T) {
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+
+	httpClient := gitOpsHTTPClient()
+	transport, ok := httpClient.Transport.(*http.Transport)
+	require.True(t, ok)
+
+	assert.False(t, transport == defaultTransport, "the default transport must not be shared")
+	assert.Equal(t, defaultTransport.TLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	assert.Equal(t, defaultTransport.IdleConnTimeout, transport.IdleConnTimeout)
+	assert.NotNil(t, transport.DialContext)
+	assert.NotNil(t, transport.Proxy)
+}
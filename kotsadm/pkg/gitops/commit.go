@@ -0,0 +1,307 @@
+package gitops
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	SigningMethodGPG = "gpg"
+	SigningMethodSSH = "ssh"
+
+	DefaultCommitAuthorName      = "KOTS Admin Console"
+	DefaultCommitAuthorEmail     = "help@replicated.com"
+	DefaultCommitMessageTemplate = "Updating {{ .AppName }} to version {{ .Sequence }}"
+)
+
+// CommitSettings holds the per-repo settings for the commits made by kots.
+// The signing key and its passphrase are stored encrypted in the gitops secret.
+type CommitSettings struct {
+	AuthorName           string
+	AuthorEmail          string
+	MessageTemplate      string
+	SigningMethod        string
+	SigningKey           string // an empty key keeps the current one
+	SigningKeyPassphrase string
+}
+
+// CommitMessageData is available to the commit message template
+type CommitMessageData struct {
+	AppName      string
+	AppSlug      string
+	Sequence     int
+	VersionLabel string
+	ReleaseNotes string
+	ConfigDiff   string
+	DiffSummary  string
+}
+
+func IsValidSigningMethod(method string) bool {
+	switch method {
+	case "", SigningMethodGPG, SigningMethodSSH:
+		return true
+	}
+	return false
+}
+
+// ValidateCommitMessageTemplate checks that the template parses and only uses the available fields
+func ValidateCommitMessageTemplate(messageTemplate string) error {
+	if messageTemplate == "" {
+		return nil
+	}
+
+	_, err := renderCommitMessage(messageTemplate, CommitMessageData{})
+	return err
+}
+
+func renderCommitMessage(messageTemplate string, data CommitMessageData) (string, error) {
+	if messageTemplate == "" {
+		messageTemplate = DefaultCommitMessageTemplate
+	}
+
+	tmpl, err := template.New("commit-message").Option("missingkey=error").Parse(messageTemplate)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse commit message template")
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", errors.Wrap(err, "failed to execute commit message template")
+	}
+
+	message := strings.TrimSpace(b.String())
+	if message == "" {
+		return "", errors.New("commit message template rendered an empty message")
+	}
+
+	return message, nil
+}
+
+func (g *GitOpsConfig) commitAuthor() *object.Signature {
+	author := &object.Signature{
+		Name:  g.CommitAuthorName,
+		Email: g.CommitAuthorEmail,
+		When:  time.Now(),
+	}
+	if author.Name == "" {
+		author.Name = DefaultCommitAuthorName
+	}
+	if author.Email == "" {
+		author.Email = DefaultCommitAuthorEmail
+	}
+	return author
+}
+
+// configDiff lists the config items that were added, changed or removed between two versions.
+// Values are not included since they can be sensitive.
+func configDiff(previous *kotsv1beta1.ConfigValues, current *kotsv1beta1.ConfigValues) string {
+	previousValues := map[string]kotsv1beta1.ConfigValue{}
+	if previous != nil {
+		previousValues = previous.Spec.Values
+	}
+	currentValues := map[string]kotsv1beta1.ConfigValue{}
+	if current != nil {
+		currentValues = current.Spec.Values
+	}
+
+	lines := []string{}
+	for name, value := range currentValues {
+		previousValue, ok := previousValues[name]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("added %s", name))
+		} else if !reflect.DeepEqual(previousValue, value) {
+			lines = append(lines, fmt.Sprintf("changed %s", name))
+		}
+	}
+	for name := range previousValues {
+		if _, ok := currentValues[name]; !ok {
+			lines = append(lines, fmt.Sprintf("removed %s", name))
+		}
+	}
+
+	// sort by item name
+	sort.Slice(lines, func(i, j int) bool {
+		return strings.SplitN(lines[i], " ", 2)[1] < strings.SplitN(lines[j], " ", 2)[1]
+	})
+
+	return strings.Join(lines, "\n")
+}
+
+// commit commits the staged changes with the configured author and signing key
+func commit(repo *git.Repository, workTree *git.Worktree, gitOpsConfig *GitOpsConfig, message string) (plumbing.Hash, error) {
+	commitOptions := &git.CommitOptions{
+		All:    true, // stages files removed by writeGitOpsFiles
+		Author: gitOpsConfig.commitAuthor(),
+	}
+
+	if gitOpsConfig.SigningMethod == SigningMethodGPG {
+		signKey, err := parseGPGSigningKey(gitOpsConfig.SigningKey, gitOpsConfig.SigningKeyPassphrase)
+		if err != nil {
+			return plumbing.ZeroHash, errors.Wrap(err, "failed to parse gpg signing key")
+		}
+		commitOptions.SignKey = signKey
+	}
+
+	hash, err := workTree.Commit(message, commitOptions)
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to commit")
+	}
+
+	if gitOpsConfig.SigningMethod == SigningMethodSSH {
+		hash, err = signCommitWithSSH(repo, hash, gitOpsConfig.SigningKey, gitOpsConfig.SigningKeyPassphrase)
+		if err != nil {
+			return plumbing.ZeroHash, errors.Wrap(err, "failed to sign commit")
+		}
+	}
+
+	return hash, nil
+}
+
+func parseGPGSigningKey(armoredKey string, passphrase string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read armored key")
+	}
+	if len(entities) == 0 || entities[0].PrivateKey == nil {
+		return nil, errors.New("no private key found")
+	}
+
+	entity := entities[0]
+	if entity.PrivateKey.Encrypted {
+		if err := entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt private key")
+		}
+	}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			if err := subkey.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return nil, errors.Wrap(err, "failed to decrypt private subkey")
+			}
+		}
+	}
+
+	return entity, nil
+}
+
+// signCommitWithSSH replaces the commit at HEAD with a copy signed with the ssh key.
+// go-git only signs with gpg keys, git verifies ssh signatures stored in the same header.
+func signCommitWithSSH(repo *git.Repository, hash plumbing.Hash, privateKey string, passphrase string) (plumbing.Hash, error) {
+	c, err := repo.CommitObject(hash)
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to get commit")
+	}
+
+	unsigned := &plumbing.MemoryObject{}
+	if err := c.EncodeWithoutSignature(unsigned); err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to encode commit")
+	}
+	reader, err := unsigned.Reader()
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to read commit")
+	}
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to read commit")
+	}
+
+	signature, err := sshSign(content, privateKey, passphrase)
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to create ssh signature")
+	}
+	c.PGPSignature = signature
+
+	signed := repo.Storer.NewEncodedObject()
+	if err := c.Encode(signed); err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to encode signed commit")
+	}
+	signedHash, err := repo.Storer.SetEncodedObject(signed)
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to store signed commit")
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to get head")
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), signedHash)); err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "failed to update head")
+	}
+
+	return signedHash, nil
+}
+
+// sshSign creates an armored signature in the format of "ssh-keygen -Y sign -n git"
+func sshSign(message []byte, privateKey string, passphrase string) (string, error) {
+	var signer ssh.Signer
+	var err error
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(privateKey))
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse ssh signing key")
+	}
+
+	const namespace = "git"
+	const hashAlgorithm = "sha512"
+	messageHash := sha512.Sum512(message)
+
+	signedData := struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{namespace, "", hashAlgorithm, messageHash[:]}
+	toSign := append([]byte("SSHSIG"), ssh.Marshal(signedData)...)
+
+	var sig *ssh.Signature
+	if algorithmSigner, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// ssh-rsa signatures use sha1, which git refuses
+		sig, err = algorithmSigner.SignWithAlgorithm(rand.Reader, toSign, ssh.SigAlgoRSASHA2512)
+	} else {
+		sig, err = signer.Sign(rand.Reader, toSign)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign")
+	}
+
+	blob := struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, signer.PublicKey().Marshal(), namespace, "", hashAlgorithm, ssh.Marshal(sig)}
+	encoded := base64.StdEncoding.EncodeToString(append([]byte("SSHSIG"), ssh.Marshal(blob)...))
+
+	var armored strings.Builder
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n")
+	armored.WriteString("-----END SSH SIGNATURE-----")
+
+	return armored.String(), nil
+}
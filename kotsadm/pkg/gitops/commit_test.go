@@ -0,0 +1,172 @@
+package gitops
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func Test_renderCommitMessage(t *testing.T) {
+	data := CommitMessageData{
+		AppName:      "My App",
+		AppSlug:      "my-app",
+		Sequence:     3,
+		VersionLabel: "1.2.0",
+		ReleaseNotes: "fixes",
+		ConfigDiff:   "changed hostname",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "default",
+			expected: "Updating My App to version 3",
+		},
+		{
+			name:     "conventional commit",
+			template: "chore({{ .AppSlug }}): update to {{ .VersionLabel }}\n\n{{ .ReleaseNotes }}\n\nConfig changes:\n{{ .ConfigDiff }}\n",
+			expected: "chore(my-app): update to 1.2.0\n\nfixes\n\nConfig changes:\nchanged hostname",
+		},
+		{
+			name:     "unknown field",
+			template: "{{ .Version }}",
+			wantErr:  true,
+		},
+		{
+			name:     "empty message",
+			template: "{{ .DiffSummary }}",
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, err := renderCommitMessage(test.template, data)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, message)
+		})
+	}
+}
+
+func Test_configDiff(t *testing.T) {
+	previous := &kotsv1beta1.ConfigValues{
+		Spec: kotsv1beta1.ConfigValuesSpec{
+			Values: map[string]kotsv1beta1.ConfigValue{
+				"hostname": {Value: "a.example.com"},
+				"password": {ValuePlaintext: "secret"},
+				"replicas": {Value: "1"},
+			},
+		},
+	}
+	current := &kotsv1beta1.ConfigValues{
+		Spec: kotsv1beta1.ConfigValuesSpec{
+			Values: map[string]kotsv1beta1.ConfigValue{
+				"hostname": {Value: "b.example.com"},
+				"password": {ValuePlaintext: "secret"},
+				"tls":      {Value: "1"},
+			},
+		},
+	}
+
+	assert.Equal(t, "changed hostname\nremoved replicas\nadded tls", configDiff(previous, current))
+	assert.Equal(t, "", configDiff(nil, nil))
+}
+
+func Test_commitSignedWithSSH(t *testing.T) {
+	req := require.New(t)
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	req.NoError(err)
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	req.NoError(err)
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyBytes})
+
+	repo, workTree := initTestRepo(t)
+
+	hash, err := commit(repo, workTree, &GitOpsConfig{
+		CommitAuthorName:  "Release Bot",
+		CommitAuthorEmail: "bot@example.com",
+		SigningMethod:     SigningMethodSSH,
+		SigningKey:        string(privateKeyPEM),
+	}, "chore: update")
+	req.NoError(err)
+
+	head, err := repo.Head()
+	req.NoError(err)
+	assert.Equal(t, hash, head.Hash())
+
+	c, err := repo.CommitObject(hash)
+	req.NoError(err)
+	assert.Equal(t, "Release Bot", c.Author.Name)
+	assert.Equal(t, "bot@example.com", c.Author.Email)
+	assert.Equal(t, "chore: update", c.Message)
+	assert.True(t, strings.HasPrefix(c.PGPSignature, "-----BEGIN SSH SIGNATURE-----\n"))
+	assert.True(t, strings.HasSuffix(c.PGPSignature, "-----END SSH SIGNATURE-----\n"))
+}
+
+func Test_commitSignedWithGPG(t *testing.T) {
+	req := require.New(t)
+
+	entity, err := openpgp.NewEntity("Release Bot", "", "bot@example.com", nil)
+	req.NoError(err)
+
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PrivateKeyType, nil)
+	req.NoError(err)
+	req.NoError(entity.SerializePrivate(w, nil))
+	req.NoError(w.Close())
+
+	repo, workTree := initTestRepo(t)
+
+	hash, err := commit(repo, workTree, &GitOpsConfig{
+		SigningMethod: SigningMethodGPG,
+		SigningKey:    armored.String(),
+	}, "chore: update")
+	req.NoError(err)
+
+	c, err := repo.CommitObject(hash)
+	req.NoError(err)
+	assert.Equal(t, DefaultCommitAuthorName, c.Author.Name)
+
+	_, err = c.Verify(armored.String())
+	assert.NoError(t, err)
+}
+
+func initTestRepo(t *testing.T) (*git.Repository, *git.Worktree) {
+	dir, err := ioutil.TempDir("", "kotsadm")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	workTree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "my-app.yaml"), []byte("kind: ConfigMap\n"), 0644))
+	_, err = workTree.Add("my-app.yaml")
+	require.NoError(t, err)
+
+	return repo, workTree
+}
@@ -0,0 +1,416 @@
+package gitops
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/secrets"
+	"golang.org/x/crypto/openpgp"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	SecretEncryptionSOPS          = "sops"
+	SecretEncryptionSealedSecrets = "sealedSecrets"
+)
+
+// sops and sealed secrets produce a new ciphertext every time a secret is encrypted.
+// The encrypted secret is annotated with a keyed hash of its plaintext so that an unchanged
+// secret keeps the ciphertext that is already in the repo and is not committed again.
+const secretChecksumAnnotation = "kots.io/secret-checksum"
+
+// SecretEncryption configures how Secrets are encrypted before they are committed to the repo
+type SecretEncryption struct {
+	Method            string   `json:"method"`
+	AgeRecipients     []string `json:"ageRecipients,omitempty"`
+	PGPPublicKeys     []string `json:"pgpPublicKeys,omitempty"`
+	SealedSecretsCert string   `json:"sealedSecretsCert,omitempty"`
+	// Required refuses to commit when a Secret would be committed in plaintext
+	Required bool `json:"required"`
+}
+
+func (e SecretEncryption) Validate() error {
+	switch e.Method {
+	case "":
+		if e.Required {
+			return errors.New("an encryption method is required when encryption is required")
+		}
+	case SecretEncryptionSOPS:
+		if len(e.AgeRecipients) == 0 && len(e.PGPPublicKeys) == 0 {
+			return errors.New("at least one age recipient or pgp public key is required for sops")
+		}
+		if _, err := pgpFingerprints(e.PGPPublicKeys); err != nil {
+			return errors.Wrap(err, "invalid pgp public key")
+		}
+	case SecretEncryptionSealedSecrets:
+		if e.SealedSecretsCert == "" {
+			return errors.New("the sealed secrets controller certificate is required")
+		}
+	default:
+		return errors.Errorf("unsupported secret encryption method %q", e.Method)
+	}
+
+	return nil
+}
+
+type secretDocMeta struct {
+	APIVersion      string                 `yaml:"apiVersion"`
+	Kind            string                 `yaml:"kind"`
+	SOPS            map[string]interface{} `yaml:"sops"`
+	SecretGenerator []interface{}          `yaml:"secretGenerator"`
+}
+
+type encryptedSecretMeta struct {
+	Metadata struct {
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	// sealed secrets keep the metadata of the secret in the template
+	Spec struct {
+		Template struct {
+			Metadata struct {
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+// encryptSecrets replaces the Secrets in the files with their encrypted form.
+// Files are yaml documents keyed by path, as returned by renderGitOpsFiles.
+// Previous holds the encrypted secrets already in the repo, as returned by readEncryptedSecrets.
+func encryptSecrets(files map[string][]byte, encryption SecretEncryption, previous map[string][]byte) (map[string][]byte, error) {
+	if err := encryption.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid secret encryption")
+	}
+
+	if encryption.Method != "" {
+		for filename, content := range files {
+			if !isYAMLFile(filename) {
+				continue
+			}
+
+			docs := splitYAMLDocs(content)
+			changed := false
+			for i, doc := range docs {
+				if !isPlaintextSecret(doc) {
+					continue
+				}
+
+				checksum := secretChecksum(doc, encryption)
+				if existing, ok := previous[checksum]; ok && checksum != "" {
+					docs[i] = existing
+					changed = true
+					continue
+				}
+
+				if checksum != "" {
+					annotated, err := setAnnotation(doc, secretChecksumAnnotation, checksum)
+					if err != nil {
+						return nil, errors.Wrapf(err, "failed to annotate secret in %s", filename)
+					}
+					doc = annotated
+				}
+
+				encrypted, err := encryptSecret(doc, encryption)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to encrypt secret in %s", filename)
+				}
+				docs[i] = encrypted
+				changed = true
+			}
+
+			if changed {
+				files[filename] = joinYAMLDocs(docs)
+			}
+		}
+	}
+
+	if encryption.Required {
+		if plaintext := findPlaintextSecrets(files); len(plaintext) > 0 {
+			return nil, errors.Errorf("refusing to commit unencrypted secrets in %s", strings.Join(plaintext, ", "))
+		}
+	}
+
+	return files, nil
+}
+
+func encryptSecret(doc []byte, encryption SecretEncryption) ([]byte, error) {
+	switch encryption.Method {
+	case SecretEncryptionSOPS:
+		return encryptWithSOPS(doc, encryption)
+
+	case SecretEncryptionSealedSecrets:
+		decode := scheme.Codecs.UniversalDeserializer().Decode
+		obj, _, err := decode(doc, nil, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode secret")
+		}
+		secret, ok := obj.(*corev1.Secret)
+		if !ok {
+			return nil, errors.New("document is not a secret")
+		}
+		return secrets.SealSecret([]byte(encryption.SealedSecretsCert), secret)
+	}
+
+	return nil, errors.Errorf("unsupported secret encryption method %q", encryption.Method)
+}
+
+// encryptWithSOPS uses the sops binary to encrypt the data and stringData of the secret,
+// leaving the metadata readable so that the resource can still be reviewed
+func encryptWithSOPS(doc []byte, encryption SecretEncryption) ([]byte, error) {
+	tmpDir, err := ioutil.TempDir("", "kotsadm-sops")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	secretFile := filepath.Join(tmpDir, "secret.yaml")
+	if err := ioutil.WriteFile(secretFile, doc, 0600); err != nil {
+		return nil, errors.Wrap(err, "failed to write secret")
+	}
+
+	args := []string{
+		"--encrypt",
+		"--input-type", "yaml",
+		"--output-type", "yaml",
+		"--encrypted-regex", "^(data|stringData)$",
+	}
+	if len(encryption.AgeRecipients) > 0 {
+		args = append(args, "--age", strings.Join(encryption.AgeRecipients, ","))
+	}
+	env := os.Environ()
+	if len(encryption.PGPPublicKeys) > 0 {
+		// sops looks pgp keys up by fingerprint in the gpg keyring, so the keys are imported into a keyring of their own
+		gnupgHome := filepath.Join(tmpDir, "gnupg")
+		fingerprints, err := importPGPPublicKeys(gnupgHome, encryption.PGPPublicKeys)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to import pgp public keys")
+		}
+		env = append(env, fmt.Sprintf("GNUPGHOME=%s", gnupgHome))
+		args = append(args, "--pgp", strings.Join(fingerprints, ","))
+	}
+	args = append(args, secretFile)
+
+	cmd := exec.Command("sops", args...)
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("sops stderr: %q", string(ee.Stderr))
+		}
+		return nil, errors.Wrap(err, "failed to run sops")
+	}
+
+	return out, nil
+}
+
+// importPGPPublicKeys imports the armored public keys into a new gpg keyring and returns their fingerprints
+func importPGPPublicKeys(gnupgHome string, publicKeys []string) ([]string, error) {
+	fingerprints, err := pgpFingerprints(publicKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse keys")
+	}
+
+	if err := os.MkdirAll(gnupgHome, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create gnupg home")
+	}
+
+	cmd := exec.Command("gpg", "--batch", "--import")
+	cmd.Env = append(os.Environ(), fmt.Sprintf("GNUPGHOME=%s", gnupgHome))
+	cmd.Stdin = strings.NewReader(strings.Join(publicKeys, "\n"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, errors.Wrapf(err, "failed to run gpg: %s", string(out))
+	}
+
+	return fingerprints, nil
+}
+
+// pgpFingerprints returns the fingerprints of the primary keys in the armored public keys
+func pgpFingerprints(publicKeys []string) ([]string, error) {
+	fingerprints := []string{}
+	for _, publicKey := range publicKeys {
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read armored key")
+		}
+		for _, entity := range entities {
+			fingerprints = append(fingerprints, strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint[:])))
+		}
+	}
+
+	return fingerprints, nil
+}
+
+// readEncryptedSecrets returns the encrypted secrets in the files kots owns in the repo, keyed by the checksum of their plaintext.
+// Only files that are unchanged since kots committed them are read, so that an edited ciphertext is replaced and not kept.
+func readEncryptedSecrets(dirPath string, expectedFiles map[string]string) (map[string][]byte, error) {
+	encrypted := map[string][]byte{}
+	for filename, expectedHash := range expectedFiles {
+		if !isYAMLFile(filename) {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dirPath, filepath.FromSlash(filename)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to read %s", filename)
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != expectedHash {
+			continue
+		}
+
+		for _, doc := range splitYAMLDocs(content) {
+			if isPlaintextSecret(doc) {
+				continue
+			}
+			meta := encryptedSecretMeta{}
+			if err := yaml.Unmarshal(doc, &meta); err != nil {
+				continue
+			}
+			if checksum := meta.Metadata.Annotations[secretChecksumAnnotation]; checksum != "" {
+				encrypted[checksum] = doc
+			} else if checksum := meta.Spec.Template.Metadata.Annotations[secretChecksumAnnotation]; checksum != "" {
+				encrypted[checksum] = doc
+			}
+		}
+	}
+
+	return encrypted, nil
+}
+
+// secretChecksum is keyed with the api encryption key so that the annotation does not reveal the plaintext.
+// Without a key, secrets are always encrypted again.
+func secretChecksum(doc []byte, encryption SecretEncryption) string {
+	key := os.Getenv("API_ENCRYPTION_KEY")
+	if key == "" {
+		return ""
+	}
+
+	// a change of recipients or certificate needs a new ciphertext
+	config, err := json.Marshal(encryption)
+	if err != nil {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(config)
+	mac.Write(doc)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func setAnnotation(doc []byte, key string, value string) ([]byte, error) {
+	obj := yaml.MapSlice{}
+	if err := yaml.Unmarshal(doc, &obj); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal")
+	}
+
+	metadata := getMapSlice(obj, "metadata")
+	annotations := getMapSlice(metadata, "annotations")
+	annotations = setMapSliceItem(annotations, key, value)
+	metadata = setMapSliceItem(metadata, "annotations", annotations)
+	obj = setMapSliceItem(obj, "metadata", metadata)
+
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal")
+	}
+	return b, nil
+}
+
+func getMapSlice(m yaml.MapSlice, key string) yaml.MapSlice {
+	for _, item := range m {
+		if item.Key == key {
+			if value, ok := item.Value.(yaml.MapSlice); ok {
+				return value
+			}
+		}
+	}
+	return yaml.MapSlice{}
+}
+
+func setMapSliceItem(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+// findPlaintextSecrets returns the files that would put unencrypted secrets in the repo
+func findPlaintextSecrets(files map[string][]byte) []string {
+	plaintext := []string{}
+	for filename, content := range files {
+		if !isYAMLFile(filename) {
+			continue
+		}
+
+		for _, doc := range splitYAMLDocs(content) {
+			if isPlaintextSecret(doc) || hasSecretGenerator(doc) {
+				plaintext = append(plaintext, filename)
+				break
+			}
+		}
+	}
+
+	sort.Strings(plaintext)
+	return plaintext
+}
+
+func isPlaintextSecret(doc []byte) bool {
+	meta := secretDocMeta{}
+	if err := yaml.Unmarshal(doc, &meta); err != nil {
+		return false
+	}
+	// sops leaves the kind as is, but adds its metadata
+	return meta.APIVersion == "v1" && meta.Kind == "Secret" && meta.SOPS == nil
+}
+
+// secret generators in kustomization files render to plaintext secrets
+func hasSecretGenerator(doc []byte) bool {
+	meta := secretDocMeta{}
+	if err := yaml.Unmarshal(doc, &meta); err != nil {
+		return false
+	}
+	return len(meta.SecretGenerator) > 0
+}
+
+func isYAMLFile(filename string) bool {
+	ext := filepath.Ext(filename)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func splitYAMLDocs(content []byte) [][]byte {
+	docs := [][]byte{}
+	for _, doc := range bytes.Split(content, []byte("\n---\n")) {
+		doc = bytes.TrimPrefix(doc, []byte("---\n"))
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		if !bytes.HasSuffix(doc, []byte("\n")) {
+			doc = append(doc, '\n')
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func joinYAMLDocs(docs [][]byte) []byte {
+	return bytes.Join(docs, []byte("---\n"))
+}
@@ -0,0 +1,246 @@
+package gitops
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func Test_findPlaintextSecrets(t *testing.T) {
+	files := map[string][]byte{
+		"my-app/secret/plain.yaml": []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: plain
+data:
+  password: cGFzc3dvcmQ=
+`),
+		"my-app/secret/encrypted.yaml": []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: encrypted
+data:
+  password: ENC[AES256_GCM,data:abc,type:str]
+sops:
+  version: 3.7.1
+`),
+		"my-app/sealedsecret/sealed.yaml": []byte(`apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: sealed
+`),
+		"my-app/base/kustomization.yaml": []byte(`resources:
+- deployment.yaml
+secretGenerator:
+- name: generated
+  literals:
+  - password=password
+`),
+		"my-app.yaml": []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: plain
+stringData:
+  password: password
+`),
+		"my-app/README.md": []byte("kind: Secret"),
+	}
+
+	assert.Equal(t, []string{
+		"my-app.yaml",
+		"my-app/base/kustomization.yaml",
+		"my-app/secret/plain.yaml",
+	}, findPlaintextSecrets(files))
+}
+
+func Test_encryptSecretsRequired(t *testing.T) {
+	req := require.New(t)
+
+	files := map[string][]byte{
+		"my-app.yaml": []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: plain\n"),
+	}
+
+	// nothing to do without encryption configured
+	encrypted, err := encryptSecrets(files, SecretEncryption{}, nil)
+	req.NoError(err)
+	assert.Equal(t, files, encrypted)
+
+	_, err = encryptSecrets(files, SecretEncryption{Required: true}, nil)
+	assert.Error(t, err)
+
+	// the sealed secrets cert is not valid, so the secret is not encrypted
+	_, err = encryptSecrets(files, SecretEncryption{
+		Method:            SecretEncryptionSealedSecrets,
+		SealedSecretsCert: "not a cert",
+		Required:          true,
+	}, nil)
+	assert.Error(t, err)
+}
+
+func Test_encryptSecretsReusesCiphertext(t *testing.T) {
+	req := require.New(t)
+
+	os.Setenv("API_ENCRYPTION_KEY", "test-key")
+	defer os.Unsetenv("API_ENCRYPTION_KEY")
+
+	// the cert is not valid, so the secret can only be committed by reusing the previous ciphertext
+	encryption := SecretEncryption{
+		Method:            SecretEncryptionSealedSecrets,
+		SealedSecretsCert: "not a cert",
+		Required:          true,
+	}
+	plain := []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: plain\ndata:\n  password: cGFzc3dvcmQ=\n")
+	checksum := secretChecksum(plain, encryption)
+	req.NotEmpty(checksum)
+
+	sealed := []byte(fmt.Sprintf(`apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: plain
+spec:
+  encryptedData:
+    password: AgBy3i4OJSWK
+  template:
+    metadata:
+      annotations:
+        %s: %s
+      name: plain
+`, secretChecksumAnnotation, checksum))
+
+	dir, err := ioutil.TempDir("", "kotsadm-gitops")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	req.NoError(os.MkdirAll(filepath.Join(dir, "my-app", "secret"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(dir, "my-app", "secret", "plain.yaml"), sealed, 0644))
+
+	expectedFiles := fileHashes(map[string][]byte{"my-app/secret/plain.yaml": sealed})
+	previous, err := readEncryptedSecrets(dir, expectedFiles)
+	req.NoError(err)
+	assert.Equal(t, map[string][]byte{checksum: sealed}, previous)
+
+	// a ciphertext that was edited since it was committed is not reused
+	edited, err := readEncryptedSecrets(dir, map[string]string{"my-app/secret/plain.yaml": "abc"})
+	req.NoError(err)
+	assert.Empty(t, edited)
+
+	files := map[string][]byte{
+		"my-app/secret/plain.yaml": append([]byte{}, plain...),
+	}
+	encrypted, err := encryptSecrets(files, encryption, previous)
+	req.NoError(err)
+	assert.Equal(t, string(sealed), string(encrypted["my-app/secret/plain.yaml"]))
+
+	// a changed secret is encrypted again
+	files = map[string][]byte{
+		"my-app/secret/plain.yaml": []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: plain\ndata:\n  password: Y2hhbmdlZA==\n"),
+	}
+	_, err = encryptSecrets(files, encryption, previous)
+	assert.Error(t, err)
+}
+
+func Test_setAnnotation(t *testing.T) {
+	req := require.New(t)
+
+	annotated, err := setAnnotation([]byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: plain\n  annotations:\n    a: b\n"), "c", "d")
+	req.NoError(err)
+	assert.Equal(t, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: plain\n  annotations:\n    a: b\n    c: d\n", string(annotated))
+
+	annotated, err = setAnnotation([]byte("apiVersion: v1\nkind: Secret\n"), "c", "d")
+	req.NoError(err)
+	assert.Equal(t, "apiVersion: v1\nkind: Secret\nmetadata:\n  annotations:\n    c: d\n", string(annotated))
+}
+
+func Test_SecretEncryptionValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		encryption SecretEncryption
+		wantErr    bool
+	}{
+		{
+			name:       "disabled",
+			encryption: SecretEncryption{},
+		},
+		{
+			name:       "sops with age",
+			encryption: SecretEncryption{Method: SecretEncryptionSOPS, AgeRecipients: []string{"age1abc"}},
+		},
+		{
+			name:       "sops without recipients",
+			encryption: SecretEncryption{Method: SecretEncryptionSOPS},
+			wantErr:    true,
+		},
+		{
+			name:       "sops with invalid pgp key",
+			encryption: SecretEncryption{Method: SecretEncryptionSOPS, PGPPublicKeys: []string{"ABCDEF0123456789"}},
+			wantErr:    true,
+		},
+		{
+			name:       "sealed secrets without cert",
+			encryption: SecretEncryption{Method: SecretEncryptionSealedSecrets},
+			wantErr:    true,
+		},
+		{
+			name:       "required without method",
+			encryption: SecretEncryption{Required: true},
+			wantErr:    true,
+		},
+		{
+			name:       "unknown method",
+			encryption: SecretEncryption{Method: "vault"},
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.encryption.Validate()
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_pgpFingerprints(t *testing.T) {
+	req := require.New(t)
+
+	entity, err := openpgp.NewEntity("Release Bot", "", "bot@example.com", nil)
+	req.NoError(err)
+
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+	req.NoError(err)
+	req.NoError(entity.Serialize(w))
+	req.NoError(w.Close())
+
+	fingerprints, err := pgpFingerprints([]string{armored.String()})
+	req.NoError(err)
+	assert.Equal(t, []string{fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)}, fingerprints)
+	assert.Equal(t, 40, len(fingerprints[0]))
+	_, err = hex.DecodeString(fingerprints[0])
+	assert.NoError(t, err)
+
+	assert.NoError(t, SecretEncryption{Method: SecretEncryptionSOPS, PGPPublicKeys: []string{armored.String()}}.Validate())
+}
+
+func Test_splitYAMLDocs(t *testing.T) {
+	docs := splitYAMLDocs([]byte("---\na: 1\n---\nb: 2\n---\n\n"))
+	assert.Equal(t, [][]byte{[]byte("a: 1\n"), []byte("b: 2\n")}, docs)
+	assert.Equal(t, "a: 1\n---\nb: 2\n", string(joinYAMLDocs(docs)))
+}
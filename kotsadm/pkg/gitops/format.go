@@ -0,0 +1,165 @@
+package gitops
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// FormatSingle writes the kustomize build output to <appSlug>.yaml
+	FormatSingle = "single"
+	// FormatSplit writes one file per resource to <appSlug>/<kind>/<namespace>.<name>.yaml,
+	// or <appSlug>/<kind>/<name>.yaml for resources without a namespace
+	FormatSplit = "split"
+	// FormatKustomize writes the base and overlays to <appSlug>/ so they can be patched further
+	FormatKustomize = "kustomize"
+)
+
+func IsValidFormat(format string) bool {
+	switch format {
+	case "", FormatSingle, FormatSplit, FormatKustomize:
+		return true
+	}
+	return false
+}
+
+type manifestMeta struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+// renderGitOpsFiles returns the files to commit for the format, keyed by path relative to the gitops path
+func renderGitOpsFiles(format string, appSlug string, archiveDir string, downstreamName string, rendered []byte) (map[string][]byte, error) {
+	switch format {
+	case "", FormatSingle:
+		return map[string][]byte{
+			fmt.Sprintf("%s.yaml", appSlug): rendered,
+		}, nil
+
+	case FormatSplit:
+		files, err := splitManifests(rendered)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to split manifests")
+		}
+		prefixed := map[string][]byte{}
+		for filename, content := range files {
+			prefixed[filepath.Join(appSlug, filename)] = content
+		}
+		return prefixed, nil
+
+	case FormatKustomize:
+		files := map[string][]byte{}
+		dirs := []string{
+			"base",
+			filepath.Join("overlays", "midstream"),
+			filepath.Join("overlays", "downstreams", downstreamName),
+		}
+		for _, dir := range dirs {
+			err := filepath.Walk(filepath.Join(archiveDir, dir), func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() {
+					return nil
+				}
+				content, err := ioutil.ReadFile(path)
+				if err != nil {
+					return errors.Wrapf(err, "failed to read %s", path)
+				}
+				relPath, err := filepath.Rel(archiveDir, path)
+				if err != nil {
+					return errors.Wrap(err, "failed to get relative path")
+				}
+				files[filepath.Join(appSlug, relPath)] = content
+				return nil
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to walk %s", dir)
+			}
+		}
+		return files, nil
+	}
+
+	return nil, errors.Errorf("unsupported gitops format %q", format)
+}
+
+// splitManifests splits a multi document yaml into files in a kind/[namespace.]name layout
+func splitManifests(content []byte) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	docs := bytes.Split(content, []byte("\n---\n"))
+	for _, doc := range docs {
+		doc = bytes.TrimPrefix(doc, []byte("---\n"))
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		meta := manifestMeta{}
+		if err := yaml.Unmarshal(doc, &meta); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal manifest")
+		}
+		if meta.Kind == "" || meta.Metadata.Name == "" {
+			return nil, errors.Errorf("manifest is missing kind or name:\n%s", string(doc))
+		}
+
+		// the same name can be used in different namespaces. the namespace is always part of the name,
+		// so that file names don't depend on the order of the manifests
+		kind := strings.ToLower(meta.Kind)
+		filename := filepath.Join(kind, fmt.Sprintf("%s.yaml", meta.Metadata.Name))
+		if meta.Metadata.Namespace != "" {
+			filename = filepath.Join(kind, fmt.Sprintf("%s.%s.yaml", meta.Metadata.Namespace, meta.Metadata.Name))
+		}
+		if _, exists := files[filename]; exists {
+			return nil, errors.Errorf("duplicate manifest %s", filename)
+		}
+
+		if !bytes.HasSuffix(doc, []byte("\n")) {
+			doc = append(doc, '\n')
+		}
+		files[filename] = doc
+	}
+
+	return files, nil
+}
+
+// writeGitOpsFiles replaces any output previously written by kots for the app in dirPath with the given files
+// and returns the paths written, relative to dirPath
+func writeGitOpsFiles(dirPath string, appSlug string, files map[string][]byte) ([]string, error) {
+	// the app directory and the single file are owned by kots, remove them to clean up
+	// resources that no longer exist and output from a previous format
+	if err := os.RemoveAll(filepath.Join(dirPath, appSlug)); err != nil {
+		return nil, errors.Wrap(err, "failed to remove app directory")
+	}
+	if err := os.Remove(filepath.Join(dirPath, fmt.Sprintf("%s.yaml", appSlug))); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to remove app yaml")
+	}
+
+	filenames := []string{}
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		filePath := filepath.Join(dirPath, filename)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return nil, errors.Wrap(err, "failed to mkdir")
+		}
+		if err := ioutil.WriteFile(filePath, files[filename], 0644); err != nil {
+			return nil, errors.Wrapf(err, "failed to write %s", filename)
+		}
+	}
+
+	return filenames, nil
+}
@@ -0,0 +1,134 @@
+package gitops
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_splitManifests(t *testing.T) {
+	req := require.New(t)
+
+	content := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: default
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: other
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: web
+`
+
+	files, err := splitManifests([]byte(content))
+	req.NoError(err)
+
+	req.Len(files, 4)
+	assert.Equal(t, "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: default\n", string(files["service/default.web.yaml"]))
+	assert.Contains(t, string(files["deployment/default.web.yaml"]), "kind: Deployment")
+	assert.Contains(t, string(files["service/other.web.yaml"]), "namespace: other")
+	assert.Contains(t, string(files["clusterrole/web.yaml"]), "kind: ClusterRole")
+}
+
+func Test_splitManifestsOrder(t *testing.T) {
+	req := require.New(t)
+
+	first := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: default\n"
+	second := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: other\n"
+
+	files, err := splitManifests([]byte(first + "---\n" + second))
+	req.NoError(err)
+
+	reversed, err := splitManifests([]byte(second + "---\n" + first))
+	req.NoError(err)
+
+	assert.Equal(t, files, reversed)
+}
+
+func Test_splitManifestsDuplicate(t *testing.T) {
+	content := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: default\n"
+	_, err := splitManifests([]byte(content + "---\n" + content))
+	assert.Error(t, err)
+}
+
+func Test_splitManifestsMissingName(t *testing.T) {
+	_, err := splitManifests([]byte("apiVersion: v1\nkind: ConfigMap\n"))
+	assert.Error(t, err)
+}
+
+func Test_renderGitOpsFilesKustomize(t *testing.T) {
+	req := require.New(t)
+
+	archiveDir, err := ioutil.TempDir("", "kotsadm")
+	req.NoError(err)
+	defer os.RemoveAll(archiveDir)
+
+	archiveFiles := map[string]string{
+		"upstream/deployment.yaml":                              "upstream",
+		"base/kustomization.yaml":                               "base",
+		"overlays/midstream/kustomization.yaml":                 "midstream",
+		"overlays/downstreams/this-cluster/kustomization.yaml":  "downstream",
+		"overlays/downstreams/other-cluster/kustomization.yaml": "other",
+	}
+	for filename, content := range archiveFiles {
+		req.NoError(os.MkdirAll(filepath.Join(archiveDir, filepath.Dir(filename)), 0755))
+		req.NoError(ioutil.WriteFile(filepath.Join(archiveDir, filename), []byte(content), 0644))
+	}
+
+	files, err := renderGitOpsFiles(FormatKustomize, "my-app", archiveDir, "this-cluster", nil)
+	req.NoError(err)
+
+	assert.Equal(t, map[string][]byte{
+		"my-app/base/kustomization.yaml":                              []byte("base"),
+		"my-app/overlays/midstream/kustomization.yaml":                []byte("midstream"),
+		"my-app/overlays/downstreams/this-cluster/kustomization.yaml": []byte("downstream"),
+	}, files)
+}
+
+func Test_writeGitOpsFiles(t *testing.T) {
+	req := require.New(t)
+
+	dirPath, err := ioutil.TempDir("", "kotsadm")
+	req.NoError(err)
+	defer os.RemoveAll(dirPath)
+
+	// output from a previous single file commit and a resource that no longer exists
+	req.NoError(ioutil.WriteFile(filepath.Join(dirPath, "my-app.yaml"), []byte("old"), 0644))
+	req.NoError(os.MkdirAll(filepath.Join(dirPath, "my-app", "configmap"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(dirPath, "my-app", "configmap", "removed.yaml"), []byte("old"), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(dirPath, "other-app.yaml"), []byte("other"), 0644))
+
+	filenames, err := writeGitOpsFiles(dirPath, "my-app", map[string][]byte{
+		"my-app/service/web.yaml": []byte("web"),
+	})
+	req.NoError(err)
+	assert.Equal(t, []string{"my-app/service/web.yaml"}, filenames)
+
+	_, err = os.Stat(filepath.Join(dirPath, "my-app.yaml"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dirPath, "my-app", "configmap", "removed.yaml"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dirPath, "other-app.yaml"))
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dirPath, "my-app", "service", "web.yaml"))
+	req.NoError(err)
+	assert.Equal(t, "web", string(content))
+}
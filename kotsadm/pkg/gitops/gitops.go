@@ -17,11 +17,11 @@ import (
 	"time"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/object"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	go_git_ssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/pkg/errors"
-	"github.com/replicatedhq/kots/pkg/crypto"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/pkg/kotsadm/types"
 	"github.com/replicatedhq/kots/pkg/kotsutil"
 	"golang.org/x/crypto/ssh"
@@ -33,6 +33,8 @@ import (
 )
 
 type GitOpsConfig struct {
+	AppID       string `json:"-"`
+	ClusterID   string `json:"-"`
 	Provider    string `json:"provider"`
 	RepoURI     string `json:"repoUri"`
 	Hostname    string `json:"hostname"`
@@ -40,16 +42,36 @@ type GitOpsConfig struct {
 	Branch      string `json:"branch"`
 	Format      string `json:"format"`
 	Action      string `json:"action"`
+	AutoRepush  bool   `json:"autoRepush"`
+	AuthMethod  string `json:"authMethod"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"-"`
 	PublicKey   string `json:"publicKey"`
 	PrivateKey  string `json:"-"`
+	APIToken    string `json:"-"`
 	IsConnected bool   `json:"isConnected"`
+
+	CommitAuthorName      string `json:"commitAuthorName,omitempty"`
+	CommitAuthorEmail     string `json:"commitAuthorEmail,omitempty"`
+	CommitMessageTemplate string `json:"commitMessageTemplate,omitempty"`
+	SigningMethod         string `json:"signingMethod,omitempty"`
+	SigningKey            string `json:"-"`
+	SigningKeyPassphrase  string `json:"-"`
+
+	SecretEncryption SecretEncryption `json:"secretEncryption"`
 }
 
 type GlobalGitOpsConfig struct {
-	Enabled  bool   `json:"enabled"`
-	Hostname string `json:"hostname"`
-	Provider string `json:"provider"`
-	URI      string `json:"uri"`
+	Enabled    bool   `json:"enabled"`
+	Hostname   string `json:"hostname"`
+	Provider   string `json:"provider"`
+	URI        string `json:"uri"`
+	AuthMethod string `json:"authMethod"`
+
+	CommitAuthorName      string `json:"commitAuthorName,omitempty"`
+	CommitAuthorEmail     string `json:"commitAuthorEmail,omitempty"`
+	CommitMessageTemplate string `json:"commitMessageTemplate,omitempty"`
+	SigningMethod         string `json:"signingMethod,omitempty"`
 }
 
 type KeyPair struct {
@@ -74,6 +96,10 @@ func (g *GitOpsConfig) CommitURL(hash string) string {
 }
 
 func (g *GitOpsConfig) CloneURL() string {
+	if g.isHTTPS() {
+		return g.httpsCloneURL()
+	}
+
 	// copied this logic from node js api
 	// this feels incomplete and fragile....  needs enterprise support
 	uriParts := strings.Split(g.RepoURI, "/")
@@ -85,7 +111,7 @@ func (g *GitOpsConfig) CloneURL() string {
 		return fmt.Sprintf("git@gitlab.com:%s/%s.git", uriParts[3], uriParts[4])
 	case "bitbucket":
 		return fmt.Sprintf("git@bitbucket.org:%s/%s.git", uriParts[3], uriParts[4])
-	case "github_enterprise", "gitlab_enterprise":
+	case "github_enterprise", "gitlab_enterprise", "gitea":
 		return fmt.Sprintf("git@%s:%s/%s.git", uriParts[2], uriParts[3], uriParts[4])
 	}
 
@@ -147,36 +173,73 @@ func GetDownstreamGitOps(appID string, clusterID string) (*GitOpsConfig, error)
 				}
 				provider, publicKey, privateKey, repoURI, hostname := gitOpsConfigFromSecretData(idx, secret.Data)
 
-				cipher, err := crypto.AESCipherFromString(os.Getenv("API_ENCRYPTION_KEY"))
+				decryptedPrivateKey, err := decryptSecretValue([]byte(privateKey))
 				if err != nil {
-					return nil, errors.Wrap(err, "failed to create aes cipher")
+					return nil, errors.Wrap(err, "failed to decrypt private key")
 				}
-				decodedPrivateKey, err := base64.StdEncoding.DecodeString(privateKey)
+
+				apiToken, err := decryptSecretValue(secret.Data[fmt.Sprintf("provider.%d.apiToken", idx)])
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to decrypt api token")
+				}
+
+				password, err := decryptSecretValue(secret.Data[fmt.Sprintf("provider.%d.password", idx)])
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to decrypt password")
+				}
+
+				signingKey, err := decryptSecretValue(secret.Data[fmt.Sprintf("provider.%d.signingKey", idx)])
 				if err != nil {
-					return nil, errors.Wrap(err, "failed to decode")
+					return nil, errors.Wrap(err, "failed to decrypt signing key")
 				}
 
-				decryptedPrivateKey, err := cipher.Decrypt([]byte(decodedPrivateKey))
+				signingKeyPassphrase, err := decryptSecretValue(secret.Data[fmt.Sprintf("provider.%d.signingKeyPassphrase", idx)])
 				if err != nil {
-					return nil, errors.Wrap(err, "failed to decrypt")
+					return nil, errors.Wrap(err, "failed to decrypt signing key passphrase")
+				}
+
+				authMethod := string(secret.Data[fmt.Sprintf("provider.%d.authMethod", idx)])
+				if apiToken == "" && authMethod == AuthMethodHTTPSToken {
+					// the token used to push can also be used to manage pull requests
+					apiToken = password
 				}
 
 				gitOpsConfig := GitOpsConfig{
+					AppID:      appID,
+					ClusterID:  clusterID,
 					Provider:   provider,
+					AuthMethod: authMethod,
+					Username:   string(secret.Data[fmt.Sprintf("provider.%d.username", idx)]),
+					Password:   password,
 					PublicKey:  publicKey,
-					PrivateKey: string(decryptedPrivateKey),
+					PrivateKey: decryptedPrivateKey,
+					APIToken:   apiToken,
 					RepoURI:    repoURI,
 					Hostname:   hostname,
 					Branch:     configMapData["branch"],
 					Path:       configMapData["path"],
 					Format:     configMapData["format"],
 					Action:     configMapData["action"],
+					AutoRepush: configMapData["autoRepush"] == "true",
+
+					CommitAuthorName:      string(secret.Data[fmt.Sprintf("provider.%d.commitAuthorName", idx)]),
+					CommitAuthorEmail:     string(secret.Data[fmt.Sprintf("provider.%d.commitAuthorEmail", idx)]),
+					CommitMessageTemplate: string(secret.Data[fmt.Sprintf("provider.%d.commitMessageTemplate", idx)]),
+					SigningMethod:         string(secret.Data[fmt.Sprintf("provider.%d.signingMethod", idx)]),
+					SigningKey:            signingKey,
+					SigningKeyPassphrase:  signingKeyPassphrase,
 				}
 
 				if lastError, ok := configMapData["lastError"]; ok && lastError == "" {
 					gitOpsConfig.IsConnected = true
 				}
 
+				if secretEncryption := configMapData["secretEncryption"]; secretEncryption != "" {
+					if err := json.Unmarshal([]byte(secretEncryption), &gitOpsConfig.SecretEncryption); err != nil {
+						return nil, errors.Wrap(err, "failed to unmarshal secret encryption")
+					}
+				}
+
 				return &gitOpsConfig, nil
 			}
 		}
@@ -215,7 +278,7 @@ func DisableDownstreamGitOps(appID string, clusterID string) error {
 	return nil
 }
 
-func UpdateDownstreamGitOps(appID, clusterID, uri, branch, path, format, action string) error {
+func UpdateDownstreamGitOps(appID, clusterID, uri, branch, path, format, action string, autoRepush bool) error {
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return errors.Wrap(err, "failed to get cluster config")
@@ -249,6 +312,9 @@ func UpdateDownstreamGitOps(appID, clusterID, uri, branch, path, format, action
 		"format":  format,
 		"action":  action,
 	}
+	if autoRepush {
+		newAppData["autoRepush"] = "true"
+	}
 
 	// check if to reset or keep last error
 	appDataEncoded, ok := configMapData[appKey]
@@ -271,6 +337,10 @@ func UpdateDownstreamGitOps(appID, clusterID, uri, branch, path, format, action
 				newAppData["lastError"] = lastError // keep last error
 			}
 		}
+
+		if secretEncryption, ok := appDataUnmarshalled["secretEncryption"]; ok {
+			newAppData["secretEncryption"] = secretEncryption
+		}
 	}
 
 	// update/set app data in config map
@@ -353,8 +423,65 @@ func SetGitOpsError(appID string, clusterID string, errMsg string) error {
 	return nil
 }
 
+func SetDownstreamGitOpsSecretEncryption(appID string, clusterID string, encryption SecretEncryption) error {
+	if err := encryption.Validate(); err != nil {
+		return errors.Wrap(err, "invalid secret encryption")
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	configMap, err := clientset.CoreV1().ConfigMaps(os.Getenv("POD_NAMESPACE")).Get(context.TODO(), "kotsadm-gitops", metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get configmap")
+	}
+
+	appKey := fmt.Sprintf("%s-%s", appID, clusterID)
+	appDataEncoded, ok := configMap.Data[appKey]
+	if !ok {
+		return errors.New("app gitops data not found in configmap")
+	}
+
+	appDataDecoded, err := base64.StdEncoding.DecodeString(appDataEncoded)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode app data")
+	}
+
+	appDataUnmarshalled := map[string]string{}
+	if err := json.Unmarshal(appDataDecoded, &appDataUnmarshalled); err != nil {
+		return errors.Wrap(err, "failed to unmarshal app data")
+	}
+
+	encryptionMarshalled, err := json.Marshal(encryption)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal secret encryption")
+	}
+	appDataUnmarshalled["secretEncryption"] = string(encryptionMarshalled)
+
+	appDataDecoded, err = json.Marshal(appDataUnmarshalled)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal app data")
+	}
+	appDataEncoded = base64.StdEncoding.EncodeToString([]byte(appDataDecoded))
+	configMap.Data[appKey] = appDataEncoded
+
+	_, err = clientset.CoreV1().ConfigMaps(os.Getenv("POD_NAMESPACE")).Update(context.TODO(), configMap, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to update config map")
+	}
+
+	return nil
+}
+
 func TestGitOpsConnection(gitOpsConfig *GitOpsConfig) error {
-	auth, err := getAuth(gitOpsConfig.PrivateKey)
+	auth, err := getAuth(gitOpsConfig)
 	if err != nil {
 		return errors.Wrap(err, "failed to get auth")
 	}
@@ -371,13 +498,24 @@ func TestGitOpsConnection(gitOpsConfig *GitOpsConfig) error {
 		Auth:              auth,
 	})
 	if err != nil && errors.Cause(err) != transport.ErrEmptyRemoteRepository {
+		switch errors.Cause(err) {
+		case transport.ErrAuthenticationRequired, transport.ErrAuthorizationFailed:
+			return errors.Wrapf(err, "the git server rejected the %s credentials", gitOpsConfig.authMethod())
+		}
 		return errors.Wrap(err, "failed to clone repo")
 	}
 
 	return nil
 }
 
-func CreateGitOps(provider string, repoURI string, hostname string) error {
+func CreateGitOps(provider string, repoURI string, hostname string, apiToken string, auth GitOpsAuth, commitSettings CommitSettings) error {
+	if !IsValidSigningMethod(commitSettings.SigningMethod) {
+		return errors.Errorf("unsupported signing method %q", commitSettings.SigningMethod)
+	}
+	if err := ValidateCommitMessageTemplate(commitSettings.MessageTemplate); err != nil {
+		return errors.Wrap(err, "invalid commit message template")
+	}
+
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return errors.Wrap(err, "failed to get cluster config")
@@ -439,21 +577,52 @@ func CreateGitOps(provider string, repoURI string, hostname string) error {
 	secretData[fmt.Sprintf("provider.%d.type", repoIdx)] = []byte(provider)
 	secretData[fmt.Sprintf("provider.%d.repoUri", repoIdx)] = []byte(repoURI)
 
-	if !repoExists {
-		keyPair, err := generateKeyPair()
-		if err != nil {
-			return errors.Wrap(err, "failed to generate key pair")
+	authMethod := auth.Method
+	if authMethod == "" {
+		authMethod = AuthMethodSSHKey
+	}
+	secretData[fmt.Sprintf("provider.%d.authMethod", repoIdx)] = []byte(authMethod)
+
+	switch authMethod {
+	case AuthMethodSSHKey:
+		privateKeyKey := fmt.Sprintf("provider.%d.privateKey", repoIdx)
+		if _, ok := secretData[privateKeyKey]; !ok {
+			keyPair, err := generateKeyPair()
+			if err != nil {
+				return errors.Wrap(err, "failed to generate key pair")
+			}
+
+			// encoding here shouldn't be needed. moved logic from TS where ffi EncryptString function base64 encodes the value as well
+			encodedPrivateKey, err := encryptSecretValue(keyPair.PrivateKeyPEM)
+			if err != nil {
+				return errors.Wrap(err, "failed to encrypt private key")
+			}
+
+			secretData[privateKeyKey] = encodedPrivateKey
+			secretData[fmt.Sprintf("provider.%d.publicKey", repoIdx)] = []byte(keyPair.PublicKeySSH)
 		}
 
-		cipher, err := crypto.AESCipherFromString(os.Getenv("API_ENCRYPTION_KEY"))
-		if err != nil {
-			return errors.Wrap(err, "failed to create aes cipher")
+		delete(secretData, fmt.Sprintf("provider.%d.username", repoIdx))
+		delete(secretData, fmt.Sprintf("provider.%d.password", repoIdx))
+
+	case AuthMethodHTTPSToken, AuthMethodHTTPSBasic:
+		usernameKey := fmt.Sprintf("provider.%d.username", repoIdx)
+		delete(secretData, usernameKey)
+		if auth.Username != "" {
+			secretData[usernameKey] = []byte(auth.Username)
 		}
-		encryptedPrivateKey := cipher.Encrypt([]byte(keyPair.PrivateKeyPEM))
-		encodedPrivateKey := base64.StdEncoding.EncodeToString(encryptedPrivateKey) // encoding here shouldn't be needed. moved logic from TS where ffi EncryptString function base64 encodes the value as well
 
-		secretData[fmt.Sprintf("provider.%d.privateKey", repoIdx)] = []byte(encodedPrivateKey)
-		secretData[fmt.Sprintf("provider.%d.publicKey", repoIdx)] = []byte(keyPair.PublicKeySSH)
+		// an empty password keeps the current one
+		if auth.Password != "" {
+			encodedPassword, err := encryptSecretValue(auth.Password)
+			if err != nil {
+				return errors.Wrap(err, "failed to encrypt password")
+			}
+			secretData[fmt.Sprintf("provider.%d.password", repoIdx)] = encodedPassword
+		}
+
+	default:
+		return errors.Errorf("unsupported auth method %q", authMethod)
 	}
 
 	hostnameKey := fmt.Sprintf("provider.%d.hostname", repoIdx)
@@ -466,6 +635,51 @@ func CreateGitOps(provider string, repoURI string, hostname string) error {
 		secretData[hostnameKey] = []byte(hostname)
 	}
 
+	// the api token is only needed to open pull requests, an empty token keeps the current one
+	if apiToken != "" {
+		encodedAPIToken, err := encryptSecretValue(apiToken)
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt api token")
+		}
+		secretData[fmt.Sprintf("provider.%d.apiToken", repoIdx)] = encodedAPIToken
+	}
+
+	setOptionalSecretValue(secretData, fmt.Sprintf("provider.%d.commitAuthorName", repoIdx), commitSettings.AuthorName)
+	setOptionalSecretValue(secretData, fmt.Sprintf("provider.%d.commitAuthorEmail", repoIdx), commitSettings.AuthorEmail)
+	setOptionalSecretValue(secretData, fmt.Sprintf("provider.%d.commitMessageTemplate", repoIdx), commitSettings.MessageTemplate)
+
+	signingMethodKey := fmt.Sprintf("provider.%d.signingMethod", repoIdx)
+	signingKeyKey := fmt.Sprintf("provider.%d.signingKey", repoIdx)
+	signingKeyPassphraseKey := fmt.Sprintf("provider.%d.signingKeyPassphrase", repoIdx)
+	if commitSettings.SigningMethod == "" {
+		delete(secretData, signingMethodKey)
+		delete(secretData, signingKeyKey)
+		delete(secretData, signingKeyPassphraseKey)
+	} else {
+		// an empty key keeps the current one, as long as the method has not changed
+		if commitSettings.SigningKey == "" && string(secretData[signingMethodKey]) != commitSettings.SigningMethod {
+			return errors.New("a signing key is required")
+		}
+		secretData[signingMethodKey] = []byte(commitSettings.SigningMethod)
+
+		if commitSettings.SigningKey != "" {
+			encodedSigningKey, err := encryptSecretValue(commitSettings.SigningKey)
+			if err != nil {
+				return errors.Wrap(err, "failed to encrypt signing key")
+			}
+			secretData[signingKeyKey] = encodedSigningKey
+
+			delete(secretData, signingKeyPassphraseKey)
+			if commitSettings.SigningKeyPassphrase != "" {
+				encodedPassphrase, err := encryptSecretValue(commitSettings.SigningKeyPassphrase)
+				if err != nil {
+					return errors.Wrap(err, "failed to encrypt signing key passphrase")
+				}
+				secretData[signingKeyPassphraseKey] = encodedPassphrase
+			}
+		}
+	}
+
 	if secretExists {
 		secret.Data = secretData
 		_, err = clientset.CoreV1().Secrets(os.Getenv("POD_NAMESPACE")).Update(context.TODO(), secret, metav1.UpdateOptions{})
@@ -539,9 +753,26 @@ func GetGitOps() (GlobalGitOpsConfig, error) {
 		Hostname: string(secret.Data["provider.0.hostname"]),
 	}
 
+	parsedConfig.AuthMethod = string(secret.Data["provider.0.authMethod"])
+	if parsedConfig.AuthMethod == "" {
+		parsedConfig.AuthMethod = AuthMethodSSHKey
+	}
+
+	parsedConfig.CommitAuthorName = string(secret.Data["provider.0.commitAuthorName"])
+	parsedConfig.CommitAuthorEmail = string(secret.Data["provider.0.commitAuthorEmail"])
+	parsedConfig.CommitMessageTemplate = string(secret.Data["provider.0.commitMessageTemplate"])
+	parsedConfig.SigningMethod = string(secret.Data["provider.0.signingMethod"])
+
 	return parsedConfig, nil
 }
 
+func setOptionalSecretValue(secretData map[string][]byte, key string, value string) {
+	delete(secretData, key)
+	if value != "" {
+		secretData[key] = []byte(value)
+	}
+}
+
 func gitOpsConfigFromSecretData(idx int64, secretData map[string][]byte) (string, string, string, string, string) {
 	provider := ""
 	publicKey := ""
@@ -577,21 +808,35 @@ func gitOpsConfigFromSecretData(idx int64, secretData map[string][]byte) (string
 	return provider, publicKey, privateKey, repoURI, hostname
 }
 
-func getAuth(privateKey string) (transport.AuthMethod, error) {
-	var auth transport.AuthMethod
-	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+// CreateGitOpsCommit writes the rendered app to the repo and pushes it. With the pull request action,
+// the commit is pushed to a branch for the version and a pull request is opened against the configured branch.
+// previousArchiveDir is used to describe the config changes in the commit message and can be empty.
+func CreateGitOpsCommit(gitOpsConfig *GitOpsConfig, appSlug string, appName string, newSequence int, archiveDir string, previousArchiveDir string, downstreamName string, diffSummary string) (string, error) {
+	kotsKinds, err := kotsutil.LoadKotsKindsFromPath(archiveDir)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse deploy key")
+		return "", errors.Wrap(err, "failed to load kots kinds")
 	}
-	auth = &go_git_ssh.PublicKeys{User: "git", Signer: signer}
-	auth.(*go_git_ssh.PublicKeys).HostKeyCallback = ssh.InsecureIgnoreHostKey()
-	return auth, nil
-}
 
-func CreateGitOpsCommit(gitOpsConfig *GitOpsConfig, appSlug string, appName string, newSequence int, archiveDir string, downstreamName string) (string, error) {
-	kotsKinds, err := kotsutil.LoadKotsKindsFromPath(archiveDir)
+	var previousConfigValues *kotsv1beta1.ConfigValues
+	if previousArchiveDir != "" {
+		previousKotsKinds, err := kotsutil.LoadKotsKindsFromPath(previousArchiveDir)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to load previous kots kinds")
+		}
+		previousConfigValues = previousKotsKinds.ConfigValues
+	}
+
+	commitMessage, err := renderCommitMessage(gitOpsConfig.CommitMessageTemplate, CommitMessageData{
+		AppName:      appName,
+		AppSlug:      appSlug,
+		Sequence:     newSequence,
+		VersionLabel: kotsKinds.Installation.Spec.VersionLabel,
+		ReleaseNotes: kotsKinds.Installation.Spec.ReleaseNotes,
+		ConfigDiff:   configDiff(previousConfigValues, kotsKinds.ConfigValues),
+		DiffSummary:  diffSummary,
+	})
 	if err != nil {
-		return "", errors.Wrap(err, "failed to load kots kinds")
+		return "", errors.Wrap(err, "failed to render commit message")
 	}
 
 	// we use the kustomize binary here...
@@ -604,8 +849,8 @@ func CreateGitOpsCommit(gitOpsConfig *GitOpsConfig, appSlug string, appName stri
 		return "", errors.Wrap(err, "failed to run kustomize")
 	}
 
-	// using the deploy key, create the commit in a new branch
-	auth, err := getAuth(gitOpsConfig.PrivateKey)
+	// using the configured credentials, create the commit in a new branch
+	auth, err := getAuth(gitOpsConfig)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get auth")
 	}
@@ -627,6 +872,23 @@ func CreateGitOpsCommit(gitOpsConfig *GitOpsConfig, appSlug string, appName stri
 		return "", err
 	}
 
+	isPullRequest := gitOpsConfig.Action == ActionPullRequest
+	pushBranch := gitOpsConfig.Branch
+	if isPullRequest {
+		recordedPullRequest, err := getRecordedPullRequest(gitOpsConfig.AppID, gitOpsConfig.ClusterID, int64(newSequence))
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get recorded pull request")
+		}
+		pushBranch = pullRequestBranch(appSlug, newSequence, recordedPullRequest, time.Now())
+		err = workTree.Checkout(&git.CheckoutOptions{
+			Create: true,
+			Branch: plumbing.NewBranchReferenceName(pushBranch),
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create branch %s", pushBranch)
+		}
+	}
+
 	dirPath := filepath.Join(workDir, gitOpsConfig.Path)
 	_, err = os.Stat(dirPath)
 	if os.IsNotExist(err) {
@@ -635,52 +897,121 @@ func CreateGitOpsCommit(gitOpsConfig *GitOpsConfig, appSlug string, appName stri
 		if err != nil {
 			return "", errors.Wrap(err, "failed to mkdir")
 		}
-	} // ignore error here and let the stat of the file below handle any errors
+	} // ignore error here and let the writes below handle any errors
 
-	filePath := filepath.Join(dirPath, fmt.Sprintf("%s.yaml", appSlug))
-	_, err = os.Stat(filePath)
-	if err == nil { // if the file has not changed, end now
-		currentRevision, err := ioutil.ReadFile(filePath)
-		if err != nil {
-			return "", errors.Wrap(err, "failed to read current app yaml")
-		}
-		if string(currentRevision) == string(out) {
-			return "", nil
-		}
-	} else if !os.IsNotExist(err) {
-		return "", errors.Wrap(err, "failed to stat current app yaml")
+	files, err := renderGitOpsFiles(gitOpsConfig.Format, appSlug, archiveDir, downstreamName, out)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render gitops files")
 	}
 
-	err = ioutil.WriteFile(filePath, out, 0644)
+	// read before the files are replaced so that unchanged secrets keep their ciphertext
+	syncState, err := GetSyncState(gitOpsConfig.AppID, gitOpsConfig.ClusterID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get sync state")
+	}
+	expectedFiles := map[string]string{}
+	if syncState != nil {
+		expectedFiles = syncState.ExpectedFiles
+	}
+	previousSecrets, err := readEncryptedSecrets(dirPath, expectedFiles)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to write updated app yaml")
+		return "", errors.Wrap(err, "failed to read encrypted secrets")
 	}
 
-	_, err = workTree.Add(strings.TrimPrefix(filepath.Join(gitOpsConfig.Path, fmt.Sprintf("%s.yaml", appSlug)), "/"))
+	files, err = encryptSecrets(files, gitOpsConfig.SecretEncryption, previousSecrets)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to add to worktree")
+		return "", errors.Wrap(err, "failed to encrypt secrets")
+	}
+
+	filenames, err := writeGitOpsFiles(dirPath, appSlug, files)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write gitops files")
+	}
+
+	for _, filename := range filenames {
+		_, err = workTree.Add(strings.TrimPrefix(filepath.Join(gitOpsConfig.Path, filename), "/"))
+		if err != nil {
+			return "", errors.Wrap(err, "failed to add to worktree")
+		}
+	}
+
+	status, err := workTree.Status()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get worktree status")
+	}
+	if status.IsClean() { // if the files have not changed, end now
+		head, err := cloned.Head()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get head")
+		}
+		if err := recordSyncState(gitOpsConfig, appSlug, int64(newSequence), head.Hash().String(), files, SyncStatusSynced); err != nil {
+			return "", errors.Wrap(err, "failed to record sync state")
+		}
+		return "", nil
 	}
 
 	// commit it
-	updatedHash, err := workTree.Commit(fmt.Sprintf("Updating %s to version %d", appName, newSequence), &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  "KOTS Admin Console",
-			Email: "help@replicated.com",
-			When:  time.Now(),
-		},
-	})
+	updatedHash, err := commit(cloned, workTree, gitOpsConfig, commitMessage)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to commit")
 	}
 
-	err = cloned.Push(&git.PushOptions{
+	pushOptions := &git.PushOptions{
 		RemoteName: cloneOptions.RemoteName,
 		Auth:       auth,
-	})
+	}
+	if isPullRequest {
+		branchRefName := plumbing.NewBranchReferenceName(pushBranch)
+		pushOptions.RefSpecs = []gitconfig.RefSpec{
+			gitconfig.RefSpec(fmt.Sprintf("+%s:%s", branchRefName, branchRefName)),
+		}
+	}
+	err = cloned.Push(pushOptions)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to push")
 	}
 
+	syncStatus := SyncStatusSynced
+	if isPullRequest {
+		syncStatus = SyncStatusPending
+	}
+	if err := recordSyncState(gitOpsConfig, appSlug, int64(newSequence), updatedHash.String(), files, syncStatus); err != nil {
+		return "", errors.Wrap(err, "failed to record sync state")
+	}
+
+	if isPullRequest {
+		versionLabel := kotsKinds.Installation.Spec.VersionLabel
+		releaseNotes := kotsKinds.Installation.Spec.ReleaseNotes
+
+		input := pullRequestInput{
+			Title:      fmt.Sprintf("Update %s to version %s", appName, versionLabel),
+			Body:       pullRequestBody(appName, versionLabel, newSequence, releaseNotes, diffSummary),
+			HeadBranch: pushBranch,
+			BaseBranch: gitOpsConfig.Branch,
+		}
+
+		// the branch already has an open pull request when the sequence is pushed again, e.g. by a re-push or
+		// when gitops is re-initialized. the push updated its commits, creating another one would fail.
+		pr, err := findOpenPullRequest(gitOpsConfig, input)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to find open pull request")
+		}
+		if pr != nil {
+			if err := updatePullRequest(gitOpsConfig, pr.Number, input); err != nil {
+				return "", errors.Wrap(err, "failed to update pull request")
+			}
+		} else {
+			pr, err = createPullRequest(gitOpsConfig, input)
+			if err != nil {
+				return "", errors.Wrap(err, "failed to create pull request")
+			}
+		}
+
+		if err := recordPullRequest(gitOpsConfig.AppID, gitOpsConfig.ClusterID, int64(newSequence), gitOpsConfig, pushBranch, pr); err != nil {
+			return "", errors.Wrap(err, "failed to record pull request")
+		}
+	}
+
 	return gitOpsConfig.CommitURL(updatedHash.String()), nil
 }
 
@@ -0,0 +1,212 @@
+package gitops
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+	"github.com/replicatedhq/kots/pkg/kustomize"
+)
+
+const (
+	ActionCommit      = "commit"
+	ActionPullRequest = "pullRequest"
+)
+
+// trackedPullRequest is a pull request that kots opened, sequence is the app (parent) sequence of the version
+type trackedPullRequest struct {
+	AppID     string
+	ClusterID string
+	Sequence  int64
+	Number    int
+	Branch    string
+	State     string
+}
+
+// StartPullRequestTracker periodically refreshes the state of the open pull requests
+// so that merges and closes show up in the version history
+func StartPullRequestTracker() error {
+	logger.Debug("starting gitops pull request tracker")
+
+	go func() {
+		for {
+			if err := refreshPullRequests(); err != nil {
+				logger.Error(errors.Wrap(err, "failed to refresh gitops pull requests"))
+			}
+			time.Sleep(time.Minute * 5)
+		}
+	}()
+
+	return nil
+}
+
+func PullRequestBranchName(appSlug string, sequence int) string {
+	return fmt.Sprintf("kots/%s/%d", appSlug, sequence)
+}
+
+func pullRequestBody(appName string, versionLabel string, sequence int, releaseNotes string, diffSummary string) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Updates %s to version %s (sequence %d).\n", appName, versionLabel, sequence))
+
+	if diffSummary != "" {
+		diff := kustomize.Diff{}
+		if err := json.Unmarshal([]byte(diffSummary), &diff); err == nil {
+			sb.WriteString("\n## Changes\n\n")
+			sb.WriteString(fmt.Sprintf("%d files changed, %d lines added, %d lines removed\n", diff.FilesChanged, diff.LinesAdded, diff.LinesRemoved))
+		}
+	}
+
+	if releaseNotes != "" {
+		sb.WriteString("\n## Release notes\n\n")
+		sb.WriteString(releaseNotes)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+func recordPullRequest(appID string, clusterID string, sequence int64, gitOpsConfig *GitOpsConfig, branch string, pr *PullRequest) error {
+	db := persistence.MustGetPGSession()
+	query := `insert into gitops_pull_request (app_id, cluster_id, sequence, provider, repo_uri, branch, number, url, state, created_at, updated_at)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+on conflict (app_id, cluster_id, sequence) do update set
+provider = EXCLUDED.provider, repo_uri = EXCLUDED.repo_uri, branch = EXCLUDED.branch, number = EXCLUDED.number,
+url = EXCLUDED.url, state = EXCLUDED.state, updated_at = EXCLUDED.updated_at`
+	_, err := db.Exec(query, appID, clusterID, sequence, gitOpsConfig.Provider, gitOpsConfig.RepoURI, branch, pr.Number, pr.URL, pr.State, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to insert pull request")
+	}
+
+	return nil
+}
+
+func listOpenPullRequests() ([]trackedPullRequest, error) {
+	db := persistence.MustGetPGSession()
+	query := `select app_id, cluster_id, sequence, number from gitops_pull_request where state = $1`
+	rows, err := db.Query(query, PullRequestStateOpen)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query")
+	}
+	defer rows.Close()
+
+	pullRequests := []trackedPullRequest{}
+	for rows.Next() {
+		pr := trackedPullRequest{}
+		if err := rows.Scan(&pr.AppID, &pr.ClusterID, &pr.Sequence, &pr.Number); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		pullRequests = append(pullRequests, pr)
+	}
+
+	return pullRequests, nil
+}
+
+func setPullRequestState(appID string, clusterID string, sequence int64, state string) error {
+	db := persistence.MustGetPGSession()
+	query := `update gitops_pull_request set state = $4, updated_at = $5 where app_id = $1 and cluster_id = $2 and sequence = $3`
+	_, err := db.Exec(query, appID, clusterID, sequence, state, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to update pull request state")
+	}
+
+	return nil
+}
+
+// getRecordedPullRequestState returns the last known state of the pull request opened for the sequence,
+// or an empty string if there is none
+func getRecordedPullRequestState(appID string, clusterID string, sequence int64) (string, error) {
+	db := persistence.MustGetPGSession()
+	query := `select state from gitops_pull_request where app_id = $1 and cluster_id = $2 and sequence = $3`
+	row := db.QueryRow(query, appID, clusterID, sequence)
+
+	var state sql.NullString
+	if err := row.Scan(&state); err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrap(err, "failed to scan")
+	}
+
+	return state.String, nil
+}
+
+// getRecordedPullRequest returns the pull request opened for the sequence, or nil if there is none
+func getRecordedPullRequest(appID string, clusterID string, sequence int64) (*trackedPullRequest, error) {
+	db := persistence.MustGetPGSession()
+	query := `select number, branch, state from gitops_pull_request where app_id = $1 and cluster_id = $2 and sequence = $3`
+	row := db.QueryRow(query, appID, clusterID, sequence)
+
+	var number sql.NullInt64
+	var branch sql.NullString
+	var state sql.NullString
+	if err := row.Scan(&number, &branch, &state); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+
+	return &trackedPullRequest{
+		AppID:     appID,
+		ClusterID: clusterID,
+		Sequence:  sequence,
+		Number:    int(number.Int64),
+		Branch:    branch.String,
+		State:     state.String,
+	}, nil
+}
+
+// pullRequestBranch returns the branch to push the version to. The branch of an open pull request for the version
+// is reused, a version whose pull request was merged or closed gets a new branch so that a new pull request can be opened.
+func pullRequestBranch(appSlug string, sequence int, recorded *trackedPullRequest, now time.Time) string {
+	branch := PullRequestBranchName(appSlug, sequence)
+	if recorded == nil || recorded.Branch == "" {
+		return branch
+	}
+	if recorded.State == PullRequestStateOpen {
+		return recorded.Branch
+	}
+	return fmt.Sprintf("%s-%d", branch, now.Unix())
+}
+
+func refreshPullRequests() error {
+	pullRequests, err := listOpenPullRequests()
+	if err != nil {
+		return errors.Wrap(err, "failed to list open pull requests")
+	}
+
+	for _, pr := range pullRequests {
+		gitOpsConfig, err := GetDownstreamGitOps(pr.AppID, pr.ClusterID)
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to get gitops config for app %s", pr.AppID))
+			continue
+		}
+		if gitOpsConfig == nil {
+			// gitops was disabled, there is nothing left to track
+			if err := setPullRequestState(pr.AppID, pr.ClusterID, pr.Sequence, PullRequestStateClosed); err != nil {
+				logger.Error(err)
+			}
+			continue
+		}
+
+		state, err := getPullRequestState(gitOpsConfig, pr.Number)
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to get state of pull request %d for app %s", pr.Number, pr.AppID))
+			continue
+		}
+
+		if state == PullRequestStateOpen {
+			continue
+		}
+
+		if err := setPullRequestState(pr.AppID, pr.ClusterID, pr.Sequence, state); err != nil {
+			logger.Error(err)
+		}
+	}
+
+	return nil
+}
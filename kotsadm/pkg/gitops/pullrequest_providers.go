@@ -0,0 +1,402 @@
+package gitops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	PullRequestStateOpen   = "open"
+	PullRequestStateMerged = "merged"
+	PullRequestStateClosed = "closed"
+)
+
+type PullRequest struct {
+	Number int
+	URL    string
+	State  string
+}
+
+type pullRequestInput struct {
+	Title      string
+	Body       string
+	HeadBranch string
+	BaseBranch string
+}
+
+// createPullRequest opens a pull request (or merge request) from the head branch into the base branch
+// using the api of the configured provider
+func createPullRequest(gitOpsConfig *GitOpsConfig, input pullRequestInput) (*PullRequest, error) {
+	owner, repo, err := ownerAndRepo(gitOpsConfig.RepoURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse repo uri")
+	}
+	apiURL, err := providerAPIURL(gitOpsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get provider api url")
+	}
+
+	switch gitOpsConfig.Provider {
+	case "github", "github_enterprise", "gitea":
+		reqBody := map[string]string{
+			"title": input.Title,
+			"body":  input.Body,
+			"head":  input.HeadBranch,
+			"base":  input.BaseBranch,
+		}
+		respBody := struct {
+			Number  int    `json:"number"`
+			HTMLURL string `json:"html_url"`
+		}{}
+		endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls", apiURL, owner, repo)
+		if err := doProviderRequest(gitOpsConfig, "POST", endpoint, reqBody, &respBody); err != nil {
+			return nil, errors.Wrap(err, "failed to create pull request")
+		}
+		return &PullRequest{Number: respBody.Number, URL: respBody.HTMLURL, State: PullRequestStateOpen}, nil
+
+	case "gitlab", "gitlab_enterprise":
+		reqBody := map[string]string{
+			"title":         input.Title,
+			"description":   input.Body,
+			"source_branch": input.HeadBranch,
+			"target_branch": input.BaseBranch,
+		}
+		respBody := struct {
+			IID    int    `json:"iid"`
+			WebURL string `json:"web_url"`
+		}{}
+		endpoint := fmt.Sprintf("%s/projects/%s/merge_requests", apiURL, url.PathEscape(owner+"/"+repo))
+		if err := doProviderRequest(gitOpsConfig, "POST", endpoint, reqBody, &respBody); err != nil {
+			return nil, errors.Wrap(err, "failed to create merge request")
+		}
+		return &PullRequest{Number: respBody.IID, URL: respBody.WebURL, State: PullRequestStateOpen}, nil
+
+	case "bitbucket":
+		reqBody := map[string]interface{}{
+			"title":       input.Title,
+			"description": input.Body,
+			"source": map[string]interface{}{
+				"branch": map[string]string{"name": input.HeadBranch},
+			},
+			"destination": map[string]interface{}{
+				"branch": map[string]string{"name": input.BaseBranch},
+			},
+		}
+		respBody := struct {
+			ID    int `json:"id"`
+			Links struct {
+				HTML struct {
+					Href string `json:"href"`
+				} `json:"html"`
+			} `json:"links"`
+		}{}
+		endpoint := fmt.Sprintf("%s/repositories/%s/%s/pullrequests", apiURL, owner, repo)
+		if err := doProviderRequest(gitOpsConfig, "POST", endpoint, reqBody, &respBody); err != nil {
+			return nil, errors.Wrap(err, "failed to create pull request")
+		}
+		return &PullRequest{Number: respBody.ID, URL: respBody.Links.HTML.Href, State: PullRequestStateOpen}, nil
+	}
+
+	return nil, errors.Errorf("pull requests are not supported for provider %q", gitOpsConfig.Provider)
+}
+
+// findOpenPullRequest returns the open pull request (or merge request) from the head branch into the base branch,
+// or nil if there is none
+func findOpenPullRequest(gitOpsConfig *GitOpsConfig, input pullRequestInput) (*PullRequest, error) {
+	owner, repo, err := ownerAndRepo(gitOpsConfig.RepoURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse repo uri")
+	}
+	apiURL, err := providerAPIURL(gitOpsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get provider api url")
+	}
+
+	switch gitOpsConfig.Provider {
+	case "github", "github_enterprise", "gitea":
+		respBody := []struct {
+			Number  int    `json:"number"`
+			HTMLURL string `json:"html_url"`
+			Head    struct {
+				Ref string `json:"ref"`
+			} `json:"head"`
+			Base struct {
+				Ref string `json:"ref"`
+			} `json:"base"`
+		}{}
+		query := url.Values{}
+		query.Set("state", "open")
+		query.Set("head", fmt.Sprintf("%s:%s", owner, input.HeadBranch))
+		query.Set("base", input.BaseBranch)
+		endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls?%s", apiURL, owner, repo, query.Encode())
+		if err := doProviderRequest(gitOpsConfig, "GET", endpoint, nil, &respBody); err != nil {
+			return nil, errors.Wrap(err, "failed to list pull requests")
+		}
+		// gitea ignores the head and base filters
+		for _, pr := range respBody {
+			if pr.Head.Ref == input.HeadBranch && pr.Base.Ref == input.BaseBranch {
+				return &PullRequest{Number: pr.Number, URL: pr.HTMLURL, State: PullRequestStateOpen}, nil
+			}
+		}
+		return nil, nil
+
+	case "gitlab", "gitlab_enterprise":
+		respBody := []struct {
+			IID    int    `json:"iid"`
+			WebURL string `json:"web_url"`
+		}{}
+		query := url.Values{}
+		query.Set("state", "opened")
+		query.Set("source_branch", input.HeadBranch)
+		query.Set("target_branch", input.BaseBranch)
+		endpoint := fmt.Sprintf("%s/projects/%s/merge_requests?%s", apiURL, url.PathEscape(owner+"/"+repo), query.Encode())
+		if err := doProviderRequest(gitOpsConfig, "GET", endpoint, nil, &respBody); err != nil {
+			return nil, errors.Wrap(err, "failed to list merge requests")
+		}
+		if len(respBody) == 0 {
+			return nil, nil
+		}
+		return &PullRequest{Number: respBody[0].IID, URL: respBody[0].WebURL, State: PullRequestStateOpen}, nil
+
+	case "bitbucket":
+		respBody := struct {
+			Values []struct {
+				ID    int `json:"id"`
+				Links struct {
+					HTML struct {
+						Href string `json:"href"`
+					} `json:"html"`
+				} `json:"links"`
+			} `json:"values"`
+		}{}
+		query := url.Values{}
+		query.Set("state", "OPEN")
+		query.Set("q", fmt.Sprintf(`source.branch.name = "%s" AND destination.branch.name = "%s"`, input.HeadBranch, input.BaseBranch))
+		endpoint := fmt.Sprintf("%s/repositories/%s/%s/pullrequests?%s", apiURL, owner, repo, query.Encode())
+		if err := doProviderRequest(gitOpsConfig, "GET", endpoint, nil, &respBody); err != nil {
+			return nil, errors.Wrap(err, "failed to list pull requests")
+		}
+		if len(respBody.Values) == 0 {
+			return nil, nil
+		}
+		pr := respBody.Values[0]
+		return &PullRequest{Number: pr.ID, URL: pr.Links.HTML.Href, State: PullRequestStateOpen}, nil
+	}
+
+	return nil, errors.Errorf("pull requests are not supported for provider %q", gitOpsConfig.Provider)
+}
+
+// updatePullRequest sets the title and description of an open pull request (or merge request)
+// to describe the version that was pushed to its branch
+func updatePullRequest(gitOpsConfig *GitOpsConfig, number int, input pullRequestInput) error {
+	owner, repo, err := ownerAndRepo(gitOpsConfig.RepoURI)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse repo uri")
+	}
+	apiURL, err := providerAPIURL(gitOpsConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to get provider api url")
+	}
+
+	switch gitOpsConfig.Provider {
+	case "github", "github_enterprise", "gitea":
+		reqBody := map[string]string{
+			"title": input.Title,
+			"body":  input.Body,
+		}
+		endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", apiURL, owner, repo, number)
+		if err := doProviderRequest(gitOpsConfig, "PATCH", endpoint, reqBody, nil); err != nil {
+			return errors.Wrap(err, "failed to update pull request")
+		}
+		return nil
+
+	case "gitlab", "gitlab_enterprise":
+		reqBody := map[string]string{
+			"title":       input.Title,
+			"description": input.Body,
+		}
+		endpoint := fmt.Sprintf("%s/projects/%s/merge_requests/%d", apiURL, url.PathEscape(owner+"/"+repo), number)
+		if err := doProviderRequest(gitOpsConfig, "PUT", endpoint, reqBody, nil); err != nil {
+			return errors.Wrap(err, "failed to update merge request")
+		}
+		return nil
+
+	case "bitbucket":
+		reqBody := map[string]string{
+			"title":       input.Title,
+			"description": input.Body,
+		}
+		endpoint := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d", apiURL, owner, repo, number)
+		if err := doProviderRequest(gitOpsConfig, "PUT", endpoint, reqBody, nil); err != nil {
+			return errors.Wrap(err, "failed to update pull request")
+		}
+		return nil
+	}
+
+	return errors.Errorf("pull requests are not supported for provider %q", gitOpsConfig.Provider)
+}
+
+// getPullRequestState returns the current state of a pull request as one of open, merged or closed
+func getPullRequestState(gitOpsConfig *GitOpsConfig, number int) (string, error) {
+	owner, repo, err := ownerAndRepo(gitOpsConfig.RepoURI)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse repo uri")
+	}
+	apiURL, err := providerAPIURL(gitOpsConfig)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get provider api url")
+	}
+
+	switch gitOpsConfig.Provider {
+	case "github", "github_enterprise", "gitea":
+		respBody := struct {
+			State  string `json:"state"`
+			Merged bool   `json:"merged"`
+		}{}
+		endpoint := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", apiURL, owner, repo, number)
+		if err := doProviderRequest(gitOpsConfig, "GET", endpoint, nil, &respBody); err != nil {
+			return "", errors.Wrap(err, "failed to get pull request")
+		}
+		if respBody.Merged {
+			return PullRequestStateMerged, nil
+		}
+		return normalizePullRequestState(respBody.State), nil
+
+	case "gitlab", "gitlab_enterprise":
+		respBody := struct {
+			State string `json:"state"`
+		}{}
+		endpoint := fmt.Sprintf("%s/projects/%s/merge_requests/%d", apiURL, url.PathEscape(owner+"/"+repo), number)
+		if err := doProviderRequest(gitOpsConfig, "GET", endpoint, nil, &respBody); err != nil {
+			return "", errors.Wrap(err, "failed to get merge request")
+		}
+		return normalizePullRequestState(respBody.State), nil
+
+	case "bitbucket":
+		respBody := struct {
+			State string `json:"state"`
+		}{}
+		endpoint := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d", apiURL, owner, repo, number)
+		if err := doProviderRequest(gitOpsConfig, "GET", endpoint, nil, &respBody); err != nil {
+			return "", errors.Wrap(err, "failed to get pull request")
+		}
+		return normalizePullRequestState(respBody.State), nil
+	}
+
+	return "", errors.Errorf("pull requests are not supported for provider %q", gitOpsConfig.Provider)
+}
+
+func normalizePullRequestState(state string) string {
+	switch strings.ToLower(state) {
+	case "open", "opened", "locked":
+		return PullRequestStateOpen
+	case "merged":
+		return PullRequestStateMerged
+	default:
+		// closed, declined, superseded
+		return PullRequestStateClosed
+	}
+}
+
+func providerAPIURL(gitOpsConfig *GitOpsConfig) (string, error) {
+	u, err := url.Parse(gitOpsConfig.RepoURI)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse repo uri")
+	}
+	scheme := u.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+
+	switch gitOpsConfig.Provider {
+	case "github":
+		return "https://api.github.com", nil
+	case "github_enterprise":
+		return fmt.Sprintf("%s://%s/api/v3", scheme, u.Host), nil
+	case "gitlab":
+		return "https://gitlab.com/api/v4", nil
+	case "gitlab_enterprise":
+		return fmt.Sprintf("%s://%s/api/v4", scheme, u.Host), nil
+	case "bitbucket":
+		return "https://api.bitbucket.org/2.0", nil
+	case "gitea":
+		return fmt.Sprintf("%s://%s/api/v1", scheme, u.Host), nil
+	}
+
+	return "", errors.Errorf("unsupported provider %q", gitOpsConfig.Provider)
+}
+
+func ownerAndRepo(repoURI string) (string, string, error) {
+	uriParts := strings.Split(strings.TrimSuffix(repoURI, "/"), "/")
+	if len(uriParts) < 5 {
+		return "", "", errors.Errorf("expected repo uri in the form https://host/owner/repo, got %q", repoURI)
+	}
+	return uriParts[3], strings.TrimSuffix(uriParts[4], ".git"), nil
+}
+
+func doProviderRequest(gitOpsConfig *GitOpsConfig, method string, endpoint string, reqBody interface{}, respBody interface{}) error {
+	if gitOpsConfig.APIToken == "" {
+		return errors.New("an api token is required to manage pull requests")
+	}
+
+	var body *bytes.Buffer
+	if reqBody != nil {
+		b, err := json.Marshal(reqBody)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal request")
+		}
+		body = bytes.NewBuffer(b)
+	} else {
+		body = bytes.NewBuffer(nil)
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	switch gitOpsConfig.Provider {
+	case "gitlab", "gitlab_enterprise":
+		req.Header.Set("PRIVATE-TOKEN", gitOpsConfig.APIToken)
+	case "bitbucket":
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", gitOpsConfig.APIToken))
+	default:
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", gitOpsConfig.APIToken))
+	}
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status code %d: %s", resp.StatusCode, string(respBytes))
+	}
+
+	if respBody != nil {
+		if err := json.Unmarshal(respBytes, respBody); err != nil {
+			return errors.Wrap(err, "failed to unmarshal response")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package gitops
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_providerAPIURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   GitOpsConfig
+		expected string
+	}{
+		{
+			name:     "github",
+			config:   GitOpsConfig{Provider: "github", RepoURI: "https://github.com/org/repo"},
+			expected: "https://api.github.com",
+		},
+		{
+			name:     "github enterprise",
+			config:   GitOpsConfig{Provider: "github_enterprise", RepoURI: "https://github.example.com/org/repo"},
+			expected: "https://github.example.com/api/v3",
+		},
+		{
+			name:     "gitlab enterprise",
+			config:   GitOpsConfig{Provider: "gitlab_enterprise", RepoURI: "https://gitlab.example.com/org/repo"},
+			expected: "https://gitlab.example.com/api/v4",
+		},
+		{
+			name:     "bitbucket",
+			config:   GitOpsConfig{Provider: "bitbucket", RepoURI: "https://bitbucket.org/org/repo"},
+			expected: "https://api.bitbucket.org/2.0",
+		},
+		{
+			name:     "gitea",
+			config:   GitOpsConfig{Provider: "gitea", RepoURI: "http://gitea.internal:3000/org/repo"},
+			expected: "http://gitea.internal:3000/api/v1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := providerAPIURL(&test.config)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func Test_normalizePullRequestState(t *testing.T) {
+	assert.Equal(t, PullRequestStateOpen, normalizePullRequestState("opened"))
+	assert.Equal(t, PullRequestStateOpen, normalizePullRequestState("OPEN"))
+	assert.Equal(t, PullRequestStateMerged, normalizePullRequestState("MERGED"))
+	assert.Equal(t, PullRequestStateClosed, normalizePullRequestState("DECLINED"))
+	assert.Equal(t, PullRequestStateClosed, normalizePullRequestState("closed"))
+}
+
+func Test_pullRequestBody(t *testing.T) {
+	body := pullRequestBody("My App", "1.2.0", 7, "Fixed a bug", `{"filesChanged":2,"linesAdded":10,"linesRemoved":3}`)
+
+	assert.Contains(t, body, "Updates My App to version 1.2.0 (sequence 7).")
+	assert.Contains(t, body, "2 files changed, 10 lines added, 3 lines removed")
+	assert.Contains(t, body, "## Release notes\n\nFixed a bug")
+
+	body = pullRequestBody("My App", "1.2.0", 7, "", "")
+	assert.NotContains(t, body, "## Changes")
+	assert.NotContains(t, body, "## Release notes")
+}
+
+func Test_createPullRequest(t *testing.T) {
+	req := require.New(t)
+
+	// the handler runs in the server's goroutine, where require can't stop the test
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api/v1/repos/org/repo/pulls", r.URL.Path)
+		assert.Equal(t, "token abc123", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number": 12, "html_url": "https://gitea.internal/org/repo/pulls/12"}`))
+	}))
+	defer server.Close()
+
+	gitOpsConfig := &GitOpsConfig{
+		Provider: "gitea",
+		RepoURI:  server.URL + "/org/repo",
+		APIToken: "abc123",
+	}
+
+	pr, err := createPullRequest(gitOpsConfig, pullRequestInput{
+		Title:      "Update my-app",
+		Body:       "body",
+		HeadBranch: PullRequestBranchName("my-app", 3),
+		BaseBranch: "main",
+	})
+	req.NoError(err)
+
+	assert.Equal(t, 12, pr.Number)
+	assert.Equal(t, "https://gitea.internal/org/repo/pulls/12", pr.URL)
+	assert.Equal(t, PullRequestStateOpen, pr.State)
+	assert.Equal(t, "kots/my-app/3", received["head"])
+	assert.Equal(t, "main", received["base"])
+}
+
+func Test_findOpenPullRequest(t *testing.T) {
+	req := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/api/v1/repos/org/repo/pulls", r.URL.Path)
+		assert.Equal(t, "open", r.URL.Query().Get("state"))
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[
+			{"number": 3, "html_url": "https://gitea.internal/org/repo/pulls/3", "head": {"ref": "feature"}, "base": {"ref": "main"}},
+			{"number": 5, "html_url": "https://gitea.internal/org/repo/pulls/5", "head": {"ref": "kots/my-app/3"}, "base": {"ref": "main"}}
+		]`))
+	}))
+	defer server.Close()
+
+	gitOpsConfig := &GitOpsConfig{
+		Provider: "gitea",
+		RepoURI:  server.URL + "/org/repo",
+		APIToken: "abc123",
+	}
+
+	pr, err := findOpenPullRequest(gitOpsConfig, pullRequestInput{HeadBranch: "kots/my-app/3", BaseBranch: "main"})
+	req.NoError(err)
+	req.NotNil(pr)
+	assert.Equal(t, 5, pr.Number)
+	assert.Equal(t, "https://gitea.internal/org/repo/pulls/5", pr.URL)
+
+	pr, err = findOpenPullRequest(gitOpsConfig, pullRequestInput{HeadBranch: "kots/my-app/4", BaseBranch: "main"})
+	req.NoError(err)
+	assert.Nil(t, pr)
+}
+
+func Test_updatePullRequest(t *testing.T) {
+	req := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		assert.Equal(t, "/api/v4/projects/org%2Frepo/merge_requests/5", r.URL.EscapedPath())
+
+		body := map[string]string{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "Update My App to version 1.0.1", body["title"])
+		assert.Equal(t, "release notes", body["description"])
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"iid": 5}`))
+	}))
+	defer server.Close()
+
+	gitOpsConfig := &GitOpsConfig{
+		Provider: "gitlab_enterprise",
+		RepoURI:  server.URL + "/org/repo",
+		APIToken: "abc123",
+	}
+
+	err := updatePullRequest(gitOpsConfig, 5, pullRequestInput{Title: "Update My App to version 1.0.1", Body: "release notes"})
+	req.NoError(err)
+}
+
+func Test_pullRequestBranch(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	tests := []struct {
+		name     string
+		recorded *trackedPullRequest
+		expected string
+	}{
+		{
+			name:     "no pull request yet",
+			recorded: nil,
+			expected: "kots/my-app/3",
+		},
+		{
+			name:     "open pull request",
+			recorded: &trackedPullRequest{Branch: "kots/my-app/3", State: PullRequestStateOpen},
+			expected: "kots/my-app/3",
+		},
+		{
+			name:     "open pull request on a suffixed branch",
+			recorded: &trackedPullRequest{Branch: "kots/my-app/3-1500000000", State: PullRequestStateOpen},
+			expected: "kots/my-app/3-1500000000",
+		},
+		{
+			name:     "merged pull request",
+			recorded: &trackedPullRequest{Branch: "kots/my-app/3", State: PullRequestStateMerged},
+			expected: "kots/my-app/3-1600000000",
+		},
+		{
+			name:     "closed pull request",
+			recorded: &trackedPullRequest{Branch: "kots/my-app/3", State: PullRequestStateClosed},
+			expected: "kots/my-app/3-1600000000",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, pullRequestBranch("my-app", 3, test.recorded, now))
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package gitops
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+)
+
+const (
+	// SyncStatusSynced means the branch contains the output kots committed for the sequence
+	SyncStatusSynced = "synced"
+	// SyncStatusPending means the output is waiting for a pull request to be merged
+	SyncStatusPending = "pending"
+	// SyncStatusDrifted means the files in the branch were edited, reverted or overwritten
+	SyncStatusDrifted = "drifted"
+	// SyncStatusUnknown means the branch could not be checked
+	SyncStatusUnknown = "unknown"
+)
+
+// SyncState is what kots last committed for a downstream and whether the branch still matches it
+type SyncState struct {
+	AppID     string `json:"-"`
+	ClusterID string `json:"-"`
+	AppSlug   string `json:"-"`
+	Sequence  int64  `json:"sequence"`
+	// PreviousSequence is the sequence committed before Sequence, if any
+	PreviousSequence *int64            `json:"previousSequence,omitempty"`
+	Branch           string            `json:"branch"`
+	CommitSHA        string            `json:"commitSha"`
+	ExpectedFiles    map[string]string `json:"-"` // sha256 by path relative to the gitops path
+	Status           string            `json:"status"`
+	DriftedFiles     []string          `json:"driftedFiles"`
+	LastError        string            `json:"lastError,omitempty"`
+	UpdatedAt        *time.Time        `json:"updatedAt"`
+	LastCheckedAt    *time.Time        `json:"lastCheckedAt"`
+}
+
+func fileHashes(files map[string][]byte) map[string]string {
+	hashes := map[string]string{}
+	for filename, content := range files {
+		sum := sha256.Sum256(content)
+		hashes[filename] = hex.EncodeToString(sum[:])
+	}
+	return hashes
+}
+
+// recordSyncState stores the files committed for the sequence so that the branch can be checked for drift later
+func recordSyncState(gitOpsConfig *GitOpsConfig, appSlug string, sequence int64, commitSHA string, files map[string][]byte, status string) error {
+	expectedFiles, err := json.Marshal(fileHashes(files))
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal expected files")
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `insert into gitops_sync (app_id, cluster_id, app_slug, sequence, branch, commit_sha, expected_files, status, drifted_files, last_error, updated_at)
+values ($1, $2, $3, $4, $5, $6, $7, $8, '[]', '', $9)
+on conflict (app_id, cluster_id) do update set
+previous_sequence = case when gitops_sync.sequence = EXCLUDED.sequence then gitops_sync.previous_sequence else gitops_sync.sequence end,
+app_slug = EXCLUDED.app_slug, sequence = EXCLUDED.sequence, branch = EXCLUDED.branch, commit_sha = EXCLUDED.commit_sha,
+expected_files = EXCLUDED.expected_files, status = EXCLUDED.status, drifted_files = EXCLUDED.drifted_files,
+last_error = EXCLUDED.last_error, updated_at = EXCLUDED.updated_at`
+	_, err = db.Exec(query, gitOpsConfig.AppID, gitOpsConfig.ClusterID, appSlug, sequence, gitOpsConfig.Branch, commitSHA, string(expectedFiles), status, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to insert sync state")
+	}
+
+	return nil
+}
+
+func ListSyncStates() ([]SyncState, error) {
+	db := persistence.MustGetPGSession()
+	query := `select app_id, cluster_id, app_slug, sequence, previous_sequence, branch, commit_sha, expected_files, status, drifted_files, last_error, updated_at, last_checked_at from gitops_sync`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query")
+	}
+	defer rows.Close()
+
+	states := []SyncState{}
+	for rows.Next() {
+		state, err := scanSyncState(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		states = append(states, *state)
+	}
+
+	return states, nil
+}
+
+// GetSyncState returns the sync state of the downstream, or nil if kots has not committed anything for it
+func GetSyncState(appID string, clusterID string) (*SyncState, error) {
+	db := persistence.MustGetPGSession()
+	query := `select app_id, cluster_id, app_slug, sequence, previous_sequence, branch, commit_sha, expected_files, status, drifted_files, last_error, updated_at, last_checked_at from gitops_sync
+where app_id = $1 and cluster_id = $2`
+	row := db.QueryRow(query, appID, clusterID)
+
+	state, err := scanSyncState(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+
+	return state, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSyncState(row scanner) (*SyncState, error) {
+	state := SyncState{}
+
+	var appSlug, branch, commitSHA, expectedFiles, status, driftedFiles, lastError sql.NullString
+	var sequence, previousSequence sql.NullInt64
+	var updatedAt, lastCheckedAt sql.NullTime
+	if err := row.Scan(&state.AppID, &state.ClusterID, &appSlug, &sequence, &previousSequence, &branch, &commitSHA, &expectedFiles, &status, &driftedFiles, &lastError, &updatedAt, &lastCheckedAt); err != nil {
+		return nil, err
+	}
+
+	state.AppSlug = appSlug.String
+	state.Sequence = sequence.Int64
+	if previousSequence.Valid {
+		state.PreviousSequence = &previousSequence.Int64
+	}
+	state.Branch = branch.String
+	state.CommitSHA = commitSHA.String
+	state.Status = status.String
+	state.LastError = lastError.String
+	if updatedAt.Valid {
+		state.UpdatedAt = &updatedAt.Time
+	}
+	if lastCheckedAt.Valid {
+		state.LastCheckedAt = &lastCheckedAt.Time
+	}
+
+	state.ExpectedFiles = map[string]string{}
+	if expectedFiles.String != "" {
+		if err := json.Unmarshal([]byte(expectedFiles.String), &state.ExpectedFiles); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal expected files")
+		}
+	}
+
+	state.DriftedFiles = []string{}
+	if driftedFiles.String != "" {
+		if err := json.Unmarshal([]byte(driftedFiles.String), &state.DriftedFiles); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal drifted files")
+		}
+	}
+
+	return &state, nil
+}
+
+func SetSyncStatus(appID string, clusterID string, status string, driftedFiles []string, lastError string) error {
+	if driftedFiles == nil {
+		driftedFiles = []string{}
+	}
+	marshalledDriftedFiles, err := json.Marshal(driftedFiles)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal drifted files")
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update gitops_sync set status = $3, drifted_files = $4, last_error = $5, last_checked_at = $6 where app_id = $1 and cluster_id = $2`
+	_, err = db.Exec(query, appID, clusterID, status, string(marshalledDriftedFiles), lastError, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to update sync status")
+	}
+
+	return nil
+}
+
+func DeleteSyncState(appID string, clusterID string) error {
+	db := persistence.MustGetPGSession()
+	query := `delete from gitops_sync where app_id = $1 and cluster_id = $2`
+	_, err := db.Exec(query, appID, clusterID)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete sync state")
+	}
+
+	return nil
+}
+
+// CheckSync fetches the branch and compares the files kots owns with the files it committed for the sequence.
+// It returns the sync status and the files that drifted.
+func CheckSync(gitOpsConfig *GitOpsConfig, state SyncState) (string, []string, error) {
+	auth, err := getAuth(gitOpsConfig)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to get auth")
+	}
+
+	workDir, err := ioutil.TempDir("", "kotsadm")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(workDir)
+
+	cloneOptions := &git.CloneOptions{
+		RemoteName: git.DefaultRemoteName,
+		URL:        gitOpsConfig.CloneURL(),
+		Auth:       auth,
+	}
+	if _, _, err := CloneAndCheckout(workDir, cloneOptions, gitOpsConfig.Branch); err != nil {
+		return "", nil, err
+	}
+
+	driftedFiles, err := compareGitOpsFiles(filepath.Join(workDir, gitOpsConfig.Path), state.AppSlug, state.ExpectedFiles)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to compare files")
+	}
+
+	if len(driftedFiles) == 0 {
+		return SyncStatusSynced, driftedFiles, nil
+	}
+
+	if gitOpsConfig.Action == ActionPullRequest {
+		prState, err := getRecordedPullRequestState(state.AppID, state.ClusterID, state.Sequence)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "failed to get pull request state")
+		}
+		if prState == PullRequestStateOpen {
+			return SyncStatusPending, driftedFiles, nil
+		}
+	}
+
+	return SyncStatusDrifted, driftedFiles, nil
+}
+
+// compareGitOpsFiles returns the files kots owns in dirPath that are missing, changed or were not committed by kots
+func compareGitOpsFiles(dirPath string, appSlug string, expectedFiles map[string]string) ([]string, error) {
+	actualFiles := map[string][]byte{}
+
+	appYAML := fmt.Sprintf("%s.yaml", appSlug)
+	content, err := ioutil.ReadFile(filepath.Join(dirPath, appYAML))
+	if err == nil {
+		actualFiles[appYAML] = content
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to read %s", appYAML)
+	}
+
+	appDir := filepath.Join(dirPath, appSlug)
+	err = filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == appDir {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+		relPath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return errors.Wrap(err, "failed to get relative path")
+		}
+		actualFiles[filepath.ToSlash(relPath)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk app directory")
+	}
+
+	driftedFiles := []string{}
+	actualHashes := fileHashes(actualFiles)
+	for filename, expectedHash := range expectedFiles {
+		if actualHashes[filename] != expectedHash {
+			driftedFiles = append(driftedFiles, filename)
+		}
+	}
+	for filename := range actualHashes {
+		if _, ok := expectedFiles[filename]; !ok {
+			driftedFiles = append(driftedFiles, filename)
+		}
+	}
+
+	sort.Strings(driftedFiles)
+	return driftedFiles, nil
+}
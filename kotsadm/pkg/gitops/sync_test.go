@@ -0,0 +1,64 @@
+package gitops
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_compareGitOpsFiles(t *testing.T) {
+	req := require.New(t)
+
+	committed := map[string][]byte{
+		"my-app/deployment/web.yaml": []byte("kind: Deployment\n"),
+		"my-app/service/web.yaml":    []byte("kind: Service\n"),
+		"my-app/secret/db.yaml":      []byte("kind: Secret\n"),
+	}
+	expectedFiles := fileHashes(committed)
+
+	dirPath, err := ioutil.TempDir("", "kotsadm")
+	req.NoError(err)
+	defer os.RemoveAll(dirPath)
+
+	_, err = writeGitOpsFiles(dirPath, "my-app", committed)
+	req.NoError(err)
+
+	// files that are not owned by kots are ignored
+	req.NoError(ioutil.WriteFile(filepath.Join(dirPath, "other-app.yaml"), []byte("other"), 0644))
+
+	driftedFiles, err := compareGitOpsFiles(dirPath, "my-app", expectedFiles)
+	req.NoError(err)
+	assert.Empty(t, driftedFiles)
+
+	// a manual edit, a removed file and a file added next to the kots output
+	req.NoError(ioutil.WriteFile(filepath.Join(dirPath, "my-app", "deployment", "web.yaml"), []byte("kind: Deployment\nreplicas: 3\n"), 0644))
+	req.NoError(os.Remove(filepath.Join(dirPath, "my-app", "secret", "db.yaml")))
+	req.NoError(ioutil.WriteFile(filepath.Join(dirPath, "my-app", "service", "extra.yaml"), []byte("kind: Service\n"), 0644))
+
+	driftedFiles, err = compareGitOpsFiles(dirPath, "my-app", expectedFiles)
+	req.NoError(err)
+	assert.Equal(t, []string{
+		"my-app/deployment/web.yaml",
+		"my-app/secret/db.yaml",
+		"my-app/service/extra.yaml",
+	}, driftedFiles)
+}
+
+func Test_compareGitOpsFilesReverted(t *testing.T) {
+	req := require.New(t)
+
+	dirPath, err := ioutil.TempDir("", "kotsadm")
+	req.NoError(err)
+	defer os.RemoveAll(dirPath)
+
+	// the commit was reverted, so nothing kots owns is left in the branch
+	driftedFiles, err := compareGitOpsFiles(dirPath, "my-app", fileHashes(map[string][]byte{
+		"my-app.yaml": []byte("kind: Service\n"),
+	}))
+	req.NoError(err)
+	assert.Equal(t, []string{"my-app.yaml"}, driftedFiles)
+}
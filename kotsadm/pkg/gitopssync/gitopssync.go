@@ -0,0 +1,175 @@
+package gitopssync
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/gitops"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+)
+
+// Start periodically checks that the gitops branches still contain what kots committed,
+// and pushes the output again when drift is found and the downstream is set to auto re-push
+func Start() error {
+	logger.Debug("starting gitops sync reconciler")
+
+	go func() {
+		for {
+			reconcile()
+			time.Sleep(time.Minute * 5)
+		}
+	}()
+
+	return nil
+}
+
+func reconcile() {
+	states, err := gitops.ListSyncStates()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to list gitops sync states"))
+		return
+	}
+
+	for _, state := range states {
+		if err := reconcileDownstream(state); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to reconcile gitops for app %s", state.AppID))
+		}
+	}
+}
+
+func reconcileDownstream(state gitops.SyncState) error {
+	gitOpsConfig, err := gitops.GetDownstreamGitOps(state.AppID, state.ClusterID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get downstream gitops")
+	}
+	if gitOpsConfig == nil {
+		// gitops was disabled, there is nothing left to check
+		return gitops.DeleteSyncState(state.AppID, state.ClusterID)
+	}
+
+	if gitOpsConfig.Branch != state.Branch {
+		// the branch changed, nothing was committed to the new one yet
+		return gitops.SetSyncStatus(state.AppID, state.ClusterID, gitops.SyncStatusUnknown, nil, "branch changed since the last commit")
+	}
+
+	status, driftedFiles, err := gitops.CheckSync(gitOpsConfig, state)
+	if err != nil {
+		if err := gitops.SetSyncStatus(state.AppID, state.ClusterID, gitops.SyncStatusUnknown, nil, err.Error()); err != nil {
+			logger.Error(err)
+		}
+		return errors.Wrap(err, "failed to check sync")
+	}
+
+	if status == gitops.SyncStatusDrifted && gitOpsConfig.AutoRepush {
+		logger.Infof("gitops drift detected for app %s in %d files, pushing sequence %d again", state.AppID, len(driftedFiles), state.Sequence)
+		err := Repush(state.AppID, state.ClusterID)
+		if err == nil {
+			return nil
+		}
+		logger.Error(errors.Wrap(err, "failed to re-push"))
+	}
+
+	return gitops.SetSyncStatus(state.AppID, state.ClusterID, status, driftedFiles, "")
+}
+
+// Repush commits the output of the last sequence kots committed for the downstream again,
+// overwriting any changes made to the files kots owns. Encrypted secrets that were not edited keep
+// their ciphertext, so nothing is committed when the branch already has the rendered output.
+func Repush(appID string, clusterID string) error {
+	state, err := gitops.GetSyncState(appID, clusterID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get sync state")
+	}
+	if state == nil {
+		return errors.New("nothing has been committed for this downstream")
+	}
+
+	gitOpsConfig, err := gitops.GetDownstreamGitOps(appID, clusterID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get downstream gitops")
+	}
+	if gitOpsConfig == nil {
+		return errors.New("gitops is not enabled for this downstream")
+	}
+
+	a, err := store.GetStore().GetApp(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app")
+	}
+
+	downstreams, err := store.GetStore().ListDownstreamsForApp(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list downstreams")
+	}
+	downstreamName := ""
+	for _, d := range downstreams {
+		if d.ClusterID == clusterID {
+			downstreamName = d.Name
+			break
+		}
+	}
+	if downstreamName == "" {
+		return errors.Errorf("downstream for cluster %s not found", clusterID)
+	}
+
+	archiveDir, err := ioutil.TempDir("", "kotsadm")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(archiveDir)
+
+	if err := store.GetStore().GetAppVersionArchive(appID, state.Sequence, archiveDir); err != nil {
+		return errors.Wrapf(err, "failed to get app version archive for sequence %d", state.Sequence)
+	}
+
+	previousArchiveDir, err := GetPreviousVersionArchive(appID, clusterID, state.Sequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to get previous version archive")
+	}
+	defer os.RemoveAll(previousArchiveDir)
+
+	if _, err := gitops.CreateGitOpsCommit(gitOpsConfig, a.Slug, a.Name, int(state.Sequence), archiveDir, previousArchiveDir, downstreamName, ""); err != nil {
+		return errors.Wrap(err, "failed to create gitops commit")
+	}
+
+	return nil
+}
+
+// GetPreviousVersionArchive extracts the archive of the last version committed to the downstream's repo before the sequence,
+// so that the commit message can describe the config changes from what is in git.
+// The dir is empty when nothing was committed before the sequence, otherwise the caller has to remove it.
+func GetPreviousVersionArchive(appID string, clusterID string, sequence int64) (string, error) {
+	state, err := gitops.GetSyncState(appID, clusterID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get sync state")
+	}
+	if state == nil {
+		return "", nil
+	}
+
+	var previousSequence *int64
+	if state.Sequence < sequence {
+		previousSequence = &state.Sequence
+	} else if state.Sequence == sequence && state.PreviousSequence != nil && *state.PreviousSequence < sequence {
+		// the sequence is being committed again
+		previousSequence = state.PreviousSequence
+	}
+	if previousSequence == nil {
+		return "", nil
+	}
+
+	previousArchiveDir, err := ioutil.TempDir("", "kotsadm")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temp dir")
+	}
+
+	if err := store.GetStore().GetAppVersionArchive(appID, *previousSequence, previousArchiveDir); err != nil {
+		os.RemoveAll(previousArchiveDir)
+		return "", errors.Wrapf(err, "failed to get app version archive for sequence %d", *previousSequence)
+	}
+
+	return previousArchiveDir, nil
+}
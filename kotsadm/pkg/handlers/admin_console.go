@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/kurl"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	kotsadmtypes "github.com/replicatedhq/kots/pkg/kotsadm/types"
+	"github.com/replicatedhq/kots/pkg/version"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+type UpgradeAdminConsoleRequest struct {
+	Version string `json:"version"`
+}
+
+type UpgradeAdminConsoleResponse struct {
+	Success        bool   `json:"success"`
+	CurrentVersion string `json:"currentVersion"`
+	Version        string `json:"version,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// UpgradeAdminConsole is the API equivalent of "kubectl kots admin-console upgrade".
+// It is used when a release requires a newer admin console than the one running.
+func (h *Handler) UpgradeAdminConsole(w http.ResponseWriter, r *http.Request) {
+	upgradeAdminConsoleResponse := UpgradeAdminConsoleResponse{
+		Success:        false,
+		CurrentVersion: version.Version(),
+	}
+
+	upgradeAdminConsoleRequest := UpgradeAdminConsoleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&upgradeAdminConsoleRequest); err != nil {
+		logger.Error(err)
+		upgradeAdminConsoleResponse.Error = "failed to decode request body"
+		JSON(w, http.StatusBadRequest, upgradeAdminConsoleResponse)
+		return
+	}
+
+	if upgradeAdminConsoleRequest.Version != "" {
+		if _, err := semver.NewVersion(upgradeAdminConsoleRequest.Version); err != nil {
+			upgradeAdminConsoleResponse.Error = "version must be a valid semantic version"
+			JSON(w, http.StatusBadRequest, upgradeAdminConsoleResponse)
+			return
+		}
+	}
+
+	if disableOutboundConnections, _ := strconv.ParseBool(os.Getenv("DISABLE_OUTBOUND_CONNECTIONS")); disableOutboundConnections {
+		upgradeAdminConsoleResponse.Error = "the admin console cannot be upgraded from the UI in airgapped installations, run \"kubectl kots admin-console upgrade\" instead"
+		JSON(w, http.StatusBadRequest, upgradeAdminConsoleResponse)
+		return
+	}
+
+	if kurl.IsKurl() {
+		upgradeAdminConsoleResponse.Error = "the admin console is managed by kURL in this cluster, re-run the kURL installer to upgrade it"
+		JSON(w, http.StatusBadRequest, upgradeAdminConsoleResponse)
+		return
+	}
+
+	namespace := os.Getenv("POD_NAMESPACE")
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to get cluster config"))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to create kubernetes clientset"))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// keep the registry settings the admin console was installed with
+	kotsadmOptions, err := kotsadm.GetKotsadmOptionsFromCluster(namespace, clientset)
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to get kotsadm options from cluster"))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	kotsadmOptions.OverrideVersion = upgradeAdminConsoleRequest.Version
+
+	upgradeOptions := kotsadmtypes.UpgradeOptions{
+		Namespace:             namespace,
+		KubernetesConfigFlags: genericclioptions.NewConfigFlags(false),
+		Timeout:               5 * time.Minute,
+		KotsadmOptions:        kotsadmOptions,
+	}
+
+	// this pod will be replaced as part of the upgrade, so the response is sent before it starts
+	go func() {
+		if err := kotsadm.Upgrade(upgradeOptions); err != nil {
+			logger.Error(errors.Wrap(err, "failed to upgrade admin console"))
+		}
+	}()
+
+	upgradeAdminConsoleResponse.Success = true
+	upgradeAdminConsoleResponse.Version = upgradeAdminConsoleRequest.Version
+
+	JSON(w, http.StatusAccepted, upgradeAdminConsoleResponse)
+}
@@ -357,7 +357,7 @@ func (h *Handler) CreateAppFromAirgap(w http.ResponseWriter, r *http.Request) {
 	}
 
 	go func() {
-		if err := airgap.CreateAppFromAirgap(pendingApp, airgapBundlePath, registryHost, namespace, username, password, false, false); err != nil {
+		if err := airgap.CreateAppFromAirgap(pendingApp, airgapBundlePath, registryHost, namespace, username, password, false, false, false); err != nil {
 			logger.Error(errors.Wrap(err, "failed to create app from airgap bundle"))
 			return
 		}
@@ -583,7 +583,7 @@ func bc_createAppFromAirgap(w http.ResponseWriter, r *http.Request) {
 
 	go func() {
 		defer os.RemoveAll(airgapBundlePath)
-		if err := airgap.CreateAppFromAirgap(pendingApp, airgapBundlePath, registryHost, namespace, username, password, false, false); err != nil {
+		if err := airgap.CreateAppFromAirgap(pendingApp, airgapBundlePath, registryHost, namespace, username, password, false, false, false); err != nil {
 			logger.Error(err)
 		}
 	}()
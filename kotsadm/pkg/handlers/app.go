@@ -178,6 +178,22 @@ func responseAppFromApp(a *apptypes.App) (*types.ResponseApp, error) {
 				Action:      downstreamGitOps.Action,
 				DeployKey:   downstreamGitOps.PublicKey,
 				IsConnected: downstreamGitOps.IsConnected,
+				AutoRepush:  downstreamGitOps.AutoRepush,
+			}
+
+			syncState, err := gitops.GetSyncState(a.ID, d.ClusterID)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get gitops sync state")
+			}
+			if syncState != nil {
+				responseGitOps.Sync = &types.ResponseGitOpsSync{
+					Sequence:      syncState.Sequence,
+					CommitSHA:     syncState.CommitSHA,
+					Status:        syncState.Status,
+					DriftedFiles:  syncState.DriftedFiles,
+					LastError:     syncState.LastError,
+					LastCheckedAt: syncState.LastCheckedAt,
+				}
 			}
 		}
 
@@ -186,6 +202,27 @@ func responseAppFromApp(a *apptypes.App) (*types.ResponseApp, error) {
 			Slug: d.ClusterSlug,
 		}
 
+		var responsePreflightCheck *types.ResponsePreflightCheck
+		checkRun, err := store.GetStore().GetLatestPreflightCheckRun(a.ID, d.ClusterID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get latest preflight check run")
+		}
+		if checkRun != nil {
+			responsePreflightCheck = &types.ResponsePreflightCheck{
+				Sequence:    checkRun.Sequence,
+				State:       checkRun.State,
+				Changes:     []types.ResponsePreflightCheckChange{},
+				CompletedAt: checkRun.CompletedAt,
+			}
+			for _, change := range checkRun.Changes {
+				responsePreflightCheck.Changes = append(responsePreflightCheck.Changes, types.ResponsePreflightCheckChange{
+					Title: change.Title,
+					From:  change.From,
+					To:    change.To,
+				})
+			}
+		}
+
 		responseDownstream := types.ResponseDownstream{
 			Name:            d.Name,
 			Links:           links,
@@ -194,6 +231,7 @@ func responseAppFromApp(a *apptypes.App) (*types.ResponseApp, error) {
 			PastVersions:    pastVersions,
 			GitOps:          responseGitOps,
 			Cluster:         cluster,
+			PreflightCheck:  responsePreflightCheck,
 		}
 
 		responseDownstreams = append(responseDownstreams, responseDownstream)
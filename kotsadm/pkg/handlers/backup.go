@@ -82,6 +82,9 @@ func (h *Handler) ListBackups(w http.ResponseWriter, r *http.Request) {
 		JSON(w, 500, listBackupsResponse)
 		return
 	}
+	addBackupVerifications(backups)
+	// sets why each scheduled backup is kept
+	snapshot.ApplyRetentionPolicy(backups, foundApp.SnapshotRetentionPolicy)
 	listBackupsResponse.Backups = backups
 
 	JSON(w, 200, listBackupsResponse)
@@ -102,6 +105,19 @@ func (h *Handler) ListInstanceBackups(w http.ResponseWriter, r *http.Request) {
 		JSON(w, 500, listBackupsResponse)
 		return
 	}
+	addBackupVerifications(backups)
+
+	clusters, err := store.GetStore().ListClusters()
+	if err != nil {
+		logger.Error(err)
+		listBackupsResponse.Error = "failed to list clusters"
+		JSON(w, 500, listBackupsResponse)
+		return
+	}
+	if len(clusters) > 0 {
+		// sets why each scheduled backup is kept
+		snapshot.ApplyRetentionPolicy(backups, clusters[0].SnapshotRetentionPolicy)
+	}
 	listBackupsResponse.Backups = backups
 
 	JSON(w, 200, listBackupsResponse)
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot"
+)
+
+// ExportBackup streams a backup with its restic repositories as a tar.gz archive that can be imported into another cluster
+func (h *Handler) ExportBackup(w http.ResponseWriter, r *http.Request) {
+	backupName := mux.Vars(r)["snapshotName"]
+
+	backupExport, err := snapshot.PrepareBackupExport(backupName)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.tar.gz", backupName))
+	w.Header().Set("Content-Type", "application/gzip")
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := backupExport.Write(w); err != nil {
+		logger.Error(errors.Wrapf(err, "failed to export backup %s", backupName))
+		return
+	}
+}
+
+type ImportBackupResponse struct {
+	Success    bool   `json:"success"`
+	BackupName string `json:"backupName,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ImportBackup uploads an exported backup archive to the snapshot store
+func (h *Handler) ImportBackup(w http.ResponseWriter, r *http.Request) {
+	importBackupResponse := ImportBackupResponse{
+		Success: false,
+	}
+
+	metadata, err := snapshot.ImportBackup(r.Body)
+	if err != nil {
+		logger.Error(err)
+		importBackupResponse.Error = errors.Cause(err).Error()
+		JSON(w, http.StatusInternalServerError, importBackupResponse)
+		return
+	}
+
+	importBackupResponse.Success = true
+	importBackupResponse.BackupName = metadata.BackupName
+
+	JSON(w, http.StatusOK, importBackupResponse)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot"
+	snapshottypes "github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+type VerifyBackupRequest struct {
+	// Job is an optional job that is run against the restored app, the backup fails verification if the job fails
+	Job            *batchv1.JobSpec `json:"job,omitempty"`
+	TimeoutSeconds int64            `json:"timeoutSeconds,omitempty"`
+}
+
+type VerifyBackupResponse struct {
+	Success      bool                              `json:"success"`
+	Error        string                            `json:"error,omitempty"`
+	Verification *snapshottypes.BackupVerification `json:"verification,omitempty"`
+}
+
+// VerifyBackup restores the app from a backup into scratch namespaces to prove that the backup can be restored.
+// The running app is not touched.
+func (h *Handler) VerifyBackup(w http.ResponseWriter, r *http.Request) {
+	verifyBackupResponse := VerifyBackupResponse{
+		Success: false,
+	}
+
+	verifyBackupRequest := VerifyBackupRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&verifyBackupRequest); err != nil {
+			logger.Error(err)
+			verifyBackupResponse.Error = "failed to decode request body"
+			JSON(w, http.StatusBadRequest, verifyBackupResponse)
+			return
+		}
+	}
+
+	if verifyBackupRequest.TimeoutSeconds < 0 {
+		verifyBackupResponse.Error = "timeout must not be negative"
+		JSON(w, http.StatusBadRequest, verifyBackupResponse)
+		return
+	}
+
+	foundApp, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		verifyBackupResponse.Error = "failed to get app from app slug"
+		JSON(w, http.StatusInternalServerError, verifyBackupResponse)
+		return
+	}
+
+	timeout := time.Duration(verifyBackupRequest.TimeoutSeconds) * time.Second
+	verification, err := snapshot.CreateBackupVerification(foundApp, mux.Vars(r)["snapshotName"], verifyBackupRequest.Job, timeout)
+	if err != nil {
+		logger.Error(err)
+		verifyBackupResponse.Error = errors.Cause(err).Error()
+		JSON(w, http.StatusInternalServerError, verifyBackupResponse)
+		return
+	}
+
+	verifyBackupResponse.Success = true
+	verifyBackupResponse.Verification = verification
+
+	JSON(w, http.StatusOK, verifyBackupResponse)
+}
+
+type GetBackupVerificationResponse struct {
+	Error        string                            `json:"error,omitempty"`
+	Verification *snapshottypes.BackupVerification `json:"verification"`
+}
+
+func (h *Handler) GetBackupVerification(w http.ResponseWriter, r *http.Request) {
+	getBackupVerificationResponse := GetBackupVerificationResponse{}
+
+	foundApp, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		getBackupVerificationResponse.Error = "failed to get app from app slug"
+		JSON(w, http.StatusInternalServerError, getBackupVerificationResponse)
+		return
+	}
+
+	verification, err := store.GetStore().GetBackupVerification(mux.Vars(r)["snapshotName"], foundApp.ID)
+	if err != nil {
+		if store.GetStore().IsNotFound(err) {
+			getBackupVerificationResponse.Error = "backup has not been verified"
+			JSON(w, http.StatusNotFound, getBackupVerificationResponse)
+			return
+		}
+		logger.Error(err)
+		getBackupVerificationResponse.Error = "failed to get backup verification"
+		JSON(w, http.StatusInternalServerError, getBackupVerificationResponse)
+		return
+	}
+	getBackupVerificationResponse.Verification = verification
+
+	JSON(w, http.StatusOK, getBackupVerificationResponse)
+}
+
+// addBackupVerifications reports the latest verification of each app on the backups.
+// Backups are still listed if the verifications can't be read.
+func addBackupVerifications(backups []*snapshottypes.Backup) {
+	verifications, err := store.GetStore().ListBackupVerifications()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to list backup verifications"))
+		return
+	}
+
+	for _, backup := range backups {
+		for _, verification := range verifications {
+			if verification.BackupName == backup.Name {
+				backup.Verifications = append(backup.Verifications, verification)
+			}
+		}
+	}
+}
@@ -12,8 +12,13 @@ import (
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/app"
 	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/autosupportbundle"
 	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
+	"github.com/replicatedhq/kots/kotsadm/pkg/healthverifier"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow"
+	"github.com/replicatedhq/kots/kotsadm/pkg/preflight"
+	"github.com/replicatedhq/kots/kotsadm/pkg/session"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
 	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle"
 	"github.com/replicatedhq/kots/kotsadm/pkg/version"
@@ -32,6 +37,10 @@ type UpdateDeployResultRequest struct {
 	RenderError  string `json:"renderError"`
 }
 
+type DeployAppVersionResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
 type UpdateUndeployResultRequest struct {
 	AppID   string `json:"appId"`
 	IsError bool   `json:"isError"`
@@ -66,6 +75,49 @@ func (h *Handler) DeployAppVersion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := version.CheckRequiredVersions(a.ID, downstreams[0].ClusterID, int64(sequence)); err != nil {
+		if _, ok := errors.Cause(err).(version.SkippedRequiredVersionsError); ok {
+			JSON(w, http.StatusBadRequest, DeployAppVersionResponse{Error: err.Error()})
+			return
+		}
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := maintenancewindow.CheckOpenForApp(a.ID); err != nil {
+		if _, ok := errors.Cause(err).(maintenancewindow.ClosedError); ok {
+			JSON(w, http.StatusConflict, DeployAppVersionResponse{Error: err.Error()})
+			return
+		}
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	overrideStrictPreflights, _ := strconv.ParseBool(r.URL.Query().Get("overrideStrictPreflights"))
+	if overrideStrictPreflights {
+		sess := session.ContextGetSession(r)
+		if sess == nil {
+			logger.Error(errors.New("invalid session"))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := preflight.OverrideStrictPreflights(a.ID, int64(sequence), "admin console", sess.UserID); err != nil {
+			logger.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	} else if err := preflight.CheckStrictPreflights(a.ID, int64(sequence)); err != nil {
+		if _, ok := errors.Cause(err).(preflight.StrictPreflightsError); ok {
+			JSON(w, http.StatusBadRequest, DeployAppVersionResponse{Error: err.Error()})
+			return
+		}
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	if err := downstream.DeleteDownstreamDeployStatus(a.ID, downstreams[0].ClusterID, int64(sequence)); err != nil {
 		logger.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -144,6 +196,19 @@ func (h *Handler) UpdateDeployResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if updateDeployResultRequest.IsError {
+		if err := autosupportbundle.DeployFailed(updateDeployResultRequest.AppID, clusterID, currentSequence); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to collect support bundle for failed sequence %d", currentSequence))
+		}
+	}
+
+	if !updateDeployResultRequest.IsError {
+		if err := healthverifier.StartVerification(updateDeployResultRequest.AppID, clusterID, currentSequence); err != nil {
+			// the deploy itself succeeded, so don't fail the request
+			logger.Error(errors.Wrapf(err, "failed to start health verification for sequence %d", currentSequence))
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	return
 }
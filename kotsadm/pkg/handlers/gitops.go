@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
 	"github.com/replicatedhq/kots/kotsadm/pkg/gitops"
+	"github.com/replicatedhq/kots/kotsadm/pkg/gitopssync"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
 )
@@ -19,20 +20,36 @@ type UpdateAppGitOpsRequest struct {
 	GitOpsInput UpdateAppGitOpsInput `json:"gitOpsInput"`
 }
 type UpdateAppGitOpsInput struct {
-	URI    string `json:"uri"`
-	Branch string `json:"branch"`
-	Path   string `json:"path"`
-	Format string `json:"format"`
-	Action string `json:"action"`
+	URI        string `json:"uri"`
+	Branch     string `json:"branch"`
+	Path       string `json:"path"`
+	Format     string `json:"format"`
+	Action     string `json:"action"`
+	AutoRepush bool   `json:"autoRepush"`
+}
+
+type UpdateAppGitOpsSecretEncryptionRequest struct {
+	SecretEncryption gitops.SecretEncryption `json:"secretEncryption"`
 }
 
 type CreateGitOpsRequest struct {
 	GitOpsInput CreateGitOpsInput `json:"gitOpsInput"`
 }
 type CreateGitOpsInput struct {
-	Provider string `json:"provider"`
-	URI      string `json:"uri"`
-	Hostname string `json:"hostname"`
+	Provider   string `json:"provider"`
+	URI        string `json:"uri"`
+	Hostname   string `json:"hostname"`
+	APIToken   string `json:"apiToken"`
+	AuthMethod string `json:"authMethod"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+
+	CommitAuthorName      string `json:"commitAuthorName"`
+	CommitAuthorEmail     string `json:"commitAuthorEmail"`
+	CommitMessageTemplate string `json:"commitMessageTemplate"`
+	SigningMethod         string `json:"signingMethod"`
+	SigningKey            string `json:"signingKey"`
+	SigningKeyPassphrase  string `json:"signingKeyPassphrase"`
 }
 
 func (h *Handler) UpdateAppGitOps(w http.ResponseWriter, r *http.Request) {
@@ -54,7 +71,19 @@ func (h *Handler) UpdateAppGitOps(w http.ResponseWriter, r *http.Request) {
 	}
 
 	gitOpsInput := updateAppGitOpsRequest.GitOpsInput
-	if err := gitops.UpdateDownstreamGitOps(a.ID, clusterID, gitOpsInput.URI, gitOpsInput.Branch, gitOpsInput.Path, gitOpsInput.Format, gitOpsInput.Action); err != nil {
+	switch gitOpsInput.Action {
+	case "", gitops.ActionCommit, gitops.ActionPullRequest:
+	default:
+		JSON(w, http.StatusBadRequest, NewErrorResponse(errors.Errorf("unsupported gitops action %q", gitOpsInput.Action)))
+		return
+	}
+
+	if !gitops.IsValidFormat(gitOpsInput.Format) {
+		JSON(w, http.StatusBadRequest, NewErrorResponse(errors.Errorf("unsupported gitops format %q", gitOpsInput.Format)))
+		return
+	}
+
+	if err := gitops.UpdateDownstreamGitOps(a.ID, clusterID, gitOpsInput.URI, gitOpsInput.Branch, gitOpsInput.Path, gitOpsInput.Format, gitOpsInput.Action, gitOpsInput.AutoRepush); err != nil {
 		logger.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -93,6 +122,58 @@ func (h *Handler) DisableAppGitOps(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusNoContent, "")
 }
 
+func (h *Handler) UpdateAppGitOpsSecretEncryption(w http.ResponseWriter, r *http.Request) {
+	updateRequest := UpdateAppGitOpsSecretEncryptionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	appID := mux.Vars(r)["appId"]
+	clusterID := mux.Vars(r)["clusterId"]
+
+	a, err := store.GetStore().GetApp(appID)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := updateRequest.SecretEncryption.Validate(); err != nil {
+		JSON(w, http.StatusBadRequest, NewErrorResponse(err))
+		return
+	}
+
+	if err := gitops.SetDownstreamGitOpsSecretEncryption(a.ID, clusterID, updateRequest.SecretEncryption); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	JSON(w, http.StatusNoContent, "")
+}
+
+func (h *Handler) RepushAppGitOps(w http.ResponseWriter, r *http.Request) {
+	appID := mux.Vars(r)["appId"]
+	clusterID := mux.Vars(r)["clusterId"]
+
+	a, err := store.GetStore().GetApp(appID)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := gitopssync.Repush(a.ID, clusterID); err != nil {
+		logger.Error(err)
+		JSON(w, http.StatusInternalServerError, NewErrorResponse(err))
+		return
+	}
+
+	JSON(w, http.StatusNoContent, "")
+}
+
 func (h *Handler) InitGitOpsConnection(w http.ResponseWriter, r *http.Request) {
 	currentStatus, _, err := store.GetStore().GetTaskStatus("gitops-init")
 	if err != nil {
@@ -137,12 +218,21 @@ func (h *Handler) InitGitOpsConnection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if downstreamGitOps.Format != "single" {
-		logger.Error(errors.New("unsupported gitops format"))
+	if !gitops.IsValidFormat(downstreamGitOps.Format) {
+		logger.Error(errors.Errorf("unsupported gitops format %q", downstreamGitOps.Format))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	if downstreamGitOps.Action == gitops.ActionPullRequest && downstreamGitOps.APIToken == "" {
+		err := errors.New("an api token is required to open pull requests")
+		if err := gitops.SetGitOpsError(a.ID, d.ClusterID, err.Error()); err != nil {
+			logger.Error(err)
+		}
+		JSON(w, http.StatusBadRequest, NewErrorResponse(err))
+		return
+	}
+
 	if err := gitops.TestGitOpsConnection(downstreamGitOps); err != nil {
 		logger.Infof("Failed to test gitops connection: %v", err)
 
@@ -214,7 +304,16 @@ func (h *Handler) InitGitOpsConnection(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			_, err = gitops.CreateGitOpsCommit(downstreamGitOps, a.Slug, a.Name, int(currentVersion.ParentSequence), currentVersionArchive, d.Name)
+			previousArchive, err := gitopssync.GetPreviousVersionArchive(a.ID, d.ClusterID, currentVersion.ParentSequence)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to get previous app version archive for current version %d", currentVersion.ParentSequence)
+				logger.Error(err)
+				finalError = err
+				return
+			}
+			defer os.RemoveAll(previousArchive)
+
+			_, err = gitops.CreateGitOpsCommit(downstreamGitOps, a.Slug, a.Name, int(currentVersion.ParentSequence), currentVersionArchive, previousArchive, d.Name, currentVersion.DiffSummary)
 			if err != nil {
 				err = errors.Wrapf(err, "failed to create gitops commit for current version %d", currentVersion.ParentSequence)
 				logger.Error(err)
@@ -246,7 +345,16 @@ func (h *Handler) InitGitOpsConnection(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			_, err = gitops.CreateGitOpsCommit(downstreamGitOps, a.Slug, a.Name, int(pendingVersion.ParentSequence), pendingVersionArchive, d.Name)
+			previousArchive, err := gitopssync.GetPreviousVersionArchive(a.ID, d.ClusterID, pendingVersion.ParentSequence)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to get previous app version archive for pending version %d", pendingVersion.ParentSequence)
+				logger.Error(err)
+				finalError = err
+				return
+			}
+			defer os.RemoveAll(previousArchive)
+
+			_, err = gitops.CreateGitOpsCommit(downstreamGitOps, a.Slug, a.Name, int(pendingVersion.ParentSequence), pendingVersionArchive, previousArchive, d.Name, pendingVersion.DiffSummary)
 			if err != nil {
 				err = errors.Wrapf(err, "failed to create gitops commit for pending version %d", pendingVersion.ParentSequence)
 				logger.Error(err)
@@ -289,7 +397,35 @@ func (h *Handler) CreateGitOps(w http.ResponseWriter, r *http.Request) {
 	}
 
 	gitOpsInput := createGitOpsRequest.GitOpsInput
-	if err := gitops.CreateGitOps(gitOpsInput.Provider, gitOpsInput.URI, gitOpsInput.Hostname); err != nil {
+	if !gitops.IsValidAuthMethod(gitOpsInput.AuthMethod) {
+		JSON(w, http.StatusBadRequest, NewErrorResponse(errors.Errorf("unsupported gitops auth method %q", gitOpsInput.AuthMethod)))
+		return
+	}
+
+	if !gitops.IsValidSigningMethod(gitOpsInput.SigningMethod) {
+		JSON(w, http.StatusBadRequest, NewErrorResponse(errors.Errorf("unsupported gitops signing method %q", gitOpsInput.SigningMethod)))
+		return
+	}
+
+	if err := gitops.ValidateCommitMessageTemplate(gitOpsInput.CommitMessageTemplate); err != nil {
+		JSON(w, http.StatusBadRequest, NewErrorResponse(err))
+		return
+	}
+
+	auth := gitops.GitOpsAuth{
+		Method:   gitOpsInput.AuthMethod,
+		Username: gitOpsInput.Username,
+		Password: gitOpsInput.Password,
+	}
+	commitSettings := gitops.CommitSettings{
+		AuthorName:           gitOpsInput.CommitAuthorName,
+		AuthorEmail:          gitOpsInput.CommitAuthorEmail,
+		MessageTemplate:      gitOpsInput.CommitMessageTemplate,
+		SigningMethod:        gitOpsInput.SigningMethod,
+		SigningKey:           gitOpsInput.SigningKey,
+		SigningKeyPassphrase: gitOpsInput.SigningKeyPassphrase,
+	}
+	if err := gitops.CreateGitOps(gitOpsInput.Provider, gitOpsInput.URI, gitOpsInput.Hostname, gitOpsInput.APIToken, auth, commitSettings); err != nil {
 		logger.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -53,8 +53,22 @@ func RegisterSessionAuthRoutes(r *mux.Router, kotsStore store.KOTSStore, handler
 		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleRead, handler.GetSupportBundleRedactions)) // TODO: appSlug
 	r.Name("DownloadSupportBundle").Path("/api/v1/troubleshoot/supportbundle/{bundleId}/download").Methods("GET").
 		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleRead, handler.DownloadSupportBundle)) // TODO: appSlug
+	r.Name("CompareSupportBundles").Path("/api/v1/troubleshoot/supportbundle/{bundleId}/compare/{targetBundleId}").Methods("GET").
+		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleRead, handler.CompareSupportBundles))
 	r.Name("CollectSupportBundle").Path("/api/v1/troubleshoot/supportbundle/app/{appId}/cluster/{clusterId}/collect").Methods("POST").
 		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleWrite, handler.CollectSupportBundle))
+	r.Name("GetSupportBundlePolicy").Path("/api/v1/troubleshoot/app/{appSlug}/supportbundlepolicy").Methods("GET").
+		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleRead, handler.GetSupportBundlePolicy))
+	r.Name("UpdateSupportBundlePolicy").Path("/api/v1/troubleshoot/app/{appSlug}/supportbundlepolicy").Methods("PUT").
+		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleWrite, handler.UpdateSupportBundlePolicy))
+	r.Name("GetSupportBundleDestination").Path("/api/v1/troubleshoot/app/{appSlug}/supportbundledestination").Methods("GET").
+		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleRead, handler.GetSupportBundleDestination))
+	r.Name("UpdateSupportBundleDestination").Path("/api/v1/troubleshoot/app/{appSlug}/supportbundledestination").Methods("PUT").
+		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleWrite, handler.UpdateSupportBundleDestination))
+	r.Name("PushSupportBundle").Path("/api/v1/troubleshoot/supportbundle/{bundleId}/push").Methods("POST").
+		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleWrite, handler.PushSupportBundle))
+	r.Name("ListSupportBundleCollections").Path("/api/v1/troubleshoot/app/{appSlug}/collections").Methods("GET").
+		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleRead, handler.ListSupportBundleCollections))
 
 	// redactor routes
 	r.Name("UpdateRedact").Path("/api/v1/redact/set").Methods("PUT").
@@ -71,6 +85,10 @@ func RegisterSessionAuthRoutes(r *mux.Router, kotsStore store.KOTSStore, handler
 		HandlerFunc(middleware.EnforceAccess(policy.RedactorWrite, handler.DeleteRedact))
 	r.Name("SetRedactEnabled").Path("/api/v1/redact/enabled/{slug}").Methods("POST").
 		HandlerFunc(middleware.EnforceAccess(policy.RedactorWrite, handler.SetRedactEnabled))
+	r.Name("PreviewRedact").Path("/api/v1/redact/preview").Methods("POST").
+		HandlerFunc(middleware.EnforceAccess(policy.RedactorRead, handler.PreviewRedact))
+	r.Name("PreviewSupportBundleRedact").Path("/api/v1/redact/preview/supportbundle/{bundleId}").Methods("POST").
+		HandlerFunc(middleware.EnforceAccess(policy.AppSupportbundleRead, handler.PreviewSupportBundleRedact))
 
 	// Kotsadm Identity Service
 	r.Name("ConfigureIdentityService").Path("/api/v1/identity/config").Methods("POST").
@@ -125,6 +143,12 @@ func RegisterSessionAuthRoutes(r *mux.Router, kotsStore store.KOTSStore, handler
 		HandlerFunc(middleware.EnforceAccess(policy.AppDownstreamPreflightRead, handler.GetPreflightResult))
 	r.Name("GetPreflightCommand").Path("/api/v1/app/{appSlug}/sequence/{sequence}/preflightcommand").Methods("POST").
 		HandlerFunc(middleware.EnforceAccess(policy.AppRead, handler.GetPreflightCommand)) // this is intentionally policy.AppRead
+	r.Name("GetPreflightCheckPolicy").Path("/api/v1/app/{appSlug}/preflight/checkpolicy").Methods("GET").
+		HandlerFunc(middleware.EnforceAccess(policy.AppDownstreamPreflightRead, handler.GetPreflightCheckPolicy))
+	r.Name("UpdatePreflightCheckPolicy").Path("/api/v1/app/{appSlug}/preflight/checkpolicy").Methods("PUT").
+		HandlerFunc(middleware.EnforceAccess(policy.AppDownstreamPreflightWrite, handler.UpdatePreflightCheckPolicy))
+	r.Name("ListPreflightChecks").Path("/api/v1/app/{appSlug}/preflight/checks").Methods("GET").
+		HandlerFunc(middleware.EnforceAccess(policy.AppDownstreamPreflightRead, handler.ListPreflightChecks))
 
 	r.Name("DeployAppVersion").Path("/api/v1/app/{appSlug}/sequence/{sequence}/deploy").Methods("POST").
 		HandlerFunc(middleware.EnforceAccess(policy.AppDownstreamWrite, handler.DeployAppVersion))
@@ -172,6 +196,14 @@ func RegisterSessionAuthRoutes(r *mux.Router, kotsStore store.KOTSStore, handler
 	r.Name("RemoveApp").Path("/api/v1/app/{appSlug}/remove").Methods("POST").
 		HandlerFunc(middleware.EnforceAccess(policy.AppUpdate, handler.RemoveApp))
 
+	// App maintenance windows
+	r.Name("GetMaintenanceWindows").Path("/api/v1/app/{appSlug}/maintenancewindows").Methods("GET").
+		HandlerFunc(middleware.EnforceAccess(policy.AppMaintenancewindowRead, handler.GetMaintenanceWindows))
+	r.Name("UpdateMaintenanceWindows").Path("/api/v1/app/{appSlug}/maintenancewindows").Methods("PUT").
+		HandlerFunc(middleware.EnforceAccess(policy.AppMaintenancewindowWrite, handler.UpdateMaintenanceWindows))
+	r.Name("OverrideMaintenanceWindows").Path("/api/v1/app/{appSlug}/maintenancewindows/override").Methods("POST").
+		HandlerFunc(middleware.EnforceAccess(policy.AppUpdate, handler.OverrideMaintenanceWindows))
+
 	// App snapshot routes
 	r.Name("CreateApplicationBackup").Path("/api/v1/app/{appSlug}/snapshot/backup").Methods("POST").
 		HandlerFunc(middleware.EnforceAccess(policy.AppBackupWrite, handler.CreateApplicationBackup))
@@ -183,6 +215,12 @@ func RegisterSessionAuthRoutes(r *mux.Router, kotsStore store.KOTSStore, handler
 		HandlerFunc(middleware.EnforceAccess(policy.AppRestoreWrite, handler.CreateApplicationRestore))
 	r.Name("GetRestoreDetails").Path("/api/v1/app/{appSlug}/snapshot/restore/{restoreName}").Methods("GET").
 		HandlerFunc(middleware.EnforceAccess(policy.AppRestoreRead, handler.GetRestoreDetails))
+	r.Name("VerifyBackup").Path("/api/v1/app/{appSlug}/snapshot/verify/{snapshotName}").Methods("POST").
+		HandlerFunc(middleware.EnforceAccess(policy.AppRestoreWrite, handler.VerifyBackup))
+	r.Name("GetBackupVerification").Path("/api/v1/app/{appSlug}/snapshot/verify/{snapshotName}").Methods("GET").
+		HandlerFunc(middleware.EnforceAccess(policy.AppRestoreRead, handler.GetBackupVerification))
+	r.Name("RollbackAndRestoreAppVersion").Path("/api/v1/app/{appSlug}/sequence/{sequence}/rollback-restore").Methods("POST").
+		HandlerFunc(middleware.EnforceAccess(policy.AppRestoreWrite, handler.RollbackAndRestoreAppVersion))
 	r.Name("ListBackups").Path("/api/v1/app/{appSlug}/snapshots").Methods("GET").
 		HandlerFunc(middleware.EnforceAccess(policy.AppBackupRead, handler.ListBackups))
 	r.Name("GetSnapshotConfig").Path("/api/v1/app/{appSlug}/snapshot/config").Methods("GET").
@@ -213,6 +251,10 @@ func RegisterSessionAuthRoutes(r *mux.Router, kotsStore store.KOTSStore, handler
 		HandlerFunc(middleware.EnforceAccess(policy.RestoreWrite, handler.GetRestoreAppsStatus))
 	r.Name("DownloadSnapshotLogs").Path("/api/v1/snapshot/{backup}/logs").Methods("GET").
 		HandlerFunc(middleware.EnforceAccess(policy.BackupRead, handler.DownloadSnapshotLogs))
+	r.Name("ExportBackup").Path("/api/v1/snapshot/{snapshotName}/export").Methods("GET").
+		HandlerFunc(middleware.EnforceAccess(policy.BackupRead, handler.ExportBackup))
+	r.Name("ImportBackup").Path("/api/v1/snapshots/import").Methods("POST").
+		HandlerFunc(middleware.EnforceAccess(policy.BackupWrite, handler.ImportBackup))
 	r.Name("GetVeleroStatus").Path("/api/v1/velero").Methods("GET").
 		HandlerFunc(middleware.EnforceAccess(policy.BackupRead, handler.GetVeleroStatus))
 
@@ -229,6 +271,10 @@ func RegisterSessionAuthRoutes(r *mux.Router, kotsStore store.KOTSStore, handler
 	r.Name("GetKurlNodes").Path("/api/v1/kurl/nodes").Methods("GET").
 		HandlerFunc(middleware.EnforceAccess(policy.ClusterRead, handler.GetKurlNodes))
 
+	// Admin console
+	r.Name("UpgradeAdminConsole").Path("/api/v1/adminconsole/upgrade").Methods("POST").
+		HandlerFunc(middleware.EnforceAccess(policy.AdminConsoleWrite, handler.UpgradeAdminConsole))
+
 	// Prometheus
 	r.Name("SetPrometheusAddress").Path("/api/v1/prometheus").Methods("POST").
 		HandlerFunc(middleware.EnforceAccess(policy.PrometheussettingsWrite, handler.SetPrometheusAddress))
@@ -238,6 +284,10 @@ func RegisterSessionAuthRoutes(r *mux.Router, kotsStore store.KOTSStore, handler
 		HandlerFunc(middleware.EnforceAccess(policy.AppGitopsWrite, handler.UpdateAppGitOps))
 	r.Name("DisableAppGitOps").Path("/api/v1/gitops/app/{appId}/cluster/{clusterId}/disable").Methods("POST").
 		HandlerFunc(middleware.EnforceAccess(policy.AppGitopsWrite, handler.DisableAppGitOps))
+	r.Name("UpdateAppGitOpsSecretEncryption").Path("/api/v1/gitops/app/{appId}/cluster/{clusterId}/secretencryption").Methods("PUT").
+		HandlerFunc(middleware.EnforceAccess(policy.AppGitopsWrite, handler.UpdateAppGitOpsSecretEncryption))
+	r.Name("RepushAppGitOps").Path("/api/v1/gitops/app/{appId}/cluster/{clusterId}/repush").Methods("POST").
+		HandlerFunc(middleware.EnforceAccess(policy.AppGitopsWrite, handler.RepushAppGitOps))
 	r.Name("InitGitOpsConnection").Path("/api/v1/gitops/app/{appId}/cluster/{clusterId}/initconnection").Methods("POST").
 		HandlerFunc(middleware.EnforceAccess(policy.AppGitopsWrite, handler.InitGitOpsConnection))
 	r.Name("CreateGitOps").Path("/api/v1/gitops/create").Methods("POST").
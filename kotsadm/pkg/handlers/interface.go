@@ -18,6 +18,13 @@ type KOTSHandler interface {
 	GetSupportBundleRedactions(w http.ResponseWriter, r *http.Request) // TODO: appSlug
 	DownloadSupportBundle(w http.ResponseWriter, r *http.Request)      // TODO: appSlug
 	CollectSupportBundle(w http.ResponseWriter, r *http.Request)
+	GetSupportBundlePolicy(w http.ResponseWriter, r *http.Request)
+	UpdateSupportBundlePolicy(w http.ResponseWriter, r *http.Request)
+	CompareSupportBundles(w http.ResponseWriter, r *http.Request)
+	GetSupportBundleDestination(w http.ResponseWriter, r *http.Request)
+	UpdateSupportBundleDestination(w http.ResponseWriter, r *http.Request)
+	PushSupportBundle(w http.ResponseWriter, r *http.Request)
+	ListSupportBundleCollections(w http.ResponseWriter, r *http.Request)
 
 	// redactor routes
 	UpdateRedact(w http.ResponseWriter, r *http.Request)
@@ -27,6 +34,8 @@ type KOTSHandler interface {
 	SetRedactMetadataAndYaml(w http.ResponseWriter, r *http.Request)
 	DeleteRedact(w http.ResponseWriter, r *http.Request)
 	SetRedactEnabled(w http.ResponseWriter, r *http.Request)
+	PreviewRedact(w http.ResponseWriter, r *http.Request)
+	PreviewSupportBundleRedact(w http.ResponseWriter, r *http.Request)
 
 	// Kotsadm Identity Service
 	ConfigureIdentityService(w http.ResponseWriter, r *http.Request)
@@ -59,6 +68,9 @@ type KOTSHandler interface {
 	GetLatestPreflightResultsForSequenceZero(w http.ResponseWriter, r *http.Request)
 	GetPreflightResult(w http.ResponseWriter, r *http.Request)
 	GetPreflightCommand(w http.ResponseWriter, r *http.Request) // this is intentionally policy.AppRead
+	GetPreflightCheckPolicy(w http.ResponseWriter, r *http.Request)
+	UpdatePreflightCheckPolicy(w http.ResponseWriter, r *http.Request)
+	ListPreflightChecks(w http.ResponseWriter, r *http.Request)
 
 	DeployAppVersion(w http.ResponseWriter, r *http.Request)
 	RedeployAppVersion(w http.ResponseWriter, r *http.Request)
@@ -84,12 +96,20 @@ type KOTSHandler interface {
 	UpdateCheckerSpec(w http.ResponseWriter, r *http.Request)
 	RemoveApp(w http.ResponseWriter, r *http.Request)
 
+	// App maintenance windows
+	GetMaintenanceWindows(w http.ResponseWriter, r *http.Request)
+	UpdateMaintenanceWindows(w http.ResponseWriter, r *http.Request)
+	OverrideMaintenanceWindows(w http.ResponseWriter, r *http.Request)
+
 	// App snapshot routes
 	CreateApplicationBackup(w http.ResponseWriter, r *http.Request)
 	GetRestoreStatus(w http.ResponseWriter, r *http.Request)
 	CancelRestore(w http.ResponseWriter, r *http.Request)
 	CreateApplicationRestore(w http.ResponseWriter, r *http.Request)
 	GetRestoreDetails(w http.ResponseWriter, r *http.Request)
+	VerifyBackup(w http.ResponseWriter, r *http.Request)
+	GetBackupVerification(w http.ResponseWriter, r *http.Request)
+	RollbackAndRestoreAppVersion(w http.ResponseWriter, r *http.Request)
 	ListBackups(w http.ResponseWriter, r *http.Request)
 	GetSnapshotConfig(w http.ResponseWriter, r *http.Request)
 	SaveSnapshotConfig(w http.ResponseWriter, r *http.Request)
@@ -106,6 +126,8 @@ type KOTSHandler interface {
 	RestoreApps(w http.ResponseWriter, r *http.Request)
 	GetRestoreAppsStatus(w http.ResponseWriter, r *http.Request)
 	DownloadSnapshotLogs(w http.ResponseWriter, r *http.Request)
+	ExportBackup(w http.ResponseWriter, r *http.Request)
+	ImportBackup(w http.ResponseWriter, r *http.Request)
 	GetVeleroStatus(w http.ResponseWriter, r *http.Request)
 
 	// KURL
@@ -115,12 +137,17 @@ type KOTSHandler interface {
 	DeleteNode(w http.ResponseWriter, r *http.Request)
 	GetKurlNodes(w http.ResponseWriter, r *http.Request)
 
+	// Admin console
+	UpgradeAdminConsole(w http.ResponseWriter, r *http.Request)
+
 	// Prometheus
 	SetPrometheusAddress(w http.ResponseWriter, r *http.Request)
 
 	// GitOps
 	UpdateAppGitOps(w http.ResponseWriter, r *http.Request)
 	DisableAppGitOps(w http.ResponseWriter, r *http.Request)
+	UpdateAppGitOpsSecretEncryption(w http.ResponseWriter, r *http.Request)
+	RepushAppGitOps(w http.ResponseWriter, r *http.Request)
 	InitGitOpsConnection(w http.ResponseWriter, r *http.Request)
 	CreateGitOps(w http.ResponseWriter, r *http.Request)
 	ResetGitOps(w http.ResponseWriter, r *http.Request)
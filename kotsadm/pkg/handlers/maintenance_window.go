@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow"
+	maintenancewindowtypes "github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+)
+
+type GetMaintenanceWindowsResponse struct {
+	MaintenanceWindows *maintenancewindowtypes.MaintenanceWindows `json:"maintenanceWindows"`
+	IsOpen             bool                                       `json:"isOpen"`
+	NextWindow         *maintenancewindowtypes.WindowOccurrence   `json:"nextWindow"`
+	Error              string                                     `json:"error,omitempty"`
+}
+
+type UpdateMaintenanceWindowsRequest struct {
+	MaintenanceWindows *maintenancewindowtypes.MaintenanceWindows `json:"maintenanceWindows"`
+}
+
+type UpdateMaintenanceWindowsResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+type OverrideMaintenanceWindowsRequest struct {
+	Duration string `json:"duration"`
+}
+
+type OverrideMaintenanceWindowsResponse struct {
+	Success       bool       `json:"success"`
+	OverrideUntil *time.Time `json:"overrideUntil,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+func (h *Handler) GetMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	getMaintenanceWindowsResponse := GetMaintenanceWindowsResponse{}
+
+	foundApp, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		getMaintenanceWindowsResponse.Error = "failed to get app from slug"
+		JSON(w, http.StatusInternalServerError, getMaintenanceWindowsResponse)
+		return
+	}
+
+	maintenanceWindows, err := store.GetStore().GetMaintenanceWindows(foundApp.ID)
+	if err != nil {
+		logger.Error(err)
+		getMaintenanceWindowsResponse.Error = "failed to get maintenance windows"
+		JSON(w, http.StatusInternalServerError, getMaintenanceWindowsResponse)
+		return
+	}
+
+	now := time.Now()
+
+	isOpen, err := maintenancewindow.IsOpen(maintenanceWindows, now)
+	if err != nil {
+		logger.Error(err)
+		getMaintenanceWindowsResponse.Error = "failed to check maintenance windows"
+		JSON(w, http.StatusInternalServerError, getMaintenanceWindowsResponse)
+		return
+	}
+
+	nextWindow, err := maintenancewindow.NextWindow(maintenanceWindows, now)
+	if err != nil {
+		logger.Error(err)
+		getMaintenanceWindowsResponse.Error = "failed to get next maintenance window"
+		JSON(w, http.StatusInternalServerError, getMaintenanceWindowsResponse)
+		return
+	}
+
+	getMaintenanceWindowsResponse.MaintenanceWindows = maintenanceWindows
+	getMaintenanceWindowsResponse.IsOpen = isOpen
+	getMaintenanceWindowsResponse.NextWindow = nextWindow
+
+	JSON(w, http.StatusOK, getMaintenanceWindowsResponse)
+}
+
+func (h *Handler) UpdateMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	updateMaintenanceWindowsResponse := UpdateMaintenanceWindowsResponse{}
+
+	updateMaintenanceWindowsRequest := UpdateMaintenanceWindowsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&updateMaintenanceWindowsRequest); err != nil {
+		logger.Error(err)
+		updateMaintenanceWindowsResponse.Error = "failed to decode request body"
+		JSON(w, http.StatusBadRequest, updateMaintenanceWindowsResponse)
+		return
+	}
+
+	foundApp, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		updateMaintenanceWindowsResponse.Error = "failed to get app from slug"
+		JSON(w, http.StatusInternalServerError, updateMaintenanceWindowsResponse)
+		return
+	}
+
+	maintenanceWindows := updateMaintenanceWindowsRequest.MaintenanceWindows
+	if maintenanceWindows != nil {
+		if err := maintenancewindow.Validate(maintenanceWindows); err != nil {
+			logger.Error(err)
+			updateMaintenanceWindowsResponse.Error = err.Error()
+			JSON(w, http.StatusBadRequest, updateMaintenanceWindowsResponse)
+			return
+		}
+
+		// overrides can only be set through the override endpoint
+		existing, err := store.GetStore().GetMaintenanceWindows(foundApp.ID)
+		if err != nil {
+			logger.Error(err)
+			updateMaintenanceWindowsResponse.Error = "failed to get maintenance windows"
+			JSON(w, http.StatusInternalServerError, updateMaintenanceWindowsResponse)
+			return
+		}
+		maintenanceWindows.OverrideUntil = nil
+		if existing != nil {
+			maintenanceWindows.OverrideUntil = existing.OverrideUntil
+		}
+	}
+
+	if err := store.GetStore().SetMaintenanceWindows(foundApp.ID, maintenanceWindows); err != nil {
+		logger.Error(err)
+		updateMaintenanceWindowsResponse.Error = "failed to set maintenance windows"
+		JSON(w, http.StatusInternalServerError, updateMaintenanceWindowsResponse)
+		return
+	}
+
+	updateMaintenanceWindowsResponse.Success = true
+	JSON(w, http.StatusOK, updateMaintenanceWindowsResponse)
+}
+
+// OverrideMaintenanceWindows opens the app's maintenance windows for the requested duration.
+// A zero duration ends an active override.
+func (h *Handler) OverrideMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	overrideMaintenanceWindowsResponse := OverrideMaintenanceWindowsResponse{}
+
+	overrideMaintenanceWindowsRequest := OverrideMaintenanceWindowsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&overrideMaintenanceWindowsRequest); err != nil {
+		logger.Error(err)
+		overrideMaintenanceWindowsResponse.Error = "failed to decode request body"
+		JSON(w, http.StatusBadRequest, overrideMaintenanceWindowsResponse)
+		return
+	}
+
+	duration, err := time.ParseDuration(overrideMaintenanceWindowsRequest.Duration)
+	if err != nil || duration < 0 {
+		logger.Error(err)
+		overrideMaintenanceWindowsResponse.Error = fmt.Sprintf("invalid override duration: %s", overrideMaintenanceWindowsRequest.Duration)
+		JSON(w, http.StatusBadRequest, overrideMaintenanceWindowsResponse)
+		return
+	}
+
+	foundApp, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		overrideMaintenanceWindowsResponse.Error = "failed to get app from slug"
+		JSON(w, http.StatusInternalServerError, overrideMaintenanceWindowsResponse)
+		return
+	}
+
+	until := time.Now().Add(duration)
+	if err := maintenancewindow.Override(foundApp.ID, until); err != nil {
+		logger.Error(err)
+		if errors.Cause(err) == maintenancewindow.ErrNotConfigured {
+			overrideMaintenanceWindowsResponse.Error = "the app does not have any maintenance windows to override"
+			JSON(w, http.StatusConflict, overrideMaintenanceWindowsResponse)
+			return
+		}
+		overrideMaintenanceWindowsResponse.Error = "failed to override maintenance windows"
+		JSON(w, http.StatusInternalServerError, overrideMaintenanceWindowsResponse)
+		return
+	}
+
+	logger.Infof("maintenance windows for app %s overridden until %s", foundApp.Slug, until.Format(time.RFC3339))
+
+	overrideMaintenanceWindowsResponse.Success = true
+	overrideMaintenanceWindowsResponse.OverrideUntil = &until
+	JSON(w, http.StatusOK, overrideMaintenanceWindowsResponse)
+}
@@ -177,6 +177,90 @@ func (mr *MockKOTSHandlerMockRecorder) CollectSupportBundle(w, r interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectSupportBundle", reflect.TypeOf((*MockKOTSHandler)(nil).CollectSupportBundle), w, r)
 }
 
+// GetSupportBundlePolicy mocks base method
+func (m *MockKOTSHandler) GetSupportBundlePolicy(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetSupportBundlePolicy", w, r)
+}
+
+// GetSupportBundlePolicy indicates an expected call of GetSupportBundlePolicy
+func (mr *MockKOTSHandlerMockRecorder) GetSupportBundlePolicy(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSupportBundlePolicy", reflect.TypeOf((*MockKOTSHandler)(nil).GetSupportBundlePolicy), w, r)
+}
+
+// UpdateSupportBundlePolicy mocks base method
+func (m *MockKOTSHandler) UpdateSupportBundlePolicy(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateSupportBundlePolicy", w, r)
+}
+
+// UpdateSupportBundlePolicy indicates an expected call of UpdateSupportBundlePolicy
+func (mr *MockKOTSHandlerMockRecorder) UpdateSupportBundlePolicy(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSupportBundlePolicy", reflect.TypeOf((*MockKOTSHandler)(nil).UpdateSupportBundlePolicy), w, r)
+}
+
+// CompareSupportBundles mocks base method
+func (m *MockKOTSHandler) CompareSupportBundles(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CompareSupportBundles", w, r)
+}
+
+// CompareSupportBundles indicates an expected call of CompareSupportBundles
+func (mr *MockKOTSHandlerMockRecorder) CompareSupportBundles(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareSupportBundles", reflect.TypeOf((*MockKOTSHandler)(nil).CompareSupportBundles), w, r)
+}
+
+// GetSupportBundleDestination mocks base method
+func (m *MockKOTSHandler) GetSupportBundleDestination(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetSupportBundleDestination", w, r)
+}
+
+// GetSupportBundleDestination indicates an expected call of GetSupportBundleDestination
+func (mr *MockKOTSHandlerMockRecorder) GetSupportBundleDestination(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSupportBundleDestination", reflect.TypeOf((*MockKOTSHandler)(nil).GetSupportBundleDestination), w, r)
+}
+
+// UpdateSupportBundleDestination mocks base method
+func (m *MockKOTSHandler) UpdateSupportBundleDestination(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateSupportBundleDestination", w, r)
+}
+
+// UpdateSupportBundleDestination indicates an expected call of UpdateSupportBundleDestination
+func (mr *MockKOTSHandlerMockRecorder) UpdateSupportBundleDestination(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSupportBundleDestination", reflect.TypeOf((*MockKOTSHandler)(nil).UpdateSupportBundleDestination), w, r)
+}
+
+// PushSupportBundle mocks base method
+func (m *MockKOTSHandler) PushSupportBundle(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PushSupportBundle", w, r)
+}
+
+// PushSupportBundle indicates an expected call of PushSupportBundle
+func (mr *MockKOTSHandlerMockRecorder) PushSupportBundle(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushSupportBundle", reflect.TypeOf((*MockKOTSHandler)(nil).PushSupportBundle), w, r)
+}
+
+// ListSupportBundleCollections mocks base method
+func (m *MockKOTSHandler) ListSupportBundleCollections(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ListSupportBundleCollections", w, r)
+}
+
+// ListSupportBundleCollections indicates an expected call of ListSupportBundleCollections
+func (mr *MockKOTSHandlerMockRecorder) ListSupportBundleCollections(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSupportBundleCollections", reflect.TypeOf((*MockKOTSHandler)(nil).ListSupportBundleCollections), w, r)
+}
+
 // UpdateRedact mocks base method
 func (m *MockKOTSHandler) UpdateRedact(w http.ResponseWriter, r *http.Request) {
 	m.ctrl.T.Helper()
@@ -261,6 +345,30 @@ func (mr *MockKOTSHandlerMockRecorder) SetRedactEnabled(w, r interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRedactEnabled", reflect.TypeOf((*MockKOTSHandler)(nil).SetRedactEnabled), w, r)
 }
 
+// PreviewRedact mocks base method
+func (m *MockKOTSHandler) PreviewRedact(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PreviewRedact", w, r)
+}
+
+// PreviewRedact indicates an expected call of PreviewRedact
+func (mr *MockKOTSHandlerMockRecorder) PreviewRedact(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewRedact", reflect.TypeOf((*MockKOTSHandler)(nil).PreviewRedact), w, r)
+}
+
+// PreviewSupportBundleRedact mocks base method
+func (m *MockKOTSHandler) PreviewSupportBundleRedact(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PreviewSupportBundleRedact", w, r)
+}
+
+// PreviewSupportBundleRedact indicates an expected call of PreviewSupportBundleRedact
+func (mr *MockKOTSHandlerMockRecorder) PreviewSupportBundleRedact(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewSupportBundleRedact", reflect.TypeOf((*MockKOTSHandler)(nil).PreviewSupportBundleRedact), w, r)
+}
+
 // ConfigureIdentityService mocks base method
 func (m *MockKOTSHandler) ConfigureIdentityService(w http.ResponseWriter, r *http.Request) {
 	m.ctrl.T.Helper()
@@ -525,6 +633,42 @@ func (mr *MockKOTSHandlerMockRecorder) GetPreflightCommand(w, r interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPreflightCommand", reflect.TypeOf((*MockKOTSHandler)(nil).GetPreflightCommand), w, r)
 }
 
+// GetPreflightCheckPolicy mocks base method
+func (m *MockKOTSHandler) GetPreflightCheckPolicy(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetPreflightCheckPolicy", w, r)
+}
+
+// GetPreflightCheckPolicy indicates an expected call of GetPreflightCheckPolicy
+func (mr *MockKOTSHandlerMockRecorder) GetPreflightCheckPolicy(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPreflightCheckPolicy", reflect.TypeOf((*MockKOTSHandler)(nil).GetPreflightCheckPolicy), w, r)
+}
+
+// UpdatePreflightCheckPolicy mocks base method
+func (m *MockKOTSHandler) UpdatePreflightCheckPolicy(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdatePreflightCheckPolicy", w, r)
+}
+
+// UpdatePreflightCheckPolicy indicates an expected call of UpdatePreflightCheckPolicy
+func (mr *MockKOTSHandlerMockRecorder) UpdatePreflightCheckPolicy(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePreflightCheckPolicy", reflect.TypeOf((*MockKOTSHandler)(nil).UpdatePreflightCheckPolicy), w, r)
+}
+
+// ListPreflightChecks mocks base method
+func (m *MockKOTSHandler) ListPreflightChecks(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ListPreflightChecks", w, r)
+}
+
+// ListPreflightChecks indicates an expected call of ListPreflightChecks
+func (mr *MockKOTSHandlerMockRecorder) ListPreflightChecks(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPreflightChecks", reflect.TypeOf((*MockKOTSHandler)(nil).ListPreflightChecks), w, r)
+}
+
 // DeployAppVersion mocks base method
 func (m *MockKOTSHandler) DeployAppVersion(w http.ResponseWriter, r *http.Request) {
 	m.ctrl.T.Helper()
@@ -753,6 +897,42 @@ func (mr *MockKOTSHandlerMockRecorder) RemoveApp(w, r interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveApp", reflect.TypeOf((*MockKOTSHandler)(nil).RemoveApp), w, r)
 }
 
+// GetMaintenanceWindows mocks base method
+func (m *MockKOTSHandler) GetMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetMaintenanceWindows", w, r)
+}
+
+// GetMaintenanceWindows indicates an expected call of GetMaintenanceWindows
+func (mr *MockKOTSHandlerMockRecorder) GetMaintenanceWindows(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMaintenanceWindows", reflect.TypeOf((*MockKOTSHandler)(nil).GetMaintenanceWindows), w, r)
+}
+
+// UpdateMaintenanceWindows mocks base method
+func (m *MockKOTSHandler) UpdateMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateMaintenanceWindows", w, r)
+}
+
+// UpdateMaintenanceWindows indicates an expected call of UpdateMaintenanceWindows
+func (mr *MockKOTSHandlerMockRecorder) UpdateMaintenanceWindows(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMaintenanceWindows", reflect.TypeOf((*MockKOTSHandler)(nil).UpdateMaintenanceWindows), w, r)
+}
+
+// OverrideMaintenanceWindows mocks base method
+func (m *MockKOTSHandler) OverrideMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OverrideMaintenanceWindows", w, r)
+}
+
+// OverrideMaintenanceWindows indicates an expected call of OverrideMaintenanceWindows
+func (mr *MockKOTSHandlerMockRecorder) OverrideMaintenanceWindows(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OverrideMaintenanceWindows", reflect.TypeOf((*MockKOTSHandler)(nil).OverrideMaintenanceWindows), w, r)
+}
+
 // CreateApplicationBackup mocks base method
 func (m *MockKOTSHandler) CreateApplicationBackup(w http.ResponseWriter, r *http.Request) {
 	m.ctrl.T.Helper()
@@ -813,6 +993,42 @@ func (mr *MockKOTSHandlerMockRecorder) GetRestoreDetails(w, r interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRestoreDetails", reflect.TypeOf((*MockKOTSHandler)(nil).GetRestoreDetails), w, r)
 }
 
+// VerifyBackup mocks base method
+func (m *MockKOTSHandler) VerifyBackup(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "VerifyBackup", w, r)
+}
+
+// VerifyBackup indicates an expected call of VerifyBackup
+func (mr *MockKOTSHandlerMockRecorder) VerifyBackup(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyBackup", reflect.TypeOf((*MockKOTSHandler)(nil).VerifyBackup), w, r)
+}
+
+// GetBackupVerification mocks base method
+func (m *MockKOTSHandler) GetBackupVerification(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetBackupVerification", w, r)
+}
+
+// GetBackupVerification indicates an expected call of GetBackupVerification
+func (mr *MockKOTSHandlerMockRecorder) GetBackupVerification(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBackupVerification", reflect.TypeOf((*MockKOTSHandler)(nil).GetBackupVerification), w, r)
+}
+
+// RollbackAndRestoreAppVersion mocks base method
+func (m *MockKOTSHandler) RollbackAndRestoreAppVersion(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RollbackAndRestoreAppVersion", w, r)
+}
+
+// RollbackAndRestoreAppVersion indicates an expected call of RollbackAndRestoreAppVersion
+func (mr *MockKOTSHandlerMockRecorder) RollbackAndRestoreAppVersion(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollbackAndRestoreAppVersion", reflect.TypeOf((*MockKOTSHandler)(nil).RollbackAndRestoreAppVersion), w, r)
+}
+
 // ListBackups mocks base method
 func (m *MockKOTSHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
 	m.ctrl.T.Helper()
@@ -981,6 +1197,30 @@ func (mr *MockKOTSHandlerMockRecorder) DownloadSnapshotLogs(w, r interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadSnapshotLogs", reflect.TypeOf((*MockKOTSHandler)(nil).DownloadSnapshotLogs), w, r)
 }
 
+// ExportBackup mocks base method
+func (m *MockKOTSHandler) ExportBackup(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ExportBackup", w, r)
+}
+
+// ExportBackup indicates an expected call of ExportBackup
+func (mr *MockKOTSHandlerMockRecorder) ExportBackup(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportBackup", reflect.TypeOf((*MockKOTSHandler)(nil).ExportBackup), w, r)
+}
+
+// ImportBackup mocks base method
+func (m *MockKOTSHandler) ImportBackup(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ImportBackup", w, r)
+}
+
+// ImportBackup indicates an expected call of ImportBackup
+func (mr *MockKOTSHandlerMockRecorder) ImportBackup(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportBackup", reflect.TypeOf((*MockKOTSHandler)(nil).ImportBackup), w, r)
+}
+
 // GetVeleroStatus mocks base method
 func (m *MockKOTSHandler) GetVeleroStatus(w http.ResponseWriter, r *http.Request) {
 	m.ctrl.T.Helper()
@@ -1053,6 +1293,18 @@ func (mr *MockKOTSHandlerMockRecorder) GetKurlNodes(w, r interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKurlNodes", reflect.TypeOf((*MockKOTSHandler)(nil).GetKurlNodes), w, r)
 }
 
+// UpgradeAdminConsole mocks base method
+func (m *MockKOTSHandler) UpgradeAdminConsole(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpgradeAdminConsole", w, r)
+}
+
+// UpgradeAdminConsole indicates an expected call of UpgradeAdminConsole
+func (mr *MockKOTSHandlerMockRecorder) UpgradeAdminConsole(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpgradeAdminConsole", reflect.TypeOf((*MockKOTSHandler)(nil).UpgradeAdminConsole), w, r)
+}
+
 // SetPrometheusAddress mocks base method
 func (m *MockKOTSHandler) SetPrometheusAddress(w http.ResponseWriter, r *http.Request) {
 	m.ctrl.T.Helper()
@@ -1089,6 +1341,30 @@ func (mr *MockKOTSHandlerMockRecorder) DisableAppGitOps(w, r interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableAppGitOps", reflect.TypeOf((*MockKOTSHandler)(nil).DisableAppGitOps), w, r)
 }
 
+// UpdateAppGitOpsSecretEncryption mocks base method
+func (m *MockKOTSHandler) UpdateAppGitOpsSecretEncryption(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdateAppGitOpsSecretEncryption", w, r)
+}
+
+// UpdateAppGitOpsSecretEncryption indicates an expected call of UpdateAppGitOpsSecretEncryption
+func (mr *MockKOTSHandlerMockRecorder) UpdateAppGitOpsSecretEncryption(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAppGitOpsSecretEncryption", reflect.TypeOf((*MockKOTSHandler)(nil).UpdateAppGitOpsSecretEncryption), w, r)
+}
+
+// RepushAppGitOps mocks base method
+func (m *MockKOTSHandler) RepushAppGitOps(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RepushAppGitOps", w, r)
+}
+
+// RepushAppGitOps indicates an expected call of RepushAppGitOps
+func (mr *MockKOTSHandlerMockRecorder) RepushAppGitOps(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RepushAppGitOps", reflect.TypeOf((*MockKOTSHandler)(nil).RepushAppGitOps), w, r)
+}
+
 // InitGitOpsConnection mocks base method
 func (m *MockKOTSHandler) InitGitOpsConnection(w http.ResponseWriter, r *http.Request) {
 	m.ctrl.T.Helper()
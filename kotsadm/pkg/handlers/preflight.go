@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 
@@ -14,6 +15,8 @@ import (
 	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
 	"github.com/replicatedhq/kots/pkg/kotsutil"
+	troubleshootpreflight "github.com/replicatedhq/troubleshoot/pkg/preflight"
+	cron "github.com/robfig/cron/v3"
 )
 
 type GetPreflightResultResponse struct {
@@ -277,5 +280,127 @@ func (h *Handler) PostPreflightStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	uploadPreflightResults := troubleshootpreflight.UploadPreflightResults{}
+	if err := json.Unmarshal(b, &uploadPreflightResults); err != nil {
+		logger.Error(errors.Wrap(err, "failed to unmarshal preflight results"))
+	} else if err := preflight.CollectSupportBundleIfFailed(foundApp.ID, sequence, &uploadPreflightResults); err != nil {
+		logger.Error(errors.Wrap(err, "failed to collect support bundle for failed preflight checks"))
+	}
+
 	w.WriteHeader(204)
 }
+
+// PostPreflightCheckStatus route is UNAUTHENTICATED
+// This request comes from the preflight command that the operator runs for a scheduled preflight check.
+func (h *Handler) PostPreflightCheckStatus(w http.ResponseWriter, r *http.Request) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err = errors.Wrap(err, "failed to read request body")
+		logger.Error(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	if err := preflight.SetCheckRunResults(mux.Vars(r)["runId"], b); err != nil {
+		if errors.Cause(err) == preflight.ErrCheckRunCompleted {
+			w.WriteHeader(409)
+			return
+		}
+		err = errors.Wrap(err, "failed to set preflight check results")
+		logger.Error(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+type PreflightCheckPolicyResponse struct {
+	Policy preflighttypes.CheckPolicy `json:"policy"`
+}
+
+type UpdatePreflightCheckPolicyRequest struct {
+	Policy preflighttypes.CheckPolicy `json:"policy"`
+}
+
+type ListPreflightChecksResponse struct {
+	Runs []*preflighttypes.CheckRun `json:"runs"`
+}
+
+func (h *Handler) GetPreflightCheckPolicy(w http.ResponseWriter, r *http.Request) {
+	a, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := PreflightCheckPolicyResponse{}
+	if a.PreflightCheckPolicy != nil {
+		response.Policy = *a.PreflightCheckPolicy
+	}
+
+	JSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) UpdatePreflightCheckPolicy(w http.ResponseWriter, r *http.Request) {
+	request := UpdatePreflightCheckPolicyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if request.Policy.IsEnabled() {
+		if _, err := cron.ParseStandard(request.Policy.Schedule); err != nil {
+			JSON(w, http.StatusBadRequest, NewErrorResponse(errors.Wrap(err, "failed to parse cron spec")))
+			return
+		}
+	}
+	if request.Policy.WebhookURL != "" {
+		u, err := url.Parse(request.Policy.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			JSON(w, http.StatusBadRequest, NewErrorResponse(errors.New("webhook url must be an http or https url")))
+			return
+		}
+	}
+
+	a, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := store.GetStore().SetPreflightCheckPolicy(a.ID, &request.Policy); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := preflight.ConfigureChecks(a.ID); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	JSON(w, http.StatusOK, PreflightCheckPolicyResponse{Policy: request.Policy})
+}
+
+func (h *Handler) ListPreflightChecks(w http.ResponseWriter, r *http.Request) {
+	a, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	runs, err := store.GetStore().ListPreflightCheckRuns(a.ID)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	JSON(w, http.StatusOK, ListPreflightChecksResponse{Runs: runs})
+}
@@ -2,13 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
 	"github.com/replicatedhq/kots/kotsadm/pkg/redact"
 	redacttypes "github.com/replicatedhq/kots/kotsadm/pkg/redact/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle"
+	kotsredacttypes "github.com/replicatedhq/kots/pkg/api/redact/types"
 )
 
 type UpdateRedactRequest struct {
@@ -54,6 +59,17 @@ type PostRedactorEnabledMetadata struct {
 	Enabled bool `json:"enabled"`
 }
 
+type PreviewRedactRequest struct {
+	RedactSpec string `json:"redactSpec"`
+}
+
+type PreviewRedactResponse struct {
+	Preview *kotsredacttypes.RedactPreview `json:"preview,omitempty"`
+
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
 func (h *Handler) UpdateRedact(w http.ResponseWriter, r *http.Request) {
 	updateRedactResponse := UpdateRedactResponse{
 		Success: false,
@@ -252,3 +268,92 @@ func (h *Handler) SetRedactEnabled(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusOK, metadataResponse)
 	return
 }
+
+// PreviewSupportBundleRedact runs a redactor spec against a support bundle without saving the spec
+func (h *Handler) PreviewSupportBundleRedact(w http.ResponseWriter, r *http.Request) {
+	previewRedactResponse := PreviewRedactResponse{
+		Success: false,
+	}
+
+	previewRedactRequest := PreviewRedactRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&previewRedactRequest); err != nil {
+		logger.Error(err)
+		previewRedactResponse.Error = "failed to decode request body"
+		JSON(w, http.StatusBadRequest, previewRedactResponse)
+		return
+	}
+
+	redactor, err := redact.ParseSpec(previewRedactRequest.RedactSpec)
+	if err != nil {
+		logger.Error(err)
+		previewRedactResponse.Error = "failed to parse redact spec"
+		JSON(w, http.StatusBadRequest, previewRedactResponse)
+		return
+	}
+
+	preview, err := supportbundle.PreviewRedactions(mux.Vars(r)["bundleId"], redactor)
+	if err != nil {
+		logger.Error(err)
+		previewRedactResponse.Error = "failed to preview redactions"
+		JSON(w, http.StatusInternalServerError, previewRedactResponse)
+		return
+	}
+
+	previewRedactResponse.Success = true
+	previewRedactResponse.Preview = preview
+	JSON(w, http.StatusOK, previewRedactResponse)
+}
+
+// PreviewRedact runs a redactor spec against an uploaded support bundle without saving the spec or the bundle
+func (h *Handler) PreviewRedact(w http.ResponseWriter, r *http.Request) {
+	previewRedactResponse := PreviewRedactResponse{
+		Success: false,
+	}
+
+	redactor, err := redact.ParseSpec(r.FormValue("redactSpec"))
+	if err != nil {
+		logger.Error(err)
+		previewRedactResponse.Error = "failed to parse redact spec"
+		JSON(w, http.StatusBadRequest, previewRedactResponse)
+		return
+	}
+
+	bundle, _, err := r.FormFile("bundle")
+	if err != nil {
+		logger.Error(err)
+		previewRedactResponse.Error = "failed to read support bundle"
+		JSON(w, http.StatusBadRequest, previewRedactResponse)
+		return
+	}
+	defer bundle.Close()
+
+	tmpFile, err := ioutil.TempFile("", "kotsadm")
+	if err != nil {
+		logger.Error(err)
+		previewRedactResponse.Error = "failed to create temp file"
+		JSON(w, http.StatusInternalServerError, previewRedactResponse)
+		return
+	}
+	defer os.RemoveAll(tmpFile.Name())
+
+	_, err = io.Copy(tmpFile, bundle)
+	tmpFile.Close()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to save support bundle"))
+		previewRedactResponse.Error = "failed to save support bundle"
+		JSON(w, http.StatusInternalServerError, previewRedactResponse)
+		return
+	}
+
+	preview, err := supportbundle.PreviewRedactionsForArchive(tmpFile.Name(), redactor)
+	if err != nil {
+		logger.Error(err)
+		previewRedactResponse.Error = "failed to preview redactions"
+		JSON(w, http.StatusInternalServerError, previewRedactResponse)
+		return
+	}
+
+	previewRedactResponse.Success = true
+	previewRedactResponse.Preview = preview
+	JSON(w, http.StatusOK, previewRedactResponse)
+}
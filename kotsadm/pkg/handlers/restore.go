@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -15,13 +16,22 @@ import (
 	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot"
 	snapshottypes "github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+type CreateApplicationRestoreRequest struct {
+	// Options select what is restored, everything in the backup is restored when empty
+	Options *kotssnapshottypes.RestoreOptions `json:"options,omitempty"`
+	// DryRun returns what would be restored without restoring anything
+	DryRun bool `json:"dryRun"`
+}
+
 type CreateApplicationRestoreResponse struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error,omitempty"`
+	Success bool                           `json:"success"`
+	Error   string                         `json:"error,omitempty"`
+	Plan    *kotssnapshottypes.RestorePlan `json:"plan,omitempty"`
 }
 
 type GetRestoreStatusResponse struct {
@@ -35,6 +45,16 @@ func (h *Handler) CreateApplicationRestore(w http.ResponseWriter, r *http.Reques
 		Success: false,
 	}
 
+	createRestoreRequest := CreateApplicationRestoreRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&createRestoreRequest); err != nil {
+			logger.Error(err)
+			createRestoreResponse.Error = "failed to decode request body"
+			JSON(w, http.StatusBadRequest, createRestoreResponse)
+			return
+		}
+	}
+
 	appSlug := mux.Vars(r)["appSlug"]
 	snapshotName := mux.Vars(r)["snapshotName"]
 
@@ -87,6 +107,21 @@ func (h *Handler) CreateApplicationRestore(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	plan, err := snapshot.PlanApplicationRestore(snapshotName, appSlug, createRestoreRequest.Options)
+	if err != nil {
+		logger.Error(err)
+		createRestoreResponse.Error = errors.Cause(err).Error()
+		JSON(w, http.StatusBadRequest, createRestoreResponse)
+		return
+	}
+
+	if createRestoreRequest.DryRun {
+		createRestoreResponse.Success = true
+		createRestoreResponse.Plan = plan
+		JSON(w, http.StatusOK, createRestoreResponse)
+		return
+	}
+
 	if kotsApp.RestoreInProgressName != "" {
 		err := errors.Errorf("restore is already in progress")
 		logger.Error(err)
@@ -102,7 +137,7 @@ func (h *Handler) CreateApplicationRestore(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.InitiateRestore(snapshotName, kotsApp.ID)
+	err = app.InitiateRestore(snapshotName, kotsApp.ID, createRestoreRequest.Options)
 	if err != nil {
 		logger.Error(err)
 		createRestoreResponse.Error = "failed to initiate restore"
@@ -111,10 +146,109 @@ func (h *Handler) CreateApplicationRestore(w http.ResponseWriter, r *http.Reques
 	}
 
 	createRestoreResponse.Success = true
+	createRestoreResponse.Plan = plan
 
 	JSON(w, http.StatusOK, createRestoreResponse)
 }
 
+type RollbackAndRestoreAppVersionResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// RollbackAndRestoreAppVersion rolls the app back to the given sequence by restoring the snapshot that was taken
+// right before that version was upgraded. The restore redeploys the version from the snapshot once it completes.
+func (h *Handler) RollbackAndRestoreAppVersion(w http.ResponseWriter, r *http.Request) {
+	rollbackAndRestoreResponse := RollbackAndRestoreAppVersionResponse{
+		Success: false,
+	}
+
+	appSlug := mux.Vars(r)["appSlug"]
+	sequence, err := strconv.ParseInt(mux.Vars(r)["sequence"], 10, 64)
+	if err != nil {
+		logger.Error(err)
+		rollbackAndRestoreResponse.Error = "failed to parse sequence"
+		JSON(w, http.StatusBadRequest, rollbackAndRestoreResponse)
+		return
+	}
+
+	a, err := store.GetStore().GetAppFromSlug(appSlug)
+	if err != nil {
+		logger.Error(err)
+		rollbackAndRestoreResponse.Error = "failed to get app"
+		JSON(w, http.StatusInternalServerError, rollbackAndRestoreResponse)
+		return
+	}
+
+	downstreams, err := store.GetStore().ListDownstreamsForApp(a.ID)
+	if err != nil {
+		logger.Error(err)
+		rollbackAndRestoreResponse.Error = "failed to list downstreams for app"
+		JSON(w, http.StatusInternalServerError, rollbackAndRestoreResponse)
+		return
+	} else if len(downstreams) == 0 {
+		rollbackAndRestoreResponse.Error = "no downstreams for app"
+		JSON(w, http.StatusInternalServerError, rollbackAndRestoreResponse)
+		return
+	}
+
+	snapshotName, err := downstream.GetPreUpgradeSnapshotName(a.ID, downstreams[0].ClusterID, sequence)
+	if err != nil {
+		logger.Error(err)
+		rollbackAndRestoreResponse.Error = "failed to get pre-upgrade snapshot"
+		JSON(w, http.StatusInternalServerError, rollbackAndRestoreResponse)
+		return
+	}
+	if snapshotName == "" {
+		rollbackAndRestoreResponse.Error = fmt.Sprintf("sequence %d does not have a pre-upgrade snapshot", sequence)
+		JSON(w, http.StatusBadRequest, rollbackAndRestoreResponse)
+		return
+	}
+
+	backup, err := snapshot.GetBackup(snapshotName)
+	if err != nil {
+		logger.Error(err)
+		rollbackAndRestoreResponse.Error = "failed to find backup"
+		JSON(w, http.StatusInternalServerError, rollbackAndRestoreResponse)
+		return
+	}
+
+	if backup.Status.Phase != velerov1.BackupPhaseCompleted {
+		rollbackAndRestoreResponse.Error = fmt.Sprintf("pre-upgrade snapshot %s is %s", snapshotName, backup.Status.Phase)
+		JSON(w, http.StatusBadRequest, rollbackAndRestoreResponse)
+		return
+	}
+
+	if a.RestoreInProgressName != "" {
+		rollbackAndRestoreResponse.Error = "restore is already in progress"
+		JSON(w, http.StatusBadRequest, rollbackAndRestoreResponse)
+		return
+	}
+
+	if err := snapshot.DeleteRestore(snapshotName); err != nil {
+		logger.Error(err)
+		rollbackAndRestoreResponse.Error = "failed to delete restore"
+		JSON(w, http.StatusInternalServerError, rollbackAndRestoreResponse)
+		return
+	}
+
+	if err := app.InitiateRestore(snapshotName, a.ID, nil); err != nil {
+		logger.Error(err)
+		rollbackAndRestoreResponse.Error = "failed to initiate restore"
+		JSON(w, http.StatusInternalServerError, rollbackAndRestoreResponse)
+		return
+	}
+
+	rollbackAndRestoreResponse.Success = true
+
+	JSON(w, http.StatusOK, rollbackAndRestoreResponse)
+}
+
+type RestoreAppsRequest struct {
+	// Options select what is restored for each app, everything in the backup is restored when empty
+	Options *kotssnapshottypes.RestoreOptions `json:"options,omitempty"`
+}
+
 type RestoreAppsResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error,omitempty"`
@@ -125,6 +259,16 @@ func (h *Handler) RestoreApps(w http.ResponseWriter, r *http.Request) {
 		Success: false,
 	}
 
+	restoreRequest := RestoreAppsRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&restoreRequest); err != nil {
+			logger.Error(err)
+			restoreResponse.Error = "failed to decode request body"
+			JSON(w, http.StatusBadRequest, restoreResponse)
+			return
+		}
+	}
+
 	snapshotName := mux.Vars(r)["snapshotName"]
 
 	backup, err := snapshot.GetBackup(snapshotName)
@@ -167,7 +311,7 @@ func (h *Handler) RestoreApps(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := app.InitiateRestore(snapshotName, a.ID); err != nil {
+		if err := app.InitiateRestore(snapshotName, a.ID, restoreRequest.Options); err != nil {
 			logger.Error(err)
 			restoreResponse.Error = fmt.Sprintf("failed to initiate restore for app %s", a.Slug)
 			JSON(w, http.StatusInternalServerError, restoreResponse)
@@ -15,6 +15,9 @@ import (
 	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot"
 	snapshottypes "github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	"github.com/replicatedhq/kots/pkg/snapshot/encryption"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
+	"github.com/replicatedhq/kots/pkg/util"
 	"github.com/robfig/cron"
 	"k8s.io/apimachinery/pkg/util/rand"
 )
@@ -27,6 +30,9 @@ type GlobalSnapshotSettingsResponse struct {
 	IsResticRunning bool     `json:"isResticRunning"`
 	IsKurl          bool     `json:"isKurl"`
 
+	// IsMetadataEncrypted is false when only the volume data of an encrypted store is encrypted
+	IsMetadataEncrypted bool `json:"isMetadataEncrypted"`
+
 	Store   *snapshottypes.Store `json:"store,omitempty"`
 	Success bool                 `json:"success"`
 	Error   string               `json:"error,omitempty"`
@@ -37,17 +43,25 @@ type UpdateGlobalSnapshotSettingsRequest struct {
 	Bucket   string `json:"bucket"`
 	Path     string `json:"path"`
 
-	AWS      *snapshottypes.StoreAWS    `json:"aws"`
-	Google   *snapshottypes.StoreGoogle `json:"gcp"`
-	Azure    *snapshottypes.StoreAzure  `json:"azure"`
-	Other    *snapshottypes.StoreOther  `json:"other"`
-	Internal bool                       `json:"internal"`
+	AWS      *snapshottypes.StoreAWS      `json:"aws"`
+	Google   *snapshottypes.StoreGoogle   `json:"gcp"`
+	Azure    *snapshottypes.StoreAzure    `json:"azure"`
+	Other    *snapshottypes.StoreOther    `json:"other"`
+	Internal bool                         `json:"internal"`
+	NFS      *snapshottypes.StoreNFS      `json:"nfs"`
+	HostPath *snapshottypes.StoreHostPath `json:"hostPath"`
+
+	// Encryption is kept when nil and removed when empty
+	Encryption *encryption.Key `json:"encryption"`
 }
 
 type SnapshotConfig struct {
-	AutoEnabled  bool                            `json:"autoEnabled"`
-	AutoSchedule *snapshottypes.SnapshotSchedule `json:"autoSchedule"`
-	TTl          *snapshottypes.SnapshotTTL      `json:"ttl"`
+	AutoEnabled        bool                            `json:"autoEnabled"`
+	AutoSchedule       *snapshottypes.SnapshotSchedule `json:"autoSchedule"`
+	TTl                *snapshottypes.SnapshotTTL      `json:"ttl"`
+	PreUpgradeSnapshot string                          `json:"preUpgradeSnapshot"`
+	// RetentionPolicy prunes scheduled snapshots instead of the ttl when set
+	RetentionPolicy *kotssnapshottypes.RetentionPolicy `json:"retentionPolicy,omitempty"`
 }
 
 type VeleroStatus struct {
@@ -106,6 +120,8 @@ func (h *Handler) UpdateGlobalSnapshotSettings(w http.ResponseWriter, r *http.Re
 		store.Google = nil
 		store.Other = nil
 		store.Internal = nil
+		store.NFS = nil
+		store.HostPath = nil
 
 		store.AWS.UseInstanceRole = updateGlobalSnapshotSettingsRequest.AWS.UseInstanceRole
 		if store.AWS.UseInstanceRole {
@@ -144,6 +160,8 @@ func (h *Handler) UpdateGlobalSnapshotSettings(w http.ResponseWriter, r *http.Re
 		store.Azure = nil
 		store.Other = nil
 		store.Internal = nil
+		store.NFS = nil
+		store.HostPath = nil
 
 		store.Google.UseInstanceRole = updateGlobalSnapshotSettingsRequest.Google.UseInstanceRole
 		if store.Google.UseInstanceRole {
@@ -185,6 +203,8 @@ func (h *Handler) UpdateGlobalSnapshotSettings(w http.ResponseWriter, r *http.Re
 		store.Google = nil
 		store.Other = nil
 		store.Internal = nil
+		store.NFS = nil
+		store.HostPath = nil
 
 		if updateGlobalSnapshotSettingsRequest.Azure.ResourceGroup != "" {
 			store.Azure.ResourceGroup = updateGlobalSnapshotSettingsRequest.Azure.ResourceGroup
@@ -222,6 +242,8 @@ func (h *Handler) UpdateGlobalSnapshotSettings(w http.ResponseWriter, r *http.Re
 		store.Google = nil
 		store.Azure = nil
 		store.Internal = nil
+		store.NFS = nil
+		store.HostPath = nil
 
 		store.Provider = "aws"
 		if updateGlobalSnapshotSettingsRequest.Other.AccessKeyID != "" {
@@ -262,6 +284,8 @@ func (h *Handler) UpdateGlobalSnapshotSettings(w http.ResponseWriter, r *http.Re
 		store.Google = nil
 		store.Azure = nil
 		store.Other = nil
+		store.NFS = nil
+		store.HostPath = nil
 
 		secret, err := kurl.GetS3Secret()
 		if err != nil {
@@ -286,6 +310,86 @@ func (h *Handler) UpdateGlobalSnapshotSettings(w http.ResponseWriter, r *http.Re
 		store.Internal.Endpoint = string(secret.Data["endpoint"])
 		store.Internal.ObjectStoreClusterIP = string(secret.Data["object-store-cluster-ip"])
 		store.Internal.Region = "us-east-1"
+	} else if updateGlobalSnapshotSettingsRequest.NFS != nil {
+		if updateGlobalSnapshotSettingsRequest.NFS.Server == "" || updateGlobalSnapshotSettingsRequest.NFS.Path == "" {
+			globalSnapshotSettingsResponse.Error = "nfs server and path are required"
+			JSON(w, 400, globalSnapshotSettingsResponse)
+			return
+		}
+
+		store.AWS = nil
+		store.Google = nil
+		store.Azure = nil
+		store.Other = nil
+		store.Internal = nil
+		store.HostPath = nil
+
+		store.NFS = &snapshottypes.StoreNFS{
+			Server: updateGlobalSnapshotSettingsRequest.NFS.Server,
+			Path:   updateGlobalSnapshotSettingsRequest.NFS.Path,
+		}
+
+		if err := snapshot.DeployFileSystemMinio(store); err != nil {
+			logger.Error(err)
+			globalSnapshotSettingsResponse.Error = errors.Cause(err).Error()
+			if _, ok := errors.Cause(err).(util.ActionableError); ok {
+				JSON(w, 400, globalSnapshotSettingsResponse)
+				return
+			}
+			JSON(w, 500, globalSnapshotSettingsResponse)
+			return
+		}
+	} else if updateGlobalSnapshotSettingsRequest.HostPath != nil {
+		if updateGlobalSnapshotSettingsRequest.HostPath.Path == "" {
+			globalSnapshotSettingsResponse.Error = "host path is required"
+			JSON(w, 400, globalSnapshotSettingsResponse)
+			return
+		}
+
+		store.AWS = nil
+		store.Google = nil
+		store.Azure = nil
+		store.Other = nil
+		store.Internal = nil
+		store.NFS = nil
+
+		store.HostPath = &snapshottypes.StoreHostPath{
+			Path: updateGlobalSnapshotSettingsRequest.HostPath.Path,
+			Node: updateGlobalSnapshotSettingsRequest.HostPath.Node,
+		}
+
+		if err := snapshot.DeployFileSystemMinio(store); err != nil {
+			logger.Error(err)
+			globalSnapshotSettingsResponse.Error = errors.Cause(err).Error()
+			if _, ok := errors.Cause(err).(util.ActionableError); ok {
+				JSON(w, 400, globalSnapshotSettingsResponse)
+				return
+			}
+			JSON(w, 500, globalSnapshotSettingsResponse)
+			return
+		}
+	}
+
+	if updateGlobalSnapshotSettingsRequest.Encryption != nil {
+		requestEncryption := updateGlobalSnapshotSettingsRequest.Encryption
+		if requestEncryption.Type() == "" {
+			store.Encryption = nil
+		} else {
+			if strings.Contains(requestEncryption.Passphrase, "REDACTED") {
+				if store.Encryption == nil || store.Encryption.Passphrase == "" {
+					globalSnapshotSettingsResponse.Error = "invalid encryption passphrase"
+					JSON(w, 400, globalSnapshotSettingsResponse)
+					return
+				}
+				requestEncryption.Passphrase = store.Encryption.Passphrase
+			}
+			if err := requestEncryption.Validate(); err != nil {
+				globalSnapshotSettingsResponse.Error = err.Error()
+				JSON(w, 400, globalSnapshotSettingsResponse)
+				return
+			}
+			store.Encryption = requestEncryption
+		}
 	}
 
 	if err := snapshot.ValidateStore(store); err != nil {
@@ -334,6 +438,7 @@ func (h *Handler) UpdateGlobalSnapshotSettings(w http.ResponseWriter, r *http.Re
 	}
 
 	globalSnapshotSettingsResponse.Store = updatedStore
+	globalSnapshotSettingsResponse.IsMetadataEncrypted = snapshot.IsMetadataEncrypted(updatedStore)
 	globalSnapshotSettingsResponse.Success = true
 
 	JSON(w, 200, globalSnapshotSettingsResponse)
@@ -379,6 +484,7 @@ func (h *Handler) GetGlobalSnapshotSettings(w http.ResponseWriter, r *http.Reque
 	}
 
 	globalSnapshotSettingsResponse.Store = store
+	globalSnapshotSettingsResponse.IsMetadataEncrypted = snapshot.IsMetadataEncrypted(store)
 	globalSnapshotSettingsResponse.Success = true
 
 	JSON(w, 200, globalSnapshotSettingsResponse)
@@ -422,6 +528,8 @@ func (h *Handler) GetSnapshotConfig(w http.ResponseWriter, r *http.Request) {
 	getSnapshotConfigResponse.AutoEnabled = foundApp.SnapshotSchedule != ""
 	getSnapshotConfigResponse.AutoSchedule = snapshotSchedule
 	getSnapshotConfigResponse.TTl = ttl
+	getSnapshotConfigResponse.PreUpgradeSnapshot = foundApp.PreUpgradeSnapshot
+	getSnapshotConfigResponse.RetentionPolicy = foundApp.SnapshotRetentionPolicy
 
 	JSON(w, http.StatusOK, getSnapshotConfigResponse)
 }
@@ -448,11 +556,14 @@ func (h *Handler) GetVeleroStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 type SaveSnapshotConfigRequest struct {
-	AppID         string `json:"appId"`
-	InputValue    string `json:"inputValue"`
-	InputTimeUnit string `json:"inputTimeUnit"`
-	Schedule      string `json:"schedule"`
-	AutoEnabled   bool   `json:"autoEnabled"`
+	AppID              string `json:"appId"`
+	InputValue         string `json:"inputValue"`
+	InputTimeUnit      string `json:"inputTimeUnit"`
+	Schedule           string `json:"schedule"`
+	AutoEnabled        bool   `json:"autoEnabled"`
+	PreUpgradeSnapshot string `json:"preUpgradeSnapshot"`
+	// RetentionPolicy replaces the ttl of scheduled snapshots, it is left unchanged when absent and cleared when empty
+	RetentionPolicy *kotssnapshottypes.RetentionPolicy `json:"retentionPolicy"`
 }
 
 type SaveSnapshotConfigResponse struct {
@@ -478,6 +589,14 @@ func (h *Handler) SaveSnapshotConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch requestBody.PreUpgradeSnapshot {
+	case "", snapshottypes.PreUpgradeSnapshotApplication, snapshottypes.PreUpgradeSnapshotInstance:
+	default:
+		responseBody.Error = fmt.Sprintf("Invalid pre-upgrade snapshot type: %s", requestBody.PreUpgradeSnapshot)
+		JSON(w, http.StatusBadRequest, responseBody)
+		return
+	}
+
 	retention, err := snapshot.FormatTTL(requestBody.InputValue, requestBody.InputTimeUnit)
 	if err != nil {
 		logger.Error(err)
@@ -486,6 +605,12 @@ func (h *Handler) SaveSnapshotConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateRetentionPolicy(requestBody.RetentionPolicy); err != nil {
+		responseBody.Error = err.Error()
+		JSON(w, http.StatusBadRequest, responseBody)
+		return
+	}
+
 	if app.SnapshotTTL != retention {
 		app.SnapshotTTL = retention
 		if err := store.GetStore().SetSnapshotTTL(app.ID, retention); err != nil {
@@ -496,6 +621,24 @@ func (h *Handler) SaveSnapshotConfig(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if requestBody.RetentionPolicy != nil {
+		if err := store.GetStore().SetSnapshotRetentionPolicy(app.ID, requestBody.RetentionPolicy); err != nil {
+			logger.Error(err)
+			responseBody.Error = "Failed to set snapshot retention policy"
+			JSON(w, http.StatusInternalServerError, responseBody)
+			return
+		}
+	}
+
+	if app.PreUpgradeSnapshot != requestBody.PreUpgradeSnapshot {
+		if err := store.GetStore().SetPreUpgradeSnapshot(app.ID, requestBody.PreUpgradeSnapshot); err != nil {
+			logger.Error(err)
+			responseBody.Error = "Failed to set pre-upgrade snapshot"
+			JSON(w, http.StatusInternalServerError, responseBody)
+			return
+		}
+	}
+
 	if !requestBody.AutoEnabled {
 		if err := store.GetStore().SetSnapshotSchedule(app.ID, ""); err != nil {
 			logger.Error(err)
@@ -553,6 +696,8 @@ type InstanceSnapshotConfig struct {
 	AutoEnabled  bool                            `json:"autoEnabled"`
 	AutoSchedule *snapshottypes.SnapshotSchedule `json:"autoSchedule"`
 	TTl          *snapshottypes.SnapshotTTL      `json:"ttl"`
+	// RetentionPolicy prunes scheduled instance snapshots instead of the ttl when set
+	RetentionPolicy *kotssnapshottypes.RetentionPolicy `json:"retentionPolicy,omitempty"`
 }
 
 func (h *Handler) GetInstanceSnapshotConfig(w http.ResponseWriter, r *http.Request) {
@@ -598,6 +743,7 @@ func (h *Handler) GetInstanceSnapshotConfig(w http.ResponseWriter, r *http.Reque
 	getInstanceSnapshotConfigResponse.AutoEnabled = c.SnapshotSchedule != ""
 	getInstanceSnapshotConfigResponse.AutoSchedule = snapshotSchedule
 	getInstanceSnapshotConfigResponse.TTl = ttl
+	getInstanceSnapshotConfigResponse.RetentionPolicy = c.SnapshotRetentionPolicy
 
 	JSON(w, http.StatusOK, getInstanceSnapshotConfigResponse)
 }
@@ -607,6 +753,8 @@ type SaveInstanceSnapshotConfigRequest struct {
 	InputTimeUnit string `json:"inputTimeUnit"`
 	Schedule      string `json:"schedule"`
 	AutoEnabled   bool   `json:"autoEnabled"`
+	// RetentionPolicy replaces the ttl of scheduled instance snapshots, it is left unchanged when absent and cleared when empty
+	RetentionPolicy *kotssnapshottypes.RetentionPolicy `json:"retentionPolicy"`
 }
 
 type SaveInstanceSnapshotConfigResponse struct {
@@ -648,6 +796,12 @@ func (h *Handler) SaveInstanceSnapshotConfig(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if err := validateRetentionPolicy(requestBody.RetentionPolicy); err != nil {
+		responseBody.Error = err.Error()
+		JSON(w, http.StatusBadRequest, responseBody)
+		return
+	}
+
 	if c.SnapshotTTL != retention {
 		c.SnapshotTTL = retention
 		if err := store.GetStore().SetInstanceSnapshotTTL(c.ClusterID, retention); err != nil {
@@ -658,6 +812,15 @@ func (h *Handler) SaveInstanceSnapshotConfig(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	if requestBody.RetentionPolicy != nil {
+		if err := store.GetStore().SetInstanceSnapshotRetentionPolicy(c.ClusterID, requestBody.RetentionPolicy); err != nil {
+			logger.Error(err)
+			responseBody.Error = "Failed to set instance snapshot retention policy"
+			JSON(w, http.StatusInternalServerError, responseBody)
+			return
+		}
+	}
+
 	if !requestBody.AutoEnabled {
 		if err := store.GetStore().SetInstanceSnapshotSchedule(c.ClusterID, ""); err != nil {
 			logger.Error(err)
@@ -710,3 +873,13 @@ func (h *Handler) SaveInstanceSnapshotConfig(w http.ResponseWriter, r *http.Requ
 	responseBody.Success = true
 	JSON(w, http.StatusOK, responseBody)
 }
+
+func validateRetentionPolicy(retentionPolicy *kotssnapshottypes.RetentionPolicy) error {
+	if retentionPolicy == nil {
+		return nil
+	}
+	if retentionPolicy.Hourly < 0 || retentionPolicy.Daily < 0 || retentionPolicy.Weekly < 0 || retentionPolicy.Monthly < 0 {
+		return errors.New("Invalid snapshot retention policy: the number of snapshots to keep must not be negative")
+	}
+	return nil
+}
@@ -40,6 +40,14 @@ type GetSupportBundleResponse struct {
 	UploadedAt *time.Time                   `json:"uploadedAt"`
 	IsArchived bool                         `json:"isArchived"`
 	Analysis   *types.SupportBundleAnalysis `json:"analysis"`
+	Sequence   *int64                       `json:"sequence,omitempty"`
+	Reason     string                       `json:"reason,omitempty"`
+	PushStatus string                       `json:"pushStatus,omitempty"`
+	PushError  string                       `json:"pushError,omitempty"`
+	PushedAt   *time.Time                   `json:"pushedAt,omitempty"`
+	// IsPartial is set when some collectors of the bundle failed or timed out
+	IsPartial        bool     `json:"isPartial"`
+	FailedCollectors []string `json:"failedCollectors,omitempty"`
 }
 
 type GetSupportBundleFilesResponse struct {
@@ -63,6 +71,14 @@ type ResponseSupportBundle struct {
 	UploadedAt *time.Time                   `json:"uploadedAt"`
 	IsArchived bool                         `json:"isArchived"`
 	Analysis   *types.SupportBundleAnalysis `json:"analysis"`
+	Sequence   *int64                       `json:"sequence,omitempty"`
+	Reason     string                       `json:"reason,omitempty"`
+	PushStatus string                       `json:"pushStatus,omitempty"`
+	PushError  string                       `json:"pushError,omitempty"`
+	PushedAt   *time.Time                   `json:"pushedAt,omitempty"`
+	// IsPartial is set when some collectors of the bundle failed or timed out
+	IsPartial        bool     `json:"isPartial"`
+	FailedCollectors []string `json:"failedCollectors,omitempty"`
 }
 
 type GetSupportBundleCommandRequest struct {
@@ -80,6 +96,28 @@ type GetSupportBundleRedactionsResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+type ListSupportBundleCollectionsResponse struct {
+	Collections []*types.Collection `json:"collections"`
+}
+
+type SupportBundlePolicyResponse struct {
+	Policy types.AutoCollectPolicy `json:"policy"`
+}
+
+type UpdateSupportBundlePolicyRequest struct {
+	Policy types.AutoCollectPolicy `json:"policy"`
+}
+
+type SupportBundleDestinationResponse struct {
+	// Destination is nil when the app has no destination, its credentials are masked
+	Destination *types.Destination `json:"destination"`
+}
+
+type UpdateSupportBundleDestinationRequest struct {
+	// Destination removes the destination of the app when it's nil
+	Destination *types.Destination `json:"destination"`
+}
+
 type PutSupportBundleRedactions struct {
 	Redactions redact2.RedactionList `json:"redactions"`
 }
@@ -100,17 +138,24 @@ func (h *Handler) GetSupportBundle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	getSupportBundleResponse := GetSupportBundleResponse{
-		ID:         bundle.ID,
-		Slug:       bundle.Slug,
-		AppID:      bundle.AppID,
-		Name:       bundle.Name,
-		Size:       bundle.Size,
-		Status:     bundle.Status,
-		TreeIndex:  bundle.TreeIndex,
-		CreatedAt:  bundle.CreatedAt,
-		UploadedAt: bundle.UploadedAt,
-		IsArchived: bundle.IsArchived,
-		Analysis:   analysis,
+		ID:               bundle.ID,
+		Slug:             bundle.Slug,
+		AppID:            bundle.AppID,
+		Name:             bundle.Name,
+		Size:             bundle.Size,
+		Status:           bundle.Status,
+		TreeIndex:        bundle.TreeIndex,
+		CreatedAt:        bundle.CreatedAt,
+		UploadedAt:       bundle.UploadedAt,
+		IsArchived:       bundle.IsArchived,
+		Analysis:         analysis,
+		Sequence:         bundle.Sequence,
+		Reason:           bundle.Reason,
+		PushStatus:       bundle.PushStatus,
+		PushError:        bundle.PushError,
+		PushedAt:         bundle.PushedAt,
+		IsPartial:        bundle.IsPartial,
+		FailedCollectors: bundle.FailedCollectors,
 	}
 
 	JSON(w, http.StatusOK, getSupportBundleResponse)
@@ -163,16 +208,23 @@ func (h *Handler) ListSupportBundles(w http.ResponseWriter, r *http.Request) {
 		}
 
 		responseSupportBundle := ResponseSupportBundle{
-			ID:         bundle.ID,
-			Slug:       bundle.Slug,
-			AppID:      bundle.AppID,
-			Name:       bundle.Name,
-			Size:       bundle.Size,
-			Status:     bundle.Status,
-			CreatedAt:  bundle.CreatedAt,
-			UploadedAt: bundle.UploadedAt,
-			IsArchived: bundle.IsArchived,
-			Analysis:   analysis,
+			ID:               bundle.ID,
+			Slug:             bundle.Slug,
+			AppID:            bundle.AppID,
+			Name:             bundle.Name,
+			Size:             bundle.Size,
+			Status:           bundle.Status,
+			CreatedAt:        bundle.CreatedAt,
+			UploadedAt:       bundle.UploadedAt,
+			IsArchived:       bundle.IsArchived,
+			Analysis:         analysis,
+			Sequence:         bundle.Sequence,
+			Reason:           bundle.Reason,
+			PushStatus:       bundle.PushStatus,
+			PushError:        bundle.PushError,
+			PushedAt:         bundle.PushedAt,
+			IsPartial:        bundle.IsPartial,
+			FailedCollectors: bundle.FailedCollectors,
 		}
 
 		responseSupportBundles = append(responseSupportBundles, responseSupportBundle)
@@ -292,6 +344,172 @@ func (h *Handler) CollectSupportBundle(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusNoContent, "")
 }
 
+func (h *Handler) GetSupportBundlePolicy(w http.ResponseWriter, r *http.Request) {
+	a, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := SupportBundlePolicyResponse{}
+	if a.SupportBundlePolicy != nil {
+		response.Policy = *a.SupportBundlePolicy
+	}
+
+	JSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) UpdateSupportBundlePolicy(w http.ResponseWriter, r *http.Request) {
+	request := UpdateSupportBundlePolicyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if request.Policy.UnavailableMinutes < 0 {
+		JSON(w, http.StatusBadRequest, NewErrorResponse(errors.New("unavailable minutes cannot be negative")))
+		return
+	}
+
+	a, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := store.GetStore().SetSupportBundlePolicy(a.ID, &request.Policy); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	JSON(w, http.StatusOK, SupportBundlePolicyResponse{Policy: request.Policy})
+}
+
+func (h *Handler) GetSupportBundleDestination(w http.ResponseWriter, r *http.Request) {
+	a, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	destination, err := store.GetStore().GetSupportBundleDestination(a.ID)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := SupportBundleDestinationResponse{}
+	if destination != nil {
+		response.Destination = destination.Masked()
+	}
+
+	JSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) UpdateSupportBundleDestination(w http.ResponseWriter, r *http.Request) {
+	request := UpdateSupportBundleDestinationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	a, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if request.Destination != nil {
+		currentDestination, err := store.GetStore().GetSupportBundleDestination(a.ID)
+		if err != nil {
+			logger.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		request.Destination.KeepSecrets(currentDestination)
+
+		if err := request.Destination.Validate(); err != nil {
+			JSON(w, http.StatusBadRequest, NewErrorResponse(err))
+			return
+		}
+	}
+
+	if err := store.GetStore().SetSupportBundleDestination(a.ID, request.Destination); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := SupportBundleDestinationResponse{}
+	if request.Destination != nil {
+		response.Destination = request.Destination.Masked()
+	}
+
+	JSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) PushSupportBundle(w http.ResponseWriter, r *http.Request) {
+	err := supportbundle.Push(mux.Vars(r)["bundleId"])
+	if errors.Cause(err) == supportbundle.ErrNoDestination {
+		JSON(w, http.StatusBadRequest, NewErrorResponse(err))
+		return
+	}
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	JSON(w, http.StatusAccepted, "")
+}
+
+func (h *Handler) CompareSupportBundles(w http.ResponseWriter, r *http.Request) {
+	baseBundle, err := store.GetStore().GetSupportBundle(mux.Vars(r)["bundleId"])
+	if err != nil {
+		if store.GetStore().IsNotFound(err) {
+			JSON(w, http.StatusNotFound, NewErrorResponse(errors.New("support bundle not found")))
+			return
+		}
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	targetBundle, err := store.GetStore().GetSupportBundle(mux.Vars(r)["targetBundleId"])
+	if err != nil {
+		if store.GetStore().IsNotFound(err) {
+			JSON(w, http.StatusNotFound, NewErrorResponse(errors.New("target support bundle not found")))
+			return
+		}
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// access is checked against the app of the base bundle
+	if targetBundle.AppID != baseBundle.AppID {
+		JSON(w, http.StatusBadRequest, NewErrorResponse(errors.New("support bundles must belong to the same app")))
+		return
+	}
+
+	comparison, err := supportbundle.CompareBundles(baseBundle.ID, targetBundle.ID)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	JSON(w, http.StatusOK, comparison)
+}
+
 // UploadSupportBundle route is UNAUTHENTICATED
 // This request comes from the `kubectl support-bundle` command.
 func (h *Handler) UploadSupportBundle(w http.ResponseWriter, r *http.Request) {
@@ -469,3 +687,69 @@ func (h *Handler) SetSupportBundleRedactions(w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusCreated)
 	return
 }
+
+func (h *Handler) ListSupportBundleCollections(w http.ResponseWriter, r *http.Request) {
+	a, err := store.GetStore().GetAppFromSlug(mux.Vars(r)["appSlug"])
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	collections, err := supportbundle.ListCollections(a.ID)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	JSON(w, http.StatusOK, ListSupportBundleCollectionsResponse{Collections: collections})
+}
+
+// NOTE: this uses special cluster authorization
+func (h *Handler) UpdateSupportBundleProgress(w http.ResponseWriter, r *http.Request) {
+	auth, err := parseClusterAuthorization(r.Header.Get("Authorization"))
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	clusterID, err := store.GetStore().GetClusterIDFromDeployToken(auth.Password)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	event := types.CollectionEvent{}
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	bundleID := mux.Vars(r)["bundleId"]
+	collection, err := store.GetStore().GetSupportBundleCollection(bundleID)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if collection == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if collection.ClusterID != clusterID {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if err := supportbundle.UpdateCollection(bundleID, event); err != nil {
+		logger.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
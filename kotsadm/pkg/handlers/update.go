@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -13,8 +14,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/airgap"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
 	"github.com/replicatedhq/kots/kotsadm/pkg/updatechecker"
+	"github.com/replicatedhq/kots/kotsadm/pkg/version"
 	"github.com/replicatedhq/kots/pkg/util"
 )
 
@@ -118,9 +121,21 @@ func (h *Handler) AppUpdateCheck(w http.ResponseWriter, r *http.Request) {
 		err = airgap.UpdateAppFromPath(foundApp, rootDir, deploy, skipPreflights)
 		if err != nil {
 			logger.Error(errors.Wrap(err, "failed to upgrde app"))
-			w.WriteHeader(http.StatusInternalServerError)
 
 			cause := errors.Cause(err)
+			if closedErr, ok := cause.(maintenancewindow.ClosedError); ok {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(fmt.Sprintf("The update was uploaded but not deployed: %s. Deploy it from the admin console once the window opens, or override the maintenance windows.", closedErr.Error())))
+				return
+			}
+
+			if _, ok := cause.(version.SkippedRequiredVersionsError); ok {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(fmt.Sprintf("The update was uploaded but not deployed: %s", cause.Error())))
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
 			if _, ok := cause.(util.ActionableError); ok {
 				w.Write([]byte(cause.Error()))
 			}
@@ -0,0 +1,41 @@
+package healthverifier
+
+import (
+	"fmt"
+	"time"
+
+	appstatustypes "github.com/replicatedhq/kots/pkg/api/appstatus/types"
+)
+
+type outcome string
+
+const (
+	outcomePending  outcome = "pending"
+	outcomeVerified outcome = "verified"
+	outcomeFailed   outcome = "failed"
+)
+
+// evaluate decides the outcome of a health verification that started at startedAt and lasts for timeout.
+// The operator only reports app status when it changes, so an app that was already ready before the deploy
+// and stayed ready is only verified once the timeout has passed.
+func evaluate(appStatus *appstatustypes.AppStatus, startedAt time.Time, timeout time.Duration, now time.Time) (outcome, string) {
+	reportedSinceStart := appStatus.UpdatedAt.After(startedAt)
+
+	if reportedSinceStart && appStatus.State == appstatustypes.StateReady {
+		return outcomeVerified, ""
+	}
+
+	if reportedSinceStart && appStatus.State == appstatustypes.StateUnavailable {
+		return outcomeFailed, "app became unavailable during health verification"
+	}
+
+	if now.Before(startedAt.Add(timeout)) {
+		return outcomePending, ""
+	}
+
+	if appStatus.State == appstatustypes.StateReady {
+		return outcomeVerified, ""
+	}
+
+	return outcomeFailed, fmt.Sprintf("app did not become ready within %s of deploying, state is %s", timeout, appStatus.State)
+}
@@ -0,0 +1,98 @@
+package healthverifier
+
+import (
+	"testing"
+	"time"
+
+	appstatustypes "github.com/replicatedhq/kots/pkg/api/appstatus/types"
+)
+
+func TestEvaluate(t *testing.T) {
+	startedAt := time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)
+	timeout := 5 * time.Minute
+
+	before := startedAt.Add(-time.Hour)
+	after := startedAt.Add(time.Minute)
+	duringTimeout := startedAt.Add(2 * time.Minute)
+	pastTimeout := startedAt.Add(10 * time.Minute)
+
+	tests := []struct {
+		name      string
+		state     appstatustypes.State
+		updatedAt time.Time
+		now       time.Time
+		want      outcome
+	}{
+		{
+			name:      "became ready after deploy",
+			state:     appstatustypes.StateReady,
+			updatedAt: after,
+			now:       duringTimeout,
+			want:      outcomeVerified,
+		},
+		{
+			name:      "ready before deploy, still waiting",
+			state:     appstatustypes.StateReady,
+			updatedAt: before,
+			now:       duringTimeout,
+			want:      outcomePending,
+		},
+		{
+			name:      "ready before deploy and stayed ready",
+			state:     appstatustypes.StateReady,
+			updatedAt: before,
+			now:       pastTimeout,
+			want:      outcomeVerified,
+		},
+		{
+			name:      "degraded, still waiting",
+			state:     appstatustypes.StateDegraded,
+			updatedAt: after,
+			now:       duringTimeout,
+			want:      outcomePending,
+		},
+		{
+			name:      "degraded past timeout",
+			state:     appstatustypes.StateDegraded,
+			updatedAt: after,
+			now:       pastTimeout,
+			want:      outcomeFailed,
+		},
+		{
+			name:      "became unavailable after deploy",
+			state:     appstatustypes.StateUnavailable,
+			updatedAt: after,
+			now:       duringTimeout,
+			want:      outcomeFailed,
+		},
+		{
+			name:      "unavailable before deploy, still waiting",
+			state:     appstatustypes.StateUnavailable,
+			updatedAt: before,
+			now:       duringTimeout,
+			want:      outcomePending,
+		},
+		{
+			name:  "never reported",
+			state: appstatustypes.StateMissing,
+			now:   pastTimeout,
+			want:  outcomeFailed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			appStatus := &appstatustypes.AppStatus{
+				State:     test.state,
+				UpdatedAt: test.updatedAt,
+			}
+			got, reason := evaluate(appStatus, startedAt, timeout, test.now)
+			if got != test.want {
+				t.Errorf("Expected %s, got %s", test.want, got)
+			}
+			if got == outcomeFailed && reason == "" {
+				t.Error("Expected a reason for the failure")
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package healthverifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/autosupportbundle"
+	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/socketservice"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	downstreamtypes "github.com/replicatedhq/kots/pkg/api/downstream/types"
+)
+
+func Start() error {
+	logger.Debug("starting health verifier")
+
+	startLoop(verifyLoop, 10)
+
+	return nil
+}
+
+func startLoop(fn func(), intervalInSeconds time.Duration) {
+	go func() {
+		for {
+			fn()
+			time.Sleep(time.Second * intervalInSeconds)
+		}
+	}()
+}
+
+// StartVerification starts the post-deploy health verification for the current version of the app,
+// if the application spec for that version asks for one
+func StartVerification(appID string, clusterID string, sequence int64) error {
+	parentSequence, err := downstream.GetParentSequenceForSequence(appID, clusterID, sequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to get parent sequence")
+	}
+
+	kotsApp, err := getKotsApplication(appID, parentSequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to get application spec")
+	}
+
+	if kotsApp.Spec.HealthVerificationSeconds <= 0 {
+		return nil
+	}
+
+	if err := downstream.StartHealthVerification(appID, clusterID, sequence); err != nil {
+		return errors.Wrap(err, "failed to start health verification")
+	}
+
+	return nil
+}
+
+func verifyLoop() {
+	verifications, err := downstream.ListHealthVerifications()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to list health verifications"))
+		return
+	}
+
+	for _, v := range verifications {
+		if err := verify(v); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to verify health of app %s sequence %d", v.AppID, v.Sequence))
+		}
+	}
+}
+
+func verify(v downstreamtypes.HealthVerification) error {
+	currentSequence, err := downstream.GetCurrentSequence(v.AppID, v.ClusterID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get current sequence")
+	}
+
+	// another version was deployed in the meantime, this one can no longer be verified
+	if currentSequence != v.Sequence {
+		return downstream.EndHealthVerification(v.AppID, v.ClusterID, v.Sequence)
+	}
+
+	kotsApp, err := getKotsApplication(v.AppID, v.ParentSequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to get application spec")
+	}
+
+	appStatus, err := store.GetStore().GetAppStatus(v.AppID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app status")
+	}
+
+	timeout := time.Duration(kotsApp.Spec.HealthVerificationSeconds) * time.Second
+	result, reason := evaluate(appStatus, v.StartedAt, timeout, time.Now())
+
+	switch result {
+	case outcomeVerified:
+		logger.Infof("app %s sequence %d passed health verification", v.AppID, v.Sequence)
+		return downstream.EndHealthVerification(v.AppID, v.ClusterID, v.Sequence)
+
+	case outcomeFailed:
+		logger.Infof("app %s sequence %d failed health verification: %s", v.AppID, v.Sequence, reason)
+		message := fmt.Sprintf("Health verification failed: %s", reason)
+		if err := downstream.AppendDownstreamDeployError(v.AppID, v.ClusterID, v.Sequence, message); err != nil {
+			return errors.Wrap(err, "failed to update downstream deploy status")
+		}
+		// the reason is in the deploy log, status info would show it a second time as a render error
+		if err := downstream.UpdateDownstreamStatus(v.AppID, v.Sequence, "failed", ""); err != nil {
+			return errors.Wrap(err, "failed to update downstream status")
+		}
+		if err := downstream.EndHealthVerification(v.AppID, v.ClusterID, v.Sequence); err != nil {
+			return errors.Wrap(err, "failed to end health verification")
+		}
+		if err := autosupportbundle.DeployFailed(v.AppID, v.ClusterID, v.Sequence); err != nil {
+			logger.Error(errors.Wrap(err, "failed to collect support bundle for failed health verification"))
+		}
+		if !kotsApp.Spec.AllowRollback {
+			return nil
+		}
+		if err := rollback(v); err != nil {
+			return errors.Wrap(err, "failed to roll back")
+		}
+	}
+
+	return nil
+}
+
+func rollback(v downstreamtypes.HealthVerification) error {
+	previousSequence, err := downstream.GetPreviouslyDeployedSequence(v.AppID, v.ClusterID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get previously deployed sequence")
+	}
+	if previousSequence == -1 {
+		return nil
+	}
+
+	// never roll back onto a version that failed itself, that would flip between two broken versions forever
+	previousStatus, err := downstream.GetDownstreamVersionStatus(v.AppID, previousSequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to get previous version status")
+	}
+	if previousStatus == "failed" {
+		logger.Infof("not rolling back app %s to sequence %d because that version also failed", v.AppID, previousSequence)
+		return nil
+	}
+
+	logger.Infof("rolling back app %s from sequence %d to sequence %d", v.AppID, v.Sequence, previousSequence)
+
+	if err := downstream.DeleteDownstreamDeployStatus(v.AppID, v.ClusterID, previousSequence); err != nil {
+		return errors.Wrap(err, "failed to delete previous deploy status")
+	}
+
+	if err := socketservice.RedeployAppVersion(v.AppID, previousSequence, nil); err != nil {
+		return errors.Wrap(err, "failed to redeploy previous version")
+	}
+
+	return nil
+}
+
+func getKotsApplication(appID string, parentSequence int64) (*kotsv1beta1.Application, error) {
+	appVersion, err := store.GetStore().GetAppVersion(appID, parentSequence)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get app version")
+	}
+
+	return &appVersion.KOTSKinds.KotsApplication, nil
+}
@@ -0,0 +1,105 @@
+package maintenancewindow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+)
+
+// ErrNotConfigured is returned when overriding the maintenance windows of an app that has none
+var ErrNotConfigured = errors.New("no maintenance windows configured")
+
+// ClosedError is returned when an operation that was requested to run now is outside of the app's maintenance windows
+type ClosedError struct {
+	NextWindow *types.WindowOccurrence
+}
+
+func (e ClosedError) Error() string {
+	if e.NextWindow == nil {
+		return "the app's maintenance windows are closed and none will open within the next year"
+	}
+	return fmt.Sprintf("the app's maintenance windows are closed, the next one opens at %s", e.NextWindow.Start.Format(time.RFC3339))
+}
+
+// IsOpenForApp returns true if automatic operations are currently allowed for the app
+func IsOpenForApp(appID string) (bool, error) {
+	maintenanceWindows, err := store.GetStore().GetMaintenanceWindows(appID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get maintenance windows")
+	}
+
+	isOpen, err := IsOpen(maintenanceWindows, time.Now())
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check maintenance windows")
+	}
+
+	return isOpen, nil
+}
+
+// CheckOpenForApp returns a ClosedError if automatic operations are not currently allowed for the app
+func CheckOpenForApp(appID string) error {
+	maintenanceWindows, err := store.GetStore().GetMaintenanceWindows(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get maintenance windows")
+	}
+
+	now := time.Now()
+
+	isOpen, err := IsOpen(maintenanceWindows, now)
+	if err != nil {
+		return errors.Wrap(err, "failed to check maintenance windows")
+	}
+	if isOpen {
+		return nil
+	}
+
+	nextWindow, err := NextWindow(maintenanceWindows, now)
+	if err != nil {
+		return errors.Wrap(err, "failed to get next maintenance window")
+	}
+
+	return ClosedError{NextWindow: nextWindow}
+}
+
+// IsOpenForCluster returns true if automatic operations are currently allowed for every app in the cluster
+func IsOpenForCluster(clusterID string) (bool, error) {
+	apps, err := store.GetStore().ListAppsForDownstream(clusterID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list apps for cluster")
+	}
+
+	for _, a := range apps {
+		isOpen, err := IsOpenForApp(a.ID)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to check maintenance windows for app %s", a.Slug)
+		}
+		if !isOpen {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Override opens the maintenance windows for the app until the given time, regardless of the configured windows
+func Override(appID string, until time.Time) error {
+	maintenanceWindows, err := store.GetStore().GetMaintenanceWindows(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get maintenance windows")
+	}
+
+	if maintenanceWindows == nil {
+		return ErrNotConfigured
+	}
+
+	maintenanceWindows.OverrideUntil = &until
+
+	if err := store.GetStore().SetMaintenanceWindows(appID, maintenanceWindows); err != nil {
+		return errors.Wrap(err, "failed to set maintenance windows")
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package types
+
+import "time"
+
+// MaintenanceWindows is the per-app configuration that limits when automatic deploys,
+// pending deploys and scheduled snapshots are allowed to run
+type MaintenanceWindows struct {
+	Timezone      string     `json:"timezone"`
+	Windows       []Window   `json:"windows"`
+	BlackoutDates []string   `json:"blackoutDates"`
+	OverrideUntil *time.Time `json:"overrideUntil,omitempty"`
+}
+
+// Window is a recurring window that starts on each of the listed days at the given
+// time (HH:MM, in the configured timezone) and lasts for the given duration
+type Window struct {
+	Days     []string `json:"days"`
+	Start    string   `json:"start"`
+	Duration string   `json:"duration"`
+}
+
+type WindowOccurrence struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
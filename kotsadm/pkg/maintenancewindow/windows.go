@@ -0,0 +1,211 @@
+package maintenancewindow
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow/types"
+)
+
+const blackoutDateFormat = "2006-01-02"
+
+// how far ahead to look for the next window. a full year covers any combination of weekly windows and blackout dates
+const maxLookaheadDays = 366
+
+var weekdays = map[string]time.Weekday{
+	"sun":       time.Sunday,
+	"sunday":    time.Sunday,
+	"mon":       time.Monday,
+	"monday":    time.Monday,
+	"tue":       time.Tuesday,
+	"tuesday":   time.Tuesday,
+	"wed":       time.Wednesday,
+	"wednesday": time.Wednesday,
+	"thu":       time.Thursday,
+	"thursday":  time.Thursday,
+	"fri":       time.Friday,
+	"friday":    time.Friday,
+	"sat":       time.Saturday,
+	"saturday":  time.Saturday,
+}
+
+type parsedWindow struct {
+	days     map[time.Weekday]bool
+	hour     int
+	minute   int
+	duration time.Duration
+}
+
+// Validate returns an error if the maintenance windows cannot be evaluated
+func Validate(m *types.MaintenanceWindows) error {
+	_, _, err := parse(m)
+	return err
+}
+
+// IsOpen returns true if t falls within a maintenance window, or if the windows are currently overridden.
+// An app without any maintenance windows configured is always open.
+// Window occurrences that start on a blackout date are skipped entirely.
+func IsOpen(m *types.MaintenanceWindows, t time.Time) (bool, error) {
+	if m == nil {
+		return true, nil
+	}
+
+	if m.OverrideUntil != nil && t.Before(*m.OverrideUntil) {
+		return true, nil
+	}
+
+	if len(m.Windows) == 0 {
+		return true, nil
+	}
+
+	occurrence, err := NextWindow(m, t)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get next window")
+	}
+	if occurrence == nil {
+		return false, nil
+	}
+
+	return !t.Before(occurrence.Start), nil
+}
+
+// NextWindow returns the window that is currently open at t, or the next one to open after t.
+// Returns nil if no windows are configured, or none will open within the next year.
+func NextWindow(m *types.MaintenanceWindows, t time.Time) (*types.WindowOccurrence, error) {
+	if m == nil || len(m.Windows) == 0 {
+		return nil, nil
+	}
+
+	windows, loc, err := parse(m)
+	if err != nil {
+		return nil, err
+	}
+
+	blackouts := map[string]bool{}
+	for _, d := range m.BlackoutDates {
+		blackouts[d] = true
+	}
+
+	// windows can span multiple days, so start looking far enough back to find one that is still open
+	maxDuration := time.Duration(0)
+	for _, w := range windows {
+		if w.duration > maxDuration {
+			maxDuration = w.duration
+		}
+	}
+	daysBack := int(maxDuration/(24*time.Hour)) + 1
+
+	local := t.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	occurrences := []types.WindowOccurrence{}
+	for i := -daysBack; i <= maxLookaheadDays; i++ {
+		day := today.AddDate(0, 0, i)
+		if blackouts[day.Format(blackoutDateFormat)] {
+			continue
+		}
+		for _, w := range windows {
+			if !w.days[day.Weekday()] {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), w.hour, w.minute, 0, 0, loc)
+			end := start.Add(w.duration)
+			if !end.After(t) {
+				continue
+			}
+			occurrences = append(occurrences, types.WindowOccurrence{Start: start, End: end})
+		}
+
+		// occurrences are generated in day order, so nothing on a later day can start earlier
+		if len(occurrences) > 0 {
+			break
+		}
+	}
+
+	if len(occurrences) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+
+	return &occurrences[0], nil
+}
+
+func parse(m *types.MaintenanceWindows) ([]parsedWindow, *time.Location, error) {
+	loc := time.UTC
+	if m.Timezone != "" {
+		l, err := time.LoadLocation(m.Timezone)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to load timezone %q", m.Timezone)
+		}
+		loc = l
+	}
+
+	for _, d := range m.BlackoutDates {
+		if _, err := time.Parse(blackoutDateFormat, d); err != nil {
+			return nil, nil, errors.Errorf("invalid blackout date %q, expected YYYY-MM-DD", d)
+		}
+	}
+
+	windows := []parsedWindow{}
+	for i, w := range m.Windows {
+		parsed, err := parseWindow(w)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid window %d", i)
+		}
+		windows = append(windows, *parsed)
+	}
+
+	return windows, loc, nil
+}
+
+func parseWindow(w types.Window) (*parsedWindow, error) {
+	if len(w.Days) == 0 {
+		return nil, errors.New("at least one day is required")
+	}
+
+	days := map[time.Weekday]bool{}
+	for _, d := range w.Days {
+		weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(d))]
+		if !ok {
+			return nil, errors.Errorf("unknown day %q", d)
+		}
+		days[weekday] = true
+	}
+
+	parts := strings.Split(w.Start, ":")
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid start time %q, expected HH:MM", w.Start)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return nil, errors.Errorf("invalid start hour in %q", w.Start)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return nil, errors.Errorf("invalid start minute in %q", w.Start)
+	}
+
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid duration %q", w.Duration)
+	}
+	if duration <= 0 {
+		return nil, errors.New("duration must be positive")
+	}
+	if duration > 7*24*time.Hour {
+		return nil, errors.Errorf("duration %s is longer than a week", w.Duration)
+	}
+
+	return &parsedWindow{
+		days:     days,
+		hour:     hour,
+		minute:   minute,
+		duration: duration,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package maintenancewindow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow/types"
+)
+
+func TestIsOpen(t *testing.T) {
+	// 2021-01-02 is a saturday
+	saturdayNight := &types.MaintenanceWindows{
+		Timezone: "America/New_York",
+		Windows: []types.Window{
+			{Days: []string{"sat"}, Start: "22:00", Duration: "4h"},
+		},
+	}
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overrideUntil := time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		maintenanceWindows *types.MaintenanceWindows
+		at                 time.Time
+		want               bool
+	}{
+		{
+			name:               "no windows configured",
+			maintenanceWindows: nil,
+			at:                 time.Date(2021, 1, 4, 10, 0, 0, 0, newYork),
+			want:               true,
+		},
+		{
+			name:               "before window",
+			maintenanceWindows: saturdayNight,
+			at:                 time.Date(2021, 1, 2, 21, 59, 0, 0, newYork),
+			want:               false,
+		},
+		{
+			name:               "window start",
+			maintenanceWindows: saturdayNight,
+			at:                 time.Date(2021, 1, 2, 22, 0, 0, 0, newYork),
+			want:               true,
+		},
+		{
+			name:               "window spans midnight",
+			maintenanceWindows: saturdayNight,
+			at:                 time.Date(2021, 1, 3, 1, 30, 0, 0, newYork),
+			want:               true,
+		},
+		{
+			name:               "window end",
+			maintenanceWindows: saturdayNight,
+			at:                 time.Date(2021, 1, 3, 2, 0, 0, 0, newYork),
+			want:               false,
+		},
+		{
+			name:               "timezone is respected",
+			maintenanceWindows: saturdayNight,
+			at:                 time.Date(2021, 1, 2, 22, 30, 0, 0, time.UTC),
+			want:               false,
+		},
+		{
+			name: "blackout date",
+			maintenanceWindows: &types.MaintenanceWindows{
+				Timezone:      "America/New_York",
+				Windows:       saturdayNight.Windows,
+				BlackoutDates: []string{"2021-01-02"},
+			},
+			at:   time.Date(2021, 1, 2, 23, 0, 0, 0, newYork),
+			want: false,
+		},
+		{
+			name: "override",
+			maintenanceWindows: &types.MaintenanceWindows{
+				Timezone:      "America/New_York",
+				Windows:       saturdayNight.Windows,
+				OverrideUntil: &overrideUntil,
+			},
+			at:   time.Date(2021, 1, 4, 11, 0, 0, 0, time.UTC),
+			want: true,
+		},
+		{
+			name: "expired override",
+			maintenanceWindows: &types.MaintenanceWindows{
+				Timezone:      "America/New_York",
+				Windows:       saturdayNight.Windows,
+				OverrideUntil: &overrideUntil,
+			},
+			at:   time.Date(2021, 1, 4, 13, 0, 0, 0, time.UTC),
+			want: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := IsOpen(test.maintenanceWindows, test.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("Expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestNextWindow(t *testing.T) {
+	maintenanceWindows := &types.MaintenanceWindows{
+		Windows: []types.Window{
+			{Days: []string{"Tuesday", "thu"}, Start: "03:00", Duration: "1h"},
+		},
+		BlackoutDates: []string{"2021-01-05"},
+	}
+
+	// 2021-01-04 is a monday, tuesday is blacked out so the next window is on thursday
+	next, err := NextWindow(maintenanceWindows, time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil {
+		t.Fatal("Expected a window")
+	}
+
+	expectStart := time.Date(2021, 1, 7, 3, 0, 0, 0, time.UTC)
+	expectEnd := time.Date(2021, 1, 7, 4, 0, 0, 0, time.UTC)
+	if !next.Start.Equal(expectStart) {
+		t.Errorf("Expected start %s, got %s", expectStart, next.Start)
+	}
+	if !next.End.Equal(expectEnd) {
+		t.Errorf("Expected end %s, got %s", expectEnd, next.End)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []*types.MaintenanceWindows{
+		{Timezone: "Not/AZone", Windows: []types.Window{{Days: []string{"mon"}, Start: "01:00", Duration: "1h"}}},
+		{Windows: []types.Window{{Days: []string{"someday"}, Start: "01:00", Duration: "1h"}}},
+		{Windows: []types.Window{{Days: []string{"mon"}, Start: "25:00", Duration: "1h"}}},
+		{Windows: []types.Window{{Days: []string{"mon"}, Start: "01:00", Duration: "-1h"}}},
+		{Windows: []types.Window{{Days: []string{}, Start: "01:00", Duration: "1h"}}},
+		{BlackoutDates: []string{"01/02/2021"}},
+	}
+
+	for _, maintenanceWindows := range invalid {
+		if err := Validate(maintenanceWindows); err == nil {
+			t.Errorf("Expected error for %#v", maintenanceWindows)
+		}
+	}
+}
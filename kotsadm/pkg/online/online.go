@@ -26,7 +26,7 @@ import (
 	"go.uber.org/zap"
 )
 
-func CreateAppFromOnline(pendingApp *types.PendingApp, upstreamURI string, isAutomated bool, skipPreflights bool) (_ *kotsutil.KotsKinds, finalError error) {
+func CreateAppFromOnline(pendingApp *types.PendingApp, upstreamURI string, isAutomated bool, skipPreflights bool, overrideStrictPreflights bool) (_ *kotsutil.KotsKinds, finalError error) {
 	logger.Debug("creating app from online",
 		zap.String("upstreamURI", upstreamURI))
 
@@ -162,11 +162,29 @@ func CreateAppFromOnline(pendingApp *types.PendingApp, upstreamURI string, isAut
 		return nil, errors.Wrap(err, "failed to set app is not airgap")
 	}
 
+	// strict preflight checks can't be skipped, only overridden
+	if skipPreflights && !overrideStrictPreflights {
+		hasStrictAnalyzers, err := preflight.HasStrictAnalyzers(pendingApp.ID, pendingApp.Slug, 0, false, tmpRoot)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to check for strict analyzers")
+		}
+		if hasStrictAnalyzers {
+			logger.Info("not skipping preflight checks because the release has strict preflight checks")
+			skipPreflights = false
+		}
+	}
+
 	newSequence, err := version.CreateFirstVersion(pendingApp.ID, tmpRoot, "Online Install", skipPreflights)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create new version")
 	}
 
+	if overrideStrictPreflights {
+		if err := preflight.OverrideStrictPreflights(pendingApp.ID, newSequence, "kots install", ""); err != nil {
+			return nil, errors.Wrap(err, "failed to override strict preflights")
+		}
+	}
+
 	kotsKinds, err := kotsutil.LoadKotsKindsFromPath(tmpRoot)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load kotskinds from path")
@@ -51,6 +51,12 @@ var (
 	GitopsWrite = Must(NewPolicy(ActionWrite, "gitops."))
 )
 
+// Admin console
+
+var (
+	AdminConsoleWrite = Must(NewPolicy(ActionWrite, "adminconsole."))
+)
+
 // Prometheus
 
 var (
@@ -146,3 +152,10 @@ var (
 	AppDownstreamConfigRead  = Must(NewPolicy(ActionRead, "app.{{.appSlug}}.downstream.config."))
 	AppDownstreamConfigWrite = Must(NewPolicy(ActionWrite, "app.{{.appSlug}}.downstream.config."))
 )
+
+// App maintenance windows
+
+var (
+	AppMaintenancewindowRead  = Must(NewPolicy(ActionRead, "app.{{.appSlug}}.maintenancewindow."))
+	AppMaintenancewindowWrite = Must(NewPolicy(ActionWrite, "app.{{.appSlug}}.maintenancewindow."))
+)
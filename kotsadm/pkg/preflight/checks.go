@@ -0,0 +1,418 @@
+package preflight
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	"github.com/replicatedhq/kots/pkg/kotsutil"
+	troubleshootpreflight "github.com/replicatedhq/troubleshoot/pkg/preflight"
+	cron "github.com/robfig/cron/v3"
+	"github.com/segmentio/ksuid"
+	"go.uber.org/zap"
+)
+
+// checkRunsToKeep is the number of preflight check runs that are kept in the history of an app
+const checkRunsToKeep = 100
+
+// checkRunTimeout is how long a started preflight check run has to report its results before it is failed
+const checkRunTimeout = 30 * time.Minute
+
+// checkJobs maps app ids to the cron jobs that re-run their preflight checks
+var checkJobs = make(map[string]*cron.Cron)
+var checkMtx sync.Mutex
+
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// ErrCheckRunCompleted is returned when results are posted for a preflight check run that already has results
+var ErrCheckRunCompleted = errors.New("preflight check run is already completed")
+
+// CheckWebhookPayload is posted to the webhook of the check policy when the outcome of a check changes from pass to warn or fail
+type CheckWebhookPayload struct {
+	AppID     string                       `json:"appId"`
+	AppSlug   string                       `json:"appSlug"`
+	ClusterID string                       `json:"clusterId"`
+	Sequence  int64                        `json:"sequence"`
+	RunID     string                       `json:"runId"`
+	State     string                       `json:"state"`
+	Changes   []preflighttypes.CheckChange `json:"changes"`
+}
+
+// StartChecks schedules the preflight checks of every installed app that has a check policy
+func StartChecks() error {
+	logger.Debug("starting scheduled preflight checks")
+
+	appsList, err := store.GetStore().ListInstalledApps()
+	if err != nil {
+		return errors.Wrap(err, "failed to list installed apps")
+	}
+
+	for _, a := range appsList {
+		if err := ConfigureChecks(a.ID); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to configure preflight checks for app %s", a.Slug))
+		}
+	}
+
+	return nil
+}
+
+// ConfigureChecks adds, updates or stops the cron job that re-runs the preflight checks of the app, following its check policy
+func ConfigureChecks(appID string) error {
+	a, err := store.GetStore().GetApp(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app")
+	}
+
+	checkMtx.Lock()
+	defer checkMtx.Unlock()
+
+	if !a.PreflightCheckPolicy.IsEnabled() {
+		stopChecks(a.ID)
+		return nil
+	}
+
+	logger.Debug("configure preflight checks for app",
+		zap.String("slug", a.Slug))
+
+	job, ok := checkJobs[a.ID]
+	if ok {
+		// job already exists, remove entries
+		entries := job.Entries()
+		for _, entry := range entries {
+			job.Remove(entry.ID)
+		}
+	} else {
+		// job does not exist, create a new one
+		job = cron.New(cron.WithChain(
+			cron.Recover(cron.DefaultLogger),
+		))
+	}
+
+	jobAppID := a.ID
+	jobAppSlug := a.Slug
+	_, err = job.AddFunc(a.PreflightCheckPolicy.Schedule, func() {
+		logger.Debug("queueing preflight checks for app", zap.String("slug", jobAppSlug))
+
+		if err := queueChecks(jobAppID); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to queue preflight checks for app %s", jobAppSlug))
+		}
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to add func")
+	}
+
+	job.Start()
+	checkJobs[a.ID] = job
+
+	return nil
+}
+
+func stopChecks(appID string) {
+	if job, ok := checkJobs[appID]; ok {
+		job.Stop()
+		delete(checkJobs, appID)
+	}
+}
+
+// queueChecks queues a preflight check run in every cluster where the deployed version of the app has a preflight spec.
+// The runs are picked up by the socket service, which asks the operator of the cluster to run them.
+// Runs in a cluster share the spec secret of the app, so no run is queued while one is pending or running in the cluster.
+func queueChecks(appID string) error {
+	a, err := store.GetStore().GetApp(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app")
+	}
+
+	if err := store.GetStore().FailStalePreflightCheckRuns(a.ID, time.Now().Add(-checkRunTimeout)); err != nil {
+		return errors.Wrap(err, "failed to fail stale preflight check runs")
+	}
+
+	pendingRuns, err := store.GetStore().ListPendingPreflightCheckRuns(a.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list pending preflight check runs")
+	}
+
+	runningRuns, err := store.GetStore().ListRunningPreflightCheckRuns(a.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list running preflight check runs")
+	}
+	pendingRuns = append(pendingRuns, runningRuns...)
+
+	downstreams, err := store.GetStore().ListDownstreamsForApp(a.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list downstreams for app")
+	}
+
+	for _, d := range downstreams {
+		if hasPendingCheckRun(pendingRuns, d.ClusterID) {
+			logger.Debug("skipping preflight checks for cluster with a check run in progress",
+				zap.String("slug", a.Slug),
+				zap.String("clusterID", d.ClusterID))
+			continue
+		}
+
+		currentSequence, err := downstream.GetCurrentSequence(a.ID, d.ClusterID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get current sequence")
+		}
+		if currentSequence == -1 {
+			continue
+		}
+
+		hasPreflight, err := hasPreflightSpec(a.ID, currentSequence)
+		if err != nil {
+			return errors.Wrap(err, "failed to check for preflight spec")
+		}
+		if !hasPreflight {
+			continue
+		}
+
+		if err := store.GetStore().CreatePreflightCheckRun(ksuid.New().String(), a.ID, d.ClusterID, currentSequence); err != nil {
+			return errors.Wrapf(err, "failed to create preflight check run in cluster %s", d.ClusterID)
+		}
+	}
+
+	return nil
+}
+
+func hasPendingCheckRun(pendingRuns []*preflighttypes.CheckRun, clusterID string) bool {
+	for _, run := range pendingRuns {
+		if run.ClusterID == clusterID {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPreflightSpec(appID string, sequence int64) (bool, error) {
+	archiveDir, err := ioutil.TempDir("", "kotsadm")
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(archiveDir)
+
+	if err := store.GetStore().GetAppVersionArchive(appID, sequence, archiveDir); err != nil {
+		return false, errors.Wrap(err, "failed to get app version archive")
+	}
+
+	kotsKinds, err := kotsutil.LoadKotsKindsFromPath(archiveDir)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to load kots kinds")
+	}
+
+	return kotsKinds.Preflight != nil, nil
+}
+
+func GetCheckSpecSecretName(appSlug string) string {
+	return fmt.Sprintf("kotsadm-%s-preflight-check", appSlug)
+}
+
+func GetCheckSpecURI(appSlug string) string {
+	return fmt.Sprintf("secret/%s/%s", os.Getenv("POD_NAMESPACE"), GetCheckSpecSecretName(appSlug))
+}
+
+// CreateRenderedSpecForCheckRun renders the preflight spec of the version of a check run.
+// The results are uploaded to the check run, not to the version.
+func CreateRenderedSpecForCheckRun(run *preflighttypes.CheckRun) error {
+	app, err := store.GetStore().GetApp(run.AppID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app")
+	}
+
+	archivePath, err := ioutil.TempDir("", "kotsadm")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(archivePath)
+
+	err = store.GetStore().GetAppVersionArchive(app.ID, run.Sequence, archivePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app version archive")
+	}
+
+	kotsKinds, err := kotsutil.LoadKotsKindsFromPath(archivePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to load kotskinds")
+	}
+	if kotsKinds.Preflight == nil {
+		return errors.Errorf("sequence %d has no preflight spec", run.Sequence)
+	}
+
+	uploadURL := fmt.Sprintf("%s/api/v1/preflight/check/%s", os.Getenv("API_ENDPOINT"), run.ID)
+
+	return writeRenderedSpec(app, run.Sequence, kotsKinds, kotsKinds.Preflight, uploadURL, GetCheckSpecSecretName(app.Slug))
+}
+
+// SetCheckRunResults stores the results of a preflight check run, and raises a warning when the outcome of a check
+// changed from pass to warn or fail since the previous run in the same cluster
+func SetCheckRunResults(runID string, b []byte) error {
+	run, err := store.GetStore().GetPreflightCheckRun(runID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get preflight check run")
+	}
+	if run == nil {
+		return errors.Errorf("preflight check run %s not found", runID)
+	}
+	if run.Status == preflighttypes.CheckRunStatusCompleted {
+		return ErrCheckRunCompleted
+	}
+
+	results := troubleshootpreflight.UploadPreflightResults{}
+	if err := json.Unmarshal(b, &results); err != nil {
+		return errors.Wrap(err, "failed to unmarshal preflight results")
+	}
+
+	baseline, err := getCheckBaseline(run)
+	if err != nil {
+		return errors.Wrap(err, "failed to get baseline preflight results")
+	}
+
+	changes := []preflighttypes.CheckChange{}
+	if baseline != "" {
+		previousResults := troubleshootpreflight.UploadPreflightResults{}
+		if err := json.Unmarshal([]byte(baseline), &previousResults); err != nil {
+			logger.Error(errors.Wrap(err, "failed to unmarshal previous preflight results"))
+		} else {
+			changes = compareCheckResults(&previousResults, &results)
+		}
+	}
+
+	state := getPreflightState(&results)
+	if err := store.GetStore().SetPreflightCheckRunResults(run.ID, b, state, changes); err != nil {
+		return errors.Wrap(err, "failed to set preflight check run results")
+	}
+
+	if err := store.GetStore().PrunePreflightCheckRuns(run.AppID, checkRunsToKeep); err != nil {
+		logger.Error(errors.Wrap(err, "failed to prune preflight check runs"))
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	a, err := store.GetStore().GetApp(run.AppID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app")
+	}
+
+	logger.Info("preflight checks changed from pass",
+		zap.String("slug", a.Slug),
+		zap.String("clusterID", run.ClusterID),
+		zap.Int64("sequence", run.Sequence),
+		zap.Int("changes", len(changes)))
+
+	if !a.PreflightCheckPolicy.IsEnabled() || a.PreflightCheckPolicy.WebhookURL == "" {
+		return nil
+	}
+
+	payload := CheckWebhookPayload{
+		AppID:     a.ID,
+		AppSlug:   a.Slug,
+		ClusterID: run.ClusterID,
+		Sequence:  run.Sequence,
+		RunID:     run.ID,
+		State:     state,
+		Changes:   changes,
+	}
+	if err := sendCheckWebhook(a.PreflightCheckPolicy.WebhookURL, payload); err != nil {
+		return errors.Wrap(err, "failed to send webhook")
+	}
+
+	return nil
+}
+
+// getCheckBaseline returns the results that a check run is compared with, the results of the previous run in the same cluster.
+// The first run is compared with the results of the preflight checks that ran when the version was deployed.
+func getCheckBaseline(run *preflighttypes.CheckRun) (string, error) {
+	previousRun, err := store.GetStore().GetLatestPreflightCheckRun(run.AppID, run.ClusterID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get previous preflight check run")
+	}
+	if previousRun != nil {
+		return previousRun.Result, nil
+	}
+
+	versionResults, err := store.GetStore().GetPreflightResults(run.AppID, run.Sequence)
+	if err != nil {
+		if store.GetStore().IsNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to get preflight results of version")
+	}
+
+	return versionResults.Result, nil
+}
+
+// compareCheckResults returns the checks that passed in the previous results and warn or fail in the current results.
+// Checks are matched by title, checks that are new in the current results are not reported.
+func compareCheckResults(previous *troubleshootpreflight.UploadPreflightResults, current *troubleshootpreflight.UploadPreflightResults) []preflighttypes.CheckChange {
+	previousOutcomes := map[string]string{}
+	for _, r := range previous.Results {
+		previousOutcomes[r.Title] = getCheckOutcome(r)
+	}
+
+	changes := []preflighttypes.CheckChange{}
+	for _, r := range current.Results {
+		from, ok := previousOutcomes[r.Title]
+		if !ok || from != "pass" {
+			continue
+		}
+
+		to := getCheckOutcome(r)
+		if to == "pass" {
+			continue
+		}
+
+		changes = append(changes, preflighttypes.CheckChange{
+			Title: r.Title,
+			From:  from,
+			To:    to,
+		})
+	}
+
+	return changes
+}
+
+func getCheckOutcome(result *troubleshootpreflight.UploadPreflightResult) string {
+	if result.IsFail {
+		return "fail"
+	} else if result.IsWarn {
+		return "warn"
+	}
+	return "pass"
+}
+
+func sendCheckWebhook(url string, payload CheckWebhookPayload) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal payload")
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package preflight
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
+	troubleshootpreflight "github.com/replicatedhq/troubleshoot/pkg/preflight"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_compareCheckResults(t *testing.T) {
+	previous := &troubleshootpreflight.UploadPreflightResults{
+		Results: []*troubleshootpreflight.UploadPreflightResult{
+			{Title: "Kubernetes version", IsPass: true},
+			{Title: "Node count", IsPass: true},
+			{Title: "Storage class", IsPass: true},
+			{Title: "Disk space", IsWarn: true},
+			{Title: "Memory", IsFail: true},
+		},
+	}
+	current := &troubleshootpreflight.UploadPreflightResults{
+		Results: []*troubleshootpreflight.UploadPreflightResult{
+			{Title: "Kubernetes version", IsPass: true},
+			{Title: "Node count", IsWarn: true},
+			{Title: "Storage class", IsFail: true},
+			{Title: "Disk space", IsFail: true},
+			{Title: "Memory", IsPass: true},
+			{Title: "Ingress", IsFail: true},
+		},
+	}
+
+	changes := compareCheckResults(previous, current)
+	assert.Equal(t, []preflighttypes.CheckChange{
+		{Title: "Node count", From: "pass", To: "warn"},
+		{Title: "Storage class", From: "pass", To: "fail"},
+	}, changes)
+
+	assert.Empty(t, compareCheckResults(current, current))
+	assert.Empty(t, compareCheckResults(&troubleshootpreflight.UploadPreflightResults{}, current))
+}
+
+func Test_sendCheckWebhook(t *testing.T) {
+	var received CheckWebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	payload := CheckWebhookPayload{
+		AppID:     "app-id",
+		AppSlug:   "my-app",
+		ClusterID: "cluster-id",
+		Sequence:  3,
+		RunID:     "run-id",
+		State:     "fail",
+		Changes: []preflighttypes.CheckChange{
+			{Title: "Storage class", From: "pass", To: "fail"},
+		},
+	}
+	require.NoError(t, sendCheckWebhook(server.URL, payload))
+	assert.Equal(t, payload, received)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	assert.Error(t, sendCheckWebhook(failing.URL, payload))
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
 	troubleshootv1beta2 "github.com/replicatedhq/troubleshoot/pkg/apis/troubleshoot/v1beta2"
 	"github.com/replicatedhq/troubleshoot/pkg/preflight"
@@ -16,7 +17,8 @@ import (
 
 // execute will execute the preflights using spec in preflightSpec.
 // This spec should be rendered, no template functions remaining
-func execute(appID string, sequence int64, preflightSpec *troubleshootv1beta2.Preflight, ignorePermissionErrors bool) (*troubleshootpreflight.UploadPreflightResults, error) {
+// strictAnalyzers has the strict flag of each analyzer in the spec, the results of strict analyzers are marked when they are stored
+func execute(appID string, sequence int64, preflightSpec *troubleshootv1beta2.Preflight, strictAnalyzers []bool, ignorePermissionErrors bool) (*troubleshootpreflight.UploadPreflightResults, error) {
 	logger.Debug("executing preflight checks",
 		zap.String("appID", appID),
 		zap.Int64("sequence", sequence))
@@ -54,6 +56,7 @@ func execute(appID string, sequence int64, preflightSpec *troubleshootv1beta2.Pr
 	}
 
 	uploadPreflightResults := &troubleshootpreflight.UploadPreflightResults{}
+	storedPreflightResults := &preflighttypes.PreflightResults{}
 	if isPermissionsError(err) {
 		logger.Debug("skipping analyze due to RBAC errors")
 		rbacErrors := []*troubleshootpreflight.UploadPreflightError{}
@@ -65,31 +68,47 @@ func execute(appID string, sequence int64, preflightSpec *troubleshootv1beta2.Pr
 			}
 		}
 		uploadPreflightResults.Errors = rbacErrors
+		storedPreflightResults.Errors = rbacErrors
 	} else {
 		logger.Debug("preflight analyze phase")
-		analyzeResults := collectResults.Analyze()
 
 		// the typescript api added some flair to this result
 		// so let's keep it for compatibility
 		// MORE TYPES!
 		results := []*troubleshootpreflight.UploadPreflightResult{}
-		for _, analyzeResult := range analyzeResults {
-			uploadPreflightResult := &troubleshootpreflight.UploadPreflightResult{
-				IsFail:  analyzeResult.IsFail,
-				IsWarn:  analyzeResult.IsWarn,
-				IsPass:  analyzeResult.IsPass,
-				Title:   analyzeResult.Title,
-				Message: analyzeResult.Message,
-				URI:     analyzeResult.URI,
-			}
+		storedResults := []*preflighttypes.PreflightCheckResult{}
+
+		// analyzers run one at a time so that the results of strict analyzers can be told apart
+		for i, analyzer := range preflightSpec.Spec.Analyzers {
+			analyzerSpec := *preflightSpec
+			analyzerSpec.Spec.Analyzers = []*troubleshootv1beta2.Analyze{analyzer}
+			analyzerCollectResults := collectResults
+			analyzerCollectResults.Spec = &analyzerSpec
+
+			strict := i < len(strictAnalyzers) && strictAnalyzers[i]
+			for _, analyzeResult := range analyzerCollectResults.Analyze() {
+				uploadPreflightResult := &troubleshootpreflight.UploadPreflightResult{
+					IsFail:  analyzeResult.IsFail,
+					IsWarn:  analyzeResult.IsWarn,
+					IsPass:  analyzeResult.IsPass,
+					Title:   analyzeResult.Title,
+					Message: analyzeResult.Message,
+					URI:     analyzeResult.URI,
+				}
 
-			results = append(results, uploadPreflightResult)
+				results = append(results, uploadPreflightResult)
+				storedResults = append(storedResults, &preflighttypes.PreflightCheckResult{
+					UploadPreflightResult: *uploadPreflightResult,
+					Strict:                strict,
+				})
+			}
 		}
 		uploadPreflightResults.Results = results
+		storedPreflightResults.Results = storedResults
 	}
 
 	logger.Debug("preflight marshalling")
-	b, err := json.Marshal(uploadPreflightResults)
+	b, err := json.Marshal(storedPreflightResults)
 	if err != nil {
 		return uploadPreflightResults, errors.Wrap(err, "failed to marshal results")
 	}
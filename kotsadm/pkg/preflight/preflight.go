@@ -8,6 +8,8 @@ import (
 	"os"
 
 	"github.com/pkg/errors"
+	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/autosupportbundle"
 	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
 	"github.com/replicatedhq/kots/kotsadm/pkg/registry"
@@ -96,9 +98,14 @@ func Run(appID string, appSlug string, sequence int64, isAirgap bool, archiveDir
 		}
 		p.Spec.Collectors = collectors
 
+		strictAnalyzers, err := loadStrictAnalyzers(renderedKotsKinds, registrySettings, appSlug, sequence, isAirgap, archiveDir)
+		if err != nil {
+			return errors.Wrap(err, "failed to load strict analyzers")
+		}
+
 		go func() {
 			logger.Debug("preflight checks beginning")
-			uploadPreflightResults, err := execute(appID, sequence, p, ignoreRBAC)
+			uploadPreflightResults, err := execute(appID, sequence, p, strictAnalyzers, ignoreRBAC)
 			if err != nil {
 				err = errors.Wrap(err, "failed to run preflight checks")
 				logger.Error(err)
@@ -106,6 +113,10 @@ func Run(appID string, appSlug string, sequence int64, isAirgap bool, archiveDir
 			}
 			logger.Debug("preflight checks completed")
 
+			if err := CollectSupportBundleIfFailed(appID, sequence, uploadPreflightResults); err != nil {
+				logger.Error(errors.Wrap(err, "failed to collect support bundle for failed preflight checks"))
+			}
+
 			err = maybeDeployFirstVersion(appID, sequence, uploadPreflightResults)
 			if err != nil {
 				err = errors.Wrap(err, "failed to deploy first version")
@@ -134,7 +145,7 @@ func Run(appID string, appSlug string, sequence int64, isAirgap bool, archiveDir
 }
 
 // maybeDeployFirstVersion will deploy the first version if
-// 1. preflight checks pass
+// 1. preflight checks pass, or an admin overrode the strict preflight checks at install
 // 2. we have not already deployed it
 func maybeDeployFirstVersion(appID string, sequence int64, preflightResults *troubleshootpreflight.UploadPreflightResults) error {
 	if sequence != 0 {
@@ -153,7 +164,17 @@ func maybeDeployFirstVersion(appID string, sequence int64, preflightResults *tro
 
 	preflightState := getPreflightState(preflightResults)
 	if preflightState != "pass" {
-		return nil
+		result, err := store.GetStore().GetPreflightResults(appID, sequence)
+		if err != nil {
+			return errors.Wrap(err, "failed to get preflight results")
+		}
+		if result.StrictOverrideAt == nil {
+			return nil
+		}
+		logger.Info("deploying first app version with failed preflight checks because of an override",
+			zap.String("appID", appID),
+			zap.String("source", result.StrictOverrideSource),
+			zap.String("by", result.StrictOverrideBy))
 	}
 
 	logger.Debug("automatically deploying first app version")
@@ -164,6 +185,14 @@ func maybeDeployFirstVersion(appID string, sequence int64, preflightResults *tro
 	return version.DeployVersion(appID, sequence)
 }
 
+// CollectSupportBundleIfFailed queues a support bundle when preflight checks fail, if the support bundle policy of the app asks for one
+func CollectSupportBundleIfFailed(appID string, sequence int64, preflightResults *troubleshootpreflight.UploadPreflightResults) error {
+	if getPreflightState(preflightResults) != "fail" {
+		return nil
+	}
+	return autosupportbundle.PreflightFailed(appID, sequence)
+}
+
 func getPreflightState(preflightResults *troubleshootpreflight.UploadPreflightResults) string {
 	if len(preflightResults.Errors) > 0 {
 		return "fail"
@@ -226,13 +255,7 @@ func CreateRenderedSpec(appID string, sequence int64, origin string, inCluster b
 	} else if origin != "" {
 		baseURL = origin
 	}
-	preflight.Spec.UploadResultsTo = fmt.Sprintf("%s/api/v1/preflight/app/%s/sequence/%d", baseURL, app.Slug, sequence)
-
-	s := serializer.NewYAMLSerializer(serializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
-	var b bytes.Buffer
-	if err := s.Encode(preflight, &b); err != nil {
-		return errors.Wrap(err, "failed to encode preflight")
-	}
+	uploadURL := fmt.Sprintf("%s/api/v1/preflight/app/%s/sequence/%d", baseURL, app.Slug, sequence)
 
 	archivePath, err := ioutil.TempDir("", "kotsadm")
 	if err != nil {
@@ -250,6 +273,19 @@ func CreateRenderedSpec(appID string, sequence int64, origin string, inCluster b
 		return errors.Wrap(err, "failed to load current kotskinds")
 	}
 
+	return writeRenderedSpec(app, sequence, kotsKinds, preflight, uploadURL, GetSpecSecretName(app.Slug))
+}
+
+// writeRenderedSpec renders the preflight spec of the app version and stores it in the secret that is passed to the preflight command
+func writeRenderedSpec(app *apptypes.App, sequence int64, kotsKinds *kotsutil.KotsKinds, preflight *troubleshootv1beta2.Preflight, uploadURL string, secretName string) error {
+	preflight.Spec.UploadResultsTo = uploadURL
+
+	s := serializer.NewYAMLSerializer(serializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+	var b bytes.Buffer
+	if err := s.Encode(preflight, &b); err != nil {
+		return errors.Wrap(err, "failed to encode preflight")
+	}
+
 	templatedSpec := b.Bytes()
 
 	renderedSpec, err := helper.RenderAppFile(app, &sequence, templatedSpec, kotsKinds)
@@ -267,8 +303,6 @@ func CreateRenderedSpec(appID string, sequence int64, origin string, inCluster b
 		return errors.Wrap(err, "failed to create clientset")
 	}
 
-	secretName := GetSpecSecretName(app.Slug)
-
 	existingSecret, err := clientset.CoreV1().Secrets(os.Getenv("POD_NAMESPACE")).Get(context.TODO(), secretName, metav1.GetOptions{})
 	if err != nil && !kuberneteserrors.IsNotFound(err) {
 		return errors.Wrap(err, "failed to read preflight secret")
@@ -0,0 +1,285 @@
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
+	registrytypes "github.com/replicatedhq/kots/kotsadm/pkg/registry/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/render"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	"github.com/replicatedhq/kots/pkg/kotsutil"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// strictPreflightsTimeout is how long automated deploys wait for the preflight checks of a new version
+const strictPreflightsTimeout = 15 * time.Minute
+
+// StrictPreflightsError is returned when a version can't be deployed because its strict preflight checks have not passed
+type StrictPreflightsError struct {
+	Sequence int64
+	Failures []string
+	Reason   string
+	Pending  bool
+}
+
+func (e StrictPreflightsError) Error() string {
+	if len(e.Failures) > 0 {
+		return fmt.Sprintf("cannot deploy sequence %d because strict preflight checks failed: %s", e.Sequence, strings.Join(e.Failures, ", "))
+	}
+	return fmt.Sprintf("cannot deploy sequence %d because strict preflight checks have not passed: %s", e.Sequence, e.Reason)
+}
+
+type strictPreflightSpec struct {
+	Kind       string `yaml:"kind"`
+	APIVersion string `yaml:"apiVersion"`
+	Spec       struct {
+		Analyzers []map[string]strictAnalyzer `yaml:"analyzers"`
+	} `yaml:"spec"`
+}
+
+// strictAnalyzer is the part of an analyzer that troubleshoot does not decode
+type strictAnalyzer struct {
+	Strict interface{} `yaml:"strict"`
+}
+
+func (a strictAnalyzer) isStrict() bool {
+	switch strict := a.Strict.(type) {
+	case bool:
+		return strict
+	case string:
+		b, _ := strconv.ParseBool(strict)
+		return b
+	}
+	return false
+}
+
+// CheckStrictPreflights returns a StrictPreflightsError if the preflight spec of the version has strict analyzers,
+// and their results failed, errored or are not in yet. Versions with an admin override are not checked.
+func CheckStrictPreflights(appID string, sequence int64) error {
+	a, err := store.GetStore().GetApp(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app")
+	}
+
+	archiveDir, err := ioutil.TempDir("", "kotsadm")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(archiveDir)
+
+	if err := store.GetStore().GetAppVersionArchive(appID, sequence, archiveDir); err != nil {
+		return errors.Wrap(err, "failed to get app version archive")
+	}
+
+	hasStrictAnalyzers, err := HasStrictAnalyzers(appID, a.Slug, sequence, a.IsAirgap, archiveDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to check for strict analyzers")
+	}
+	if !hasStrictAnalyzers {
+		return nil
+	}
+
+	result, err := store.GetStore().GetPreflightResults(appID, sequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to get preflight results")
+	}
+	if result.StrictOverrideAt != nil {
+		return nil
+	}
+
+	return checkStrictResults(sequence, result.Result)
+}
+
+// WaitForStrictPreflights is CheckStrictPreflights for a version whose preflight checks were just started,
+// it waits for their results when the version has strict analyzers
+func WaitForStrictPreflights(appID string, sequence int64) error {
+	err := CheckStrictPreflights(appID, sequence)
+	if e, ok := errors.Cause(err).(StrictPreflightsError); !ok || !e.Pending {
+		return err
+	}
+
+	if err := waitForPreflightResults(appID, sequence); err != nil {
+		return errors.Wrap(err, "failed to wait for preflight results")
+	}
+
+	return CheckStrictPreflights(appID, sequence)
+}
+
+func waitForPreflightResults(appID string, sequence int64) error {
+	start := time.Now()
+	for {
+		result, err := store.GetStore().GetPreflightResults(appID, sequence)
+		if err != nil {
+			return errors.Wrap(err, "failed to get preflight results")
+		}
+		if result.Result != "" {
+			return nil
+		}
+		if time.Since(start) > strictPreflightsTimeout {
+			return errors.Errorf("timed out after %s", strictPreflightsTimeout)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// OverrideStrictPreflights records when and by whom the version was deployed even though its strict preflight checks have not passed.
+// overrideBy is the user of the admin console session, or empty when the override comes from the cli.
+func OverrideStrictPreflights(appID string, sequence int64, source string, overrideBy string) error {
+	logger.Info("overriding strict preflight checks",
+		zap.String("appID", appID),
+		zap.Int64("sequence", sequence),
+		zap.String("source", source),
+		zap.String("by", overrideBy))
+
+	if err := store.GetStore().SetPreflightStrictOverride(appID, sequence, source, overrideBy); err != nil {
+		return errors.Wrap(err, "failed to set strict preflight override")
+	}
+
+	return nil
+}
+
+// HasStrictAnalyzers returns true if the preflight spec in the archive has an analyzer that is marked strict
+func HasStrictAnalyzers(appID string, appSlug string, sequence int64, isAirgap bool, archiveDir string) (bool, error) {
+	kotsKinds, err := kotsutil.LoadKotsKindsFromPath(archiveDir)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to load kots kinds")
+	}
+	if kotsKinds.Preflight == nil {
+		return false, nil
+	}
+
+	registrySettings, err := store.GetStore().GetRegistryDetailsForApp(appID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get registry settings for app")
+	}
+
+	strictAnalyzers, err := loadStrictAnalyzers(kotsKinds, registrySettings, appSlug, sequence, isAirgap, archiveDir)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to load strict analyzers")
+	}
+
+	for _, strict := range strictAnalyzers {
+		if strict {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// loadStrictAnalyzers returns the strict flag of each analyzer of the preflight spec in the archive, in the order of the analyzers
+func loadStrictAnalyzers(kotsKinds *kotsutil.KotsKinds, registrySettings *registrytypes.RegistrySettings, appSlug string, sequence int64, isAirgap bool, archiveDir string) ([]bool, error) {
+	spec, err := findPreflightSpec(archiveDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find preflight spec")
+	}
+	if spec == nil {
+		return []bool{}, nil
+	}
+
+	renderedSpec, err := render.RenderFile(kotsKinds, registrySettings, appSlug, sequence, isAirgap, spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render preflight spec")
+	}
+
+	return getStrictAnalyzers(renderedSpec)
+}
+
+// findPreflightSpec returns the contents of the preflight spec in the archive, or nil if there's none.
+// Like LoadKotsKindsFromPath, the last spec that is found is used.
+func findPreflightSpec(archiveDir string) ([]byte, error) {
+	var spec []byte
+	err := filepath.Walk(archiveDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if isPreflightSpec(contents) {
+			spec = contents
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk archive")
+	}
+
+	return spec, nil
+}
+
+func isPreflightSpec(contents []byte) bool {
+	s := strictPreflightSpec{}
+	if err := yaml.Unmarshal(contents, &s); err != nil {
+		return false // not an error because the file might not be yaml
+	}
+	return s.Kind == "Preflight" && strings.HasPrefix(s.APIVersion, "troubleshoot.")
+}
+
+// getStrictAnalyzers reads the strict flags from a rendered preflight spec, troubleshoot drops the field when it decodes the spec
+func getStrictAnalyzers(spec []byte) ([]bool, error) {
+	s := strictPreflightSpec{}
+	if err := yaml.Unmarshal(spec, &s); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal preflight spec")
+	}
+
+	strictAnalyzers := []bool{}
+	for _, analyzer := range s.Spec.Analyzers {
+		strict := false
+		for _, a := range analyzer {
+			strict = strict || a.isStrict()
+		}
+		strictAnalyzers = append(strictAnalyzers, strict)
+	}
+
+	return strictAnalyzers, nil
+}
+
+// checkStrictResults checks the stored preflight results of a version that has strict analyzers
+func checkStrictResults(sequence int64, result string) error {
+	if result == "" {
+		return StrictPreflightsError{Sequence: sequence, Reason: "preflight checks have not completed", Pending: true}
+	}
+
+	preflightResults := preflighttypes.PreflightResults{}
+	if err := json.Unmarshal([]byte(result), &preflightResults); err != nil {
+		return errors.Wrap(err, "failed to unmarshal preflight results")
+	}
+
+	if len(preflightResults.Errors) > 0 {
+		return StrictPreflightsError{Sequence: sequence, Reason: fmt.Sprintf("preflight checks could not run: %s", preflightResults.Errors[0].Error)}
+	}
+
+	// results that are uploaded by kubectl preflight don't say which analyzer they came from, so every failure counts as strict
+	hasStrictResults := false
+	for _, r := range preflightResults.Results {
+		hasStrictResults = hasStrictResults || r.Strict
+	}
+
+	failures := []string{}
+	for _, r := range preflightResults.Results {
+		if r.IsFail && (r.Strict || !hasStrictResults) {
+			failures = append(failures, r.Title)
+		}
+	}
+	if len(failures) > 0 {
+		return StrictPreflightsError{Sequence: sequence, Failures: failures}
+	}
+
+	return nil
+}
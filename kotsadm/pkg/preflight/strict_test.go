@@ -0,0 +1,101 @@
+package preflight
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getStrictAnalyzers(t *testing.T) {
+	spec := `apiVersion: troubleshoot.sh/v1beta2
+kind: Preflight
+metadata:
+  name: my-app
+spec:
+  analyzers:
+    - clusterVersion:
+        checkName: Kubernetes version
+        strict: true
+        outcomes:
+          - fail:
+              when: "< 1.16.0"
+              message: The application requires Kubernetes 1.16.0 or later
+    - nodeResources:
+        checkName: Node count
+        outcomes:
+          - warn:
+              when: "count() < 3"
+              message: At least 3 nodes are recommended
+    - storageClass:
+        checkName: Storage class
+        strict: "true"
+        storageClassName: default
+    - distribution:
+        strict: false
+`
+	strictAnalyzers, err := getStrictAnalyzers([]byte(spec))
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true, false}, strictAnalyzers)
+
+	assert.True(t, isPreflightSpec([]byte(spec)))
+	assert.False(t, isPreflightSpec([]byte("apiVersion: kots.io/v1beta1\nkind: Config\n")))
+	assert.False(t, isPreflightSpec([]byte("not: [yaml")))
+}
+
+func Test_checkStrictResults(t *testing.T) {
+	tests := []struct {
+		name         string
+		result       string
+		wantFailures []string
+		wantPending  bool
+		wantErr      bool
+	}{
+		{
+			name:        "not run",
+			result:      "",
+			wantPending: true,
+			wantErr:     true,
+		},
+		{
+			name:   "strict passed, other failed",
+			result: `{"results":[{"isPass":true,"title":"Kubernetes version","strict":true},{"isFail":true,"title":"Node count"}]}`,
+		},
+		{
+			name:         "strict failed",
+			result:       `{"results":[{"isFail":true,"title":"Kubernetes version","strict":true},{"isFail":true,"title":"Node count"}]}`,
+			wantFailures: []string{"Kubernetes version"},
+			wantErr:      true,
+		},
+		{
+			name:         "uploaded by kubectl preflight",
+			result:       `{"results":[{"isPass":true,"title":"Kubernetes version"},{"isFail":true,"title":"Node count"}]}`,
+			wantFailures: []string{"Node count"},
+			wantErr:      true,
+		},
+		{
+			name:    "rbac errors",
+			result:  `{"errors":[{"error":"cannot list nodes"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkStrictResults(2, test.result)
+			if !test.wantErr {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			strictErr, ok := err.(StrictPreflightsError)
+			require.True(t, ok)
+			assert.Equal(t, int64(2), strictErr.Sequence)
+			assert.Equal(t, test.wantPending, strictErr.Pending)
+			if test.wantFailures != nil {
+				assert.Equal(t, test.wantFailures, strictErr.Failures)
+			}
+		})
+	}
+}
@@ -1,10 +1,71 @@
 package types
 
-import "time"
+import (
+	"time"
+
+	troubleshootpreflight "github.com/replicatedhq/troubleshoot/pkg/preflight"
+)
 
 type PreflightResult struct {
-	Result      string     `json:"result"`
-	CreatedAt   *time.Time `json:"createdAt"`
-	AppSlug     string     `json:"appSlug"`
-	ClusterSlug string     `json:"clusterSlug"`
+	Result               string     `json:"result"`
+	CreatedAt            *time.Time `json:"createdAt"`
+	AppSlug              string     `json:"appSlug"`
+	ClusterSlug          string     `json:"clusterSlug"`
+	StrictOverrideAt     *time.Time `json:"strictOverrideAt"`
+	StrictOverrideSource string     `json:"strictOverrideSource"`
+	StrictOverrideBy     string     `json:"strictOverrideBy"`
+}
+
+// PreflightResults are the results that are stored for a version when the preflight checks run in the cluster.
+// They are the uploaded results of troubleshoot, with the results of strict analyzers marked.
+type PreflightResults struct {
+	Results []*PreflightCheckResult                       `json:"results,omitempty"`
+	Errors  []*troubleshootpreflight.UploadPreflightError `json:"errors,omitempty"`
+}
+
+type PreflightCheckResult struct {
+	troubleshootpreflight.UploadPreflightResult
+	Strict bool `json:"strict,omitempty"`
+}
+
+const (
+	CheckRunStatusPending   = "pending"
+	CheckRunStatusRunning   = "running"
+	CheckRunStatusCompleted = "completed"
+	// CheckRunStatusFailed is set on runs that did not report results in time
+	CheckRunStatusFailed = "failed"
+)
+
+// CheckPolicy re-runs the preflight checks of the deployed version on a schedule, to catch cluster changes after the deploy
+type CheckPolicy struct {
+	// Schedule is a cron spec
+	Schedule string `json:"schedule"`
+	// WebhookURL is called when the outcome of a check changes from pass to warn or fail
+	WebhookURL string `json:"webhookUrl,omitempty"`
+}
+
+func (p *CheckPolicy) IsEnabled() bool {
+	return p != nil && p.Schedule != "" && p.Schedule != "@never"
+}
+
+// CheckRun is one scheduled run of the preflight checks of a deployed version
+type CheckRun struct {
+	ID          string        `json:"id"`
+	AppID       string        `json:"appId"`
+	ClusterID   string        `json:"clusterId"`
+	Sequence    int64         `json:"sequence"`
+	Status      string        `json:"status"`
+	State       string        `json:"state,omitempty"`
+	Result      string        `json:"result,omitempty"`
+	Changes     []CheckChange `json:"changes"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	StartedAt   *time.Time    `json:"startedAt,omitempty"`
+	CompletedAt *time.Time    `json:"completedAt,omitempty"`
+}
+
+// CheckChange is a check whose outcome changed from pass to warn or fail since the previous run
+type CheckChange struct {
+	Title string `json:"title"`
+	From  string `json:"from"`
+	To    string `json:"to"`
 }
@@ -0,0 +1,259 @@
+package redact
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	redacttypes "github.com/replicatedhq/kots/pkg/api/redact/types"
+	troubleshootv1beta2 "github.com/replicatedhq/troubleshoot/pkg/apis/troubleshoot/v1beta2"
+	troubleshootredact "github.com/replicatedhq/troubleshoot/pkg/redact"
+)
+
+const (
+	maxPreviewSnippets = 5
+	maxSnippetLength   = 200
+)
+
+// troubleshoot keeps a global list of the redactions it made, previews are run one at a time so that the list can be cleared
+var previewMutex sync.Mutex
+
+// redactFile runs the troubleshoot redactors on the content of a file of the bundle, the default redactors are always applied
+var redactFile = func(content []byte, path string, redacts []*troubleshootv1beta2.Redact) ([]byte, error) {
+	return troubleshootredact.Redact(content, path, redacts)
+}
+
+// fileRedactions returns the redactions that troubleshoot made in the file since the list was last reset
+var fileRedactions = func(path string) []troubleshootredact.Redaction {
+	return troubleshootredact.GetRedactionList().ByFile[path]
+}
+
+type previewRemoval struct {
+	preview *redacttypes.RemovalPreview
+	redact  *troubleshootv1beta2.Redact
+}
+
+// ParseSpec parses a redactor spec that is previewed before it's saved
+func ParseSpec(spec string) (*troubleshootv1beta2.Redactor, error) {
+	return parseRedact([]byte(spec))
+}
+
+// Preview runs every value, regex and yaml path of the redactor on its own against the files of the bundle,
+// and compares the result with the files redacted by the default redactors only
+func Preview(bundleDir string, redactor *troubleshootv1beta2.Redactor) (*redacttypes.RedactPreview, error) {
+	previewMutex.Lock()
+	defer previewMutex.Unlock()
+	defer troubleshootredact.ResetRedactionList()
+
+	removals := splitRemovals(redactor)
+	allRedacts := []*troubleshootv1beta2.Redact{}
+	for _, redact := range redactor.Spec.Redactors {
+		if redact != nil {
+			allRedacts = append(allRedacts, redact)
+		}
+	}
+
+	preview := &redacttypes.RedactPreview{
+		Files:    []redacttypes.FilePreview{},
+		Removals: []redacttypes.RemovalPreview{},
+	}
+
+	err := filepath.Walk(bundleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(bundleDir, path)
+		if err != nil {
+			return errors.Wrap(err, "failed to get relative path")
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", relPath)
+		}
+
+		filePreview, err := previewFile(content, relPath, allRedacts, removals)
+		if err != nil {
+			return errors.Wrapf(err, "failed to preview %s", relPath)
+		}
+		if filePreview != nil {
+			preview.Files = append(preview.Files, *filePreview)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk bundle")
+	}
+
+	sort.Slice(preview.Files, func(i, j int) bool {
+		return preview.Files[i].Path < preview.Files[j].Path
+	})
+
+	for _, removal := range removals {
+		removal.preview.NeverMatched = removal.preview.Matches == 0
+		preview.Removals = append(preview.Removals, *removal.preview)
+	}
+
+	return preview, nil
+}
+
+func previewFile(content []byte, path string, allRedacts []*troubleshootv1beta2.Redact, removals []previewRemoval) (*redacttypes.FilePreview, error) {
+	baseline, err := redactFile(content, path, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run default redactors")
+	}
+
+	// most files are not changed by the spec, they are skipped without running each removal
+	redacted, err := redactFile(content, path, allRedacts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run redactors")
+	}
+	if bytes.Equal(baseline, redacted) {
+		return nil, nil
+	}
+
+	filePreview := &redacttypes.FilePreview{
+		Path:     path,
+		Snippets: []redacttypes.Snippet{},
+	}
+
+	baselineLines := strings.Split(string(baseline), "\n")
+	for _, removal := range removals {
+		troubleshootredact.ResetRedactionList()
+		redacted, err := redactFile(content, path, []*troubleshootv1beta2.Redact{removal.redact})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to run redactor %s", removal.preview.Redactor)
+		}
+
+		// every redaction is a match, there can be more than one on a line and multi line redactors change the line count
+		redactedLines := strings.Split(string(redacted), "\n")
+		for _, redaction := range fileRedactions(path) {
+			if redaction.IsDefaultRedactor {
+				continue
+			}
+
+			removal.preview.Matches++
+			filePreview.Matches++
+			if len(filePreview.Snippets) < maxPreviewSnippets && !hasSnippet(filePreview.Snippets, redaction.Line, removal.preview.Redactor) {
+				before, after := snippet(lineAt(baselineLines, redaction.Line-1), lineAt(redactedLines, redaction.Line-1))
+				filePreview.Snippets = append(filePreview.Snippets, redacttypes.Snippet{
+					Line:     redaction.Line,
+					Redactor: removal.preview.Redactor,
+					Before:   before,
+					After:    after,
+				})
+			}
+		}
+	}
+
+	return filePreview, nil
+}
+
+// splitRemovals returns a redactor for each value, regex and yaml path of the spec, with the file selector of the redactor it's in
+func splitRemovals(redactor *troubleshootv1beta2.Redactor) []previewRemoval {
+	removals := []previewRemoval{}
+	for i, redact := range redactor.Spec.Redactors {
+		if redact == nil {
+			continue
+		}
+
+		name := redact.Name
+		if name == "" {
+			name = fmt.Sprintf("redactor-%d", i+1)
+		}
+
+		newRemoval := func(removalType string, index int, pattern string, removals troubleshootv1beta2.Removals) previewRemoval {
+			return previewRemoval{
+				preview: &redacttypes.RemovalPreview{
+					Redactor: name,
+					Type:     removalType,
+					Index:    index,
+					Pattern:  pattern,
+				},
+				redact: &troubleshootv1beta2.Redact{
+					Name:         redact.Name,
+					FileSelector: redact.FileSelector,
+					Removals:     removals,
+				},
+			}
+		}
+
+		for j, value := range redact.Removals.Values {
+			removals = append(removals, newRemoval(redacttypes.RemovalTypeValue, j, "", troubleshootv1beta2.Removals{
+				Values: []string{value},
+			}))
+		}
+		for j, regex := range redact.Removals.Regex {
+			pattern := regex.Redactor
+			if regex.Selector != "" {
+				pattern = fmt.Sprintf("%s %s", regex.Selector, regex.Redactor)
+			}
+			removals = append(removals, newRemoval(redacttypes.RemovalTypeRegex, j, pattern, troubleshootv1beta2.Removals{
+				Regex: []troubleshootv1beta2.Regex{regex},
+			}))
+		}
+		for j, yamlPath := range redact.Removals.YamlPath {
+			removals = append(removals, newRemoval(redacttypes.RemovalTypeYamlPath, j, yamlPath, troubleshootv1beta2.Removals{
+				YamlPath: []string{yamlPath},
+			}))
+		}
+	}
+	return removals
+}
+
+func hasSnippet(snippets []redacttypes.Snippet, line int, redactor string) bool {
+	for _, s := range snippets {
+		if s.Line == line && s.Redactor == redactor {
+			return true
+		}
+	}
+	return false
+}
+
+func lineAt(lines []string, i int) string {
+	if i >= 0 && i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+// snippet shortens long lines to the part around the first change
+func snippet(before string, after string) (string, string) {
+	if len(before) <= maxSnippetLength && len(after) <= maxSnippetLength {
+		return before, after
+	}
+
+	start := 0
+	for start < len(before) && start < len(after) && before[start] == after[start] {
+		start++
+	}
+	start -= maxSnippetLength / 4
+	if start < 0 {
+		start = 0
+	}
+
+	return truncate(before, start), truncate(after, start)
+}
+
+func truncate(line string, start int) string {
+	if start >= len(line) {
+		return ""
+	}
+	end := start + maxSnippetLength
+	if end > len(line) {
+		end = len(line)
+	}
+	return line[start:end]
+}
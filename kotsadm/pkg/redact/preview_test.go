@@ -0,0 +1,138 @@
+package redact
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	redacttypes "github.com/replicatedhq/kots/pkg/api/redact/types"
+	troubleshootv1beta2 "github.com/replicatedhq/troubleshoot/pkg/apis/troubleshoot/v1beta2"
+	troubleshootredact "github.com/replicatedhq/troubleshoot/pkg/redact"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedactFile masks values and regexes like the troubleshoot redactors, and masks "default-secret" as a default redactor.
+// The redactions are recorded for fakeFileRedactions.
+func fakeRedactFile(content []byte, path string, redacts []*troubleshootv1beta2.Redact) ([]byte, error) {
+	fakeRedactions = []troubleshootredact.Redaction{}
+
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		replace := func(re *regexp.Regexp, replacement string, isDefault bool) {
+			for range re.FindAllStringIndex(line, -1) {
+				fakeRedactions = append(fakeRedactions, troubleshootredact.Redaction{
+					File:              path,
+					Line:              i + 1,
+					IsDefaultRedactor: isDefault,
+				})
+			}
+			line = re.ReplaceAllString(line, replacement)
+		}
+
+		replace(regexp.MustCompile("default-secret"), "***HIDDEN***", true)
+		for _, redact := range redacts {
+			if redact.FileSelector.File != "" && redact.FileSelector.File != path {
+				continue
+			}
+			for _, value := range redact.Removals.Values {
+				replace(regexp.MustCompile(regexp.QuoteMeta(value)), "***HIDDEN***", false)
+			}
+			for _, regex := range redact.Removals.Regex {
+				replace(regexp.MustCompile(regex.Redactor), "${1}***HIDDEN***", false)
+			}
+		}
+		lines[i] = line
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+var fakeRedactions []troubleshootredact.Redaction
+
+func fakeFileRedactions(path string) []troubleshootredact.Redaction {
+	return fakeRedactions
+}
+
+func Test_Preview(t *testing.T) {
+	req := require.New(t)
+
+	defaultRedactFile, defaultFileRedactions := redactFile, fileRedactions
+	redactFile, fileRedactions = fakeRedactFile, fakeFileRedactions
+	defer func() {
+		redactFile, fileRedactions = defaultRedactFile, defaultFileRedactions
+	}()
+
+	bundleDir, err := ioutil.TempDir("", "kotsadm")
+	req.NoError(err)
+	defer os.RemoveAll(bundleDir)
+
+	files := map[string]string{
+		"app/logs/api.log":          "connecting with token abc123\nready\nretrying with token abc123 and default-secret\nabc123 rotated to abc123\n",
+		"app/logs/worker.log":       "password=hunter2\n",
+		"cluster-resources/ns.json": `{"name": "default"}`,
+		"long.log":                  strings.Repeat("x", 500) + "abc123" + strings.Repeat("y", 500),
+	}
+	for name, content := range files {
+		path := filepath.Join(bundleDir, filepath.FromSlash(name))
+		req.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		req.NoError(ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	redactor := &troubleshootv1beta2.Redactor{
+		Spec: troubleshootv1beta2.RedactorSpec{
+			Redactors: []*troubleshootv1beta2.Redact{
+				{
+					Name: "tokens",
+					Removals: troubleshootv1beta2.Removals{
+						Values: []string{"abc123", "never-seen"},
+					},
+				},
+				{
+					Name: "passwords",
+					FileSelector: troubleshootv1beta2.FileSelector{
+						File: "app/logs/worker.log",
+					},
+					Removals: troubleshootv1beta2.Removals{
+						Regex: []troubleshootv1beta2.Regex{
+							{Redactor: `(password=).*`},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	preview, err := Preview(bundleDir, redactor)
+	req.NoError(err)
+
+	req.Equal([]redacttypes.RemovalPreview{
+		{Redactor: "tokens", Type: redacttypes.RemovalTypeValue, Index: 0, Matches: 5},
+		{Redactor: "tokens", Type: redacttypes.RemovalTypeValue, Index: 1, Matches: 0, NeverMatched: true},
+		{Redactor: "passwords", Type: redacttypes.RemovalTypeRegex, Index: 0, Pattern: `(password=).*`, Matches: 1},
+	}, preview.Removals)
+
+	req.Len(preview.Files, 3)
+
+	req.Equal("app/logs/api.log", preview.Files[0].Path)
+	req.Equal(4, preview.Files[0].Matches)
+	req.Equal([]redacttypes.Snippet{
+		{Line: 1, Redactor: "tokens", Before: "connecting with token abc123", After: "connecting with token ***HIDDEN***"},
+		{Line: 3, Redactor: "tokens", Before: "retrying with token abc123 and ***HIDDEN***", After: "retrying with token ***HIDDEN*** and ***HIDDEN***"},
+		{Line: 4, Redactor: "tokens", Before: "abc123 rotated to abc123", After: "***HIDDEN*** rotated to ***HIDDEN***"},
+	}, preview.Files[0].Snippets)
+
+	req.Equal("app/logs/worker.log", preview.Files[1].Path)
+	req.Equal([]redacttypes.Snippet{
+		{Line: 1, Redactor: "passwords", Before: "password=hunter2", After: "password=***HIDDEN***"},
+	}, preview.Files[1].Snippets)
+
+	// long lines are shortened to the part around the change
+	req.Equal("long.log", preview.Files[2].Path)
+	snippet := preview.Files[2].Snippets[0]
+	req.Len(snippet.Before, maxSnippetLength)
+	req.Contains(snippet.Before, "abc123")
+	req.Contains(snippet.After, "***HIDDEN***")
+}
@@ -23,19 +23,6 @@ func replaceSecretsWithSealedSecrets(archivePath string, config map[string][]byt
 		return errors.Wrap(err, "failed to get secrets in path")
 	}
 
-	block, _ := pem.Decode(config["cert.pem"])
-	if block == nil {
-		return errors.New("unable to read public key from secret")
-	}
-
-	cert, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		return errors.Wrap(err, "failed to parse certificate")
-	}
-
-	sealedsecretsscheme.AddToScheme(scheme.Scheme)
-	codecFactory := serializer.NewCodecFactory(scheme.Scheme)
-
 	decode := scheme.Codecs.UniversalDeserializer().Decode
 	for _, secretPath := range secretPaths {
 		contents, err := ioutil.ReadFile(secretPath)
@@ -53,34 +40,63 @@ func replaceSecretsWithSealedSecrets(archivePath string, config map[string][]byt
 			return nil
 		}
 
-		// sealed secrets require a namespace
-		if secret.Namespace == "" {
-			if os.Getenv("DEV_NAMESPACE") != "" {
-				secret.Namespace = os.Getenv("DEV_NAMESPACE")
-			}
-
-			secret.Namespace = os.Getenv("POD_NAMESPACE")
+		sealedSecret, err := SealSecret(config["cert.pem"], secret)
+		if err != nil {
+			return errors.Wrap(err, "failed to seal secret")
 		}
 
-		sealedSecret, err := sealedsecretsv1alpha1.NewSealedSecret(codecFactory, cert.PublicKey.(*rsa.PublicKey), secret)
-		if err != nil {
-			return errors.Wrap(err, "failed to create sealedsecret")
+		if err := ioutil.WriteFile(secretPath, sealedSecret, 0644); err != nil {
+			return errors.Wrap(err, "failed to write sealed secret")
 		}
+	}
+
+	return nil
+}
+
+// SealSecret encrypts the secret with the public key in the sealed secrets controller certificate
+// and returns the SealedSecret yaml
+func SealSecret(certPEM []byte, secret *v1.Secret) ([]byte, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("unable to read public key from secret")
+	}
 
-		sealedSecret.APIVersion = "bitnami.com/v1alpha1"
-		sealedSecret.Kind = "SealedSecret"
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse certificate")
+	}
 
-		s := jsonserializer.NewYAMLSerializer(jsonserializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+	pubKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("certificate does not contain an rsa public key")
+	}
 
-		var b bytes.Buffer
-		if err := s.Encode(sealedSecret, &b); err != nil {
-			return errors.Wrap(err, "failed to serialized sealedsecret")
-		}
+	sealedsecretsscheme.AddToScheme(scheme.Scheme)
+	codecFactory := serializer.NewCodecFactory(scheme.Scheme)
 
-		if err := ioutil.WriteFile(secretPath, b.Bytes(), 0644); err != nil {
-			return errors.Wrap(err, "failed to write sealed secret")
+	// sealed secrets require a namespace
+	if secret.Namespace == "" {
+		if os.Getenv("DEV_NAMESPACE") != "" {
+			secret.Namespace = os.Getenv("DEV_NAMESPACE")
 		}
+
+		secret.Namespace = os.Getenv("POD_NAMESPACE")
 	}
 
-	return nil
+	sealedSecret, err := sealedsecretsv1alpha1.NewSealedSecret(codecFactory, pubKey, secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create sealedsecret")
+	}
+
+	sealedSecret.APIVersion = "bitnami.com/v1alpha1"
+	sealedSecret.Kind = "SealedSecret"
+
+	s := jsonserializer.NewYAMLSerializer(jsonserializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var b bytes.Buffer
+	if err := s.Encode(sealedSecret, &b); err != nil {
+		return nil, errors.Wrap(err, "failed to serialized sealedsecret")
+	}
+
+	return b.Bytes(), nil
 }
@@ -49,6 +49,35 @@ func CreateApplicationBackup(ctx context.Context, a *apptypes.App, isScheduled b
 		return nil, errors.New("app does not have a deployed version")
 	}
 
+	snapshotTrigger := "manual"
+	if isScheduled {
+		snapshotTrigger = "schedule"
+	}
+
+	return createApplicationBackup(ctx, a, parentSequence, snapshotTrigger)
+}
+
+// CreatePreUpgradeBackup backs up the version that is about to be replaced by an upgrade.
+// The current sequence already points at the new version by the time this is called, so the
+// outgoing parent sequence has to be passed in explicitly.
+func CreatePreUpgradeBackup(ctx context.Context, a *apptypes.App, clusterID string, outgoingParentSequence int64) (*velerov1.Backup, error) {
+	switch a.PreUpgradeSnapshot {
+	case types.PreUpgradeSnapshotApplication:
+		return createApplicationBackup(ctx, a, outgoingParentSequence, "pre-upgrade")
+
+	case types.PreUpgradeSnapshotInstance:
+		cluster, err := store.GetStore().GetDownstream(clusterID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get downstream")
+		}
+		return createInstanceBackup(ctx, cluster, "pre-upgrade", map[string]int64{a.ID: outgoingParentSequence})
+
+	default:
+		return nil, errors.Errorf("unknown pre-upgrade snapshot type %q", a.PreUpgradeSnapshot)
+	}
+}
+
+func createApplicationBackup(ctx context.Context, a *apptypes.App, parentSequence int64, snapshotTrigger string) (*velerov1.Backup, error) {
 	logger.Debug("creating backup",
 		zap.String("appID", a.ID),
 		zap.Int64("sequence", parentSequence))
@@ -96,11 +125,6 @@ func CreateApplicationBackup(ctx context.Context, a *apptypes.App, isScheduled b
 	includedNamespaces := []string{appNamespace}
 	includedNamespaces = append(includedNamespaces, kotsKinds.KotsApplication.Spec.AdditionalNamespaces...)
 
-	snapshotTrigger := "manual"
-	if isScheduled {
-		snapshotTrigger = "schedule"
-	}
-
 	veleroBackup.Name = ""
 	veleroBackup.GenerateName = a.Slug + "-"
 
@@ -136,6 +160,13 @@ func CreateApplicationBackup(ctx context.Context, a *apptypes.App, isScheduled b
 		}
 	}
 
+	if snapshotTrigger == "schedule" && a.SnapshotRetentionPolicy.IsEnabled() {
+		// scheduled backups are pruned by the retention policy, they only expire once the policy can't keep them anymore
+		veleroBackup.Spec.TTL = metav1.Duration{
+			Duration: a.SnapshotRetentionPolicy.MaxAge(),
+		}
+	}
+
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get cluster config")
@@ -146,6 +177,10 @@ func CreateApplicationBackup(ctx context.Context, a *apptypes.App, isScheduled b
 		return nil, errors.Wrap(err, "failed to create clientset")
 	}
 
+	if err := annotateBackupEncryption(veleroBackup); err != nil {
+		return nil, errors.Wrap(err, "failed to annotate backup encryption")
+	}
+
 	backup, err := veleroClient.Backups(kotsadmVeleroBackendStorageLocation.Namespace).Create(ctx, veleroBackup, metav1.CreateOptions{})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create velero backup")
@@ -155,6 +190,17 @@ func CreateApplicationBackup(ctx context.Context, a *apptypes.App, isScheduled b
 }
 
 func CreateInstanceBackup(ctx context.Context, cluster *downstreamtypes.Downstream, isScheduled bool) (*velerov1.Backup, error) {
+	snapshotTrigger := "manual"
+	if isScheduled {
+		snapshotTrigger = "schedule"
+	}
+
+	return createInstanceBackup(ctx, cluster, snapshotTrigger, nil)
+}
+
+// createInstanceBackup backs up all installed apps at their current sequence, unless a different
+// parent sequence is given for the app id in parentSequenceOverrides
+func createInstanceBackup(ctx context.Context, cluster *downstreamtypes.Downstream, snapshotTrigger string, parentSequenceOverrides map[string]int64) (*velerov1.Backup, error) {
 	logger.Debug("creating instance backup")
 
 	apps, err := store.GetStore().ListInstalledApps()
@@ -190,6 +236,9 @@ func CreateInstanceBackup(ctx context.Context, cluster *downstreamtypes.Downstre
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to get current downstream parent sequence for app %s", a.Slug)
 		}
+		if s, ok := parentSequenceOverrides[a.ID]; ok {
+			parentSequence = s
+		}
 		if parentSequence == -1 {
 			// no version is deployed for this app yet
 			continue
@@ -248,11 +297,6 @@ func CreateInstanceBackup(ctx context.Context, cluster *downstreamtypes.Downstre
 		return nil, errors.Wrap(err, "failed to find kotsadm image")
 	}
 
-	snapshotTrigger := "manual"
-	if isScheduled {
-		snapshotTrigger = "schedule"
-	}
-
 	// marshal apps sequences map
 	b, err := json.Marshal(appsSequences)
 	if err != nil {
@@ -299,6 +343,13 @@ func CreateInstanceBackup(ctx context.Context, cluster *downstreamtypes.Downstre
 		}
 	}
 
+	if snapshotTrigger == "schedule" && cluster.SnapshotRetentionPolicy.IsEnabled() {
+		// scheduled backups are pruned by the retention policy, they only expire once the policy can't keep them anymore
+		veleroBackup.Spec.TTL = metav1.Duration{
+			Duration: cluster.SnapshotRetentionPolicy.MaxAge(),
+		}
+	}
+
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get cluster config")
@@ -309,6 +360,10 @@ func CreateInstanceBackup(ctx context.Context, cluster *downstreamtypes.Downstre
 		return nil, errors.Wrap(err, "failed to create clientset")
 	}
 
+	if err := annotateBackupEncryption(veleroBackup); err != nil {
+		return nil, errors.Wrap(err, "failed to annotate backup encryption")
+	}
+
 	backup, err := veleroClient.Backups(kotsadmVeleroBackendStorageLocation.Namespace).Create(ctx, veleroBackup, metav1.CreateOptions{})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create velero backup")
@@ -738,6 +793,9 @@ func listBackupVolumes(backupVolumes []velerov1.PodVolumeBackup) []types.Snapsho
 	for _, backupVolume := range backupVolumes {
 		v := types.SnapshotVolume{
 			Name:           backupVolume.Name,
+			PodName:        backupVolume.Spec.Pod.Name,
+			PodNamespace:   backupVolume.Spec.Pod.Namespace,
+			PodVolumeName:  backupVolume.Spec.Volume,
 			SizeBytesHuman: units.HumanSize(float64(backupVolume.Status.Progress.TotalBytes)),
 			DoneBytesHuman: units.HumanSize(float64(backupVolume.Status.Progress.BytesDone)),
 			Phase:          string(backupVolume.Status.Phase),
@@ -0,0 +1,378 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/hmac"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	"github.com/replicatedhq/kots/pkg/snapshot/encryption"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	// SnapshotEncryptionSecretName holds the key and the envelope of an encrypted snapshot store in the velero namespace
+	SnapshotEncryptionSecretName = "kotsadm-snapshot-encryption"
+
+	// EncryptionEnvelopeName is the object at the root of the store path with the envelope of an encrypted store
+	EncryptionEnvelopeName = "kotsadm-encryption.json"
+
+	resticCredentialsSecretName = "velero-restic-credentials"
+	defaultResticPassword       = "static-passw0rd"
+)
+
+// newKeyManagementService returns the kms that wraps the data key of the store, tests replace it with a local stand-in
+var newKeyManagementService = func(store *types.Store, keyID string) (encryption.KeyManagementService, error) {
+	if store.AWS == nil {
+		return nil, errors.New("kms keys are only supported with aws s3 stores")
+	}
+	return encryption.NewAWSKeyManagementService(awsStoreConfig(store.AWS), keyID)
+}
+
+// openStoreEncryption returns the envelope and data key of a store that has encrypted snapshots.
+// Nothing is returned if the store has no encrypted snapshots yet.
+// A store that already has snapshots can't be encrypted because restic repositories keep the password they were created with.
+func openStoreEncryption(store *types.Store, objectStore objectStore) (*encryption.Envelope, []byte, error) {
+	envelope, err := readEncryptionEnvelope(objectStore, store.Path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read encryption envelope")
+	}
+
+	if envelope != nil {
+		if store.Encryption == nil {
+			return nil, nil, encryption.ErrKeyRequired
+		}
+		kms, err := storeKeyManagementService(store)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to create key management service")
+		}
+		dataKey, err := envelope.DataKey(store.Encryption, kms)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get data key")
+		}
+		return envelope, dataKey, nil
+	}
+
+	if store.Encryption == nil {
+		return nil, nil, nil
+	}
+
+	if err := store.Encryption.Validate(); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid encryption key")
+	}
+
+	hasSnapshots, err := storeHasSnapshots(objectStore, store.Path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to check for snapshots")
+	}
+	if hasSnapshots {
+		return nil, nil, errors.New("encryption can only be enabled on a store path without unencrypted snapshots")
+	}
+
+	return nil, nil, nil
+}
+
+func storeHasSnapshots(objectStore objectStore, storePrefix string) (bool, error) {
+	for _, prefix := range []string{"backups", "restic"} {
+		objects, err := objectStore.ListObjects(objectKey(storePrefix, prefix) + "/")
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to list %s", prefix)
+		}
+		if len(objects) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// planImportEncryption checks that a backup exported with the envelope can be imported into the store.
+// The restic repositories of the backup keep the password of the store they were exported from, so a store without
+// snapshots adopts the envelope of the backup, and the envelope and data key to adopt are returned.
+// The data key is only returned when the store is configured with the key of the envelope.
+func planImportEncryption(store *types.Store, objectStore objectStore, imported *encryption.Envelope, hasVolumes bool) (*encryption.Envelope, []byte, error) {
+	existing, err := readEncryptionEnvelope(objectStore, store.Path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read encryption envelope")
+	}
+
+	if imported == nil {
+		if existing != nil && hasVolumes {
+			return nil, nil, errors.New("the backup is not encrypted and its volumes cannot be imported into an encrypted snapshot store")
+		}
+		return nil, nil, nil
+	}
+
+	if existing != nil && hmac.Equal(existing.KeyCheck, imported.KeyCheck) {
+		return nil, nil, nil
+	}
+
+	hasSnapshots, err := storeHasSnapshots(objectStore, store.Path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to check for snapshots")
+	}
+	if hasSnapshots {
+		if existing != nil {
+			return nil, nil, errors.New("the backup is encrypted with a different key than the snapshots in the snapshot store")
+		}
+		return nil, nil, errors.New("the backup is encrypted and cannot be imported into a snapshot store with unencrypted snapshots")
+	}
+
+	if store.Encryption == nil {
+		return imported, nil, nil
+	}
+
+	kms, err := storeKeyManagementService(store)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create key management service")
+	}
+	dataKey, err := imported.DataKey(store.Encryption, kms)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "the snapshot store is not configured with the key the backup was encrypted with")
+	}
+
+	return imported, dataKey, nil
+}
+
+// adoptStoreEncryption replaces the envelope of the store with the envelope of an imported backup.
+// Without a data key the store requires its key to be configured before it can be used.
+func adoptStoreEncryption(clientset kubernetes.Interface, veleroNamespace string, store *types.Store, objectStore objectStore, envelope *encryption.Envelope, dataKey []byte) error {
+	if err := objectStore.PutObject(objectKey(store.Path, EncryptionEnvelopeName), strings.NewReader(envelope.String())); err != nil {
+		return errors.Wrap(err, "failed to write encryption envelope")
+	}
+
+	if dataKey == nil {
+		return nil
+	}
+
+	if err := updateEncryptionSecrets(clientset, veleroNamespace, store, envelope, dataKey); err != nil {
+		return errors.Wrap(err, "failed to update encryption secrets")
+	}
+
+	return nil
+}
+
+// initStoreEncryption creates the envelope of a store that is encrypted for the first time
+func initStoreEncryption(store *types.Store, objectStore objectStore) (*encryption.Envelope, []byte, error) {
+	envelope, dataKey, err := openStoreEncryption(store, objectStore)
+	if err != nil {
+		return nil, nil, err
+	}
+	if envelope != nil || store.Encryption == nil {
+		return envelope, dataKey, nil
+	}
+
+	kms, err := storeKeyManagementService(store)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create key management service")
+	}
+
+	envelope, dataKey, err = encryption.NewEnvelope(store.Encryption, kms)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create envelope")
+	}
+
+	if err := objectStore.PutObject(objectKey(store.Path, EncryptionEnvelopeName), strings.NewReader(envelope.String())); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to write encryption envelope")
+	}
+
+	return envelope, dataKey, nil
+}
+
+// IsMetadataEncrypted reports if velero encrypts the backup metadata of the store, which it only does server side with aws kms keys
+func IsMetadataEncrypted(store *types.Store) bool {
+	return store != nil && store.AWS != nil && store.Encryption.Type() == encryption.KeyTypeKMS
+}
+
+// checkMetadataEncryption refuses keys that would only encrypt the volume data of the store.
+// Velero writes the velero-backup.json and the resources of a backup itself, and only encrypts them with aws kms keys.
+func checkMetadataEncryption(store *types.Store) error {
+	if store.Encryption == nil || IsMetadataEncrypted(store) {
+		return nil
+	}
+	return errors.New("backup metadata can only be encrypted with an aws kms key, a passphrase or a kms key on another provider would leave it unencrypted")
+}
+
+func storeKeyManagementService(store *types.Store) (encryption.KeyManagementService, error) {
+	if store.Encryption.Type() != encryption.KeyTypeKMS {
+		return nil, nil
+	}
+	return newKeyManagementService(store, store.Encryption.KMSKeyID)
+}
+
+func readEncryptionEnvelope(objectStore objectStore, storePrefix string) (*encryption.Envelope, error) {
+	key := objectKey(storePrefix, EncryptionEnvelopeName)
+
+	exists, err := objectStore.ObjectExists(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check if envelope exists")
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	r, err := objectStore.GetObject(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get envelope")
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read envelope")
+	}
+
+	return encryption.ParseEnvelope(string(data))
+}
+
+// updateEncryptionSecrets saves the key of the store and sets the password of new restic repositories
+func updateEncryptionSecrets(clientset kubernetes.Interface, veleroNamespace string, store *types.Store, envelope *encryption.Envelope, dataKey []byte) error {
+	if envelope == nil {
+		err := clientset.CoreV1().Secrets(veleroNamespace).Delete(context.TODO(), SnapshotEncryptionSecretName, metav1.DeleteOptions{})
+		if err != nil && !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to delete encryption secret")
+		}
+		if err := setResticPassword(clientset, veleroNamespace, defaultResticPassword); err != nil {
+			return errors.Wrap(err, "failed to reset restic password")
+		}
+		return nil
+	}
+
+	data := map[string][]byte{
+		"passphrase": []byte(store.Encryption.Passphrase),
+		"kmsKeyId":   []byte(store.Encryption.KMSKeyID),
+		"envelope":   []byte(envelope.String()),
+	}
+	if err := upsertSecret(clientset, veleroNamespace, SnapshotEncryptionSecretName, data); err != nil {
+		return errors.Wrap(err, "failed to save encryption secret")
+	}
+
+	if err := setResticPassword(clientset, veleroNamespace, encryption.ResticPassword(dataKey)); err != nil {
+		return errors.Wrap(err, "failed to set restic password")
+	}
+
+	return nil
+}
+
+func setResticPassword(clientset kubernetes.Interface, veleroNamespace string, password string) error {
+	return upsertSecret(clientset, veleroNamespace, resticCredentialsSecretName, map[string][]byte{
+		"repository-password": []byte(password),
+	})
+}
+
+func upsertSecret(clientset kubernetes.Interface, namespace string, name string, data map[string][]byte) error {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get secret")
+	}
+
+	if kuberneteserrors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Secret",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Data: data,
+		}
+		if _, err := clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, "failed to create secret")
+		}
+		return nil
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for key, value := range data {
+		secret.Data[key] = value
+	}
+	if _, err := clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, "failed to update secret")
+	}
+
+	return nil
+}
+
+// getStoreEncryption returns the key and the envelope of the store, nothing is returned if the store is not encrypted
+func getStoreEncryption(clientset kubernetes.Interface, veleroNamespace string) (*encryption.Key, *encryption.Envelope, error) {
+	secret, err := clientset.CoreV1().Secrets(veleroNamespace).Get(context.TODO(), SnapshotEncryptionSecretName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get encryption secret")
+	}
+
+	key := &encryption.Key{
+		Passphrase: string(secret.Data["passphrase"]),
+		KMSKeyID:   string(secret.Data["kmsKeyId"]),
+	}
+	envelope, err := encryption.ParseEnvelope(string(secret.Data["envelope"]))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse envelope")
+	}
+
+	return key, envelope, nil
+}
+
+// annotateBackupEncryption records the envelope of an encrypted store on a new backup so that it can be restored in another cluster
+func annotateBackupEncryption(veleroBackup *velerov1.Backup) error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create clientset")
+	}
+
+	_, envelope, err := getStoreEncryption(clientset, veleroBackup.Namespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to get store encryption")
+	}
+	if envelope == nil {
+		return nil
+	}
+
+	if veleroBackup.Annotations == nil {
+		veleroBackup.Annotations = map[string]string{}
+	}
+	veleroBackup.Annotations[encryption.BackupAnnotation] = envelope.String()
+
+	return nil
+}
+
+// checkBackupEncryption refuses to restore an encrypted backup unless restic has the password of its key
+func checkBackupEncryption(clientset kubernetes.Interface, veleroNamespace string, backup *velerov1.Backup) error {
+	data, ok := backup.Annotations[encryption.BackupAnnotation]
+	if !ok {
+		return nil
+	}
+
+	envelope, err := encryption.ParseEnvelope(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse envelope")
+	}
+
+	secret, err := clientset.CoreV1().Secrets(veleroNamespace).Get(context.TODO(), resticCredentialsSecretName, metav1.GetOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get restic credentials")
+	}
+
+	if secret == nil || !envelope.VerifyResticPassword(string(secret.Data["repository-password"])) {
+		return errors.Errorf("backup %s is encrypted, configure the snapshot store with the encryption key it was taken with before restoring", backup.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,268 @@
+package snapshot
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	"github.com/replicatedhq/kots/pkg/snapshot/encryption"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStoreEncryption(t *testing.T) {
+	objectStore := &memObjectStore{objects: map[string][]byte{}}
+	store := &types.Store{
+		Path: "snapshots",
+		Encryption: &encryption.Key{
+			Passphrase: "passphrase",
+		},
+	}
+
+	envelope, dataKey, err := initStoreEncryption(store, objectStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope == nil {
+		t.Fatal("Expected the store to be encrypted")
+	}
+	if _, ok := objectStore.objects["snapshots/"+EncryptionEnvelopeName]; !ok {
+		t.Error("Expected the envelope to be written to the store")
+	}
+
+	// the store keeps its data key when it is configured again
+	reopened, reopenedDataKey, err := initStoreEncryption(store, objectStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reopened.Salt, envelope.Salt) || !bytes.Equal(reopenedDataKey, dataKey) {
+		t.Error("Expected the existing envelope to be used")
+	}
+
+	wrongKey := &types.Store{Path: "snapshots", Encryption: &encryption.Key{Passphrase: "wrong"}}
+	if _, _, err := openStoreEncryption(wrongKey, objectStore); errors.Cause(err) != encryption.ErrWrongKey {
+		t.Errorf("Expected wrong key error, got %v", err)
+	}
+
+	noKey := &types.Store{Path: "snapshots"}
+	if _, _, err := openStoreEncryption(noKey, objectStore); errors.Cause(err) != encryption.ErrKeyRequired {
+		t.Errorf("Expected key required error, got %v", err)
+	}
+}
+
+func TestStoreEncryptionWithExistingSnapshots(t *testing.T) {
+	objectStore := &memObjectStore{objects: map[string][]byte{
+		"snapshots/restic/default/config": []byte("config"),
+	}}
+
+	store := &types.Store{Path: "snapshots", Encryption: &encryption.Key{Passphrase: "passphrase"}}
+	if _, _, err := openStoreEncryption(store, objectStore); err == nil {
+		t.Error("Expected encryption to be refused on a store with unencrypted snapshots")
+	}
+
+	// a new path of the same bucket can be encrypted
+	store.Path = "encrypted"
+	if _, _, err := initStoreEncryption(store, objectStore); err != nil {
+		t.Errorf("Expected a new path to be encrypted, got %v", err)
+	}
+
+	unencrypted := &types.Store{Path: "snapshots"}
+	if envelope, _, err := openStoreEncryption(unencrypted, objectStore); err != nil || envelope != nil {
+		t.Errorf("Expected unencrypted store to be opened without an envelope, got %v", err)
+	}
+}
+
+func TestStoreEncryptionWithKMS(t *testing.T) {
+	kms := &encryption.LocalKeyManagementService{
+		MasterKeys: map[string][]byte{
+			"arn:aws:kms:us-east-1:123456789012:key/test": bytes.Repeat([]byte{7}, 32),
+		},
+	}
+	defaultNewKeyManagementService := newKeyManagementService
+	newKeyManagementService = func(store *types.Store, keyID string) (encryption.KeyManagementService, error) {
+		return kms, nil
+	}
+	defer func() {
+		newKeyManagementService = defaultNewKeyManagementService
+	}()
+
+	objectStore := &memObjectStore{objects: map[string][]byte{}}
+	store := &types.Store{
+		Path: "snapshots",
+		Encryption: &encryption.Key{
+			KMSKeyID: "arn:aws:kms:us-east-1:123456789012:key/test",
+		},
+	}
+
+	envelope, dataKey, err := initStoreEncryption(store, objectStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope.KeyType != encryption.KeyTypeKMS {
+		t.Errorf("Expected key type %q, got %q", encryption.KeyTypeKMS, envelope.KeyType)
+	}
+
+	_, reopenedDataKey, err := openStoreEncryption(store, objectStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reopenedDataKey, dataKey) {
+		t.Error("Expected the data key to be decrypted with the kms")
+	}
+}
+
+func TestCheckMetadataEncryption(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   *types.Store
+		wantErr bool
+	}{
+		{
+			name:  "not encrypted",
+			store: &types.Store{AWS: &types.StoreAWS{}},
+		},
+		{
+			name:  "aws kms key",
+			store: &types.Store{AWS: &types.StoreAWS{}, Encryption: &encryption.Key{KMSKeyID: "alias/backups"}},
+		},
+		{
+			name:    "passphrase",
+			store:   &types.Store{AWS: &types.StoreAWS{}, Encryption: &encryption.Key{Passphrase: "passphrase"}},
+			wantErr: true,
+		},
+		{
+			name:    "kms key on another provider",
+			store:   &types.Store{Other: &types.StoreOther{}, Encryption: &encryption.Key{KMSKeyID: "alias/backups"}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkMetadataEncryption(test.store)
+			if test.wantErr && err == nil {
+				t.Error("Expected error")
+			} else if !test.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckBackupEncryption(t *testing.T) {
+	envelope, dataKey, err := encryption.NewEnvelope(&encryption.Key{Passphrase: "passphrase"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup := &velerov1.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "instance-abcd",
+			Annotations: map[string]string{
+				encryption.BackupAnnotation: envelope.String(),
+			},
+		},
+	}
+
+	resticSecret := func(password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      resticCredentialsSecretName,
+				Namespace: "velero",
+			},
+			Data: map[string][]byte{
+				"repository-password": []byte(password),
+			},
+		}
+	}
+
+	if err := checkBackupEncryption(fake.NewSimpleClientset(resticSecret(defaultResticPassword)), "velero", backup); err == nil {
+		t.Error("Expected restore to be refused without the encryption key")
+	}
+	if err := checkBackupEncryption(fake.NewSimpleClientset(), "velero", backup); err == nil {
+		t.Error("Expected restore to be refused without restic credentials")
+	}
+	if err := checkBackupEncryption(fake.NewSimpleClientset(resticSecret(encryption.ResticPassword(dataKey))), "velero", backup); err != nil {
+		t.Errorf("Expected restore to be allowed with the encryption key, got %v", err)
+	}
+
+	unencrypted := &velerov1.Backup{ObjectMeta: metav1.ObjectMeta{Name: "instance-efgh"}}
+	if err := checkBackupEncryption(fake.NewSimpleClientset(), "velero", unencrypted); err != nil {
+		t.Errorf("Expected unencrypted backup to be restored, got %v", err)
+	}
+}
+
+func TestPlanImportEncryption(t *testing.T) {
+	key := &encryption.Key{Passphrase: "passphrase"}
+	exported, exportedDataKey, err := encryption.NewEnvelope(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a store without snapshots adopts the envelope of the backup instead of the one it was configured with
+	objectStore := &memObjectStore{objects: map[string][]byte{}}
+	store := &types.Store{Path: "snapshots", Encryption: key}
+	if _, _, err := initStoreEncryption(store, objectStore); err != nil {
+		t.Fatal(err)
+	}
+	adopt, dataKey, err := planImportEncryption(store, objectStore, exported, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopt != exported || !bytes.Equal(dataKey, exportedDataKey) {
+		t.Error("Expected the envelope of the backup to be adopted")
+	}
+
+	// a store without a key adopts the envelope and requires the key before it is used
+	noKey := &types.Store{Path: "snapshots"}
+	adopt, dataKey, err = planImportEncryption(noKey, &memObjectStore{objects: map[string][]byte{}}, exported, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopt != exported || dataKey != nil {
+		t.Error("Expected the envelope to be adopted without a data key")
+	}
+
+	wrongKey := &types.Store{Path: "snapshots", Encryption: &encryption.Key{Passphrase: "wrong"}}
+	if _, _, err := planImportEncryption(wrongKey, &memObjectStore{objects: map[string][]byte{}}, exported, true); err == nil {
+		t.Error("Expected import to be refused with a different key")
+	}
+
+	// a store with the same envelope has nothing to adopt
+	sameEnvelope := &memObjectStore{objects: map[string][]byte{
+		"snapshots/" + EncryptionEnvelopeName:    []byte(exported.String()),
+		"snapshots/restic/default/config":        []byte("config"),
+		"snapshots/backups/instance-abcd/a.json": []byte("{}"),
+	}}
+	adopt, _, err = planImportEncryption(store, sameEnvelope, exported, true)
+	if err != nil || adopt != nil {
+		t.Errorf("Expected nothing to adopt, got %v %v", adopt, err)
+	}
+
+	// stores with snapshots of another key or unencrypted snapshots can't adopt the envelope
+	otherSnapshots := &memObjectStore{objects: map[string][]byte{}}
+	if _, _, err := initStoreEncryption(store, otherSnapshots); err != nil {
+		t.Fatal(err)
+	}
+	otherSnapshots.objects["snapshots/restic/default/config"] = []byte("config")
+	if _, _, err := planImportEncryption(store, otherSnapshots, exported, true); err == nil {
+		t.Error("Expected import to be refused into a store with snapshots of another key")
+	}
+	unencryptedSnapshots := &memObjectStore{objects: map[string][]byte{
+		"snapshots/restic/default/config": []byte("config"),
+	}}
+	if _, _, err := planImportEncryption(noKey, unencryptedSnapshots, exported, true); err == nil {
+		t.Error("Expected import to be refused into a store with unencrypted snapshots")
+	}
+
+	// unencrypted volumes can't be imported into an encrypted store
+	if _, _, err := planImportEncryption(store, otherSnapshots, nil, true); err == nil {
+		t.Error("Expected unencrypted volumes to be refused")
+	}
+	if _, _, err := planImportEncryption(store, otherSnapshots, nil, false); err != nil {
+		t.Errorf("Expected backup without volumes to be imported, got %v", err)
+	}
+}
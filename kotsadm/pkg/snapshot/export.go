@@ -0,0 +1,498 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	kotssnapshot "github.com/replicatedhq/kots/pkg/snapshot"
+	"github.com/replicatedhq/kots/pkg/snapshot/encryption"
+	"github.com/replicatedhq/kots/pkg/version"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	veleroclientv1 "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/typed/velero/v1"
+	velerolabel "github.com/vmware-tanzu/velero/pkg/label"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const BackupArchiveMetadataName = "kotsadm-backup.json"
+
+// BackupArchiveMetadata is the first file in a backup archive
+type BackupArchiveMetadata struct {
+	BackupName       string           `json:"backupName"`
+	ExportedAt       time.Time        `json:"exportedAt"`
+	KotsadmVersion   string           `json:"kotsadmVersion"`
+	Backup           *velerov1.Backup `json:"backup"`
+	ResticNamespaces []string         `json:"resticNamespaces"`
+	// Encryption is the envelope of the store the backup was exported from, its restic repositories can only be read with the key of the envelope
+	Encryption *encryption.Envelope `json:"encryption,omitempty"`
+}
+
+// BackupExport is a backup that has been checked and listed, and is ready to be written as an archive
+type BackupExport struct {
+	Metadata    BackupArchiveMetadata
+	objectStore objectStore
+	objects     []exportObject
+}
+
+type exportObject struct {
+	key  string
+	name string
+	size int64
+}
+
+// PrepareBackupExport lists the velero backup files and the restic repositories of a backup in the snapshot store.
+// Restic shares data between snapshots, so the whole restic repository of each namespace with volumes in the backup is exported.
+func PrepareBackupExport(backupName string) (*BackupExport, error) {
+	bsl, err := FindBackupStoreLocation()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find backupstoragelocations")
+	}
+
+	store, err := GetGlobalStore(bsl)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get global store")
+	}
+	if store == nil {
+		return nil, errors.New("snapshot store is not configured")
+	}
+
+	objectStore, err := newObjectStore(store)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create object store client")
+	}
+
+	veleroClient, err := getVeleroClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create velero clientset")
+	}
+
+	backup, err := veleroClient.Backups(bsl.Namespace).Get(context.TODO(), backupName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get backup")
+	}
+
+	if backup.Status.Phase != velerov1.BackupPhaseCompleted {
+		return nil, errors.Errorf("backup %s is %s, only completed backups can be exported", backupName, backup.Status.Phase)
+	}
+
+	podVolumeBackups, err := veleroClient.PodVolumeBackups(bsl.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: velerov1.BackupNameLabel + "=" + velerolabel.GetValidName(backupName),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pod volume backups")
+	}
+
+	envelope, err := readEncryptionEnvelope(objectStore, store.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read encryption envelope")
+	}
+
+	resticNamespaces := []string{}
+	for _, podVolumeBackup := range podVolumeBackups.Items {
+		if !containsString(resticNamespaces, podVolumeBackup.Spec.Pod.Namespace) {
+			resticNamespaces = append(resticNamespaces, podVolumeBackup.Spec.Pod.Namespace)
+		}
+	}
+	sort.Strings(resticNamespaces)
+
+	backupExport := &BackupExport{
+		Metadata: BackupArchiveMetadata{
+			BackupName:       backupName,
+			ExportedAt:       time.Now(),
+			KotsadmVersion:   version.Version(),
+			Backup:           backup,
+			ResticNamespaces: resticNamespaces,
+			Encryption:       envelope,
+		},
+		objectStore: objectStore,
+	}
+
+	// restic repositories go before the backup so that an import makes the backup visible to velero after its volumes are in place
+	for _, namespace := range resticNamespaces {
+		objects, err := listExportObjects(objectStore, objectKey(store.Path, "restic", namespace), path.Join("restic", namespace))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list restic repository for namespace %s", namespace)
+		}
+		backupExport.objects = append(backupExport.objects, objects...)
+	}
+
+	objects, err := listExportObjects(objectStore, objectKey(store.Path, "backups", backupName), path.Join("backups", backupName))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list backup files")
+	}
+	if len(objects) == 0 {
+		return nil, errors.Errorf("backup %s not found in the snapshot store", backupName)
+	}
+	backupExport.objects = append(backupExport.objects, objects...)
+
+	return backupExport, nil
+}
+
+// listExportObjects lists the objects under a prefix with their names in the archive.
+// The restic repository config is listed first so that imports can check it before writing anything else to the repository.
+func listExportObjects(objectStore objectStore, prefix string, archiveDir string) ([]exportObject, error) {
+	objects, err := objectStore.ListObjects(prefix + "/")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list objects")
+	}
+
+	exportObjects := []exportObject{}
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, prefix+"/")
+		if strings.HasPrefix(name, "locks/") {
+			// stale restic locks would block the repository after an import
+			continue
+		}
+
+		o := exportObject{
+			key:  object.Key,
+			name: path.Join(archiveDir, name),
+			size: object.Size,
+		}
+		if name == "config" {
+			exportObjects = append([]exportObject{o}, exportObjects...)
+		} else {
+			exportObjects = append(exportObjects, o)
+		}
+	}
+
+	return exportObjects, nil
+}
+
+// Write writes the backup as a gzipped tar archive that ends with a manifest of its files
+func (e *BackupExport) Write(w io.Writer) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	archiveWriter := kotssnapshot.NewBackupArchiveWriter(tarWriter)
+
+	metadata, err := json.MarshalIndent(e.Metadata, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal metadata")
+	}
+
+	err = archiveWriter.WriteFile(&tar.Header{
+		Name:    BackupArchiveMetadataName,
+		Mode:    0644,
+		Size:    int64(len(metadata)),
+		ModTime: e.Metadata.ExportedAt,
+	}, bytes.NewReader(metadata))
+	if err != nil {
+		return errors.Wrap(err, "failed to write metadata")
+	}
+
+	for _, object := range e.objects {
+		if err := e.writeObject(archiveWriter, object); err != nil {
+			return errors.Wrapf(err, "failed to write %s", object.key)
+		}
+	}
+
+	if err := archiveWriter.Close(); err != nil {
+		return errors.Wrap(err, "failed to write manifest")
+	}
+	if err := tarWriter.Close(); err != nil {
+		return errors.Wrap(err, "failed to close tar writer")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return errors.Wrap(err, "failed to close gzip writer")
+	}
+
+	return nil
+}
+
+func (e *BackupExport) writeObject(archiveWriter *kotssnapshot.BackupArchiveWriter, object exportObject) error {
+	reader, err := e.objectStore.GetObject(object.key)
+	if err != nil {
+		return errors.Wrap(err, "failed to get object")
+	}
+	defer reader.Close()
+
+	err = archiveWriter.WriteFile(&tar.Header{
+		Name:    object.name,
+		Mode:    0644,
+		Size:    object.size,
+		ModTime: e.Metadata.ExportedAt,
+	}, reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to write object")
+	}
+
+	return nil
+}
+
+// ImportBackup uploads a backup archive to the snapshot store.
+// Velero creates the backup in the cluster the next time it syncs backups from the store.
+func ImportBackup(r io.Reader) (*BackupArchiveMetadata, error) {
+	bsl, err := FindBackupStoreLocation()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find backupstoragelocations")
+	}
+
+	store, err := GetGlobalStore(bsl)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get global store")
+	}
+	if store == nil {
+		return nil, errors.New("snapshot store is not configured")
+	}
+
+	objectStore, err := newObjectStore(store)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create object store client")
+	}
+
+	veleroClient, err := getVeleroClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create velero clientset")
+	}
+
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gzip reader")
+	}
+	defer gzipReader.Close()
+
+	archiveReader := kotssnapshot.NewBackupArchiveReader(tar.NewReader(gzipReader))
+
+	metadata, err := readBackupArchiveMetadata(archiveReader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read archive metadata")
+	}
+
+	_, err = veleroClient.Backups(bsl.Namespace).Get(context.TODO(), metadata.BackupName, metav1.GetOptions{})
+	if err == nil {
+		return nil, errors.Errorf("backup %s already exists", metadata.BackupName)
+	} else if !kuberneteserrors.IsNotFound(err) {
+		return nil, errors.Wrap(err, "failed to get backup")
+	}
+
+	exists, err := objectStore.ObjectExists(objectKey(store.Path, "backups", metadata.BackupName, "velero-backup.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check backup in snapshot store")
+	}
+	if exists {
+		return nil, errors.Errorf("backup %s already exists in the snapshot store", metadata.BackupName)
+	}
+
+	adoptEnvelope, adoptDataKey, err := planImportEncryption(store, objectStore, metadata.Encryption, len(metadata.ResticNamespaces) > 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check backup encryption")
+	}
+
+	if err := uploadBackupArchive(objectStore, store.Path, archiveReader, metadata); err != nil {
+		return nil, errors.Wrap(err, "failed to upload backup archive")
+	}
+
+	if adoptEnvelope != nil {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get cluster config")
+		}
+
+		clientset, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create clientset")
+		}
+
+		if err := adoptStoreEncryption(clientset, bsl.Namespace, store, objectStore, adoptEnvelope, adoptDataKey); err != nil {
+			return nil, errors.Wrap(err, "failed to adopt backup encryption")
+		}
+	}
+
+	return metadata, nil
+}
+
+// uploadBackupArchive uploads the files in the archive after the metadata to the store.
+// A restic repository that already exists in the store is only added to if it is the same repository.
+// Velero syncs backups by their velero-backup.json, which is uploaded last, once the archive has been verified.
+// The files are uploaded while the archive is read, so the files that were uploaded are deleted again when the archive fails to verify.
+func uploadBackupArchive(objectStore objectStore, storePrefix string, archiveReader *kotssnapshot.BackupArchiveReader, metadata *BackupArchiveMetadata) (finalErr error) {
+	uploadedKeys := []string{}
+	defer func() {
+		if finalErr == nil {
+			return
+		}
+		for _, key := range uploadedKeys {
+			if err := objectStore.DeleteObject(key); err != nil {
+				logger.Error(errors.Wrapf(err, "failed to delete %s", key))
+			}
+		}
+	}()
+
+	existingRepositories := map[string]bool{}
+	var veleroBackup []byte
+	for {
+		header, err := archiveReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read archive")
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dir, namespaceOrName, name, err := splitBackupArchiveName(header.Name)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse archive file name")
+		}
+
+		switch dir {
+		case "restic":
+			if !containsString(metadata.ResticNamespaces, namespaceOrName) {
+				return errors.Errorf("unexpected restic repository for namespace %s", namespaceOrName)
+			}
+
+			key := objectKey(storePrefix, "restic", namespaceOrName, name)
+			if name == "config" {
+				exists, err := objectStore.ObjectExists(key)
+				if err != nil {
+					return errors.Wrapf(err, "failed to check restic repository for namespace %s", namespaceOrName)
+				}
+				if exists {
+					same, err := isSameObject(objectStore, key, archiveReader)
+					if err != nil {
+						return errors.Wrapf(err, "failed to compare restic repository for namespace %s", namespaceOrName)
+					}
+					if !same {
+						return errors.Errorf("a different restic repository for namespace %s already exists in the snapshot store", namespaceOrName)
+					}
+					existingRepositories[namespaceOrName] = true
+					continue
+				}
+			} else if existingRepositories[namespaceOrName] {
+				// restic files are content addressed, files that already exist have the same content
+				exists, err := objectStore.ObjectExists(key)
+				if err != nil {
+					return errors.Wrapf(err, "failed to check %s", key)
+				}
+				if exists {
+					continue
+				}
+			}
+
+			if err := objectStore.PutObject(key, archiveReader); err != nil {
+				return errors.Wrapf(err, "failed to upload %s", key)
+			}
+			uploadedKeys = append(uploadedKeys, key)
+
+		case "backups":
+			if namespaceOrName != metadata.BackupName {
+				return errors.Errorf("unexpected files for backup %s", namespaceOrName)
+			}
+
+			if name == "velero-backup.json" {
+				veleroBackup, err = ioutil.ReadAll(archiveReader)
+				if err != nil {
+					return errors.Wrapf(err, "failed to read %s", header.Name)
+				}
+				continue
+			}
+
+			key := objectKey(storePrefix, "backups", namespaceOrName, name)
+			if err := objectStore.PutObject(key, archiveReader); err != nil {
+				return errors.Wrapf(err, "failed to upload %s", key)
+			}
+			uploadedKeys = append(uploadedKeys, key)
+
+		default:
+			return errors.Errorf("unexpected file %s in archive", header.Name)
+		}
+	}
+
+	if veleroBackup == nil {
+		return errors.New("archive does not have velero-backup.json")
+	}
+
+	key := objectKey(storePrefix, "backups", metadata.BackupName, "velero-backup.json")
+	if err := objectStore.PutObject(key, bytes.NewReader(veleroBackup)); err != nil {
+		return errors.Wrapf(err, "failed to upload %s", key)
+	}
+
+	return nil
+}
+
+func readBackupArchiveMetadata(archiveReader *kotssnapshot.BackupArchiveReader) (*BackupArchiveMetadata, error) {
+	header, err := archiveReader.Next()
+	if err == io.EOF {
+		return nil, errors.New("archive is empty")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read archive")
+	}
+	if header.Name != BackupArchiveMetadataName {
+		return nil, errors.Errorf("archive does not start with %s", BackupArchiveMetadataName)
+	}
+
+	metadata := &BackupArchiveMetadata{}
+	if err := json.NewDecoder(archiveReader).Decode(metadata); err != nil {
+		return nil, errors.Wrap(err, "failed to decode metadata")
+	}
+	if metadata.BackupName == "" {
+		return nil, errors.New("metadata does not have a backup name")
+	}
+
+	return metadata, nil
+}
+
+// splitBackupArchiveName splits an archive file name like restic/<namespace>/<file> or backups/<backup>/<file>
+func splitBackupArchiveName(name string) (string, string, string, error) {
+	name = path.Clean(name)
+	if path.IsAbs(name) || strings.HasPrefix(name, "../") {
+		return "", "", "", errors.Errorf("invalid file name %s", name)
+	}
+
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) != 3 {
+		return "", "", "", errors.Errorf("unexpected file %s", name)
+	}
+
+	return parts[0], parts[1], parts[2], nil
+}
+
+func isSameObject(objectStore objectStore, key string, r io.Reader) (bool, error) {
+	reader, err := objectStore.GetObject(key)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get object")
+	}
+	defer reader.Close()
+
+	existing, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read object")
+	}
+
+	imported, err := ioutil.ReadAll(r)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read archive file")
+	}
+
+	return bytes.Equal(existing, imported), nil
+}
+
+func getVeleroClient() (veleroclientv1.VeleroV1Interface, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	veleroClient, err := veleroclientv1.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create clientset")
+	}
+
+	return veleroClient, nil
+}
@@ -0,0 +1,235 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	kotssnapshot "github.com/replicatedhq/kots/pkg/snapshot"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
+)
+
+type memObjectStore struct {
+	objects map[string][]byte
+}
+
+func (s *memObjectStore) ListObjects(prefix string) ([]objectInfo, error) {
+	objects := []objectInfo{}
+	for key, data := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, objectInfo{Key: key, Size: int64(len(data))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (s *memObjectStore) GetObject(key string) (io.ReadCloser, error) {
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, errors.Errorf("object %s not found", key)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memObjectStore) PutObject(key string, body io.Reader) error {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memObjectStore) ObjectExists(key string) (bool, error) {
+	_, ok := s.objects[key]
+	return ok, nil
+}
+
+func (s *memObjectStore) DeleteObject(key string) error {
+	delete(s.objects, key)
+	return nil
+}
+
+func exportTestArchive(t *testing.T, source *memObjectStore) []byte {
+	backupExport := &BackupExport{
+		Metadata: BackupArchiveMetadata{
+			BackupName:       "instance-abcd",
+			ResticNamespaces: []string{"default"},
+		},
+		objectStore: source,
+	}
+	for _, dir := range [][]string{{"restic", "default"}, {"backups", "instance-abcd"}} {
+		objects, err := listExportObjects(source, objectKey("velero", dir...), strings.Join(dir, "/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		backupExport.objects = append(backupExport.objects, objects...)
+	}
+
+	archive := bytes.NewBuffer(nil)
+	if err := backupExport.Write(archive); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+func importTestArchive(target *memObjectStore, archive []byte) error {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return err
+	}
+	archiveReader := kotssnapshot.NewBackupArchiveReader(tar.NewReader(gzipReader))
+
+	metadata, err := readBackupArchiveMetadata(archiveReader)
+	if err != nil {
+		return err
+	}
+	return uploadBackupArchive(target, "imported", archiveReader, metadata)
+}
+
+func TestExportImportBackup(t *testing.T) {
+	source := &memObjectStore{objects: map[string][]byte{
+		"velero/backups/instance-abcd/velero-backup.json":     []byte(`{"kind":"Backup"}`),
+		"velero/backups/instance-abcd/instance-abcd.tar.gz":   []byte("resources"),
+		"velero/backups/instance-abcdef/velero-backup.json":   []byte(`{"kind":"Backup"}`),
+		"velero/restic/default/config":                        []byte("repository config"),
+		"velero/restic/default/data/ab/abcd":                  []byte("pack"),
+		"velero/restic/default/keys/1234":                     []byte("key"),
+		"velero/restic/default/locks/5678":                    []byte("lock"),
+		"velero/restic/kube-system/config":                    []byte("other repository config"),
+		"velero/restic/default/snapshots/0123456789abcdef012": []byte("snapshot"),
+	}}
+
+	archive := exportTestArchive(t, source)
+
+	target := &memObjectStore{objects: map[string][]byte{}}
+	if err := importTestArchive(target, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]byte{
+		"imported/backups/instance-abcd/velero-backup.json":     []byte(`{"kind":"Backup"}`),
+		"imported/backups/instance-abcd/instance-abcd.tar.gz":   []byte("resources"),
+		"imported/restic/default/config":                        []byte("repository config"),
+		"imported/restic/default/data/ab/abcd":                  []byte("pack"),
+		"imported/restic/default/keys/1234":                     []byte("key"),
+		"imported/restic/default/snapshots/0123456789abcdef012": []byte("snapshot"),
+	}
+	if !reflect.DeepEqual(target.objects, expected) {
+		t.Errorf("Expected %v, got %v", expected, target.objects)
+	}
+
+	// importing into the same repository only adds missing files
+	delete(target.objects, "imported/restic/default/data/ab/abcd")
+	if err := importTestArchive(target, archive); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := target.objects["imported/restic/default/data/ab/abcd"]; !ok {
+		t.Error("Expected missing restic file to be uploaded")
+	}
+
+	// importing into a different repository fails before the backup is uploaded
+	conflicting := &memObjectStore{objects: map[string][]byte{
+		"imported/restic/default/config": []byte("different repository config"),
+	}}
+	if err := importTestArchive(conflicting, archive); err == nil {
+		t.Error("Expected error importing into a different restic repository")
+	}
+	if _, ok := conflicting.objects["imported/backups/instance-abcd/velero-backup.json"]; ok {
+		t.Error("Expected backup not to be uploaded")
+	}
+	if len(conflicting.objects) != 1 {
+		t.Errorf("Expected only the existing repository config to be left, got %v", conflicting.objects)
+	}
+
+	// an archive that was cut off is not made visible to velero
+	truncated := &memObjectStore{objects: map[string][]byte{}}
+	if err := importTestArchive(truncated, truncateTestArchive(t, archive)); err == nil {
+		t.Error("Expected error importing a truncated archive")
+	}
+	if _, ok := truncated.objects["imported/backups/instance-abcd/velero-backup.json"]; ok {
+		t.Error("Expected backup of a truncated archive not to be uploaded")
+	}
+	if len(truncated.objects) != 0 {
+		t.Errorf("Expected the files of a truncated archive to be deleted, got %v", truncated.objects)
+	}
+
+	// files added to an existing repository are deleted, the files that were already there are kept
+	partial := &memObjectStore{objects: map[string][]byte{
+		"imported/restic/default/config":    []byte("repository config"),
+		"imported/restic/default/keys/1234": []byte("key"),
+	}}
+	if err := importTestArchive(partial, truncateTestArchive(t, archive)); err == nil {
+		t.Error("Expected error importing a truncated archive")
+	}
+	expectedPartial := map[string][]byte{
+		"imported/restic/default/config":    []byte("repository config"),
+		"imported/restic/default/keys/1234": []byte("key"),
+	}
+	if !reflect.DeepEqual(partial.objects, expectedPartial) {
+		t.Errorf("Expected %v, got %v", expectedPartial, partial.objects)
+	}
+}
+
+// truncateTestArchive rewrites the archive without its manifest, as if the export stopped before it was written
+func truncateTestArchive(t *testing.T, archive []byte) []byte {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	truncated := bytes.NewBuffer(nil)
+	gzipWriter := gzip.NewWriter(truncated)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == kotssnapshottypes.BackupArchiveManifestName {
+			continue
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(tarWriter, tarReader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return truncated.Bytes()
+}
+
+func TestSplitBackupArchiveName(t *testing.T) {
+	dir, namespace, name, err := splitBackupArchiveName("restic/default/data/ab/abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "restic" || namespace != "default" || name != "data/ab/abcd" {
+		t.Errorf("Unexpected split %q %q %q", dir, namespace, name)
+	}
+
+	for _, invalid := range []string{"/etc/passwd", "../backups/a/b", "backups/a", "restic/../../x/y"} {
+		if _, _, _, err := splitBackupArchiveName(invalid); err == nil {
+			t.Errorf("Expected error for %s", invalid)
+		}
+	}
+}
@@ -0,0 +1,615 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	kotsadmtypes "github.com/replicatedhq/kots/pkg/kotsadm/types"
+	kotsadmversion "github.com/replicatedhq/kots/pkg/kotsadm/version"
+	"github.com/replicatedhq/kots/pkg/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	// FileSystemMinioName is the name of the s3 compatible shim kotsadm deploys in front of nfs and host path stores
+	FileSystemMinioName   = "kotsadm-fs-minio"
+	FileSystemMinioBucket = "velero"
+	FileSystemMinioRegion = "us-east-1"
+
+	fileSystemMinioPort   = 9000
+	fileSystemMinioVolume = "data"
+)
+
+// FileSystemMinioEndpoint is the in-cluster url of the shim, as used by velero and kotsadm
+func FileSystemMinioEndpoint(namespace string) string {
+	return fmt.Sprintf("http://%s.%s:%d", FileSystemMinioName, namespace, fileSystemMinioPort)
+}
+
+func isFileSystemStore(store *types.Store) bool {
+	return store.NFS != nil || store.HostPath != nil
+}
+
+func validateFileSystemConfig(store *types.Store) error {
+	if store.NFS != nil {
+		if store.NFS.Server == "" {
+			return errors.New("nfs server is required")
+		}
+		if !filepath.IsAbs(store.NFS.Path) {
+			return errors.New("nfs path must be an absolute path")
+		}
+	}
+	if store.HostPath != nil {
+		if !filepath.IsAbs(store.HostPath.Path) {
+			return errors.New("host path must be an absolute path")
+		}
+	}
+	return nil
+}
+
+func fileSystemVolumeSource(store *types.Store) corev1.VolumeSource {
+	if store.NFS != nil {
+		return corev1.VolumeSource{
+			NFS: &corev1.NFSVolumeSource{
+				Server: store.NFS.Server,
+				Path:   store.NFS.Path,
+			},
+		}
+	}
+
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	return corev1.VolumeSource{
+		HostPath: &corev1.HostPathVolumeSource{
+			Path: store.HostPath.Path,
+			Type: &hostPathType,
+		},
+	}
+}
+
+// DeployFileSystemMinio deploys a minio nas gateway on the nfs export or host path of the store,
+// waits for it to be ready and creates the velero bucket. The store is updated with the s3 settings of the shim.
+func DeployFileSystemMinio(store *types.Store) error {
+	if err := validateFileSystemConfig(store); err != nil {
+		return err
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create clientset")
+	}
+
+	namespace := os.Getenv("POD_NAMESPACE")
+
+	if store.HostPath != nil {
+		if err := ensureHostPathNode(clientset, store.HostPath); err != nil {
+			return err
+		}
+	}
+
+	accessKeyID, secretAccessKey, err := ensureFileSystemMinioSecret(clientset, namespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to ensure minio secret")
+	}
+
+	kotsadmOptions, err := kotsadm.GetKotsadmOptionsFromCluster(namespace, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to get kotsadm options from cluster")
+	}
+
+	if err := ensureFileSystemMinioDeployment(clientset, namespace, fileSystemMinioDeployment(store, namespace, kotsadmOptions)); err != nil {
+		return errors.Wrap(err, "failed to ensure minio deployment")
+	}
+
+	if err := ensureFileSystemMinioService(clientset, namespace); err != nil {
+		return errors.Wrap(err, "failed to ensure minio service")
+	}
+
+	if err := waitForFileSystemMinio(clientset, namespace, 5*time.Minute); err != nil {
+		return errors.Wrap(err, "failed to wait for minio")
+	}
+
+	fileSystemStore := &types.StoreFileSystem{
+		Region:          FileSystemMinioRegion,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		Endpoint:        FileSystemMinioEndpoint(namespace),
+	}
+	if err := createFileSystemMinioBucket(fileSystemStore); err != nil {
+		return errors.Wrap(err, "failed to create bucket")
+	}
+
+	store.Provider = "aws"
+	store.Bucket = FileSystemMinioBucket
+	store.Path = ""
+	if store.NFS != nil {
+		store.NFS.StoreFileSystem = *fileSystemStore
+	} else {
+		store.HostPath.StoreFileSystem = *fileSystemStore
+	}
+
+	return nil
+}
+
+// ensureHostPathNode makes sure the host path store is pinned to the node the path is on. The backups
+// would be spread over the nodes the shim is scheduled on otherwise. A single node cluster needs no node.
+func ensureHostPathNode(clientset kubernetes.Interface, hostPath *types.StoreHostPath) error {
+	nodes, err := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list nodes")
+	}
+
+	if hostPath.Node == "" {
+		if len(nodes.Items) != 1 {
+			return util.ActionableError{Message: "a node is required for host path stores on clusters with more than one node"}
+		}
+		hostPath.Node = nodes.Items[0].Name
+		return nil
+	}
+
+	for _, node := range nodes.Items {
+		if node.Name == hostPath.Node {
+			return nil
+		}
+	}
+	return util.ActionableError{Message: fmt.Sprintf("node %s does not exist", hostPath.Node)}
+}
+
+func fileSystemMinioAffinity(store *types.Store) *corev1.Affinity {
+	if store.HostPath == nil || store.HostPath.Node == "" {
+		return nil
+	}
+
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchFields: []corev1.NodeSelectorRequirement{
+							{
+								Key:      "metadata.name",
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{store.HostPath.Node},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func hostPathNodeFromAffinity(affinity *corev1.Affinity) string {
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, field := range term.MatchFields {
+			if field.Key == "metadata.name" && len(field.Values) == 1 {
+				return field.Values[0]
+			}
+		}
+	}
+	return ""
+}
+
+// DeleteFileSystemMinio removes the shim when the store is no longer on a file system.
+// The data on the export or host path is kept.
+func DeleteFileSystemMinio() error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create clientset")
+	}
+
+	namespace := os.Getenv("POD_NAMESPACE")
+
+	err = clientset.AppsV1().Deployments(namespace).Delete(context.TODO(), FileSystemMinioName, metav1.DeleteOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete deployment")
+	}
+
+	err = clientset.CoreV1().Services(namespace).Delete(context.TODO(), FileSystemMinioName, metav1.DeleteOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete service")
+	}
+
+	err = clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), FileSystemMinioName, metav1.DeleteOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete secret")
+	}
+
+	return nil
+}
+
+// getFileSystemStore reads the nfs or host path the shim is deployed on
+func getFileSystemStore(store *types.Store, endpoint string) (bool, error) {
+	namespace := os.Getenv("POD_NAMESPACE")
+	if endpoint != FileSystemMinioEndpoint(namespace) {
+		return false, nil
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create clientset")
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), FileSystemMinioName, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "failed to get deployment")
+	}
+
+	fileSystemStore := types.StoreFileSystem{
+		Region:   FileSystemMinioRegion,
+		Endpoint: endpoint,
+	}
+
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.Name != fileSystemMinioVolume {
+			continue
+		}
+		if volume.NFS != nil {
+			store.NFS = &types.StoreNFS{
+				Server:          volume.NFS.Server,
+				Path:            volume.NFS.Path,
+				StoreFileSystem: fileSystemStore,
+			}
+			return true, nil
+		}
+		if volume.HostPath != nil {
+			store.HostPath = &types.StoreHostPath{
+				Path:            volume.HostPath.Path,
+				Node:            hostPathNodeFromAffinity(deployment.Spec.Template.Spec.Affinity),
+				StoreFileSystem: fileSystemStore,
+			}
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func validateFileSystem(store *types.Store) error {
+	if err := validateFileSystemConfig(store); err != nil {
+		return err
+	}
+
+	fileSystemStore := store.FileSystem()
+	if fileSystemStore == nil || fileSystemStore.Endpoint == "" {
+		return errors.New("file system store has not been deployed")
+	}
+
+	s3Client := fileSystemS3Client(fileSystemStore)
+	_, err := s3Client.HeadBucket(&s3.HeadBucketInput{
+		Bucket: aws.String(store.Bucket),
+	})
+	if err != nil {
+		return errors.Wrap(err, "bucket does not exist")
+	}
+
+	return nil
+}
+
+func fileSystemS3Client(fileSystemStore *types.StoreFileSystem) *s3.S3 {
+	s3Config := &aws.Config{
+		Region:           aws.String(fileSystemStore.Region),
+		Endpoint:         aws.String(fileSystemStore.Endpoint),
+		DisableSSL:       aws.Bool(true),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials(fileSystemStore.AccessKeyID, fileSystemStore.SecretAccessKey, ""),
+	}
+
+	return s3.New(session.New(s3Config))
+}
+
+func createFileSystemMinioBucket(fileSystemStore *types.StoreFileSystem) error {
+	s3Client := fileSystemS3Client(fileSystemStore)
+	_, err := s3Client.CreateBucket(&s3.CreateBucketInput{
+		Bucket: aws.String(FileSystemMinioBucket),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case s3.ErrCodeBucketAlreadyOwnedByYou, s3.ErrCodeBucketAlreadyExists:
+				return nil
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func ensureFileSystemMinioSecret(clientset kubernetes.Interface, namespace string) (string, string, error) {
+	existing, err := clientset.CoreV1().Secrets(namespace).Get(context.TODO(), FileSystemMinioName, metav1.GetOptions{})
+	if err == nil {
+		return string(existing.Data["accesskey"]), string(existing.Data["secretkey"]), nil
+	} else if !kuberneteserrors.IsNotFound(err) {
+		return "", "", errors.Wrap(err, "failed to get secret")
+	}
+
+	accessKeyID, err := randomHex(10)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to generate access key")
+	}
+	secretAccessKey, err := randomHex(20)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to generate secret key")
+	}
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FileSystemMinioName,
+			Namespace: namespace,
+			Labels:    kotsadmtypes.GetKotsadmLabels(),
+		},
+		Data: map[string][]byte{
+			"accesskey": []byte(accessKeyID),
+			"secretkey": []byte(secretAccessKey),
+		},
+	}
+	_, err = clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to create secret")
+	}
+
+	return accessKeyID, secretAccessKey, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func fileSystemMinioDeployment(store *types.Store, namespace string, kotsadmOptions kotsadmtypes.KotsadmOptions) *appsv1.Deployment {
+	var pullSecrets []corev1.LocalObjectReference
+	if s := kotsadmversion.KotsadmPullSecret(namespace, kotsadmOptions); s != nil {
+		pullSecrets = []corev1.LocalObjectReference{
+			{
+				Name: s.ObjectMeta.Name,
+			},
+		}
+	}
+
+	secretKeyRef := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: FileSystemMinioName,
+				},
+				Key: key,
+			},
+		}
+	}
+
+	replicas := int32(1)
+
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FileSystemMinioName,
+			Namespace: namespace,
+			Labels:    kotsadmtypes.GetKotsadmLabels(),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": FileSystemMinioName,
+				},
+			},
+			// the gateway must not run twice on the same directory
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RecreateDeploymentStrategyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: kotsadmtypes.GetKotsadmLabels(map[string]string{
+						"app": FileSystemMinioName,
+					}),
+				},
+				Spec: corev1.PodSpec{
+					ImagePullSecrets: pullSecrets,
+					Affinity:         fileSystemMinioAffinity(store),
+					Volumes: []corev1.Volume{
+						{
+							Name:         fileSystemMinioVolume,
+							VolumeSource: fileSystemVolumeSource(store),
+						},
+						{
+							Name: "minio-config-dir",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Image:           fmt.Sprintf("%s/minio:%s", kotsadmversion.KotsadmRegistry(kotsadmOptions), kotsadmversion.KotsadmTag(kotsadmOptions)),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Name:            "minio",
+							Command: []string{
+								"/bin/sh",
+								"-ce",
+								"/usr/bin/docker-entrypoint.sh minio -C /home/minio/.minio/ --quiet gateway nas /data",
+							},
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "service",
+									ContainerPort: fileSystemMinioPort,
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      fileSystemMinioVolume,
+									MountPath: "/data",
+								},
+								{
+									Name:      "minio-config-dir",
+									MountPath: "/home/minio/.minio/",
+								},
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name:      "MINIO_ACCESS_KEY",
+									ValueFrom: secretKeyRef("accesskey"),
+								},
+								{
+									Name:      "MINIO_SECRET_KEY",
+									ValueFrom: secretKeyRef("secretkey"),
+								},
+								{
+									Name:  "MINIO_BROWSER",
+									Value: "off",
+								},
+								{
+									Name:  "MINIO_UPDATE",
+									Value: "off",
+								},
+							},
+							ReadinessProbe: &corev1.Probe{
+								InitialDelaySeconds: 5,
+								TimeoutSeconds:      1,
+								PeriodSeconds:       5,
+								Handler: corev1.Handler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path:   "/minio/health/ready",
+										Port:   intstr.FromString("service"),
+										Scheme: corev1.URISchemeHTTP,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func ensureFileSystemMinioDeployment(clientset kubernetes.Interface, namespace string, deployment *appsv1.Deployment) error {
+	existing, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), deployment.Name, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		_, err = clientset.AppsV1().Deployments(namespace).Create(context.TODO(), deployment, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrap(err, "failed to create deployment")
+		}
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to get deployment")
+	}
+
+	existing.Spec = deployment.Spec
+	_, err = clientset.AppsV1().Deployments(namespace).Update(context.TODO(), existing, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to update deployment")
+	}
+
+	return nil
+}
+
+func ensureFileSystemMinioService(clientset kubernetes.Interface, namespace string) error {
+	_, err := clientset.CoreV1().Services(namespace).Get(context.TODO(), FileSystemMinioName, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	} else if !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get service")
+	}
+
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FileSystemMinioName,
+			Namespace: namespace,
+			Labels:    kotsadmtypes.GetKotsadmLabels(),
+		},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeClusterIP,
+			Selector: map[string]string{
+				"app": FileSystemMinioName,
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "service",
+					Port:       fileSystemMinioPort,
+					TargetPort: intstr.FromInt(fileSystemMinioPort),
+				},
+			},
+		},
+	}
+	_, err = clientset.CoreV1().Services(namespace).Create(context.TODO(), service, metav1.CreateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to create service")
+	}
+
+	return nil
+}
+
+func waitForFileSystemMinio(clientset kubernetes.Interface, namespace string, timeout time.Duration) error {
+	start := time.Now()
+	for {
+		deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.TODO(), FileSystemMinioName, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrap(err, "failed to get deployment")
+		}
+
+		if deployment.Status.ObservedGeneration >= deployment.Generation &&
+			deployment.Status.UpdatedReplicas == 1 && deployment.Status.ReadyReplicas == 1 && deployment.Status.Replicas == 1 {
+			return nil
+		}
+
+		if time.Now().Sub(start) > timeout {
+			return errors.New("timeout waiting for the file system store to become ready, check that the export or path can be mounted by the cluster")
+		}
+
+		logger.Debug("waiting for file system minio to become ready")
+		time.Sleep(time.Second * 2)
+	}
+}
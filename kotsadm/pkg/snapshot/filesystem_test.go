@@ -0,0 +1,151 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	kotsadmtypes "github.com/replicatedhq/kots/pkg/kotsadm/types"
+	"github.com/replicatedhq/kots/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateFileSystemConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   *types.Store
+		wantErr bool
+	}{
+		{
+			name:  "nfs",
+			store: &types.Store{NFS: &types.StoreNFS{Server: "10.0.0.4", Path: "/exports/backups"}},
+		},
+		{
+			name:    "nfs without server",
+			store:   &types.Store{NFS: &types.StoreNFS{Path: "/exports/backups"}},
+			wantErr: true,
+		},
+		{
+			name:    "nfs relative path",
+			store:   &types.Store{NFS: &types.StoreNFS{Server: "10.0.0.4", Path: "exports/backups"}},
+			wantErr: true,
+		},
+		{
+			name:  "host path",
+			store: &types.Store{HostPath: &types.StoreHostPath{Path: "/var/lib/backups"}},
+		},
+		{
+			name:    "host path relative path",
+			store:   &types.Store{HostPath: &types.StoreHostPath{Path: "backups"}},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateFileSystemConfig(test.store)
+			if test.wantErr && err == nil {
+				t.Error("Expected error")
+			} else if !test.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileSystemMinioDeploymentVolume(t *testing.T) {
+	nfsStore := &types.Store{NFS: &types.StoreNFS{Server: "10.0.0.4", Path: "/exports/backups"}}
+	deployment := fileSystemMinioDeployment(nfsStore, "default", kotsadmtypes.KotsadmOptions{})
+	volume := deployment.Spec.Template.Spec.Volumes[0]
+	if volume.Name != fileSystemMinioVolume || volume.NFS == nil {
+		t.Fatalf("Expected nfs volume %q, got %+v", fileSystemMinioVolume, volume)
+	}
+	if volume.NFS.Server != "10.0.0.4" || volume.NFS.Path != "/exports/backups" {
+		t.Errorf("Unexpected nfs volume source %+v", volume.NFS)
+	}
+
+	hostPathStore := &types.Store{HostPath: &types.StoreHostPath{Path: "/var/lib/backups"}}
+	deployment = fileSystemMinioDeployment(hostPathStore, "default", kotsadmtypes.KotsadmOptions{})
+	volume = deployment.Spec.Template.Spec.Volumes[0]
+	if volume.HostPath == nil || volume.HostPath.Path != "/var/lib/backups" {
+		t.Errorf("Unexpected host path volume source %+v", volume.HostPath)
+	}
+}
+
+func TestFileSystemMinioDeploymentNode(t *testing.T) {
+	nfsStore := &types.Store{NFS: &types.StoreNFS{Server: "10.0.0.4", Path: "/exports/backups"}}
+	deployment := fileSystemMinioDeployment(nfsStore, "default", kotsadmtypes.KotsadmOptions{})
+	if deployment.Spec.Template.Spec.Affinity != nil {
+		t.Errorf("Expected nfs store not to be pinned, got %+v", deployment.Spec.Template.Spec.Affinity)
+	}
+
+	hostPathStore := &types.Store{HostPath: &types.StoreHostPath{Path: "/var/lib/backups", Node: "node-2"}}
+	deployment = fileSystemMinioDeployment(hostPathStore, "default", kotsadmtypes.KotsadmOptions{})
+	if node := hostPathNodeFromAffinity(deployment.Spec.Template.Spec.Affinity); node != "node-2" {
+		t.Errorf("Expected host path store to be pinned to node-2, got %q", node)
+	}
+}
+
+func TestEnsureHostPathNode(t *testing.T) {
+	node := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	tests := []struct {
+		name         string
+		nodes        []*corev1.Node
+		hostPath     *types.StoreHostPath
+		expectedNode string
+		wantErr      bool
+	}{
+		{
+			name:         "single node",
+			nodes:        []*corev1.Node{node("node-1")},
+			hostPath:     &types.StoreHostPath{Path: "/var/lib/backups"},
+			expectedNode: "node-1",
+		},
+		{
+			name:     "multiple nodes without node",
+			nodes:    []*corev1.Node{node("node-1"), node("node-2")},
+			hostPath: &types.StoreHostPath{Path: "/var/lib/backups"},
+			wantErr:  true,
+		},
+		{
+			name:         "multiple nodes with node",
+			nodes:        []*corev1.Node{node("node-1"), node("node-2")},
+			hostPath:     &types.StoreHostPath{Path: "/var/lib/backups", Node: "node-2"},
+			expectedNode: "node-2",
+		},
+		{
+			name:     "node does not exist",
+			nodes:    []*corev1.Node{node("node-1")},
+			hostPath: &types.StoreHostPath{Path: "/var/lib/backups", Node: "node-2"},
+			wantErr:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			for _, n := range test.nodes {
+				if _, err := clientset.CoreV1().Nodes().Create(context.TODO(), n, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := ensureHostPathNode(clientset, test.hostPath)
+			if test.wantErr {
+				if _, ok := err.(util.ActionableError); !ok {
+					t.Errorf("Expected actionable error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if test.hostPath.Node != test.expectedNode {
+				t.Errorf("Expected node %q, got %q", test.expectedNode, test.hostPath.Node)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package snapshot
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	gcpstorage "cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+type objectInfo struct {
+	Key  string
+	Size int64
+}
+
+// objectStore is the part of the object storage api of the snapshot store that is used to copy backups in and out of the store
+type objectStore interface {
+	ListObjects(prefix string) ([]objectInfo, error)
+	GetObject(key string) (io.ReadCloser, error)
+	PutObject(key string, body io.Reader) error
+	ObjectExists(key string) (bool, error)
+	DeleteObject(key string) error
+}
+
+func newObjectStore(store *types.Store) (objectStore, error) {
+	if store.AWS != nil {
+		return newS3ObjectStore(awsStoreConfig(store.AWS), store.Bucket), nil
+	}
+
+	if store.Other != nil {
+		return newS3ObjectStore(s3CompatibleConfig(store.Other.Region, store.Other.Endpoint, store.Other.AccessKeyID, store.Other.SecretAccessKey), store.Bucket), nil
+	}
+
+	if store.Internal != nil {
+		return newS3ObjectStore(s3CompatibleConfig(store.Internal.Region, store.Internal.Endpoint, store.Internal.AccessKeyID, store.Internal.SecretAccessKey), store.Bucket), nil
+	}
+
+	if fileSystemStore := store.FileSystem(); fileSystemStore != nil {
+		return newS3ObjectStore(s3CompatibleConfig(fileSystemStore.Region, fileSystemStore.Endpoint, fileSystemStore.AccessKeyID, fileSystemStore.SecretAccessKey), store.Bucket), nil
+	}
+
+	if store.Google != nil {
+		opts := []option.ClientOption{}
+		if !store.Google.UseInstanceRole {
+			opts = append(opts, option.WithCredentialsJSON([]byte(store.Google.JSONFile)))
+		}
+		client, err := gcpstorage.NewClient(context.Background(), opts...)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create storage client")
+		}
+		return &gcpObjectStore{bucket: client.Bucket(store.Bucket)}, nil
+	}
+
+	if store.Azure != nil {
+		container, err := getAzureContainer(store.Azure, store.Bucket)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get container")
+		}
+		return &azureObjectStore{container: container}, nil
+	}
+
+	return nil, errors.New("no valid configuration found")
+}
+
+func awsStoreConfig(storeAWS *types.StoreAWS) *aws.Config {
+	awsConfig := &aws.Config{
+		Region:           aws.String(storeAWS.Region),
+		DisableSSL:       aws.Bool(false),
+		S3ForcePathStyle: aws.Bool(false),
+	}
+	if storeAWS.UseInstanceRole {
+		awsConfig.Credentials = credentials.NewChainCredentials([]credentials.Provider{
+			&ec2rolecreds.EC2RoleProvider{
+				Client:       ec2metadata.New(session.New()),
+				ExpiryWindow: 5 * time.Minute,
+			},
+		})
+	} else {
+		awsConfig.Credentials = credentials.NewStaticCredentials(storeAWS.AccessKeyID, storeAWS.SecretAccessKey, "")
+	}
+	return awsConfig
+}
+
+func s3CompatibleConfig(region string, endpoint string, accessKeyID string, secretAccessKey string) *aws.Config {
+	s3Config := &aws.Config{
+		Region:           aws.String(region),
+		Endpoint:         aws.String(endpoint),
+		DisableSSL:       aws.Bool(true), // TODO: this needs to be configurable
+		S3ForcePathStyle: aws.Bool(true),
+	}
+
+	if accessKeyID != "" && secretAccessKey != "" {
+		s3Config.Credentials = credentials.NewStaticCredentials(accessKeyID, secretAccessKey, "")
+	}
+
+	return s3Config
+}
+
+type s3ObjectStore struct {
+	session *session.Session
+	client  *s3.S3
+	bucket  string
+}
+
+func newS3ObjectStore(s3Config *aws.Config, bucket string) *s3ObjectStore {
+	newSession := session.New(s3Config)
+	return &s3ObjectStore{
+		session: newSession,
+		client:  s3.New(newSession),
+		bucket:  bucket,
+	}
+}
+
+func (s *s3ObjectStore) ListObjects(prefix string) ([]objectInfo, error) {
+	objects := []objectInfo{}
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			objects = append(objects, objectInfo{
+				Key:  aws.StringValue(object.Key),
+				Size: aws.Int64Value(object.Size),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list objects")
+	}
+	return objects, nil
+}
+
+func (s *s3ObjectStore) GetObject(key string) (io.ReadCloser, error) {
+	output, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get object")
+	}
+	return output.Body, nil
+}
+
+func (s *s3ObjectStore) PutObject(key string, body io.Reader) error {
+	uploader := s3manager.NewUploader(s.session)
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to upload object")
+	}
+	return nil
+}
+
+func (s *s3ObjectStore) ObjectExists(key string) (bool, error) {
+	_, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NotFound" {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "failed to head object")
+	}
+	return true, nil
+}
+
+func (s *s3ObjectStore) DeleteObject(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete object")
+	}
+	return nil
+}
+
+type gcpObjectStore struct {
+	bucket *gcpstorage.BucketHandle
+}
+
+func (s *gcpObjectStore) ListObjects(prefix string) ([]objectInfo, error) {
+	objects := []objectInfo{}
+	objectsItr := s.bucket.Objects(context.Background(), &gcpstorage.Query{Prefix: prefix})
+	for {
+		attrs, err := objectsItr.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list objects")
+		}
+		objects = append(objects, objectInfo{
+			Key:  attrs.Name,
+			Size: attrs.Size,
+		})
+	}
+	return objects, nil
+}
+
+func (s *gcpObjectStore) GetObject(key string) (io.ReadCloser, error) {
+	reader, err := s.bucket.Object(key).NewReader(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create object reader")
+	}
+	return reader, nil
+}
+
+func (s *gcpObjectStore) PutObject(key string, body io.Reader) error {
+	writer := s.bucket.Object(key).NewWriter(context.Background())
+	if _, err := io.Copy(writer, body); err != nil {
+		writer.Close()
+		return errors.Wrap(err, "failed to write object")
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrap(err, "failed to close object writer")
+	}
+	return nil
+}
+
+func (s *gcpObjectStore) ObjectExists(key string) (bool, error) {
+	_, err := s.bucket.Object(key).Attrs(context.Background())
+	if err == gcpstorage.ErrObjectNotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get object attributes")
+	}
+	return true, nil
+}
+
+func (s *gcpObjectStore) DeleteObject(key string) error {
+	err := s.bucket.Object(key).Delete(context.Background())
+	if err != nil && err != gcpstorage.ErrObjectNotExist {
+		return errors.Wrap(err, "failed to delete object")
+	}
+	return nil
+}
+
+type azureObjectStore struct {
+	container *storage.Container
+}
+
+func (s *azureObjectStore) ListObjects(prefix string) ([]objectInfo, error) {
+	objects := []objectInfo{}
+	marker := ""
+	for {
+		res, err := s.container.ListBlobs(storage.ListBlobsParameters{
+			Prefix: prefix,
+			Marker: marker,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list blobs")
+		}
+		for _, blob := range res.Blobs {
+			objects = append(objects, objectInfo{
+				Key:  blob.Name,
+				Size: blob.Properties.ContentLength,
+			})
+		}
+		if res.NextMarker == "" {
+			break
+		}
+		marker = res.NextMarker
+	}
+	return objects, nil
+}
+
+func (s *azureObjectStore) GetObject(key string) (io.ReadCloser, error) {
+	reader, err := s.container.GetBlobReference(key).Get(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get blob")
+	}
+	return reader, nil
+}
+
+func (s *azureObjectStore) PutObject(key string, body io.Reader) error {
+	if err := s.container.GetBlobReference(key).CreateBlockBlobFromReader(body, nil); err != nil {
+		return errors.Wrap(err, "failed to create blob")
+	}
+	return nil
+}
+
+func (s *azureObjectStore) ObjectExists(key string) (bool, error) {
+	exists, err := s.container.GetBlobReference(key).Exists()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check blob existence")
+	}
+	return exists, nil
+}
+
+func (s *azureObjectStore) DeleteObject(key string) error {
+	if _, err := s.container.GetBlobReference(key).DeleteIfExists(nil); err != nil {
+		return errors.Wrap(err, "failed to delete blob")
+	}
+	return nil
+}
+
+// objectKey joins the store prefix and the parts of an object key
+func objectKey(prefix string, parts ...string) string {
+	key := strings.Trim(prefix, "/")
+	for _, part := range parts {
+		part = strings.Trim(part, "/")
+		if part == "" {
+			continue
+		}
+		if key == "" {
+			key = part
+		} else {
+			key = key + "/" + part
+		}
+	}
+	return key
+}
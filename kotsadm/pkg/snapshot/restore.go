@@ -11,12 +11,15 @@ import (
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
 	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	kotssnapshot "github.com/replicatedhq/kots/pkg/snapshot"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	veleroclientv1 "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/typed/velero/v1"
 	velerolabel "github.com/vmware-tanzu/velero/pkg/label"
 	"go.uber.org/zap"
 	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 )
 
@@ -49,7 +52,7 @@ func GetRestore(snapshotName string) (*velerov1.Restore, error) {
 	return restore, nil
 }
 
-func CreateApplicationRestore(snapshotName string, appSlug string) error {
+func CreateApplicationRestore(snapshotName string, appSlug string, restoreOptions *kotssnapshottypes.RestoreOptions) error {
 	// Reference https://github.com/vmware-tanzu/velero/blob/42b612645863c2b3e451b447f9bf798295dd7dba/pkg/cmd/cli/restore/create.go#L222
 
 	logger.Debug("creating restore",
@@ -62,7 +65,6 @@ func CreateApplicationRestore(snapshotName string, appSlug string) error {
 
 	veleroNamespace := bsl.Namespace
 
-	// get the backup
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return errors.Wrap(err, "failed to get cluster config")
@@ -73,9 +75,70 @@ func CreateApplicationRestore(snapshotName string, appSlug string) error {
 		return errors.Wrap(err, "failed to create clientset")
 	}
 
+	restore, _, err := buildApplicationRestore(veleroClient, veleroNamespace, snapshotName, appSlug, restoreOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to build restore")
+	}
+
+	_, err = veleroClient.Restores(veleroNamespace).Create(context.TODO(), restore, metav1.CreateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to create restore")
+	}
+
+	return nil
+}
+
+// PlanApplicationRestore returns what restoring the app from the snapshot would restore, without creating the restore
+func PlanApplicationRestore(snapshotName string, appSlug string, restoreOptions *kotssnapshottypes.RestoreOptions) (*kotssnapshottypes.RestorePlan, error) {
+	bsl, err := FindBackupStoreLocation()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get velero namespace")
+	}
+
+	veleroNamespace := bsl.Namespace
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	veleroClient, err := veleroclientv1.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create clientset")
+	}
+
+	restore, volumes, err := buildApplicationRestore(veleroClient, veleroNamespace, snapshotName, appSlug, restoreOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build restore")
+	}
+
+	plan := &kotssnapshottypes.RestorePlan{
+		RestoreName: restore.Name,
+		Spec:        restore.Spec,
+		Volumes:     volumes,
+	}
+
+	return plan, nil
+}
+
+func buildApplicationRestore(veleroClient veleroclientv1.VeleroV1Interface, veleroNamespace string, snapshotName string, appSlug string, restoreOptions *kotssnapshottypes.RestoreOptions) (*velerov1.Restore, []kotssnapshottypes.PodVolume, error) {
 	backup, err := veleroClient.Backups(veleroNamespace).Get(context.TODO(), snapshotName, metav1.GetOptions{})
 	if err != nil {
-		return errors.Wrap(err, "failed to find backup")
+		return nil, nil, errors.Wrap(err, "failed to find backup")
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create clientset")
+	}
+
+	if err := checkBackupEncryption(clientset, veleroNamespace, backup); err != nil {
+		return nil, nil, err
 	}
 
 	trueVal := true
@@ -104,12 +167,25 @@ func CreateApplicationRestore(snapshotName string, appSlug string) error {
 		}
 	}
 
-	_, err = veleroClient.Restores(veleroNamespace).Create(context.TODO(), restore, metav1.CreateOptions{})
+	backupVolumes, err := kotssnapshot.ListBackupPodVolumes(context.TODO(), veleroClient, veleroNamespace, snapshotName)
 	if err != nil {
-		return errors.Wrap(err, "failed to create restore")
+		return nil, nil, errors.Wrap(err, "failed to list backup volumes")
 	}
 
-	return nil
+	var volumeLabels map[kotssnapshottypes.PodVolume]kotssnapshottypes.PodVolumeLabels
+	if restoreOptions.IsSelective() {
+		volumeLabels, err = kotssnapshot.ListBackupPodVolumeLabels(context.TODO(), veleroClient, veleroNamespace, snapshotName, backupVolumes)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to list backup volume labels")
+		}
+	}
+
+	volumes, err := kotssnapshot.ApplyRestoreOptions(&restore.Spec, restoreOptions, backupVolumes, volumeLabels)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to apply restore options")
+	}
+
+	return restore, volumes, nil
 }
 
 func DeleteRestore(snapshotName string) error {
@@ -0,0 +1,97 @@
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
+)
+
+type retentionRule struct {
+	name   string
+	keep   int
+	period func(t time.Time) string
+}
+
+func retentionRules(policy *kotssnapshottypes.RetentionPolicy) []retentionRule {
+	return []retentionRule{
+		{
+			name: "hourly",
+			keep: policy.Hourly,
+			period: func(t time.Time) string {
+				return t.Format("2006-01-02 15:00")
+			},
+		},
+		{
+			name: "daily",
+			keep: policy.Daily,
+			period: func(t time.Time) string {
+				return t.Format("2006-01-02")
+			},
+		},
+		{
+			name: "weekly",
+			keep: policy.Weekly,
+			period: func(t time.Time) string {
+				year, week := t.ISOWeek()
+				return fmt.Sprintf("%d-W%02d", year, week)
+			},
+		},
+		{
+			name: "monthly",
+			keep: policy.Monthly,
+			period: func(t time.Time) string {
+				return t.Format("2006-01")
+			},
+		},
+	}
+}
+
+// ApplyRetentionPolicy sets the retention reasons of the completed scheduled backups and returns the ones that the policy does not keep.
+// Each rule keeps the newest backup of each of its most recent periods.
+// Manual, pre-upgrade, unfinished and failed backups are left alone and still expire with their ttl.
+func ApplyRetentionPolicy(backups []*types.Backup, policy *kotssnapshottypes.RetentionPolicy) []*types.Backup {
+	if !policy.IsEnabled() {
+		return nil
+	}
+
+	scheduled := []*types.Backup{}
+	for _, backup := range backups {
+		if backup.Trigger != "schedule" || backup.Status != "Completed" || backup.StartedAt == nil {
+			continue
+		}
+		scheduled = append(scheduled, backup)
+	}
+
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		return scheduled[i].StartedAt.After(*scheduled[j].StartedAt)
+	})
+
+	for _, rule := range retentionRules(policy) {
+		kept := 0
+		lastPeriod := ""
+		for _, backup := range scheduled {
+			if kept >= rule.keep {
+				break
+			}
+			period := rule.period(backup.StartedAt.UTC())
+			if period == lastPeriod {
+				continue
+			}
+			lastPeriod = period
+			backup.RetentionReasons = append(backup.RetentionReasons, fmt.Sprintf("%s %s", rule.name, period))
+			kept++
+		}
+	}
+
+	prune := []*types.Backup{}
+	for _, backup := range scheduled {
+		if len(backup.RetentionReasons) == 0 {
+			prune = append(prune, backup)
+		}
+	}
+
+	return prune
+}
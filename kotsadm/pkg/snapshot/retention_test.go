@@ -0,0 +1,81 @@
+package snapshot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
+)
+
+func TestApplyRetentionPolicy(t *testing.T) {
+	newBackup := func(name string, trigger string, status string, startedAt time.Time) *types.Backup {
+		return &types.Backup{
+			Name:      name,
+			Trigger:   trigger,
+			Status:    status,
+			StartedAt: &startedAt,
+		}
+	}
+
+	// 2021-01-04 is a monday
+	backups := []*types.Backup{
+		newBackup("mon-1200", "schedule", "Completed", time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)),
+		newBackup("mon-1130", "schedule", "Completed", time.Date(2021, 1, 4, 11, 30, 0, 0, time.UTC)),
+		newBackup("mon-1100", "schedule", "Completed", time.Date(2021, 1, 4, 11, 0, 0, 0, time.UTC)),
+		newBackup("mon-1000", "schedule", "Completed", time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)),
+		newBackup("sun-2300", "schedule", "Completed", time.Date(2021, 1, 3, 23, 0, 0, 0, time.UTC)),
+		newBackup("sun-1200", "schedule", "Completed", time.Date(2021, 1, 3, 12, 0, 0, 0, time.UTC)),
+		newBackup("sat-2300", "schedule", "Completed", time.Date(2021, 1, 2, 23, 0, 0, 0, time.UTC)),
+		newBackup("dec-2300", "schedule", "Completed", time.Date(2020, 12, 20, 23, 0, 0, 0, time.UTC)),
+		newBackup("nov-2300", "schedule", "Completed", time.Date(2020, 11, 20, 23, 0, 0, 0, time.UTC)),
+		newBackup("failed", "schedule", "Failed", time.Date(2021, 1, 4, 12, 30, 0, 0, time.UTC)),
+		newBackup("manual", "manual", "Completed", time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)),
+		{Name: "in-progress", Trigger: "schedule", Status: "InProgress"},
+	}
+
+	policy := &kotssnapshottypes.RetentionPolicy{
+		Hourly:  2,
+		Daily:   2,
+		Weekly:  2,
+		Monthly: 2,
+	}
+
+	prune := ApplyRetentionPolicy(backups, policy)
+
+	pruned := []string{}
+	for _, backup := range prune {
+		pruned = append(pruned, backup.Name)
+	}
+	expectedPruned := []string{"mon-1100", "mon-1000", "sun-1200", "sat-2300", "nov-2300"}
+	if !reflect.DeepEqual(pruned, expectedPruned) {
+		t.Errorf("Expected %v to be pruned, got %v", expectedPruned, pruned)
+	}
+
+	expectedReasons := map[string][]string{
+		"mon-1200": {"hourly 2021-01-04 12:00", "daily 2021-01-04", "weekly 2021-W01", "monthly 2021-01"},
+		"mon-1130": {"hourly 2021-01-04 11:00"},
+		"sun-2300": {"daily 2021-01-03", "weekly 2020-W53"},
+		"dec-2300": {"monthly 2020-12"},
+	}
+	for _, backup := range backups {
+		if !reflect.DeepEqual(backup.RetentionReasons, expectedReasons[backup.Name]) {
+			t.Errorf("Expected %s to be kept by %v, got %v", backup.Name, expectedReasons[backup.Name], backup.RetentionReasons)
+		}
+	}
+}
+
+func TestApplyRetentionPolicyDisabled(t *testing.T) {
+	startedAt := time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)
+	backups := []*types.Backup{
+		{Name: "scheduled", Trigger: "schedule", Status: "Completed", StartedAt: &startedAt},
+	}
+
+	if prune := ApplyRetentionPolicy(backups, nil); len(prune) != 0 {
+		t.Errorf("Expected nothing to be pruned without a policy, got %d backups", len(prune))
+	}
+	if prune := ApplyRetentionPolicy(backups, &kotssnapshottypes.RetentionPolicy{}); len(prune) != 0 {
+		t.Errorf("Expected nothing to be pruned with an empty policy, got %d backups", len(prune))
+	}
+}
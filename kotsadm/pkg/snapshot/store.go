@@ -25,6 +25,7 @@ import (
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
 	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/providers"
 	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	"github.com/replicatedhq/kots/pkg/snapshot/encryption"
 	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	veleroclientv1 "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/typed/velero/v1"
 	"go.uber.org/zap"
@@ -39,6 +40,10 @@ import (
 
 // UpdateGlobalStore will update the in-cluster storage with exactly what's in the store param
 func UpdateGlobalStore(store *types.Store) (*velerov1.BackupStorageLocation, error) {
+	if err := checkMetadataEncryption(store); err != nil {
+		return nil, errors.Wrap(err, "invalid encryption")
+	}
+
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get cluster config")
@@ -82,6 +87,10 @@ func UpdateGlobalStore(store *types.Store) (*velerov1.BackupStorageLocation, err
 		kotsadmVeleroBackendStorageLocation.Spec.Config = map[string]string{
 			"region": store.AWS.Region,
 		}
+		if store.Encryption.Type() == encryption.KeyTypeKMS {
+			// velero encrypts backup metadata server side with the kms key, restic encrypts volume data client side
+			kotsadmVeleroBackendStorageLocation.Spec.Config["kmsKeyId"] = store.Encryption.KMSKeyID
+		}
 
 		if store.AWS.UseInstanceRole {
 			// delete the secret
@@ -214,6 +223,70 @@ func UpdateGlobalStore(store *types.Store) (*velerov1.BackupStorageLocation, err
 				return nil, errors.Wrap(err, "failed to update other secret")
 			}
 		}
+	} else if fileSystemStore := store.FileSystem(); fileSystemStore != nil {
+		kotsadmVeleroBackendStorageLocation.Spec.Config = map[string]string{
+			"region":           fileSystemStore.Region,
+			"s3Url":            fileSystemStore.Endpoint,
+			"s3ForcePathStyle": "true",
+		}
+
+		fileSystemCfg := ini.Empty()
+		section, err := fileSystemCfg.NewSection("default")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create default section in file system creds")
+		}
+		_, err = section.NewKey("aws_access_key_id", fileSystemStore.AccessKeyID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create file system access key id")
+		}
+
+		_, err = section.NewKey("aws_secret_access_key", fileSystemStore.SecretAccessKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create file system secret access key")
+		}
+
+		var fileSystemCredentials bytes.Buffer
+		writer := bufio.NewWriter(&fileSystemCredentials)
+		_, err = fileSystemCfg.WriteTo(writer)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to write ini")
+		}
+		if err := writer.Flush(); err != nil {
+			return nil, errors.Wrap(err, "failed to flush buffer")
+		}
+
+		// create or update the secret
+		if kuberneteserrors.IsNotFound(currentSecretErr) {
+			// create
+			toCreate := corev1.Secret{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Secret",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cloud-credentials",
+					Namespace: kotsadmVeleroBackendStorageLocation.Namespace,
+				},
+				Data: map[string][]byte{
+					"cloud": fileSystemCredentials.Bytes(),
+				},
+			}
+			_, err = clientset.CoreV1().Secrets(kotsadmVeleroBackendStorageLocation.Namespace).Create(context.TODO(), &toCreate, metav1.CreateOptions{})
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create file system secret")
+			}
+		} else {
+			// update
+			if currentSecret.Data == nil {
+				currentSecret.Data = map[string][]byte{}
+			}
+
+			currentSecret.Data["cloud"] = fileSystemCredentials.Bytes()
+			_, err = clientset.CoreV1().Secrets(kotsadmVeleroBackendStorageLocation.Namespace).Update(context.TODO(), currentSecret, metav1.UpdateOptions{})
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to update file system secret")
+			}
+		}
 	} else if store.Internal != nil {
 		kotsadmVeleroBackendStorageLocation.Spec.Config = map[string]string{
 			"region":           store.Internal.Region,
@@ -374,11 +447,29 @@ func UpdateGlobalStore(store *types.Store) (*velerov1.BackupStorageLocation, err
 		}
 	}
 
+	objectStore, err := newObjectStore(store)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create object store")
+	}
+	envelope, dataKey, err := initStoreEncryption(store, objectStore)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize store encryption")
+	}
+	if err := updateEncryptionSecrets(clientset, kotsadmVeleroBackendStorageLocation.Namespace, store, envelope, dataKey); err != nil {
+		return nil, errors.Wrap(err, "failed to update encryption secrets")
+	}
+
 	updated, err := veleroClient.BackupStorageLocations(kotsadmVeleroBackendStorageLocation.Namespace).Update(context.TODO(), kotsadmVeleroBackendStorageLocation, metav1.UpdateOptions{})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to update backup storage location")
 	}
 
+	if !isFileSystemStore(store) {
+		if err := DeleteFileSystemMinio(); err != nil {
+			return nil, errors.Wrap(err, "failed to delete file system minio")
+		}
+	}
+
 	return updated, nil
 }
 
@@ -439,7 +530,13 @@ func GetGlobalStore(kotsadmVeleroBackendStorageLocation *velerov1.BackupStorageL
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to get s3 secret")
 			}
-			if s3Secret != nil && string(s3Secret.Data["endpoint"]) == endpoint {
+			isFileSystem, err := getFileSystemStore(&store, endpoint)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get file system store")
+			}
+			if isFileSystem {
+				// getFileSystemStore filled in the nfs or host path store
+			} else if s3Secret != nil && string(s3Secret.Data["endpoint"]) == endpoint {
 				store.Internal = &types.StoreInternal{
 					Region:               kotsadmVeleroBackendStorageLocation.Spec.Config["region"],
 					Endpoint:             endpoint,
@@ -474,7 +571,10 @@ func GetGlobalStore(kotsadmVeleroBackendStorageLocation *velerov1.BackupStorageL
 
 			for _, section := range awsCfg.Sections() {
 				if section.Name() == "default" {
-					if store.Internal != nil {
+					if fileSystemStore := store.FileSystem(); fileSystemStore != nil {
+						fileSystemStore.AccessKeyID = section.Key("aws_access_key_id").Value()
+						fileSystemStore.SecretAccessKey = section.Key("aws_secret_access_key").Value()
+					} else if store.Internal != nil {
 						store.Internal.AccessKeyID = section.Key("aws_access_key_id").Value()
 						store.Internal.SecretAccessKey = section.Key("aws_secret_access_key").Value()
 					} else if store.Other != nil {
@@ -540,6 +640,12 @@ func GetGlobalStore(kotsadmVeleroBackendStorageLocation *velerov1.BackupStorageL
 		break
 	}
 
+	encryptionKey, _, err := getStoreEncryption(clientset, kotsadmVeleroBackendStorageLocation.Namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get store encryption")
+	}
+	store.Encryption = encryptionKey
+
 	return &store, nil
 }
 
@@ -569,6 +675,26 @@ func FindBackupStoreLocation() (*velerov1.BackupStorageLocation, error) {
 }
 
 func ValidateStore(store *types.Store) error {
+	if err := validateStoreProvider(store); err != nil {
+		return err
+	}
+
+	if err := checkMetadataEncryption(store); err != nil {
+		return errors.Wrap(err, "invalid encryption")
+	}
+
+	objectStore, err := newObjectStore(store)
+	if err != nil {
+		return errors.Wrap(err, "failed to create object store")
+	}
+	if _, _, err := openStoreEncryption(store, objectStore); err != nil {
+		return errors.Wrap(err, "failed to validate encryption")
+	}
+
+	return nil
+}
+
+func validateStoreProvider(store *types.Store) error {
 	if store.AWS != nil {
 		if err := validateAWS(store.AWS, store.Bucket); err != nil {
 			return errors.Wrap(err, "failed to validate AWS configuration")
@@ -604,6 +730,20 @@ func ValidateStore(store *types.Store) error {
 		return nil
 	}
 
+	if store.NFS != nil {
+		if err := validateFileSystem(store); err != nil {
+			return errors.Wrap(err, "failed to validate NFS configuration")
+		}
+		return nil
+	}
+
+	if store.HostPath != nil {
+		if err := validateFileSystem(store); err != nil {
+			return errors.Wrap(err, "failed to validate host path configuration")
+		}
+		return nil
+	}
+
 	return errors.New("no valid configuration found")
 }
 
@@ -640,21 +780,39 @@ func validateAWS(storeAWS *types.StoreAWS, bucket string) error {
 }
 
 func validateAzure(storeAzure *types.StoreAzure, bucket string) error {
+	container, err := getAzureContainer(storeAzure, bucket)
+	if err != nil {
+		return errors.Wrap(err, "failed to get container")
+	}
+
+	exists, err := container.Exists()
+	if err != nil {
+		return errors.Wrap(err, "failed to check container existence")
+	}
+
+	if !exists {
+		return errors.New("container does not exist")
+	}
+
+	return nil
+}
+
+func getAzureContainer(storeAzure *types.StoreAzure, bucket string) (*storage.Container, error) {
 	// Mostly copied from Velero Azure plugin
 
 	env, err := azure.EnvironmentFromName(storeAzure.CloudName)
 	if err != nil {
-		return errors.Wrap(err, "failed to find azure env")
+		return nil, errors.Wrap(err, "failed to find azure env")
 	}
 
 	oauthConfig, err := adal.NewOAuthConfig(env.ActiveDirectoryEndpoint, storeAzure.TenantID)
 	if err != nil {
-		return errors.Wrap(err, "failed to get OAuthConfig")
+		return nil, errors.Wrap(err, "failed to get OAuthConfig")
 	}
 
 	spt, err := adal.NewServicePrincipalToken(*oauthConfig, storeAzure.ClientID, storeAzure.ClientSecret, env.ResourceManagerEndpoint)
 	if err != nil {
-		return errors.Wrap(err, "failed to get service principal token")
+		return nil, errors.Wrap(err, "failed to get service principal token")
 	}
 
 	storageAccountsClient := storagemgmt.NewAccountsClientWithBaseURI(env.ResourceManagerEndpoint, storeAzure.SubscriptionID)
@@ -662,10 +820,10 @@ func validateAzure(storeAzure *types.StoreAzure, bucket string) error {
 
 	res, err := storageAccountsClient.ListKeys(context.TODO(), storeAzure.ResourceGroup, storeAzure.StorageAccount)
 	if err != nil {
-		return errors.Wrap(err, "failed to list account keys")
+		return nil, errors.Wrap(err, "failed to list account keys")
 	}
 	if res.Keys == nil || len(*res.Keys) == 0 {
-		return errors.New("No storage keys found")
+		return nil, errors.New("No storage keys found")
 	}
 
 	var storageKey string
@@ -679,30 +837,21 @@ func validateAzure(storeAzure *types.StoreAzure, bucket string) error {
 	}
 
 	if storageKey == "" {
-		return errors.New("No storage key with Full permissions found")
+		return nil, errors.New("No storage key with Full permissions found")
 	}
 
 	storageClient, err := storage.NewBasicClientOnSovereignCloud(storeAzure.StorageAccount, storageKey, env)
 	if err != nil {
-		return errors.Wrap(err, "failed to get storage client")
+		return nil, errors.Wrap(err, "failed to get storage client")
 	}
 
 	blobClient := storageClient.GetBlobService()
 	container := blobClient.GetContainerReference(bucket)
 	if container == nil {
-		return errors.Errorf("unable to get container reference for bucket %s", bucket)
-	}
-
-	exists, err := container.Exists()
-	if err != nil {
-		return errors.Wrap(err, "failed to check container existence")
-	}
-
-	if !exists {
-		return errors.New("container does not exist")
+		return nil, errors.Errorf("unable to get container reference for bucket %s", bucket)
 	}
 
-	return nil
+	return container, nil
 }
 
 func validateGCP(storeGoogle *types.StoreGoogle, bucket string) error {
@@ -816,6 +965,18 @@ func Redact(store *types.Store) error {
 		}
 	}
 
+	if fileSystemStore := store.FileSystem(); fileSystemStore != nil {
+		if fileSystemStore.SecretAccessKey != "" {
+			fileSystemStore.SecretAccessKey = "--- REDACTED ---"
+		}
+	}
+
+	if store.Encryption != nil {
+		if store.Encryption.Passphrase != "" {
+			store.Encryption.Passphrase = "--- REDACTED ---"
+		}
+	}
+
 	return nil
 }
 
@@ -1,6 +1,12 @@
 package types
 
-import "time"
+import (
+	"time"
+
+	appstatustypes "github.com/replicatedhq/kots/pkg/api/appstatus/types"
+	"github.com/replicatedhq/kots/pkg/snapshot/encryption"
+	batchv1 "k8s.io/api/batch/v1"
+)
 
 type StoreAWS struct {
 	Region          string `json:"region"`
@@ -40,6 +46,30 @@ type StoreInternal struct {
 	ObjectStoreClusterIP string `json:"objectStoreClusterIP"`
 }
 
+// StoreFileSystem is the s3 compatible shim kotsadm deploys in front of nfs and host path stores
+type StoreFileSystem struct {
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"accessKeyID"`
+	SecretAccessKey string `json:"secretAccessKey"` // added for unmarshaling, redacted on marshaling
+	Endpoint        string `json:"endpoint"`
+}
+
+type StoreNFS struct {
+	Server string `json:"server"`
+	Path   string `json:"path"`
+
+	StoreFileSystem
+}
+
+type StoreHostPath struct {
+	Path string `json:"path"`
+	// Node is the name of the node the path is on, the shim is pinned to it.
+	// It is required on clusters with more than one node.
+	Node string `json:"node,omitempty"`
+
+	StoreFileSystem
+}
+
 type Store struct {
 	Provider string         `json:"provider"`
 	Bucket   string         `json:"bucket"`
@@ -49,6 +79,22 @@ type Store struct {
 	Google   *StoreGoogle   `json:"gcp,omitempty"`
 	Other    *StoreOther    `json:"other,omitempty"`
 	Internal *StoreInternal `json:"internal,omitempty"`
+	NFS      *StoreNFS      `json:"nfs,omitempty"`
+	HostPath *StoreHostPath `json:"hostPath,omitempty"`
+	// Encryption is the key that restic volume data is encrypted with.
+	// Backup metadata is only encrypted with aws kms keys, server side, and is otherwise stored unencrypted.
+	Encryption *encryption.Key `json:"encryption,omitempty"`
+}
+
+// FileSystem returns the shim settings of an nfs or host path store
+func (s *Store) FileSystem() *StoreFileSystem {
+	if s.NFS != nil {
+		return &s.NFS.StoreFileSystem
+	}
+	if s.HostPath != nil {
+		return &s.HostPath.StoreFileSystem
+	}
+	return nil
 }
 
 type Backup struct {
@@ -65,6 +111,10 @@ type Backup struct {
 	VolumeBytes        int64      `json:"volumeBytes"`
 	VolumeSizeHuman    string     `json:"volumeSizeHuman"`
 	SupportBundleID    string     `json:"supportBundleId,omitempty"`
+
+	Verifications []BackupVerification `json:"verifications,omitempty"`
+	// RetentionReasons are the rules of the retention policy that keep a scheduled backup
+	RetentionReasons []string `json:"retentionReasons,omitempty"`
 }
 
 type BackupDetail struct {
@@ -103,6 +153,9 @@ type SnapshotHook struct {
 
 type SnapshotVolume struct {
 	Name                 string     `json:"name"`
+	PodName              string     `json:"podName"`
+	PodNamespace         string     `json:"podNamespace"`
+	PodVolumeName        string     `json:"podVolumeName"`
 	SizeBytesHuman       string     `json:"sizeBytesHuman"`
 	DoneBytesHuman       string     `json:"doneBytesHuman"`
 	CompletionPercent    int        `json:"completionPercent"`
@@ -139,6 +192,11 @@ type VolumeSummary struct {
 	VolumeSizeHuman    string `json:"volumeSizeHuman"`
 }
 
+const (
+	PreUpgradeSnapshotApplication = "application"
+	PreUpgradeSnapshotInstance    = "instance"
+)
+
 type SnapshotSchedule struct {
 	Schedule string `json:"schedule"`
 }
@@ -169,3 +227,36 @@ type ScheduledInstanceSnapshot struct {
 	// name of Backup CR will be set once scheduled
 	BackupName string `json:"backupName,omitempty"`
 }
+
+const (
+	BackupVerificationStatusRestoring  = "restoring"
+	BackupVerificationStatusVerifying  = "verifying"
+	BackupVerificationStatusCleaningUp = "cleaningUp"
+	BackupVerificationStatusCompleted  = "completed"
+
+	BackupVerificationOutcomePassed = "passed"
+	BackupVerificationOutcomeFailed = "failed"
+
+	BackupVerificationJobRunning   = "running"
+	BackupVerificationJobSucceeded = "succeeded"
+	BackupVerificationJobFailed    = "failed"
+)
+
+// BackupVerification is a test restore of an app from a backup into scratch namespaces.
+// The outcome is set once the app's status informers and the optional verification job have passed or failed.
+type BackupVerification struct {
+	BackupName       string                         `json:"backupName"`
+	AppID            string                         `json:"appId"`
+	Status           string                         `json:"status"`
+	Outcome          string                         `json:"outcome,omitempty"`
+	Message          string                         `json:"message,omitempty"`
+	RestoreName      string                         `json:"restoreName"`
+	NamespaceMapping map[string]string              `json:"namespaceMapping"`
+	JobSpec          *batchv1.JobSpec               `json:"-"`
+	JobStatus        string                         `json:"jobStatus,omitempty"`
+	Timeout          time.Duration                  `json:"-"`
+	ResourceStates   []appstatustypes.ResourceState `json:"resourceStates"`
+	StartedAt        *time.Time                     `json:"startedAt,omitempty"`
+	VerifyingAt      *time.Time                     `json:"verifyingAt,omitempty"`
+	FinishedAt       *time.Time                     `json:"finishedAt,omitempty"`
+}
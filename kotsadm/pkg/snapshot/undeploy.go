@@ -0,0 +1,175 @@
+package snapshot
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+)
+
+// RestoreUndeploy is what is removed from the cluster before a restore, velero does not overwrite resources that exist
+type RestoreUndeploy struct {
+	Manifests       []byte
+	ClearNamespaces []string
+	ClearPVCs       bool
+}
+
+type undeployManifest struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Namespace string            `yaml:"namespace"`
+		Labels    map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Template struct {
+			Metadata struct {
+				Labels map[string]string `yaml:"labels"`
+			} `yaml:"metadata"`
+		} `yaml:"template"`
+		JobTemplate struct {
+			Spec struct {
+				Template struct {
+					Metadata struct {
+						Labels map[string]string `yaml:"labels"`
+					} `yaml:"metadata"`
+				} `yaml:"template"`
+			} `yaml:"spec"`
+		} `yaml:"jobTemplate"`
+	} `yaml:"spec"`
+}
+
+// GetRestoreUndeploy returns what to remove before restoring the app from the backup.
+// A full restore, when the plan is nil, removes the app, clears the namespaces of the backup and deletes the claims of the app.
+// A selective restore only removes the resources in the rendered manifests that the restore restores, including the
+// workloads of the pods it restores, and only deletes the claims of those workloads when it restores volumes.
+func GetRestoreUndeploy(renderedManifests []byte, backup *velerov1.Backup, plan *kotssnapshottypes.RestorePlan, defaultNamespace string) (*RestoreUndeploy, error) {
+	if plan == nil {
+		return &RestoreUndeploy{
+			Manifests:       renderedManifests,
+			ClearNamespaces: backup.Spec.IncludedNamespaces,
+			ClearPVCs:       true,
+		}, nil
+	}
+
+	var selector k8slabels.Selector
+	if plan.Spec.LabelSelector != nil {
+		s, err := metav1.LabelSelectorAsSelector(plan.Spec.LabelSelector)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse label selector")
+		}
+		selector = s
+	}
+
+	restored := [][]byte{}
+	for _, doc := range bytes.Split(renderedManifests, []byte("\n---\n")) {
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		manifest := undeployManifest{}
+		if err := yaml.Unmarshal(doc, &manifest); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal manifest")
+		}
+
+		if isManifestRestored(plan.Spec, selector, manifest, defaultNamespace) {
+			restored = append(restored, doc)
+		}
+	}
+
+	return &RestoreUndeploy{
+		Manifests: bytes.Join(restored, []byte("\n---\n")),
+		ClearPVCs: len(plan.Volumes) > 0,
+	}, nil
+}
+
+func isManifestRestored(spec velerov1.RestoreSpec, selector k8slabels.Selector, manifest undeployManifest, defaultNamespace string) bool {
+	namespace := manifest.Metadata.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if len(spec.IncludedNamespaces) > 0 && !containsString(spec.IncludedNamespaces, "*") && !containsString(spec.IncludedNamespaces, namespace) {
+		return false
+	}
+	if containsString(spec.ExcludedNamespaces, namespace) {
+		return false
+	}
+
+	group := ""
+	if parts := strings.SplitN(manifest.APIVersion, "/", 2); len(parts) == 2 {
+		group = parts[0]
+	}
+	if isResourceRestored(spec, manifest.Kind, group) && labelsMatch(selector, manifest.Metadata.Labels) {
+		return true
+	}
+
+	// the pods are restored without the workload, which would create another pod next to the restored one
+	podLabels := manifest.Spec.Template.Metadata.Labels
+	if manifest.Kind == "CronJob" {
+		podLabels = manifest.Spec.JobTemplate.Spec.Template.Metadata.Labels
+	}
+	if podLabels != nil && isResourceRestored(spec, "Pod", "") && labelsMatch(selector, podLabels) {
+		return true
+	}
+
+	return false
+}
+
+// isResourceRestored matches the kind against the resource names in the restore, as in "deployments" or "deployments.apps"
+func isResourceRestored(spec velerov1.RestoreSpec, kind string, group string) bool {
+	names := resourceNames(kind, group)
+	if len(spec.IncludedResources) > 0 && !containsString(spec.IncludedResources, "*") && !containsAnyString(spec.IncludedResources, names) {
+		return false
+	}
+	if containsAnyString(spec.ExcludedResources, names) {
+		return false
+	}
+	return true
+}
+
+func resourceNames(kind string, group string) []string {
+	singular := strings.ToLower(kind)
+	plural := singular + "s"
+	switch {
+	case strings.HasSuffix(singular, "s"), strings.HasSuffix(singular, "x"), strings.HasSuffix(singular, "ch"), strings.HasSuffix(singular, "sh"):
+		plural = singular + "es"
+	case strings.HasSuffix(singular, "y") && !strings.HasSuffix(singular, "ay") && !strings.HasSuffix(singular, "ey") && !strings.HasSuffix(singular, "oy"):
+		plural = strings.TrimSuffix(singular, "y") + "ies"
+	}
+
+	names := []string{singular, plural}
+	if group != "" {
+		names = append(names, singular+"."+group, plural+"."+group)
+	}
+	return names
+}
+
+func labelsMatch(selector k8slabels.Selector, labels map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+	return selector.Matches(k8slabels.Set(labels))
+}
+
+func containsAnyString(values []string, candidates []string) bool {
+	for _, candidate := range candidates {
+		if containsString(values, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package snapshot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const undeployManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  labels:
+    app: web
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: prometheus
+  namespace: monitoring
+spec:
+  template:
+    metadata:
+      labels:
+        app: prometheus
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        metadata:
+          labels:
+            app: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: prometheus
+  namespace: monitoring
+  labels:
+    app: prometheus`
+
+func TestGetRestoreUndeploy(t *testing.T) {
+	backup := &velerov1.Backup{
+		Spec: velerov1.BackupSpec{
+			IncludedNamespaces: []string{"default", "monitoring"},
+		},
+	}
+
+	tests := []struct {
+		name                    string
+		plan                    *kotssnapshottypes.RestorePlan
+		expectedNames           []string
+		expectedClearNamespaces []string
+		expectedClearPVCs       bool
+	}{
+		{
+			name:                    "full restore",
+			plan:                    nil,
+			expectedNames:           []string{"Deployment/web", "Service/web", "StatefulSet/prometheus", "CronJob/cleanup", "ConfigMap/prometheus"},
+			expectedClearNamespaces: []string{"default", "monitoring"},
+			expectedClearPVCs:       true,
+		},
+		{
+			name: "namespace",
+			plan: &kotssnapshottypes.RestorePlan{
+				Spec: velerov1.RestoreSpec{
+					IncludedNamespaces: []string{"monitoring"},
+				},
+			},
+			expectedNames:     []string{"StatefulSet/prometheus", "ConfigMap/prometheus"},
+			expectedClearPVCs: false,
+		},
+		{
+			name: "excluded namespace",
+			plan: &kotssnapshottypes.RestorePlan{
+				Spec: velerov1.RestoreSpec{
+					ExcludedNamespaces: []string{"monitoring"},
+				},
+			},
+			expectedNames:     []string{"Deployment/web", "Service/web", "CronJob/cleanup"},
+			expectedClearPVCs: false,
+		},
+		{
+			name: "resources",
+			plan: &kotssnapshottypes.RestorePlan{
+				Spec: velerov1.RestoreSpec{
+					IncludedResources: []string{"services", "configmaps"},
+				},
+			},
+			expectedNames:     []string{"Service/web", "ConfigMap/prometheus"},
+			expectedClearPVCs: false,
+		},
+		{
+			name: "resources with group",
+			plan: &kotssnapshottypes.RestorePlan{
+				Spec: velerov1.RestoreSpec{
+					ExcludedResources: []string{"deployments.apps", "cronjobs.batch", "pods"},
+				},
+			},
+			expectedNames:     []string{"Service/web", "StatefulSet/prometheus", "ConfigMap/prometheus"},
+			expectedClearPVCs: false,
+		},
+		{
+			name: "labels match workloads by their pods",
+			plan: &kotssnapshottypes.RestorePlan{
+				Spec: velerov1.RestoreSpec{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "prometheus"},
+					},
+				},
+				Volumes: []kotssnapshottypes.PodVolume{
+					{Namespace: "monitoring", Pod: "prometheus-0", Volume: "storage"},
+				},
+			},
+			expectedNames:     []string{"StatefulSet/prometheus", "ConfigMap/prometheus"},
+			expectedClearPVCs: true,
+		},
+		{
+			name: "pods of workloads",
+			plan: &kotssnapshottypes.RestorePlan{
+				Spec: velerov1.RestoreSpec{
+					IncludedResources: []string{"services", "pods"},
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "web"},
+					},
+				},
+			},
+			expectedNames:     []string{"Deployment/web", "Service/web", "CronJob/cleanup"},
+			expectedClearPVCs: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			undeploy, err := GetRestoreUndeploy([]byte(undeployManifests), backup, test.plan, "default")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := []string{}
+			for _, doc := range strings.Split(string(undeploy.Manifests), "\n---\n") {
+				if doc == "" {
+					continue
+				}
+				lines := strings.Split(doc, "\n")
+				kind := strings.TrimPrefix(lines[1], "kind: ")
+				name := strings.TrimPrefix(strings.TrimSpace(lines[3]), "name: ")
+				names = append(names, kind+"/"+name)
+			}
+
+			if !reflect.DeepEqual(names, test.expectedNames) {
+				t.Errorf("Expected manifests %v, got %v", test.expectedNames, names)
+			}
+			if !reflect.DeepEqual(undeploy.ClearNamespaces, test.expectedClearNamespaces) {
+				t.Errorf("Expected clear namespaces %v, got %v", test.expectedClearNamespaces, undeploy.ClearNamespaces)
+			}
+			if undeploy.ClearPVCs != test.expectedClearPVCs {
+				t.Errorf("Expected clear pvcs %v, got %v", test.expectedClearPVCs, undeploy.ClearPVCs)
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/appstatus"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/rand"
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	appstatustypes "github.com/replicatedhq/kots/pkg/api/appstatus/types"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	veleroclientv1 "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/typed/velero/v1"
+	"go.uber.org/zap"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	DefaultBackupVerificationTimeout = 15 * time.Minute
+
+	backupVerificationLabel   = "kots.io/backup-verification"
+	backupVerificationJobName = "kotsadm-backup-verification"
+)
+
+var statusInformerRegexp = regexp.MustCompile(`^(?:([^\/]+)\/)?([^\/]+)\/([^\/]+)$`)
+
+// BackupVerificationAppID is the id the operator reports the status of the restored resources under
+func BackupVerificationAppID(backupName string, appID string) string {
+	return fmt.Sprintf("backup-verification.%s.%s", backupName, appID)
+}
+
+// GetBackupAppSequence returns the sequence of the app that was deployed when the backup was taken
+func GetBackupAppSequence(backup *velerov1.Backup, appSlug string) (int64, error) {
+	backupAnnotations := backup.ObjectMeta.GetAnnotations()
+	if backupAnnotations == nil {
+		return 0, errors.New("backup is missing required annotations")
+	}
+
+	if backupAnnotations["kots.io/instance"] == "true" {
+		b, ok := backupAnnotations["kots.io/apps-sequences"]
+		if !ok || b == "" {
+			return 0, errors.New("instance backup is missing apps sequences annotation")
+		}
+
+		var appsSequences map[string]int64
+		if err := json.Unmarshal([]byte(b), &appsSequences); err != nil {
+			return 0, errors.Wrap(err, "failed to unmarshal apps sequences")
+		}
+
+		s, ok := appsSequences[appSlug]
+		if !ok {
+			return 0, errors.New("instance backup is missing sequence annotation")
+		}
+		return s, nil
+	}
+
+	sequenceStr, ok := backupAnnotations["kots.io/app-sequence"]
+	if !ok || sequenceStr == "" {
+		return 0, errors.New("backup is missing sequence annotation")
+	}
+
+	s, err := strconv.ParseInt(sequenceStr, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to parse sequence")
+	}
+	return s, nil
+}
+
+// CreateBackupVerification restores the app from the backup into scratch namespaces.
+// The socket service picks up the verification once the restore completes.
+func CreateBackupVerification(a *apptypes.App, backupName string, jobSpec *batchv1.JobSpec, timeout time.Duration) (*types.BackupVerification, error) {
+	logger.Debug("creating backup verification",
+		zap.String("backupName", backupName),
+		zap.String("appID", a.ID))
+
+	backup, err := GetBackup(backupName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get backup")
+	}
+
+	if backup.Status.Phase != velerov1.BackupPhaseCompleted {
+		return nil, errors.Errorf("backup %s is %s, only completed backups can be verified", backupName, backup.Status.Phase)
+	}
+
+	isInstanceBackup := backup.Annotations["kots.io/instance"] == "true"
+	if !isInstanceBackup && backup.Annotations["kots.io/app-id"] != a.ID {
+		return nil, errors.Errorf("backup %s does not belong to app %s", backupName, a.Slug)
+	}
+	if _, err := GetBackupAppSequence(backup, a.Slug); err != nil {
+		return nil, errors.Wrapf(err, "backup %s does not include app %s", backupName, a.Slug)
+	}
+
+	existing, err := store.GetStore().GetBackupVerification(backupName, a.ID)
+	if err != nil && !store.GetStore().IsNotFound(err) {
+		return nil, errors.Wrap(err, "failed to get existing verification")
+	}
+	if existing != nil && existing.Status != types.BackupVerificationStatusCompleted {
+		return nil, errors.Errorf("backup %s is already being verified", backupName)
+	}
+
+	if len(backup.Spec.IncludedNamespaces) == 0 {
+		return nil, errors.Errorf("backup %s does not list the namespaces it includes", backupName)
+	}
+
+	bsl, err := FindBackupStoreLocation()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get velero namespace")
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create clientset")
+	}
+
+	veleroClient, err := veleroclientv1.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create velero clientset")
+	}
+
+	if err := checkBackupEncryption(clientset, bsl.Namespace, backup); err != nil {
+		return nil, err
+	}
+
+	suffix := rand.StringWithCharset(5, rand.LOWER_CASE)
+	namespaceMapping := backupVerificationNamespaceMapping(backup.Spec.IncludedNamespaces, suffix)
+
+	now := time.Now()
+	verification := &types.BackupVerification{
+		BackupName:       backupName,
+		AppID:            a.ID,
+		Status:           types.BackupVerificationStatusRestoring,
+		RestoreName:      fmt.Sprintf("%s.verify-%s", backupName, suffix),
+		NamespaceMapping: namespaceMapping,
+		JobSpec:          jobSpec,
+		Timeout:          timeout,
+		ResourceStates:   []appstatustypes.ResourceState{},
+		StartedAt:        &now,
+	}
+	if verification.Timeout == 0 {
+		verification.Timeout = DefaultBackupVerificationTimeout
+	}
+
+	// the informers of a previous verification may have reported after it was cleaned up
+	if err := appstatus.Delete(BackupVerificationAppID(backupName, a.ID)); err != nil {
+		return nil, errors.Wrap(err, "failed to delete previous app status")
+	}
+
+	for _, scratchNamespace := range namespaceMapping {
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: scratchNamespace,
+				Labels: map[string]string{
+					backupVerificationLabel: "true",
+				},
+				Annotations: map[string]string{
+					"kots.io/backup-name": backupName,
+					"kots.io/app-id":      a.ID,
+				},
+			},
+		}
+		if _, err := clientset.CoreV1().Namespaces().Create(context.TODO(), namespace, metav1.CreateOptions{}); err != nil {
+			deleteBackupVerificationNamespaces(clientset, namespaceMapping)
+			return nil, errors.Wrapf(err, "failed to create namespace %s", scratchNamespace)
+		}
+	}
+
+	trueVal := true
+	falseVal := false
+	restore := &velerov1.Restore{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: bsl.Namespace,
+			Name:      verification.RestoreName,
+			Annotations: map[string]string{
+				"kots.io/backup-verification": "true",
+			},
+		},
+		Spec: velerov1.RestoreSpec{
+			BackupName: backupName,
+			RestorePVs: &trueVal,
+			// cluster scoped resources are shared with the running app
+			IncludeClusterResources: &falseVal,
+			NamespaceMapping:        namespaceMapping,
+		},
+	}
+	if isInstanceBackup {
+		restore.Spec.LabelSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"kots.io/app-slug": a.Slug,
+			},
+		}
+	}
+
+	if _, err := veleroClient.Restores(bsl.Namespace).Create(context.TODO(), restore, metav1.CreateOptions{}); err != nil {
+		deleteBackupVerificationNamespaces(clientset, namespaceMapping)
+		return nil, errors.Wrap(err, "failed to create restore")
+	}
+
+	if err := store.GetStore().CreateBackupVerification(verification); err != nil {
+		return nil, errors.Wrap(err, "failed to create backup verification")
+	}
+
+	return verification, nil
+}
+
+// backupVerificationNamespaceMapping maps each namespace in the backup to a scratch namespace
+func backupVerificationNamespaceMapping(namespaces []string, suffix string) map[string]string {
+	namespaceMapping := map[string]string{}
+	for _, namespace := range namespaces {
+		prefix := namespace
+		// namespace names are limited to 63 characters
+		if maxPrefix := 63 - len("-verify-") - len(suffix); len(prefix) > maxPrefix {
+			prefix = prefix[:maxPrefix]
+		}
+		namespaceMapping[namespace] = fmt.Sprintf("%s-verify-%s", prefix, suffix)
+	}
+	return namespaceMapping
+}
+
+// BackupVerificationStatusInformers points the app's rendered status informers at the scratch namespaces.
+// Informers for resources in namespaces that were not restored are dropped.
+func BackupVerificationStatusInformers(informers []string, verification *types.BackupVerification) []string {
+	return mapStatusInformers(informers, verification.NamespaceMapping, appNamespace())
+}
+
+func mapStatusInformers(informers []string, namespaceMapping map[string]string, appNamespace string) []string {
+	mapped := []string{}
+	for _, informer := range informers {
+		matches := statusInformerRegexp.FindStringSubmatch(informer)
+		if len(matches) != 4 {
+			continue
+		}
+		namespace := matches[1]
+		if namespace == "" {
+			namespace = appNamespace
+		}
+		scratchNamespace, ok := namespaceMapping[namespace]
+		if !ok {
+			continue
+		}
+		mapped = append(mapped, fmt.Sprintf("%s/%s/%s", scratchNamespace, matches[2], matches[3]))
+	}
+	return mapped
+}
+
+// BackupVerificationAppNamespace is the scratch namespace the app's own namespace was restored into
+func BackupVerificationAppNamespace(verification *types.BackupVerification) string {
+	return verification.NamespaceMapping[appNamespace()]
+}
+
+func appNamespace() string {
+	if os.Getenv("KOTSADM_TARGET_NAMESPACE") != "" {
+		return os.Getenv("KOTSADM_TARGET_NAMESPACE")
+	}
+	return os.Getenv("POD_NAMESPACE")
+}
+
+// StartBackupVerificationJob runs the verification job in the scratch namespace of the app
+func StartBackupVerificationJob(verification *types.BackupVerification) error {
+	namespace := BackupVerificationAppNamespace(verification)
+	if namespace == "" {
+		return errors.New("app namespace was not restored")
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create clientset")
+	}
+
+	jobSpec := verification.JobSpec.DeepCopy()
+	if jobSpec.Template.Spec.RestartPolicy == "" {
+		jobSpec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupVerificationJobName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				backupVerificationLabel: "true",
+			},
+		},
+		Spec: *jobSpec,
+	}
+
+	_, err = clientset.BatchV1().Jobs(namespace).Create(context.TODO(), job, metav1.CreateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to create job")
+	}
+
+	return nil
+}
+
+// GetBackupVerificationJobStatus returns whether the verification job is running, succeeded or failed
+func GetBackupVerificationJobStatus(verification *types.BackupVerification) (string, error) {
+	namespace := BackupVerificationAppNamespace(verification)
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create clientset")
+	}
+
+	job, err := clientset.BatchV1().Jobs(namespace).Get(context.TODO(), backupVerificationJobName, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get job")
+	}
+
+	return jobStatus(job), nil
+}
+
+func jobStatus(job *batchv1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		if condition.Type == batchv1.JobComplete {
+			return types.BackupVerificationJobSucceeded
+		}
+		if condition.Type == batchv1.JobFailed {
+			return types.BackupVerificationJobFailed
+		}
+	}
+	return types.BackupVerificationJobRunning
+}
+
+// EvaluateBackupVerification decides the outcome of a verification that started verifying at verifyingAt.
+// An empty outcome means the restored app has not passed or failed yet.
+func EvaluateBackupVerification(state appstatustypes.State, jobStatus string, verifyingAt time.Time, timeout time.Duration, now time.Time) (string, string) {
+	if jobStatus == types.BackupVerificationJobFailed {
+		return types.BackupVerificationOutcomeFailed, "verification job failed"
+	}
+
+	jobDone := jobStatus == "" || jobStatus == types.BackupVerificationJobSucceeded
+	if state == appstatustypes.StateReady && jobDone {
+		return types.BackupVerificationOutcomePassed, ""
+	}
+
+	if now.Before(verifyingAt.Add(timeout)) {
+		return "", ""
+	}
+
+	if state != appstatustypes.StateReady {
+		return types.BackupVerificationOutcomeFailed, fmt.Sprintf("app did not become ready within %s of restoring, state is %s", timeout, state)
+	}
+	return types.BackupVerificationOutcomeFailed, fmt.Sprintf("verification job did not complete within %s", timeout)
+}
+
+// CleanupBackupVerification deletes the scratch namespaces, the restore and the status reported for them
+func CleanupBackupVerification(verification *types.BackupVerification) error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create clientset")
+	}
+
+	for _, scratchNamespace := range verification.NamespaceMapping {
+		err := clientset.CoreV1().Namespaces().Delete(context.TODO(), scratchNamespace, metav1.DeleteOptions{})
+		if err != nil && !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete namespace %s", scratchNamespace)
+		}
+	}
+
+	if err := DeleteRestore(verification.RestoreName); err != nil {
+		return errors.Wrap(err, "failed to delete restore")
+	}
+
+	if err := appstatus.Delete(BackupVerificationAppID(verification.BackupName, verification.AppID)); err != nil {
+		return errors.Wrap(err, "failed to delete app status")
+	}
+
+	return nil
+}
+
+func deleteBackupVerificationNamespaces(clientset kubernetes.Interface, namespaceMapping map[string]string) {
+	for _, scratchNamespace := range namespaceMapping {
+		err := clientset.CoreV1().Namespaces().Delete(context.TODO(), scratchNamespace, metav1.DeleteOptions{})
+		if err != nil && !kuberneteserrors.IsNotFound(err) {
+			logger.Error(errors.Wrapf(err, "failed to delete namespace %s", scratchNamespace))
+		}
+	}
+}
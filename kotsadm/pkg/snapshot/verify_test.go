@@ -0,0 +1,199 @@
+package snapshot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	appstatustypes "github.com/replicatedhq/kots/pkg/api/appstatus/types"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBackupVerificationNamespaceMapping(t *testing.T) {
+	longNamespace := strings.Repeat("a", 63)
+	mapping := backupVerificationNamespaceMapping([]string{"default", "monitoring", longNamespace}, "abcde")
+
+	expected := map[string]string{
+		"default":     "default-verify-abcde",
+		"monitoring":  "monitoring-verify-abcde",
+		longNamespace: strings.Repeat("a", 50) + "-verify-abcde",
+	}
+	if !reflect.DeepEqual(mapping, expected) {
+		t.Errorf("Expected %v, got %v", expected, mapping)
+	}
+	if len(mapping[longNamespace]) != 63 {
+		t.Errorf("Expected namespace name to be truncated to 63 characters, got %d", len(mapping[longNamespace]))
+	}
+}
+
+func TestMapStatusInformers(t *testing.T) {
+	namespaceMapping := map[string]string{
+		"default":    "default-verify-abcde",
+		"monitoring": "monitoring-verify-abcde",
+	}
+	informers := []string{
+		"deployment/web",
+		"default/statefulset/postgres",
+		"monitoring/deployment/prometheus",
+		"kube-system/deployment/coredns",
+		"invalid",
+	}
+
+	mapped := mapStatusInformers(informers, namespaceMapping, "default")
+
+	expected := []string{
+		"default-verify-abcde/deployment/web",
+		"default-verify-abcde/statefulset/postgres",
+		"monitoring-verify-abcde/deployment/prometheus",
+	}
+	if !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("Expected %v, got %v", expected, mapped)
+	}
+}
+
+func TestEvaluateBackupVerification(t *testing.T) {
+	verifyingAt := time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)
+	timeout := 15 * time.Minute
+
+	duringTimeout := verifyingAt.Add(5 * time.Minute)
+	pastTimeout := verifyingAt.Add(20 * time.Minute)
+
+	tests := []struct {
+		name        string
+		state       appstatustypes.State
+		jobStatus   string
+		now         time.Time
+		wantOutcome string
+	}{
+		{
+			name:        "ready without job",
+			state:       appstatustypes.StateReady,
+			now:         duringTimeout,
+			wantOutcome: types.BackupVerificationOutcomePassed,
+		},
+		{
+			name:        "ready and job succeeded",
+			state:       appstatustypes.StateReady,
+			jobStatus:   types.BackupVerificationJobSucceeded,
+			now:         duringTimeout,
+			wantOutcome: types.BackupVerificationOutcomePassed,
+		},
+		{
+			name:      "ready and job running",
+			state:     appstatustypes.StateReady,
+			jobStatus: types.BackupVerificationJobRunning,
+			now:       duringTimeout,
+		},
+		{
+			name:        "job failed",
+			state:       appstatustypes.StateReady,
+			jobStatus:   types.BackupVerificationJobFailed,
+			now:         duringTimeout,
+			wantOutcome: types.BackupVerificationOutcomeFailed,
+		},
+		{
+			name:  "degraded, still waiting",
+			state: appstatustypes.StateDegraded,
+			now:   duringTimeout,
+		},
+		{
+			name:        "degraded past timeout",
+			state:       appstatustypes.StateDegraded,
+			now:         pastTimeout,
+			wantOutcome: types.BackupVerificationOutcomeFailed,
+		},
+		{
+			name:        "job running past timeout",
+			state:       appstatustypes.StateReady,
+			jobStatus:   types.BackupVerificationJobRunning,
+			now:         pastTimeout,
+			wantOutcome: types.BackupVerificationOutcomeFailed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outcome, message := EvaluateBackupVerification(test.state, test.jobStatus, verifyingAt, timeout, test.now)
+			if outcome != test.wantOutcome {
+				t.Errorf("Expected outcome %q, got %q (%s)", test.wantOutcome, outcome, message)
+			}
+			if outcome == types.BackupVerificationOutcomeFailed && message == "" {
+				t.Error("Expected a message for a failed verification")
+			}
+		})
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []batchv1.JobCondition
+		want       string
+	}{
+		{
+			name: "running",
+			want: types.BackupVerificationJobRunning,
+		},
+		{
+			name: "complete",
+			conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			},
+			want: types.BackupVerificationJobSucceeded,
+		},
+		{
+			name: "failed",
+			conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+			},
+			want: types.BackupVerificationJobFailed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			job := &batchv1.Job{Status: batchv1.JobStatus{Conditions: test.conditions}}
+			if got := jobStatus(job); got != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestGetBackupAppSequence(t *testing.T) {
+	appBackup := &velerov1.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"kots.io/app-sequence": "4"},
+		},
+	}
+	sequence, err := GetBackupAppSequence(appBackup, "my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sequence != 4 {
+		t.Errorf("Expected sequence 4, got %d", sequence)
+	}
+
+	instanceBackup := &velerov1.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"kots.io/instance":       "true",
+				"kots.io/apps-sequences": `{"my-app": 7}`,
+			},
+		},
+	}
+	sequence, err = GetBackupAppSequence(instanceBackup, "my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sequence != 7 {
+		t.Errorf("Expected sequence 7, got %d", sequence)
+	}
+
+	if _, err := GetBackupAppSequence(instanceBackup, "other-app"); err == nil {
+		t.Error("Expected error")
+	}
+}
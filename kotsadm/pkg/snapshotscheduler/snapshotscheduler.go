@@ -8,10 +8,12 @@ import (
 	"github.com/pkg/errors"
 	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow"
 	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot"
 	snapshottypes "github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
 	downstreamtypes "github.com/replicatedhq/kots/pkg/api/downstream/types"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 	"k8s.io/apimachinery/pkg/util/rand"
 
 	cron "github.com/robfig/cron/v3"
@@ -22,6 +24,8 @@ func Start() error {
 
 	startLoop(appScheduleLoop, 60)
 	startLoop(instanceScheduleLoop, 60)
+	startLoop(appRetentionLoop, 300)
+	startLoop(instanceRetentionLoop, 300)
 
 	return nil
 }
@@ -66,6 +70,57 @@ func instanceScheduleLoop() {
 	}
 }
 
+func appRetentionLoop() {
+	appsList, err := store.GetStore().ListInstalledApps()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to list installed apps for snapshot retention"))
+		return
+	}
+
+	for _, a := range appsList {
+		if a.RestoreInProgressName != "" || !a.SnapshotRetentionPolicy.IsEnabled() {
+			continue
+		}
+		backups, err := snapshot.ListBackupsForApp(a.ID)
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to list backups for app %s", a.ID))
+			continue
+		}
+		pruneBackups(backups, a.SnapshotRetentionPolicy)
+	}
+}
+
+func instanceRetentionLoop() {
+	clusters, err := store.GetStore().ListClusters()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to list clusters for instance snapshot retention"))
+		return
+	}
+
+	for _, c := range clusters {
+		if !c.SnapshotRetentionPolicy.IsEnabled() {
+			continue
+		}
+		backups, err := snapshot.ListInstanceBackups()
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to list instance backups for cluster %s", c.ClusterID))
+			continue
+		}
+		pruneBackups(backups, c.SnapshotRetentionPolicy)
+	}
+}
+
+// pruneBackups deletes the scheduled backups that are not kept by the retention policy
+func pruneBackups(backups []*snapshottypes.Backup, retentionPolicy *kotssnapshottypes.RetentionPolicy) {
+	for _, backup := range snapshot.ApplyRetentionPolicy(backups, retentionPolicy) {
+		if err := snapshot.DeleteBackup(backup.Name); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to delete backup %s", backup.Name))
+			continue
+		}
+		logger.Infof("Deleted backup %s that is not kept by the snapshot retention policy", backup.Name)
+	}
+}
+
 /* App Level Scheduled Snapshots */
 func handleApp(a *apptypes.App) error {
 	if a.SnapshotSchedule == "" {
@@ -110,6 +165,15 @@ func handleApp(a *apptypes.App) error {
 		return nil
 	}
 
+	isOpen, err := maintenancewindow.IsOpenForApp(a.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to check maintenance windows")
+	}
+	if !isOpen {
+		logger.Debugf("Postponing scheduled application snapshot for app %s until its maintenance window opens", a.ID)
+		return nil
+	}
+
 	hasUnfinished, err := snapshot.HasUnfinishedApplicationBackup(a.ID)
 	if err != nil {
 		return errors.Wrap(err, "failed to to check if app has unfinished backups")
@@ -193,6 +257,15 @@ func handleCluster(c *downstreamtypes.Downstream) error {
 		return nil
 	}
 
+	isOpen, err := maintenancewindow.IsOpenForCluster(c.ClusterID)
+	if err != nil {
+		return errors.Wrap(err, "failed to check maintenance windows")
+	}
+	if !isOpen {
+		logger.Debugf("Postponing scheduled instance snapshot for cluster %s until the maintenance windows of all its apps open", c.ClusterID)
+		return nil
+	}
+
 	hasUnfinished, err := snapshot.HasUnfinishedInstanceBackup()
 	if err != nil {
 		return errors.Wrap(err, "failed to to check if cluster has unfinished backups")
@@ -0,0 +1,264 @@
+package socketservice
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/appstatus"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/render"
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot"
+	snapshottypes "github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	appstatustypes "github.com/replicatedhq/kots/pkg/api/appstatus/types"
+	"github.com/replicatedhq/kots/pkg/kotsutil"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+func backupVerificationLoop() {
+	verifications, err := store.GetStore().ListBackupVerifications()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "failed to list backup verifications"))
+		return
+	}
+
+	for _, clusterSocket := range clusterSocketHistory {
+		apps, err := store.GetStore().ListAppsForDownstream(clusterSocket.ClusterID)
+		if err != nil {
+			logger.Error(errors.Wrap(err, "failed to list installed apps for downstream"))
+			continue
+		}
+
+		for _, a := range apps {
+			for _, verification := range verifications {
+				if verification.AppID != a.ID || verification.Status == snapshottypes.BackupVerificationStatusCompleted {
+					continue
+				}
+				v := verification
+				if err := processBackupVerification(clusterSocket, a, &v); err != nil {
+					logger.Error(errors.Wrapf(err, "failed to process verification of backup %s for app %s", v.BackupName, a.ID))
+					continue
+				}
+			}
+		}
+	}
+}
+
+// processBackupVerification moves a verification along: restoring -> verifying -> cleaning up -> completed
+func processBackupVerification(clusterSocket *ClusterSocket, a *apptypes.App, verification *snapshottypes.BackupVerification) error {
+	switch verification.Status {
+	case snapshottypes.BackupVerificationStatusRestoring:
+		return errors.Wrap(checkBackupVerificationRestore(clusterSocket, a, verification), "failed to check restore")
+
+	case snapshottypes.BackupVerificationStatusVerifying:
+		return errors.Wrap(checkBackupVerificationStatus(clusterSocket, verification), "failed to check status")
+
+	case snapshottypes.BackupVerificationStatusCleaningUp:
+		if err := snapshot.CleanupBackupVerification(verification); err != nil {
+			return errors.Wrap(err, "failed to clean up")
+		}
+		now := time.Now()
+		verification.Status = snapshottypes.BackupVerificationStatusCompleted
+		verification.FinishedAt = &now
+		if err := store.GetStore().UpdateBackupVerification(verification); err != nil {
+			return errors.Wrap(err, "failed to update backup verification")
+		}
+		logger.Infof("verification of backup %s for app %s %s", verification.BackupName, a.Slug, verification.Outcome)
+	}
+
+	return nil
+}
+
+func checkBackupVerificationRestore(clusterSocket *ClusterSocket, a *apptypes.App, verification *snapshottypes.BackupVerification) error {
+	restore, err := snapshot.GetRestore(verification.RestoreName)
+	if err != nil {
+		return errors.Wrap(err, "failed to get restore")
+	}
+	if restore == nil {
+		return failBackupVerification(clusterSocket, verification, "restore was deleted")
+	}
+
+	switch restore.Status.Phase {
+	case velerov1.RestorePhaseCompleted:
+		break
+
+	case velerov1.RestorePhaseFailed, velerov1.RestorePhasePartiallyFailed, velerov1.RestorePhaseFailedValidation:
+		return failBackupVerification(clusterSocket, verification, fmt.Sprintf("restore finished with phase %s", restore.Status.Phase))
+
+	default:
+		// restore is in progress
+		return nil
+	}
+
+	informers, err := renderBackupVerificationInformers(a, verification)
+	if err != nil {
+		return errors.Wrap(err, "failed to render status informers")
+	}
+
+	appID := snapshot.BackupVerificationAppID(verification.BackupName, verification.AppID)
+	if len(informers) > 0 {
+		c, err := server.GetChannel(clusterSocket.SocketID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get socket channel from server")
+		}
+		c.Emit("appInformers", AppInformersArgs{
+			AppID:        appID,
+			Informers:    informers,
+			Verification: true,
+		})
+	} else {
+		// no informers, set state to ready
+		defaultReadyState := []appstatustypes.ResourceState{
+			{
+				Kind:      "EMPTY",
+				Name:      "EMPTY",
+				Namespace: "EMPTY",
+				State:     appstatustypes.StateReady,
+			},
+		}
+		if err := appstatus.Set(appID, defaultReadyState, time.Now()); err != nil {
+			return errors.Wrap(err, "failed to set app status")
+		}
+	}
+
+	if verification.JobSpec != nil {
+		if err := snapshot.StartBackupVerificationJob(verification); err != nil {
+			return failBackupVerification(clusterSocket, verification, fmt.Sprintf("failed to start verification job: %s", errors.Cause(err)))
+		}
+		verification.JobStatus = snapshottypes.BackupVerificationJobRunning
+	}
+
+	now := time.Now()
+	verification.Status = snapshottypes.BackupVerificationStatusVerifying
+	verification.VerifyingAt = &now
+	if err := store.GetStore().UpdateBackupVerification(verification); err != nil {
+		return errors.Wrap(err, "failed to update backup verification")
+	}
+
+	return nil
+}
+
+func checkBackupVerificationStatus(clusterSocket *ClusterSocket, verification *snapshottypes.BackupVerification) error {
+	appStatus, err := store.GetStore().GetAppStatus(snapshot.BackupVerificationAppID(verification.BackupName, verification.AppID))
+	if err != nil {
+		return errors.Wrap(err, "failed to get app status")
+	}
+	verification.ResourceStates = appStatus.ResourceStates
+
+	if verification.JobSpec != nil {
+		jobStatus, err := snapshot.GetBackupVerificationJobStatus(verification)
+		if err != nil {
+			return errors.Wrap(err, "failed to get job status")
+		}
+		verification.JobStatus = jobStatus
+	}
+
+	verifyingAt := time.Now()
+	if verification.VerifyingAt != nil {
+		verifyingAt = *verification.VerifyingAt
+	}
+	outcome, message := snapshot.EvaluateBackupVerification(appStatus.State, verification.JobStatus, verifyingAt, verification.Timeout, time.Now())
+	if outcome == "" {
+		return errors.Wrap(store.GetStore().UpdateBackupVerification(verification), "failed to update backup verification")
+	}
+
+	if err := stopBackupVerificationInformers(clusterSocket, verification); err != nil {
+		return errors.Wrap(err, "failed to stop status informers")
+	}
+
+	verification.Status = snapshottypes.BackupVerificationStatusCleaningUp
+	verification.Outcome = outcome
+	verification.Message = message
+	if err := store.GetStore().UpdateBackupVerification(verification); err != nil {
+		return errors.Wrap(err, "failed to update backup verification")
+	}
+
+	return nil
+}
+
+func failBackupVerification(clusterSocket *ClusterSocket, verification *snapshottypes.BackupVerification, message string) error {
+	if err := stopBackupVerificationInformers(clusterSocket, verification); err != nil {
+		return errors.Wrap(err, "failed to stop status informers")
+	}
+
+	verification.Status = snapshottypes.BackupVerificationStatusCleaningUp
+	verification.Outcome = snapshottypes.BackupVerificationOutcomeFailed
+	verification.Message = message
+	if err := store.GetStore().UpdateBackupVerification(verification); err != nil {
+		return errors.Wrap(err, "failed to update backup verification")
+	}
+
+	return nil
+}
+
+// stopBackupVerificationInformers replaces the informers for the scratch namespaces with none
+func stopBackupVerificationInformers(clusterSocket *ClusterSocket, verification *snapshottypes.BackupVerification) error {
+	c, err := server.GetChannel(clusterSocket.SocketID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get socket channel from server")
+	}
+	c.Emit("appInformers", AppInformersArgs{
+		AppID:        snapshot.BackupVerificationAppID(verification.BackupName, verification.AppID),
+		Informers:    []string{},
+		Verification: true,
+	})
+	return nil
+}
+
+// renderBackupVerificationInformers renders the status informers of the version in the backup for the scratch namespaces
+func renderBackupVerificationInformers(a *apptypes.App, verification *snapshottypes.BackupVerification) ([]string, error) {
+	backup, err := snapshot.GetBackup(verification.BackupName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get backup")
+	}
+
+	sequence, err := snapshot.GetBackupAppSequence(backup, a.Slug)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get backup app sequence")
+	}
+
+	archiveDir, err := ioutil.TempDir("", "kotsadm")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(archiveDir)
+
+	err = store.GetStore().GetAppVersionArchive(a.ID, sequence, archiveDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get app version archive")
+	}
+
+	kotsKinds, err := kotsutil.LoadKotsKindsFromPath(archiveDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load kotskinds")
+	}
+
+	registrySettings, err := store.GetStore().GetRegistryDetailsForApp(a.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get registry settings for app")
+	}
+
+	builder, err := render.NewBuilder(kotsKinds, registrySettings, a.Slug, sequence, a.IsAirgap)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get template builder")
+	}
+
+	renderedInformers := []string{}
+	for _, informer := range kotsKinds.KotsApplication.Spec.StatusInformers {
+		renderedInformer, err := builder.String(informer)
+		if err != nil {
+			logger.Error(errors.Wrap(err, "failed to render status informer"))
+			continue
+		}
+		if renderedInformer == "" {
+			continue
+		}
+		renderedInformers = append(renderedInformers, renderedInformer)
+	}
+
+	return snapshot.BackupVerificationStatusInformers(renderedInformers, verification), nil
+}
@@ -0,0 +1,121 @@
+package socketservice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot"
+	downstreamtypes "github.com/replicatedhq/kots/pkg/api/downstream/types"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+// ensurePreUpgradeBackup snapshots the outgoing version of the app before deployedVersion replaces it.
+// Returns true once the snapshot has completed and the deploy can go ahead.
+// A failed snapshot fails the new version, deploying it again will take a new snapshot.
+// The state of the snapshot is kept with the downstream version, so that a restart of kotsadm or a reconnect
+// of the operator can't deploy the version before its snapshot completed.
+func ensurePreUpgradeBackup(clusterID string, a *apptypes.App, deployedVersion *downstreamtypes.DownstreamVersion) (bool, error) {
+	pending, err := downstream.GetPreUpgradeBackup(a.ID, clusterID, deployedVersion.Sequence)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get pre-upgrade backup")
+	}
+	if pending == nil || !isPreUpgradeBackupFor(pending, deployedVersion) {
+		return startPreUpgradeBackup(clusterID, a, deployedVersion)
+	}
+
+	switch pending.Status {
+	case downstreamtypes.PreUpgradeBackupCompleted:
+		return true, nil
+	case downstreamtypes.PreUpgradeBackupFailed:
+		return false, nil
+	}
+
+	backup, err := snapshot.GetBackup(pending.BackupName)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get backup")
+	}
+
+	switch backup.Status.Phase {
+	case velerov1.BackupPhaseCompleted:
+		logger.Infof("pre-upgrade snapshot %s completed, deploying sequence %d of app %s", pending.BackupName, deployedVersion.Sequence, a.Slug)
+		pending.Status = downstreamtypes.PreUpgradeBackupCompleted
+		if err := downstream.SetPreUpgradeBackup(a.ID, clusterID, deployedVersion.Sequence, *pending); err != nil {
+			return false, errors.Wrap(err, "failed to set pre-upgrade backup")
+		}
+		return true, nil
+
+	case velerov1.BackupPhaseFailed, velerov1.BackupPhasePartiallyFailed, velerov1.BackupPhaseFailedValidation:
+		pending.Status = downstreamtypes.PreUpgradeBackupFailed
+		if err := downstream.SetPreUpgradeBackup(a.ID, clusterID, deployedVersion.Sequence, *pending); err != nil {
+			return false, errors.Wrap(err, "failed to set pre-upgrade backup")
+		}
+		reason := fmt.Sprintf("pre-upgrade snapshot %s finished with phase %s", pending.BackupName, backup.Status.Phase)
+		if err := downstream.UpdateDownstreamStatus(a.ID, deployedVersion.Sequence, "failed", reason); err != nil {
+			return false, errors.Wrap(err, "failed to update downstream status")
+		}
+		return false, nil
+
+	default:
+		return false, nil
+	}
+}
+
+// startPreUpgradeBackup returns true if there is no outgoing version to snapshot
+func startPreUpgradeBackup(clusterID string, a *apptypes.App, deployedVersion *downstreamtypes.DownstreamVersion) (bool, error) {
+	// the current sequence already points at the new version, so the outgoing one is the one deployed before it
+	outgoingSequence, err := downstream.GetPreviouslyDeployedSequence(a.ID, clusterID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get previously deployed sequence")
+	}
+	if outgoingSequence == -1 {
+		return true, nil
+	}
+
+	outgoingParentSequence, err := downstream.GetParentSequenceForSequence(a.ID, clusterID, outgoingSequence)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get outgoing parent sequence")
+	}
+
+	pending := downstreamtypes.PreUpgradeBackup{
+		Status:     downstreamtypes.PreUpgradeBackupRunning,
+		DeployedAt: deployedVersion.DeployedAt,
+	}
+
+	backup, err := snapshot.CreatePreUpgradeBackup(context.TODO(), a, clusterID, outgoingParentSequence)
+	if err != nil {
+		pending.Status = downstreamtypes.PreUpgradeBackupFailed
+		if err := downstream.SetPreUpgradeBackup(a.ID, clusterID, deployedVersion.Sequence, pending); err != nil {
+			return false, errors.Wrap(err, "failed to set pre-upgrade backup")
+		}
+		reason := fmt.Sprintf("failed to create pre-upgrade snapshot: %s", err.Error())
+		if err := downstream.UpdateDownstreamStatus(a.ID, deployedVersion.Sequence, "failed", reason); err != nil {
+			return false, errors.Wrap(err, "failed to update downstream status")
+		}
+		return false, errors.Wrap(err, "failed to create pre-upgrade backup")
+	}
+
+	logger.Infof("created pre-upgrade snapshot %s of sequence %d for app %s", backup.Name, outgoingSequence, a.Slug)
+
+	pending.BackupName = backup.Name
+	if err := downstream.SetPreUpgradeBackup(a.ID, clusterID, deployedVersion.Sequence, pending); err != nil {
+		return false, errors.Wrap(err, "failed to set pre-upgrade backup")
+	}
+	if err := downstream.SetPreUpgradeSnapshotName(a.ID, clusterID, outgoingSequence, backup.Name); err != nil {
+		return false, errors.Wrap(err, "failed to record pre-upgrade snapshot name")
+	}
+
+	return false, nil
+}
+
+// isPreUpgradeBackupFor returns false if the version was deployed again after the backup was started
+func isPreUpgradeBackupFor(b *downstreamtypes.PreUpgradeBackup, deployedVersion *downstreamtypes.DownstreamVersion) bool {
+	if deployedVersion.DeployedAt == nil || b.DeployedAt == nil {
+		return deployedVersion.DeployedAt == nil && b.DeployedAt == nil
+	}
+	// deploying the same version again sets a new deployed at time
+	return b.DeployedAt.Equal(*deployedVersion.DeployedAt)
+}
@@ -2,13 +2,11 @@ package socketservice
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"sync"
 	"time"
 
@@ -16,8 +14,12 @@ import (
 	"github.com/replicatedhq/kots/kotsadm/pkg/app"
 	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/appstatus"
+	"github.com/replicatedhq/kots/kotsadm/pkg/autosupportbundle"
 	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow"
+	"github.com/replicatedhq/kots/kotsadm/pkg/preflight"
+	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/render"
 	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot"
 	"github.com/replicatedhq/kots/kotsadm/pkg/socket"
@@ -33,6 +35,7 @@ import (
 	identitytypes "github.com/replicatedhq/kots/pkg/identity/types"
 	"github.com/replicatedhq/kots/pkg/kotsutil"
 	"github.com/replicatedhq/kots/pkg/midstream"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 )
 
@@ -62,10 +65,21 @@ type DeployArgs struct {
 type AppInformersArgs struct {
 	AppID     string   `json:"app_id"`
 	Informers []string `json:"informers"`
+	// Verification is set for the informers of the scratch namespaces a backup is verified in
+	Verification bool `json:"verification"`
 }
 
 type SupportBundleArgs struct {
-	URI string `json:"uri"`
+	URI                string `json:"uri"`
+	BundleID           string `json:"bundleId"`
+	TimeoutSeconds     int    `json:"timeoutSeconds"`
+	GracePeriodSeconds int    `json:"gracePeriodSeconds"`
+	ProgressCallback   string `json:"progressCallback"`
+}
+
+type PreflightArgs struct {
+	URI               string `json:"uri"`
+	IgnorePermissions bool   `json:"ignorePermissions"`
 }
 
 var server *socket.Server
@@ -115,7 +129,10 @@ func Start() *socket.Server {
 
 	startLoop(deployLoop, 1)
 	startLoop(supportBundleLoop, 1)
+	startLoop(supportBundleTimeoutLoop, 60)
+	startLoop(preflightCheckLoop, 1)
 	startLoop(restoreLoop, 1)
+	startLoop(backupVerificationLoop, 5)
 
 	return server
 }
@@ -165,6 +182,48 @@ func processDeploySocketForApp(clusterSocket *ClusterSocket, a *apptypes.App) er
 		return nil
 	}
 
+	isUpgrade := false
+	if _, ok := clusterSocket.LastDeployedSequences[a.ID]; ok {
+		// a different version has been approved since the last deploy, hold it until the app's maintenance window opens.
+		// the first deploy after a (re)connect is not held because it also (re)starts the status informers.
+		// deploys from the admin console are rejected while the windows are closed, so these are automatic deploys.
+		if err := maintenancewindow.CheckOpenForApp(a.ID); err != nil {
+			closedErr, ok := errors.Cause(err).(maintenancewindow.ClosedError)
+			if !ok {
+				return errors.Wrap(err, "failed to check maintenance windows")
+			}
+			if deployedVersion.DeployHeldReason != closedErr.Error() {
+				if err := downstream.SetDownstreamDeployHeld(a.ID, clusterSocket.ClusterID, deployedVersion.Sequence, closedErr.Error()); err != nil {
+					return errors.Wrap(err, "failed to record held deploy")
+				}
+			}
+			return nil
+		}
+		if deployedVersion.DeployHeldReason != "" {
+			if err := downstream.SetDownstreamDeployHeld(a.ID, clusterSocket.ClusterID, deployedVersion.Sequence, ""); err != nil {
+				return errors.Wrap(err, "failed to clear held deploy")
+			}
+		}
+		isUpgrade = true
+	} else if a.PreUpgradeSnapshot != "" {
+		// after a restart or reconnect, a version that the operator never reported a result for has not been applied yet
+		hasOutput, err := downstream.HasDownstreamDeployOutput(a.ID, clusterSocket.ClusterID, deployedVersion.Sequence)
+		if err != nil {
+			return errors.Wrap(err, "failed to check downstream deploy output")
+		}
+		isUpgrade = !hasOutput
+	}
+
+	if isUpgrade && a.PreUpgradeSnapshot != "" {
+		isComplete, err := ensurePreUpgradeBackup(clusterSocket.ClusterID, a, deployedVersion)
+		if err != nil {
+			return errors.Wrap(err, "failed to ensure pre-upgrade snapshot")
+		}
+		if !isComplete {
+			return nil
+		}
+	}
+
 	d, err := store.GetStore().GetDownstream(clusterSocket.ClusterID)
 	if err != nil {
 		return errors.Wrap(err, "failed to get downstream")
@@ -177,6 +236,9 @@ func processDeploySocketForApp(clusterSocket *ClusterSocket, a *apptypes.App) er
 			if err != nil {
 				logger.Error(errors.Wrap(err, "failed to update downstream status"))
 			}
+			if err := autosupportbundle.DeployFailed(a.ID, clusterSocket.ClusterID, deployedVersion.Sequence); err != nil {
+				logger.Error(errors.Wrap(err, "failed to collect support bundle for failed deploy"))
+			}
 		}
 	}()
 
@@ -430,6 +492,11 @@ func processSupportBundle(clusterSocket *ClusterSocket, pendingSupportBundle sup
 		sequence = currentVersion.Sequence
 	}
 
+	// bundles collected because a sequence failed use the collectors of that sequence
+	if pendingSupportBundle.Sequence != nil {
+		sequence = *pendingSupportBundle.Sequence
+	}
+
 	archivePath, err := ioutil.TempDir("", "kotsadm")
 	if err != nil {
 		return errors.Wrap(err, "failed to create temp dir")
@@ -446,16 +513,33 @@ func processSupportBundle(clusterSocket *ClusterSocket, pendingSupportBundle sup
 		return errors.Wrap(err, "failed to load current kotskinds")
 	}
 
-	err = supportbundle.CreateRenderedSpec(a.ID, sequence, "", true, kotsKinds)
+	// the bundle is uploaded with the id of the pending bundle so that its progress can be tracked
+	err = supportbundle.CreateRenderedSpecForBundle(pendingSupportBundle.ID, a.ID, sequence, "", true, kotsKinds)
 	if err != nil {
 		return errors.Wrap(err, "failed to create rendered support bundle spec")
 	}
 
+	if err := supportbundle.StartCollection(pendingSupportBundle.ID, a.ID, clusterSocket.ClusterID); err != nil {
+		return errors.Wrap(err, "failed to start support bundle collection")
+	}
+
 	supportBundleArgs := SupportBundleArgs{
-		URI: supportbundle.GetSpecURI(a.Slug),
+		URI:                supportbundle.GetBundleSpecURI(a.Slug, pendingSupportBundle.ID),
+		BundleID:           pendingSupportBundle.ID,
+		TimeoutSeconds:     int(supportbundle.CollectionTimeout.Seconds()),
+		GracePeriodSeconds: int(supportbundle.CollectionKillGracePeriod.Seconds()),
+		ProgressCallback:   fmt.Sprintf("/api/v1/troubleshoot/supportbundle/%s/progress", pendingSupportBundle.ID),
 	}
 	c.Emit("supportbundle", supportBundleArgs)
 
+	if pendingSupportBundle.Sequence != nil {
+		// cleared when the bundle is uploaded
+		if err := supportbundle.MarkPendingStarted(pendingSupportBundle.ID); err != nil {
+			return errors.Wrap(err, "failed to mark pending support bundle started")
+		}
+		return nil
+	}
+
 	if err := supportbundle.ClearPending(pendingSupportBundle.ID); err != nil {
 		return errors.Wrap(err, "failed to clear pending support bundle")
 	}
@@ -463,6 +547,75 @@ func processSupportBundle(clusterSocket *ClusterSocket, pendingSupportBundle sup
 	return nil
 }
 
+// supportBundleTimeoutLoop clears the bundles that the operator started collecting but never uploaded
+func supportBundleTimeoutLoop() {
+	if err := supportbundle.ClearTimedOutCollections(time.Now()); err != nil {
+		logger.Error(errors.Wrap(err, "failed to clear timed out support bundle collections"))
+	}
+}
+
+func preflightCheckLoop() {
+	for _, clusterSocket := range clusterSocketHistory {
+		apps, err := store.GetStore().ListAppsForDownstream(clusterSocket.ClusterID)
+		if err != nil {
+			logger.Error(errors.Wrap(err, "failed to list apps for cluster"))
+			continue
+		}
+
+		for _, a := range apps {
+			pendingRuns, err := store.GetStore().ListPendingPreflightCheckRuns(a.ID)
+			if err != nil {
+				logger.Error(errors.Wrap(err, "failed to list pending preflight check runs for app"))
+				continue
+			}
+
+			for _, run := range pendingRuns {
+				if run.ClusterID != clusterSocket.ClusterID {
+					continue
+				}
+				if err := processPreflightCheckRun(clusterSocket, run); err != nil {
+					logger.Error(errors.Wrapf(err, "failed to process preflight check run %s for app %s", run.ID, run.AppID))
+					continue
+				}
+			}
+		}
+	}
+}
+
+func processPreflightCheckRun(clusterSocket *ClusterSocket, run *preflighttypes.CheckRun) error {
+	a, err := store.GetStore().GetApp(run.AppID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get app %s", run.AppID)
+	}
+
+	c, err := server.GetChannel(clusterSocket.SocketID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get socket channel from server")
+	}
+
+	ignoreRBAC, err := downstream.GetIgnoreRBACErrors(a.ID, run.Sequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to get ignore rbac flag")
+	}
+
+	if err := preflight.CreateRenderedSpecForCheckRun(run); err != nil {
+		return errors.Wrap(err, "failed to create rendered preflight spec")
+	}
+
+	preflightArgs := PreflightArgs{
+		URI:               preflight.GetCheckSpecURI(a.Slug),
+		IgnorePermissions: ignoreRBAC,
+	}
+	c.Emit("preflight", preflightArgs)
+
+	// completed when the results are uploaded
+	if err := store.GetStore().SetPreflightCheckRunStarted(run.ID); err != nil {
+		return errors.Wrap(err, "failed to mark preflight check run started")
+	}
+
+	return nil
+}
+
 func restoreLoop() {
 	for _, clusterSocket := range clusterSocketHistory {
 		apps, err := store.GetStore().ListAppsForDownstream(clusterSocket.ClusterID)
@@ -533,16 +686,16 @@ func handleUndeployCompleted(clusterSocket *ClusterSocket, a *apptypes.App) erro
 	}
 
 	if restore == nil {
-		return errors.Wrap(startVeleroRestore(snapshotName, a.Slug), "failed to start velero restore")
+		return errors.Wrap(startVeleroRestore(snapshotName, a.Slug, a.RestoreOptions), "failed to start velero restore")
 	}
 
 	return errors.Wrap(checkRestoreComplete(clusterSocket, a, restore), "failed to check restore complete")
 }
 
-func startVeleroRestore(snapshotName string, appSlug string) error {
+func startVeleroRestore(snapshotName string, appSlug string, restoreOptions *kotssnapshottypes.RestoreOptions) error {
 	logger.Info(fmt.Sprintf("creating velero restore object from snapshot %s", snapshotName))
 
-	if err := snapshot.CreateApplicationRestore(snapshotName, appSlug); err != nil {
+	if err := snapshot.CreateApplicationRestore(snapshotName, appSlug, restoreOptions); err != nil {
 		return errors.Wrap(err, "failed to create restore")
 	}
 
@@ -552,55 +705,41 @@ func startVeleroRestore(snapshotName string, appSlug string) error {
 func checkRestoreComplete(clusterSocket *ClusterSocket, a *apptypes.App, restore *velerov1.Restore) error {
 	switch restore.Status.Phase {
 	case velerov1.RestorePhaseCompleted:
-		backup, err := snapshot.GetBackup(restore.Spec.BackupName)
-		if err != nil {
-			return errors.Wrap(err, "failed to get backup")
-		}
-
-		backupAnnotations := backup.ObjectMeta.GetAnnotations()
-		if backupAnnotations == nil {
-			return errors.New("backup is missing required annotations")
-		}
-
-		var sequence int64 = 0
-		if backupAnnotations["kots.io/instance"] == "true" {
-			b, ok := backupAnnotations["kots.io/apps-sequences"]
-			if !ok || b == "" {
-				return errors.New("instance backup is missing apps sequences annotation")
-			}
-
-			var appsSequences map[string]int64
-			if err := json.Unmarshal([]byte(b), &appsSequences); err != nil {
-				return errors.Wrap(err, "failed to unmarshal apps sequences")
+		var sequence int64
+		if a.RestoreOptions.IsSelective() {
+			// a selective restore only replaced some of the resources, the rest of the app is still the deployed version.
+			// redeploying the version in the backup would roll back everything that was not restored.
+			currentSequence, err := downstream.GetCurrentParentSequence(a.ID, clusterSocket.ClusterID)
+			if err != nil {
+				return errors.Wrap(err, "failed to get current parent sequence")
 			}
+			sequence = currentSequence
 
-			s, ok := appsSequences[a.Slug]
-			if !ok {
-				return errors.New("instance backup is missing sequence annotation")
-			}
-			sequence = s
+			logger.Info(fmt.Sprintf("selective restore complete, keeping deployed version %d", sequence))
 		} else {
-			sequenceStr, ok := backupAnnotations["kots.io/app-sequence"]
-			if !ok || sequenceStr == "" {
-				return errors.New("backup is missing sequence annotation")
+			backup, err := snapshot.GetBackup(restore.Spec.BackupName)
+			if err != nil {
+				return errors.Wrap(err, "failed to get backup")
 			}
 
-			s, err := strconv.ParseInt(sequenceStr, 10, 64)
+			backupSequence, err := snapshot.GetBackupAppSequence(backup, a.Slug)
 			if err != nil {
-				return errors.Wrap(err, "failed to parse sequence")
+				return errors.Wrap(err, "failed to get backup app sequence")
 			}
-			sequence = s
-		}
+			sequence = backupSequence
 
-		logger.Info(fmt.Sprintf("restore complete, re-deploying version %d", sequence))
+			logger.Info(fmt.Sprintf("restore complete, re-deploying version %d", sequence))
 
-		if err := RedeployAppVersion(a.ID, sequence, clusterSocket); err != nil {
-			return errors.Wrap(err, "failed to redeploy app version")
+			if err := RedeployAppVersion(a.ID, sequence, clusterSocket); err != nil {
+				return errors.Wrap(err, "failed to redeploy app version")
+			}
 		}
 
-		if err := createSupportBundle(a.ID, sequence, "", true); err != nil {
-			// support bundle is not essential.  keep processing restore status
-			logger.Error(errors.Wrapf(err, "failed to create support bundle for sequence %d post restore", sequence))
+		if sequence >= 0 {
+			if err := createSupportBundle(a.ID, sequence, "", true); err != nil {
+				// support bundle is not essential.  keep processing restore status
+				logger.Error(errors.Wrapf(err, "failed to create support bundle for sequence %d post restore", sequence))
+			}
 		}
 
 		if err := app.ResetRestore(a.ID); err != nil {
@@ -679,13 +818,27 @@ func undeployApp(a *apptypes.App, d *downstreamtypes.Downstream, clusterSocket *
 		}
 		return errors.Wrap(err, "failed to run kustomize")
 	}
-	base64EncodedManifests := base64.StdEncoding.EncodeToString(renderedManifests)
 
 	backup, err := snapshot.GetBackup(a.RestoreInProgressName)
 	if err != nil {
 		return errors.Wrap(err, "failed to get backup")
 	}
 
+	// a selective restore only removes what it restores
+	var restorePlan *kotssnapshottypes.RestorePlan
+	if a.RestoreOptions.IsSelective() {
+		restorePlan, err = snapshot.PlanApplicationRestore(a.RestoreInProgressName, a.Slug, a.RestoreOptions)
+		if err != nil {
+			return errors.Wrap(err, "failed to plan restore")
+		}
+	}
+
+	undeploy, err := snapshot.GetRestoreUndeploy(renderedManifests, backup, restorePlan, os.Getenv("POD_NAMESPACE"))
+	if err != nil {
+		return errors.Wrap(err, "failed to get restore undeploy")
+	}
+	base64EncodedManifests := base64.StdEncoding.EncodeToString(undeploy.Manifests)
+
 	args := DeployArgs{
 		AppID:             a.ID,
 		AppSlug:           a.Slug,
@@ -695,8 +848,8 @@ func undeployApp(a *apptypes.App, d *downstreamtypes.Downstream, clusterSocket *
 		PreviousManifests: base64EncodedManifests,
 		ResultCallback:    "/api/v1/undeploy/result",
 		Wait:              true,
-		ClearNamespaces:   backup.Spec.IncludedNamespaces,
-		ClearPVCs:         true,
+		ClearNamespaces:   undeploy.ClearNamespaces,
+		ClearPVCs:         undeploy.ClearPVCs,
 	}
 
 	c, err := server.GetChannel(clusterSocket.SocketID)
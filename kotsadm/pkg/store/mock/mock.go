@@ -13,6 +13,7 @@ import (
 	types "github.com/replicatedhq/kots/kotsadm/pkg/airgap/types"
 	types0 "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
 	types3 "github.com/replicatedhq/kots/kotsadm/pkg/gitops/types"
+	types12 "github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow/types"
 	types4 "github.com/replicatedhq/kots/kotsadm/pkg/online/types"
 	types5 "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
 	types6 "github.com/replicatedhq/kots/kotsadm/pkg/registry/types"
@@ -25,6 +26,7 @@ import (
 	types2 "github.com/replicatedhq/kots/pkg/api/downstream/types"
 	types11 "github.com/replicatedhq/kots/pkg/api/version/types"
 	kotsutil "github.com/replicatedhq/kots/pkg/kotsutil"
+	types13 "github.com/replicatedhq/kots/pkg/snapshot/types"
 	redact "github.com/replicatedhq/troubleshoot/pkg/redact"
 )
 
@@ -166,6 +168,50 @@ func (mr *MockKOTSStoreMockRecorder) CreatePendingSupportBundle(bundleID, appID,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePendingSupportBundle", reflect.TypeOf((*MockKOTSStore)(nil).CreatePendingSupportBundle), bundleID, appID, clusterID)
 }
 
+// CreateTriggeredPendingSupportBundle mocks base method
+func (m *MockKOTSStore) CreateTriggeredPendingSupportBundle(bundleID, appID, clusterID string, sequence int64, reason string, since time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTriggeredPendingSupportBundle", bundleID, appID, clusterID, sequence, reason, since)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTriggeredPendingSupportBundle indicates an expected call of CreateTriggeredPendingSupportBundle
+func (mr *MockKOTSStoreMockRecorder) CreateTriggeredPendingSupportBundle(bundleID, appID, clusterID, sequence, reason, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTriggeredPendingSupportBundle", reflect.TypeOf((*MockKOTSStore)(nil).CreateTriggeredPendingSupportBundle), bundleID, appID, clusterID, sequence, reason, since)
+}
+
+// GetPendingSupportBundle mocks base method
+func (m *MockKOTSStore) GetPendingSupportBundle(bundleID string) (*types9.PendingSupportBundle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingSupportBundle", bundleID)
+	ret0, _ := ret[0].(*types9.PendingSupportBundle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingSupportBundle indicates an expected call of GetPendingSupportBundle
+func (mr *MockKOTSStoreMockRecorder) GetPendingSupportBundle(bundleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingSupportBundle", reflect.TypeOf((*MockKOTSStore)(nil).GetPendingSupportBundle), bundleID)
+}
+
+// SetSupportBundleTrigger mocks base method
+func (m *MockKOTSStore) SetSupportBundleTrigger(bundleID string, sequence int64, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundleTrigger", bundleID, sequence, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSupportBundleTrigger indicates an expected call of SetSupportBundleTrigger
+func (mr *MockKOTSStoreMockRecorder) SetSupportBundleTrigger(bundleID, sequence, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundleTrigger", reflect.TypeOf((*MockKOTSStore)(nil).SetSupportBundleTrigger), bundleID, sequence, reason)
+}
+
 // CreateSupportBundle mocks base method
 func (m *MockKOTSStore) CreateSupportBundle(bundleID, appID, archivePath string, marshalledTree []byte) (*types9.SupportBundle, error) {
 	m.ctrl.T.Helper()
@@ -269,6 +315,121 @@ func (mr *MockKOTSStoreMockRecorder) GetSupportBundleSpecForApp(id interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSupportBundleSpecForApp", reflect.TypeOf((*MockKOTSStore)(nil).GetSupportBundleSpecForApp), id)
 }
 
+// GetSupportBundleDestination mocks base method
+func (m *MockKOTSStore) GetSupportBundleDestination(appID string) (*types9.Destination, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSupportBundleDestination", appID)
+	ret0, _ := ret[0].(*types9.Destination)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSupportBundleDestination indicates an expected call of GetSupportBundleDestination
+func (mr *MockKOTSStoreMockRecorder) GetSupportBundleDestination(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSupportBundleDestination", reflect.TypeOf((*MockKOTSStore)(nil).GetSupportBundleDestination), appID)
+}
+
+// SetSupportBundleDestination mocks base method
+func (m *MockKOTSStore) SetSupportBundleDestination(appID string, destination *types9.Destination) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundleDestination", appID, destination)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSupportBundleDestination indicates an expected call of SetSupportBundleDestination
+func (mr *MockKOTSStoreMockRecorder) SetSupportBundleDestination(appID, destination interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundleDestination", reflect.TypeOf((*MockKOTSStore)(nil).SetSupportBundleDestination), appID, destination)
+}
+
+// SetSupportBundlePushStatus mocks base method
+func (m *MockKOTSStore) SetSupportBundlePushStatus(bundleID, status, pushError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundlePushStatus", bundleID, status, pushError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSupportBundlePushStatus indicates an expected call of SetSupportBundlePushStatus
+func (mr *MockKOTSStoreMockRecorder) SetSupportBundlePushStatus(bundleID, status, pushError interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundlePushStatus", reflect.TypeOf((*MockKOTSStore)(nil).SetSupportBundlePushStatus), bundleID, status, pushError)
+}
+
+// SetSupportBundlePartial mocks base method
+func (m *MockKOTSStore) SetSupportBundlePartial(bundleID string, failedCollectors []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundlePartial", bundleID, failedCollectors)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSupportBundlePartial indicates an expected call of SetSupportBundlePartial
+func (mr *MockKOTSStoreMockRecorder) SetSupportBundlePartial(bundleID, failedCollectors interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundlePartial", reflect.TypeOf((*MockKOTSStore)(nil).SetSupportBundlePartial), bundleID, failedCollectors)
+}
+
+// CreateSupportBundleCollection mocks base method
+func (m *MockKOTSStore) CreateSupportBundleCollection(collection *types9.Collection) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSupportBundleCollection", collection)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSupportBundleCollection indicates an expected call of CreateSupportBundleCollection
+func (mr *MockKOTSStoreMockRecorder) CreateSupportBundleCollection(collection interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSupportBundleCollection", reflect.TypeOf((*MockKOTSStore)(nil).CreateSupportBundleCollection), collection)
+}
+
+// GetSupportBundleCollection mocks base method
+func (m *MockKOTSStore) GetSupportBundleCollection(bundleID string) (*types9.Collection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSupportBundleCollection", bundleID)
+	ret0, _ := ret[0].(*types9.Collection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSupportBundleCollection indicates an expected call of GetSupportBundleCollection
+func (mr *MockKOTSStoreMockRecorder) GetSupportBundleCollection(bundleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSupportBundleCollection", reflect.TypeOf((*MockKOTSStore)(nil).GetSupportBundleCollection), bundleID)
+}
+
+// UpdateSupportBundleCollection mocks base method
+func (m *MockKOTSStore) UpdateSupportBundleCollection(collection *types9.Collection) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSupportBundleCollection", collection)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSupportBundleCollection indicates an expected call of UpdateSupportBundleCollection
+func (mr *MockKOTSStoreMockRecorder) UpdateSupportBundleCollection(collection interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSupportBundleCollection", reflect.TypeOf((*MockKOTSStore)(nil).UpdateSupportBundleCollection), collection)
+}
+
+// ListSupportBundleCollections mocks base method
+func (m *MockKOTSStore) ListSupportBundleCollections(appID string) ([]*types9.Collection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSupportBundleCollections", appID)
+	ret0, _ := ret[0].([]*types9.Collection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSupportBundleCollections indicates an expected call of ListSupportBundleCollections
+func (mr *MockKOTSStoreMockRecorder) ListSupportBundleCollections(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSupportBundleCollections", reflect.TypeOf((*MockKOTSStore)(nil).ListSupportBundleCollections), appID)
+}
+
 // SetPreflightResults mocks base method
 func (m *MockKOTSStore) SetPreflightResults(appID string, sequence int64, results []byte) error {
 	m.ctrl.T.Helper()
@@ -341,6 +502,165 @@ func (mr *MockKOTSStoreMockRecorder) SetIgnorePreflightPermissionErrors(appID, s
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetIgnorePreflightPermissionErrors", reflect.TypeOf((*MockKOTSStore)(nil).SetIgnorePreflightPermissionErrors), appID, sequence)
 }
 
+// SetPreflightStrictOverride mocks base method
+func (m *MockKOTSStore) SetPreflightStrictOverride(appID string, sequence int64, source, overrideBy string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreflightStrictOverride", appID, sequence, source, overrideBy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreflightStrictOverride indicates an expected call of SetPreflightStrictOverride
+func (mr *MockKOTSStoreMockRecorder) SetPreflightStrictOverride(appID, sequence, source, overrideBy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreflightStrictOverride", reflect.TypeOf((*MockKOTSStore)(nil).SetPreflightStrictOverride), appID, sequence, source, overrideBy)
+}
+
+// CreatePreflightCheckRun mocks base method
+func (m *MockKOTSStore) CreatePreflightCheckRun(id, appID, clusterID string, sequence int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePreflightCheckRun", id, appID, clusterID, sequence)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePreflightCheckRun indicates an expected call of CreatePreflightCheckRun
+func (mr *MockKOTSStoreMockRecorder) CreatePreflightCheckRun(id, appID, clusterID, sequence interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePreflightCheckRun", reflect.TypeOf((*MockKOTSStore)(nil).CreatePreflightCheckRun), id, appID, clusterID, sequence)
+}
+
+// ListPendingPreflightCheckRuns mocks base method
+func (m *MockKOTSStore) ListPendingPreflightCheckRuns(appID string) ([]*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingPreflightCheckRuns", appID)
+	ret0, _ := ret[0].([]*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingPreflightCheckRuns indicates an expected call of ListPendingPreflightCheckRuns
+func (mr *MockKOTSStoreMockRecorder) ListPendingPreflightCheckRuns(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingPreflightCheckRuns", reflect.TypeOf((*MockKOTSStore)(nil).ListPendingPreflightCheckRuns), appID)
+}
+
+// ListRunningPreflightCheckRuns mocks base method
+func (m *MockKOTSStore) ListRunningPreflightCheckRuns(appID string) ([]*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRunningPreflightCheckRuns", appID)
+	ret0, _ := ret[0].([]*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRunningPreflightCheckRuns indicates an expected call of ListRunningPreflightCheckRuns
+func (mr *MockKOTSStoreMockRecorder) ListRunningPreflightCheckRuns(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRunningPreflightCheckRuns", reflect.TypeOf((*MockKOTSStore)(nil).ListRunningPreflightCheckRuns), appID)
+}
+
+// SetPreflightCheckRunStarted mocks base method
+func (m *MockKOTSStore) SetPreflightCheckRunStarted(id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreflightCheckRunStarted", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreflightCheckRunStarted indicates an expected call of SetPreflightCheckRunStarted
+func (mr *MockKOTSStoreMockRecorder) SetPreflightCheckRunStarted(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreflightCheckRunStarted", reflect.TypeOf((*MockKOTSStore)(nil).SetPreflightCheckRunStarted), id)
+}
+
+// FailStalePreflightCheckRuns mocks base method
+func (m *MockKOTSStore) FailStalePreflightCheckRuns(appID string, startedBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailStalePreflightCheckRuns", appID, startedBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailStalePreflightCheckRuns indicates an expected call of FailStalePreflightCheckRuns
+func (mr *MockKOTSStoreMockRecorder) FailStalePreflightCheckRuns(appID, startedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailStalePreflightCheckRuns", reflect.TypeOf((*MockKOTSStore)(nil).FailStalePreflightCheckRuns), appID, startedBefore)
+}
+
+// GetPreflightCheckRun mocks base method
+func (m *MockKOTSStore) GetPreflightCheckRun(id string) (*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPreflightCheckRun", id)
+	ret0, _ := ret[0].(*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPreflightCheckRun indicates an expected call of GetPreflightCheckRun
+func (mr *MockKOTSStoreMockRecorder) GetPreflightCheckRun(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPreflightCheckRun", reflect.TypeOf((*MockKOTSStore)(nil).GetPreflightCheckRun), id)
+}
+
+// GetLatestPreflightCheckRun mocks base method
+func (m *MockKOTSStore) GetLatestPreflightCheckRun(appID, clusterID string) (*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestPreflightCheckRun", appID, clusterID)
+	ret0, _ := ret[0].(*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestPreflightCheckRun indicates an expected call of GetLatestPreflightCheckRun
+func (mr *MockKOTSStoreMockRecorder) GetLatestPreflightCheckRun(appID, clusterID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestPreflightCheckRun", reflect.TypeOf((*MockKOTSStore)(nil).GetLatestPreflightCheckRun), appID, clusterID)
+}
+
+// SetPreflightCheckRunResults mocks base method
+func (m *MockKOTSStore) SetPreflightCheckRunResults(id string, result []byte, state string, changes []types5.CheckChange) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreflightCheckRunResults", id, result, state, changes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreflightCheckRunResults indicates an expected call of SetPreflightCheckRunResults
+func (mr *MockKOTSStoreMockRecorder) SetPreflightCheckRunResults(id, result, state, changes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreflightCheckRunResults", reflect.TypeOf((*MockKOTSStore)(nil).SetPreflightCheckRunResults), id, result, state, changes)
+}
+
+// ListPreflightCheckRuns mocks base method
+func (m *MockKOTSStore) ListPreflightCheckRuns(appID string) ([]*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPreflightCheckRuns", appID)
+	ret0, _ := ret[0].([]*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPreflightCheckRuns indicates an expected call of ListPreflightCheckRuns
+func (mr *MockKOTSStoreMockRecorder) ListPreflightCheckRuns(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPreflightCheckRuns", reflect.TypeOf((*MockKOTSStore)(nil).ListPreflightCheckRuns), appID)
+}
+
+// PrunePreflightCheckRuns mocks base method
+func (m *MockKOTSStore) PrunePreflightCheckRuns(appID string, keep int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrunePreflightCheckRuns", appID, keep)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PrunePreflightCheckRuns indicates an expected call of PrunePreflightCheckRuns
+func (mr *MockKOTSStoreMockRecorder) PrunePreflightCheckRuns(appID, keep interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrunePreflightCheckRuns", reflect.TypeOf((*MockKOTSStore)(nil).PrunePreflightCheckRuns), appID, keep)
+}
+
 // GetPrometheusAddress mocks base method
 func (m *MockKOTSStore) GetPrometheusAddress() (string, error) {
 	m.ctrl.T.Helper()
@@ -765,6 +1085,62 @@ func (mr *MockKOTSStoreMockRecorder) SetSnapshotSchedule(appID, snapshotSchedule
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSnapshotSchedule", reflect.TypeOf((*MockKOTSStore)(nil).SetSnapshotSchedule), appID, snapshotSchedule)
 }
 
+// SetSnapshotRetentionPolicy mocks base method
+func (m *MockKOTSStore) SetSnapshotRetentionPolicy(appID string, retentionPolicy *types13.RetentionPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSnapshotRetentionPolicy", appID, retentionPolicy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSnapshotRetentionPolicy indicates an expected call of SetSnapshotRetentionPolicy
+func (mr *MockKOTSStoreMockRecorder) SetSnapshotRetentionPolicy(appID, retentionPolicy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSnapshotRetentionPolicy", reflect.TypeOf((*MockKOTSStore)(nil).SetSnapshotRetentionPolicy), appID, retentionPolicy)
+}
+
+// SetSupportBundlePolicy mocks base method
+func (m *MockKOTSStore) SetSupportBundlePolicy(appID string, policy *types9.AutoCollectPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundlePolicy", appID, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSupportBundlePolicy indicates an expected call of SetSupportBundlePolicy
+func (mr *MockKOTSStoreMockRecorder) SetSupportBundlePolicy(appID, policy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundlePolicy", reflect.TypeOf((*MockKOTSStore)(nil).SetSupportBundlePolicy), appID, policy)
+}
+
+// SetPreflightCheckPolicy mocks base method
+func (m *MockKOTSStore) SetPreflightCheckPolicy(appID string, policy *types5.CheckPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreflightCheckPolicy", appID, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreflightCheckPolicy indicates an expected call of SetPreflightCheckPolicy
+func (mr *MockKOTSStoreMockRecorder) SetPreflightCheckPolicy(appID, policy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreflightCheckPolicy", reflect.TypeOf((*MockKOTSStore)(nil).SetPreflightCheckPolicy), appID, policy)
+}
+
+// SetPreUpgradeSnapshot mocks base method
+func (m *MockKOTSStore) SetPreUpgradeSnapshot(appID, preUpgradeSnapshot string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreUpgradeSnapshot", appID, preUpgradeSnapshot)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreUpgradeSnapshot indicates an expected call of SetPreUpgradeSnapshot
+func (mr *MockKOTSStoreMockRecorder) SetPreUpgradeSnapshot(appID, preUpgradeSnapshot interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreUpgradeSnapshot", reflect.TypeOf((*MockKOTSStore)(nil).SetPreUpgradeSnapshot), appID, preUpgradeSnapshot)
+}
+
 // RemoveApp mocks base method
 func (m *MockKOTSStore) RemoveApp(appID string) error {
 	m.ctrl.T.Helper()
@@ -1030,6 +1406,20 @@ func (mr *MockKOTSStoreMockRecorder) SetInstanceSnapshotSchedule(clusterID, snap
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInstanceSnapshotSchedule", reflect.TypeOf((*MockKOTSStore)(nil).SetInstanceSnapshotSchedule), clusterID, snapshotSchedule)
 }
 
+// SetInstanceSnapshotRetentionPolicy mocks base method
+func (m *MockKOTSStore) SetInstanceSnapshotRetentionPolicy(clusterID string, retentionPolicy *types13.RetentionPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetInstanceSnapshotRetentionPolicy", clusterID, retentionPolicy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetInstanceSnapshotRetentionPolicy indicates an expected call of SetInstanceSnapshotRetentionPolicy
+func (mr *MockKOTSStoreMockRecorder) SetInstanceSnapshotRetentionPolicy(clusterID, retentionPolicy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInstanceSnapshotRetentionPolicy", reflect.TypeOf((*MockKOTSStore)(nil).SetInstanceSnapshotRetentionPolicy), clusterID, retentionPolicy)
+}
+
 // ListPendingScheduledSnapshots mocks base method
 func (m *MockKOTSStore) ListPendingScheduledSnapshots(appID string) ([]types8.ScheduledSnapshot, error) {
 	m.ctrl.T.Helper()
@@ -1144,6 +1534,64 @@ func (mr *MockKOTSStoreMockRecorder) CreateScheduledInstanceSnapshot(snapshotID,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScheduledInstanceSnapshot", reflect.TypeOf((*MockKOTSStore)(nil).CreateScheduledInstanceSnapshot), snapshotID, clusterID, timestamp)
 }
 
+// CreateBackupVerification mocks base method
+func (m *MockKOTSStore) CreateBackupVerification(verification *types8.BackupVerification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBackupVerification", verification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateBackupVerification indicates an expected call of CreateBackupVerification
+func (mr *MockKOTSStoreMockRecorder) CreateBackupVerification(verification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBackupVerification", reflect.TypeOf((*MockKOTSStore)(nil).CreateBackupVerification), verification)
+}
+
+// UpdateBackupVerification mocks base method
+func (m *MockKOTSStore) UpdateBackupVerification(verification *types8.BackupVerification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBackupVerification", verification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateBackupVerification indicates an expected call of UpdateBackupVerification
+func (mr *MockKOTSStoreMockRecorder) UpdateBackupVerification(verification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBackupVerification", reflect.TypeOf((*MockKOTSStore)(nil).UpdateBackupVerification), verification)
+}
+
+// GetBackupVerification mocks base method
+func (m *MockKOTSStore) GetBackupVerification(backupName, appID string) (*types8.BackupVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBackupVerification", backupName, appID)
+	ret0, _ := ret[0].(*types8.BackupVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBackupVerification indicates an expected call of GetBackupVerification
+func (mr *MockKOTSStoreMockRecorder) GetBackupVerification(backupName, appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBackupVerification", reflect.TypeOf((*MockKOTSStore)(nil).GetBackupVerification), backupName, appID)
+}
+
+// ListBackupVerifications mocks base method
+func (m *MockKOTSStore) ListBackupVerifications() ([]types8.BackupVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBackupVerifications")
+	ret0, _ := ret[0].([]types8.BackupVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBackupVerifications indicates an expected call of ListBackupVerifications
+func (mr *MockKOTSStoreMockRecorder) ListBackupVerifications() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBackupVerifications", reflect.TypeOf((*MockKOTSStore)(nil).ListBackupVerifications))
+}
+
 // GetPendingInstallationStatus mocks base method
 func (m *MockKOTSStore) GetPendingInstallationStatus() (*types4.InstallStatus, error) {
 	m.ctrl.T.Helper()
@@ -1159,6 +1607,35 @@ func (mr *MockKOTSStoreMockRecorder) GetPendingInstallationStatus() *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingInstallationStatus", reflect.TypeOf((*MockKOTSStore)(nil).GetPendingInstallationStatus))
 }
 
+// GetMaintenanceWindows mocks base method
+func (m *MockKOTSStore) GetMaintenanceWindows(appID string) (*types12.MaintenanceWindows, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMaintenanceWindows", appID)
+	ret0, _ := ret[0].(*types12.MaintenanceWindows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMaintenanceWindows indicates an expected call of GetMaintenanceWindows
+func (mr *MockKOTSStoreMockRecorder) GetMaintenanceWindows(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMaintenanceWindows", reflect.TypeOf((*MockKOTSStore)(nil).GetMaintenanceWindows), appID)
+}
+
+// SetMaintenanceWindows mocks base method
+func (m *MockKOTSStore) SetMaintenanceWindows(appID string, maintenanceWindows *types12.MaintenanceWindows) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMaintenanceWindows", appID, maintenanceWindows)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMaintenanceWindows indicates an expected call of SetMaintenanceWindows
+func (mr *MockKOTSStoreMockRecorder) SetMaintenanceWindows(appID, maintenanceWindows interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMaintenanceWindows", reflect.TypeOf((*MockKOTSStore)(nil).SetMaintenanceWindows), appID, maintenanceWindows)
+}
+
 // Init mocks base method
 func (m *MockKOTSStore) Init() error {
 	m.ctrl.T.Helper()
@@ -1374,15 +1851,59 @@ func (mr *MockSupportBundleStoreMockRecorder) GetSupportBundle(bundleID interfac
 // CreatePendingSupportBundle mocks base method
 func (m *MockSupportBundleStore) CreatePendingSupportBundle(bundleID, appID, clusterID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreatePendingSupportBundle", bundleID, appID, clusterID)
+	ret := m.ctrl.Call(m, "CreatePendingSupportBundle", bundleID, appID, clusterID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePendingSupportBundle indicates an expected call of CreatePendingSupportBundle
+func (mr *MockSupportBundleStoreMockRecorder) CreatePendingSupportBundle(bundleID, appID, clusterID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePendingSupportBundle", reflect.TypeOf((*MockSupportBundleStore)(nil).CreatePendingSupportBundle), bundleID, appID, clusterID)
+}
+
+// CreateTriggeredPendingSupportBundle mocks base method
+func (m *MockSupportBundleStore) CreateTriggeredPendingSupportBundle(bundleID, appID, clusterID string, sequence int64, reason string, since time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTriggeredPendingSupportBundle", bundleID, appID, clusterID, sequence, reason, since)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTriggeredPendingSupportBundle indicates an expected call of CreateTriggeredPendingSupportBundle
+func (mr *MockSupportBundleStoreMockRecorder) CreateTriggeredPendingSupportBundle(bundleID, appID, clusterID, sequence, reason, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTriggeredPendingSupportBundle", reflect.TypeOf((*MockSupportBundleStore)(nil).CreateTriggeredPendingSupportBundle), bundleID, appID, clusterID, sequence, reason, since)
+}
+
+// GetPendingSupportBundle mocks base method
+func (m *MockSupportBundleStore) GetPendingSupportBundle(bundleID string) (*types9.PendingSupportBundle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingSupportBundle", bundleID)
+	ret0, _ := ret[0].(*types9.PendingSupportBundle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingSupportBundle indicates an expected call of GetPendingSupportBundle
+func (mr *MockSupportBundleStoreMockRecorder) GetPendingSupportBundle(bundleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingSupportBundle", reflect.TypeOf((*MockSupportBundleStore)(nil).GetPendingSupportBundle), bundleID)
+}
+
+// SetSupportBundleTrigger mocks base method
+func (m *MockSupportBundleStore) SetSupportBundleTrigger(bundleID string, sequence int64, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundleTrigger", bundleID, sequence, reason)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// CreatePendingSupportBundle indicates an expected call of CreatePendingSupportBundle
-func (mr *MockSupportBundleStoreMockRecorder) CreatePendingSupportBundle(bundleID, appID, clusterID interface{}) *gomock.Call {
+// SetSupportBundleTrigger indicates an expected call of SetSupportBundleTrigger
+func (mr *MockSupportBundleStoreMockRecorder) SetSupportBundleTrigger(bundleID, sequence, reason interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePendingSupportBundle", reflect.TypeOf((*MockSupportBundleStore)(nil).CreatePendingSupportBundle), bundleID, appID, clusterID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundleTrigger", reflect.TypeOf((*MockSupportBundleStore)(nil).SetSupportBundleTrigger), bundleID, sequence, reason)
 }
 
 // CreateSupportBundle mocks base method
@@ -1488,6 +2009,121 @@ func (mr *MockSupportBundleStoreMockRecorder) GetSupportBundleSpecForApp(id inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSupportBundleSpecForApp", reflect.TypeOf((*MockSupportBundleStore)(nil).GetSupportBundleSpecForApp), id)
 }
 
+// GetSupportBundleDestination mocks base method
+func (m *MockSupportBundleStore) GetSupportBundleDestination(appID string) (*types9.Destination, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSupportBundleDestination", appID)
+	ret0, _ := ret[0].(*types9.Destination)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSupportBundleDestination indicates an expected call of GetSupportBundleDestination
+func (mr *MockSupportBundleStoreMockRecorder) GetSupportBundleDestination(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSupportBundleDestination", reflect.TypeOf((*MockSupportBundleStore)(nil).GetSupportBundleDestination), appID)
+}
+
+// SetSupportBundleDestination mocks base method
+func (m *MockSupportBundleStore) SetSupportBundleDestination(appID string, destination *types9.Destination) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundleDestination", appID, destination)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSupportBundleDestination indicates an expected call of SetSupportBundleDestination
+func (mr *MockSupportBundleStoreMockRecorder) SetSupportBundleDestination(appID, destination interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundleDestination", reflect.TypeOf((*MockSupportBundleStore)(nil).SetSupportBundleDestination), appID, destination)
+}
+
+// SetSupportBundlePushStatus mocks base method
+func (m *MockSupportBundleStore) SetSupportBundlePushStatus(bundleID, status, pushError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundlePushStatus", bundleID, status, pushError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSupportBundlePushStatus indicates an expected call of SetSupportBundlePushStatus
+func (mr *MockSupportBundleStoreMockRecorder) SetSupportBundlePushStatus(bundleID, status, pushError interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundlePushStatus", reflect.TypeOf((*MockSupportBundleStore)(nil).SetSupportBundlePushStatus), bundleID, status, pushError)
+}
+
+// SetSupportBundlePartial mocks base method
+func (m *MockSupportBundleStore) SetSupportBundlePartial(bundleID string, failedCollectors []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundlePartial", bundleID, failedCollectors)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSupportBundlePartial indicates an expected call of SetSupportBundlePartial
+func (mr *MockSupportBundleStoreMockRecorder) SetSupportBundlePartial(bundleID, failedCollectors interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundlePartial", reflect.TypeOf((*MockSupportBundleStore)(nil).SetSupportBundlePartial), bundleID, failedCollectors)
+}
+
+// CreateSupportBundleCollection mocks base method
+func (m *MockSupportBundleStore) CreateSupportBundleCollection(collection *types9.Collection) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSupportBundleCollection", collection)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSupportBundleCollection indicates an expected call of CreateSupportBundleCollection
+func (mr *MockSupportBundleStoreMockRecorder) CreateSupportBundleCollection(collection interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSupportBundleCollection", reflect.TypeOf((*MockSupportBundleStore)(nil).CreateSupportBundleCollection), collection)
+}
+
+// GetSupportBundleCollection mocks base method
+func (m *MockSupportBundleStore) GetSupportBundleCollection(bundleID string) (*types9.Collection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSupportBundleCollection", bundleID)
+	ret0, _ := ret[0].(*types9.Collection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSupportBundleCollection indicates an expected call of GetSupportBundleCollection
+func (mr *MockSupportBundleStoreMockRecorder) GetSupportBundleCollection(bundleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSupportBundleCollection", reflect.TypeOf((*MockSupportBundleStore)(nil).GetSupportBundleCollection), bundleID)
+}
+
+// UpdateSupportBundleCollection mocks base method
+func (m *MockSupportBundleStore) UpdateSupportBundleCollection(collection *types9.Collection) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSupportBundleCollection", collection)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSupportBundleCollection indicates an expected call of UpdateSupportBundleCollection
+func (mr *MockSupportBundleStoreMockRecorder) UpdateSupportBundleCollection(collection interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSupportBundleCollection", reflect.TypeOf((*MockSupportBundleStore)(nil).UpdateSupportBundleCollection), collection)
+}
+
+// ListSupportBundleCollections mocks base method
+func (m *MockSupportBundleStore) ListSupportBundleCollections(appID string) ([]*types9.Collection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSupportBundleCollections", appID)
+	ret0, _ := ret[0].([]*types9.Collection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSupportBundleCollections indicates an expected call of ListSupportBundleCollections
+func (mr *MockSupportBundleStoreMockRecorder) ListSupportBundleCollections(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSupportBundleCollections", reflect.TypeOf((*MockSupportBundleStore)(nil).ListSupportBundleCollections), appID)
+}
+
 // MockPreflightStore is a mock of PreflightStore interface
 type MockPreflightStore struct {
 	ctrl     *gomock.Controller
@@ -1583,6 +2219,165 @@ func (mr *MockPreflightStoreMockRecorder) SetIgnorePreflightPermissionErrors(app
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetIgnorePreflightPermissionErrors", reflect.TypeOf((*MockPreflightStore)(nil).SetIgnorePreflightPermissionErrors), appID, sequence)
 }
 
+// SetPreflightStrictOverride mocks base method
+func (m *MockPreflightStore) SetPreflightStrictOverride(appID string, sequence int64, source, overrideBy string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreflightStrictOverride", appID, sequence, source, overrideBy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreflightStrictOverride indicates an expected call of SetPreflightStrictOverride
+func (mr *MockPreflightStoreMockRecorder) SetPreflightStrictOverride(appID, sequence, source, overrideBy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreflightStrictOverride", reflect.TypeOf((*MockPreflightStore)(nil).SetPreflightStrictOverride), appID, sequence, source, overrideBy)
+}
+
+// CreatePreflightCheckRun mocks base method
+func (m *MockPreflightStore) CreatePreflightCheckRun(id, appID, clusterID string, sequence int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePreflightCheckRun", id, appID, clusterID, sequence)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePreflightCheckRun indicates an expected call of CreatePreflightCheckRun
+func (mr *MockPreflightStoreMockRecorder) CreatePreflightCheckRun(id, appID, clusterID, sequence interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePreflightCheckRun", reflect.TypeOf((*MockPreflightStore)(nil).CreatePreflightCheckRun), id, appID, clusterID, sequence)
+}
+
+// ListPendingPreflightCheckRuns mocks base method
+func (m *MockPreflightStore) ListPendingPreflightCheckRuns(appID string) ([]*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingPreflightCheckRuns", appID)
+	ret0, _ := ret[0].([]*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingPreflightCheckRuns indicates an expected call of ListPendingPreflightCheckRuns
+func (mr *MockPreflightStoreMockRecorder) ListPendingPreflightCheckRuns(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingPreflightCheckRuns", reflect.TypeOf((*MockPreflightStore)(nil).ListPendingPreflightCheckRuns), appID)
+}
+
+// ListRunningPreflightCheckRuns mocks base method
+func (m *MockPreflightStore) ListRunningPreflightCheckRuns(appID string) ([]*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRunningPreflightCheckRuns", appID)
+	ret0, _ := ret[0].([]*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRunningPreflightCheckRuns indicates an expected call of ListRunningPreflightCheckRuns
+func (mr *MockPreflightStoreMockRecorder) ListRunningPreflightCheckRuns(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRunningPreflightCheckRuns", reflect.TypeOf((*MockPreflightStore)(nil).ListRunningPreflightCheckRuns), appID)
+}
+
+// SetPreflightCheckRunStarted mocks base method
+func (m *MockPreflightStore) SetPreflightCheckRunStarted(id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreflightCheckRunStarted", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreflightCheckRunStarted indicates an expected call of SetPreflightCheckRunStarted
+func (mr *MockPreflightStoreMockRecorder) SetPreflightCheckRunStarted(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreflightCheckRunStarted", reflect.TypeOf((*MockPreflightStore)(nil).SetPreflightCheckRunStarted), id)
+}
+
+// FailStalePreflightCheckRuns mocks base method
+func (m *MockPreflightStore) FailStalePreflightCheckRuns(appID string, startedBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailStalePreflightCheckRuns", appID, startedBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailStalePreflightCheckRuns indicates an expected call of FailStalePreflightCheckRuns
+func (mr *MockPreflightStoreMockRecorder) FailStalePreflightCheckRuns(appID, startedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailStalePreflightCheckRuns", reflect.TypeOf((*MockPreflightStore)(nil).FailStalePreflightCheckRuns), appID, startedBefore)
+}
+
+// GetPreflightCheckRun mocks base method
+func (m *MockPreflightStore) GetPreflightCheckRun(id string) (*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPreflightCheckRun", id)
+	ret0, _ := ret[0].(*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPreflightCheckRun indicates an expected call of GetPreflightCheckRun
+func (mr *MockPreflightStoreMockRecorder) GetPreflightCheckRun(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPreflightCheckRun", reflect.TypeOf((*MockPreflightStore)(nil).GetPreflightCheckRun), id)
+}
+
+// GetLatestPreflightCheckRun mocks base method
+func (m *MockPreflightStore) GetLatestPreflightCheckRun(appID, clusterID string) (*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestPreflightCheckRun", appID, clusterID)
+	ret0, _ := ret[0].(*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestPreflightCheckRun indicates an expected call of GetLatestPreflightCheckRun
+func (mr *MockPreflightStoreMockRecorder) GetLatestPreflightCheckRun(appID, clusterID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestPreflightCheckRun", reflect.TypeOf((*MockPreflightStore)(nil).GetLatestPreflightCheckRun), appID, clusterID)
+}
+
+// SetPreflightCheckRunResults mocks base method
+func (m *MockPreflightStore) SetPreflightCheckRunResults(id string, result []byte, state string, changes []types5.CheckChange) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreflightCheckRunResults", id, result, state, changes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreflightCheckRunResults indicates an expected call of SetPreflightCheckRunResults
+func (mr *MockPreflightStoreMockRecorder) SetPreflightCheckRunResults(id, result, state, changes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreflightCheckRunResults", reflect.TypeOf((*MockPreflightStore)(nil).SetPreflightCheckRunResults), id, result, state, changes)
+}
+
+// ListPreflightCheckRuns mocks base method
+func (m *MockPreflightStore) ListPreflightCheckRuns(appID string) ([]*types5.CheckRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPreflightCheckRuns", appID)
+	ret0, _ := ret[0].([]*types5.CheckRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPreflightCheckRuns indicates an expected call of ListPreflightCheckRuns
+func (mr *MockPreflightStoreMockRecorder) ListPreflightCheckRuns(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPreflightCheckRuns", reflect.TypeOf((*MockPreflightStore)(nil).ListPreflightCheckRuns), appID)
+}
+
+// PrunePreflightCheckRuns mocks base method
+func (m *MockPreflightStore) PrunePreflightCheckRuns(appID string, keep int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrunePreflightCheckRuns", appID, keep)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PrunePreflightCheckRuns indicates an expected call of PrunePreflightCheckRuns
+func (mr *MockPreflightStoreMockRecorder) PrunePreflightCheckRuns(appID, keep interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrunePreflightCheckRuns", reflect.TypeOf((*MockPreflightStore)(nil).PrunePreflightCheckRuns), appID, keep)
+}
+
 // MockPrometheusStore is a mock of PrometheusStore interface
 type MockPrometheusStore struct {
 	ctrl     *gomock.Controller
@@ -2145,6 +2940,62 @@ func (mr *MockAppStoreMockRecorder) SetSnapshotSchedule(appID, snapshotSchedule
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSnapshotSchedule", reflect.TypeOf((*MockAppStore)(nil).SetSnapshotSchedule), appID, snapshotSchedule)
 }
 
+// SetSnapshotRetentionPolicy mocks base method
+func (m *MockAppStore) SetSnapshotRetentionPolicy(appID string, retentionPolicy *types13.RetentionPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSnapshotRetentionPolicy", appID, retentionPolicy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSnapshotRetentionPolicy indicates an expected call of SetSnapshotRetentionPolicy
+func (mr *MockAppStoreMockRecorder) SetSnapshotRetentionPolicy(appID, retentionPolicy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSnapshotRetentionPolicy", reflect.TypeOf((*MockAppStore)(nil).SetSnapshotRetentionPolicy), appID, retentionPolicy)
+}
+
+// SetSupportBundlePolicy mocks base method
+func (m *MockAppStore) SetSupportBundlePolicy(appID string, policy *types9.AutoCollectPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSupportBundlePolicy", appID, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSupportBundlePolicy indicates an expected call of SetSupportBundlePolicy
+func (mr *MockAppStoreMockRecorder) SetSupportBundlePolicy(appID, policy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSupportBundlePolicy", reflect.TypeOf((*MockAppStore)(nil).SetSupportBundlePolicy), appID, policy)
+}
+
+// SetPreflightCheckPolicy mocks base method
+func (m *MockAppStore) SetPreflightCheckPolicy(appID string, policy *types5.CheckPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreflightCheckPolicy", appID, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreflightCheckPolicy indicates an expected call of SetPreflightCheckPolicy
+func (mr *MockAppStoreMockRecorder) SetPreflightCheckPolicy(appID, policy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreflightCheckPolicy", reflect.TypeOf((*MockAppStore)(nil).SetPreflightCheckPolicy), appID, policy)
+}
+
+// SetPreUpgradeSnapshot mocks base method
+func (m *MockAppStore) SetPreUpgradeSnapshot(appID, preUpgradeSnapshot string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreUpgradeSnapshot", appID, preUpgradeSnapshot)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreUpgradeSnapshot indicates an expected call of SetPreUpgradeSnapshot
+func (mr *MockAppStoreMockRecorder) SetPreUpgradeSnapshot(appID, preUpgradeSnapshot interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreUpgradeSnapshot", reflect.TypeOf((*MockAppStore)(nil).SetPreUpgradeSnapshot), appID, preUpgradeSnapshot)
+}
+
 // RemoveApp mocks base method
 func (m *MockAppStore) RemoveApp(appID string) error {
 	m.ctrl.T.Helper()
@@ -2296,6 +3147,64 @@ func (mr *MockSnapshotStoreMockRecorder) CreateScheduledInstanceSnapshot(snapsho
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScheduledInstanceSnapshot", reflect.TypeOf((*MockSnapshotStore)(nil).CreateScheduledInstanceSnapshot), snapshotID, clusterID, timestamp)
 }
 
+// CreateBackupVerification mocks base method
+func (m *MockSnapshotStore) CreateBackupVerification(verification *types8.BackupVerification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBackupVerification", verification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateBackupVerification indicates an expected call of CreateBackupVerification
+func (mr *MockSnapshotStoreMockRecorder) CreateBackupVerification(verification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBackupVerification", reflect.TypeOf((*MockSnapshotStore)(nil).CreateBackupVerification), verification)
+}
+
+// UpdateBackupVerification mocks base method
+func (m *MockSnapshotStore) UpdateBackupVerification(verification *types8.BackupVerification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBackupVerification", verification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateBackupVerification indicates an expected call of UpdateBackupVerification
+func (mr *MockSnapshotStoreMockRecorder) UpdateBackupVerification(verification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBackupVerification", reflect.TypeOf((*MockSnapshotStore)(nil).UpdateBackupVerification), verification)
+}
+
+// GetBackupVerification mocks base method
+func (m *MockSnapshotStore) GetBackupVerification(backupName, appID string) (*types8.BackupVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBackupVerification", backupName, appID)
+	ret0, _ := ret[0].(*types8.BackupVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBackupVerification indicates an expected call of GetBackupVerification
+func (mr *MockSnapshotStoreMockRecorder) GetBackupVerification(backupName, appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBackupVerification", reflect.TypeOf((*MockSnapshotStore)(nil).GetBackupVerification), backupName, appID)
+}
+
+// ListBackupVerifications mocks base method
+func (m *MockSnapshotStore) ListBackupVerifications() ([]types8.BackupVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBackupVerifications")
+	ret0, _ := ret[0].([]types8.BackupVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBackupVerifications indicates an expected call of ListBackupVerifications
+func (mr *MockSnapshotStoreMockRecorder) ListBackupVerifications() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBackupVerifications", reflect.TypeOf((*MockSnapshotStore)(nil).ListBackupVerifications))
+}
+
 // MockVersionStore is a mock of VersionStore interface
 type MockVersionStore struct {
 	ctrl     *gomock.Controller
@@ -2616,6 +3525,20 @@ func (mr *MockClusterStoreMockRecorder) SetInstanceSnapshotSchedule(clusterID, s
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInstanceSnapshotSchedule", reflect.TypeOf((*MockClusterStore)(nil).SetInstanceSnapshotSchedule), clusterID, snapshotSchedule)
 }
 
+// SetInstanceSnapshotRetentionPolicy mocks base method
+func (m *MockClusterStore) SetInstanceSnapshotRetentionPolicy(clusterID string, retentionPolicy *types13.RetentionPolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetInstanceSnapshotRetentionPolicy", clusterID, retentionPolicy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetInstanceSnapshotRetentionPolicy indicates an expected call of SetInstanceSnapshotRetentionPolicy
+func (mr *MockClusterStoreMockRecorder) SetInstanceSnapshotRetentionPolicy(clusterID, retentionPolicy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetInstanceSnapshotRetentionPolicy", reflect.TypeOf((*MockClusterStore)(nil).SetInstanceSnapshotRetentionPolicy), clusterID, retentionPolicy)
+}
+
 // MockInstallationStore is a mock of InstallationStore interface
 type MockInstallationStore struct {
 	ctrl     *gomock.Controller
@@ -2653,3 +3576,55 @@ func (mr *MockInstallationStoreMockRecorder) GetPendingInstallationStatus() *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingInstallationStatus", reflect.TypeOf((*MockInstallationStore)(nil).GetPendingInstallationStatus))
 }
+
+// MockMaintenanceWindowStore is a mock of MaintenanceWindowStore interface
+type MockMaintenanceWindowStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockMaintenanceWindowStoreMockRecorder
+}
+
+// MockMaintenanceWindowStoreMockRecorder is the mock recorder for MockMaintenanceWindowStore
+type MockMaintenanceWindowStoreMockRecorder struct {
+	mock *MockMaintenanceWindowStore
+}
+
+// NewMockMaintenanceWindowStore creates a new mock instance
+func NewMockMaintenanceWindowStore(ctrl *gomock.Controller) *MockMaintenanceWindowStore {
+	mock := &MockMaintenanceWindowStore{ctrl: ctrl}
+	mock.recorder = &MockMaintenanceWindowStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockMaintenanceWindowStore) EXPECT() *MockMaintenanceWindowStoreMockRecorder {
+	return m.recorder
+}
+
+// GetMaintenanceWindows mocks base method
+func (m *MockMaintenanceWindowStore) GetMaintenanceWindows(appID string) (*types12.MaintenanceWindows, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMaintenanceWindows", appID)
+	ret0, _ := ret[0].(*types12.MaintenanceWindows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMaintenanceWindows indicates an expected call of GetMaintenanceWindows
+func (mr *MockMaintenanceWindowStoreMockRecorder) GetMaintenanceWindows(appID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMaintenanceWindows", reflect.TypeOf((*MockMaintenanceWindowStore)(nil).GetMaintenanceWindows), appID)
+}
+
+// SetMaintenanceWindows mocks base method
+func (m *MockMaintenanceWindowStore) SetMaintenanceWindows(appID string, maintenanceWindows *types12.MaintenanceWindows) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMaintenanceWindows", appID, maintenanceWindows)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMaintenanceWindows indicates an expected call of SetMaintenanceWindows
+func (mr *MockMaintenanceWindowStoreMockRecorder) SetMaintenanceWindows(appID, maintenanceWindows interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMaintenanceWindows", reflect.TypeOf((*MockMaintenanceWindowStore)(nil).SetMaintenanceWindows), appID, maintenanceWindows)
+}
@@ -9,7 +9,10 @@ import (
 	"github.com/gosimple/slug"
 	"github.com/pkg/errors"
 	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
+	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
+	supportbundletypes "github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
 	downstreamtypes "github.com/replicatedhq/kots/pkg/api/downstream/types"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 	"github.com/segmentio/ksuid"
 )
 
@@ -324,6 +327,22 @@ func (c OCIStore) SetSnapshotTTL(appID string, snapshotTTL string) error {
 	return ErrNotImplemented
 }
 
+func (c OCIStore) SetSnapshotRetentionPolicy(appID string, retentionPolicy *kotssnapshottypes.RetentionPolicy) error {
+	return ErrNotImplemented
+}
+
+func (c OCIStore) SetSupportBundlePolicy(appID string, policy *supportbundletypes.AutoCollectPolicy) error {
+	return ErrNotImplemented
+}
+
+func (c OCIStore) SetPreflightCheckPolicy(appID string, policy *preflighttypes.CheckPolicy) error {
+	return ErrNotImplemented
+}
+
+func (c OCIStore) SetPreUpgradeSnapshot(appID string, preUpgradeSnapshot string) error {
+	return ErrNotImplemented
+}
+
 func (s OCIStore) updateApp(app *apptypes.App) error {
 	b, err := json.Marshal(app)
 	if err != nil {
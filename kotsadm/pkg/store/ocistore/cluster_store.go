@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/rand"
 	downstreamtypes "github.com/replicatedhq/kots/pkg/api/downstream/types"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 )
 
 const (
@@ -129,3 +130,7 @@ func (s OCIStore) SetInstanceSnapshotTTL(clusterID string, snapshotTTL string) e
 func (s OCIStore) SetInstanceSnapshotSchedule(clusterID string, snapshotSchedule string) error {
 	return ErrNotImplemented
 }
+
+func (s OCIStore) SetInstanceSnapshotRetentionPolicy(clusterID string, retentionPolicy *kotssnapshottypes.RetentionPolicy) error {
+	return ErrNotImplemented
+}
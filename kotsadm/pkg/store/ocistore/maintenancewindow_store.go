@@ -0,0 +1,13 @@
+package ocistore
+
+import (
+	maintenancewindowtypes "github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow/types"
+)
+
+func (s OCIStore) GetMaintenanceWindows(appID string) (*maintenancewindowtypes.MaintenanceWindows, error) {
+	return nil, ErrNotImplemented
+}
+
+func (s OCIStore) SetMaintenanceWindows(appID string, maintenanceWindows *maintenancewindowtypes.MaintenanceWindows) error {
+	return ErrNotImplemented
+}
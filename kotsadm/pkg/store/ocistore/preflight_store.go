@@ -1,6 +1,8 @@
 package ocistore
 
 import (
+	"time"
+
 	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
 )
 
@@ -23,3 +25,47 @@ func (s OCIStore) ResetPreflightResults(appID string, sequence int64) error {
 func (s OCIStore) SetIgnorePreflightPermissionErrors(appID string, sequence int64) error {
 	return ErrNotImplemented
 }
+
+func (s OCIStore) SetPreflightStrictOverride(appID string, sequence int64, source string, overrideBy string) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) CreatePreflightCheckRun(id string, appID string, clusterID string, sequence int64) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) ListPendingPreflightCheckRuns(appID string) ([]*preflighttypes.CheckRun, error) {
+	return nil, ErrNotImplemented
+}
+
+func (s OCIStore) ListRunningPreflightCheckRuns(appID string) ([]*preflighttypes.CheckRun, error) {
+	return nil, ErrNotImplemented
+}
+
+func (s OCIStore) SetPreflightCheckRunStarted(id string) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) FailStalePreflightCheckRuns(appID string, startedBefore time.Time) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) GetPreflightCheckRun(id string) (*preflighttypes.CheckRun, error) {
+	return nil, ErrNotImplemented
+}
+
+func (s OCIStore) GetLatestPreflightCheckRun(appID string, clusterID string) (*preflighttypes.CheckRun, error) {
+	return nil, ErrNotImplemented
+}
+
+func (s OCIStore) SetPreflightCheckRunResults(id string, result []byte, state string, changes []preflighttypes.CheckChange) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) ListPreflightCheckRuns(appID string) ([]*preflighttypes.CheckRun, error) {
+	return nil, ErrNotImplemented
+}
+
+func (s OCIStore) PrunePreflightCheckRuns(appID string, keep int) error {
+	return ErrNotImplemented
+}
@@ -43,6 +43,7 @@ func (s OCIStore) CreateSession(forUser *usertypes.User, issuedAt time.Time, exp
 
 	session := sessiontypes.Session{
 		ID:        id,
+		UserID:    forUser.ID,
 		IssuedAt:  issuedAt,
 		ExpiresAt: expiresAt,
 		Roles:     roles,
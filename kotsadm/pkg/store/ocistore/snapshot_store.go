@@ -37,3 +37,19 @@ func (c OCIStore) DeletePendingScheduledInstanceSnapshots(clusterID string) erro
 func (c OCIStore) CreateScheduledInstanceSnapshot(snapshotID string, clusterID string, timestamp time.Time) error {
 	return ErrNotImplemented
 }
+
+func (c OCIStore) CreateBackupVerification(verification *snapshottypes.BackupVerification) error {
+	return ErrNotImplemented
+}
+
+func (c OCIStore) UpdateBackupVerification(verification *snapshottypes.BackupVerification) error {
+	return ErrNotImplemented
+}
+
+func (c OCIStore) GetBackupVerification(backupName string, appID string) (*snapshottypes.BackupVerification, error) {
+	return nil, ErrNotImplemented
+}
+
+func (c OCIStore) ListBackupVerifications() ([]snapshottypes.BackupVerification, error) {
+	return nil, ErrNotImplemented
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/containerd/containerd/remotes/docker"
 	"github.com/deislabs/oras/pkg/content"
@@ -40,6 +41,18 @@ func (s OCIStore) CreatePendingSupportBundle(id string, appID string, clusterID
 	return ErrNotImplemented
 }
 
+func (s OCIStore) CreateTriggeredPendingSupportBundle(id string, appID string, clusterID string, sequence int64, reason string, since time.Time) (bool, error) {
+	return false, ErrNotImplemented
+}
+
+func (s OCIStore) GetPendingSupportBundle(id string) (*supportbundletypes.PendingSupportBundle, error) {
+	return nil, ErrNotImplemented
+}
+
+func (s OCIStore) SetSupportBundleTrigger(id string, sequence int64, reason string) error {
+	return ErrNotImplemented
+}
+
 func (s OCIStore) CreateSupportBundle(id string, appID string, archivePath string, marshalledTree []byte) (*supportbundletypes.SupportBundle, error) {
 
 	fileContents, err := ioutil.ReadFile(archivePath)
@@ -178,3 +191,35 @@ func (s OCIStore) SetRedactions(bundleID string, redacts troubleshootredact.Reda
 func (s OCIStore) GetSupportBundleSpecForApp(id string) (string, error) {
 	return "", ErrNotImplemented
 }
+
+func (s OCIStore) GetSupportBundleDestination(appID string) (*supportbundletypes.Destination, error) {
+	return nil, ErrNotImplemented
+}
+
+func (s OCIStore) SetSupportBundleDestination(appID string, destination *supportbundletypes.Destination) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) SetSupportBundlePushStatus(bundleID string, status string, pushError string) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) SetSupportBundlePartial(id string, failedCollectors []string) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) CreateSupportBundleCollection(collection *supportbundletypes.Collection) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) GetSupportBundleCollection(id string) (*supportbundletypes.Collection, error) {
+	return nil, ErrNotImplemented
+}
+
+func (s OCIStore) UpdateSupportBundleCollection(collection *supportbundletypes.Collection) error {
+	return ErrNotImplemented
+}
+
+func (s OCIStore) ListSupportBundleCollections(appID string) ([]*supportbundletypes.Collection, error) {
+	return nil, ErrNotImplemented
+}
@@ -385,7 +385,7 @@ func (s OCIStore) CreateAppVersion(appID string, currentSequence *int64, appName
 			}
 		}
 
-		commitURL, err := gitops.CreateGitOpsDownstreamCommit(appID, d.ClusterID, int(newSequence), filesInDir, d.Name)
+		commitURL, err := gitops.CreateGitOpsDownstreamCommit(appID, d.ClusterID, int(newSequence), filesInDir, previousArchiveDir, d.Name, diffSummary)
 		if err != nil {
 			return int64(0), errors.Wrap(err, "failed to create gitops commit")
 		}
@@ -2,6 +2,7 @@ package s3pg
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -12,7 +13,10 @@ import (
 	"github.com/replicatedhq/kots/kotsadm/pkg/gitops"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
 	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
+	supportbundletypes "github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
 	downstreamtypes "github.com/replicatedhq/kots/pkg/api/downstream/types"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 	"github.com/segmentio/ksuid"
 	"go.uber.org/zap"
 )
@@ -112,7 +116,7 @@ func (s S3PGStore) GetApp(id string) (*apptypes.App, error) {
 	// 	zap.String("id", id))
 
 	db := persistence.MustGetPGSession()
-	query := `select id, name, license, upstream_uri, icon_uri, created_at, updated_at, slug, current_sequence, last_update_check_at, is_airgap, snapshot_ttl_new, snapshot_schedule, restore_in_progress_name, restore_undeploy_status, update_checker_spec, install_state from app where id = $1`
+	query := `select id, name, license, upstream_uri, icon_uri, created_at, updated_at, slug, current_sequence, last_update_check_at, is_airgap, snapshot_ttl_new, snapshot_schedule, snapshot_retention_policy, support_bundle_policy, preflight_check_policy, pre_upgrade_snapshot, restore_in_progress_name, restore_undeploy_status, restore_options, update_checker_spec, install_state from app where id = $1`
 	row := db.QueryRow(query, id)
 
 	app := apptypes.App{}
@@ -125,11 +129,16 @@ func (s S3PGStore) GetApp(id string) (*apptypes.App, error) {
 	var lastUpdateCheckAt sql.NullString
 	var snapshotTTLNew sql.NullString
 	var snapshotSchedule sql.NullString
+	var snapshotRetentionPolicy sql.NullString
+	var supportBundlePolicy sql.NullString
+	var preflightCheckPolicy sql.NullString
+	var preUpgradeSnapshot sql.NullString
 	var restoreInProgressName sql.NullString
 	var restoreUndeployStatus sql.NullString
+	var restoreOptions sql.NullString
 	var updateCheckerSpec sql.NullString
 
-	if err := row.Scan(&app.ID, &app.Name, &licenseStr, &upstreamURI, &iconURI, &app.CreatedAt, &updatedAt, &app.Slug, &currentSequence, &lastUpdateCheckAt, &app.IsAirgap, &snapshotTTLNew, &snapshotSchedule, &restoreInProgressName, &restoreUndeployStatus, &updateCheckerSpec, &app.InstallState); err != nil {
+	if err := row.Scan(&app.ID, &app.Name, &licenseStr, &upstreamURI, &iconURI, &app.CreatedAt, &updatedAt, &app.Slug, &currentSequence, &lastUpdateCheckAt, &app.IsAirgap, &snapshotTTLNew, &snapshotSchedule, &snapshotRetentionPolicy, &supportBundlePolicy, &preflightCheckPolicy, &preUpgradeSnapshot, &restoreInProgressName, &restoreUndeployStatus, &restoreOptions, &updateCheckerSpec, &app.InstallState); err != nil {
 		return nil, errors.Wrap(err, "failed to scan app")
 	}
 
@@ -139,10 +148,39 @@ func (s S3PGStore) GetApp(id string) (*apptypes.App, error) {
 	app.LastUpdateCheckAt = lastUpdateCheckAt.String
 	app.SnapshotTTL = snapshotTTLNew.String
 	app.SnapshotSchedule = snapshotSchedule.String
+	app.PreUpgradeSnapshot = preUpgradeSnapshot.String
 	app.RestoreInProgressName = restoreInProgressName.String
 	app.RestoreUndeployStatus = apptypes.UndeployStatus(restoreUndeployStatus.String)
 	app.UpdateCheckerSpec = updateCheckerSpec.String
 
+	if snapshotRetentionPolicy.Valid && snapshotRetentionPolicy.String != "" {
+		app.SnapshotRetentionPolicy = &kotssnapshottypes.RetentionPolicy{}
+		if err := json.Unmarshal([]byte(snapshotRetentionPolicy.String), app.SnapshotRetentionPolicy); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal snapshot retention policy")
+		}
+	}
+
+	if supportBundlePolicy.Valid && supportBundlePolicy.String != "" {
+		app.SupportBundlePolicy = &supportbundletypes.AutoCollectPolicy{}
+		if err := json.Unmarshal([]byte(supportBundlePolicy.String), app.SupportBundlePolicy); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal support bundle policy")
+		}
+	}
+
+	if preflightCheckPolicy.Valid && preflightCheckPolicy.String != "" {
+		app.PreflightCheckPolicy = &preflighttypes.CheckPolicy{}
+		if err := json.Unmarshal([]byte(preflightCheckPolicy.String), app.PreflightCheckPolicy); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal preflight check policy")
+		}
+	}
+
+	if restoreOptions.Valid && restoreOptions.String != "" {
+		app.RestoreOptions = &kotssnapshottypes.RestoreOptions{}
+		if err := json.Unmarshal([]byte(restoreOptions.String), app.RestoreOptions); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal restore options")
+		}
+	}
+
 	if updatedAt.Valid {
 		app.UpdatedAt = &updatedAt.Time
 	}
@@ -377,6 +415,75 @@ func (c S3PGStore) SetSnapshotTTL(appID string, snapshotTTL string) error {
 	return nil
 }
 
+func (c S3PGStore) SetSnapshotRetentionPolicy(appID string, retentionPolicy *kotssnapshottypes.RetentionPolicy) error {
+	logger.Debug("Setting snapshot retention policy",
+		zap.String("appID", appID))
+
+	var marshalledPolicy interface{}
+	if retentionPolicy.IsEnabled() {
+		b, err := json.Marshal(retentionPolicy)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal retention policy")
+		}
+		marshalledPolicy = string(b)
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update app set snapshot_retention_policy = $1 where id = $2`
+	_, err := db.Exec(query, marshalledPolicy, appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec db query")
+	}
+
+	return nil
+}
+
+func (c S3PGStore) SetSupportBundlePolicy(appID string, policy *supportbundletypes.AutoCollectPolicy) error {
+	logger.Debug("Setting support bundle policy",
+		zap.String("appID", appID))
+
+	var marshalledPolicy interface{}
+	if policy.IsEnabled() {
+		b, err := json.Marshal(policy)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal support bundle policy")
+		}
+		marshalledPolicy = string(b)
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update app set support_bundle_policy = $1 where id = $2`
+	_, err := db.Exec(query, marshalledPolicy, appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec db query")
+	}
+
+	return nil
+}
+
+func (c S3PGStore) SetPreflightCheckPolicy(appID string, policy *preflighttypes.CheckPolicy) error {
+	logger.Debug("Setting preflight check policy",
+		zap.String("appID", appID))
+
+	var marshalledPolicy interface{}
+	if policy.IsEnabled() {
+		b, err := json.Marshal(policy)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal preflight check policy")
+		}
+		marshalledPolicy = string(b)
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update app set preflight_check_policy = $1 where id = $2`
+	_, err := db.Exec(query, marshalledPolicy, appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec db query")
+	}
+
+	return nil
+}
+
 func (c S3PGStore) SetSnapshotSchedule(appID string, snapshotSchedule string) error {
 	logger.Debug("Setting snapshot Schedule",
 		zap.String("appID", appID))
@@ -390,6 +497,19 @@ func (c S3PGStore) SetSnapshotSchedule(appID string, snapshotSchedule string) er
 	return nil
 }
 
+func (c S3PGStore) SetPreUpgradeSnapshot(appID string, preUpgradeSnapshot string) error {
+	logger.Debug("Setting pre-upgrade snapshot",
+		zap.String("appID", appID))
+	db := persistence.MustGetPGSession()
+	query := `update app set pre_upgrade_snapshot = $1 where id = $2`
+	_, err := db.Exec(query, preUpgradeSnapshot, appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec db query")
+	}
+
+	return nil
+}
+
 func (c S3PGStore) RemoveApp(appID string) error {
 	logger.Debug("Removing app",
 		zap.String("appID", appID))
@@ -2,6 +2,7 @@ package s3pg
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -11,13 +12,14 @@ import (
 	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
 	"github.com/replicatedhq/kots/kotsadm/pkg/rand"
 	downstreamtypes "github.com/replicatedhq/kots/pkg/api/downstream/types"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 	"go.uber.org/zap"
 )
 
 func (s S3PGStore) ListClusters() ([]*downstreamtypes.Downstream, error) {
 	db := persistence.MustGetPGSession()
 
-	query := `select id, slug, title, snapshot_schedule, snapshot_ttl from cluster` // TODO the current sequence
+	query := `select id, slug, title, snapshot_schedule, snapshot_ttl, snapshot_retention_policy from cluster` // TODO the current sequence
 	rows, err := db.Query(query)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to query clusters")
@@ -30,14 +32,22 @@ func (s S3PGStore) ListClusters() ([]*downstreamtypes.Downstream, error) {
 
 		var snapshotSchedule sql.NullString
 		var snapshotTTL sql.NullString
+		var snapshotRetentionPolicy sql.NullString
 
-		if err := rows.Scan(&cluster.ClusterID, &cluster.ClusterSlug, &cluster.Name, &snapshotSchedule, &snapshotTTL); err != nil {
+		if err := rows.Scan(&cluster.ClusterID, &cluster.ClusterSlug, &cluster.Name, &snapshotSchedule, &snapshotTTL, &snapshotRetentionPolicy); err != nil {
 			return nil, errors.Wrap(err, "failed to scan row")
 		}
 
 		cluster.SnapshotSchedule = snapshotSchedule.String
 		cluster.SnapshotTTL = snapshotTTL.String
 
+		if snapshotRetentionPolicy.Valid && snapshotRetentionPolicy.String != "" {
+			cluster.SnapshotRetentionPolicy = &kotssnapshottypes.RetentionPolicy{}
+			if err := json.Unmarshal([]byte(snapshotRetentionPolicy.String), cluster.SnapshotRetentionPolicy); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal snapshot retention policy")
+			}
+		}
+
 		clusters = append(clusters, &cluster)
 	}
 
@@ -141,6 +151,29 @@ func (c S3PGStore) SetInstanceSnapshotTTL(clusterID string, snapshotTTL string)
 	return nil
 }
 
+func (c S3PGStore) SetInstanceSnapshotRetentionPolicy(clusterID string, retentionPolicy *kotssnapshottypes.RetentionPolicy) error {
+	logger.Debug("Setting instance snapshot retention policy",
+		zap.String("clusterID", clusterID))
+
+	var marshalledPolicy interface{}
+	if retentionPolicy.IsEnabled() {
+		b, err := json.Marshal(retentionPolicy)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal retention policy")
+		}
+		marshalledPolicy = string(b)
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update cluster set snapshot_retention_policy = $1 where id = $2`
+	_, err := db.Exec(query, marshalledPolicy, clusterID)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec db query")
+	}
+
+	return nil
+}
+
 func (c S3PGStore) SetInstanceSnapshotSchedule(clusterID string, snapshotSchedule string) error {
 	logger.Debug("Setting instance snapshot Schedule",
 		zap.String("clusterID", clusterID))
@@ -0,0 +1,57 @@
+package s3pg
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	maintenancewindowtypes "github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow/types"
+	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+	"go.uber.org/zap"
+)
+
+func (s S3PGStore) GetMaintenanceWindows(appID string) (*maintenancewindowtypes.MaintenanceWindows, error) {
+	db := persistence.MustGetPGSession()
+	query := `select maintenance_windows from app where id = $1`
+	row := db.QueryRow(query, appID)
+
+	var marshalled sql.NullString
+	if err := row.Scan(&marshalled); err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+
+	if marshalled.String == "" {
+		return nil, nil
+	}
+
+	maintenanceWindows := maintenancewindowtypes.MaintenanceWindows{}
+	if err := json.Unmarshal([]byte(marshalled.String), &maintenanceWindows); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal maintenance windows")
+	}
+
+	return &maintenanceWindows, nil
+}
+
+func (s S3PGStore) SetMaintenanceWindows(appID string, maintenanceWindows *maintenancewindowtypes.MaintenanceWindows) error {
+	logger.Debug("setting maintenance windows",
+		zap.String("appID", appID))
+
+	var marshalled sql.NullString
+	if maintenanceWindows != nil {
+		b, err := json.Marshal(maintenanceWindows)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal maintenance windows")
+		}
+		marshalled = sql.NullString{String: string(b), Valid: true}
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update app set maintenance_windows = $1 where id = $2`
+	_, err := db.Exec(query, marshalled, appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec db query")
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package s3pg
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
+)
+
+const preflightCheckRunColumns = `id, app_id, cluster_id, sequence, status, state, result, changes, created_at, started_at, completed_at`
+
+func (s S3PGStore) CreatePreflightCheckRun(id string, appID string, clusterID string, sequence int64) error {
+	db := persistence.MustGetPGSession()
+	query := `insert into preflight_check_run (id, app_id, cluster_id, sequence, status, created_at) values ($1, $2, $3, $4, $5, $6)`
+
+	_, err := db.Exec(query, id, appID, clusterID, sequence, preflighttypes.CheckRunStatusPending, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to insert preflight check run")
+	}
+
+	return nil
+}
+
+func (s S3PGStore) ListPendingPreflightCheckRuns(appID string) ([]*preflighttypes.CheckRun, error) {
+	db := persistence.MustGetPGSession()
+	query := `select ` + preflightCheckRunColumns + ` from preflight_check_run where app_id = $1 and status = $2 order by created_at asc`
+
+	rows, err := db.Query(query, appID, preflighttypes.CheckRunStatusPending)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query")
+	}
+	defer rows.Close()
+
+	return preflightCheckRunsFromRows(rows)
+}
+
+func (s S3PGStore) ListRunningPreflightCheckRuns(appID string) ([]*preflighttypes.CheckRun, error) {
+	db := persistence.MustGetPGSession()
+	query := `select ` + preflightCheckRunColumns + ` from preflight_check_run where app_id = $1 and status = $2 order by created_at asc`
+
+	rows, err := db.Query(query, appID, preflighttypes.CheckRunStatusRunning)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query")
+	}
+	defer rows.Close()
+
+	return preflightCheckRunsFromRows(rows)
+}
+
+func (s S3PGStore) SetPreflightCheckRunStarted(id string) error {
+	db := persistence.MustGetPGSession()
+	query := `update preflight_check_run set status = $1, started_at = $2 where id = $3`
+
+	_, err := db.Exec(query, preflighttypes.CheckRunStatusRunning, time.Now(), id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update preflight check run")
+	}
+
+	return nil
+}
+
+func (s S3PGStore) FailStalePreflightCheckRuns(appID string, startedBefore time.Time) error {
+	db := persistence.MustGetPGSession()
+	query := `update preflight_check_run set status = $1, completed_at = $2 where app_id = $3 and status = $4 and started_at < $5`
+
+	_, err := db.Exec(query, preflighttypes.CheckRunStatusFailed, time.Now(), appID, preflighttypes.CheckRunStatusRunning, startedBefore)
+	if err != nil {
+		return errors.Wrap(err, "failed to update preflight check runs")
+	}
+
+	return nil
+}
+
+func (s S3PGStore) GetPreflightCheckRun(id string) (*preflighttypes.CheckRun, error) {
+	db := persistence.MustGetPGSession()
+	query := `select ` + preflightCheckRunColumns + ` from preflight_check_run where id = $1`
+	row := db.QueryRow(query, id)
+
+	run, err := preflightCheckRunFromRow(row)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to get preflight check run from row")
+	}
+
+	return run, nil
+}
+
+func (s S3PGStore) GetLatestPreflightCheckRun(appID string, clusterID string) (*preflighttypes.CheckRun, error) {
+	db := persistence.MustGetPGSession()
+	query := `select ` + preflightCheckRunColumns + ` from preflight_check_run
+	where app_id = $1 and cluster_id = $2 and status = $3
+	order by completed_at desc limit 1`
+	row := db.QueryRow(query, appID, clusterID, preflighttypes.CheckRunStatusCompleted)
+
+	run, err := preflightCheckRunFromRow(row)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to get preflight check run from row")
+	}
+
+	return run, nil
+}
+
+func (s S3PGStore) SetPreflightCheckRunResults(id string, result []byte, state string, changes []preflighttypes.CheckChange) error {
+	marshalledChanges, err := json.Marshal(changes)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal changes")
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update preflight_check_run set status = $1, state = $2, result = $3, changes = $4, completed_at = $5 where id = $6 and status != $1`
+
+	_, err = db.Exec(query, preflighttypes.CheckRunStatusCompleted, state, string(result), string(marshalledChanges), time.Now(), id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update preflight check run")
+	}
+
+	return nil
+}
+
+func (s S3PGStore) ListPreflightCheckRuns(appID string) ([]*preflighttypes.CheckRun, error) {
+	db := persistence.MustGetPGSession()
+	query := `select ` + preflightCheckRunColumns + ` from preflight_check_run where app_id = $1 order by created_at desc`
+
+	rows, err := db.Query(query, appID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query")
+	}
+	defer rows.Close()
+
+	return preflightCheckRunsFromRows(rows)
+}
+
+func (s S3PGStore) PrunePreflightCheckRuns(appID string, keep int) error {
+	db := persistence.MustGetPGSession()
+	query := `delete from preflight_check_run where app_id = $1 and id not in (
+		select id from preflight_check_run where app_id = $1 order by created_at desc limit $2
+	)`
+
+	_, err := db.Exec(query, appID, keep)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete preflight check runs")
+	}
+
+	return nil
+}
+
+func preflightCheckRunsFromRows(rows *sql.Rows) ([]*preflighttypes.CheckRun, error) {
+	runs := []*preflighttypes.CheckRun{}
+	for rows.Next() {
+		run, err := preflightCheckRunFromRow(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get preflight check run from row")
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+func preflightCheckRunFromRow(row scannable) (*preflighttypes.CheckRun, error) {
+	run := &preflighttypes.CheckRun{}
+
+	var state sql.NullString
+	var result sql.NullString
+	var changes sql.NullString
+	var startedAt sql.NullTime
+	var completedAt sql.NullTime
+
+	if err := row.Scan(
+		&run.ID,
+		&run.AppID,
+		&run.ClusterID,
+		&run.Sequence,
+		&run.Status,
+		&state,
+		&result,
+		&changes,
+		&run.CreatedAt,
+		&startedAt,
+		&completedAt,
+	); err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+
+	run.State = state.String
+	run.Result = result.String
+	if startedAt.Valid {
+		run.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		run.CompletedAt = &completedAt.Time
+	}
+
+	run.Changes = []preflighttypes.CheckChange{}
+	if changes.Valid && changes.String != "" {
+		if err := json.Unmarshal([]byte(changes.String), &run.Changes); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal changes")
+		}
+	}
+
+	return run, nil
+}
@@ -29,6 +29,9 @@ func (c S3PGStore) GetPreflightResults(appID string, sequence int64) (*preflight
 	SELECT
 		app_downstream_version.preflight_result,
 		app_downstream_version.preflight_result_created_at,
+		app_downstream_version.preflight_strict_override_at,
+		app_downstream_version.preflight_strict_override_source,
+		app_downstream_version.preflight_strict_override_by,
 		app.slug as app_slug,
 		cluster.slug as cluster_slug
 	FROM app_downstream_version
@@ -53,6 +56,9 @@ func (c S3PGStore) GetLatestPreflightResultsForSequenceZero() (*preflighttypes.P
 	SELECT
 		app_downstream_version.preflight_result,
 		app_downstream_version.preflight_result_created_at,
+		app_downstream_version.preflight_strict_override_at,
+		app_downstream_version.preflight_strict_override_source,
+		app_downstream_version.preflight_strict_override_by,
 		app.slug as app_slug,
 		cluster.slug as cluster_slug
 	FROM app_downstream_version
@@ -96,15 +102,35 @@ func (s S3PGStore) SetIgnorePreflightPermissionErrors(appID string, sequence int
 	return nil
 }
 
+func (s S3PGStore) SetPreflightStrictOverride(appID string, sequence int64, source string, overrideBy string) error {
+	db := persistence.MustGetPGSession()
+	query := `UPDATE app_downstream_version
+	SET preflight_strict_override_at = $1, preflight_strict_override_source = $2, preflight_strict_override_by = $3
+	WHERE app_id = $4 AND sequence = $5`
+
+	_, err := db.Exec(query, time.Now(), source, overrideBy, appID, sequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to set downstream version strict preflight override")
+	}
+
+	return nil
+}
+
 func preflightResultFromRow(row scannable) (*preflighttypes.PreflightResult, error) {
 	r := &preflighttypes.PreflightResult{}
 
 	var preflightResult sql.NullString
 	var preflightResultCreatedAt sql.NullTime
+	var strictOverrideAt sql.NullTime
+	var strictOverrideSource sql.NullString
+	var strictOverrideBy sql.NullString
 
 	if err := row.Scan(
 		&preflightResult,
 		&preflightResultCreatedAt,
+		&strictOverrideAt,
+		&strictOverrideSource,
+		&strictOverrideBy,
 		&r.AppSlug,
 		&r.ClusterSlug,
 	); err != nil {
@@ -115,6 +141,11 @@ func preflightResultFromRow(row scannable) (*preflighttypes.PreflightResult, err
 	if preflightResultCreatedAt.Valid {
 		r.CreatedAt = &preflightResultCreatedAt.Time
 	}
+	if strictOverrideAt.Valid {
+		r.StrictOverrideAt = &strictOverrideAt.Time
+	}
+	r.StrictOverrideSource = strictOverrideSource.String
+	r.StrictOverrideBy = strictOverrideBy.String
 
 	return r, nil
 }
@@ -1,13 +1,17 @@
 package s3pg
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
 	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
 	snapshottypes "github.com/replicatedhq/kots/kotsadm/pkg/snapshot/types"
+	appstatustypes "github.com/replicatedhq/kots/pkg/api/appstatus/types"
 	"go.uber.org/zap"
+	batchv1 "k8s.io/api/batch/v1"
 )
 
 func (c S3PGStore) ListPendingScheduledSnapshots(appID string) ([]snapshottypes.ScheduledSnapshot, error) {
@@ -159,3 +163,215 @@ func (c S3PGStore) CreateScheduledInstanceSnapshot(id string, clusterID string,
 
 	return nil
 }
+
+// CreateBackupVerification replaces the previous verification of the app from the backup, if any
+func (c S3PGStore) CreateBackupVerification(verification *snapshottypes.BackupVerification) error {
+	logger.Debug("Creating backup verification",
+		zap.String("backupName", verification.BackupName),
+		zap.String("appID", verification.AppID))
+
+	namespaceMapping, jobSpec, resourceStates, err := marshalBackupVerification(verification)
+	if err != nil {
+		return err
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `
+		INSERT INTO backup_verification (
+			backup_name,
+			app_id,
+			status,
+			outcome,
+			message,
+			restore_name,
+			namespace_mapping,
+			job_spec,
+			job_status,
+			timeout_seconds,
+			resource_states,
+			started_at,
+			verifying_at,
+			finished_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+		) ON CONFLICT (backup_name, app_id) DO UPDATE SET
+			status = EXCLUDED.status,
+			outcome = EXCLUDED.outcome,
+			message = EXCLUDED.message,
+			restore_name = EXCLUDED.restore_name,
+			namespace_mapping = EXCLUDED.namespace_mapping,
+			job_spec = EXCLUDED.job_spec,
+			job_status = EXCLUDED.job_status,
+			timeout_seconds = EXCLUDED.timeout_seconds,
+			resource_states = EXCLUDED.resource_states,
+			started_at = EXCLUDED.started_at,
+			verifying_at = EXCLUDED.verifying_at,
+			finished_at = EXCLUDED.finished_at
+	`
+	_, err = db.Exec(query,
+		verification.BackupName,
+		verification.AppID,
+		verification.Status,
+		verification.Outcome,
+		verification.Message,
+		verification.RestoreName,
+		namespaceMapping,
+		jobSpec,
+		verification.JobStatus,
+		int64(verification.Timeout.Seconds()),
+		resourceStates,
+		verification.StartedAt,
+		verification.VerifyingAt,
+		verification.FinishedAt,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to db exec query")
+	}
+
+	return nil
+}
+
+func (c S3PGStore) UpdateBackupVerification(verification *snapshottypes.BackupVerification) error {
+	logger.Debug("Updating backup verification",
+		zap.String("backupName", verification.BackupName),
+		zap.String("appID", verification.AppID),
+		zap.String("status", verification.Status))
+
+	_, _, resourceStates, err := marshalBackupVerification(verification)
+	if err != nil {
+		return err
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `UPDATE backup_verification SET status = $3, outcome = $4, message = $5, job_status = $6, resource_states = $7, verifying_at = $8, finished_at = $9
+	WHERE backup_name = $1 AND app_id = $2`
+	_, err = db.Exec(query,
+		verification.BackupName,
+		verification.AppID,
+		verification.Status,
+		verification.Outcome,
+		verification.Message,
+		verification.JobStatus,
+		resourceStates,
+		verification.VerifyingAt,
+		verification.FinishedAt,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec")
+	}
+
+	return nil
+}
+
+func (c S3PGStore) GetBackupVerification(backupName string, appID string) (*snapshottypes.BackupVerification, error) {
+	db := persistence.MustGetPGSession()
+	query := `SELECT backup_name, app_id, status, outcome, message, restore_name, namespace_mapping, job_spec, job_status, timeout_seconds, resource_states, started_at, verifying_at, finished_at
+	FROM backup_verification WHERE backup_name = $1 AND app_id = $2`
+	row := db.QueryRow(query, backupName, appID)
+
+	verification, err := scanBackupVerification(row)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+
+	return verification, nil
+}
+
+func (c S3PGStore) ListBackupVerifications() ([]snapshottypes.BackupVerification, error) {
+	db := persistence.MustGetPGSession()
+	query := `SELECT backup_name, app_id, status, outcome, message, restore_name, namespace_mapping, job_spec, job_status, timeout_seconds, resource_states, started_at, verifying_at, finished_at
+	FROM backup_verification`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query")
+	}
+	defer rows.Close()
+
+	verifications := []snapshottypes.BackupVerification{}
+	for rows.Next() {
+		verification, err := scanBackupVerification(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		verifications = append(verifications, *verification)
+	}
+
+	return verifications, nil
+}
+
+func marshalBackupVerification(verification *snapshottypes.BackupVerification) (string, string, string, error) {
+	namespaceMapping, err := json.Marshal(verification.NamespaceMapping)
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "failed to marshal namespace mapping")
+	}
+
+	jobSpec := ""
+	if verification.JobSpec != nil {
+		b, err := json.Marshal(verification.JobSpec)
+		if err != nil {
+			return "", "", "", errors.Wrap(err, "failed to marshal job spec")
+		}
+		jobSpec = string(b)
+	}
+
+	resourceStates := []appstatustypes.ResourceState{}
+	if verification.ResourceStates != nil {
+		resourceStates = verification.ResourceStates
+	}
+	marshalledResourceStates, err := json.Marshal(resourceStates)
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "failed to marshal resource states")
+	}
+
+	return string(namespaceMapping), jobSpec, string(marshalledResourceStates), nil
+}
+
+func scanBackupVerification(row scannable) (*snapshottypes.BackupVerification, error) {
+	verification := snapshottypes.BackupVerification{}
+
+	var status, outcome, message, restoreName, namespaceMapping, jobSpec, jobStatus, resourceStates sql.NullString
+	var timeoutSeconds sql.NullInt64
+	var startedAt, verifyingAt, finishedAt sql.NullTime
+	if err := row.Scan(&verification.BackupName, &verification.AppID, &status, &outcome, &message, &restoreName, &namespaceMapping, &jobSpec, &jobStatus, &timeoutSeconds, &resourceStates, &startedAt, &verifyingAt, &finishedAt); err != nil {
+		return nil, err
+	}
+
+	verification.Status = status.String
+	verification.Outcome = outcome.String
+	verification.Message = message.String
+	verification.RestoreName = restoreName.String
+	verification.JobStatus = jobStatus.String
+	verification.Timeout = time.Duration(timeoutSeconds.Int64) * time.Second
+	if startedAt.Valid {
+		verification.StartedAt = &startedAt.Time
+	}
+	if verifyingAt.Valid {
+		verification.VerifyingAt = &verifyingAt.Time
+	}
+	if finishedAt.Valid {
+		verification.FinishedAt = &finishedAt.Time
+	}
+
+	verification.NamespaceMapping = map[string]string{}
+	if namespaceMapping.String != "" {
+		if err := json.Unmarshal([]byte(namespaceMapping.String), &verification.NamespaceMapping); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal namespace mapping")
+		}
+	}
+
+	if jobSpec.String != "" {
+		verification.JobSpec = &batchv1.JobSpec{}
+		if err := json.Unmarshal([]byte(jobSpec.String), verification.JobSpec); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal job spec")
+		}
+	}
+
+	verification.ResourceStates = []appstatustypes.ResourceState{}
+	if resourceStates.String != "" {
+		if err := json.Unmarshal([]byte(resourceStates.String), &verification.ResourceStates); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal resource states")
+		}
+	}
+
+	return &verification, nil
+}
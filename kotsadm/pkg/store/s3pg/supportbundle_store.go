@@ -2,6 +2,7 @@ package s3pg
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -20,6 +21,7 @@ import (
 	kotss3 "github.com/replicatedhq/kots/kotsadm/pkg/s3"
 	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
 	supportbundletypes "github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	"github.com/replicatedhq/kots/pkg/crypto"
 	troubleshootredact "github.com/replicatedhq/troubleshoot/pkg/redact"
 	"github.com/segmentio/ksuid"
 	"go.uber.org/zap"
@@ -28,7 +30,7 @@ import (
 func (s S3PGStore) ListSupportBundles(appID string) ([]*supportbundletypes.SupportBundle, error) {
 	db := persistence.MustGetPGSession()
 	// DANGER ZONE: changing sort order here affects what support bundle is shown in the analysis view.
-	query := `select id, slug, watch_id, name, size, status, created_at, uploaded_at, is_archived from supportbundle where watch_id = $1 order by created_at desc`
+	query := `select id, slug, watch_id, name, size, status, created_at, uploaded_at, is_archived, sequence, reason, push_status, push_error, pushed_at, is_partial, failed_collectors from supportbundle where watch_id = $1 order by created_at desc`
 
 	rows, err := db.Query(query, appID)
 	if err != nil {
@@ -43,19 +45,42 @@ func (s S3PGStore) ListSupportBundles(appID string) ([]*supportbundletypes.Suppo
 		var size sql.NullFloat64
 		var uploadedAt sql.NullTime
 		var isArchived sql.NullBool
+		var sequence sql.NullInt64
+		var reason sql.NullString
+		var pushStatus sql.NullString
+		var pushError sql.NullString
+		var pushedAt sql.NullTime
+		var isPartial sql.NullBool
+		var failedCollectors sql.NullString
 
 		s := &types.SupportBundle{}
-		if err := rows.Scan(&s.ID, &s.Slug, &s.AppID, &name, &size, &s.Status, &s.CreatedAt, &uploadedAt, &isArchived); err != nil {
+		if err := rows.Scan(&s.ID, &s.Slug, &s.AppID, &name, &size, &s.Status, &s.CreatedAt, &uploadedAt, &isArchived, &sequence, &reason, &pushStatus, &pushError, &pushedAt, &isPartial, &failedCollectors); err != nil {
 			return nil, errors.Wrap(err, "failed to scan")
 		}
 
 		s.Name = name.String
 		s.Size = size.Float64
 		s.IsArchived = isArchived.Bool
+		s.Reason = reason.String
+		s.PushStatus = pushStatus.String
+		s.PushError = pushError.String
+		s.IsPartial = isPartial.Bool
+
+		if failedCollectors.Valid && failedCollectors.String != "" {
+			if err := json.Unmarshal([]byte(failedCollectors.String), &s.FailedCollectors); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal failed collectors")
+			}
+		}
 
 		if uploadedAt.Valid {
 			s.UploadedAt = &uploadedAt.Time
 		}
+		if sequence.Valid {
+			s.Sequence = &sequence.Int64
+		}
+		if pushedAt.Valid {
+			s.PushedAt = &pushedAt.Time
+		}
 
 		supportBundles = append(supportBundles, s)
 	}
@@ -65,7 +90,7 @@ func (s S3PGStore) ListSupportBundles(appID string) ([]*supportbundletypes.Suppo
 
 func (s S3PGStore) ListPendingSupportBundlesForApp(appID string) ([]*supportbundletypes.PendingSupportBundle, error) {
 	db := persistence.MustGetPGSession()
-	query := `select id, app_id, cluster_id from pending_supportbundle where app_id = $1`
+	query := `select id, app_id, cluster_id, sequence, reason from pending_supportbundle where app_id = $1 and started_at is null`
 
 	rows, err := db.Query(query, appID)
 	if err != nil {
@@ -76,17 +101,49 @@ func (s S3PGStore) ListPendingSupportBundlesForApp(appID string) ([]*supportbund
 	pendingSupportBundles := []*supportbundletypes.PendingSupportBundle{}
 
 	for rows.Next() {
+		var sequence sql.NullInt64
+		var reason sql.NullString
+
 		s := supportbundletypes.PendingSupportBundle{}
-		if err := rows.Scan(&s.ID, &s.AppID, &s.ClusterID); err != nil {
+		if err := rows.Scan(&s.ID, &s.AppID, &s.ClusterID, &sequence, &reason); err != nil {
 			return nil, errors.Wrap(err, "failed to scan")
 		}
 
+		s.Reason = reason.String
+		if sequence.Valid {
+			s.Sequence = &sequence.Int64
+		}
+
 		pendingSupportBundles = append(pendingSupportBundles, &s)
 	}
 
 	return pendingSupportBundles, nil
 }
 
+func (s S3PGStore) GetPendingSupportBundle(id string) (*supportbundletypes.PendingSupportBundle, error) {
+	db := persistence.MustGetPGSession()
+	query := `select id, app_id, cluster_id, sequence, reason from pending_supportbundle where id = $1`
+	row := db.QueryRow(query, id)
+
+	var sequence sql.NullInt64
+	var reason sql.NullString
+
+	pendingSupportBundle := &supportbundletypes.PendingSupportBundle{}
+	if err := row.Scan(&pendingSupportBundle.ID, &pendingSupportBundle.AppID, &pendingSupportBundle.ClusterID, &sequence, &reason); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+
+	pendingSupportBundle.Reason = reason.String
+	if sequence.Valid {
+		pendingSupportBundle.Sequence = &sequence.Int64
+	}
+
+	return pendingSupportBundle, nil
+}
+
 func (s S3PGStore) GetSupportBundleFromSlug(slug string) (*supportbundletypes.SupportBundle, error) {
 	db := persistence.MustGetPGSession()
 	query := `select id from supportbundle where slug = $1`
@@ -105,7 +162,7 @@ func (s S3PGStore) GetSupportBundleFromSlug(slug string) (*supportbundletypes.Su
 
 func (s S3PGStore) GetSupportBundle(id string) (*supportbundletypes.SupportBundle, error) {
 	db := persistence.MustGetPGSession()
-	query := `select id, slug, watch_id, name, size, status, tree_index, created_at, uploaded_at, is_archived from supportbundle where slug = $1`
+	query := `select id, slug, watch_id, name, size, status, tree_index, created_at, uploaded_at, is_archived, sequence, reason, push_status, push_error, pushed_at, is_partial, failed_collectors from supportbundle where slug = $1`
 	row := db.QueryRow(query, id)
 
 	var name sql.NullString
@@ -113,9 +170,16 @@ func (s S3PGStore) GetSupportBundle(id string) (*supportbundletypes.SupportBundl
 	var treeIndex sql.NullString
 	var uploadedAt sql.NullTime
 	var isArchived sql.NullBool
+	var sequence sql.NullInt64
+	var reason sql.NullString
+	var pushStatus sql.NullString
+	var pushError sql.NullString
+	var pushedAt sql.NullTime
+	var isPartial sql.NullBool
+	var failedCollectors sql.NullString
 
 	supportbundle := &supportbundletypes.SupportBundle{}
-	if err := row.Scan(&supportbundle.ID, &supportbundle.Slug, &supportbundle.AppID, &name, &size, &supportbundle.Status, &treeIndex, &supportbundle.CreatedAt, &uploadedAt, &isArchived); err != nil {
+	if err := row.Scan(&supportbundle.ID, &supportbundle.Slug, &supportbundle.AppID, &name, &size, &supportbundle.Status, &treeIndex, &supportbundle.CreatedAt, &uploadedAt, &isArchived, &sequence, &reason, &pushStatus, &pushError, &pushedAt, &isPartial, &failedCollectors); err != nil {
 		return nil, errors.Wrap(err, "failed to scan")
 	}
 
@@ -123,10 +187,26 @@ func (s S3PGStore) GetSupportBundle(id string) (*supportbundletypes.SupportBundl
 	supportbundle.Size = size.Float64
 	supportbundle.TreeIndex = treeIndex.String
 	supportbundle.IsArchived = isArchived.Bool
+	supportbundle.Reason = reason.String
+	supportbundle.PushStatus = pushStatus.String
+	supportbundle.PushError = pushError.String
+	supportbundle.IsPartial = isPartial.Bool
+
+	if failedCollectors.Valid && failedCollectors.String != "" {
+		if err := json.Unmarshal([]byte(failedCollectors.String), &supportbundle.FailedCollectors); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal failed collectors")
+		}
+	}
 
 	if uploadedAt.Valid {
 		supportbundle.UploadedAt = &uploadedAt.Time
 	}
+	if sequence.Valid {
+		supportbundle.Sequence = &sequence.Int64
+	}
+	if pushedAt.Valid {
+		supportbundle.PushedAt = &pushedAt.Time
+	}
 
 	return supportbundle, nil
 }
@@ -143,6 +223,40 @@ func (s S3PGStore) CreatePendingSupportBundle(id string, appID string, clusterID
 	return nil
 }
 
+// CreateTriggeredPendingSupportBundle queues a bundle for the failure of the sequence, unless one was already queued or collected
+// for it since the given time. It returns false when nothing was queued.
+func (s S3PGStore) CreateTriggeredPendingSupportBundle(id string, appID string, clusterID string, sequence int64, reason string, since time.Time) (bool, error) {
+	db := persistence.MustGetPGSession()
+	query := `insert into pending_supportbundle (id, app_id, cluster_id, sequence, reason, created_at)
+	select $1::text, $2::text, $3::text, $4::integer, $5::text, $6::timestamp
+	where not exists (select 1 from pending_supportbundle where app_id = $2 and sequence = $4 and reason = $5 and created_at >= $7)
+	and not exists (select 1 from supportbundle where watch_id = $2 and sequence = $4 and reason = $5 and created_at >= $7)`
+
+	result, err := db.Exec(query, id, appID, clusterID, sequence, reason, time.Now(), since)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to insert support bundle")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get rows affected")
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (s S3PGStore) SetSupportBundleTrigger(id string, sequence int64, reason string) error {
+	db := persistence.MustGetPGSession()
+	query := `update supportbundle set sequence = $1, reason = $2 where id = $3`
+
+	_, err := db.Exec(query, sequence, reason, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update support bundle")
+	}
+
+	return nil
+}
+
 func (s S3PGStore) CreateSupportBundle(id string, appID string, archivePath string, marshalledTree []byte) (*supportbundletypes.SupportBundle, error) {
 	fi, err := os.Stat(archivePath)
 	if err != nil {
@@ -356,3 +470,202 @@ func (s S3PGStore) GetSupportBundleSpecForApp(id string) (string, error) {
 	}
 	return spec, nil
 }
+
+// GetSupportBundleDestination returns the decrypted destination of the app, nothing is returned if the app has no destination
+func (s S3PGStore) GetSupportBundleDestination(appID string) (*supportbundletypes.Destination, error) {
+	db := persistence.MustGetPGSession()
+	query := `select support_bundle_destination_enc from app where id = $1`
+	row := db.QueryRow(query, appID)
+
+	var destinationEnc sql.NullString
+	if err := row.Scan(&destinationEnc); err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+
+	if !destinationEnc.Valid || destinationEnc.String == "" {
+		return nil, nil
+	}
+
+	cipher, err := crypto.AESCipherFromString(os.Getenv("API_ENCRYPTION_KEY"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load apiCipher")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(destinationEnc.String)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode")
+	}
+
+	decrypted, err := cipher.Decrypt(decoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt")
+	}
+
+	destination := &supportbundletypes.Destination{}
+	if err := json.Unmarshal(decrypted, destination); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal destination")
+	}
+
+	return destination, nil
+}
+
+// SetSupportBundleDestination encrypts the destination with its credentials, a nil destination removes it
+func (s S3PGStore) SetSupportBundleDestination(appID string, destination *supportbundletypes.Destination) error {
+	logger.Debug("setting support bundle destination",
+		zap.String("appID", appID))
+
+	var destinationEnc interface{}
+	if destination != nil {
+		b, err := json.Marshal(destination)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal destination")
+		}
+
+		cipher, err := crypto.AESCipherFromString(os.Getenv("API_ENCRYPTION_KEY"))
+		if err != nil {
+			return errors.Wrap(err, "failed to create aes cipher")
+		}
+
+		destinationEnc = base64.StdEncoding.EncodeToString(cipher.Encrypt(b))
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update app set support_bundle_destination_enc = $1 where id = $2`
+	_, err := db.Exec(query, destinationEnc, appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to update support bundle destination")
+	}
+
+	return nil
+}
+
+func (s S3PGStore) SetSupportBundlePushStatus(id string, status string, pushError string) error {
+	db := persistence.MustGetPGSession()
+
+	var pushedAt interface{}
+	if status == supportbundletypes.PushStatusPushed {
+		pushedAt = time.Now()
+	}
+
+	query := `update supportbundle set push_status = $1, push_error = $2, pushed_at = $3 where id = $4`
+	_, err := db.Exec(query, status, pushError, pushedAt, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update support bundle")
+	}
+
+	return nil
+}
+
+func (s S3PGStore) SetSupportBundlePartial(id string, failedCollectors []string) error {
+	marshalledCollectors, err := json.Marshal(failedCollectors)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal failed collectors")
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update supportbundle set is_partial = $1, failed_collectors = $2 where id = $3`
+	_, err = db.Exec(query, true, string(marshalledCollectors), id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update support bundle")
+	}
+
+	return nil
+}
+
+func (s S3PGStore) CreateSupportBundleCollection(collection *supportbundletypes.Collection) error {
+	marshalledCollectors, err := json.Marshal(collection.Collectors)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal collectors")
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `insert into supportbundle_collection (id, app_id, cluster_id, status, collectors, started_at, updated_at) values ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = db.Exec(query, collection.ID, collection.AppID, collection.ClusterID, collection.Status, string(marshalledCollectors), collection.StartedAt, collection.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert support bundle collection")
+	}
+
+	return nil
+}
+
+func (s S3PGStore) GetSupportBundleCollection(id string) (*supportbundletypes.Collection, error) {
+	db := persistence.MustGetPGSession()
+	query := `select id, app_id, cluster_id, status, collectors, error, started_at, updated_at, finished_at from supportbundle_collection where id = $1`
+	row := db.QueryRow(query, id)
+
+	collection, err := supportBundleCollectionFromRow(row)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to get support bundle collection from row")
+	}
+
+	return collection, nil
+}
+
+func (s S3PGStore) UpdateSupportBundleCollection(collection *supportbundletypes.Collection) error {
+	marshalledCollectors, err := json.Marshal(collection.Collectors)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal collectors")
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `update supportbundle_collection set status = $1, collectors = $2, error = $3, updated_at = $4, finished_at = $5 where id = $6`
+
+	_, err = db.Exec(query, collection.Status, string(marshalledCollectors), collection.Error, collection.UpdatedAt, collection.FinishedAt, collection.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to update support bundle collection")
+	}
+
+	return nil
+}
+
+func (s S3PGStore) ListSupportBundleCollections(appID string) ([]*supportbundletypes.Collection, error) {
+	db := persistence.MustGetPGSession()
+	query := `select id, app_id, cluster_id, status, collectors, error, started_at, updated_at, finished_at from supportbundle_collection where app_id = $1 order by started_at desc limit 20`
+
+	rows, err := db.Query(query, appID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query")
+	}
+	defer rows.Close()
+
+	collections := []*supportbundletypes.Collection{}
+	for rows.Next() {
+		collection, err := supportBundleCollectionFromRow(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get support bundle collection from row")
+		}
+		collections = append(collections, collection)
+	}
+
+	return collections, nil
+}
+
+func supportBundleCollectionFromRow(row scannable) (*supportbundletypes.Collection, error) {
+	collection := &supportbundletypes.Collection{}
+
+	var collectors sql.NullString
+	var collectionError sql.NullString
+	var finishedAt sql.NullTime
+
+	if err := row.Scan(&collection.ID, &collection.AppID, &collection.ClusterID, &collection.Status, &collectors, &collectionError, &collection.StartedAt, &collection.UpdatedAt, &finishedAt); err != nil {
+		return nil, errors.Wrap(err, "failed to scan")
+	}
+
+	collection.Error = collectionError.String
+	if finishedAt.Valid {
+		collection.FinishedAt = &finishedAt.Time
+	}
+
+	collection.Collectors = []supportbundletypes.CollectorProgress{}
+	if collectors.Valid && collectors.String != "" {
+		if err := json.Unmarshal([]byte(collectors.String), &collection.Collectors); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal collectors")
+		}
+	}
+
+	return collection, nil
+}
@@ -345,7 +345,7 @@ func (s S3PGStore) CreateAppVersion(appID string, currentSequence *int64, appNam
 			}
 		}
 
-		commitURL, err := gitops.CreateGitOpsDownstreamCommit(appID, d.ClusterID, int(newSequence), filesInDir, d.Name)
+		commitURL, err := gitops.CreateGitOpsDownstreamCommit(appID, d.ClusterID, int(newSequence), filesInDir, previousArchiveDir, d.Name, diffSummary)
 		if err != nil {
 			return int64(0), errors.Wrap(err, "failed to create gitops commit")
 		}
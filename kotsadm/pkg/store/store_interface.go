@@ -7,6 +7,7 @@ import (
 	airgaptypes "github.com/replicatedhq/kots/kotsadm/pkg/airgap/types"
 	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
 	gitopstypes "github.com/replicatedhq/kots/kotsadm/pkg/gitops/types"
+	maintenancewindowtypes "github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow/types"
 	installationtypes "github.com/replicatedhq/kots/kotsadm/pkg/online/types"
 	preflighttypes "github.com/replicatedhq/kots/kotsadm/pkg/preflight/types"
 	registrytypes "github.com/replicatedhq/kots/kotsadm/pkg/registry/types"
@@ -19,6 +20,7 @@ import (
 	downstreamtypes "github.com/replicatedhq/kots/pkg/api/downstream/types"
 	versiontypes "github.com/replicatedhq/kots/pkg/api/version/types"
 	"github.com/replicatedhq/kots/pkg/kotsutil"
+	kotssnapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 	troubleshootredact "github.com/replicatedhq/troubleshoot/pkg/redact"
 )
 
@@ -38,6 +40,7 @@ type KOTSStore interface {
 	ClusterStore
 	SnapshotStore
 	InstallationStore
+	MaintenanceWindowStore
 
 	Init() error // this may need options
 	WaitForReady(ctx context.Context) error
@@ -59,6 +62,9 @@ type SupportBundleStore interface {
 	GetSupportBundleFromSlug(slug string) (*supportbundletypes.SupportBundle, error)
 	GetSupportBundle(bundleID string) (*supportbundletypes.SupportBundle, error)
 	CreatePendingSupportBundle(bundleID string, appID string, clusterID string) error
+	CreateTriggeredPendingSupportBundle(bundleID string, appID string, clusterID string, sequence int64, reason string, since time.Time) (bool, error)
+	GetPendingSupportBundle(bundleID string) (*supportbundletypes.PendingSupportBundle, error)
+	SetSupportBundleTrigger(bundleID string, sequence int64, reason string) error
 	CreateSupportBundle(bundleID string, appID string, archivePath string, marshalledTree []byte) (*supportbundletypes.SupportBundle, error)
 	GetSupportBundleArchive(bundleID string) (archivePath string, err error)
 	GetSupportBundleAnalysis(bundleID string) (*supportbundletypes.SupportBundleAnalysis, error)
@@ -66,6 +72,16 @@ type SupportBundleStore interface {
 	GetRedactions(bundleID string) (troubleshootredact.RedactionList, error)
 	SetRedactions(bundleID string, redacts troubleshootredact.RedactionList) error
 	GetSupportBundleSpecForApp(id string) (spec string, err error)
+	GetSupportBundleDestination(appID string) (*supportbundletypes.Destination, error)
+	SetSupportBundleDestination(appID string, destination *supportbundletypes.Destination) error
+	SetSupportBundlePushStatus(bundleID string, status string, pushError string) error
+	SetSupportBundlePartial(bundleID string, failedCollectors []string) error
+
+	CreateSupportBundleCollection(collection *supportbundletypes.Collection) error
+	// GetSupportBundleCollection returns nil if the bundle was not collected by the operator
+	GetSupportBundleCollection(bundleID string) (*supportbundletypes.Collection, error)
+	UpdateSupportBundleCollection(collection *supportbundletypes.Collection) error
+	ListSupportBundleCollections(appID string) ([]*supportbundletypes.Collection, error)
 }
 
 type PreflightStore interface {
@@ -74,6 +90,21 @@ type PreflightStore interface {
 	GetLatestPreflightResultsForSequenceZero() (*preflighttypes.PreflightResult, error)
 	ResetPreflightResults(appID string, sequence int64) error
 	SetIgnorePreflightPermissionErrors(appID string, sequence int64) error
+	SetPreflightStrictOverride(appID string, sequence int64, source string, overrideBy string) error
+
+	CreatePreflightCheckRun(id string, appID string, clusterID string, sequence int64) error
+	ListPendingPreflightCheckRuns(appID string) ([]*preflighttypes.CheckRun, error)
+	ListRunningPreflightCheckRuns(appID string) ([]*preflighttypes.CheckRun, error)
+	SetPreflightCheckRunStarted(id string) error
+	// FailStalePreflightCheckRuns fails the running runs of the app that started before the time
+	FailStalePreflightCheckRuns(appID string, startedBefore time.Time) error
+	GetPreflightCheckRun(id string) (*preflighttypes.CheckRun, error)
+	// GetLatestPreflightCheckRun returns the last completed run in the cluster, or nil if there's none
+	GetLatestPreflightCheckRun(appID string, clusterID string) (*preflighttypes.CheckRun, error)
+	SetPreflightCheckRunResults(id string, result []byte, state string, changes []preflighttypes.CheckChange) error
+	ListPreflightCheckRuns(appID string) ([]*preflighttypes.CheckRun, error)
+	// PrunePreflightCheckRuns keeps the latest runs of the app
+	PrunePreflightCheckRuns(appID string, keep int) error
 }
 
 type PrometheusStore interface {
@@ -121,6 +152,10 @@ type AppStore interface {
 	SetUpdateCheckerSpec(appID string, updateCheckerSpec string) error
 	SetSnapshotTTL(appID string, snapshotTTL string) error
 	SetSnapshotSchedule(appID string, snapshotSchedule string) error
+	SetSnapshotRetentionPolicy(appID string, retentionPolicy *kotssnapshottypes.RetentionPolicy) error
+	SetSupportBundlePolicy(appID string, policy *supportbundletypes.AutoCollectPolicy) error
+	SetPreflightCheckPolicy(appID string, policy *preflighttypes.CheckPolicy) error
+	SetPreUpgradeSnapshot(appID string, preUpgradeSnapshot string) error
 	RemoveApp(appID string) error
 }
 
@@ -134,6 +169,11 @@ type SnapshotStore interface {
 	UpdateScheduledInstanceSnapshot(snapshotID string, backupName string) error
 	DeletePendingScheduledInstanceSnapshots(clusterID string) error
 	CreateScheduledInstanceSnapshot(snapshotID string, clusterID string, timestamp time.Time) error
+
+	CreateBackupVerification(verification *snapshottypes.BackupVerification) error
+	UpdateBackupVerification(verification *snapshottypes.BackupVerification) error
+	GetBackupVerification(backupName string, appID string) (*snapshottypes.BackupVerification, error)
+	ListBackupVerifications() ([]snapshottypes.BackupVerification, error)
 }
 
 type VersionStore interface {
@@ -160,8 +200,14 @@ type ClusterStore interface {
 	CreateNewCluster(userID string, isAllUsers bool, title string, token string) (clusterID string, err error)
 	SetInstanceSnapshotTTL(clusterID string, snapshotTTL string) error
 	SetInstanceSnapshotSchedule(clusterID string, snapshotSchedule string) error
+	SetInstanceSnapshotRetentionPolicy(clusterID string, retentionPolicy *kotssnapshottypes.RetentionPolicy) error
 }
 
 type InstallationStore interface {
 	GetPendingInstallationStatus() (*installationtypes.InstallStatus, error)
 }
+
+type MaintenanceWindowStore interface {
+	GetMaintenanceWindows(appID string) (*maintenancewindowtypes.MaintenanceWindows, error)
+	SetMaintenanceWindows(appID string, maintenanceWindows *maintenancewindowtypes.MaintenanceWindows) error
+}
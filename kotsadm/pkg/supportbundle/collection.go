@@ -0,0 +1,298 @@
+package supportbundle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	troubleshootv1beta2 "github.com/replicatedhq/troubleshoot/pkg/apis/troubleshoot/v1beta2"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const (
+	// DefaultCollectorTimeout is set on run and exec collectors that don't have a timeout, so that one hung collector can't block the bundle
+	DefaultCollectorTimeout = 2 * time.Minute
+	// CollectionTimeout is how long the operator lets the support-bundle command run before the collectors that are still running are failed.
+	// The command keeps running for CollectionKillGracePeriod, so that what was collected can still be uploaded as a partial bundle.
+	CollectionTimeout = 30 * time.Minute
+	// CollectionKillGracePeriod is how long after the timeout the operator stops the support-bundle command
+	CollectionKillGracePeriod = 2 * time.Minute
+	// collectionGracePeriod is how long after the timeout a collection that was never reported as done is considered timed out.
+	// It is longer than CollectionKillGracePeriod so that the command has been stopped when the collection is cleared.
+	collectionGracePeriod = 5 * time.Minute
+)
+
+// StartCollection starts tracking the progress of a bundle that was sent to the operator
+func StartCollection(bundleID string, appID string, clusterID string) error {
+	now := time.Now()
+	collection := &types.Collection{
+		ID:         bundleID,
+		AppID:      appID,
+		ClusterID:  clusterID,
+		Status:     types.CollectionStatusCollecting,
+		Collectors: []types.CollectorProgress{},
+		StartedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := store.GetStore().CreateSupportBundleCollection(collection); err != nil {
+		return errors.Wrap(err, "failed to create support bundle collection")
+	}
+
+	return nil
+}
+
+// UpdateCollection records an event that the operator reported while collecting a bundle
+func UpdateCollection(bundleID string, event types.CollectionEvent) error {
+	collection, err := store.GetStore().GetSupportBundleCollection(bundleID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get support bundle collection")
+	}
+	if collection == nil {
+		return errors.Errorf("support bundle collection %s not found", bundleID)
+	}
+
+	applyCollectionEvent(collection, event, time.Now())
+
+	if err := store.GetStore().UpdateSupportBundleCollection(collection); err != nil {
+		return errors.Wrap(err, "failed to update support bundle collection")
+	}
+
+	return nil
+}
+
+// ListCollections returns the latest collections of the app, collections that were never reported as done are timed out
+func ListCollections(appID string) ([]*types.Collection, error) {
+	collections, err := store.GetStore().ListSupportBundleCollections(appID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list support bundle collections")
+	}
+
+	now := time.Now()
+	for _, collection := range collections {
+		checkCollectionTimeout(collection, now)
+	}
+
+	return collections, nil
+}
+
+// completeCollection is called when the bundle is uploaded, bundles with failed collectors are marked partial
+func completeCollection(bundleID string) (*types.Collection, error) {
+	collection, err := store.GetStore().GetSupportBundleCollection(bundleID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get support bundle collection")
+	}
+	if collection == nil {
+		return nil, nil
+	}
+
+	failedCollectors := markCollectionUploaded(collection, time.Now())
+
+	if err := store.GetStore().UpdateSupportBundleCollection(collection); err != nil {
+		return nil, errors.Wrap(err, "failed to update support bundle collection")
+	}
+
+	if len(failedCollectors) > 0 {
+		if err := store.GetStore().SetSupportBundlePartial(bundleID, failedCollectors); err != nil {
+			return nil, errors.Wrap(err, "failed to set support bundle partial")
+		}
+	}
+
+	return collection, nil
+}
+
+func applyCollectionEvent(collection *types.Collection, event types.CollectionEvent, now time.Time) {
+	collection.UpdatedAt = now
+
+	if event.Done {
+		collection.FinishedAt = &now
+
+		collectorStatus := types.CollectorStatusCompleted
+		collectorMessage := ""
+		if event.TimedOut {
+			collectorStatus = types.CollectorStatusFailed
+			collectorMessage = "timed out"
+		}
+		finishRunningCollectors(collection, collectorStatus, collectorMessage, now)
+
+		if collection.Status != types.CollectionStatusCollecting {
+			// the bundle was uploaded before the command exited
+			return
+		}
+
+		switch {
+		case event.TimedOut:
+			collection.Status = types.CollectionStatusTimedOut
+			collection.Error = fmt.Sprintf("collection did not complete in %s", CollectionTimeout)
+		case event.Error != "":
+			collection.Status = types.CollectionStatusFailed
+			collection.Error = event.Error
+		default:
+			collection.Status = types.CollectionStatusFailed
+			collection.Error = "the support bundle was not uploaded"
+		}
+		return
+	}
+
+	if event.TimedOut {
+		// the operator keeps the command running, collectors that finish late don't count
+		collection.Error = fmt.Sprintf("collection did not complete in %s", CollectionTimeout)
+		finishRunningCollectors(collection, types.CollectorStatusFailed, "timed out", now)
+		return
+	}
+
+	if event.Collector == "" {
+		return
+	}
+
+	switch event.CollectorStatus {
+	case types.CollectorStatusRunning:
+		// collectors run one at a time
+		finishRunningCollectors(collection, types.CollectorStatusCompleted, "", now)
+		collection.Collectors = append(collection.Collectors, types.CollectorProgress{
+			Name:      event.Collector,
+			Status:    types.CollectorStatusRunning,
+			StartedAt: now,
+		})
+
+	case types.CollectorStatusFailed:
+		for i := len(collection.Collectors) - 1; i >= 0; i-- {
+			c := &collection.Collectors[i]
+			if c.Name != event.Collector {
+				continue
+			}
+			c.Status = types.CollectorStatusFailed
+			c.Message = event.Message
+			if c.FinishedAt == nil {
+				c.FinishedAt = &now
+			}
+			return
+		}
+
+		collection.Collectors = append(collection.Collectors, types.CollectorProgress{
+			Name:       event.Collector,
+			Status:     types.CollectorStatusFailed,
+			Message:    event.Message,
+			StartedAt:  now,
+			FinishedAt: &now,
+		})
+	}
+}
+
+func finishRunningCollectors(collection *types.Collection, status string, message string, now time.Time) {
+	for i := range collection.Collectors {
+		c := &collection.Collectors[i]
+		if c.Status != types.CollectorStatusRunning {
+			continue
+		}
+		c.Status = status
+		c.Message = message
+		c.FinishedAt = &now
+	}
+}
+
+// markCollectionUploaded completes the collection and returns the names of the collectors that failed
+func markCollectionUploaded(collection *types.Collection, now time.Time) []string {
+	collection.UpdatedAt = now
+	finishRunningCollectors(collection, types.CollectorStatusCompleted, "", now)
+
+	failedCollectors := []string{}
+	for _, c := range collection.Collectors {
+		if c.Status == types.CollectorStatusFailed {
+			failedCollectors = append(failedCollectors, c.Name)
+		}
+	}
+
+	if len(failedCollectors) > 0 {
+		collection.Status = types.CollectionStatusPartial
+	} else {
+		collection.Status = types.CollectionStatusCompleted
+	}
+	collection.Error = ""
+
+	return failedCollectors
+}
+
+// checkCollectionTimeout times out a collection that the operator never reported as done, e.g. because it restarted
+func checkCollectionTimeout(collection *types.Collection, now time.Time) {
+	if collection.Status != types.CollectionStatusCollecting {
+		return
+	}
+	if now.Before(collection.StartedAt.Add(CollectionTimeout + collectionGracePeriod)) {
+		return
+	}
+
+	collection.Status = types.CollectionStatusTimedOut
+	collection.Error = "the operator did not report the end of the collection"
+}
+
+// ClearTimedOutCollections deletes the pending bundles and spec secrets of collections that the operator started
+// but never uploaded, so that a bundle can be collected again for what triggered them
+func ClearTimedOutCollections(now time.Time) error {
+	cutoff := now.Add(-(CollectionTimeout + collectionGracePeriod))
+
+	db := persistence.MustGetPGSession()
+	query := `delete from pending_supportbundle where started_at is not null and started_at < $1`
+
+	result, err := db.Exec(query, cutoff)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete timed out pending support bundles")
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected > 0 {
+		logger.Infof("cleared %d timed out support bundle collections", rowsAffected)
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create clientset")
+	}
+
+	secrets, err := clientset.CoreV1().Secrets(os.Getenv("POD_NAMESPACE")).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: BundleSpecLabel,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list support bundle secrets")
+	}
+
+	for _, secret := range secrets.Items {
+		if !secret.CreationTimestamp.Time.Before(cutoff) {
+			continue
+		}
+		err := clientset.CoreV1().Secrets(os.Getenv("POD_NAMESPACE")).Delete(context.TODO(), secret.Name, metav1.DeleteOptions{})
+		if err != nil && !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete support bundle secret %s", secret.Name)
+		}
+	}
+
+	return nil
+}
+
+// setCollectorTimeouts sets the default timeout on run and exec collectors that don't have one
+func setCollectorTimeouts(supportBundle *troubleshootv1beta2.SupportBundle) {
+	if supportBundle == nil {
+		return
+	}
+
+	for _, collect := range supportBundle.Spec.Collectors {
+		if collect.Run != nil && collect.Run.Timeout == "" {
+			collect.Run.Timeout = DefaultCollectorTimeout.String()
+		}
+		if collect.Exec != nil && collect.Exec.Timeout == "" {
+			collect.Exec.Timeout = DefaultCollectorTimeout.String()
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package supportbundle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	troubleshootv1beta2 "github.com/replicatedhq/troubleshoot/pkg/apis/troubleshoot/v1beta2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCollection(startedAt time.Time) *types.Collection {
+	return &types.Collection{
+		ID:         "bundle-id",
+		Status:     types.CollectionStatusCollecting,
+		Collectors: []types.CollectorProgress{},
+		StartedAt:  startedAt,
+		UpdatedAt:  startedAt,
+	}
+}
+
+func runningEvent(collector string) types.CollectionEvent {
+	return types.CollectionEvent{Collector: collector, CollectorStatus: types.CollectorStatusRunning}
+}
+
+func failedEvent(collector string, message string) types.CollectionEvent {
+	return types.CollectionEvent{Collector: collector, CollectorStatus: types.CollectorStatusFailed, Message: message}
+}
+
+func Test_applyCollectionEvent(t *testing.T) {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	collection := newTestCollection(start)
+	applyCollectionEvent(collection, runningEvent("cluster-info"), start.Add(time.Second))
+	applyCollectionEvent(collection, runningEvent("run/ping"), start.Add(2*time.Second))
+	applyCollectionEvent(collection, failedEvent("run/ping", "failed to run collector \"run/ping\": timeout"), start.Add(3*time.Second))
+	applyCollectionEvent(collection, runningEvent("logs/kotsadm"), start.Add(4*time.Second))
+	// events without a collector are not collector progress
+	applyCollectionEvent(collection, types.CollectionEvent{Message: "Collecting support bundle"}, start.Add(5*time.Second))
+
+	assert.Equal(t, types.CollectionStatusCollecting, collection.Status)
+	assert.Equal(t, start.Add(5*time.Second), collection.UpdatedAt)
+	if assert.Len(t, collection.Collectors, 3) {
+		assert.Equal(t, "cluster-info", collection.Collectors[0].Name)
+		assert.Equal(t, types.CollectorStatusCompleted, collection.Collectors[0].Status)
+		assert.Equal(t, "run/ping", collection.Collectors[1].Name)
+		assert.Equal(t, types.CollectorStatusFailed, collection.Collectors[1].Status)
+		assert.Equal(t, "failed to run collector \"run/ping\": timeout", collection.Collectors[1].Message)
+		assert.Equal(t, "logs/kotsadm", collection.Collectors[2].Name)
+		assert.Equal(t, types.CollectorStatusRunning, collection.Collectors[2].Status)
+	}
+
+	// a failure for a collector that was never reported is added as failed
+	applyCollectionEvent(collection, failedEvent("copy/data", "failed to run collector \"copy/data\""), start.Add(7*time.Second))
+	if assert.Len(t, collection.Collectors, 4) {
+		assert.Equal(t, types.CollectorStatusRunning, collection.Collectors[2].Status)
+		assert.Equal(t, "copy/data", collection.Collectors[3].Name)
+		assert.Equal(t, types.CollectorStatusFailed, collection.Collectors[3].Status)
+	}
+}
+
+func Test_applyCollectionEventTimedOut(t *testing.T) {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	collection := newTestCollection(start)
+	applyCollectionEvent(collection, runningEvent("cluster-info"), start.Add(time.Second))
+	applyCollectionEvent(collection, runningEvent("logs/kotsadm"), start.Add(2*time.Second))
+	applyCollectionEvent(collection, types.CollectionEvent{TimedOut: true}, start.Add(CollectionTimeout))
+
+	// the command keeps running after the timeout, and the bundle it uploads is partial
+	assert.Equal(t, types.CollectionStatusCollecting, collection.Status)
+	assert.NotEmpty(t, collection.Error)
+	assert.Equal(t, types.CollectorStatusFailed, collection.Collectors[1].Status)
+
+	applyCollectionEvent(collection, runningEvent("run/ping"), start.Add(CollectionTimeout+time.Second))
+	assert.Equal(t, types.CollectorStatusFailed, collection.Collectors[1].Status)
+
+	assert.Equal(t, []string{"logs/kotsadm"}, markCollectionUploaded(collection, start.Add(CollectionTimeout+2*time.Second)))
+	assert.Equal(t, types.CollectionStatusPartial, collection.Status)
+	assert.Empty(t, collection.Error)
+}
+
+func Test_applyCollectionEventDone(t *testing.T) {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		event           types.CollectionEvent
+		uploaded        bool
+		expectStatus    string
+		expectCollector string
+	}{
+		{
+			name:            "timed out",
+			event:           types.CollectionEvent{Done: true, TimedOut: true},
+			expectStatus:    types.CollectionStatusTimedOut,
+			expectCollector: types.CollectorStatusFailed,
+		},
+		{
+			name:            "command failed",
+			event:           types.CollectionEvent{Done: true, Error: "exit status 1"},
+			expectStatus:    types.CollectionStatusFailed,
+			expectCollector: types.CollectorStatusCompleted,
+		},
+		{
+			name:            "not uploaded",
+			event:           types.CollectionEvent{Done: true},
+			expectStatus:    types.CollectionStatusFailed,
+			expectCollector: types.CollectorStatusCompleted,
+		},
+		{
+			name:            "uploaded before done",
+			event:           types.CollectionEvent{Done: true, Error: "exit status 1"},
+			uploaded:        true,
+			expectStatus:    types.CollectionStatusCompleted,
+			expectCollector: types.CollectorStatusCompleted,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			collection := newTestCollection(start)
+			applyCollectionEvent(collection, runningEvent("cluster-info"), start.Add(time.Second))
+			if test.uploaded {
+				markCollectionUploaded(collection, start.Add(2*time.Second))
+			}
+
+			applyCollectionEvent(collection, test.event, start.Add(3*time.Second))
+
+			assert.Equal(t, test.expectStatus, collection.Status)
+			assert.Equal(t, test.expectCollector, collection.Collectors[0].Status)
+			if assert.NotNil(t, collection.FinishedAt) {
+				assert.Equal(t, start.Add(3*time.Second), *collection.FinishedAt)
+			}
+		})
+	}
+}
+
+func Test_markCollectionUploaded(t *testing.T) {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	collection := newTestCollection(start)
+	applyCollectionEvent(collection, runningEvent("cluster-info"), start)
+	applyCollectionEvent(collection, runningEvent("run/ping"), start)
+	assert.Empty(t, markCollectionUploaded(collection, start.Add(time.Second)))
+	assert.Equal(t, types.CollectionStatusCompleted, collection.Status)
+	assert.Equal(t, types.CollectorStatusCompleted, collection.Collectors[1].Status)
+
+	collection = newTestCollection(start)
+	applyCollectionEvent(collection, runningEvent("run/ping"), start)
+	applyCollectionEvent(collection, failedEvent("run/ping", "failed to run collector \"run/ping\""), start)
+	applyCollectionEvent(collection, runningEvent("logs/kotsadm"), start)
+	assert.Equal(t, []string{"run/ping"}, markCollectionUploaded(collection, start.Add(time.Second)))
+	assert.Equal(t, types.CollectionStatusPartial, collection.Status)
+}
+
+func Test_checkCollectionTimeout(t *testing.T) {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	collection := newTestCollection(start)
+	checkCollectionTimeout(collection, start.Add(CollectionTimeout))
+	assert.Equal(t, types.CollectionStatusCollecting, collection.Status)
+
+	checkCollectionTimeout(collection, start.Add(CollectionTimeout+collectionGracePeriod))
+	assert.Equal(t, types.CollectionStatusTimedOut, collection.Status)
+	assert.NotEmpty(t, collection.Error)
+
+	completed := newTestCollection(start)
+	completed.Status = types.CollectionStatusCompleted
+	checkCollectionTimeout(completed, start.Add(time.Hour))
+	assert.Equal(t, types.CollectionStatusCompleted, completed.Status)
+}
+
+func Test_setCollectorTimeouts(t *testing.T) {
+	supportBundle := &troubleshootv1beta2.SupportBundle{
+		Spec: troubleshootv1beta2.SupportBundleSpec{
+			Collectors: []*troubleshootv1beta2.Collect{
+				{ClusterInfo: &troubleshootv1beta2.ClusterInfo{}},
+				{Run: &troubleshootv1beta2.Run{}},
+				{Run: &troubleshootv1beta2.Run{Timeout: "10s"}},
+				{Exec: &troubleshootv1beta2.Exec{}},
+			},
+		},
+	}
+
+	setCollectorTimeouts(supportBundle)
+
+	assert.Equal(t, DefaultCollectorTimeout.String(), supportBundle.Spec.Collectors[1].Run.Timeout)
+	assert.Equal(t, "10s", supportBundle.Spec.Collectors[2].Run.Timeout)
+	assert.Equal(t, DefaultCollectorTimeout.String(), supportBundle.Spec.Collectors[3].Exec.Timeout)
+
+	setCollectorTimeouts(nil)
+}
@@ -0,0 +1,758 @@
+package supportbundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mholt/archiver"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	kotssupportbundletypes "github.com/replicatedhq/kots/pkg/api/supportbundle/types"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	clusterResourcesDir = "cluster-resources"
+	appArchivesDir      = "kots/admin-console/app"
+	maxLogErrorLines    = 5
+)
+
+var (
+	logErrorRegexp = regexp.MustCompile(`(?i)\b(error|fatal|panic|exception)\b`)
+
+	// these files are not lists of cluster resources, or change too often to be compared
+	skippedClusterResources = map[string]bool{
+		"events":         true,
+		"nodes":          true,
+		"groups":         true,
+		"resources":      true,
+		"auth-cani-list": true,
+	}
+)
+
+// CompareBundles compares the target support bundle with the base support bundle
+func CompareBundles(baseBundleID string, targetBundleID string) (*kotssupportbundletypes.BundleComparison, error) {
+	baseDir, err := extractBundle(baseBundleID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to extract base bundle")
+	}
+	defer os.RemoveAll(baseDir)
+
+	targetDir, err := extractBundle(targetBundleID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to extract target bundle")
+	}
+	defer os.RemoveAll(targetDir)
+
+	baseAnalysis, err := store.GetStore().GetSupportBundleAnalysis(baseBundleID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get base bundle analysis")
+	}
+
+	targetAnalysis, err := store.GetStore().GetSupportBundleAnalysis(targetBundleID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get target bundle analysis")
+	}
+
+	comparison, err := compareBundleDirs(bundleRoot(baseDir), bundleRoot(targetDir), baseAnalysis, targetAnalysis)
+	if err != nil {
+		return nil, err
+	}
+	comparison.BaseBundleID = baseBundleID
+	comparison.TargetBundleID = targetBundleID
+
+	return comparison, nil
+}
+
+func extractBundle(bundleID string) (string, error) {
+	bundleArchive, err := store.GetStore().GetSupportBundleArchive(bundleID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get bundle")
+	}
+	defer os.RemoveAll(bundleArchive)
+
+	return extractArchive(bundleArchive)
+}
+
+func extractArchive(bundleArchive string) (string, error) {
+	tmpDir, err := ioutil.TempDir("", "kots")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create tmp dir")
+	}
+
+	tarGz := archiver.TarGz{
+		Tar: &archiver.Tar{
+			ImplicitTopLevelFolder: false,
+		},
+	}
+	if err := tarGz.Unarchive(bundleArchive, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", errors.Wrap(err, "failed to unarchive")
+	}
+
+	return tmpDir, nil
+}
+
+// bundleRoot skips the top level directory of a bundle, its name has the time the bundle was collected
+func bundleRoot(dir string) string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return dir
+	}
+	if _, err := os.Stat(filepath.Join(dir, clusterResourcesDir)); err == nil {
+		return dir
+	}
+	return filepath.Join(dir, entries[0].Name())
+}
+
+func compareBundleDirs(baseDir string, targetDir string, baseAnalysis *types.SupportBundleAnalysis, targetAnalysis *types.SupportBundleAnalysis) (*kotssupportbundletypes.BundleComparison, error) {
+	comparison := &kotssupportbundletypes.BundleComparison{
+		Analyzers: compareAnalyses(baseAnalysis, targetAnalysis),
+	}
+
+	resources, err := compareClusterResources(baseDir, targetDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compare cluster resources")
+	}
+	comparison.Resources = resources
+
+	nodes, err := compareNodeConditions(baseDir, targetDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compare nodes")
+	}
+	comparison.Nodes = nodes
+
+	logs, err := compareLogs(baseDir, targetDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compare logs")
+	}
+	comparison.Logs = logs
+
+	if err := compareAppArchives(baseDir, targetDir, comparison); err != nil {
+		return nil, errors.Wrap(err, "failed to compare app archives")
+	}
+
+	return comparison, nil
+}
+
+func compareAnalyses(baseAnalysis *types.SupportBundleAnalysis, targetAnalysis *types.SupportBundleAnalysis) []kotssupportbundletypes.AnalyzerChange {
+	baseInsights := insightsByKey(baseAnalysis)
+	targetInsights := insightsByKey(targetAnalysis)
+
+	changes := []kotssupportbundletypes.AnalyzerChange{}
+	for _, key := range unionKeys(baseInsights, targetInsights) {
+		base, target := baseInsights[key], targetInsights[key]
+		if base.Severity == target.Severity {
+			continue
+		}
+
+		change := kotssupportbundletypes.AnalyzerChange{
+			Key:            key,
+			Title:          target.Primary,
+			BaseSeverity:   base.Severity,
+			TargetSeverity: target.Severity,
+			Detail:         target.Detail,
+		}
+		if change.Title == "" {
+			change.Title = base.Primary
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func insightsByKey(analysis *types.SupportBundleAnalysis) map[string]types.SupportBundleInsight {
+	insights := map[string]types.SupportBundleInsight{}
+	if analysis == nil {
+		return insights
+	}
+	for _, insight := range analysis.Insights {
+		insights[insight.Key] = insight
+	}
+	return insights
+}
+
+type resourceKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func compareClusterResources(baseDir string, targetDir string) ([]kotssupportbundletypes.ResourceChange, error) {
+	baseResources, err := readClusterResources(baseDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read base cluster resources")
+	}
+
+	targetResources, err := readClusterResources(targetDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read target cluster resources")
+	}
+
+	changes := []kotssupportbundletypes.ResourceChange{}
+	for key, target := range targetResources {
+		base, ok := baseResources[key]
+		if !ok {
+			changes = append(changes, resourceChange(key, kotssupportbundletypes.ChangeAdded))
+		} else if !reflect.DeepEqual(base, target) {
+			changes = append(changes, resourceChange(key, kotssupportbundletypes.ChangeModified))
+		}
+	}
+	for key := range baseResources {
+		if _, ok := targetResources[key]; !ok {
+			changes = append(changes, resourceChange(key, kotssupportbundletypes.ChangeRemoved))
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		if changes[i].Namespace != changes[j].Namespace {
+			return changes[i].Namespace < changes[j].Namespace
+		}
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes, nil
+}
+
+func resourceChange(key resourceKey, change string) kotssupportbundletypes.ResourceChange {
+	return kotssupportbundletypes.ResourceChange{
+		Kind:      key.Kind,
+		Namespace: key.Namespace,
+		Name:      key.Name,
+		Change:    change,
+	}
+}
+
+// readClusterResources reads the resources collected in cluster-resources/<kind>/<namespace>.json
+// and cluster-resources/<kind>.json for cluster scoped kinds
+func readClusterResources(bundleDir string) (map[resourceKey]map[string]interface{}, error) {
+	resources := map[resourceKey]map[string]interface{}{}
+
+	root := filepath.Join(bundleDir, clusterResourcesDir)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" || strings.HasSuffix(path, "-errors.json") {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(strings.TrimSuffix(relPath, ".json")), "/")
+
+		kind, namespace := parts[0], ""
+		if len(parts) == 2 {
+			namespace = parts[1]
+		} else if len(parts) > 2 {
+			return nil
+		}
+		if skippedClusterResources[kind] {
+			return nil
+		}
+
+		items, err := readResourceList(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", relPath)
+		}
+
+		for _, item := range items {
+			metadata, _ := item["metadata"].(map[string]interface{})
+			name, _ := metadata["name"].(string)
+			if name == "" {
+				continue
+			}
+			itemNamespace, _ := metadata["namespace"].(string)
+			if itemNamespace == "" {
+				itemNamespace = namespace
+			}
+
+			// these change on every update without the resource changing in a meaningful way
+			delete(metadata, "resourceVersion")
+			delete(metadata, "managedFields")
+			delete(item, "status")
+
+			resources[resourceKey{Kind: kind, Namespace: itemNamespace, Name: name}] = item
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// readResourceList reads a file that has either a list of resources or an object with the list in items
+func readResourceList(path string) ([]map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read file")
+	}
+
+	var content interface{}
+	if err := json.Unmarshal(b, &content); err != nil {
+		// not every file in cluster resources is a list of resources
+		return nil, nil
+	}
+
+	var list []interface{}
+	switch c := content.(type) {
+	case []interface{}:
+		list = c
+	case map[string]interface{}:
+		list, _ = c["items"].([]interface{})
+	}
+
+	items := []map[string]interface{}{}
+	for _, i := range list {
+		if item, ok := i.(map[string]interface{}); ok {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+func compareNodeConditions(baseDir string, targetDir string) ([]kotssupportbundletypes.NodeConditionChange, error) {
+	baseConditions, err := readNodeConditions(baseDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read base nodes")
+	}
+
+	targetConditions, err := readNodeConditions(targetDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read target nodes")
+	}
+
+	changes := []kotssupportbundletypes.NodeConditionChange{}
+	for _, key := range unionKeys(baseConditions, targetConditions) {
+		base, target := baseConditions[key], targetConditions[key]
+		if base == target {
+			continue
+		}
+		parts := strings.SplitN(key, "/", 2)
+		changes = append(changes, kotssupportbundletypes.NodeConditionChange{
+			Node:         parts[0],
+			Condition:    parts[1],
+			BaseStatus:   base,
+			TargetStatus: target,
+		})
+	}
+
+	return changes, nil
+}
+
+// readNodeConditions returns the status of each condition of each node, keyed by <node>/<condition>
+func readNodeConditions(bundleDir string) (map[string]string, error) {
+	conditions := map[string]string{}
+
+	path := filepath.Join(bundleDir, clusterResourcesDir, "nodes.json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return conditions, nil
+	}
+
+	nodes, err := readResourceList(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		metadata, _ := node["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		status, _ := node["status"].(map[string]interface{})
+		nodeConditions, _ := status["conditions"].([]interface{})
+		for _, c := range nodeConditions {
+			condition, _ := c.(map[string]interface{})
+			conditionType, _ := condition["type"].(string)
+			conditionStatus, _ := condition["status"].(string)
+			if name == "" || conditionType == "" {
+				continue
+			}
+			conditions[name+"/"+conditionType] = conditionStatus
+		}
+	}
+
+	return conditions, nil
+}
+
+func compareLogs(baseDir string, targetDir string) ([]kotssupportbundletypes.LogErrors, error) {
+	logs := []kotssupportbundletypes.LogErrors{}
+
+	err := filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".log" {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(targetDir, path)
+		if err != nil {
+			return err
+		}
+
+		targetCount, targetLines, err := findLogErrors(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", relPath)
+		}
+		if targetCount == 0 {
+			return nil
+		}
+
+		basePath := filepath.Join(baseDir, relPath)
+		if _, err := os.Stat(basePath); err == nil {
+			baseCount, _, err := findLogErrors(basePath)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read base %s", relPath)
+			}
+			if baseCount > 0 {
+				return nil
+			}
+		}
+
+		logs = append(logs, kotssupportbundletypes.LogErrors{
+			Path:       filepath.ToSlash(relPath),
+			ErrorCount: targetCount,
+			ErrorLines: targetLines,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+// findLogErrors returns the number of lines with errors in the log file and the first of them
+func findLogErrors(path string) (int, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	count := 0
+	lines := []string{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !logErrorRegexp.MatchString(line) {
+			continue
+		}
+		count++
+		if len(lines) < maxLogErrorLines {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, nil, errors.Wrap(err, "failed to scan file")
+	}
+
+	return count, lines, nil
+}
+
+func compareAppArchives(baseDir string, targetDir string, comparison *kotssupportbundletypes.BundleComparison) error {
+	baseApps, err := readAppArchives(baseDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read base app archives")
+	}
+
+	targetApps, err := readAppArchives(targetDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read target app archives")
+	}
+
+	comparison.AppArchives = []kotssupportbundletypes.AppArchiveChange{}
+	comparison.AppVersions = []kotssupportbundletypes.AppVersionChange{}
+	comparison.ConfigItems = []kotssupportbundletypes.ConfigItemChange{}
+
+	for _, app := range unionKeys(baseApps, targetApps) {
+		base, target := baseApps[app], targetApps[app]
+		if base == nil {
+			base = newAppArchive()
+		}
+		if target == nil {
+			target = newAppArchive()
+		}
+
+		for _, path := range unionKeys(base.files, target.files) {
+			change := compareChecksums(base.files, target.files, path)
+			if change == "" {
+				continue
+			}
+			comparison.AppArchives = append(comparison.AppArchives, kotssupportbundletypes.AppArchiveChange{
+				App:    app,
+				Path:   path,
+				Change: change,
+			})
+		}
+
+		if base.versionLabel != target.versionLabel || base.sequence != target.sequence {
+			comparison.AppVersions = append(comparison.AppVersions, kotssupportbundletypes.AppVersionChange{
+				App:                app,
+				BaseVersionLabel:   base.versionLabel,
+				TargetVersionLabel: target.versionLabel,
+				BaseSequence:       base.sequence,
+				TargetSequence:     target.sequence,
+			})
+		}
+
+		for _, key := range unionKeys(base.configItems, target.configItems, base.configValues, target.configValues) {
+			change := ""
+			switch {
+			case !base.hasConfigItem(key):
+				change = kotssupportbundletypes.ChangeAdded
+			case !target.hasConfigItem(key):
+				change = kotssupportbundletypes.ChangeRemoved
+			case compareChecksums(base.configItems, target.configItems, key) != "", compareChecksums(base.configValues, target.configValues, key) != "":
+				change = kotssupportbundletypes.ChangeModified
+			default:
+				continue
+			}
+			comparison.ConfigItems = append(comparison.ConfigItems, kotssupportbundletypes.ConfigItemChange{
+				App:    app,
+				Key:    key,
+				Change: change,
+			})
+		}
+	}
+
+	return nil
+}
+
+func compareChecksums(base map[string]string, target map[string]string, key string) string {
+	baseChecksum, inBase := base[key]
+	targetChecksum, inTarget := target[key]
+	switch {
+	case !inBase && !inTarget:
+		return ""
+	case !inBase:
+		return kotssupportbundletypes.ChangeAdded
+	case !inTarget:
+		return kotssupportbundletypes.ChangeRemoved
+	case baseChecksum != targetChecksum:
+		return kotssupportbundletypes.ChangeModified
+	}
+	return ""
+}
+
+// appArchive is what's compared of the app version archive collected for an app
+type appArchive struct {
+	// files are the checksums of the files in the archive
+	files map[string]string
+	// versionLabel and sequence are read from the installation
+	versionLabel string
+	sequence     string
+	// configItems are the checksums of the item definitions in the config, configValues the checksums of the values
+	configItems  map[string]string
+	configValues map[string]string
+}
+
+func newAppArchive() *appArchive {
+	return &appArchive{
+		files:        map[string]string{},
+		configItems:  map[string]string{},
+		configValues: map[string]string{},
+	}
+}
+
+// appArchiveDoc has the fields of the kinds in the app archive that are compared
+type appArchiveDoc struct {
+	Kind string `yaml:"kind"`
+	Spec struct {
+		// Installation
+		UpdateCursor string `yaml:"updateCursor"`
+		VersionLabel string `yaml:"versionLabel"`
+		// Config
+		Groups []struct {
+			Items []map[string]interface{} `yaml:"items"`
+		} `yaml:"groups"`
+		// ConfigValues
+		Values map[string]interface{} `yaml:"values"`
+	} `yaml:"spec"`
+}
+
+func (a *appArchive) hasConfigItem(key string) bool {
+	_, hasItem := a.configItems[key]
+	_, hasValue := a.configValues[key]
+	return hasItem || hasValue
+}
+
+func (a *appArchive) addFile(name string, r io.Reader) error {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "failed to read")
+	}
+
+	a.files[name] = checksum(content)
+
+	if ext := filepath.Ext(name); ext != ".yaml" && ext != ".yml" {
+		return nil
+	}
+
+	doc := appArchiveDoc{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		// not every file in the archive is a single kubernetes resource
+		return nil
+	}
+
+	switch doc.Kind {
+	case "Installation":
+		a.versionLabel = doc.Spec.VersionLabel
+		a.sequence = doc.Spec.UpdateCursor
+	case "Config":
+		for _, group := range doc.Spec.Groups {
+			for _, item := range group.Items {
+				name, _ := item["name"].(string)
+				if name == "" {
+					continue
+				}
+				b, err := yaml.Marshal(item)
+				if err != nil {
+					return errors.Wrapf(err, "failed to marshal config item %s", name)
+				}
+				a.configItems[name] = checksum(b)
+			}
+		}
+	case "ConfigValues":
+		for name, value := range doc.Spec.Values {
+			b, err := yaml.Marshal(value)
+			if err != nil {
+				return errors.Wrapf(err, "failed to marshal config value %s", name)
+			}
+			a.configValues[name] = checksum(b)
+		}
+	}
+
+	return nil
+}
+
+// readAppArchives reads the app version archives collected for each app.
+// The archives are collected as tar files that can be nested in the tar of the copy collector.
+func readAppArchives(bundleDir string) (map[string]*appArchive, error) {
+	apps := map[string]*appArchive{}
+
+	root := filepath.Join(bundleDir, filepath.FromSlash(appArchivesDir))
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return apps, nil
+		}
+		return nil, errors.Wrap(err, "failed to read dir")
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		appDir := filepath.Join(root, entry.Name())
+		archive := newAppArchive()
+		err := filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return errors.Wrap(err, "failed to open file")
+			}
+			defer f.Close()
+
+			if filepath.Ext(path) == ".tar" {
+				return readTarFiles(f, archive)
+			}
+
+			relPath, err := filepath.Rel(appDir, path)
+			if err != nil {
+				return err
+			}
+			return archive.addFile(filepath.ToSlash(relPath), f)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read archive of app %s", entry.Name())
+		}
+
+		apps[entry.Name()] = archive
+	}
+
+	return apps, nil
+}
+
+func readTarFiles(r io.Reader, archive *appArchive) error {
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read tar")
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if filepath.Ext(header.Name) == ".tar" {
+			if err := readTarFiles(tarReader, archive); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := archive.addFile(strings.TrimPrefix(header.Name, "/"), tarReader); err != nil {
+			return errors.Wrapf(err, "failed to read %s", header.Name)
+		}
+	}
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func unionKeys(maps ...interface{}) []string {
+	keys := map[string]bool{}
+	for _, m := range maps {
+		for _, key := range reflect.ValueOf(m).MapKeys() {
+			keys[key.String()] = true
+		}
+	}
+
+	sorted := []string{}
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
@@ -0,0 +1,148 @@
+package supportbundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	kotssupportbundletypes "github.com/replicatedhq/kots/pkg/api/supportbundle/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBundleFiles(t *testing.T, files map[string][]byte) string {
+	dir, err := ioutil.TempDir("", "kots")
+	require.NoError(t, err)
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, content, 0644))
+	}
+
+	return dir
+}
+
+func appArchiveTar(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	tarWriter := tar.NewWriter(&b)
+	for name, content := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tarWriter.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	return b.Bytes()
+}
+
+func Test_compareBundleDirs(t *testing.T) {
+	baseDir := writeBundleFiles(t, map[string][]byte{
+		"support-bundle-1/cluster-resources/deployments/default.json": []byte(`{"items": [
+			{"metadata": {"name": "web", "namespace": "default", "resourceVersion": "1", "managedFields": [{"manager": "kubectl"}]}, "spec": {"replicas": 2}, "status": {"readyReplicas": 2}},
+			{"metadata": {"name": "worker", "namespace": "default", "resourceVersion": "1"}, "spec": {"replicas": 1}},
+			{"metadata": {"name": "cron", "namespace": "default", "resourceVersion": "1"}, "spec": {"replicas": 1}}
+		]}`),
+		"support-bundle-1/cluster-resources/events/default.json": []byte(`{"items": [{"metadata": {"name": "event-1"}}]}`),
+		"support-bundle-1/cluster-resources/nodes.json": []byte(`{"items": [
+			{"metadata": {"name": "node-1"}, "status": {"conditions": [{"type": "Ready", "status": "True"}, {"type": "DiskPressure", "status": "False"}]}}
+		]}`),
+		"support-bundle-1/web/web-abc/web.log":       []byte("starting\nlistening on :3000\n"),
+		"support-bundle-1/worker/worker-abc/job.log": []byte("error: retrying\n"),
+		"support-bundle-1/kots/admin-console/app/my-app/kotsadm-abc/kotsadm/my-app.tar": appArchiveTar(t, map[string]string{
+			"/config.yaml":                "kind: Config\nspec:\n  groups:\n  - items:\n    - name: hostname\n      type: text\n    - name: replicas\n      type: text\n      default: \"1\"\n    - name: tls\n      type: bool\n",
+			"/application.yaml":           "kind: Application\n",
+			"/old.yaml":                   "kind: ConfigMap\n",
+			"/userdata/installation.yaml": "kind: Installation\nspec:\n  updateCursor: \"12\"\n  versionLabel: 1.0.0\n",
+			"/userdata/config.yaml":       "kind: ConfigValues\nspec:\n  values:\n    hostname:\n      value: abc\n    replicas:\n      value: def\n    tls:\n      value: ghi\n",
+		}),
+	})
+	defer os.RemoveAll(baseDir)
+
+	targetDir := writeBundleFiles(t, map[string][]byte{
+		"support-bundle-2/cluster-resources/deployments/default.json": []byte(`[
+			{"metadata": {"name": "web", "namespace": "default", "resourceVersion": "2", "managedFields": [{"manager": "kotsadm"}]}, "spec": {"replicas": 2}, "status": {"readyReplicas": 0}},
+			{"metadata": {"name": "worker", "namespace": "default", "resourceVersion": "2"}, "spec": {"replicas": 3}},
+			{"metadata": {"name": "api", "namespace": "default", "resourceVersion": "2"}, "spec": {"replicas": 1}}
+		]`),
+		"support-bundle-2/cluster-resources/events/default.json": []byte(`{"items": [{"metadata": {"name": "event-2"}}]}`),
+		"support-bundle-2/cluster-resources/nodes.json": []byte(`{"items": [
+			{"metadata": {"name": "node-1"}, "status": {"conditions": [{"type": "Ready", "status": "False"}, {"type": "DiskPressure", "status": "False"}]}},
+			{"metadata": {"name": "node-2"}, "status": {"conditions": [{"type": "Ready", "status": "True"}]}}
+		]}`),
+		"support-bundle-2/web/web-abc/web.log":       []byte("starting\npanic: nil pointer dereference\nERROR exiting\n"),
+		"support-bundle-2/worker/worker-abc/job.log": []byte("error: retrying\nerror: retrying\n"),
+		"support-bundle-2/kots/admin-console/app/my-app/kotsadm-def/kotsadm/my-app.tar": appArchiveTar(t, map[string]string{
+			"/config.yaml":                "kind: Config\nspec:\n  groups:\n  - items:\n    - name: hostname\n      type: text\n    - name: replicas\n      type: text\n      default: \"3\"\n    - name: database\n      type: text\n",
+			"/application.yaml":           "kind: Application\n",
+			"/new.yaml":                   "kind: Secret\n",
+			"/userdata/installation.yaml": "kind: Installation\nspec:\n  updateCursor: \"15\"\n  versionLabel: 1.1.0\n",
+			"/userdata/config.yaml":       "kind: ConfigValues\nspec:\n  values:\n    hostname:\n      value: xyz\n    replicas:\n      value: def\n    database:\n      value: jkl\n",
+		}),
+	})
+	defer os.RemoveAll(targetDir)
+
+	baseAnalysis := &types.SupportBundleAnalysis{
+		Insights: []types.SupportBundleInsight{
+			{Key: "kotsadm-api", Severity: "debug", Primary: "Admin Console API"},
+			{Key: "k8s-version", Severity: "debug", Primary: "Kubernetes version"},
+		},
+	}
+	targetAnalysis := &types.SupportBundleAnalysis{
+		Insights: []types.SupportBundleInsight{
+			{Key: "kotsadm-api", Severity: "error", Primary: "Admin Console API", Detail: "There are no replicas of the Admin Console API running and ready"},
+			{Key: "k8s-version", Severity: "debug", Primary: "Kubernetes version"},
+			{Key: "ceph-status", Severity: "warn", Primary: "Ceph status"},
+		},
+	}
+
+	comparison, err := compareBundleDirs(bundleRoot(baseDir), bundleRoot(targetDir), baseAnalysis, targetAnalysis)
+	require.NoError(t, err)
+
+	assert.Equal(t, []kotssupportbundletypes.AnalyzerChange{
+		{Key: "ceph-status", Title: "Ceph status", BaseSeverity: "", TargetSeverity: "warn"},
+		{Key: "kotsadm-api", Title: "Admin Console API", BaseSeverity: "debug", TargetSeverity: "error", Detail: "There are no replicas of the Admin Console API running and ready"},
+	}, comparison.Analyzers)
+
+	assert.Equal(t, []kotssupportbundletypes.ResourceChange{
+		{Kind: "deployments", Namespace: "default", Name: "api", Change: kotssupportbundletypes.ChangeAdded},
+		{Kind: "deployments", Namespace: "default", Name: "cron", Change: kotssupportbundletypes.ChangeRemoved},
+		{Kind: "deployments", Namespace: "default", Name: "worker", Change: kotssupportbundletypes.ChangeModified},
+	}, comparison.Resources)
+
+	assert.Equal(t, []kotssupportbundletypes.NodeConditionChange{
+		{Node: "node-1", Condition: "Ready", BaseStatus: "True", TargetStatus: "False"},
+		{Node: "node-2", Condition: "Ready", BaseStatus: "", TargetStatus: "True"},
+	}, comparison.Nodes)
+
+	assert.Equal(t, []kotssupportbundletypes.LogErrors{
+		{Path: "web/web-abc/web.log", ErrorCount: 2, ErrorLines: []string{"panic: nil pointer dereference", "ERROR exiting"}},
+	}, comparison.Logs)
+
+	assert.Equal(t, []kotssupportbundletypes.AppArchiveChange{
+		{App: "my-app", Path: "config.yaml", Change: kotssupportbundletypes.ChangeModified},
+		{App: "my-app", Path: "new.yaml", Change: kotssupportbundletypes.ChangeAdded},
+		{App: "my-app", Path: "old.yaml", Change: kotssupportbundletypes.ChangeRemoved},
+		{App: "my-app", Path: "userdata/config.yaml", Change: kotssupportbundletypes.ChangeModified},
+		{App: "my-app", Path: "userdata/installation.yaml", Change: kotssupportbundletypes.ChangeModified},
+	}, comparison.AppArchives)
+
+	assert.Equal(t, []kotssupportbundletypes.AppVersionChange{
+		{App: "my-app", BaseVersionLabel: "1.0.0", TargetVersionLabel: "1.1.0", BaseSequence: "12", TargetSequence: "15"},
+	}, comparison.AppVersions)
+
+	assert.Equal(t, []kotssupportbundletypes.ConfigItemChange{
+		{App: "my-app", Key: "database", Change: kotssupportbundletypes.ChangeAdded},
+		{App: "my-app", Key: "hostname", Change: kotssupportbundletypes.ChangeModified},
+		{App: "my-app", Key: "replicas", Change: kotssupportbundletypes.ChangeModified},
+		{App: "my-app", Key: "tls", Change: kotssupportbundletypes.ChangeRemoved},
+	}, comparison.ConfigItems)
+}
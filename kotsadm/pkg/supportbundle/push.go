@@ -0,0 +1,269 @@
+package supportbundle
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+	"github.com/replicatedhq/kots/kotsadm/pkg/store"
+	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrNoDestination is returned when a bundle is pushed but its app has no destination
+var ErrNoDestination = errors.New("no support bundle destination is configured for the app")
+
+// httpsClient sends bundles to https destinations, tests replace it with the client of a local server
+var httpsClient = http.DefaultClient
+
+// Push starts pushing the bundle to the destination of its app, the status of the push is tracked on the bundle
+func Push(bundleID string) error {
+	bundle, err := store.GetStore().GetSupportBundle(bundleID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get support bundle")
+	}
+
+	destination, err := store.GetStore().GetSupportBundleDestination(bundle.AppID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get support bundle destination")
+	}
+	if destination == nil {
+		return ErrNoDestination
+	}
+
+	if err := store.GetStore().SetSupportBundlePushStatus(bundle.ID, types.PushStatusPushing, ""); err != nil {
+		return errors.Wrap(err, "failed to set push status")
+	}
+
+	go func() {
+		status, pushError := types.PushStatusPushed, ""
+		if err := pushBundle(bundle, destination); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to push support bundle %s", bundle.ID))
+			status, pushError = types.PushStatusFailed, errors.Cause(err).Error()
+		}
+		if err := store.GetStore().SetSupportBundlePushStatus(bundle.ID, status, pushError); err != nil {
+			logger.Error(errors.Wrap(err, "failed to set push status"))
+		}
+	}()
+
+	return nil
+}
+
+// FailInterruptedPushes fails the pushes that were in progress when kotsadm stopped, they are never completed
+func FailInterruptedPushes() error {
+	db := persistence.MustGetPGSession()
+	query := `update supportbundle set push_status = $1, push_error = $2 where push_status = $3`
+
+	_, err := db.Exec(query, types.PushStatusFailed, "the push was interrupted", types.PushStatusPushing)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec")
+	}
+
+	return nil
+}
+
+// pushIfConfigured pushes a new bundle when its app pushes every bundle automatically
+func pushIfConfigured(bundleID string, appID string) error {
+	destination, err := store.GetStore().GetSupportBundleDestination(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get support bundle destination")
+	}
+	if destination == nil || !destination.AutoPush {
+		return nil
+	}
+
+	return Push(bundleID)
+}
+
+func pushBundle(bundle *types.SupportBundle, destination *types.Destination) error {
+	a, err := store.GetStore().GetApp(bundle.AppID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get app")
+	}
+
+	archivePath, err := store.GetStore().GetSupportBundleArchive(bundle.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get support bundle archive")
+	}
+	defer os.RemoveAll(archivePath)
+
+	logger.Debug("pushing support bundle",
+		zap.String("bundleID", bundle.ID),
+		zap.String("appID", bundle.AppID))
+
+	return pushArchive(destination, archivePath, pushedBundleName(a.Slug, bundle.ID))
+}
+
+func pushedBundleName(appSlug string, bundleID string) string {
+	return fmt.Sprintf("%s-supportbundle-%s.tar.gz", appSlug, bundleID)
+}
+
+func pushArchive(destination *types.Destination, archivePath string, name string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open archive")
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat archive")
+	}
+
+	switch {
+	case destination.S3 != nil:
+		return errors.Wrap(pushToS3(destination.S3, f, name), "failed to push to s3")
+	case destination.SFTP != nil:
+		return errors.Wrap(pushToSFTP(destination.SFTP, f, name), "failed to push to sftp")
+	case destination.HTTPS != nil:
+		return errors.Wrap(pushToHTTPS(destination.HTTPS, f, fi.Size(), name), "failed to push to https")
+	}
+
+	return errors.New("no valid destination found")
+}
+
+func pushToS3(destination *types.S3Destination, r io.Reader, name string) error {
+	// s3 compatible object stores usually ignore the region, but the sdk requires one
+	region := destination.Region
+	if region == "" && destination.Endpoint != "" {
+		region = "us-east-1"
+	}
+
+	s3Config := &aws.Config{
+		Region: aws.String(region),
+	}
+	if destination.Endpoint != "" {
+		s3Config.Endpoint = aws.String(destination.Endpoint)
+		s3Config.S3ForcePathStyle = aws.Bool(true)
+	}
+	if destination.AccessKeyID != "" && destination.SecretAccessKey != "" {
+		s3Config.Credentials = credentials.NewStaticCredentials(destination.AccessKeyID, destination.SecretAccessKey, "")
+	}
+
+	uploader := s3manager.NewUploader(session.New(s3Config))
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(destination.Bucket),
+		Key:    aws.String(path.Join(destination.Path, name)),
+		Body:   r,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to upload")
+	}
+
+	return nil
+}
+
+func pushToSFTP(destination *types.SFTPDestination, r io.Reader, name string) error {
+	clientConfig, err := sftpClientConfig(destination)
+	if err != nil {
+		return errors.Wrap(err, "failed to create client config")
+	}
+
+	port := destination.Port
+	if port == 0 {
+		port = 22
+	}
+
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(destination.Host, strconv.Itoa(port)), clientConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect")
+	}
+	defer conn.Close()
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return errors.Wrap(err, "failed to create sftp client")
+	}
+	defer client.Close()
+
+	if destination.Path != "" {
+		if err := client.MkdirAll(destination.Path); err != nil {
+			return errors.Wrap(err, "failed to create path")
+		}
+	}
+
+	f, err := client.Create(path.Join(destination.Path, name))
+	if err != nil {
+		return errors.Wrap(err, "failed to create file")
+	}
+	defer f.Close()
+
+	if _, err := f.ReadFrom(r); err != nil {
+		return errors.Wrap(err, "failed to write file")
+	}
+
+	return nil
+}
+
+func sftpClientConfig(destination *types.SFTPDestination) (*ssh.ClientConfig, error) {
+	if destination.HostKey == "" {
+		return nil, errors.New("host key is required")
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(destination.HostKey))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse host key")
+	}
+
+	clientConfig := &ssh.ClientConfig{
+		User:            destination.Username,
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         30 * time.Second,
+	}
+
+	if destination.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(destination.PrivateKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse private key")
+		}
+		clientConfig.Auth = append(clientConfig.Auth, ssh.PublicKeys(signer))
+	}
+	if destination.Password != "" {
+		clientConfig.Auth = append(clientConfig.Auth, ssh.Password(destination.Password))
+	}
+
+	return clientConfig, nil
+}
+
+func pushToHTTPS(destination *types.HTTPSDestination, r io.Reader, size int64, name string) error {
+	url := destination.URL
+	if strings.HasSuffix(url, "/") {
+		url += name
+	}
+
+	req, err := http.NewRequest("PUT", url, r)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/gzip")
+	for name, value := range destination.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpsClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package supportbundle
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func Test_pushToHTTPS(t *testing.T) {
+	var gotPath, gotAuthorization, gotBody string
+	var gotContentLength int64
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		gotPath, gotAuthorization, gotBody, gotContentLength = r.URL.Path, r.Header.Get("Authorization"), string(body), r.ContentLength
+		if r.Method != "PUT" || r.URL.Path == "/forbidden/my-app.tar.gz" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	defaultHTTPSClient := httpsClient
+	httpsClient = server.Client()
+	defer func() {
+		httpsClient = defaultHTTPSClient
+	}()
+
+	destination := &types.HTTPSDestination{
+		URL:     server.URL + "/bundles/",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	}
+	err := pushToHTTPS(destination, strings.NewReader("bundle"), 6, "my-app.tar.gz")
+	require.NoError(t, err)
+	assert.Equal(t, "/bundles/my-app.tar.gz", gotPath)
+	assert.Equal(t, "Bearer token", gotAuthorization)
+	assert.Equal(t, "bundle", gotBody)
+	assert.Equal(t, int64(6), gotContentLength)
+
+	destination.URL = server.URL + "/upload"
+	err = pushToHTTPS(destination, strings.NewReader("bundle"), 6, "my-app.tar.gz")
+	require.NoError(t, err)
+	assert.Equal(t, "/upload", gotPath)
+
+	destination.URL = server.URL + "/forbidden/"
+	err = pushToHTTPS(destination, strings.NewReader("bundle"), 6, "my-app.tar.gz")
+	assert.Error(t, err)
+}
+
+func Test_pushToSFTP(t *testing.T) {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "kots" && string(password) == "password" {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	handlers := sftp.InMemHandler()
+	go serveSFTP(listener, serverConfig, handlers)
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	destination := &types.SFTPDestination{
+		Host:     host,
+		Port:     portNumber,
+		Username: "kots",
+		Password: "password",
+		HostKey:  string(ssh.MarshalAuthorizedKey(hostSigner.PublicKey())),
+		Path:     "/bundles",
+	}
+
+	err = pushToSFTP(destination, strings.NewReader("bundle"), "my-app.tar.gz")
+	require.NoError(t, err)
+
+	r, err := handlers.FileGet.Fileread(sftp.NewRequest("Get", "/bundles/my-app.tar.gz"))
+	require.NoError(t, err)
+	content := make([]byte, 6)
+	_, err = r.ReadAt(content, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "bundle", string(content))
+
+	// the server is verified with its host key
+	_, otherHostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherHostSigner, err := ssh.NewSignerFromKey(otherHostKey)
+	require.NoError(t, err)
+	destination.HostKey = string(ssh.MarshalAuthorizedKey(otherHostSigner.PublicKey()))
+	err = pushToSFTP(destination, strings.NewReader("bundle"), "my-app.tar.gz")
+	assert.Error(t, err)
+
+	destination.HostKey = ""
+	err = pushToSFTP(destination, strings.NewReader("bundle"), "my-app.tar.gz")
+	assert.Error(t, err)
+}
+
+func serveSFTP(listener net.Listener, serverConfig *ssh.ServerConfig, handlers sftp.Handlers) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(requests)
+
+			for newChannel := range channels {
+				if newChannel.ChannelType() != "session" {
+					newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+					continue
+				}
+				channel, requests, err := newChannel.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					for req := range requests {
+						// the payload of a subsystem request is the length prefixed name of the subsystem
+						isSFTP := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+						req.Reply(isSFTP, nil)
+						if isSFTP {
+							go func() {
+								sftp.NewRequestServer(channel, handlers).Serve()
+								channel.Close()
+							}()
+						}
+					}
+				}()
+			}
+		}()
+	}
+}
+
+func TestDestinationSecrets(t *testing.T) {
+	current := &types.Destination{
+		SFTP: &types.SFTPDestination{
+			Host:       "sftp.example.com",
+			Username:   "kots",
+			Password:   "password",
+			PrivateKey: "",
+			HostKey:    "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAhxb3dJi4sEx6vUkSaZFJuYS8rMp1NjqS4f+qGwpbvx",
+		},
+	}
+	require.NoError(t, current.Validate())
+
+	masked := current.Masked()
+	assert.Equal(t, types.SecretMask, masked.SFTP.Password)
+	assert.Equal(t, "", masked.SFTP.PrivateKey)
+	assert.Equal(t, "password", current.SFTP.Password)
+
+	// the masked password of the destination that was returned by the api is kept on update
+	updated := masked
+	updated.SFTP.Path = "/bundles"
+	updated.KeepSecrets(current)
+	assert.Equal(t, "password", updated.SFTP.Password)
+	assert.Equal(t, "/bundles", updated.SFTP.Path)
+
+	https := &types.Destination{
+		HTTPS: &types.HTTPSDestination{
+			URL:     "https://example.com/bundles/",
+			Headers: map[string]string{"Authorization": "Bearer token"},
+		},
+	}
+	require.NoError(t, https.Validate())
+	updatedHTTPS := https.Masked()
+	assert.Equal(t, types.SecretMask, updatedHTTPS.HTTPS.Headers["Authorization"])
+	updatedHTTPS.KeepSecrets(https)
+	assert.Equal(t, "Bearer token", updatedHTTPS.HTTPS.Headers["Authorization"])
+
+	assert.Error(t, (&types.Destination{}).Validate())
+	assert.Error(t, (&types.Destination{SFTP: &types.SFTPDestination{Host: "sftp.example.com", Username: "kots", Password: "password"}}).Validate())
+	assert.Error(t, (&types.Destination{HTTPS: &types.HTTPSDestination{URL: "http://example.com"}}).Validate())
+	assert.Error(t, (&types.Destination{S3: &types.S3Destination{Bucket: "bundles", Region: "us-east-1"}, HTTPS: https.HTTPS}).Validate())
+}
@@ -14,19 +14,21 @@ import (
 	"strings"
 	"time"
 
-	"github.com/mholt/archiver"
 	"github.com/pkg/errors"
 	apptypes "github.com/replicatedhq/kots/kotsadm/pkg/app/types"
 	"github.com/replicatedhq/kots/kotsadm/pkg/kurl"
 	"github.com/replicatedhq/kots/kotsadm/pkg/license"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
 	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+	"github.com/replicatedhq/kots/kotsadm/pkg/redact"
 	"github.com/replicatedhq/kots/kotsadm/pkg/registry"
 	"github.com/replicatedhq/kots/kotsadm/pkg/render/helper"
 	"github.com/replicatedhq/kots/kotsadm/pkg/snapshot"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
 	"github.com/replicatedhq/kots/kotsadm/pkg/supportbundle/types"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
 	"github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
+	redacttypes "github.com/replicatedhq/kots/pkg/api/redact/types"
 	kotstypes "github.com/replicatedhq/kots/pkg/kotsadm/types"
 	"github.com/replicatedhq/kots/pkg/kotsutil"
 	"github.com/replicatedhq/kots/pkg/template"
@@ -45,6 +47,8 @@ import (
 
 const (
 	SpecDataKey = "support-bundle-spec"
+	// BundleSpecLabel is set on the spec secrets of bundles that are collected by the operator
+	BundleSpecLabel = "kots.io/supportbundle-id"
 )
 
 // Collect will queue collection of a new support bundle
@@ -72,32 +76,78 @@ func CreateBundle(requestedID string, appID string, archivePath string) (*types.
 		return nil, errors.Wrap(err, "failed to marshal tree index")
 	}
 
-	return store.GetStore().CreateSupportBundle(id, appID, archivePath, marshalledTree)
-}
+	supportBundle, err := store.GetStore().CreateSupportBundle(id, appID, archivePath, marshalledTree)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create support bundle")
+	}
 
-// GetFilesContents will return the file contents for filenames matching the filenames
-// parameter.
-func GetFilesContents(bundleID string, filenames []string) (map[string][]byte, error) {
-	bundleArchive, err := store.GetStore().GetSupportBundleArchive(bundleID)
+	// bundles that were collected by the operator are partial when some of their collectors failed
+	collection, err := completeCollection(id)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get bundle")
+		return nil, errors.Wrap(err, "failed to complete support bundle collection")
+	}
+	if collection != nil && collection.Status == types.CollectionStatusPartial {
+		supportBundle.IsPartial = true
 	}
-	defer os.RemoveAll(bundleArchive)
 
-	tmpDir, err := ioutil.TempDir("", "kots")
+	// bundles that were collected automatically are linked to the sequence that failed
+	pendingSupportBundle, err := store.GetStore().GetPendingSupportBundle(id)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create tmp dir")
+		return nil, errors.Wrap(err, "failed to get pending support bundle")
+	}
+	if pendingSupportBundle != nil && pendingSupportBundle.Sequence != nil {
+		if err := store.GetStore().SetSupportBundleTrigger(id, *pendingSupportBundle.Sequence, pendingSupportBundle.Reason); err != nil {
+			return nil, errors.Wrap(err, "failed to set support bundle trigger")
+		}
+		if err := ClearPending(id); err != nil {
+			return nil, errors.Wrap(err, "failed to clear pending support bundle")
+		}
+		supportBundle.Sequence = pendingSupportBundle.Sequence
+		supportBundle.Reason = pendingSupportBundle.Reason
+	}
+
+	if err := deleteBundleSpec(id); err != nil {
+		logger.Error(errors.Wrap(err, "failed to delete support bundle spec"))
+	}
+
+	// a bundle that can't be pushed is still created, the failure is tracked on the bundle
+	if err := pushIfConfigured(id, appID); err != nil {
+		logger.Error(errors.Wrap(err, "failed to push support bundle"))
+	}
+
+	return supportBundle, nil
+}
+
+// PreviewRedactions runs a redactor against a support bundle without saving the redactor
+func PreviewRedactions(bundleID string, redactor *troubleshootv1beta2.Redactor) (*redacttypes.RedactPreview, error) {
+	tmpDir, err := extractBundle(bundleID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to extract bundle")
 	}
 	defer os.RemoveAll(tmpDir)
 
-	tarGz := archiver.TarGz{
-		Tar: &archiver.Tar{
-			ImplicitTopLevelFolder: false,
-		},
+	return redact.Preview(bundleRoot(tmpDir), redactor)
+}
+
+// PreviewRedactionsForArchive runs a redactor against a support bundle archive that is not in the store
+func PreviewRedactionsForArchive(archivePath string, redactor *troubleshootv1beta2.Redactor) (*redacttypes.RedactPreview, error) {
+	tmpDir, err := extractArchive(archivePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to extract archive")
 	}
-	if err := tarGz.Unarchive(bundleArchive, tmpDir); err != nil {
-		return nil, errors.Wrap(err, "failed to unarchive")
+	defer os.RemoveAll(tmpDir)
+
+	return redact.Preview(bundleRoot(tmpDir), redactor)
+}
+
+// GetFilesContents will return the file contents for filenames matching the filenames
+// parameter.
+func GetFilesContents(bundleID string, filenames []string) (map[string][]byte, error) {
+	tmpDir, err := extractBundle(bundleID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to extract bundle")
 	}
+	defer os.RemoveAll(tmpDir)
 
 	files := map[string][]byte{}
 	for _, filename := range filenames {
@@ -124,6 +174,20 @@ func ClearPending(id string) error {
 	return nil
 }
 
+// MarkPendingStarted keeps a pending bundle that was sent to the operator until it's uploaded,
+// so that the uploaded bundle can be linked to what triggered it
+func MarkPendingStarted(id string) error {
+	db := persistence.MustGetPGSession()
+	query := `update pending_supportbundle set started_at = $1 where id = $2`
+
+	_, err := db.Exec(query, time.Now(), id)
+	if err != nil {
+		return errors.Wrap(err, "failed to exec")
+	}
+
+	return nil
+}
+
 func GetSpecSecretName(appSlug string) string {
 	return fmt.Sprintf("kotsadm-%s-supportbundle", appSlug)
 }
@@ -132,6 +196,37 @@ func GetSpecURI(appSlug string) string {
 	return fmt.Sprintf("secret/%s/%s", os.Getenv("POD_NAMESPACE"), GetSpecSecretName(appSlug))
 }
 
+// GetBundleSpecSecretName is the secret with the spec that collects the bundle with the given id
+func GetBundleSpecSecretName(appSlug string, bundleID string) string {
+	return fmt.Sprintf("kotsadm-%s-supportbundle-%s", appSlug, strings.ToLower(bundleID))
+}
+
+func GetBundleSpecURI(appSlug string, bundleID string) string {
+	return fmt.Sprintf("secret/%s/%s", os.Getenv("POD_NAMESPACE"), GetBundleSpecSecretName(appSlug, bundleID))
+}
+
+// deleteBundleSpec deletes the spec secret of the bundle with the given id, if it has one
+func deleteBundleSpec(bundleID string) error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create clientset")
+	}
+
+	err = clientset.CoreV1().Secrets(os.Getenv("POD_NAMESPACE")).DeleteCollection(context.TODO(), metav1.DeleteOptions{}, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", BundleSpecLabel, strings.ToLower(bundleID)),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete support bundle secret")
+	}
+
+	return nil
+}
+
 func GetBundleCommand(appSlug string) []string {
 	comamnd := []string{
 		"curl https://krew.sh/support-bundle | bash",
@@ -142,6 +237,11 @@ func GetBundleCommand(appSlug string) []string {
 }
 
 func CreateRenderedSpec(appID string, sequence int64, origin string, inCluster bool, kotsKinds *kotsutil.KotsKinds) error {
+	return CreateRenderedSpecForBundle("", appID, sequence, origin, inCluster, kotsKinds)
+}
+
+// CreateRenderedSpecForBundle renders a spec that uploads the collected bundle with the given id, a random id is used when it's empty
+func CreateRenderedSpecForBundle(bundleID string, appID string, sequence int64, origin string, inCluster bool, kotsKinds *kotsutil.KotsKinds) error {
 	builtBundle := kotsKinds.SupportBundle.DeepCopy()
 	if builtBundle == nil {
 		builtBundle = &troubleshootv1beta2.SupportBundle{
@@ -167,7 +267,7 @@ func CreateRenderedSpec(appID string, sequence int64, origin string, inCluster b
 		return errors.Wrap(err, "failed to get app")
 	}
 
-	err = injectDefaults(app, origin, inCluster, builtBundle)
+	err = injectDefaults(app, bundleID, origin, inCluster, builtBundle)
 	if err != nil {
 		return errors.Wrap(err, "failed to inject defaults")
 	}
@@ -219,7 +319,15 @@ func CreateRenderedSpec(appID string, sequence int64, origin string, inCluster b
 		return errors.Wrap(err, "failed to create clientset")
 	}
 
+	// bundles with an id get their own secret, the shared secret is the spec that the ui command collects with
 	secretName := GetSpecSecretName(app.Slug)
+	labels := kotstypes.GetKotsadmLabels()
+	if bundleID != "" {
+		secretName = GetBundleSpecSecretName(app.Slug, bundleID)
+		labels = kotstypes.GetKotsadmLabels(map[string]string{
+			BundleSpecLabel: strings.ToLower(bundleID),
+		})
+	}
 
 	existingSecret, err := clientset.CoreV1().Secrets(os.Getenv("POD_NAMESPACE")).Get(context.TODO(), secretName, metav1.GetOptions{})
 	if err != nil && !kuberneteserrors.IsNotFound(err) {
@@ -233,7 +341,7 @@ func CreateRenderedSpec(appID string, sequence int64, origin string, inCluster b
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      secretName,
 				Namespace: os.Getenv("POD_NAMESPACE"),
-				Labels:    kotstypes.GetKotsadmLabels(),
+				Labels:    labels,
 			},
 			Data: map[string][]byte{
 				SpecDataKey: renderedSpec,
@@ -252,7 +360,7 @@ func CreateRenderedSpec(appID string, sequence int64, origin string, inCluster b
 		existingSecret.Data = map[string][]byte{}
 	}
 	existingSecret.Data[SpecDataKey] = renderedSpec
-	existingSecret.ObjectMeta.Labels = kotstypes.GetKotsadmLabels()
+	existingSecret.ObjectMeta.Labels = labels
 
 	_, err = clientset.CoreV1().Secrets(os.Getenv("POD_NAMESPACE")).Update(context.TODO(), existingSecret, metav1.UpdateOptions{})
 	if err != nil {
@@ -262,13 +370,16 @@ func CreateRenderedSpec(appID string, sequence int64, origin string, inCluster b
 	return nil
 }
 
-func injectDefaults(app *apptypes.App, origin string, inCluster bool, supportBundle *troubleshootv1beta2.SupportBundle) error {
+func injectDefaults(app *apptypes.App, bundleID string, origin string, inCluster bool, supportBundle *troubleshootv1beta2.SupportBundle) error {
 	populateNamespaces(supportBundle)
 
 	// determine an upload URL
 	var uploadURL string
 	var redactURL string
-	randomBundleID := strings.ToLower(rand.String(32))
+	randomBundleID := bundleID
+	if randomBundleID == "" {
+		randomBundleID = strings.ToLower(rand.String(32))
+	}
 	if origin != "" {
 		uploadURL = fmt.Sprintf("%s/api/v1/troubleshoot/%s/%s", origin, app.ID, randomBundleID)
 		redactURL = fmt.Sprintf("%s/api/v1/troubleshoot/supportbundle/%s/redactions", origin, randomBundleID)
@@ -281,6 +392,7 @@ func injectDefaults(app *apptypes.App, origin string, inCluster bool, supportBun
 	}
 
 	addDefaultTroubleshoot(supportBundle, app)
+	setCollectorTimeouts(supportBundle)
 
 	supportBundle.Spec.AfterCollection = []*troubleshootv1beta2.AfterCollection{
 		{
@@ -757,6 +869,10 @@ func makeAppVersionArchiveCollector(app *apptypes.App, dirPrefix string) (*troub
 		return nil, errors.Wrapf(err, "failed to walk archive dir %s", archivePath)
 	}
 
+	if err := writeAppVersionUserdata(tarWriter, tempPath); err != nil {
+		return nil, errors.Wrap(err, "failed to write userdata")
+	}
+
 	return &troubleshootv1beta2.Collect{
 		Copy: &troubleshootv1beta2.Copy{
 			CollectorMeta: troubleshootv1beta2.CollectorMeta{
@@ -773,6 +889,91 @@ func makeAppVersionArchiveCollector(app *apptypes.App, dirPrefix string) (*troub
 	}, nil
 }
 
+// writeAppVersionUserdata writes the installation and the config values of the archive for support bundle comparisons.
+// The installation is written without the encryption key and the images, and the config values are checksummed.
+func writeAppVersionUserdata(tarWriter *tar.Writer, archiveDir string) error {
+	kotsKinds, err := kotsutil.LoadKotsKindsFromPath(archiveDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to load kotskinds")
+	}
+
+	s := serializer.NewYAMLSerializer(serializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	installation := kotsv1beta1.Installation{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "kots.io/v1beta1",
+			Kind:       "Installation",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: kotsKinds.Installation.Name,
+		},
+		Spec: kotsv1beta1.InstallationSpec{
+			UpdateCursor: kotsKinds.Installation.Spec.UpdateCursor,
+			ChannelName:  kotsKinds.Installation.Spec.ChannelName,
+			VersionLabel: kotsKinds.Installation.Spec.VersionLabel,
+		},
+	}
+	var b bytes.Buffer
+	if err := s.Encode(&installation, &b); err != nil {
+		return errors.Wrap(err, "failed to encode installation")
+	}
+	if err := writeTarFile(tarWriter, "/userdata/installation.yaml", b.Bytes()); err != nil {
+		return errors.Wrap(err, "failed to write installation")
+	}
+
+	if kotsKinds.ConfigValues == nil {
+		return nil
+	}
+
+	configValues := kotsKinds.ConfigValues.DeepCopy()
+	configValues.APIVersion = "kots.io/v1beta1"
+	configValues.Kind = "ConfigValues"
+	for name, value := range configValues.Spec.Values {
+		configValues.Spec.Values[name] = kotsv1beta1.ConfigValue{
+			Default:        checksumValue(value.Default),
+			Value:          checksumValue(value.Value),
+			Data:           checksumValue(value.Data),
+			ValuePlaintext: checksumValue(value.ValuePlaintext),
+			DataPlaintext:  checksumValue(value.DataPlaintext),
+		}
+	}
+	b.Reset()
+	if err := s.Encode(configValues, &b); err != nil {
+		return errors.Wrap(err, "failed to encode config values")
+	}
+	if err := writeTarFile(tarWriter, "/userdata/config.yaml", b.Bytes()); err != nil {
+		return errors.Wrap(err, "failed to write config values")
+	}
+
+	return nil
+}
+
+func checksumValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return checksum([]byte(value))
+}
+
+func writeTarFile(tarWriter *tar.Writer, name string, content []byte) error {
+	err := tarWriter.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		Typeflag: tar.TypeReg,
+		ModTime:  time.Now(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to write tar header")
+	}
+
+	if _, err := tarWriter.Write(content); err != nil {
+		return errors.Wrap(err, "failed to write tar contents")
+	}
+
+	return nil
+}
+
 func makeCollectDCollectors() ([]*troubleshootv1beta2.Collect, error) {
 	collectors := []*troubleshootv1beta2.Collect{}
 
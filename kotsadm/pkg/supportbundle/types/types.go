@@ -1,7 +1,10 @@
 package types
 
 import (
+	"net/url"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 type SupportBundle struct {
@@ -15,12 +18,86 @@ type SupportBundle struct {
 	CreatedAt  time.Time  `json:"createdAt"`
 	UploadedAt *time.Time `json:"uploadedAt"`
 	IsArchived bool       `json:"isArchived"`
+	// Sequence and Reason are set on bundles that were collected automatically because the sequence failed
+	Sequence *int64 `json:"sequence,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// PushStatus is set on bundles that were pushed to the destination of the app
+	PushStatus string     `json:"pushStatus,omitempty"`
+	PushError  string     `json:"pushError,omitempty"`
+	PushedAt   *time.Time `json:"pushedAt,omitempty"`
+	// IsPartial is set on bundles that were uploaded even though some of their collectors failed or timed out
+	IsPartial        bool     `json:"isPartial"`
+	FailedCollectors []string `json:"failedCollectors,omitempty"`
 }
 
 type PendingSupportBundle struct {
 	ID        string `json:"id"`
 	AppID     string `json:"appId"`
 	ClusterID string `json:"clusterId"`
+	Sequence  *int64 `json:"sequence,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+const (
+	ReasonDeployFailed    = "deploy-failed"
+	ReasonPreflightFailed = "preflight-failed"
+	ReasonAppUnavailable  = "app-unavailable"
+)
+
+// AutoCollectPolicy decides which failures of an app collect a support bundle without waiting for a user to request one
+type AutoCollectPolicy struct {
+	DeployFailed    bool `json:"deployFailed"`
+	PreflightFailed bool `json:"preflightFailed"`
+	// UnavailableMinutes collects a bundle when the app stays unavailable for this long, 0 disables it
+	UnavailableMinutes int `json:"unavailableMinutes"`
+}
+
+func (p *AutoCollectPolicy) IsEnabled() bool {
+	return p != nil && (p.DeployFailed || p.PreflightFailed || p.UnavailableMinutes > 0)
+}
+
+const (
+	CollectionStatusCollecting = "collecting"
+	CollectionStatusCompleted  = "completed"
+	CollectionStatusPartial    = "partial"
+	CollectionStatusFailed     = "failed"
+	CollectionStatusTimedOut   = "timed-out"
+
+	CollectorStatusRunning   = "running"
+	CollectorStatusCompleted = "completed"
+	CollectorStatusFailed    = "failed"
+)
+
+// Collection is the progress of a support bundle that the operator is collecting, its id is the id of the bundle
+type Collection struct {
+	ID         string              `json:"id"`
+	AppID      string              `json:"appId"`
+	ClusterID  string              `json:"clusterId"`
+	Status     string              `json:"status"`
+	Collectors []CollectorProgress `json:"collectors"`
+	Error      string              `json:"error,omitempty"`
+	StartedAt  time.Time           `json:"startedAt"`
+	UpdatedAt  time.Time           `json:"updatedAt"`
+	FinishedAt *time.Time          `json:"finishedAt,omitempty"`
+}
+
+type CollectorProgress struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Message    string     `json:"message,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// CollectionEvent is reported by the operator for every collector that the support-bundle command starts or that fails,
+// when the collection times out, and once more when the command exits
+type CollectionEvent struct {
+	Collector       string `json:"collector,omitempty"`
+	CollectorStatus string `json:"collectorStatus,omitempty"`
+	Message         string `json:"message,omitempty"`
+	Done            bool   `json:"done,omitempty"`
+	Error           string `json:"error,omitempty"`
+	TimedOut        bool   `json:"timedOut,omitempty"`
 }
 
 type SupportBundleAnalysis struct {
@@ -50,3 +127,144 @@ type FileTreeNode struct {
 	Path     string         `json:"path"`
 	Children []FileTreeNode `json:"children,omitempty"`
 }
+
+const (
+	PushStatusPushing = "pushing"
+	PushStatusPushed  = "pushed"
+	PushStatusFailed  = "failed"
+
+	// SecretMask replaces the credentials of a destination in api responses
+	SecretMask = "***HIDDEN***"
+)
+
+// Destination is where the support bundles of an app are pushed to, exactly one of S3, SFTP or HTTPS is set
+type Destination struct {
+	// AutoPush pushes every new bundle of the app, bundles can also be pushed on demand
+	AutoPush bool              `json:"autoPush"`
+	S3       *S3Destination    `json:"s3,omitempty"`
+	SFTP     *SFTPDestination  `json:"sftp,omitempty"`
+	HTTPS    *HTTPSDestination `json:"https,omitempty"`
+}
+
+type S3Destination struct {
+	Bucket string `json:"bucket"`
+	Path   string `json:"path,omitempty"`
+	Region string `json:"region"`
+	// Endpoint is set for s3 compatible object stores such as minio
+	Endpoint        string `json:"endpoint,omitempty"`
+	AccessKeyID     string `json:"accessKeyId,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+}
+
+type SFTPDestination struct {
+	Host       string `json:"host"`
+	Port       int    `json:"port,omitempty"`
+	Username   string `json:"username"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	// HostKey is the public key of the server in authorized_keys format, the server is verified with it
+	HostKey string `json:"hostKey"`
+	Path    string `json:"path,omitempty"`
+}
+
+type HTTPSDestination struct {
+	// URL receives the bundle in a PUT request, the name of the bundle is appended when it ends with a slash
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func (d *Destination) Validate() error {
+	count := 0
+	if d.S3 != nil {
+		count++
+		if d.S3.Bucket == "" {
+			return errors.New("s3 bucket is required")
+		}
+		if d.S3.Region == "" && d.S3.Endpoint == "" {
+			return errors.New("s3 region or endpoint is required")
+		}
+	}
+	if d.SFTP != nil {
+		count++
+		if d.SFTP.Host == "" || d.SFTP.Username == "" {
+			return errors.New("sftp host and username are required")
+		}
+		if d.SFTP.Password == "" && d.SFTP.PrivateKey == "" {
+			return errors.New("sftp password or private key is required")
+		}
+		if d.SFTP.HostKey == "" {
+			return errors.New("sftp host key is required")
+		}
+	}
+	if d.HTTPS != nil {
+		count++
+		u, err := url.Parse(d.HTTPS.URL)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse url")
+		}
+		if u.Scheme != "https" || u.Host == "" {
+			return errors.New("an https url is required")
+		}
+	}
+	if count != 1 {
+		return errors.New("exactly one of s3, sftp or https is required")
+	}
+	return nil
+}
+
+// Masked returns a copy of the destination that can be returned by the api
+func (d *Destination) Masked() *Destination {
+	masked := *d
+	if d.S3 != nil {
+		s3 := *d.S3
+		s3.SecretAccessKey = maskSecret(s3.SecretAccessKey)
+		masked.S3 = &s3
+	}
+	if d.SFTP != nil {
+		sftp := *d.SFTP
+		sftp.Password = maskSecret(sftp.Password)
+		sftp.PrivateKey = maskSecret(sftp.PrivateKey)
+		masked.SFTP = &sftp
+	}
+	if d.HTTPS != nil {
+		https := *d.HTTPS
+		https.Headers = map[string]string{}
+		for name, value := range d.HTTPS.Headers {
+			https.Headers[name] = maskSecret(value)
+		}
+		masked.HTTPS = &https
+	}
+	return &masked
+}
+
+// KeepSecrets replaces the masked credentials of an updated destination with the credentials of the current destination
+func (d *Destination) KeepSecrets(current *Destination) {
+	if current == nil {
+		return
+	}
+	if d.S3 != nil && current.S3 != nil && d.S3.SecretAccessKey == SecretMask {
+		d.S3.SecretAccessKey = current.S3.SecretAccessKey
+	}
+	if d.SFTP != nil && current.SFTP != nil {
+		if d.SFTP.Password == SecretMask {
+			d.SFTP.Password = current.SFTP.Password
+		}
+		if d.SFTP.PrivateKey == SecretMask {
+			d.SFTP.PrivateKey = current.SFTP.PrivateKey
+		}
+	}
+	if d.HTTPS != nil && current.HTTPS != nil {
+		for name, value := range d.HTTPS.Headers {
+			if value == SecretMask {
+				d.HTTPS.Headers[name] = current.HTTPS.Headers[name]
+			}
+		}
+	}
+}
+
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return SecretMask
+}
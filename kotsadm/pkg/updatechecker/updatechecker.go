@@ -12,6 +12,8 @@ import (
 	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
 	"github.com/replicatedhq/kots/kotsadm/pkg/license"
 	"github.com/replicatedhq/kots/kotsadm/pkg/logger"
+	"github.com/replicatedhq/kots/kotsadm/pkg/maintenancewindow"
+	"github.com/replicatedhq/kots/kotsadm/pkg/preflight"
 	"github.com/replicatedhq/kots/kotsadm/pkg/reporting"
 	"github.com/replicatedhq/kots/kotsadm/pkg/store"
 	"github.com/replicatedhq/kots/kotsadm/pkg/upstream"
@@ -225,6 +227,18 @@ func CheckForUpdates(appID string, deploy bool, skipPreflights bool) (int64, err
 		return 0, errors.Wrap(err, "failed to update last updated at time")
 	}
 
+	// automatic deploys are only allowed during the app's maintenance windows
+	if deploy {
+		isOpen, err := maintenancewindow.IsOpenForApp(a.ID)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to check maintenance windows")
+		}
+		if !isOpen {
+			logger.Info("not deploying updates for app outside of maintenance window", zap.String("slug", a.Slug))
+			deploy = false
+		}
+	}
+
 	// if there are updates, go routine it
 	if len(updates) == 0 {
 		if !deploy {
@@ -255,6 +269,26 @@ func CheckForUpdates(appID string, deploy bool, skipPreflights bool) (int64, err
 		}
 
 		if latestVersion.Sequence != downstreamParentSequence {
+			if err := version.CheckRequiredVersions(a.ID, downstreams[0].ClusterID, latestVersion.Sequence); err != nil {
+				// the update check itself succeeded, only the automatic deploy is blocked
+				if _, ok := errors.Cause(err).(version.SkippedRequiredVersionsError); ok {
+					logger.Info("not deploying latest version", zap.String("slug", a.Slug), zap.String("reason", err.Error()))
+					return 0, nil
+				}
+				return 0, errors.Wrap(err, "failed to check required versions")
+			}
+			if err := preflight.CheckStrictPreflights(a.ID, latestVersion.Sequence); err != nil {
+				// like required versions, strict preflights only block the automatic deploy, pending checks are retried on the next update check
+				if e, ok := errors.Cause(err).(preflight.StrictPreflightsError); ok {
+					if e.Pending {
+						logger.Info("not deploying latest version yet", zap.String("slug", a.Slug), zap.String("reason", err.Error()))
+					} else {
+						logger.Info("not deploying latest version", zap.String("slug", a.Slug), zap.String("reason", err.Error()))
+					}
+					return 0, nil
+				}
+				return 0, errors.Wrap(err, "failed to check strict preflights")
+			}
 			err := version.DeployVersion(a.ID, latestVersion.Sequence)
 			if err != nil {
 				return 0, errors.Wrap(err, "failed to deploy latest version")
@@ -284,8 +318,7 @@ func CheckForUpdates(appID string, deploy bool, skipPreflights bool) (int64, err
 			}
 			// deploy latest version?
 			if deploy && index == len(updates)-1 {
-				err := version.DeployVersion(a.ID, sequence)
-				if err != nil {
+				if err := deployIfNotSkippingRequired(a.ID, sequence, skipPreflights); err != nil {
 					logger.Error(err)
 				}
 			}
@@ -294,3 +327,34 @@ func CheckForUpdates(appID string, deploy bool, skipPreflights bool) (int64, err
 
 	return availableUpdates, nil
 }
+
+// deployIfNotSkippingRequired deploys the sequence, unless that would skip a release that is marked as required
+// or its strict preflight checks did not pass
+func deployIfNotSkippingRequired(appID string, sequence int64, skipPreflights bool) error {
+	downstreams, err := store.GetStore().ListDownstreamsForApp(appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list downstreams for app")
+	}
+	if len(downstreams) == 0 {
+		return errors.New("no downstreams for app")
+	}
+
+	if err := version.CheckRequiredVersions(appID, downstreams[0].ClusterID, sequence); err != nil {
+		return errors.Wrap(err, "failed to check required versions")
+	}
+
+	// the preflight checks of the new version are still running
+	checkStrictPreflights := preflight.WaitForStrictPreflights
+	if skipPreflights {
+		checkStrictPreflights = preflight.CheckStrictPreflights
+	}
+	if err := checkStrictPreflights(appID, sequence); err != nil {
+		return errors.Wrap(err, "failed to check strict preflights")
+	}
+
+	if err := version.DeployVersion(appID, sequence); err != nil {
+		return errors.Wrap(err, "failed to deploy version")
+	}
+
+	return nil
+}
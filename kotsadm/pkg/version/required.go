@@ -0,0 +1,134 @@
+package version
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/kotsadm/pkg/downstream"
+	"github.com/replicatedhq/kots/kotsadm/pkg/persistence"
+	"github.com/replicatedhq/kots/pkg/kotsutil"
+)
+
+type requiredVersion struct {
+	Sequence     int64
+	VersionLabel string
+	IsRequired   bool
+}
+
+// SkippedRequiredVersionsError is returned when deploying a version would skip releases that are marked as required
+type SkippedRequiredVersionsError struct {
+	Target  requiredVersion
+	Skipped []requiredVersion
+}
+
+func (e SkippedRequiredVersionsError) Error() string {
+	skipped := []string{}
+	for _, v := range e.Skipped {
+		skipped = append(skipped, v.String())
+	}
+
+	return fmt.Sprintf("cannot deploy %s because it would skip required version(s) %s, deploy them in order first: %s -> %s",
+		e.Target.String(), strings.Join(skipped, ", "), strings.Join(skipped, " -> "), e.Target.String())
+}
+
+func (v requiredVersion) String() string {
+	if v.VersionLabel == "" {
+		return fmt.Sprintf("sequence %d", v.Sequence)
+	}
+	return fmt.Sprintf("%s (sequence %d)", v.VersionLabel, v.Sequence)
+}
+
+// CheckRequiredVersions returns a SkippedRequiredVersionsError if deploying the downstream sequence would skip
+// a version between it and the currently deployed one that is marked as required in its application spec.
+// Rolling back to an earlier sequence never skips a required version.
+func CheckRequiredVersions(appID string, clusterID string, sequence int64) error {
+	currentSequence, err := downstream.GetCurrentSequence(appID, clusterID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get current sequence")
+	}
+
+	// nothing has been deployed yet, so there's nothing to upgrade from
+	if currentSequence == -1 || sequence <= currentSequence {
+		return nil
+	}
+
+	db := persistence.MustGetPGSession()
+	query := `select adv.sequence, adv.version_label, av.kots_app_spec
+	from app_downstream_version adv
+	inner join app_version av on adv.app_id = av.app_id and adv.parent_sequence = av.sequence
+	where adv.app_id = $1 and adv.cluster_id = $2 and adv.sequence > $3 and adv.sequence <= $4
+	order by adv.sequence asc`
+	rows, err := db.Query(query, appID, clusterID, currentSequence, sequence)
+	if err != nil {
+		return errors.Wrap(err, "failed to query")
+	}
+	defer rows.Close()
+
+	versions := []requiredVersion{}
+	for rows.Next() {
+		var v requiredVersion
+		var versionLabel sql.NullString
+		var kotsAppSpec sql.NullString
+		if err := rows.Scan(&v.Sequence, &versionLabel, &kotsAppSpec); err != nil {
+			return errors.Wrap(err, "failed to scan")
+		}
+		v.VersionLabel = versionLabel.String
+
+		if v.Sequence != sequence && kotsAppSpec.Valid && kotsAppSpec.String != "" {
+			kotsApp, err := kotsutil.LoadKotsAppFromContents([]byte(kotsAppSpec.String))
+			if err != nil {
+				return errors.Wrapf(err, "failed to load application spec for sequence %d", v.Sequence)
+			}
+			v.IsRequired = kotsApp.Spec.IsRequired
+		}
+
+		versions = append(versions, v)
+	}
+
+	if skippedErr := findSkippedRequiredVersions(currentSequence, sequence, versions); skippedErr != nil {
+		return *skippedErr
+	}
+
+	return nil
+}
+
+// findSkippedRequiredVersions returns the required versions that deploying the target sequence would skip
+// when upgrading from the current sequence. Versions are ordered by sequence, not by version label,
+// because labels don't have to be semantic versions.
+func findSkippedRequiredVersions(currentSequence int64, targetSequence int64, versions []requiredVersion) *SkippedRequiredVersionsError {
+	// nothing has been deployed yet, or this is a rollback
+	if currentSequence == -1 || targetSequence <= currentSequence {
+		return nil
+	}
+
+	target := requiredVersion{Sequence: targetSequence}
+	skipped := []requiredVersion{}
+	for _, v := range versions {
+		if v.Sequence == targetSequence {
+			target = v
+			continue
+		}
+		if v.Sequence <= currentSequence || v.Sequence > targetSequence {
+			continue
+		}
+		if v.IsRequired {
+			skipped = append(skipped, v)
+		}
+	}
+
+	if len(skipped) == 0 {
+		return nil
+	}
+
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].Sequence < skipped[j].Sequence
+	})
+
+	return &SkippedRequiredVersionsError{
+		Target:  target,
+		Skipped: skipped,
+	}
+}
@@ -0,0 +1,170 @@
+package version
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSkippedRequiredVersionsError(t *testing.T) {
+	err := SkippedRequiredVersionsError{
+		Target: requiredVersion{Sequence: 5, VersionLabel: "1.3.0"},
+		Skipped: []requiredVersion{
+			{Sequence: 2, VersionLabel: "1.1.0"},
+			{Sequence: 4},
+		},
+	}
+
+	want := "cannot deploy 1.3.0 (sequence 5) because it would skip required version(s) 1.1.0 (sequence 2), sequence 4, deploy them in order first: 1.1.0 (sequence 2) -> sequence 4 -> 1.3.0 (sequence 5)"
+	if got := err.Error(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func Test_findSkippedRequiredVersions(t *testing.T) {
+	tests := []struct {
+		name            string
+		currentSequence int64
+		targetSequence  int64
+		versions        []requiredVersion
+		want            *SkippedRequiredVersionsError
+	}{
+		{
+			name:            "nothing deployed yet",
+			currentSequence: -1,
+			targetSequence:  3,
+			versions: []requiredVersion{
+				{Sequence: 1, VersionLabel: "1.0.0", IsRequired: true},
+				{Sequence: 3, VersionLabel: "1.2.0"},
+			},
+			want: nil,
+		},
+		{
+			name:            "rollback",
+			currentSequence: 4,
+			targetSequence:  2,
+			versions: []requiredVersion{
+				{Sequence: 3, VersionLabel: "1.1.0", IsRequired: true},
+			},
+			want: nil,
+		},
+		{
+			name:            "redeploy",
+			currentSequence: 4,
+			targetSequence:  4,
+			versions:        []requiredVersion{},
+			want:            nil,
+		},
+		{
+			name:            "no required versions skipped",
+			currentSequence: 1,
+			targetSequence:  3,
+			versions: []requiredVersion{
+				{Sequence: 2, VersionLabel: "1.1.0"},
+				{Sequence: 3, VersionLabel: "1.2.0"},
+			},
+			want: nil,
+		},
+		{
+			name:            "next version is required",
+			currentSequence: 1,
+			targetSequence:  2,
+			versions: []requiredVersion{
+				{Sequence: 2, VersionLabel: "1.1.0", IsRequired: true},
+			},
+			want: nil,
+		},
+		{
+			name:            "current and target versions are required",
+			currentSequence: 1,
+			targetSequence:  3,
+			versions: []requiredVersion{
+				{Sequence: 1, VersionLabel: "1.0.0", IsRequired: true},
+				{Sequence: 2, VersionLabel: "1.1.0"},
+				{Sequence: 3, VersionLabel: "1.2.0", IsRequired: true},
+			},
+			want: nil,
+		},
+		{
+			name:            "semver labels",
+			currentSequence: 1,
+			targetSequence:  4,
+			versions: []requiredVersion{
+				{Sequence: 3, VersionLabel: "1.2.0", IsRequired: true},
+				{Sequence: 2, VersionLabel: "1.1.0", IsRequired: true},
+				{Sequence: 4, VersionLabel: "1.3.0"},
+			},
+			want: &SkippedRequiredVersionsError{
+				Target: requiredVersion{Sequence: 4, VersionLabel: "1.3.0"},
+				Skipped: []requiredVersion{
+					{Sequence: 2, VersionLabel: "1.1.0", IsRequired: true},
+					{Sequence: 3, VersionLabel: "1.2.0", IsRequired: true},
+				},
+			},
+		},
+		{
+			name:            "semver labels out of sequence order",
+			currentSequence: 1,
+			targetSequence:  3,
+			versions: []requiredVersion{
+				{Sequence: 2, VersionLabel: "2.0.0", IsRequired: true},
+				{Sequence: 3, VersionLabel: "1.9.1"},
+			},
+			want: &SkippedRequiredVersionsError{
+				Target: requiredVersion{Sequence: 3, VersionLabel: "1.9.1"},
+				Skipped: []requiredVersion{
+					{Sequence: 2, VersionLabel: "2.0.0", IsRequired: true},
+				},
+			},
+		},
+		{
+			name:            "non-semver labels",
+			currentSequence: 5,
+			targetSequence:  8,
+			versions: []requiredVersion{
+				{Sequence: 6, VersionLabel: "nightly-2020-10-01"},
+				{Sequence: 7, VersionLabel: "migrate-db", IsRequired: true},
+				{Sequence: 8, VersionLabel: "nightly-2020-10-03"},
+			},
+			want: &SkippedRequiredVersionsError{
+				Target: requiredVersion{Sequence: 8, VersionLabel: "nightly-2020-10-03"},
+				Skipped: []requiredVersion{
+					{Sequence: 7, VersionLabel: "migrate-db", IsRequired: true},
+				},
+			},
+		},
+		{
+			name:            "versions outside of the range are ignored",
+			currentSequence: 2,
+			targetSequence:  4,
+			versions: []requiredVersion{
+				{Sequence: 1, VersionLabel: "1.0.0", IsRequired: true},
+				{Sequence: 3, VersionLabel: ""},
+				{Sequence: 5, VersionLabel: "1.4.0", IsRequired: true},
+			},
+			want: nil,
+		},
+		{
+			name:            "target without a downstream version",
+			currentSequence: 0,
+			targetSequence:  2,
+			versions: []requiredVersion{
+				{Sequence: 1, IsRequired: true},
+			},
+			want: &SkippedRequiredVersionsError{
+				Target: requiredVersion{Sequence: 2},
+				Skipped: []requiredVersion{
+					{Sequence: 1, IsRequired: true},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := findSkippedRequiredVersions(test.currentSequence, test.targetSequence, test.versions)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %#v, got %#v", test.want, got)
+			}
+		})
+	}
+}
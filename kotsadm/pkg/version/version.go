@@ -54,7 +54,7 @@ func CreateVersion(appID string, filesInDir string, source string, currentSequen
 type downstreamGitOps struct {
 }
 
-func (d *downstreamGitOps) CreateGitOpsDownstreamCommit(appID string, clusterID string, newSequence int, filesInDir string, downstreamName string) (string, error) {
+func (d *downstreamGitOps) CreateGitOpsDownstreamCommit(appID string, clusterID string, newSequence int, filesInDir string, previousArchiveDir string, downstreamName string, diffSummary string) (string, error) {
 	downstreamGitOps, err := gitops.GetDownstreamGitOps(appID, clusterID)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get downstream gitops")
@@ -67,7 +67,7 @@ func (d *downstreamGitOps) CreateGitOpsDownstreamCommit(appID string, clusterID
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get app")
 	}
-	createdCommitURL, err := gitops.CreateGitOpsCommit(downstreamGitOps, a.Slug, a.Name, int(newSequence), filesInDir, downstreamName)
+	createdCommitURL, err := gitops.CreateGitOpsCommit(downstreamGitOps, a.Slug, a.Name, int(newSequence), filesInDir, previousArchiveDir, downstreamName, diffSummary)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to create gitops commit")
 	}
@@ -158,6 +158,13 @@ func DeployVersion(appID string, sequence int64) error {
 		return errors.Wrap(err, "failed to update app downstream version status")
 	}
 
+	// a version that the deploy loop was holding is not deployed anymore
+	query = `update app_downstream_version set deploy_held_reason = null where app_id = $1 and deploy_held_reason is not null`
+	_, err = tx.Exec(query, appID)
+	if err != nil {
+		return errors.Wrap(err, "failed to clear held deploys")
+	}
+
 	if err := tx.Commit(); err != nil {
 		return errors.Wrap(err, "failed to commit")
 	}
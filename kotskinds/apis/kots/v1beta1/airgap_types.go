@@ -29,6 +29,11 @@ type AirgapSpec struct {
 	ChannelName  string `json:"channelName,omitempty"`
 	Signature    []byte `json:"signature,omitempty"`
 	AppSlug      string `json:"appSlug,omitempty"`
+
+	// IsDelta is set on bundles that leave out the image layers of the base release
+	IsDelta          bool   `json:"isDelta,omitempty"`
+	BaseUpdateCursor string `json:"baseUpdateCursor,omitempty"`
+	BaseVersionLabel string `json:"baseVersionLabel,omitempty"`
 }
 
 // AirgapStatus defines the observed state of Airgap
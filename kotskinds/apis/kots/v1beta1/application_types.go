@@ -49,10 +49,14 @@ type ApplicationSpec struct {
 	ApplicationPorts             []ApplicationPort `json:"ports,omitempty"`
 	ReleaseNotes                 string            `json:"releaseNotes,omitempty"`
 	AllowRollback                bool              `json:"allowRollback,omitempty"`
+	HealthVerificationSeconds    int               `json:"healthVerificationSeconds,omitempty"`
+	IsRequired                   bool              `json:"isRequired,omitempty"`
 	StatusInformers              []string          `json:"statusInformers,omitempty"`
 	Graphs                       []MetricGraph     `json:"graphs,omitempty"`
 	KubectlVersion               string            `json:"kubectlVersion,omitempty"`
 	KustomizeVersion             string            `json:"kustomizeVersion,omitempty"`
+	MinKotsVersion               string            `json:"minKotsVersion,omitempty"`
+	TargetKotsVersion            string            `json:"targetKotsVersion,omitempty"`
 	AdditionalImages             []string          `json:"additionalImages,omitempty"`
 	AdditionalNamespaces         []string          `json:"additionalNamespaces,omitempty"`
 	RequireMinimalRBACPrivileges bool              `json:"requireMinimalRBACPrivileges,omitempty"`
@@ -30,6 +30,7 @@ type InstallationSpec struct {
 	ReleasedAt    *metav1.Time            `json:"releasedAt,omitempty"`
 	EncryptionKey string                  `json:"encryptionKey,omitempty"`
 	KnownImages   []InstallationImage     `json:"knownImages,omitempty"`
+	AirgapImages  []InstallationImage     `json:"airgapImages,omitempty"`
 	YAMLErrors    []InstallationYAMLError `json:"yamlErrors,omitempty"`
 }
 
@@ -1274,6 +1274,11 @@ func (in *InstallationSpec) DeepCopyInto(out *InstallationSpec) {
 		*out = make([]InstallationImage, len(*in))
 		copy(*out, *in)
 	}
+	if in.AirgapImages != nil {
+		in, out := &in.AirgapImages, &out.AirgapImages
+		*out = make([]InstallationImage, len(*in))
+		copy(*out, *in)
+	}
 	if in.YAMLErrors != nil {
 		in, out := &in.YAMLErrors, &out.YAMLErrors
 		*out = make([]InstallationYAMLError, len(*in))
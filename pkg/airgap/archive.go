@@ -0,0 +1,242 @@
+package airgap
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/containers/image/v5/docker/tarfile"
+	"github.com/pkg/errors"
+)
+
+// imageArchive is the part of a docker-archive image file that delta bundles need
+type imageArchive struct {
+	// Layers are the paths of the layers in the archive, in the same order as DiffIDs
+	Layers  []string
+	DiffIDs []string
+	// Files are the paths of the files that are in the archive
+	Files map[string]bool
+}
+
+type imageConfig struct {
+	RootFS struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+func readImageArchive(path string) (*imageArchive, error) {
+	files := map[string]bool{}
+	var manifestItems []tarfile.ManifestItem
+	err := walkTar(path, func(header *tar.Header, r io.Reader) error {
+		files[header.Name] = true
+		if header.Name != "manifest.json" {
+			return nil
+		}
+		if err := json.NewDecoder(r).Decode(&manifestItems); err != nil {
+			return errors.Wrap(err, "failed to decode manifest.json")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read image archive")
+	}
+
+	if len(manifestItems) != 1 {
+		return nil, errors.Errorf("manifest.json: expected 1 item, got %d", len(manifestItems))
+	}
+	manifest := manifestItems[0]
+
+	config := imageConfig{}
+	err = walkTar(path, func(header *tar.Header, r io.Reader) error {
+		if header.Name != manifest.Config {
+			return nil
+		}
+		if err := json.NewDecoder(r).Decode(&config); err != nil {
+			return errors.Wrap(err, "failed to decode image config")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read image config")
+	}
+
+	if len(config.RootFS.DiffIDs) != len(manifest.Layers) {
+		return nil, errors.Errorf("image config has %d layers, manifest has %d", len(config.RootFS.DiffIDs), len(manifest.Layers))
+	}
+
+	return &imageArchive{
+		Layers:  manifest.Layers,
+		DiffIDs: config.RootFS.DiffIDs,
+		Files:   files,
+	}, nil
+}
+
+// walkTar calls fn for every regular file in the tar archive
+func walkTar(path string, fn func(header *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open archive")
+	}
+	defer f.Close()
+
+	tarReader := tar.NewReader(f)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to advance in tar archive")
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := fn(header, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// copyImageArchive copies the image archive at srcPath to destPath, leaving out the files in skip,
+// and appending the files in add
+func copyImageArchive(srcPath string, destPath string, skip map[string]bool, add map[string]string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open archive")
+	}
+	defer src.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to create archive")
+	}
+	defer dest.Close()
+
+	tarReader := tar.NewReader(src)
+	tarWriter := tar.NewWriter(dest)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to advance in tar archive")
+		}
+
+		if skip[header.Name] {
+			continue
+		}
+		if _, ok := add[header.Name]; ok {
+			// the file is replaced
+			continue
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return errors.Wrapf(err, "failed to write header for %s", header.Name)
+		}
+		if _, err := io.Copy(tarWriter, tarReader); err != nil {
+			return errors.Wrapf(err, "failed to copy %s", header.Name)
+		}
+	}
+
+	addNames := make([]string, 0, len(add))
+	for name := range add {
+		addNames = append(addNames, name)
+	}
+	sort.Strings(addNames)
+	for _, name := range addNames {
+		if err := addFileToTar(tarWriter, name, add[name]); err != nil {
+			return errors.Wrapf(err, "failed to add %s", name)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return errors.Wrap(err, "failed to close tar writer")
+	}
+
+	return nil
+}
+
+func addFileToTar(tarWriter *tar.Writer, name string, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "failed to stat file")
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     info.Size(),
+		Mode:     0644,
+		ModTime:  info.ModTime(),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return errors.Wrap(err, "failed to write header")
+	}
+	if _, err := io.Copy(tarWriter, f); err != nil {
+		return errors.Wrap(err, "failed to copy file")
+	}
+
+	return nil
+}
+
+// writeLayer writes a layer blob from the registry to a temp file, uncompressed like the layers in docker-archive files,
+// and checks that it has the expected diff id
+func writeLayer(blob io.Reader, diffID string) (string, error) {
+	r := bufio.NewReader(blob)
+
+	var layerReader io.Reader = r
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to create gzip reader")
+		}
+		defer gzipReader.Close()
+		layerReader = gzipReader
+	}
+
+	f, err := ioutil.TempFile("", "kots-layer")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temp file")
+	}
+	defer f.Close()
+
+	digester := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, digester), layerReader); err != nil {
+		os.Remove(f.Name())
+		return "", errors.Wrap(err, "failed to write layer")
+	}
+
+	if digest := fmt.Sprintf("sha256:%x", digester.Sum(nil)); digest != diffID {
+		os.Remove(f.Name())
+		return "", errors.Errorf("layer has diff id %s, expected %s", digest, diffID)
+	}
+
+	return f.Name(), nil
+}
+
+// archivePathInRoot returns the path of an image archive of the bundle, relative paths that leave the root are an error
+func archivePathInRoot(root string, archivePath string) (string, error) {
+	fullPath := filepath.Join(root, filepath.FromSlash(archivePath))
+	if rel, err := filepath.Rel(root, fullPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("invalid image archive path %q", archivePath)
+	}
+	return fullPath, nil
+}
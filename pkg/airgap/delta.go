@@ -0,0 +1,485 @@
+package airgap
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/util"
+	serializer "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func init() {
+	kotsscheme.AddToScheme(scheme.Scheme)
+}
+
+// DeltaLayersFile is written to the root of delta bundles, it lists the layers that were left out of the image archives
+const DeltaLayersFile = "delta.json"
+
+type DeltaLayers struct {
+	// Images are keyed by the path of the image archive in the bundle
+	Images map[string][]DeltaLayer `json:"images"`
+}
+
+type DeltaLayer struct {
+	// File is the path of the layer in the image archive
+	File   string `json:"file"`
+	DiffID string `json:"diffId"`
+	// Image is the known image of the base release that has the layer
+	Image string `json:"image"`
+}
+
+type CreateDeltaBundleOptions struct {
+	// BundlePath is the full airgap bundle of the new release
+	BundlePath string
+	OutputPath string
+	// Installation is the installation of the release that's installed, it has the images that were pushed to the registry
+	Installation   *kotsv1beta1.Installation
+	Registry       registry.RegistryOptions
+	ProgressWriter io.Writer
+}
+
+type CreateDeltaBundleResult struct {
+	SkippedLayers int
+	SkippedBytes  int64
+}
+
+// CreateDeltaBundle writes a copy of an airgap bundle that leaves out the image layers that are already in the registry
+func CreateDeltaBundle(options CreateDeltaBundleOptions) (*CreateDeltaBundleResult, error) {
+	if options.Installation == nil {
+		return nil, errors.New("installation is required")
+	}
+
+	source := newRegistryLayerSource(options.Registry)
+	knownLayers := getKnownLayers(source, options.Installation.Spec.AirgapImages, options.ProgressWriter)
+
+	return createDeltaBundle(options, knownLayers)
+}
+
+// getKnownLayers returns the known image that has each layer, keyed by diff id.
+// Images that can't be read from the registry are skipped, their layers stay in the bundle.
+func getKnownLayers(source layerSource, knownImages []kotsv1beta1.InstallationImage, progressWriter io.Writer) map[string]string {
+	knownLayers := map[string]string{}
+	for _, knownImage := range knownImages {
+		diffIDs, err := source.getDiffIDs(knownImage.Image)
+		if err != nil {
+			fmt.Fprintf(progressWriter, "Skipping image %s: %s\n", knownImage.Image, err.Error())
+			continue
+		}
+
+		for _, diffID := range diffIDs {
+			if _, ok := knownLayers[diffID]; !ok {
+				knownLayers[diffID] = knownImage.Image
+			}
+		}
+	}
+
+	return knownLayers
+}
+
+func createDeltaBundle(options CreateDeltaBundleOptions, knownLayers map[string]string) (*CreateDeltaBundleResult, error) {
+	src, err := os.Open(options.BundlePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open airgap bundle")
+	}
+	defer src.Close()
+
+	gzipReader, err := gzip.NewReader(src)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get new gzip reader")
+	}
+	defer gzipReader.Close()
+
+	dest, err := os.Create(options.OutputPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create delta bundle")
+	}
+	defer dest.Close()
+
+	gzipWriter := gzip.NewWriter(dest)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	result := &CreateDeltaBundleResult{}
+	deltaLayers := DeltaLayers{
+		Images: map[string][]DeltaLayer{},
+	}
+	foundAirgap := false
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read tar header")
+		}
+
+		name := path.Clean(header.Name)
+
+		if header.Typeflag != tar.TypeReg {
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return nil, errors.Wrapf(err, "failed to write header for %s", name)
+			}
+			continue
+		}
+
+		if name == DeltaLayersFile {
+			return nil, errors.New("airgap bundle is already a delta bundle")
+		}
+
+		if !strings.Contains(name, "/") && (strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")) {
+			contents, err := ioutil.ReadAll(tarReader)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read %s", name)
+			}
+
+			airgap := decodeAirgap(contents)
+			if airgap != nil {
+				if airgap.Spec.IsDelta {
+					return nil, errors.New("airgap bundle is already a delta bundle")
+				}
+				contents, err = deltaAirgapMeta(airgap, options.Installation)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to update airgap meta")
+				}
+				foundAirgap = true
+			}
+
+			if err := writeTarFile(tarWriter, header, contents); err != nil {
+				return nil, errors.Wrapf(err, "failed to write %s", name)
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(name, "images/") {
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return nil, errors.Wrapf(err, "failed to write header for %s", name)
+			}
+			if _, err := io.Copy(tarWriter, tarReader); err != nil {
+				return nil, errors.Wrapf(err, "failed to copy %s", name)
+			}
+			continue
+		}
+
+		layers, skippedBytes, err := writeDeltaImage(tarWriter, header, tarReader, knownLayers)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to write image %s", name)
+		}
+		if len(layers) > 0 {
+			fmt.Fprintf(options.ProgressWriter, "Leaving out %d layers of image %s\n", len(layers), name)
+			deltaLayers.Images[name] = layers
+			result.SkippedLayers += len(layers)
+			result.SkippedBytes += skippedBytes
+		}
+	}
+
+	if !foundAirgap {
+		return nil, errors.New("airgap bundle does not have airgap.yaml")
+	}
+
+	b, err := json.Marshal(deltaLayers)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal delta layers")
+	}
+	deltaHeader := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     DeltaLayersFile,
+		Mode:     0644,
+	}
+	if err := writeTarFile(tarWriter, deltaHeader, b); err != nil {
+		return nil, errors.Wrap(err, "failed to write delta layers")
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close tar writer")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close gzip writer")
+	}
+
+	return result, nil
+}
+
+// writeDeltaImage writes an image archive of the bundle without the layers that are known, and returns the layers that were left out
+func writeDeltaImage(tarWriter *tar.Writer, header *tar.Header, r io.Reader, knownLayers map[string]string) ([]DeltaLayer, int64, error) {
+	tmpDir, err := ioutil.TempDir("", "kots-delta")
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archivePath := filepath.Join(tmpDir, "image.tar")
+	if err := writeFile(archivePath, r); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to write image archive")
+	}
+
+	archive, err := readImageArchive(archivePath)
+	if err != nil {
+		// not every file in the images dir is an image archive
+		return nil, 0, copyFileToTar(tarWriter, header, archivePath)
+	}
+
+	layers := []DeltaLayer{}
+	skip := map[string]bool{}
+	for i, file := range archive.Layers {
+		knownImage, ok := knownLayers[archive.DiffIDs[i]]
+		if !ok || skip[file] {
+			continue
+		}
+		layers = append(layers, DeltaLayer{
+			File:   file,
+			DiffID: archive.DiffIDs[i],
+			Image:  knownImage,
+		})
+		skip[file] = true
+	}
+
+	if len(layers) == 0 {
+		return nil, 0, copyFileToTar(tarWriter, header, archivePath)
+	}
+
+	deltaPath := filepath.Join(tmpDir, "delta.tar")
+	if err := copyImageArchive(archivePath, deltaPath, skip, nil); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to copy image archive")
+	}
+
+	fullSize := header.Size
+	if err := copyFileToTar(tarWriter, header, deltaPath); err != nil {
+		return nil, 0, err
+	}
+
+	return layers, fullSize - header.Size, nil
+}
+
+// LoadDeltaLayers returns the layers that were left out of the images of a delta bundle, or nil if the bundle is not a delta
+func LoadDeltaLayers(airgapRoot string) (*DeltaLayers, error) {
+	b, err := ioutil.ReadFile(filepath.Join(airgapRoot, DeltaLayersFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read delta layers")
+	}
+
+	deltaLayers := DeltaLayers{}
+	if err := json.Unmarshal(b, &deltaLayers); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal delta layers")
+	}
+
+	return &deltaLayers, nil
+}
+
+// RehydrateDeltaImages adds the layers that were left out of the image archives of an extracted delta bundle back,
+// from the images of the base release in the registry, so that the images can be pushed like the images of a full bundle
+func RehydrateDeltaImages(airgapRoot string, airgap *kotsv1beta1.Airgap, registryOptions registry.RegistryOptions, progressWriter io.Writer) error {
+	deltaLayers, err := LoadDeltaLayers(airgapRoot)
+	if err != nil {
+		return errors.Wrap(err, "failed to load delta layers")
+	}
+	if deltaLayers == nil {
+		return nil
+	}
+
+	source := newRegistryLayerSource(registryOptions)
+	return rehydrateImages(airgapRoot, airgap, deltaLayers, source, progressWriter)
+}
+
+func rehydrateImages(airgapRoot string, airgap *kotsv1beta1.Airgap, deltaLayers *DeltaLayers, source layerSource, progressWriter io.Writer) error {
+	archivePaths := []string{}
+	for archivePath := range deltaLayers.Images {
+		archivePaths = append(archivePaths, archivePath)
+	}
+	sort.Strings(archivePaths)
+
+	for _, archivePath := range archivePaths {
+		fullPath, err := archivePathInRoot(airgapRoot, archivePath)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(progressWriter, "Restoring layers of image %s\n", archivePath)
+
+		if err := rehydrateImage(fullPath, airgap, deltaLayers.Images[archivePath], source); err != nil {
+			return errors.Wrapf(err, "failed to restore layers of image %s", archivePath)
+		}
+	}
+
+	return nil
+}
+
+func rehydrateImage(archivePath string, airgap *kotsv1beta1.Airgap, layers []DeltaLayer, source layerSource) error {
+	archive, err := readImageArchive(archivePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read image archive")
+	}
+
+	add := map[string]string{}
+	defer func() {
+		for _, layerPath := range add {
+			os.Remove(layerPath)
+		}
+	}()
+
+	for _, layer := range layers {
+		if archive.Files[layer.File] {
+			continue
+		}
+		if _, ok := add[layer.File]; ok {
+			continue
+		}
+
+		layerPath, err := getLayer(source, layer)
+		if err != nil {
+			return fullBundleRequiredError(airgap, fmt.Sprintf("Layer %s of image %s could not be restored from the registry: %s", layer.DiffID, layer.Image, err.Error()))
+		}
+		add[layer.File] = layerPath
+	}
+
+	if len(add) == 0 {
+		return nil
+	}
+
+	tmpPath := archivePath + ".tmp"
+	if err := copyImageArchive(archivePath, tmpPath, nil, add); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to copy image archive")
+	}
+
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		return errors.Wrap(err, "failed to replace image archive")
+	}
+
+	return nil
+}
+
+func getLayer(source layerSource, layer DeltaLayer) (string, error) {
+	blob, err := source.getLayer(layer.Image, layer.DiffID)
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	return writeLayer(blob, layer.DiffID)
+}
+
+// CheckDeltaBase returns an error that names the full bundle to upload when a delta bundle was not built for the installed release
+func CheckDeltaBase(airgap *kotsv1beta1.Airgap, installation *kotsv1beta1.Installation) error {
+	if airgap == nil || !airgap.Spec.IsDelta {
+		return nil
+	}
+
+	if installation == nil || installation.Spec.UpdateCursor == "" {
+		return fullBundleRequiredError(airgap, "Delta airgap bundles can only update an installed application")
+	}
+
+	if airgap.Spec.ChannelID != installation.Spec.ChannelID {
+		return fullBundleRequiredError(airgap, fmt.Sprintf("This delta airgap bundle was built for channel %s, but the installed release is from channel %s",
+			airgap.Spec.ChannelName, installation.Spec.ChannelName))
+	}
+
+	if airgap.Spec.BaseUpdateCursor != installation.Spec.UpdateCursor {
+		return fullBundleRequiredError(airgap, fmt.Sprintf("This delta airgap bundle was built for version %s (%s), but version %s (%s) is installed",
+			airgap.Spec.BaseVersionLabel, airgap.Spec.BaseUpdateCursor, installation.Spec.VersionLabel, installation.Spec.UpdateCursor))
+	}
+
+	return nil
+}
+
+func fullBundleRequiredError(airgap *kotsv1beta1.Airgap, reason string) error {
+	if airgap == nil {
+		return util.ActionableError{Message: reason}
+	}
+	return util.ActionableError{
+		Message: fmt.Sprintf("%s. Upload the full airgap bundle for version %s (%s) instead.", reason, airgap.Spec.VersionLabel, airgap.Spec.UpdateCursor),
+	}
+}
+
+func decodeAirgap(contents []byte) *kotsv1beta1.Airgap {
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	decoded, gvk, err := decode(contents, nil, nil)
+	if err != nil {
+		return nil
+	}
+
+	if gvk.Group != "kots.io" || gvk.Version != "v1beta1" || gvk.Kind != "Airgap" {
+		return nil
+	}
+
+	return decoded.(*kotsv1beta1.Airgap)
+}
+
+func deltaAirgapMeta(airgap *kotsv1beta1.Airgap, installation *kotsv1beta1.Installation) ([]byte, error) {
+	airgap.Spec.IsDelta = true
+	airgap.Spec.BaseUpdateCursor = installation.Spec.UpdateCursor
+	airgap.Spec.BaseVersionLabel = installation.Spec.VersionLabel
+
+	s := serializer.NewYAMLSerializer(serializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var b bytes.Buffer
+	if err := s.Encode(airgap, &b); err != nil {
+		return nil, errors.Wrap(err, "failed to encode airgap")
+	}
+
+	return b.Bytes(), nil
+}
+
+func writeTarFile(tarWriter *tar.Writer, header *tar.Header, contents []byte) error {
+	header.Size = int64(len(contents))
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return errors.Wrap(err, "failed to write header")
+	}
+	if _, err := tarWriter.Write(contents); err != nil {
+		return errors.Wrap(err, "failed to write contents")
+	}
+	return nil
+}
+
+func copyFileToTar(tarWriter *tar.Writer, header *tar.Header, filePath string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to stat file")
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	header.Size = info.Size()
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return errors.Wrap(err, "failed to write header")
+	}
+	if _, err := io.Copy(tarWriter, f); err != nil {
+		return errors.Wrap(err, "failed to copy file")
+	}
+	return nil
+}
+
+func writeFile(filePath string, r io.Reader) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to create file")
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return errors.Wrap(err, "failed to copy file")
+	}
+	return nil
+}
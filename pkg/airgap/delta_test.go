@@ -0,0 +1,414 @@
+package airgap
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAirgapYAML = `apiVersion: kots.io/v1beta1
+kind: Airgap
+metadata:
+  name: my-app
+spec:
+  appSlug: my-app
+  channelID: channel-id
+  channelName: Stable
+  updateCursor: "2"
+  versionLabel: "2.0.0"
+`
+
+type testLayerSource struct {
+	images map[string][][]byte
+}
+
+func (s testLayerSource) getDiffIDs(knownImage string) ([]string, error) {
+	layers, ok := s.images[knownImage]
+	if !ok {
+		return nil, errors.Errorf("image %s not found", knownImage)
+	}
+
+	diffIDs := []string{}
+	for _, layer := range layers {
+		diffIDs = append(diffIDs, testDiffID(layer))
+	}
+	return diffIDs, nil
+}
+
+// getLayer returns the layers gzipped, like the blobs in a registry
+func (s testLayerSource) getLayer(knownImage string, diffID string) (io.ReadCloser, error) {
+	for _, layer := range s.images[knownImage] {
+		if testDiffID(layer) != diffID {
+			continue
+		}
+
+		var b bytes.Buffer
+		gzipWriter := gzip.NewWriter(&b)
+		gzipWriter.Write(layer)
+		gzipWriter.Close()
+		return ioutil.NopCloser(&b), nil
+	}
+	return nil, errors.Errorf("image %s does not have layer %s", knownImage, diffID)
+}
+
+func testDiffID(layer []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(layer))
+}
+
+func testTar(t *testing.T, files map[string][]byte, order []string) []byte {
+	var b bytes.Buffer
+	tarWriter := tar.NewWriter(&b)
+	for _, name := range order {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     int64(len(files[name])),
+			Mode:     0644,
+		}))
+		_, err := tarWriter.Write(files[name])
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	return b.Bytes()
+}
+
+// testImageArchive returns a docker-archive image with the layers
+func testImageArchive(t *testing.T, layers ...[]byte) []byte {
+	config := imageConfig{}
+	files := map[string][]byte{}
+	layerFiles := []string{}
+	for _, layer := range layers {
+		diffID := testDiffID(layer)
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, diffID)
+		layerFile := diffID[len("sha256:"):] + ".tar"
+		layerFiles = append(layerFiles, layerFile)
+		files[layerFile] = layer
+	}
+
+	configBytes, err := json.Marshal(config)
+	require.NoError(t, err)
+	files["config.json"] = configBytes
+
+	manifest, err := json.Marshal([]map[string]interface{}{
+		{"Config": "config.json", "RepoTags": []string{}, "Layers": layerFiles},
+	})
+	require.NoError(t, err)
+	files["manifest.json"] = manifest
+
+	return testTar(t, files, append(append([]string{"config.json"}, layerFiles...), "manifest.json"))
+}
+
+func writeTestBundle(t *testing.T, dir string, files map[string][]byte, order []string) string {
+	var b bytes.Buffer
+	gzipWriter := gzip.NewWriter(&b)
+	_, err := gzipWriter.Write(testTar(t, files, order))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	bundlePath := filepath.Join(dir, "app.airgap")
+	require.NoError(t, ioutil.WriteFile(bundlePath, b.Bytes(), 0644))
+	return bundlePath
+}
+
+func extractTestBundle(t *testing.T, bundlePath string, dir string) {
+	f, err := os.Open(bundlePath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gzipReader, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		filePath := filepath.Join(dir, header.Name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		contents, err := ioutil.ReadAll(tarReader)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filePath, contents, 0644))
+	}
+}
+
+func readTestTar(t *testing.T, tarPath string) map[string][]byte {
+	files := map[string][]byte{}
+	err := walkTar(tarPath, func(header *tar.Header, r io.Reader) error {
+		contents, err := ioutil.ReadAll(r)
+		files[header.Name] = contents
+		return err
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func Test_DeltaBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kots-delta")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	baseLayer := bytes.Repeat([]byte("base layer"), 100)
+	sharedLayer := []byte("shared layer")
+	newLayer := []byte("new layer")
+
+	source := testLayerSource{
+		images: map[string][][]byte{
+			"quay.io/org/app:1.0":     {baseLayer, sharedLayer},
+			"quay.io/org/sidecar:1.0": {sharedLayer},
+		},
+	}
+
+	knownImages := []kotsv1beta1.InstallationImage{
+		{Image: "quay.io/org/app:1.0", IsPrivate: true},
+		{Image: "quay.io/org/sidecar:1.0", IsPrivate: true},
+		{Image: "quay.io/org/removed:1.0", IsPrivate: true},
+	}
+	knownLayers := getKnownLayers(source, knownImages, ioutil.Discard)
+	assert.Equal(t, map[string]string{
+		testDiffID(baseLayer):   "quay.io/org/app:1.0",
+		testDiffID(sharedLayer): "quay.io/org/app:1.0",
+	}, knownLayers)
+
+	appImage := testImageArchive(t, baseLayer, sharedLayer, newLayer)
+	otherImage := testImageArchive(t, newLayer)
+	bundlePath := writeTestBundle(t, dir, map[string][]byte{
+		"airgap.yaml": []byte(testAirgapYAML),
+		"app.tar.gz":  []byte("app"),
+		"images/docker-archive/quay.io/org/app/2.0":   appImage,
+		"images/docker-archive/quay.io/org/other/1.0": otherImage,
+	}, []string{
+		"airgap.yaml",
+		"app.tar.gz",
+		"images/docker-archive/quay.io/org/app/2.0",
+		"images/docker-archive/quay.io/org/other/1.0",
+	})
+
+	installation := &kotsv1beta1.Installation{
+		Spec: kotsv1beta1.InstallationSpec{
+			UpdateCursor: "1",
+			VersionLabel: "1.0.0",
+			ChannelID:    "channel-id",
+			AirgapImages: knownImages,
+		},
+	}
+	options := CreateDeltaBundleOptions{
+		BundlePath:     bundlePath,
+		OutputPath:     filepath.Join(dir, "app-delta.airgap"),
+		Installation:   installation,
+		ProgressWriter: ioutil.Discard,
+	}
+	result, err := createDeltaBundle(options, knownLayers)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.SkippedLayers)
+	assert.True(t, result.SkippedBytes >= int64(len(baseLayer)+len(sharedLayer)))
+
+	airgapRoot := filepath.Join(dir, "delta")
+	extractTestBundle(t, options.OutputPath, airgapRoot)
+
+	airgapYAML, err := ioutil.ReadFile(filepath.Join(airgapRoot, "airgap.yaml"))
+	require.NoError(t, err)
+	airgap := decodeAirgap(airgapYAML)
+	require.NotNil(t, airgap)
+	assert.True(t, airgap.Spec.IsDelta)
+	assert.Equal(t, "1", airgap.Spec.BaseUpdateCursor)
+	assert.Equal(t, "1.0.0", airgap.Spec.BaseVersionLabel)
+	assert.Equal(t, "2", airgap.Spec.UpdateCursor)
+
+	deltaLayers, err := LoadDeltaLayers(airgapRoot)
+	require.NoError(t, err)
+	appArchivePath := "images/docker-archive/quay.io/org/app/2.0"
+	assert.Equal(t, map[string][]DeltaLayer{
+		appArchivePath: {
+			{File: testDiffID(baseLayer)[7:] + ".tar", DiffID: testDiffID(baseLayer), Image: "quay.io/org/app:1.0"},
+			{File: testDiffID(sharedLayer)[7:] + ".tar", DiffID: testDiffID(sharedLayer), Image: "quay.io/org/app:1.0"},
+		},
+	}, deltaLayers.Images)
+
+	deltaFiles := readTestTar(t, filepath.Join(airgapRoot, appArchivePath))
+	assert.Len(t, deltaFiles, 3)
+	assert.Equal(t, newLayer, deltaFiles[testDiffID(newLayer)[7:]+".tar"])
+
+	otherImageAfter, err := ioutil.ReadFile(filepath.Join(airgapRoot, "images/docker-archive/quay.io/org/other/1.0"))
+	require.NoError(t, err)
+	assert.Equal(t, otherImage, otherImageAfter)
+
+	// a delta of a delta can't be created
+	_, err = createDeltaBundle(CreateDeltaBundleOptions{
+		BundlePath:     options.OutputPath,
+		OutputPath:     filepath.Join(dir, "app-delta-delta.airgap"),
+		Installation:   installation,
+		ProgressWriter: ioutil.Discard,
+	}, knownLayers)
+	assert.Error(t, err)
+
+	// rehydrating restores the image archive
+	require.NoError(t, rehydrateImages(airgapRoot, airgap, deltaLayers, source, ioutil.Discard))
+
+	rehydratedFiles := readTestTar(t, filepath.Join(airgapRoot, appArchivePath))
+	assert.Equal(t, readTestTar(t, writeTestFile(t, dir, appImage)), rehydratedFiles)
+
+	archive, err := readImageArchive(filepath.Join(airgapRoot, appArchivePath))
+	require.NoError(t, err)
+	for _, layer := range archive.Layers {
+		assert.True(t, archive.Files[layer])
+	}
+
+	// rehydrating again is a no-op
+	require.NoError(t, rehydrateImages(airgapRoot, airgap, deltaLayers, source, ioutil.Discard))
+}
+
+func Test_rehydrateImagesMissingLayer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kots-delta")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	baseLayer := []byte("base layer")
+	newLayer := []byte("new layer")
+
+	fullArchive := testImageArchive(t, baseLayer, newLayer)
+	archivePath := writeTestFile(t, dir, fullArchive)
+	deltaPath := filepath.Join(dir, "delta.tar")
+	layerFile := testDiffID(baseLayer)[7:] + ".tar"
+	require.NoError(t, copyImageArchive(archivePath, deltaPath, map[string]bool{layerFile: true}, nil))
+
+	airgap := decodeAirgap([]byte(testAirgapYAML))
+	require.NotNil(t, airgap)
+
+	deltaLayers := &DeltaLayers{
+		Images: map[string][]DeltaLayer{
+			"delta.tar": {{File: layerFile, DiffID: testDiffID(baseLayer), Image: "quay.io/org/app:1.0"}},
+		},
+	}
+
+	// the image was removed from the registry
+	source := testLayerSource{images: map[string][][]byte{}}
+	err = rehydrateImages(dir, airgap, deltaLayers, source, ioutil.Discard)
+	require.Error(t, err)
+	actionableErr, ok := errors.Cause(err).(util.ActionableError)
+	require.True(t, ok)
+	assert.Contains(t, actionableErr.Message, "Upload the full airgap bundle for version 2.0.0 (2)")
+
+	// archive paths can't leave the bundle
+	err = rehydrateImages(filepath.Join(dir, "root"), airgap, &DeltaLayers{Images: map[string][]DeltaLayer{
+		"../delta.tar": deltaLayers.Images["delta.tar"],
+	}}, source, ioutil.Discard)
+	assert.Error(t, err)
+}
+
+func writeTestFile(t *testing.T, dir string, contents []byte) string {
+	f, err := ioutil.TempFile(dir, "image")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(contents)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func Test_writeLayer(t *testing.T) {
+	layer := []byte("layer")
+
+	layerPath, err := writeLayer(bytes.NewReader(layer), testDiffID(layer))
+	require.NoError(t, err)
+	defer os.Remove(layerPath)
+
+	contents, err := ioutil.ReadFile(layerPath)
+	require.NoError(t, err)
+	assert.Equal(t, layer, contents)
+
+	_, err = writeLayer(bytes.NewReader(layer), testDiffID([]byte("other")))
+	assert.Error(t, err)
+}
+
+func Test_CheckDeltaBase(t *testing.T) {
+	full := decodeAirgap([]byte(testAirgapYAML))
+	require.NotNil(t, full)
+
+	delta := full.DeepCopy()
+	delta.Spec.IsDelta = true
+	delta.Spec.BaseUpdateCursor = "1"
+	delta.Spec.BaseVersionLabel = "1.0.0"
+
+	installed := func(updateCursor string, versionLabel string, channelID string) *kotsv1beta1.Installation {
+		channelName := "Stable"
+		if channelID != "channel-id" {
+			channelName = "Beta"
+		}
+		return &kotsv1beta1.Installation{
+			Spec: kotsv1beta1.InstallationSpec{
+				UpdateCursor: updateCursor,
+				VersionLabel: versionLabel,
+				ChannelID:    channelID,
+				ChannelName:  channelName,
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		airgap       *kotsv1beta1.Airgap
+		installation *kotsv1beta1.Installation
+		wantErr      string
+	}{
+		{
+			name:         "full bundle",
+			airgap:       full,
+			installation: installed("0", "0.9.0", "channel-id"),
+		},
+		{
+			name:         "no airgap meta",
+			installation: installed("0", "0.9.0", "channel-id"),
+		},
+		{
+			name:         "delta of installed release",
+			airgap:       delta,
+			installation: installed("1", "1.0.0", "channel-id"),
+		},
+		{
+			name:         "delta of another release",
+			airgap:       delta,
+			installation: installed("0", "0.9.0", "channel-id"),
+			wantErr:      "This delta airgap bundle was built for version 1.0.0 (1), but version 0.9.0 (0) is installed. Upload the full airgap bundle for version 2.0.0 (2) instead.",
+		},
+		{
+			name:         "delta of another channel",
+			airgap:       delta,
+			installation: installed("1", "1.0.0", "other-channel-id"),
+			wantErr:      "This delta airgap bundle was built for channel Stable, but the installed release is from channel Beta. Upload the full airgap bundle for version 2.0.0 (2) instead.",
+		},
+		{
+			name:    "delta install",
+			airgap:  delta,
+			wantErr: "Delta airgap bundles can only update an installed application. Upload the full airgap bundle for version 2.0.0 (2) instead.",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckDeltaBase(test.airgap, test.installation)
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			_, ok := err.(util.ActionableError)
+			assert.True(t, ok)
+			assert.Equal(t, test.wantErr, err.Error())
+		})
+	}
+}
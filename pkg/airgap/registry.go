@@ -0,0 +1,211 @@
+package airgap
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	imagedocker "github.com/containers/image/v5/docker"
+	dockerref "github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/types"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/image"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+)
+
+// layerSource returns the layers of the images of the base release
+type layerSource interface {
+	// getDiffIDs returns the diff ids of the layers of a known image
+	getDiffIDs(knownImage string) ([]string, error)
+	// getLayer returns the blob of the layer of a known image that has the diff id
+	getLayer(knownImage string, diffID string) (io.ReadCloser, error)
+}
+
+// registryLayerSource reads the images of the base release from the registry they were pushed to
+type registryLayerSource struct {
+	registry registry.RegistryOptions
+	images   map[string]*registryImage
+}
+
+type registryImage struct {
+	ref     types.ImageReference
+	diffIDs []string
+	blobs   []types.BlobInfo
+}
+
+// InstallationImages returns the images that were pushed from an airgap bundle as installation images.
+// Images are recorded with the tag or digest they were pushed with, so that the registry layer source reads the same image.
+func InstallationImages(images []kustomizetypes.Image) []kotsv1beta1.InstallationImage {
+	installationImages := []kotsv1beta1.InstallationImage{}
+	seen := map[string]bool{}
+	for _, image := range images {
+		name := image.NewName
+		if name == "" {
+			name = image.Name
+		}
+		if image.Digest != "" {
+			name = fmt.Sprintf("%s@%s", name, image.Digest)
+		} else if image.NewTag != "" {
+			name = fmt.Sprintf("%s:%s", name, image.NewTag)
+		}
+
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		installationImages = append(installationImages, kotsv1beta1.InstallationImage{
+			Image:     name,
+			IsPrivate: true,
+		})
+	}
+	return installationImages
+}
+
+func newRegistryLayerSource(registryOptions registry.RegistryOptions) *registryLayerSource {
+	return &registryLayerSource{
+		registry: registryOptions,
+		images:   map[string]*registryImage{},
+	}
+}
+
+func (s *registryLayerSource) getDiffIDs(knownImage string) ([]string, error) {
+	img, err := s.getImage(knownImage)
+	if err != nil {
+		return nil, err
+	}
+	return img.diffIDs, nil
+}
+
+func (s *registryLayerSource) getLayer(knownImage string, diffID string) (io.ReadCloser, error) {
+	img, err := s.getImage(knownImage)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, d := range img.diffIDs {
+		if d != diffID {
+			continue
+		}
+
+		sysCtx, err := s.systemContext(img.ref)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get system context")
+		}
+
+		src, err := img.ref.NewImageSource(context.Background(), sysCtx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create image source")
+		}
+
+		blob, _, err := src.GetBlob(context.Background(), img.blobs[i], none.NoCache)
+		if err != nil {
+			src.Close()
+			return nil, errors.Wrapf(err, "failed to get blob %s", img.blobs[i].Digest)
+		}
+
+		return &blobReadCloser{ReadCloser: blob, src: src}, nil
+	}
+
+	return nil, errors.Errorf("image %s does not have layer %s", knownImage, diffID)
+}
+
+func (s *registryLayerSource) getImage(knownImage string) (*registryImage, error) {
+	imageRef := image.DestRef(s.registry, knownImage)
+	if img, ok := s.images[imageRef]; ok {
+		return img, nil
+	}
+
+	ref, err := parseImageRef(imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	sysCtx, err := s.systemContext(ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get system context")
+	}
+
+	remoteImage, err := ref.NewImage(context.Background(), sysCtx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get image %s", imageRef)
+	}
+	defer remoteImage.Close()
+
+	config, err := remoteImage.OCIConfig(context.Background())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get config of image %s", imageRef)
+	}
+
+	blobs := remoteImage.LayerInfos()
+	if len(blobs) != len(config.RootFS.DiffIDs) {
+		return nil, errors.Errorf("image %s has %d layers and %d diff ids", imageRef, len(blobs), len(config.RootFS.DiffIDs))
+	}
+
+	diffIDs := []string{}
+	for _, diffID := range config.RootFS.DiffIDs {
+		diffIDs = append(diffIDs, diffID.String())
+	}
+
+	img := &registryImage{
+		ref:     ref,
+		diffIDs: diffIDs,
+		blobs:   blobs,
+	}
+	s.images[imageRef] = img
+
+	return img, nil
+}
+
+func parseImageRef(imageRef string) (types.ImageReference, error) {
+	// ParseReference requires the // prefix
+	ref, err := imagedocker.ParseReference(fmt.Sprintf("//%s", imageRef))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse image ref %q", imageRef)
+	}
+	return ref, nil
+}
+
+func (s *registryLayerSource) systemContext(ref types.ImageReference) (*types.SystemContext, error) {
+	sysCtx := &types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerDisableV1Ping:         true,
+	}
+
+	if s.registry.Username == "" || s.registry.Password == "" {
+		return sysCtx, nil
+	}
+
+	username, password := s.registry.Username, s.registry.Password
+
+	registryHost := dockerref.Domain(ref.DockerReference())
+	if registry.IsECREndpoint(registryHost) {
+		login, err := registry.GetECRLogin(registryHost, username, password)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get ECR login")
+		}
+		username = login.Username
+		password = login.Password
+	}
+
+	sysCtx.DockerAuthConfig = &types.DockerAuthConfig{
+		Username: username,
+		Password: password,
+	}
+
+	return sysCtx, nil
+}
+
+// blobReadCloser closes the image source of the blob with the blob
+type blobReadCloser struct {
+	io.ReadCloser
+	src types.ImageSource
+}
+
+func (b *blobReadCloser) Close() error {
+	err := b.ReadCloser.Close()
+	b.src.Close()
+	return err
+}
@@ -0,0 +1,61 @@
+package airgap
+
+import (
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+)
+
+func Test_InstallationImages(t *testing.T) {
+	registryOptions := registry.RegistryOptions{
+		Endpoint:  "registry.example.com:5000",
+		Namespace: "my-app",
+	}
+
+	images := []kustomizetypes.Image{
+		{
+			Name:    "docker.io/library/redis:5.0.7",
+			NewName: "registry.example.com:5000/my-app/redis",
+			NewTag:  "5.0.7",
+		},
+		{
+			Name:    "library/redis:5.0.7",
+			NewName: "registry.example.com:5000/my-app/redis",
+			NewTag:  "5.0.7",
+		},
+		{
+			Name:    "redis:5.0.7",
+			NewName: "registry.example.com:5000/my-app/redis",
+			NewTag:  "5.0.7",
+		},
+		{
+			Name:    "quay.io/replicated/api@sha256:aa1f69a1ce4fe0d4d0b6ee5eb0d7a4e55b2bf2f3b9a5a9d8f8c6b7e1d2c3f4a5",
+			NewName: "registry.example.com:5000/my-app/api",
+			Digest:  "sha256:aa1f69a1ce4fe0d4d0b6ee5eb0d7a4e55b2bf2f3b9a5a9d8f8c6b7e1d2c3f4a5",
+		},
+	}
+
+	installationImages := InstallationImages(images)
+	assert.Equal(t, []kotsv1beta1.InstallationImage{
+		{
+			Image:     "registry.example.com:5000/my-app/redis:5.0.7",
+			IsPrivate: true,
+		},
+		{
+			Image:     "registry.example.com:5000/my-app/api@sha256:aa1f69a1ce4fe0d4d0b6ee5eb0d7a4e55b2bf2f3b9a5a9d8f8c6b7e1d2c3f4a5",
+			IsPrivate: true,
+		},
+	}, installationImages)
+
+	for _, installationImage := range installationImages {
+		imageRef := image.DestRef(registryOptions, installationImage.Image)
+		ref, err := parseImageRef(imageRef)
+		require.NoError(t, err)
+		assert.Equal(t, installationImage.Image, ref.DockerReference().String())
+	}
+}
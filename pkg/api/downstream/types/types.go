@@ -4,6 +4,7 @@ import (
 	"time"
 
 	v1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	snapshottypes "github.com/replicatedhq/kots/pkg/snapshot/types"
 )
 
 type Downstream struct {
@@ -13,6 +14,8 @@ type Downstream struct {
 	CurrentSequence  int64  `json:"currentSequence"`
 	SnapshotSchedule string `json:"snapshotSchedule,omitempty"`
 	SnapshotTTL      string `json:"snapshotTtl,omitempty"`
+	// SnapshotRetentionPolicy prunes scheduled instance snapshots, they are kept until they expire when nil
+	SnapshotRetentionPolicy *snapshottypes.RetentionPolicy `json:"snapshotRetentionPolicy,omitempty"`
 }
 
 type DownstreamVersion struct {
@@ -30,6 +33,12 @@ type DownstreamVersion struct {
 	DiffSummaryError         string                          `json:"diffSummaryError,omitempty"`
 	CommitURL                string                          `json:"commitUrl,omitempty"`
 	GitDeployable            bool                            `json:"gitDeployable,omitempty"`
+	PreUpgradeSnapshot       string                          `json:"preUpgradeSnapshot,omitempty"`
+	DeployHeldReason         string                          `json:"deployHeldReason,omitempty"`
+	NeedsKotsUpgrade         bool                            `json:"needsKotsUpgrade,omitempty"`
+	TargetKotsVersion        string                          `json:"targetKotsVersion,omitempty"`
+	PullRequestURL           string                          `json:"pullRequestUrl,omitempty"`
+	PullRequestState         string                          `json:"pullRequestState,omitempty"`
 	UpstreamReleasedAt       *time.Time                      `json:"upstreamReleasedAt,omitempty"`
 	YamlErrors               []v1beta1.InstallationYAMLError `json:"yamlErrors,omitempty"`
 }
@@ -41,3 +50,26 @@ type DownstreamOutput struct {
 	ApplyStderr  string `json:"applyStderr"`
 	RenderError  string `json:"renderError"`
 }
+
+// HealthVerification is a deployed downstream version that is waiting for the app to become ready
+type HealthVerification struct {
+	AppID          string
+	ClusterID      string
+	Sequence       int64
+	ParentSequence int64
+	StartedAt      time.Time
+}
+
+const (
+	PreUpgradeBackupRunning   = "running"
+	PreUpgradeBackupCompleted = "completed"
+	PreUpgradeBackupFailed    = "failed"
+)
+
+// PreUpgradeBackup is the snapshot of the outgoing version that has to complete before a downstream version is deployed
+type PreUpgradeBackup struct {
+	BackupName string
+	Status     string
+	// DeployedAt is the deploy time of the version the backup was taken for, deploying the same version again takes a new backup
+	DeployedAt *time.Time
+}
@@ -52,6 +52,7 @@ type ResponseDownstream struct {
 	PastVersions    []downstreamtypes.DownstreamVersion `json:"pastVersions"`
 	GitOps          ResponseGitOps                      `json:"gitops"`
 	Cluster         ResponseCluster                     `json:"cluster"`
+	PreflightCheck  *ResponsePreflightCheck             `json:"preflightCheck,omitempty"`
 }
 
 type ResponseGitOps struct {
@@ -65,6 +66,34 @@ type ResponseGitOps struct {
 	Action      string `json:"action"`
 	DeployKey   string `json:"deployKey"`
 	IsConnected bool   `json:"isConnected"`
+	AutoRepush  bool   `json:"autoRepush"`
+
+	Sync *ResponseGitOpsSync `json:"sync,omitempty"`
+}
+
+// ResponseGitOpsSync is whether the branch still contains what kots last committed
+type ResponseGitOpsSync struct {
+	Sequence      int64      `json:"sequence"`
+	CommitSHA     string     `json:"commitSha"`
+	Status        string     `json:"status"`
+	DriftedFiles  []string   `json:"driftedFiles"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastCheckedAt *time.Time `json:"lastCheckedAt"`
+}
+
+// ResponsePreflightCheck is the latest scheduled preflight check of the deployed version
+type ResponsePreflightCheck struct {
+	Sequence    int64                          `json:"sequence"`
+	State       string                         `json:"state"`
+	Changes     []ResponsePreflightCheckChange `json:"changes"`
+	CompletedAt *time.Time                     `json:"completedAt"`
+}
+
+// ResponsePreflightCheckChange is a check that passed in the previous run and warns or fails now
+type ResponsePreflightCheckChange struct {
+	Title string `json:"title"`
+	From  string `json:"from"`
+	To    string `json:"to"`
 }
 
 type ResponseCluster struct {
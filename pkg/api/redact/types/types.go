@@ -0,0 +1,38 @@
+package types
+
+const (
+	RemovalTypeValue    = "value"
+	RemovalTypeRegex    = "regex"
+	RemovalTypeYamlPath = "yamlPath"
+)
+
+// RedactPreview describes what a redactor spec would remove from a support bundle without saving the spec
+type RedactPreview struct {
+	Files    []FilePreview    `json:"files"`
+	Removals []RemovalPreview `json:"removals"`
+}
+
+// FilePreview is a file of the bundle that the spec redacts
+type FilePreview struct {
+	Path     string    `json:"path"`
+	Matches  int       `json:"matches"`
+	Snippets []Snippet `json:"snippets"`
+}
+
+// Snippet is a line of a file before and after the redactor removed a value, the default redactors are applied to both
+type Snippet struct {
+	Line     int    `json:"line"`
+	Redactor string `json:"redactor"`
+	Before   string `json:"before"`
+	After    string `json:"after"`
+}
+
+// RemovalPreview is a value, regex or yaml path of a redactor of the spec, values are not returned to not echo secrets
+type RemovalPreview struct {
+	Redactor     string `json:"redactor"`
+	Type         string `json:"type"`
+	Index        int    `json:"index"`
+	Pattern      string `json:"pattern,omitempty"`
+	Matches      int    `json:"matches"`
+	NeverMatched bool   `json:"neverMatched"`
+}
@@ -0,0 +1,77 @@
+package types
+
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// BundleComparison describes what changed from a base support bundle to a target support bundle,
+// usually from a bundle of a healthy app to a bundle of the same app when it's unhealthy
+type BundleComparison struct {
+	BaseBundleID   string `json:"baseBundleId"`
+	TargetBundleID string `json:"targetBundleId"`
+
+	Analyzers   []AnalyzerChange      `json:"analyzers"`
+	Resources   []ResourceChange      `json:"resources"`
+	Nodes       []NodeConditionChange `json:"nodes"`
+	Logs        []LogErrors           `json:"logs"`
+	AppArchives []AppArchiveChange    `json:"appArchives"`
+	AppVersions []AppVersionChange    `json:"appVersions"`
+	ConfigItems []ConfigItemChange    `json:"configItems"`
+}
+
+// AnalyzerChange is an analyzer whose outcome is different in the target bundle, the severity is empty when the analyzer didn't run
+type AnalyzerChange struct {
+	Key            string `json:"key"`
+	Title          string `json:"title"`
+	BaseSeverity   string `json:"baseSeverity"`
+	TargetSeverity string `json:"targetSeverity"`
+	Detail         string `json:"detail"`
+}
+
+type ResourceChange struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Change    string `json:"change"`
+}
+
+// NodeConditionChange is a node condition whose status is different in the target bundle, the status is empty when the node is missing
+type NodeConditionChange struct {
+	Node         string `json:"node"`
+	Condition    string `json:"condition"`
+	BaseStatus   string `json:"baseStatus"`
+	TargetStatus string `json:"targetStatus"`
+}
+
+// LogErrors is a log file that has errors in the target bundle and had none in the base bundle
+type LogErrors struct {
+	Path       string   `json:"path"`
+	ErrorCount int      `json:"errorCount"`
+	ErrorLines []string `json:"errorLines"`
+}
+
+// AppArchiveChange is a file of the app version archive that is different in the target bundle
+type AppArchiveChange struct {
+	App    string `json:"app"`
+	Path   string `json:"path"`
+	Change string `json:"change"`
+}
+
+// AppVersionChange is an app that runs a different version in the target bundle, the sequence is the channel sequence of the release
+type AppVersionChange struct {
+	App                string `json:"app"`
+	BaseVersionLabel   string `json:"baseVersionLabel"`
+	TargetVersionLabel string `json:"targetVersionLabel"`
+	BaseSequence       string `json:"baseSequence"`
+	TargetSequence     string `json:"targetSequence"`
+}
+
+// ConfigItemChange is a config item whose definition or value is different in the target bundle.
+// The values are not included, they can be secrets.
+type ConfigItemChange struct {
+	App    string `json:"app"`
+	Key    string `json:"key"`
+	Change string `json:"change"`
+}
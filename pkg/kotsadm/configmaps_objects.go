@@ -11,8 +11,9 @@ import (
 
 func kotsadmConfigMap(deployOptions types.DeployOptions) *corev1.ConfigMap {
 	data := map[string]string{
-		"initial-app-images-pushed": fmt.Sprintf("%v", deployOptions.AppImagesPushed),
-		"skip-preflights":           fmt.Sprintf("%v", deployOptions.SkipPreflights),
+		"initial-app-images-pushed":  fmt.Sprintf("%v", deployOptions.AppImagesPushed),
+		"skip-preflights":            fmt.Sprintf("%v", deployOptions.SkipPreflights),
+		"override-strict-preflights": fmt.Sprintf("%v", deployOptions.OverrideStrictPreflights),
 	}
 	if kotsadmversion.KotsadmPullSecret(deployOptions.Namespace, deployOptions.KotsadmOptions) != nil {
 		data["kotsadm-registry"] = kotsadmversion.KotsadmRegistry(deployOptions.KotsadmOptions)
@@ -569,8 +569,9 @@ func SupportBundleToAnalyzer(sb *troubleshootv1beta2.SupportBundle) *troubleshoo
 }
 
 type InstallationParams struct {
-	SkipImagePush  bool
-	SkipPreflights bool
+	SkipImagePush            bool
+	SkipPreflights           bool
+	OverrideStrictPreflights bool
 }
 
 func GetInstallationParams(configMapName string) (InstallationParams, error) {
@@ -597,6 +598,7 @@ func GetInstallationParams(configMapName string) (InstallationParams, error) {
 
 	autoConfig.SkipImagePush, _ = strconv.ParseBool(kotsadmConfigMap.Data["initial-app-images-pushed"])
 	autoConfig.SkipPreflights, _ = strconv.ParseBool(kotsadmConfigMap.Data["skip-preflights"])
+	autoConfig.OverrideStrictPreflights, _ = strconv.ParseBool(kotsadmConfigMap.Data["override-strict-preflights"])
 
 	return autoConfig, nil
 }
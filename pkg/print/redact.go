@@ -0,0 +1,51 @@
+package print
+
+import (
+	"encoding/json"
+	"fmt"
+
+	redacttypes "github.com/replicatedhq/kots/pkg/api/redact/types"
+)
+
+func RedactPreview(preview *redacttypes.RedactPreview, format string) {
+	switch format {
+	case "json":
+		printRedactPreviewJSON(preview)
+	default:
+		printRedactPreviewTable(preview)
+	}
+}
+
+func printRedactPreviewJSON(preview *redacttypes.RedactPreview) {
+	str, _ := json.MarshalIndent(preview, "", "    ")
+	fmt.Println(string(str))
+}
+
+func printRedactPreviewTable(preview *redacttypes.RedactPreview) {
+	w := NewTabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, "REDACTOR\tTYPE\tINDEX\tPATTERN\tMATCHES\n")
+	for _, removal := range preview.Removals {
+		matches := fmt.Sprintf("%d", removal.Matches)
+		if removal.NeverMatched {
+			matches = "0 (never matched)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", removal.Redactor, removal.Type, removal.Index, printableValue(removal.Pattern), matches)
+	}
+
+	fmt.Fprintf(w, "\nFILE\tMATCHES\tLINE\tBEFORE\tAFTER\n")
+	for _, file := range preview.Files {
+		if len(file.Snippets) == 0 {
+			fmt.Fprintf(w, "%s\t%d\t\t\t\n", file.Path, file.Matches)
+			continue
+		}
+		for i, snippet := range file.Snippets {
+			path, matches := "", ""
+			if i == 0 {
+				path, matches = file.Path, fmt.Sprintf("%d", file.Matches)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", path, matches, snippet.Line, snippet.Before, snippet.After)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package print
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/replicatedhq/kots/pkg/snapshot/types"
 	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func Restores(restores []velerov1.Restore) {
@@ -32,3 +35,41 @@ func Restores(restores []velerov1.Restore) {
 		fmt.Fprintf(w, fmtColumns, r.ObjectMeta.Name, r.Spec.BackupName, phase, startedAt, completedAt, fmt.Sprintf("%d", r.Status.Errors), fmt.Sprintf("%d", r.Status.Warnings))
 	}
 }
+
+func RestorePlans(plans []types.RestorePlan) {
+	w := NewTabWriter()
+	defer w.Flush()
+
+	fmtColumns := "%s\t%s\t%s\t%s\t%s\n"
+	fmt.Fprintf(w, fmtColumns, "RESTORE", "NAMESPACES", "RESOURCES", "LABELS", "VOLUMES")
+	for _, p := range plans {
+		namespaces := restorePlanFilter(p.Spec.IncludedNamespaces, p.Spec.ExcludedNamespaces)
+		resources := restorePlanFilter(p.Spec.IncludedResources, p.Spec.ExcludedResources)
+
+		labels := "*"
+		if p.Spec.LabelSelector != nil {
+			labels = metav1.FormatLabelSelector(p.Spec.LabelSelector)
+		}
+
+		volumes := []string{}
+		for _, v := range p.Volumes {
+			volumes = append(volumes, v.String())
+		}
+		if len(volumes) == 0 {
+			volumes = append(volumes, "<none>")
+		}
+
+		fmt.Fprintf(w, fmtColumns, p.RestoreName, namespaces, resources, labels, strings.Join(volumes, ","))
+	}
+}
+
+func restorePlanFilter(included []string, excluded []string) string {
+	filter := "*"
+	if len(included) > 0 {
+		filter = strings.Join(included, ",")
+	}
+	if len(excluded) > 0 {
+		filter = fmt.Sprintf("%s (excluding %s)", filter, strings.Join(excluded, ","))
+	}
+	return filter
+}
@@ -0,0 +1,63 @@
+package print
+
+import (
+	"encoding/json"
+	"fmt"
+
+	supportbundletypes "github.com/replicatedhq/kots/pkg/api/supportbundle/types"
+)
+
+func SupportBundleComparison(comparison *supportbundletypes.BundleComparison, format string) {
+	switch format {
+	case "json":
+		printSupportBundleComparisonJSON(comparison)
+	default:
+		printSupportBundleComparisonTable(comparison)
+	}
+}
+
+func printSupportBundleComparisonJSON(comparison *supportbundletypes.BundleComparison) {
+	str, _ := json.MarshalIndent(comparison, "", "    ")
+	fmt.Println(string(str))
+}
+
+func printSupportBundleComparisonTable(comparison *supportbundletypes.BundleComparison) {
+	w := NewTabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, "ANALYZER\tBASE\tTARGET\tDETAIL\n")
+	for _, analyzer := range comparison.Analyzers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", analyzer.Title, printableValue(analyzer.BaseSeverity), printableValue(analyzer.TargetSeverity), analyzer.Detail)
+	}
+
+	fmt.Fprintf(w, "\nKIND\tNAMESPACE\tNAME\tCHANGE\n")
+	for _, resource := range comparison.Resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", resource.Kind, printableValue(resource.Namespace), resource.Name, resource.Change)
+	}
+
+	fmt.Fprintf(w, "\nNODE\tCONDITION\tBASE\tTARGET\n")
+	for _, node := range comparison.Nodes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", node.Node, node.Condition, printableValue(node.BaseStatus), printableValue(node.TargetStatus))
+	}
+
+	fmt.Fprintf(w, "\nLOG\tERRORS\tFIRST ERROR\n")
+	for _, log := range comparison.Logs {
+		firstError := ""
+		if len(log.ErrorLines) > 0 {
+			firstError = log.ErrorLines[0]
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", log.Path, log.ErrorCount, firstError)
+	}
+
+	fmt.Fprintf(w, "\nAPP\tFILE\tCHANGE\n")
+	for _, file := range comparison.AppArchives {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", file.App, file.Path, file.Change)
+	}
+}
+
+func printableValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
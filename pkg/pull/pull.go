@@ -13,6 +13,7 @@ import (
 
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/airgap"
 	"github.com/replicatedhq/kots/pkg/archives"
 	"github.com/replicatedhq/kots/pkg/base"
 	"github.com/replicatedhq/kots/pkg/crypto"
@@ -219,7 +220,7 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 			return "", util.ActionableError{Message: "License is expired"}
 		}
 
-		airgap, err := findAirgapMetaInDir(pullOptions.AirgapRoot)
+		airgap, err := FindAirgapMetaInDir(pullOptions.AirgapRoot)
 		if err != nil {
 			return "", errors.Wrap(err, "failed to parse license from file")
 		}
@@ -251,6 +252,11 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		return "", errors.Wrap(err, "failed to fetch upstream")
 	}
 
+	if err := upstream.CheckKotsVersion(u); err != nil {
+		log.FinishSpinnerWithError()
+		return "", err
+	}
+
 	includeAdminConsole := uri.Scheme == "replicated" && !pullOptions.ExcludeAdminConsole
 
 	writeUpstreamOptions := upstreamtypes.WriteOptions{
@@ -436,6 +442,17 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 				if err != nil {
 					return "", errors.Wrap(err, "failed to push upstream images")
 				}
+
+				// the images in the registry are recorded so that delta airgap bundles can be built against this release
+				newInstallation, err := upstream.LoadInstallation(u.GetUpstreamDir(writeUpstreamOptions))
+				if err != nil {
+					return "", errors.Wrap(err, "failed to load installation")
+				}
+				newInstallation.Spec.AirgapImages = airgap.InstallationImages(rewrittenImages)
+				err = upstream.SaveInstallation(newInstallation, u.GetUpstreamDir(writeUpstreamOptions))
+				if err != nil {
+					return "", errors.Wrap(err, "failed to save installation")
+				}
 			}
 
 			findObjectsOptions := base.FindObjectsWithImagesOptions{
@@ -735,7 +752,8 @@ func parseInstallationFromFile(filename string) (*kotsv1beta1.Installation, erro
 	return installation, nil
 }
 
-func findAirgapMetaInDir(root string) (*kotsv1beta1.Airgap, error) {
+// FindAirgapMetaInDir returns the airgap meta of an extracted airgap bundle, or nil if there's none
+func FindAirgapMetaInDir(root string) (*kotsv1beta1.Airgap, error) {
 	files, err := ioutil.ReadDir(root)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -0,0 +1,188 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/snapshot/types"
+)
+
+// BackupArchiveWriter writes the files of a backup archive and ends it with a manifest of their checksums.
+// The archive is streamed after the response status is sent, so the manifest is how readers know that it is complete.
+type BackupArchiveWriter struct {
+	tarWriter *tar.Writer
+	manifest  types.BackupArchiveManifest
+}
+
+func NewBackupArchiveWriter(tarWriter *tar.Writer) *BackupArchiveWriter {
+	return &BackupArchiveWriter{
+		tarWriter: tarWriter,
+	}
+}
+
+// WriteFile writes a file with the size in the header
+func (w *BackupArchiveWriter) WriteFile(header *tar.Header, r io.Reader) error {
+	if header.Name == types.BackupArchiveManifestName {
+		return errors.Errorf("%s is reserved for the archive manifest", header.Name)
+	}
+
+	if err := w.tarWriter.WriteHeader(header); err != nil {
+		return errors.Wrap(err, "failed to write header")
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w.tarWriter, h), r)
+	if err != nil {
+		return errors.Wrap(err, "failed to copy file")
+	}
+	if n != header.Size {
+		return errors.Errorf("expected %d bytes, got %d", header.Size, n)
+	}
+
+	w.manifest.Files = append(w.manifest.Files, types.BackupArchiveFile{
+		Name:   header.Name,
+		Size:   n,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	})
+
+	return nil
+}
+
+// Close writes the manifest, it does not close the tar writer
+func (w *BackupArchiveWriter) Close() error {
+	manifest, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal manifest")
+	}
+
+	err = w.tarWriter.WriteHeader(&tar.Header{
+		Name: types.BackupArchiveManifestName,
+		Mode: 0644,
+		Size: int64(len(manifest)),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to write manifest header")
+	}
+	if _, err := w.tarWriter.Write(manifest); err != nil {
+		return errors.Wrap(err, "failed to write manifest")
+	}
+
+	return nil
+}
+
+// BackupArchiveReader reads the files of a backup archive and verifies them against the manifest at the end.
+// Next returns io.EOF only after the manifest has been verified.
+type BackupArchiveReader struct {
+	tarReader *tar.Reader
+	current   *tar.Header
+	hash      hash.Hash
+	size      int64
+	files     []types.BackupArchiveFile
+}
+
+func NewBackupArchiveReader(tarReader *tar.Reader) *BackupArchiveReader {
+	return &BackupArchiveReader{
+		tarReader: tarReader,
+	}
+}
+
+// Next advances to the next file in the archive, the rest of the current file is read to checksum it
+func (r *BackupArchiveReader) Next() (*tar.Header, error) {
+	if err := r.finishCurrent(); err != nil {
+		return nil, err
+	}
+
+	header, err := r.tarReader.Next()
+	if err == io.EOF {
+		return nil, errors.Errorf("archive is incomplete, %s not found", types.BackupArchiveManifestName)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read archive")
+	}
+
+	if header.Name == types.BackupArchiveManifestName {
+		if err := r.verifyManifest(); err != nil {
+			return nil, errors.Wrap(err, "failed to verify archive")
+		}
+		return nil, io.EOF
+	}
+
+	r.current = header
+	r.hash = sha256.New()
+	r.size = 0
+
+	return header, nil
+}
+
+func (r *BackupArchiveReader) Read(p []byte) (int, error) {
+	if r.current == nil {
+		return 0, io.EOF
+	}
+
+	n, err := r.tarReader.Read(p)
+	r.hash.Write(p[:n])
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *BackupArchiveReader) finishCurrent() error {
+	if r.current == nil {
+		return nil
+	}
+
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return errors.Wrapf(err, "failed to read %s", r.current.Name)
+	}
+
+	r.files = append(r.files, types.BackupArchiveFile{
+		Name:   r.current.Name,
+		Size:   r.size,
+		SHA256: hex.EncodeToString(r.hash.Sum(nil)),
+	})
+	r.current = nil
+
+	return nil
+}
+
+func (r *BackupArchiveReader) verifyManifest() error {
+	manifest := types.BackupArchiveManifest{}
+	if err := json.NewDecoder(r.tarReader).Decode(&manifest); err != nil {
+		return errors.Wrap(err, "failed to decode manifest")
+	}
+
+	if _, err := r.tarReader.Next(); err != io.EOF {
+		return errors.Errorf("unexpected file after %s", types.BackupArchiveManifestName)
+	}
+
+	if len(manifest.Files) != len(r.files) {
+		return errors.Errorf("manifest lists %d files, archive has %d", len(manifest.Files), len(r.files))
+	}
+	for i, file := range r.files {
+		if !reflect.DeepEqual(file, manifest.Files[i]) {
+			return errors.Errorf("%s does not match the manifest", file.Name)
+		}
+	}
+
+	return nil
+}
+
+// VerifyBackupArchive reads a whole backup archive and checks it against its manifest
+func VerifyBackupArchive(tarReader *tar.Reader) error {
+	archiveReader := NewBackupArchiveReader(tarReader)
+	for {
+		_, err := archiveReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func writeTestBackupArchive(t *testing.T, files [][2]string, withManifest bool) []byte {
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	archiveWriter := NewBackupArchiveWriter(tarWriter)
+	for _, file := range files {
+		header := &tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1]))}
+		if err := archiveWriter.WriteFile(header, strings.NewReader(file[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if withManifest {
+		if err := archiveWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyBackupArchive(t *testing.T) {
+	files := [][2]string{
+		{"kotsadm-backup.json", `{"backupName":"instance-abcd"}`},
+		{"restic/default/config", "repository config"},
+		{"backups/instance-abcd/velero-backup.json", `{"kind":"Backup"}`},
+	}
+
+	archive := writeTestBackupArchive(t, files, true)
+	if err := VerifyBackupArchive(tar.NewReader(bytes.NewReader(archive))); err != nil {
+		t.Errorf("Expected archive to verify, got %v", err)
+	}
+
+	withoutManifest := writeTestBackupArchive(t, files, false)
+	if err := VerifyBackupArchive(tar.NewReader(bytes.NewReader(withoutManifest))); err == nil {
+		t.Error("Expected error for an archive without a manifest")
+	}
+
+	tampered := bytes.Replace(archive, []byte("repository config"), []byte("repository CONFIG"), 1)
+	if err := VerifyBackupArchive(tar.NewReader(bytes.NewReader(tampered))); err == nil {
+		t.Error("Expected error for an archive that does not match its manifest")
+	}
+
+	// the files are checksummed even when they are only partly read
+	archiveReader := NewBackupArchiveReader(tar.NewReader(bytes.NewReader(archive)))
+	if _, err := archiveReader.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiveReader.Read(make([]byte, 4)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err := archiveReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Expected partly read archive to verify, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/snapshot/types"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	veleroclientv1 "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/typed/velero/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ListBackupPodVolumeLabels reads the pods and claims of the backed up pod volumes from the contents of the backup
+func ListBackupPodVolumeLabels(ctx context.Context, veleroClient veleroclientv1.VeleroV1Interface, veleroNamespace string, backupName string, volumes []types.PodVolume) (map[types.PodVolume]types.PodVolumeLabels, error) {
+	if len(volumes) == 0 {
+		return map[types.PodVolume]types.PodVolumeLabels{}, nil
+	}
+
+	signedURL, err := getBackupContentsURL(ctx, veleroClient, veleroNamespace, backupName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get backup contents url")
+	}
+
+	resp, err := http.Get(signedURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download backup contents")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Errorf("unexpected status code %d downloading backup contents: %s", resp.StatusCode, string(body))
+	}
+
+	gzipReader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gzip reader")
+	}
+	defer gzipReader.Close()
+
+	return readPodVolumeLabels(gzipReader, volumes)
+}
+
+func getBackupContentsURL(ctx context.Context, veleroClient veleroclientv1.VeleroV1Interface, veleroNamespace string, backupName string) (string, error) {
+	downloadRequest := &velerov1.DownloadRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "kotsadm-contents-",
+			Namespace:    veleroNamespace,
+		},
+		Spec: velerov1.DownloadRequestSpec{
+			Target: velerov1.DownloadTarget{
+				Kind: velerov1.DownloadTargetKindBackupContents,
+				Name: backupName,
+			},
+		},
+	}
+
+	created, err := veleroClient.DownloadRequests(veleroNamespace).Create(ctx, downloadRequest, metav1.CreateOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create download request")
+	}
+	defer func() {
+		_ = veleroClient.DownloadRequests(veleroNamespace).Delete(context.TODO(), created.Name, metav1.DeleteOptions{})
+	}()
+
+	// generally takes less than a second
+	timeout := time.After(30 * time.Second)
+	for {
+		current, err := veleroClient.DownloadRequests(veleroNamespace).Get(ctx, created.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get download request")
+		}
+		if current.Status.DownloadURL != "" {
+			return current.Status.DownloadURL, nil
+		}
+
+		select {
+		case <-timeout:
+			return "", errors.New("timeout waiting for the download url")
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// readPodVolumeLabels reads the backup contents tarball, in which velero stores resources as
+// resources/<resource>[/<version>]/namespaces/<namespace>/<name>.json
+func readPodVolumeLabels(r io.Reader, volumes []types.PodVolume) (map[types.PodVolume]types.PodVolumeLabels, error) {
+	pods := map[string]corev1.Pod{}
+	claims := map[string]corev1.PersistentVolumeClaim{}
+
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to read backup contents")
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		resource, namespace, name, ok := parseBackupResourcePath(header.Name)
+		if !ok {
+			continue
+		}
+
+		switch resource {
+		case "pods":
+			pod := corev1.Pod{}
+			if err := json.NewDecoder(tarReader).Decode(&pod); err != nil {
+				return nil, errors.Wrapf(err, "failed to decode %s", header.Name)
+			}
+			pods[path.Join(namespace, name)] = pod
+		case "persistentvolumeclaims":
+			claim := corev1.PersistentVolumeClaim{}
+			if err := json.NewDecoder(tarReader).Decode(&claim); err != nil {
+				return nil, errors.Wrapf(err, "failed to decode %s", header.Name)
+			}
+			claims[path.Join(namespace, name)] = claim
+		}
+	}
+
+	volumeLabels := map[types.PodVolume]types.PodVolumeLabels{}
+	for _, volume := range volumes {
+		pod, ok := pods[path.Join(volume.Namespace, volume.Pod)]
+		if !ok {
+			return nil, errors.Errorf("pod of volume %s not found in backup", volume)
+		}
+
+		labels := types.PodVolumeLabels{
+			PodLabels: pod.Labels,
+		}
+		for _, podVolume := range pod.Spec.Volumes {
+			if podVolume.Name != volume.Volume || podVolume.PersistentVolumeClaim == nil {
+				continue
+			}
+			labels.Claim = podVolume.PersistentVolumeClaim.ClaimName
+			claim, ok := claims[path.Join(volume.Namespace, labels.Claim)]
+			if !ok {
+				return nil, errors.Errorf("claim %s of volume %s not found in backup", labels.Claim, volume)
+			}
+			labels.ClaimLabels = claim.Labels
+		}
+		volumeLabels[volume] = labels
+	}
+
+	return volumeLabels, nil
+}
+
+func parseBackupResourcePath(filename string) (string, string, string, bool) {
+	parts := strings.Split(strings.TrimPrefix(filename, "/"), "/")
+	if len(parts) < 5 || parts[0] != "resources" || path.Ext(filename) != ".json" {
+		return "", "", "", false
+	}
+	n := len(parts)
+	if parts[n-3] != "namespaces" {
+		return "", "", "", false
+	}
+	return parts[1], parts[n-2], strings.TrimSuffix(parts[n-1], ".json"), true
+}
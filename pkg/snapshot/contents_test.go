@@ -0,0 +1,72 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/snapshot/types"
+)
+
+func TestReadPodVolumeLabels(t *testing.T) {
+	files := map[string]string{
+		"resources/pods/namespaces/monitoring/prometheus-0.json": `{
+  "metadata": {"name": "prometheus-0", "namespace": "monitoring", "labels": {"app": "prometheus"}},
+  "spec": {"volumes": [
+    {"name": "storage", "persistentVolumeClaim": {"claimName": "storage-prometheus-0"}},
+    {"name": "config", "configMap": {"name": "prometheus"}}
+  ]}
+}`,
+		"resources/persistentvolumeclaims/v1-preferredversion/namespaces/monitoring/storage-prometheus-0.json": `{
+  "metadata": {"name": "storage-prometheus-0", "namespace": "monitoring", "labels": {"app": "prometheus", "component": "server"}}
+}`,
+		"resources/pods/namespaces/default/web-1234.json": `{
+  "metadata": {"name": "web-1234", "namespace": "default", "labels": {"app": "web"}},
+  "spec": {"volumes": [{"name": "uploads", "emptyDir": {}}]}
+}`,
+		"resources/persistentvolumes/cluster/pvc-1234.json": `{}`,
+		"metadata/version": "1",
+	}
+
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	storage := types.PodVolume{Namespace: "monitoring", Pod: "prometheus-0", Volume: "storage"}
+	uploads := types.PodVolume{Namespace: "default", Pod: "web-1234", Volume: "uploads"}
+
+	volumeLabels, err := readPodVolumeLabels(bytes.NewReader(buf.Bytes()), []types.PodVolume{storage, uploads})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[types.PodVolume]types.PodVolumeLabels{
+		storage: {
+			Claim:       "storage-prometheus-0",
+			PodLabels:   map[string]string{"app": "prometheus"},
+			ClaimLabels: map[string]string{"app": "prometheus", "component": "server"},
+		},
+		uploads: {
+			PodLabels: map[string]string{"app": "web"},
+		},
+	}
+	if !reflect.DeepEqual(volumeLabels, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, volumeLabels)
+	}
+
+	missing := types.PodVolume{Namespace: "default", Pod: "postgres-0", Volume: "data"}
+	if _, err := readPodVolumeLabels(bytes.NewReader(buf.Bytes()), []types.PodVolume{missing}); err == nil {
+		t.Error("Expected error for a pod that is not in the backup")
+	}
+}
@@ -0,0 +1,192 @@
+// Package encryption derives the restic repository password of an encrypted snapshot store from a customer managed key.
+//
+// Only volume data is encrypted client side, by restic. The backup metadata that velero writes to the store, the
+// velero-backup.json and the tarball of kubernetes resources including secrets, is not encrypted by velero.
+// With an aws kms key velero asks s3 to encrypt it server side with the same key, with a passphrase or with other
+// providers it is stored as is, and the bucket must be protected with the encryption of the provider.
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	KeyTypePassphrase = "passphrase"
+	KeyTypeKMS        = "kms"
+
+	// BackupAnnotation is set on backups taken while the snapshot store is encrypted, it holds the envelope of the store
+	BackupAnnotation = "kots.io/snapshot-encryption"
+
+	pbkdf2Iterations = 100000
+	dataKeyLength    = 32
+)
+
+var (
+	ErrKeyRequired = errors.New("the snapshot store is encrypted, an encryption key is required")
+	ErrWrongKey    = errors.New("the encryption key does not match the key the snapshots were encrypted with")
+)
+
+// Key is the customer managed key that snapshot data is encrypted with.
+// Exactly one of a passphrase or a KMS key id is set.
+type Key struct {
+	Passphrase string `json:"passphrase,omitempty"`
+	KMSKeyID   string `json:"kmsKeyId,omitempty"`
+}
+
+func (k *Key) Type() string {
+	if k == nil {
+		return ""
+	}
+	if k.KMSKeyID != "" {
+		return KeyTypeKMS
+	}
+	if k.Passphrase != "" {
+		return KeyTypePassphrase
+	}
+	return ""
+}
+
+func (k *Key) Validate() error {
+	if k.Passphrase != "" && k.KMSKeyID != "" {
+		return errors.New("only one of a passphrase or a kms key id can be set")
+	}
+	if k.Type() == "" {
+		return errors.New("a passphrase or a kms key id is required")
+	}
+	return nil
+}
+
+// KeyManagementService wraps the data key of a snapshot store with a master key that never leaves the service
+type KeyManagementService interface {
+	GenerateDataKey(keyID string) (plaintext []byte, ciphertext []byte, err error)
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// Envelope describes how the data key of an encrypted snapshot store is derived from the customer managed key.
+// It holds no secrets and is stored next to the backups so that they can be restored in another cluster.
+type Envelope struct {
+	KeyType          string `json:"keyType"`
+	KMSKeyID         string `json:"kmsKeyId,omitempty"`
+	Salt             []byte `json:"salt,omitempty"`
+	EncryptedDataKey []byte `json:"encryptedDataKey,omitempty"`
+	// KeyCheck proves that a key or a restic repository password belongs to the envelope
+	KeyCheck []byte `json:"keyCheck"`
+}
+
+// NewEnvelope creates a new data key for the key and returns it with its envelope
+func NewEnvelope(key *Key, kms KeyManagementService) (*Envelope, []byte, error) {
+	if err := key.Validate(); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid key")
+	}
+
+	envelope := &Envelope{
+		KeyType: key.Type(),
+	}
+
+	var dataKey []byte
+	switch envelope.KeyType {
+	case KeyTypePassphrase:
+		envelope.Salt = make([]byte, 16)
+		if _, err := rand.Read(envelope.Salt); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to generate salt")
+		}
+		dataKey = passphraseDataKey(key.Passphrase, envelope.Salt)
+
+	case KeyTypeKMS:
+		if kms == nil {
+			return nil, nil, errors.New("a key management service is required for kms keys")
+		}
+		plaintext, ciphertext, err := kms.GenerateDataKey(key.KMSKeyID)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to generate data key")
+		}
+		envelope.KMSKeyID = key.KMSKeyID
+		envelope.EncryptedDataKey = ciphertext
+		dataKey = plaintext
+	}
+
+	envelope.KeyCheck = keyCheck(ResticPassword(dataKey))
+
+	return envelope, dataKey, nil
+}
+
+// DataKey derives the data key of the envelope from the key, ErrWrongKey is returned if the key is not the one the envelope was created with
+func (e *Envelope) DataKey(key *Key, kms KeyManagementService) ([]byte, error) {
+	if key.Type() == "" {
+		return nil, ErrKeyRequired
+	}
+	if key.Type() != e.KeyType {
+		return nil, errors.Wrapf(ErrWrongKey, "snapshots are encrypted with a %s key", e.KeyType)
+	}
+
+	var dataKey []byte
+	switch e.KeyType {
+	case KeyTypePassphrase:
+		dataKey = passphraseDataKey(key.Passphrase, e.Salt)
+
+	case KeyTypeKMS:
+		if key.KMSKeyID != e.KMSKeyID {
+			return nil, errors.Wrapf(ErrWrongKey, "snapshots are encrypted with kms key %s", e.KMSKeyID)
+		}
+		if kms == nil {
+			return nil, errors.New("a key management service is required for kms keys")
+		}
+		plaintext, err := kms.Decrypt(e.KMSKeyID, e.EncryptedDataKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt data key")
+		}
+		dataKey = plaintext
+
+	default:
+		return nil, errors.Errorf("unknown key type %q", e.KeyType)
+	}
+
+	if !e.VerifyResticPassword(ResticPassword(dataKey)) {
+		return nil, ErrWrongKey
+	}
+
+	return dataKey, nil
+}
+
+// VerifyResticPassword reports if the restic repository password was derived from the data key of the envelope
+func (e *Envelope) VerifyResticPassword(password string) bool {
+	return hmac.Equal(keyCheck(password), e.KeyCheck)
+}
+
+// ResticPassword is the restic repository password derived from the data key.
+// Restic encrypts volume data client-side with a key that is protected by this password.
+func ResticPassword(dataKey []byte) string {
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write([]byte("restic-repository-password"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func ParseEnvelope(data string) (*Envelope, error) {
+	envelope := &Envelope{}
+	if err := json.Unmarshal([]byte(data), envelope); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal envelope")
+	}
+	return envelope, nil
+}
+
+func (e *Envelope) String() string {
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+func passphraseDataKey(passphrase string, salt []byte) []byte {
+	return pbkdf2.Key([]byte(passphrase), salt, pbkdf2Iterations, dataKeyLength, sha256.New)
+}
+
+func keyCheck(resticPassword string) []byte {
+	mac := hmac.New(sha256.New, []byte(resticPassword))
+	mac.Write([]byte("kots-snapshot-key-check"))
+	return mac.Sum(nil)
+}
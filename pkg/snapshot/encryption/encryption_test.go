@@ -0,0 +1,98 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestPassphraseEnvelope(t *testing.T) {
+	key := &Key{Passphrase: "correct horse battery staple"}
+
+	envelope, dataKey, err := NewEnvelope(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope.KeyType != KeyTypePassphrase {
+		t.Errorf("Expected key type %q, got %q", KeyTypePassphrase, envelope.KeyType)
+	}
+
+	parsed, err := ParseEnvelope(envelope.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	derived, err := parsed.DataKey(&Key{Passphrase: "correct horse battery staple"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dataKey, derived) {
+		t.Error("Expected the same data key to be derived from the passphrase")
+	}
+	if !parsed.VerifyResticPassword(ResticPassword(dataKey)) {
+		t.Error("Expected the restic password to be verified")
+	}
+
+	if _, err := parsed.DataKey(&Key{Passphrase: "wrong"}, nil); errors.Cause(err) != ErrWrongKey {
+		t.Errorf("Expected wrong key error, got %v", err)
+	}
+	if _, err := parsed.DataKey(nil, nil); errors.Cause(err) != ErrKeyRequired {
+		t.Errorf("Expected key required error, got %v", err)
+	}
+	if parsed.VerifyResticPassword("static-passw0rd") {
+		t.Error("Expected the default restic password not to be verified")
+	}
+
+	other, otherDataKey, err := NewEnvelope(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(other.Salt, envelope.Salt) || bytes.Equal(otherDataKey, dataKey) {
+		t.Error("Expected a new salt and data key for each envelope")
+	}
+}
+
+func TestKMSEnvelope(t *testing.T) {
+	kms := &LocalKeyManagementService{
+		MasterKeys: map[string][]byte{
+			"key-1": bytes.Repeat([]byte{1}, 32),
+			"key-2": bytes.Repeat([]byte{2}, 32),
+		},
+	}
+
+	envelope, dataKey, err := NewEnvelope(&Key{KMSKeyID: "key-1"}, kms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(envelope.EncryptedDataKey, dataKey) {
+		t.Error("Expected the data key to be encrypted in the envelope")
+	}
+
+	derived, err := envelope.DataKey(&Key{KMSKeyID: "key-1"}, kms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dataKey, derived) {
+		t.Error("Expected the data key to be decrypted")
+	}
+
+	if _, err := envelope.DataKey(&Key{KMSKeyID: "key-2"}, kms); errors.Cause(err) != ErrWrongKey {
+		t.Errorf("Expected wrong key error, got %v", err)
+	}
+	if _, err := envelope.DataKey(&Key{Passphrase: "passphrase"}, kms); errors.Cause(err) != ErrWrongKey {
+		t.Errorf("Expected wrong key error, got %v", err)
+	}
+}
+
+func TestKeyValidate(t *testing.T) {
+	if err := (&Key{}).Validate(); err == nil {
+		t.Error("Expected an empty key to be invalid")
+	}
+	if err := (&Key{Passphrase: "a", KMSKeyID: "b"}).Validate(); err == nil {
+		t.Error("Expected a key with a passphrase and a kms key id to be invalid")
+	}
+	if err := (&Key{KMSKeyID: "b"}).Validate(); err != nil {
+		t.Errorf("Expected a kms key to be valid, got %v", err)
+	}
+}
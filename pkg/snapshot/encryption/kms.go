@@ -0,0 +1,115 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/pkg/errors"
+)
+
+type awsKeyManagementService struct {
+	client *kms.KMS
+}
+
+// NewAWSKeyManagementService uses AWS KMS with the given config.
+// The region is taken from the key id when it is an arn and no region is configured.
+func NewAWSKeyManagementService(awsConfig *aws.Config, keyID string) (KeyManagementService, error) {
+	if aws.StringValue(awsConfig.Region) == "" {
+		// arn:aws:kms:<region>:<account>:key/<id>
+		parts := strings.Split(keyID, ":")
+		if len(parts) > 3 && parts[0] == "arn" {
+			awsConfig.Region = aws.String(parts[3])
+		}
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create aws session")
+	}
+
+	return &awsKeyManagementService{
+		client: kms.New(sess),
+	}, nil
+}
+
+func (s *awsKeyManagementService) GenerateDataKey(keyID string) ([]byte, []byte, error) {
+	output, err := s.client.GenerateDataKey(&kms.GenerateDataKeyInput{
+		KeyId:   aws.String(keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate data key")
+	}
+	return output.Plaintext, output.CiphertextBlob, nil
+}
+
+func (s *awsKeyManagementService) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	output, err := s.client.Decrypt(&kms.DecryptInput{
+		KeyId:          aws.String(keyID),
+		CiphertextBlob: ciphertext,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt data key")
+	}
+	return output.Plaintext, nil
+}
+
+// LocalKeyManagementService is a stand-in for a KMS that wraps data keys with master keys kept in memory
+type LocalKeyManagementService struct {
+	MasterKeys map[string][]byte
+}
+
+func (s *LocalKeyManagementService) GenerateDataKey(keyID string) ([]byte, []byte, error) {
+	gcm, err := s.cipher(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext := make([]byte, dataKeyLength)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate data key")
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate nonce")
+	}
+
+	return plaintext, gcm.Seal(nonce, nonce, plaintext, []byte(keyID)), nil
+}
+
+func (s *LocalKeyManagementService) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	gcm, err := s.cipher(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt data key")
+	}
+	return plaintext, nil
+}
+
+func (s *LocalKeyManagementService) cipher(keyID string) (cipher.AEAD, error) {
+	masterKey, ok := s.MasterKeys[keyID]
+	if !ok {
+		return nil, errors.Errorf("key %s not found", keyID)
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create gcm")
+	}
+	return gcm, nil
+}
@@ -0,0 +1,293 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/auth"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/logger"
+	veleroclientv1 "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/typed/velero/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+type ExportInstanceBackupOptions struct {
+	BackupName            string
+	Namespace             string
+	OutputFile            string
+	KubernetesConfigFlags *genericclioptions.ConfigFlags
+}
+
+type ImportInstanceBackupOptions struct {
+	InputFile             string
+	Namespace             string
+	KubernetesConfigFlags *genericclioptions.ConfigFlags
+	Wait                  bool
+	Timeout               time.Duration
+}
+
+// ExportInstanceBackup downloads a backup with its volume data from kotsadm as a portable archive
+func ExportInstanceBackup(options ExportInstanceBackupOptions) error {
+	log := logger.NewLogger()
+	log.ActionWithSpinner("Connecting to cluster")
+
+	localPort, stopCh, err := portForwardKotsadm(options.Namespace, options.KubernetesConfigFlags, log)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to connect to kotsadm")
+	}
+	defer close(stopCh)
+
+	log.FinishSpinner()
+	log.ActionWithSpinner("Exporting Backup")
+
+	authSlug, err := auth.GetOrCreateAuthSlug(options.KubernetesConfigFlags, options.Namespace)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to get kotsadm auth slug")
+	}
+
+	url := fmt.Sprintf("http://localhost:%d/api/v1/snapshot/%s/export", localPort, options.BackupName)
+
+	newRequest, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to create export request")
+	}
+	newRequest.Header.Add("Authorization", authSlug)
+
+	resp, err := http.DefaultClient.Do(newRequest)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to get from kotsadm")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.FinishSpinnerWithError()
+		return errors.Errorf("unexpected status code from %s: %s", url, resp.Status)
+	}
+
+	// the response status is sent before the archive is streamed, so errors while exporting only show in the archive
+	if err := writeBackupArchive(options.OutputFile, resp.Body); err != nil {
+		os.Remove(options.OutputFile)
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to download backup archive")
+	}
+
+	log.FinishSpinner()
+	log.ActionWithoutSpinner("Backup %s exported to %s", options.BackupName, options.OutputFile)
+
+	return nil
+}
+
+// writeBackupArchive writes the archive to the file while verifying it against the manifest at its end
+func writeBackupArchive(filename string, r io.Reader) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return errors.Wrap(err, "failed to create output file")
+	}
+	defer f.Close()
+
+	gzipReader, err := gzip.NewReader(io.TeeReader(r, f))
+	if err != nil {
+		return errors.Wrap(err, "failed to create gzip reader")
+	}
+	defer gzipReader.Close()
+
+	if err := VerifyBackupArchive(tar.NewReader(gzipReader)); err != nil {
+		return errors.Wrap(err, "failed to verify archive")
+	}
+
+	// read to the end of the gzip stream to check its checksum
+	if _, err := io.Copy(ioutil.Discard, gzipReader); err != nil {
+		return errors.Wrap(err, "failed to read archive")
+	}
+
+	return nil
+}
+
+// verifyBackupArchiveFile checks an archive before it is uploaded and rewinds the file
+func verifyBackupArchiveFile(f *os.File) error {
+	gzipReader, err := gzip.NewReader(f)
+	if err != nil {
+		return errors.Wrap(err, "failed to create gzip reader")
+	}
+	defer gzipReader.Close()
+
+	if err := VerifyBackupArchive(tar.NewReader(gzipReader)); err != nil {
+		return err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "failed to rewind file")
+	}
+
+	return nil
+}
+
+// ImportInstanceBackup uploads a backup archive to the snapshot store through kotsadm
+func ImportInstanceBackup(options ImportInstanceBackupOptions) error {
+	f, err := os.Open(options.InputFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to open input file")
+	}
+	defer f.Close()
+
+	if err := verifyBackupArchiveFile(f); err != nil {
+		return errors.Wrapf(err, "failed to verify %s", options.InputFile)
+	}
+
+	log := logger.NewLogger()
+	log.ActionWithSpinner("Connecting to cluster")
+
+	localPort, stopCh, err := portForwardKotsadm(options.Namespace, options.KubernetesConfigFlags, log)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to connect to kotsadm")
+	}
+	defer close(stopCh)
+
+	log.FinishSpinner()
+	log.ActionWithSpinner("Importing Backup")
+
+	authSlug, err := auth.GetOrCreateAuthSlug(options.KubernetesConfigFlags, options.Namespace)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to get kotsadm auth slug")
+	}
+
+	url := fmt.Sprintf("http://localhost:%d/api/v1/snapshots/import", localPort)
+
+	newRequest, err := http.NewRequest("POST", url, f)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to create import request")
+	}
+	newRequest.Header.Add("Authorization", authSlug)
+	newRequest.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := http.DefaultClient.Do(newRequest)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to post to kotsadm")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to read server response")
+	}
+
+	type ImportResponse struct {
+		Success    bool   `json:"success"`
+		BackupName string `json:"backupName,omitempty"`
+		Error      string `json:"error,omitempty"`
+	}
+	var importResponse ImportResponse
+	if err := json.Unmarshal(respBody, &importResponse); err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrapf(err, "failed to unmarshal response with status %s", resp.Status)
+	}
+
+	if importResponse.Error != "" {
+		log.FinishSpinnerWithError()
+		return errors.New(importResponse.Error)
+	}
+
+	if !options.Wait {
+		log.FinishSpinner()
+		log.ActionWithoutSpinner("Backup %s imported. It will be available once Velero syncs the snapshot store.", importResponse.BackupName)
+		return nil
+	}
+
+	// velero creates the backup when it syncs the backups in the store
+	if err := waitForVeleroBackupSynced(importResponse.BackupName, options.Timeout); err != nil {
+		log.FinishSpinnerWithError()
+		return errors.Wrap(err, "failed to wait for velero to sync the backup")
+	}
+
+	log.FinishSpinner()
+	log.ActionWithoutSpinner("Backup %s imported. Run kots restore --from-backup %s to restore it.", importResponse.BackupName, importResponse.BackupName)
+
+	return nil
+}
+
+func portForwardKotsadm(namespace string, kubernetesConfigFlags *genericclioptions.ConfigFlags, log *logger.Logger) (int, chan struct{}, error) {
+	clientset, err := k8sutil.GetClientset(kubernetesConfigFlags)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "failed to get clientset")
+	}
+
+	podName, err := k8sutil.FindKotsadm(clientset, namespace)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "failed to find kotsadm pod")
+	}
+
+	stopCh := make(chan struct{})
+
+	localPort, errChan, err := k8sutil.PortForward(kubernetesConfigFlags, 0, 3000, namespace, podName, false, stopCh, log)
+	if err != nil {
+		close(stopCh)
+		return 0, nil, errors.Wrap(err, "failed to start port forwarding")
+	}
+
+	go func() {
+		select {
+		case err := <-errChan:
+			if err != nil {
+				log.Error(err)
+			}
+		case <-stopCh:
+		}
+	}()
+
+	return localPort, stopCh, nil
+}
+
+func waitForVeleroBackupSynced(backupName string, timeout time.Duration) error {
+	bsl, err := findBackupStoreLocation()
+	if err != nil {
+		return errors.Wrap(err, "failed to get velero namespace")
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster config")
+	}
+
+	veleroClient, err := veleroclientv1.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create clientset")
+	}
+
+	start := time.Now()
+	for {
+		_, err := veleroClient.Backups(bsl.Namespace).Get(context.TODO(), backupName, metav1.GetOptions{})
+		if err == nil {
+			return nil
+		}
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get backup")
+		}
+
+		if time.Now().Sub(start) > timeout {
+			return errors.Errorf("backup %s was not synced after %s", backupName, timeout)
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -1,11 +1,13 @@
 package snapshot
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/replicatedhq/kots/pkg/kotsadm"
 	kotsadmtypes "github.com/replicatedhq/kots/pkg/kotsadm/types"
 	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/replicatedhq/kots/pkg/snapshot/types"
 	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	veleroclientv1 "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/typed/velero/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -26,6 +29,8 @@ type RestoreInstanceBackupOptions struct {
 	BackupName            string
 	KubernetesConfigFlags *genericclioptions.ConfigFlags
 	WaitForApps           bool
+	// RestoreOptions select what is restored for the applications, the admin console is always fully restored
+	RestoreOptions *types.RestoreOptions
 }
 
 type ListInstanceRestoresOptions struct {
@@ -61,6 +66,11 @@ func RestoreInstanceBackup(options RestoreInstanceBackupOptions) (*velerov1.Rest
 		return nil, errors.Wrap(err, "backup provided is not an instance backup")
 	}
 
+	// validate the restore options before anything is deleted
+	if _, err := planInstanceAppRestores(veleroClient, backup, options.RestoreOptions); err != nil {
+		return nil, errors.Wrap(err, "failed to plan application restores")
+	}
+
 	kotsadmImage, ok := backup.Annotations["kots.io/kotsadm-image"]
 	if !ok {
 		return nil, errors.Wrap(err, "failed to find kotsadm image annotation")
@@ -172,7 +182,7 @@ func RestoreInstanceBackup(options RestoreInstanceBackupOptions) (*velerov1.Rest
 	log.ActionWithSpinner("Restoring Applications")
 
 	// initiate kotsadm applications restore
-	err = initiateKotsadmApplicationsRestore(options.BackupName, kotsadmNamespace, kotsadmPodName, options.KubernetesConfigFlags, log)
+	err = initiateKotsadmApplicationsRestore(options.BackupName, options.RestoreOptions, kotsadmNamespace, kotsadmPodName, options.KubernetesConfigFlags, log)
 	if err != nil {
 		log.FinishSpinnerWithError()
 		return nil, errors.Wrap(err, "failed to restore kotsadm applications")
@@ -200,6 +210,97 @@ func RestoreInstanceBackup(options RestoreInstanceBackupOptions) (*velerov1.Rest
 	return restore, nil
 }
 
+// PlanInstanceRestore returns what would be restored for each application in the backup without restoring anything
+func PlanInstanceRestore(options RestoreInstanceBackupOptions) ([]types.RestorePlan, error) {
+	bsl, err := findBackupStoreLocation()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get velero namespace")
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	veleroClient, err := veleroclientv1.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create velero clientset")
+	}
+
+	backup, err := veleroClient.Backups(bsl.Namespace).Get(context.TODO(), options.BackupName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find backup")
+	}
+
+	if backup.Annotations["kots.io/instance"] != "true" {
+		return nil, errors.New("backup provided is not an instance backup")
+	}
+
+	plans, err := planInstanceAppRestores(veleroClient, backup, options.RestoreOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to plan application restores")
+	}
+
+	return plans, nil
+}
+
+// planInstanceAppRestores plans the restores that kotsadm creates for the applications in an instance backup
+func planInstanceAppRestores(veleroClient veleroclientv1.VeleroV1Interface, backup *velerov1.Backup, restoreOptions *types.RestoreOptions) ([]types.RestorePlan, error) {
+	appsSequences := map[string]int64{}
+	if b, ok := backup.Annotations["kots.io/apps-sequences"]; ok {
+		if err := json.Unmarshal([]byte(b), &appsSequences); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal apps sequences")
+		}
+	}
+
+	backupVolumes, err := ListBackupPodVolumes(context.TODO(), veleroClient, backup.Namespace, backup.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list backup volumes")
+	}
+
+	var volumeLabels map[types.PodVolume]types.PodVolumeLabels
+	if restoreOptions.IsSelective() {
+		volumeLabels, err = ListBackupPodVolumeLabels(context.TODO(), veleroClient, backup.Namespace, backup.Name, backupVolumes)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list backup volume labels")
+		}
+	}
+
+	appSlugs := []string{}
+	for appSlug := range appsSequences {
+		appSlugs = append(appSlugs, appSlug)
+	}
+	sort.Strings(appSlugs)
+
+	trueVal := true
+	plans := []types.RestorePlan{}
+	for _, appSlug := range appSlugs {
+		plan := types.RestorePlan{
+			RestoreName: fmt.Sprintf("%s.%s", backup.Name, appSlug),
+			Spec: velerov1.RestoreSpec{
+				BackupName: backup.Name,
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"kots.io/app-slug": appSlug,
+					},
+				},
+				RestorePVs:              &trueVal,
+				IncludeClusterResources: &trueVal,
+			},
+		}
+
+		volumes, err := ApplyRestoreOptions(&plan.Spec, restoreOptions, backupVolumes, volumeLabels)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to apply restore options for app %s", appSlug)
+		}
+		plan.Volumes = volumes
+
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
 func ListInstanceRestores(options ListInstanceRestoresOptions) ([]velerov1.Restore, error) {
 	bsl, err := findBackupStoreLocation()
 	if err != nil {
@@ -279,7 +380,7 @@ func waitForVeleroRestoreCompleted(restoreName string) (*velerov1.Restore, error
 	}
 }
 
-func initiateKotsadmApplicationsRestore(backupName string, kotsadmNamespace string, kotsadmPodName string, kubernetesConfigFlags *genericclioptions.ConfigFlags, log *logger.Logger) error {
+func initiateKotsadmApplicationsRestore(backupName string, restoreOptions *types.RestoreOptions, kotsadmNamespace string, kotsadmPodName string, kubernetesConfigFlags *genericclioptions.ConfigFlags, log *logger.Logger) error {
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 
@@ -305,11 +406,20 @@ func initiateKotsadmApplicationsRestore(backupName string, kotsadmNamespace stri
 
 	url := fmt.Sprintf("http://localhost:%d/api/v1/snapshot/%s/restore-apps", localPort, backupName)
 
-	newRequest, err := http.NewRequest("POST", url, nil)
+	requestPayload := map[string]interface{}{
+		"options": restoreOptions,
+	}
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal request json")
+	}
+
+	newRequest, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return errors.Wrap(err, "failed to create request")
 	}
 	newRequest.Header.Add("Authorization", authSlug)
+	newRequest.Header.Set("Content-Type", "application/json")
 
 	resp, err := http.DefaultClient.Do(newRequest)
 	if err != nil {
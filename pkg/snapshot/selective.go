@@ -0,0 +1,282 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/snapshot/types"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	veleroclientv1 "github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/typed/velero/v1"
+	velerolabel "github.com/vmware-tanzu/velero/pkg/label"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+)
+
+// ListBackupPodVolumes lists the pod volumes that were backed up with restic in a backup
+func ListBackupPodVolumes(ctx context.Context, veleroClient veleroclientv1.VeleroV1Interface, veleroNamespace string, backupName string) ([]types.PodVolume, error) {
+	podVolumeBackups, err := veleroClient.PodVolumeBackups(veleroNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("velero.io/backup-name=%s", velerolabel.GetValidName(backupName)),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pod volume backups")
+	}
+
+	volumes := []types.PodVolume{}
+	for _, podVolumeBackup := range podVolumeBackups.Items {
+		volumes = append(volumes, types.PodVolume{
+			Namespace: podVolumeBackup.Spec.Pod.Namespace,
+			Pod:       podVolumeBackup.Spec.Pod.Name,
+			Volume:    podVolumeBackup.Spec.Volume,
+		})
+	}
+
+	return volumes, nil
+}
+
+// ApplyRestoreOptions narrows the restore spec down to the selected namespaces, resources, labels and volumes,
+// and returns the backed up pod volumes that the restore will restore.
+// Velero can't select resources by name, so included volumes are selected with the labels that their pods and claims
+// have in common. The labels are read from the backup with ListBackupPodVolumeLabels, without them volumes can't be
+// included and label options are not considered for the restored volumes.
+// Velero restores pod volumes together with their pods, so a volume can't be excluded while its pod is restored.
+func ApplyRestoreOptions(spec *velerov1.RestoreSpec, options *types.RestoreOptions, backupVolumes []types.PodVolume, volumeLabels map[types.PodVolume]types.PodVolumeLabels) ([]types.PodVolume, error) {
+	if options == nil {
+		options = &types.RestoreOptions{}
+	}
+
+	includeVolumes, err := findPodVolumes(options.IncludeVolumes, backupVolumes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find included volumes")
+	}
+	excludeVolumes, err := findPodVolumes(options.ExcludeVolumes, backupVolumes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find excluded volumes")
+	}
+
+	spec.IncludedNamespaces = options.IncludeNamespaces
+	spec.ExcludedNamespaces = options.ExcludeNamespaces
+	spec.IncludedResources = options.IncludeResources
+	spec.ExcludedResources = options.ExcludeResources
+
+	includeLabels := options.IncludeLabels
+	if len(includeVolumes) > 0 {
+		selector, err := podVolumesSelector(includeVolumes, volumeLabels)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range selector {
+			includeLabels = append(includeLabels, fmt.Sprintf("%s=%s", key, value))
+		}
+
+		namespaces := []string{}
+		for _, volume := range includeVolumes {
+			if !containsString(namespaces, volume.Namespace) {
+				namespaces = append(namespaces, volume.Namespace)
+			}
+		}
+		spec.IncludedNamespaces = namespaces
+
+		if len(spec.IncludedResources) == 0 {
+			spec.IncludedResources = []string{"pods", "persistentvolumeclaims", "persistentvolumes"}
+		}
+	}
+
+	if err := applyRestoreLabels(spec, includeLabels, options.ExcludeLabels); err != nil {
+		return nil, errors.Wrap(err, "failed to apply labels")
+	}
+
+	for _, volume := range includeVolumes {
+		if !isPodVolumeRestored(spec, volume, volumeLabels) {
+			return nil, errors.Errorf("volume %s is excluded by the other restore options", volume)
+		}
+		if len(options.IncludeNamespaces) > 0 && !containsString(options.IncludeNamespaces, volume.Namespace) {
+			return nil, errors.Errorf("volume %s is not in the included namespaces", volume)
+		}
+	}
+
+	restoredVolumes := []types.PodVolume{}
+	for _, volume := range backupVolumes {
+		if !isPodVolumeRestored(spec, volume, volumeLabels) {
+			continue
+		}
+		if containsPodVolume(excludeVolumes, volume) {
+			return nil, errors.Errorf("volume %s can't be excluded while pod %s is restored, exclude namespace %s or the pods resource instead", volume, volume.Pod, volume.Namespace)
+		}
+		restoredVolumes = append(restoredVolumes, volume)
+	}
+
+	return restoredVolumes, nil
+}
+
+func findPodVolumes(names []string, backupVolumes []types.PodVolume) ([]types.PodVolume, error) {
+	volumes := []types.PodVolume{}
+	for _, name := range names {
+		found := false
+		for _, backupVolume := range backupVolumes {
+			if backupVolume.String() == name {
+				volumes = append(volumes, backupVolume)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("volume %s not found in backup, volumes must be in the form namespace/pod/volume", name)
+		}
+	}
+	return volumes, nil
+}
+
+// podVolumesSelector returns the labels that the pods of the volumes and the claims the volumes are mounted from have in common
+func podVolumesSelector(volumes []types.PodVolume, volumeLabels map[types.PodVolume]types.PodVolumeLabels) (map[string]string, error) {
+	var selector map[string]string
+	intersect := func(labels map[string]string) {
+		if selector == nil {
+			selector = map[string]string{}
+			for key, value := range labels {
+				selector[key] = value
+			}
+			return
+		}
+		for key, value := range selector {
+			if labels[key] != value {
+				delete(selector, key)
+			}
+		}
+	}
+
+	names := []string{}
+	for _, volume := range volumes {
+		labels, ok := volumeLabels[volume]
+		if !ok {
+			return nil, errors.Errorf("labels of volume %s are not known", volume)
+		}
+		intersect(labels.PodLabels)
+		if labels.Claim != "" {
+			intersect(labels.ClaimLabels)
+		}
+		names = append(names, volume.String())
+	}
+
+	if len(selector) == 0 {
+		return nil, errors.Errorf("%s can't be restored on its own because the pods and claims have no labels in common, use the label options instead", strings.Join(names, ", "))
+	}
+
+	return selector, nil
+}
+
+// applyRestoreLabels adds the labels to the label selector of the restore.
+// Included labels must match, excluded labels must not match.
+func applyRestoreLabels(spec *velerov1.RestoreSpec, includeLabels []string, excludeLabels []string) error {
+	if len(includeLabels) == 0 && len(excludeLabels) == 0 {
+		return nil
+	}
+
+	if spec.LabelSelector == nil {
+		spec.LabelSelector = &metav1.LabelSelector{}
+	}
+
+	for _, label := range includeLabels {
+		key, value, hasValue, err := parseRestoreLabel(label)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse included label %q", label)
+		}
+		if !hasValue {
+			spec.LabelSelector.MatchExpressions = append(spec.LabelSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+				Key:      key,
+				Operator: metav1.LabelSelectorOpExists,
+			})
+			continue
+		}
+		if spec.LabelSelector.MatchLabels == nil {
+			spec.LabelSelector.MatchLabels = map[string]string{}
+		}
+		spec.LabelSelector.MatchLabels[key] = value
+	}
+
+	for _, label := range excludeLabels {
+		key, value, hasValue, err := parseRestoreLabel(label)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse excluded label %q", label)
+		}
+		requirement := metav1.LabelSelectorRequirement{
+			Key:      key,
+			Operator: metav1.LabelSelectorOpDoesNotExist,
+		}
+		if hasValue {
+			requirement.Operator = metav1.LabelSelectorOpNotIn
+			requirement.Values = []string{value}
+		}
+		spec.LabelSelector.MatchExpressions = append(spec.LabelSelector.MatchExpressions, requirement)
+	}
+
+	return nil
+}
+
+func parseRestoreLabel(label string) (string, string, bool, error) {
+	parts := strings.SplitN(label, "=", 2)
+	key := strings.TrimSpace(parts[0])
+	if key == "" {
+		return "", "", false, errors.New("label key is empty")
+	}
+	if len(parts) == 1 {
+		return key, "", false, nil
+	}
+	return key, strings.TrimSpace(parts[1]), true, nil
+}
+
+// isPodVolumeRestored returns true if the filters of the restore include the pod of the volume.
+// The label selector is only considered when the labels of the pod are known.
+func isPodVolumeRestored(spec *velerov1.RestoreSpec, volume types.PodVolume, volumeLabels map[types.PodVolume]types.PodVolumeLabels) bool {
+	if len(spec.IncludedNamespaces) > 0 && !containsString(spec.IncludedNamespaces, "*") && !containsString(spec.IncludedNamespaces, volume.Namespace) {
+		return false
+	}
+	if containsString(spec.ExcludedNamespaces, volume.Namespace) {
+		return false
+	}
+	if len(spec.IncludedResources) > 0 && !containsString(spec.IncludedResources, "*") && !containsPodsResource(spec.IncludedResources) {
+		return false
+	}
+	if containsPodsResource(spec.ExcludedResources) {
+		return false
+	}
+	if labels, ok := volumeLabels[volume]; ok && spec.LabelSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.LabelSelector)
+		if err != nil {
+			return false
+		}
+		if !selector.Matches(k8slabels.Set(labels.PodLabels)) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsPodsResource(resources []string) bool {
+	for _, resource := range resources {
+		switch strings.ToLower(resource) {
+		case "pods", "pod", "po":
+			return true
+		}
+	}
+	return false
+}
+
+func containsPodVolume(volumes []types.PodVolume, volume types.PodVolume) bool {
+	for _, v := range volumes {
+		if v == volume {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package snapshot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/snapshot/types"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyRestoreOptions(t *testing.T) {
+	backupVolumes := []types.PodVolume{
+		{Namespace: "default", Pod: "postgres-0", Volume: "data"},
+		{Namespace: "default", Pod: "web-1234", Volume: "uploads"},
+		{Namespace: "monitoring", Pod: "prometheus-0", Volume: "storage"},
+	}
+	volumeLabels := map[types.PodVolume]types.PodVolumeLabels{
+		backupVolumes[0]: {
+			Claim:       "data-postgres-0",
+			PodLabels:   map[string]string{"app": "postgres", "statefulset.kubernetes.io/pod-name": "postgres-0"},
+			ClaimLabels: map[string]string{"app": "postgres"},
+		},
+		backupVolumes[1]: {
+			PodLabels: map[string]string{"app": "web", "tier": "frontend"},
+		},
+		backupVolumes[2]: {
+			Claim:       "storage-prometheus-0",
+			PodLabels:   map[string]string{"app": "prometheus", "statefulset.kubernetes.io/pod-name": "prometheus-0"},
+			ClaimLabels: map[string]string{"app": "prometheus"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		options     *types.RestoreOptions
+		unknown     bool // the labels of the volumes were not read from the backup
+		wantSpec    velerov1.RestoreSpec
+		wantVolumes []types.PodVolume
+		wantErr     bool
+	}{
+		{
+			name:        "everything",
+			wantSpec:    velerov1.RestoreSpec{},
+			wantVolumes: backupVolumes,
+		},
+		{
+			name: "exclude namespace",
+			options: &types.RestoreOptions{
+				ExcludeNamespaces: []string{"monitoring"},
+			},
+			wantSpec: velerov1.RestoreSpec{
+				ExcludedNamespaces: []string{"monitoring"},
+			},
+			wantVolumes: backupVolumes[:2],
+		},
+		{
+			name: "include volume",
+			options: &types.RestoreOptions{
+				IncludeVolumes: []string{"monitoring/prometheus-0/storage"},
+			},
+			wantSpec: velerov1.RestoreSpec{
+				IncludedNamespaces: []string{"monitoring"},
+				IncludedResources:  []string{"pods", "persistentvolumeclaims", "persistentvolumes"},
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "prometheus"},
+				},
+			},
+			wantVolumes: backupVolumes[2:],
+		},
+		{
+			name: "include volume with unknown labels",
+			options: &types.RestoreOptions{
+				IncludeVolumes: []string{"monitoring/prometheus-0/storage"},
+			},
+			unknown: true,
+			wantErr: true,
+		},
+		{
+			name: "include volumes without labels in common",
+			options: &types.RestoreOptions{
+				IncludeVolumes: []string{"default/postgres-0/data", "monitoring/prometheus-0/storage"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "include volume without pods",
+			options: &types.RestoreOptions{
+				IncludeVolumes:   []string{"monitoring/prometheus-0/storage"},
+				IncludeResources: []string{"persistentvolumeclaims"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "include volume outside included namespaces",
+			options: &types.RestoreOptions{
+				IncludeVolumes:    []string{"monitoring/prometheus-0/storage"},
+				IncludeNamespaces: []string{"default"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown volume",
+			options: &types.RestoreOptions{
+				IncludeVolumes: []string{"default/postgres-0/logs"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "exclude volume of restored pod",
+			options: &types.RestoreOptions{
+				ExcludeVolumes: []string{"default/web-1234/uploads"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "exclude volume with its namespace",
+			options: &types.RestoreOptions{
+				ExcludeNamespaces: []string{"default"},
+				ExcludeVolumes:    []string{"default/web-1234/uploads"},
+			},
+			wantSpec: velerov1.RestoreSpec{
+				ExcludedNamespaces: []string{"default"},
+			},
+			wantVolumes: backupVolumes[2:],
+		},
+		{
+			name: "exclude pods",
+			options: &types.RestoreOptions{
+				ExcludeResources: []string{"pods"},
+			},
+			wantSpec: velerov1.RestoreSpec{
+				ExcludedResources: []string{"pods"},
+			},
+			wantVolumes: []types.PodVolume{},
+		},
+		{
+			name: "labels",
+			options: &types.RestoreOptions{
+				IncludeLabels: []string{"app=web", "tier"},
+				ExcludeLabels: []string{"env=test", "skip-restore"},
+			},
+			wantSpec: velerov1.RestoreSpec{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "web"},
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "tier", Operator: metav1.LabelSelectorOpExists},
+						{Key: "env", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"test"}},
+						{Key: "skip-restore", Operator: metav1.LabelSelectorOpDoesNotExist},
+					},
+				},
+			},
+			wantVolumes: backupVolumes[1:2],
+		},
+		{
+			name: "labels of unknown volumes",
+			options: &types.RestoreOptions{
+				IncludeLabels: []string{"app=web"},
+			},
+			unknown: true,
+			wantSpec: velerov1.RestoreSpec{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "web"},
+				},
+			},
+			wantVolumes: backupVolumes,
+		},
+		{
+			name: "empty label",
+			options: &types.RestoreOptions{
+				IncludeLabels: []string{"=web"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := velerov1.RestoreSpec{}
+			labels := volumeLabels
+			if test.unknown {
+				labels = nil
+			}
+			volumes, err := ApplyRestoreOptions(&spec, test.options, backupVolumes, labels)
+			if test.wantErr {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(spec, test.wantSpec) {
+				t.Errorf("Expected spec %+v, got %+v", test.wantSpec, spec)
+			}
+			if !reflect.DeepEqual(volumes, test.wantVolumes) {
+				t.Errorf("Expected volumes %v, got %v", test.wantVolumes, volumes)
+			}
+		})
+	}
+}
+
+func TestApplyRestoreOptionsKeepsAppSelector(t *testing.T) {
+	spec := velerov1.RestoreSpec{
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"kots.io/app-slug": "my-app"},
+		},
+	}
+	options := &types.RestoreOptions{
+		IncludeLabels: []string{"app=postgres"},
+	}
+	if _, err := ApplyRestoreOptions(&spec, options, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"kots.io/app-slug": "my-app", "app": "postgres"}
+	if !reflect.DeepEqual(spec.LabelSelector.MatchLabels, expected) {
+		t.Errorf("Expected %v, got %v", expected, spec.LabelSelector.MatchLabels)
+	}
+}
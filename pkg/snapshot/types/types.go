@@ -0,0 +1,108 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+// RestoreOptions selects what is restored from a backup. Empty lists select everything in the backup.
+type RestoreOptions struct {
+	IncludeNamespaces []string `json:"includeNamespaces,omitempty"`
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+	IncludeResources  []string `json:"includeResources,omitempty"`
+	ExcludeResources  []string `json:"excludeResources,omitempty"`
+	// IncludeLabels and ExcludeLabels are "key=value" or "key" selectors
+	IncludeLabels []string `json:"includeLabels,omitempty"`
+	ExcludeLabels []string `json:"excludeLabels,omitempty"`
+	// IncludeVolumes and ExcludeVolumes are pod volumes in the form "namespace/pod/volume"
+	IncludeVolumes []string `json:"includeVolumes,omitempty"`
+	ExcludeVolumes []string `json:"excludeVolumes,omitempty"`
+}
+
+func (o *RestoreOptions) IsSelective() bool {
+	if o == nil {
+		return false
+	}
+	return len(o.IncludeNamespaces) > 0 || len(o.ExcludeNamespaces) > 0 ||
+		len(o.IncludeResources) > 0 || len(o.ExcludeResources) > 0 ||
+		len(o.IncludeLabels) > 0 || len(o.ExcludeLabels) > 0 ||
+		len(o.IncludeVolumes) > 0 || len(o.ExcludeVolumes) > 0
+}
+
+// PodVolume is a pod volume that was backed up with restic
+type PodVolume struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Volume    string `json:"volume"`
+}
+
+func (v PodVolume) String() string {
+	return fmt.Sprintf("%s/%s/%s", v.Namespace, v.Pod, v.Volume)
+}
+
+// PodVolumeLabels are the labels of the pod of a backed up volume, and of the claim the volume is mounted from
+type PodVolumeLabels struct {
+	// Claim is empty when the volume is not mounted from a claim
+	Claim       string            `json:"claim,omitempty"`
+	PodLabels   map[string]string `json:"podLabels,omitempty"`
+	ClaimLabels map[string]string `json:"claimLabels,omitempty"`
+}
+
+// RestorePlan describes what a restore would restore without creating it
+type RestorePlan struct {
+	RestoreName string               `json:"restoreName"`
+	Spec        velerov1.RestoreSpec `json:"spec"`
+	Volumes     []PodVolume          `json:"volumes"`
+}
+
+// BackupArchiveManifestName is the last file in a backup archive, an archive without it is incomplete
+const BackupArchiveManifestName = "kotsadm-backup-manifest.json"
+
+// BackupArchiveManifest lists the files in a backup archive before the manifest
+type BackupArchiveManifest struct {
+	Files []BackupArchiveFile `json:"files"`
+}
+
+type BackupArchiveFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// RetentionPolicy is a grandfather-father-son retention policy for scheduled snapshots.
+// The newest snapshot of each of the last Hourly hours, Daily days, Weekly weeks and Monthly months is kept.
+type RetentionPolicy struct {
+	Hourly  int `json:"hourly"`
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+}
+
+func (p *RetentionPolicy) IsEnabled() bool {
+	if p == nil {
+		return false
+	}
+	return p.Hourly > 0 || p.Daily > 0 || p.Weekly > 0 || p.Monthly > 0
+}
+
+// MaxAge is the age of the oldest snapshot the policy can keep
+func (p *RetentionPolicy) MaxAge() time.Duration {
+	if p == nil {
+		return 0
+	}
+
+	day := 24 * time.Hour
+	maxAge := time.Duration(p.Hourly+1) * time.Hour
+	if age := time.Duration(p.Daily+1) * day; p.Daily > 0 && age > maxAge {
+		maxAge = age
+	}
+	if age := time.Duration(p.Weekly+1) * 7 * day; p.Weekly > 0 && age > maxAge {
+		maxAge = age
+	}
+	if age := time.Duration(p.Monthly+1) * 31 * day; p.Monthly > 0 && age > maxAge {
+		maxAge = age
+	}
+	return maxAge
+}
@@ -0,0 +1,100 @@
+package upstream
+
+import (
+	"fmt"
+
+	semver "github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/replicatedhq/kots/pkg/version"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// UnsupportedKotsVersionError is returned when a release requires a newer version of kots
+// than the one currently running
+type UnsupportedKotsVersionError struct {
+	MinKotsVersion     string
+	CurrentKotsVersion string
+}
+
+func (e UnsupportedKotsVersionError) Error() string {
+	return fmt.Sprintf("This release requires KOTS version %s or later, but the admin console is running %s. Upgrade the admin console first, then check for updates again.", e.MinKotsVersion, e.CurrentKotsVersion)
+}
+
+// CheckKotsVersion returns an UnsupportedKotsVersionError if the application spec
+// in the upstream requires a newer version of kots than the one running
+func CheckKotsVersion(u *types.Upstream) error {
+	app := findKotsApplication(u)
+	if app == nil {
+		return nil
+	}
+
+	compatible, err := IsKotsVersionCompatible(app.Spec.MinKotsVersion)
+	if err != nil {
+		return errors.Wrap(err, "failed to check min kots version")
+	}
+	if !compatible {
+		return UnsupportedKotsVersionError{
+			MinKotsVersion:     app.Spec.MinKotsVersion,
+			CurrentKotsVersion: version.Version(),
+		}
+	}
+
+	return nil
+}
+
+// IsKotsVersionCompatible returns true if the running kots version satisfies minKotsVersion
+func IsKotsVersionCompatible(minKotsVersion string) (bool, error) {
+	if minKotsVersion == "" {
+		return true, nil
+	}
+	return isKotsVersionAtLeast(version.Version(), minKotsVersion)
+}
+
+// NeedsKotsUpgrade returns true if the running kots version is older than targetKotsVersion
+func NeedsKotsUpgrade(targetKotsVersion string) (bool, error) {
+	if targetKotsVersion == "" {
+		return false, nil
+	}
+	atLeast, err := isKotsVersionAtLeast(version.Version(), targetKotsVersion)
+	if err != nil {
+		return false, err
+	}
+	return !atLeast, nil
+}
+
+func isKotsVersionAtLeast(current string, required string) (bool, error) {
+	requiredSemver, err := semver.NewVersion(required)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse required version %q", required)
+	}
+
+	// development builds don't carry a real version and are never blocked
+	if current == "" || current == "v0.0.0-unknown" {
+		return true, nil
+	}
+
+	currentSemver, err := semver.NewVersion(current)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse current version %q", current)
+	}
+
+	return !currentSemver.LessThan(requiredSemver), nil
+}
+
+func findKotsApplication(u *types.Upstream) *kotsv1beta1.Application {
+	for _, file := range u.Files {
+		decode := scheme.Codecs.UniversalDeserializer().Decode
+		obj, gvk, err := decode(file.Content, nil, nil)
+		if err != nil {
+			continue
+		}
+
+		if gvk.Group == "kots.io" && gvk.Version == "v1beta1" && gvk.Kind == "Application" {
+			return obj.(*kotsv1beta1.Application)
+		}
+	}
+
+	return nil
+}
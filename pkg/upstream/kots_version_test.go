@@ -0,0 +1,68 @@
+package upstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isKotsVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  string
+		required string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name:     "same version",
+			current:  "v1.30.0",
+			required: "1.30.0",
+			want:     true,
+		},
+		{
+			name:     "newer version",
+			current:  "v1.31.2",
+			required: "v1.30.0",
+			want:     true,
+		},
+		{
+			name:     "older version",
+			current:  "v1.29.3",
+			required: "v1.30.0",
+			want:     false,
+		},
+		{
+			name:     "prerelease is older than release",
+			current:  "v1.30.0-beta.1",
+			required: "v1.30.0",
+			want:     false,
+		},
+		{
+			name:     "development build",
+			current:  "v0.0.0-unknown",
+			required: "v1.30.0",
+			want:     true,
+		},
+		{
+			name:     "invalid required version",
+			current:  "v1.30.0",
+			required: "latest",
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			got, err := isKotsVersionAtLeast(test.current, test.required)
+			if test.wantErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+			req.Equal(test.want, got)
+		})
+	}
+}